@@ -0,0 +1,120 @@
+// Copyright (c) 2021 the SC authors. All rights reserved. MIT License.
+
+package sc
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"reflect"
+	"strings"
+
+	"github.com/sc-lang/go-sc/scparse"
+)
+
+// byteEncoding is the representation of a []byte value in SC.
+type byteEncoding int
+
+const (
+	bytesBase64    byteEncoding = iota // string using standard base64, the default
+	bytesBase64URL                     // string using unpadded URL-safe base64
+	bytesHex                           // string using hex
+	bytesList                          // list of numbers
+)
+
+// byteEncodingOption returns the encoding set by a tag option in opts.
+func byteEncodingOption(opts tagOptions) byteEncoding {
+	switch {
+	case opts.Contains("hex"):
+		return bytesHex
+	case opts.Contains("base64url"):
+		return bytesBase64URL
+	case opts.Contains("bytelist"):
+		return bytesList
+	}
+	return bytesBase64
+}
+
+// isByteSlice reports whether t is a []byte that is encoded as a single SC value
+// instead of a list of elements.
+func isByteSlice(t reflect.Type) bool {
+	if t.Kind() != reflect.Slice || t.Elem().Kind() != reflect.Uint8 {
+		return false
+	}
+	p := reflect.PtrTo(t.Elem())
+	return !p.Implements(marshalerType) && !p.Implements(textMarshalerType)
+}
+
+// decodeByteString decodes the []byte contained in the string s using enc.
+func decodeByteString(s string, enc byteEncoding) ([]byte, error) {
+	switch enc {
+	case bytesHex:
+		return hex.DecodeString(s)
+	case bytesBase64URL:
+		// Accept padded strings as well
+		return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+	}
+	return base64.StdEncoding.DecodeString(s)
+}
+
+// decodeBytes decodes n into v, which is a []byte or a pointer to one,
+// using enc if n is a string. It reports whether n was handled,
+// other values, ex: lists of numbers, are left to be decoded normally.
+func (d *decoder) decodeBytes(n scparse.ValueNode, v reflect.Value, enc byteEncoding) bool {
+	if !v.IsValid() {
+		return false
+	}
+	var s string
+	switch sn := n.(type) {
+	case *scparse.InterpolatedStringNode:
+		var ok bool
+		if s, ok = d.interpolate(sn); !ok {
+			return true
+		}
+	case *scparse.RawStringNode:
+		s = sn.Value
+	case *scparse.MultilineStringNode:
+		s = sn.Value
+	default:
+		return false
+	}
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+	b, err := decodeByteString(s, enc)
+	if err != nil {
+		d.saveError(err)
+		return true
+	}
+	v.SetBytes(b)
+	return true
+}
+
+// encodeBytes encodes v, which is a []byte or a pointer to one, using enc.
+func (e *encoder) encodeBytes(v reflect.Value, enc byteEncoding) scparse.ValueNode {
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return &scparse.NullNode{}
+		}
+		v = v.Elem()
+	}
+	if v.IsNil() && !e.emptyCollections {
+		return &scparse.NullNode{}
+	}
+	b := v.Bytes()
+	switch enc {
+	case bytesHex:
+		return newDoubleString(hex.EncodeToString(b))
+	case bytesBase64URL:
+		return newDoubleString(base64.RawURLEncoding.EncodeToString(b))
+	case bytesList:
+		elements := make([]scparse.ValueNode, len(b))
+		for i, c := range b {
+			elements[i] = &scparse.NumberNode{IsUint: true, Uint64: uint64(c)}
+		}
+		return &scparse.ListNode{Elements: elements}
+	}
+	return newDoubleString(base64.StdEncoding.EncodeToString(b))
+}
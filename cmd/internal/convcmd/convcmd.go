@@ -0,0 +1,160 @@
+// Copyright (c) 2021 the SC authors. All rights reserved. MIT License.
+
+// Package convcmd implements the command line handling shared by the sc2json
+// and json2sc commands.
+package convcmd
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ConvertFunc converts the contents of the file filename.
+type ConvertFunc func(filename string, src []byte) ([]byte, error)
+
+// Command describes a conversion command.
+type Command struct {
+	Name    string      // Name of the command, used in messages.
+	InExt   string      // Extension of input files, ex: .sc.
+	OutExt  string      // Extension of output files, ex: .json.
+	Convert ConvertFunc // Performs the conversion.
+	// Output is the output directory set by the -o flag.
+	Output string
+
+	// written maps each output file to the input file it was converted from.
+	written map[string]string
+}
+
+// RegisterFlags registers the flags shared by all commands on fs.
+func (c *Command) RegisterFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.Output, "o", "", "write output files to `dir` instead of standard output")
+}
+
+// Run converts the files and directories in args. It returns the exit code of the command.
+//
+// If there are no args, the standard input is converted and written to stdout.
+// A single file is written to stdout unless an output directory was set.
+// Otherwise, an output directory is required, each file is written to it with its
+// extension replaced by OutExt, and the files in each directory with the extension
+// InExt are converted recursively, keeping their path relative to the directory.
+// It is an error if two input files would be written to the same output file,
+// ex: a/x.sc and b/x.sc given as separate files.
+func (c *Command) Run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	failed := false
+	report := func(err error) {
+		fmt.Fprintf(stderr, "%s: %v\n", c.Name, err)
+		failed = true
+	}
+
+	if len(args) == 0 {
+		src, err := ioutil.ReadAll(stdin)
+		if err != nil {
+			report(err)
+			return 1
+		}
+		out, err := c.Convert("<standard input>", src)
+		if err != nil {
+			report(err)
+			return 1
+		}
+		if _, err := stdout.Write(out); err != nil {
+			report(err)
+			return 1
+		}
+		return 0
+	}
+
+	if c.Output == "" {
+		if info, err := os.Stat(args[0]); len(args) > 1 || (err == nil && info.IsDir()) {
+			report(errors.New("-o is required when converting multiple files or directories"))
+			return 2
+		}
+		if err := c.convertFile(args[0], stdout); err != nil {
+			report(err)
+			return 1
+		}
+		return 0
+	}
+
+	c.written = make(map[string]string)
+	for _, arg := range args {
+		info, err := os.Stat(arg)
+		if err != nil {
+			report(err)
+			continue
+		}
+		if !info.IsDir() {
+			if err := c.writeFile(arg, filepath.Base(arg)); err != nil {
+				report(err)
+			}
+			continue
+		}
+		err = filepath.Walk(arg, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				report(err)
+				return nil
+			}
+			if info.IsDir() || strings.HasPrefix(info.Name(), ".") || filepath.Ext(path) != c.InExt {
+				return nil
+			}
+			rel, err := filepath.Rel(arg, path)
+			if err != nil {
+				report(err)
+				return nil
+			}
+			if err := c.writeFile(path, rel); err != nil {
+				report(err)
+			}
+			return nil
+		})
+		if err != nil {
+			report(err)
+		}
+	}
+	if failed {
+		return 1
+	}
+	return 0
+}
+
+// convertFile converts the file filename and writes the result to w.
+func (c *Command) convertFile(filename string, w io.Writer) error {
+	src, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	out, err := c.Convert(filename, src)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(out)
+	return err
+}
+
+// writeFile converts the file filename and writes the result to the path rel
+// in the output directory with its extension replaced.
+func (c *Command) writeFile(filename, rel string) error {
+	dst := filepath.Join(c.Output, strings.TrimSuffix(rel, filepath.Ext(rel))+c.OutExt)
+	if prev, ok := c.written[dst]; ok {
+		return fmt.Errorf("%s and %s would both be written to %s", prev, filename, dst)
+	}
+	c.written[dst] = filename
+	src, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	out, err := c.Convert(filename, src)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(dst, out, 0o644)
+}
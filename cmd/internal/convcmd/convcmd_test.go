@@ -0,0 +1,121 @@
+// Copyright (c) 2021 the SC authors. All rights reserved. MIT License.
+
+package convcmd
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func upper(filename string, src []byte) ([]byte, error) {
+	if len(src) == 0 {
+		return nil, errors.New(filename + ": empty file")
+	}
+	return bytes.ToUpper(src), nil
+}
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func run(c *Command, args []string, stdin string) (code int, stdout, stderr string) {
+	var out, errOut bytes.Buffer
+	code = c.Run(args, strings.NewReader(stdin), &out, &errOut)
+	return code, out.String(), errOut.String()
+}
+
+func TestRunStdin(t *testing.T) {
+	c := &Command{Name: "test", InExt: ".a", OutExt: ".b", Convert: upper}
+	code, stdout, stderr := run(c, nil, "abc")
+	if code != 0 || stdout != "ABC" || stderr != "" {
+		t.Errorf("got code %d, stdout %q, stderr %q", code, stdout, stderr)
+	}
+
+	code, _, stderr = run(c, nil, "")
+	if want := "test: <standard input>: empty file\n"; code != 1 || stderr != want {
+		t.Errorf("got code %d, stderr %q, want 1, %q", code, stderr, want)
+	}
+}
+
+func TestRunFile(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"x.a": "abc"})
+	c := &Command{Name: "test", InExt: ".a", OutExt: ".b", Convert: upper}
+	code, stdout, stderr := run(c, []string{filepath.Join(dir, "x.a")}, "")
+	if code != 0 || stdout != "ABC" || stderr != "" {
+		t.Errorf("got code %d, stdout %q, stderr %q", code, stdout, stderr)
+	}
+
+	code, _, stderr = run(c, []string{dir}, "")
+	if want := "test: -o is required when converting multiple files or directories\n"; code != 2 || stderr != want {
+		t.Errorf("got code %d, stderr %q, want 2, %q", code, stderr, want)
+	}
+}
+
+func TestRunOutputDir(t *testing.T) {
+	in := writeFiles(t, map[string]string{
+		"x.a":          "x",
+		"sub/y.a":      "y",
+		"sub/skip.txt": "skip",
+		".hidden.a":    "hidden",
+	})
+	single := writeFiles(t, map[string]string{"z.a": "z"})
+	out := filepath.Join(t.TempDir(), "out")
+	c := &Command{Name: "test", InExt: ".a", OutExt: ".b", Convert: upper, Output: out}
+	code, stdout, stderr := run(c, []string{in, filepath.Join(single, "z.a")}, "")
+	if code != 0 || stdout != "" || stderr != "" {
+		t.Fatalf("got code %d, stdout %q, stderr %q", code, stdout, stderr)
+	}
+
+	var got []string
+	err := filepath.Walk(out, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(out, path)
+		got = append(got, filepath.ToSlash(rel)+"="+string(b))
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"sub/y.b=Y", "x.b=X", "z.b=Z"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("got files %v, want %v", got, want)
+	}
+}
+
+func TestRunOutputConflict(t *testing.T) {
+	in := writeFiles(t, map[string]string{"a/x.a": "a", "b/x.a": "b"})
+	out := t.TempDir()
+	c := &Command{Name: "test", InExt: ".a", OutExt: ".b", Convert: upper, Output: out}
+	a, b := filepath.Join(in, "a", "x.a"), filepath.Join(in, "b", "x.a")
+	code, _, stderr := run(c, []string{a, b}, "")
+	want := "test: " + a + " and " + b + " would both be written to " + filepath.Join(out, "x.b") + "\n"
+	if code != 1 || stderr != want {
+		t.Errorf("got code %d, stderr %q, want 1, %q", code, stderr, want)
+	}
+	got, err := ioutil.ReadFile(filepath.Join(out, "x.b"))
+	if err != nil || string(got) != "A" {
+		t.Errorf("got %q, %v, want the first file to be kept", got, err)
+	}
+}
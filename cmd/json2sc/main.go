@@ -0,0 +1,63 @@
+// Copyright (c) 2021 the SC authors. All rights reserved. MIT License.
+
+// Json2sc converts JSON files to SC.
+//
+// Usage:
+//
+//	json2sc [flags] [path ...]
+//
+// Without an explicit path, json2sc converts the standard input and writes the
+// result to standard output. A single file is also written to standard output.
+// To convert multiple files or directories, an output directory must be given with
+// the -o flag. Each file is written to the output directory with the .sc extension,
+// and the .json files in each directory are converted recursively, keeping their path
+// relative to the directory:
+//
+//	json2sc -o configs build/json
+//
+// The top level JSON value must be an object. The output is formatted using
+// scparse.Format. See github.com/sc-lang/go-sc/scjson for details of the conversion.
+//
+// The flags are:
+//
+//	-o dir
+//		Write output files to dir instead of standard output.
+//	-sort
+//		Sort the keys of dictionaries.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/sc-lang/go-sc/cmd/internal/convcmd"
+	"github.com/sc-lang/go-sc/scjson"
+)
+
+// config contains the conversion options set by flags.
+type config struct {
+	sort bool
+}
+
+// convert converts the JSON source src to SC.
+func (cfg *config) convert(filename string, src []byte) ([]byte, error) {
+	out, err := scjson.FromJSON(src, scjson.WithSortedKeys(cfg.sort))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	return out, nil
+}
+
+func main() {
+	cfg := &config{}
+	cmd := convcmd.Command{Name: "json2sc", InExt: ".json", OutExt: ".sc", Convert: cfg.convert}
+	cmd.RegisterFlags(flag.CommandLine)
+	flag.BoolVar(&cfg.sort, "sort", false, "sort the keys of dictionaries")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: json2sc [flags] [path ...]\n\nFlags:\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	os.Exit(cmd.Run(flag.Args(), os.Stdin, os.Stdout, os.Stderr))
+}
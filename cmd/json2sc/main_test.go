@@ -0,0 +1,45 @@
+// Copyright (c) 2021 the SC authors. All rights reserved. MIT License.
+
+package main
+
+import "testing"
+
+func TestConvert(t *testing.T) {
+	src := []byte(`{"server": {"port": 8080, "host": "localhost"}, "name": "sc", "tags": []}`)
+	tests := []struct {
+		name string
+		cfg  config
+		want string
+	}{
+		{
+			"default",
+			config{},
+			"{\n  server: {\n    port: 8080\n    host: \"localhost\"\n  }\n  name: \"sc\"\n  tags: []\n}\n",
+		},
+		{
+			"sort",
+			config{sort: true},
+			"{\n  name: \"sc\"\n  server: {\n    host: \"localhost\"\n    port: 8080\n  }\n  tags: []\n}\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.cfg.convert("config.json", src)
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConvertError(t *testing.T) {
+	cfg := config{}
+	_, err := cfg.convert("config.json", []byte(`[1, 2]`))
+	want := "config.json: scjson: top level JSON value must be an object"
+	if err == nil || err.Error() != want {
+		t.Errorf("got error %v, want %q", err, want)
+	}
+}
@@ -0,0 +1,133 @@
+// Copyright (c) 2021 the SC authors. All rights reserved. MIT License.
+
+// Sc2json converts SC files to JSON.
+//
+// Usage:
+//
+//	sc2json [flags] [path ...]
+//
+// Without an explicit path, sc2json converts the standard input and writes the
+// result to standard output. A single file is also written to standard output.
+// To convert multiple files or directories, an output directory must be given with
+// the -o flag. Each file is written to the output directory with the .json extension,
+// and the .sc files in each directory are converted recursively, keeping their path
+// relative to the directory:
+//
+//	sc2json -o build/json configs
+//
+// Variables that are not resolved are replaced by their default values. A variable
+// without a default value is an error unless it is provided using the -env or -var flags.
+// Anchors and aliases are expanded. See github.com/sc-lang/go-sc/scjson for details
+// of the conversion.
+//
+// The flags are:
+//
+//	-o dir
+//		Write output files to dir instead of standard output.
+//	-indent string
+//		The indentation used for each level, if empty the output is compact.
+//		The default is two spaces.
+//	-sort
+//		Sort the keys of objects.
+//	-comments
+//		Keep comments. The output is JSON with comments, which is not valid JSON.
+//	-env
+//		Resolve variables using the environment variables.
+//	-var name=value
+//		Set the variable name to the string value. It can be repeated
+//		and takes precedence over -env.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/sc-lang/go-sc"
+	"github.com/sc-lang/go-sc/cmd/internal/convcmd"
+	"github.com/sc-lang/go-sc/scjson"
+	"github.com/sc-lang/go-sc/scparse"
+)
+
+// varFlags collects the values of the -var flag.
+type varFlags map[string]string
+
+func (v varFlags) String() string {
+	return ""
+}
+
+func (v varFlags) Set(s string) error {
+	i := strings.Index(s, "=")
+	if i <= 0 {
+		return fmt.Errorf("invalid variable %q, must be name=value", s)
+	}
+	v[s[:i]] = s[i+1:]
+	return nil
+}
+
+// config contains the conversion options set by flags.
+type config struct {
+	indent   string
+	sort     bool
+	comments bool
+	env      bool
+	vars     varFlags
+}
+
+// environ returns the environment variables as a map.
+func environ() map[string]string {
+	env := make(map[string]string)
+	for _, kv := range os.Environ() {
+		if i := strings.Index(kv, "="); i > 0 {
+			env[kv[:i]] = kv[i+1:]
+		}
+	}
+	return env
+}
+
+// convert converts the SC source src to JSON.
+func (cfg *config) convert(filename string, src []byte) ([]byte, error) {
+	n, err := scparse.ParseFile(filename, src)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.env || len(cfg.vars) > 0 {
+		var vars []sc.Variables
+		if cfg.env {
+			vars = append(vars, sc.MustVariables(environ()))
+		}
+		vars = append(vars, sc.MustVariables(map[string]string(cfg.vars)))
+		v, err := sc.Resolve(n, sc.WithVariables(sc.MergeVariables(vars...)))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", filename, err)
+		}
+		n = v.(*scparse.DictionaryNode)
+	}
+	out, err := scjson.NodeToJSON(n,
+		scjson.WithIndent(cfg.indent),
+		scjson.WithSortedKeys(cfg.sort),
+		scjson.WithComments(cfg.comments),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	return append(out, '\n'), nil
+}
+
+func main() {
+	cfg := &config{vars: make(varFlags)}
+	cmd := convcmd.Command{Name: "sc2json", InExt: ".sc", OutExt: ".json", Convert: cfg.convert}
+	cmd.RegisterFlags(flag.CommandLine)
+	flag.StringVar(&cfg.indent, "indent", "  ", "indentation for each level, compact output if empty")
+	flag.BoolVar(&cfg.sort, "sort", false, "sort the keys of objects")
+	flag.BoolVar(&cfg.comments, "comments", false, "keep comments, the output is JSON with comments")
+	flag.BoolVar(&cfg.env, "env", false, "resolve variables using the environment variables")
+	flag.Var(cfg.vars, "var", "set a variable, in the form `name=value`; can be repeated")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: sc2json [flags] [path ...]\n\nFlags:\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	os.Exit(cmd.Run(flag.Args(), os.Stdin, os.Stdout, os.Stderr))
+}
@@ -0,0 +1,73 @@
+// Copyright (c) 2021 the SC authors. All rights reserved. MIT License.
+
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+func TestConvert(t *testing.T) {
+	os.Setenv("SC2JSON_TEST_HOST", "example.com")
+	defer os.Unsetenv("SC2JSON_TEST_HOST")
+
+	src := []byte(`{
+  // The server
+  server: {host: "${SC2JSON_TEST_HOST}", port: ${PORT}}
+  name: "${NAME:-sc}"
+}`)
+	tests := []struct {
+		name string
+		cfg  config
+		want string
+	}{
+		{
+			"vars",
+			config{vars: varFlags{"PORT": "8080", "SC2JSON_TEST_HOST": "localhost"}},
+			`{"server":{"host":"localhost","port":"8080"},"name":"sc"}` + "\n",
+		},
+		{
+			"env and vars",
+			config{indent: "  ", sort: true, env: true, vars: varFlags{"PORT": "8080"}},
+			"{\n  \"name\": \"sc\",\n  \"server\": {\n    \"host\": \"example.com\",\n    \"port\": \"8080\"\n  }\n}\n",
+		},
+		{
+			"comments",
+			config{comments: true, vars: varFlags{"PORT": "1", "SC2JSON_TEST_HOST": "h"}},
+			"{\n  // The server\n  \"server\": {\n    \"host\": \"h\",\n    \"port\": \"1\"\n  },\n  \"name\": \"sc\"\n}\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.cfg.convert("config.sc", src)
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConvertUnresolvedVariable(t *testing.T) {
+	cfg := config{vars: varFlags{}}
+	_, err := cfg.convert("config.sc", []byte(`{port: ${PORT}}`))
+	want := `config.sc: sc: 1:8: cannot convert unresolved variable "PORT"`
+	if err == nil || err.Error() != want {
+		t.Errorf("got error %v, want %q", err, want)
+	}
+}
+
+func TestVarFlags(t *testing.T) {
+	v := make(varFlags)
+	if err := v.Set("a=b=c"); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if v["a"] != "b=c" {
+		t.Errorf("got %q, want %q", v["a"], "b=c")
+	}
+	if err := v.Set("=x"); err == nil {
+		t.Error("expected error for empty name")
+	}
+}
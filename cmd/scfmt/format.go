@@ -0,0 +1,118 @@
+// Copyright (c) 2021 the SC authors. All rights reserved. MIT License.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+
+	"github.com/sc-lang/go-sc/scparse"
+)
+
+// options contains the command line flags.
+type options struct {
+	list  bool // -l
+	write bool // -w
+	diff  bool // -d
+}
+
+// format returns the formatted version of the SC source src.
+func format(filename string, src []byte) ([]byte, error) {
+	n, err := scparse.ParseFile(filename, src)
+	if err != nil {
+		return nil, err
+	}
+	return scparse.Format(n), nil
+}
+
+// processFile formats the file filename and writes the result to out according to opts.
+// If in is nil, the file is read from disk.
+func processFile(filename string, in io.Reader, out io.Writer, opts options) error {
+	var src []byte
+	var err error
+	if in == nil {
+		src, err = ioutil.ReadFile(filename)
+	} else {
+		src, err = ioutil.ReadAll(in)
+	}
+	if err != nil {
+		return err
+	}
+
+	res, err := format(filename, src)
+	if err != nil {
+		return err
+	}
+
+	if !opts.list && !opts.write && !opts.diff {
+		_, err = out.Write(res)
+		return err
+	}
+	if bytes.Equal(src, res) {
+		return nil
+	}
+	if opts.list {
+		fmt.Fprintln(out, filename)
+	}
+	if opts.write {
+		info, err := os.Stat(filename)
+		if err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(filename, res, info.Mode().Perm()); err != nil {
+			return err
+		}
+	}
+	if opts.diff {
+		d, err := diff(filename, src, res)
+		if err != nil {
+			return fmt.Errorf("computing diff: %v", err)
+		}
+		fmt.Fprintf(out, "diff -u %s %s\n", filename+".orig", filename)
+		_, err = out.Write(d)
+		return err
+	}
+	return nil
+}
+
+// diff returns the unified diff of a and b using the diff command.
+// The file names in the header are filename.orig and filename.
+func diff(filename string, a, b []byte) ([]byte, error) {
+	fa, err := writeTempFile("scfmt", a)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(fa)
+	fb, err := writeTempFile("scfmt", b)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(fb)
+
+	d, err := exec.Command("diff", "-u", "--label", filename+".orig", "--label", filename, fa, fb).Output()
+	if len(d) > 0 {
+		// diff exits with a non-zero status when the files differ
+		return d, nil
+	}
+	return nil, err
+}
+
+func writeTempFile(prefix string, data []byte) (string, error) {
+	f, err := ioutil.TempFile("", prefix)
+	if err != nil {
+		return "", err
+	}
+	_, err = f.Write(data)
+	if err1 := f.Close(); err == nil {
+		err = err1
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
@@ -0,0 +1,120 @@
+// Copyright (c) 2021 the SC authors. All rights reserved. MIT License.
+
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const (
+	unformatted = "{a:1,\n// comment\nb:[1,2]}"
+	formatted   = "{\n  a: 1\n  // comment\n  b: [\n    1\n    2\n  ]\n}\n"
+)
+
+func writeFile(t *testing.T, src string) string {
+	t.Helper()
+	filename := filepath.Join(t.TempDir(), "config.sc")
+	if err := ioutil.WriteFile(filename, []byte(src), 0o644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	return filename
+}
+
+func readFile(t *testing.T, filename string) string {
+	t.Helper()
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("failed to read file: %v", err)
+	}
+	return string(b)
+}
+
+func TestProcessFile(t *testing.T) {
+	filename := writeFile(t, unformatted)
+	var out bytes.Buffer
+	if err := processFile(filename, nil, &out, options{}); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if out.String() != formatted {
+		t.Errorf("got output\n%s\nwant\n%s", out.String(), formatted)
+	}
+	if got := readFile(t, filename); got != unformatted {
+		t.Errorf("file was modified without -w:\n%s", got)
+	}
+}
+
+func TestProcessFileStdin(t *testing.T) {
+	var out bytes.Buffer
+	if err := processFile("<standard input>", strings.NewReader(unformatted), &out, options{}); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if out.String() != formatted {
+		t.Errorf("got output\n%s\nwant\n%s", out.String(), formatted)
+	}
+}
+
+func TestProcessFileListWrite(t *testing.T) {
+	filename := writeFile(t, unformatted)
+	var out bytes.Buffer
+	if err := processFile(filename, nil, &out, options{list: true, write: true}); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if want := filename + "\n"; out.String() != want {
+		t.Errorf("got output %q, want %q", out.String(), want)
+	}
+	if got := readFile(t, filename); got != formatted {
+		t.Errorf("got file\n%s\nwant\n%s", got, formatted)
+	}
+
+	// Formatted files are not listed
+	out.Reset()
+	if err := processFile(filename, nil, &out, options{list: true}); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if out.Len() != 0 {
+		t.Errorf("got output %q for formatted file, want none", out.String())
+	}
+}
+
+func TestProcessFileDiff(t *testing.T) {
+	if _, err := exec.LookPath("diff"); err != nil {
+		t.Skip("diff command not found")
+	}
+	filename := writeFile(t, "{\n  a: 1\n  b:   2\n}\n")
+	var out bytes.Buffer
+	if err := processFile(filename, nil, &out, options{diff: true}); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	want := "diff -u " + filename + ".orig " + filename + "\n" +
+		"--- " + filename + ".orig\n" +
+		"+++ " + filename + "\n" +
+		"@@ -1,4 +1,4 @@\n" +
+		" {\n" +
+		"   a: 1\n" +
+		"-  b:   2\n" +
+		"+  b: 2\n" +
+		" }\n"
+	if out.String() != want {
+		t.Errorf("got diff\n%s\nwant\n%s", out.String(), want)
+	}
+}
+
+func TestProcessFileError(t *testing.T) {
+	filename := writeFile(t, "{a: }")
+	var out bytes.Buffer
+	err := processFile(filename, nil, &out, options{write: true})
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	if !strings.Contains(err.Error(), filename+":1:") {
+		t.Errorf("error %q does not contain the file name and position", err)
+	}
+	if got := readFile(t, filename); got != "{a: }" {
+		t.Errorf("file was modified after an error:\n%s", got)
+	}
+}
@@ -0,0 +1,108 @@
+// Copyright (c) 2021 the SC authors. All rights reserved. MIT License.
+
+// Scfmt formats SC files.
+//
+// Usage:
+//
+//	scfmt [flags] [path ...]
+//
+// Without an explicit path, scfmt processes the standard input. Given a file,
+// it operates on that file; given a directory, it operates on all .sc files in
+// that directory, recursively. By default, scfmt prints the formatted sources
+// to standard output.
+//
+// The flags are:
+//
+//	-d
+//		Do not print formatted sources to standard output.
+//		If a file's formatting is different than scfmt's, print diffs
+//		to standard output.
+//	-l
+//		Do not print formatted sources to standard output.
+//		If a file's formatting is different from scfmt's, print its name
+//		to standard output.
+//	-w
+//		Do not print formatted sources to standard output.
+//		If a file's formatting is different from scfmt's, overwrite it
+//		with scfmt's version.
+//
+// Files are formatted with scparse.Format, comments are preserved.
+// Printing diffs requires the diff command to be installed.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func main() {
+	var opts options
+	flag.BoolVar(&opts.list, "l", false, "list files whose formatting differs from scfmt's")
+	flag.BoolVar(&opts.write, "w", false, "write result to (source) file instead of stdout")
+	flag.BoolVar(&opts.diff, "d", false, "display diffs instead of rewriting files")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: scfmt [flags] [path ...]\n\nFlags:\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		if opts.write {
+			fmt.Fprintln(os.Stderr, "scfmt: cannot use -w with standard input")
+			os.Exit(2)
+		}
+		if err := processFile("<standard input>", os.Stdin, os.Stdout, opts); err != nil {
+			fmt.Fprintf(os.Stderr, "scfmt: %v\n", err)
+			os.Exit(2)
+		}
+		return
+	}
+
+	failed := false
+	report := func(err error) {
+		fmt.Fprintf(os.Stderr, "scfmt: %v\n", err)
+		failed = true
+	}
+	for _, path := range flag.Args() {
+		info, err := os.Stat(path)
+		if err != nil {
+			report(err)
+			continue
+		}
+		if !info.IsDir() {
+			if err := processFile(path, nil, os.Stdout, opts); err != nil {
+				report(err)
+			}
+			continue
+		}
+		err = filepath.Walk(path, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				report(err)
+				return nil
+			}
+			if info.IsDir() || !isSCFile(info) {
+				return nil
+			}
+			if err := processFile(path, nil, os.Stdout, opts); err != nil {
+				report(err)
+			}
+			return nil
+		})
+		if err != nil {
+			report(err)
+		}
+	}
+	if failed {
+		os.Exit(2)
+	}
+}
+
+// isSCFile reports whether f is an SC file that should be formatted
+// when walking a directory. Hidden files are skipped.
+func isSCFile(f os.FileInfo) bool {
+	name := f.Name()
+	return !strings.HasPrefix(name, ".") && strings.HasSuffix(name, ".sc")
+}
@@ -0,0 +1,590 @@
+// Copyright (c) 2021 the SC authors. All rights reserved. MIT License.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"path"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// generateDirective marks a struct type that code should be generated for.
+const generateDirective = "//scgen:generate"
+
+// config controls which types code is generated for.
+type config struct {
+	types []string // names of the types to generate, overrides directives
+	all   bool     // generate all struct types in the file
+}
+
+// structInfo describes a struct type that code is generated for.
+type structInfo struct {
+	name   string
+	fields []fieldInfo
+}
+
+// fieldInfo describes a struct field that is encoded and decoded.
+type fieldInfo struct {
+	goName    string   // name of the Go field
+	name      string   // key in SC
+	typ       ast.Expr // type of the field
+	omitEmpty bool
+	required  bool
+}
+
+// generator generates UnmarshalSC and MarshalSC methods for the structs in a file.
+type generator struct {
+	buf     bytes.Buffer
+	fset    *token.FileSet
+	file    *ast.File
+	structs map[string]bool   // types that have generated methods
+	imports map[string]string // package name to import path for imports in file
+	used    map[string]bool   // package names used by generated code
+}
+
+// generate parses the Go source file filename and returns the formatted
+// source of a file containing the generated methods. src is used as
+// the source of the file if it is not nil.
+func generate(filename string, src interface{}, cfg config) ([]byte, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, filename, src, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	g := &generator{
+		fset:    fset,
+		file:    file,
+		structs: make(map[string]bool),
+		imports: make(map[string]string),
+		used:    make(map[string]bool),
+	}
+	for _, spec := range file.Imports {
+		p, err := strconv.Unquote(spec.Path.Value)
+		if err != nil {
+			return nil, err
+		}
+		name := importName(p)
+		if spec.Name != nil {
+			name = spec.Name.Name
+		}
+		g.imports[name] = p
+	}
+
+	structs, err := g.collectStructs(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if len(structs) == 0 {
+		return nil, fmt.Errorf("no struct types to generate code for in %s", filename)
+	}
+
+	// Generate the body first so the used imports are known.
+	for _, s := range structs {
+		g.genUnmarshal(s)
+		g.genMarshal(s)
+	}
+	body := g.buf.Bytes()
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "// Code generated by scgen. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&out, "package %s\n\n", file.Name.Name)
+	fmt.Fprintf(&out, "import (\n")
+	// Imports are grouped like goimports does, standard library packages first.
+	var std, other []string
+	for name := range g.used {
+		p := g.imports[name]
+		spec := strconv.Quote(p)
+		if importName(p) != name {
+			spec = name + " " + spec
+		}
+		if strings.Contains(strings.SplitN(p, "/", 2)[0], ".") {
+			other = append(other, spec)
+		} else {
+			std = append(std, spec)
+		}
+	}
+	for _, group := range [][]string{std, other} {
+		if len(group) == 0 {
+			continue
+		}
+		sort.Strings(group)
+		for _, spec := range group {
+			fmt.Fprintf(&out, "\t%s\n", spec)
+		}
+		fmt.Fprintf(&out, "\n")
+	}
+	fmt.Fprintf(&out, "\t%q\n", "github.com/sc-lang/go-sc")
+	fmt.Fprintf(&out, "\t%q\n", "github.com/sc-lang/go-sc/scgen")
+	fmt.Fprintf(&out, "\t%q\n", "github.com/sc-lang/go-sc/scparse")
+	fmt.Fprintf(&out, ")\n")
+	out.Write(body)
+
+	b, err := format.Source(out.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("failed to format generated code: %w", err)
+	}
+	return b, nil
+}
+
+// importName returns the default package name for an import path.
+// A major version suffix, ex: /v2 or .v2, is ignored.
+func importName(p string) string {
+	base := path.Base(p)
+	if isMajorVersion(base) {
+		base = path.Base(path.Dir(p))
+	}
+	if i := strings.LastIndex(base, ".v"); i > 0 && isMajorVersion(base[i+1:]) {
+		base = base[:i]
+	}
+	return strings.ReplaceAll(base, "-", "_")
+}
+
+func isMajorVersion(s string) bool {
+	if len(s) < 2 || s[0] != 'v' {
+		return false
+	}
+	_, err := strconv.Atoi(s[1:])
+	return err == nil
+}
+
+// collectStructs returns the structs in the file that code should be generated for.
+func (g *generator) collectStructs(cfg config) ([]*structInfo, error) {
+	want := make(map[string]bool)
+	for _, name := range cfg.types {
+		want[name] = true
+	}
+
+	var specs []*ast.TypeSpec
+	for _, decl := range g.file.Decls {
+		gd, ok := decl.(*ast.GenDecl)
+		if !ok || gd.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range gd.Specs {
+			ts := spec.(*ast.TypeSpec)
+			if _, ok := ts.Type.(*ast.StructType); !ok {
+				if want[ts.Name.Name] {
+					return nil, fmt.Errorf("type %s is not a struct", ts.Name.Name)
+				}
+				continue
+			}
+			var include bool
+			switch {
+			case len(cfg.types) > 0:
+				include = want[ts.Name.Name]
+				delete(want, ts.Name.Name)
+			case cfg.all:
+				include = true
+			default:
+				include = hasDirective(ts.Doc) || (len(gd.Specs) == 1 && hasDirective(gd.Doc))
+			}
+			if include {
+				specs = append(specs, ts)
+				g.structs[ts.Name.Name] = true
+			}
+		}
+	}
+	for name := range want {
+		return nil, fmt.Errorf("type %s not found", name)
+	}
+
+	structs := make([]*structInfo, len(specs))
+	for i, ts := range specs {
+		s, err := g.structInfo(ts.Name.Name, ts.Type.(*ast.StructType))
+		if err != nil {
+			return nil, err
+		}
+		structs[i] = s
+	}
+	return structs, nil
+}
+
+func hasDirective(cg *ast.CommentGroup) bool {
+	if cg == nil {
+		return false
+	}
+	for _, c := range cg.List {
+		if strings.TrimSpace(c.Text) == generateDirective {
+			return true
+		}
+	}
+	return false
+}
+
+// structInfo returns the fields of st that are encoded and decoded.
+// The same rules as the sc package are used for field names and tags.
+func (g *generator) structInfo(name string, st *ast.StructType) (*structInfo, error) {
+	s := &structInfo{name: name}
+	seen := make(map[string]string)
+	for _, f := range st.Fields.List {
+		var tag string
+		if f.Tag != nil {
+			t, err := strconv.Unquote(f.Tag.Value)
+			if err != nil {
+				return nil, err
+			}
+			tag = reflect.StructTag(t).Get("sc")
+		}
+		if tag == "-" {
+			continue
+		}
+		tagName, opts := tag, ""
+		if i := strings.Index(tag, ","); i >= 0 {
+			tagName, opts = tag[:i], tag[i+1:]
+		}
+
+		fi := fieldInfo{name: tagName, typ: f.Type}
+		for _, opt := range strings.Split(opts, ",") {
+			switch opt {
+			case "omitempty":
+				fi.omitEmpty = true
+			case "required":
+				fi.required = true
+			case "string", "inline", "remain", "hex", "base64url", "bytelist", "omitnil", "omitzero", "secret":
+				return nil, fmt.Errorf("%s: field option %q is not supported by scgen", g.fset.Position(f.Pos()), opt)
+			default:
+				for _, name := range []string{"format", "order", "asvar"} {
+					if strings.HasPrefix(opt, name+"=") {
+						return nil, fmt.Errorf("%s: field option %q is not supported by scgen", g.fset.Position(f.Pos()), name)
+					}
+				}
+			}
+		}
+
+		var goNames []string
+		if len(f.Names) == 0 {
+			// Embedded fields are only supported if they are named with a tag.
+			if tagName == "" {
+				return nil, fmt.Errorf("%s: embedded field %s must be named with an sc tag to be used with scgen", g.fset.Position(f.Pos()), g.typeString(f.Type))
+			}
+			goNames = []string{embeddedName(f.Type)}
+		} else {
+			for _, n := range f.Names {
+				goNames = append(goNames, n.Name)
+			}
+		}
+
+		for _, goName := range goNames {
+			if !ast.IsExported(goName) {
+				continue
+			}
+			fi := fi
+			fi.goName = goName
+			if fi.name == "" {
+				fi.name = goName
+			}
+			if prev, ok := seen[fi.name]; ok {
+				return nil, fmt.Errorf("%s: fields %s and %s of %s have the same name %q", g.fset.Position(f.Pos()), prev, goName, name, fi.name)
+			}
+			seen[fi.name] = goName
+			s.fields = append(s.fields, fi)
+		}
+	}
+	return s, nil
+}
+
+// embeddedName returns the field name of an embedded field of type t.
+func embeddedName(t ast.Expr) string {
+	switch t := t.(type) {
+	case *ast.StarExpr:
+		return embeddedName(t.X)
+	case *ast.SelectorExpr:
+		return t.Sel.Name
+	case *ast.Ident:
+		return t.Name
+	}
+	return ""
+}
+
+func (g *generator) printf(format string, args ...interface{}) {
+	fmt.Fprintf(&g.buf, format, args...)
+}
+
+// typeString returns the source for the type t and records the imports it uses.
+func (g *generator) typeString(t ast.Expr) string {
+	ast.Inspect(t, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.SelectorExpr); ok {
+			if id, ok := sel.X.(*ast.Ident); ok {
+				if _, ok := g.imports[id.Name]; ok {
+					g.used[id.Name] = true
+				}
+			}
+			return false
+		}
+		return true
+	})
+	var buf bytes.Buffer
+	if err := format.Node(&buf, g.fset, t); err != nil {
+		panic(err) // t was parsed so it can always be printed
+	}
+	return buf.String()
+}
+
+// builtin kinds of types that are decoded and encoded without falling back to the sc package.
+var (
+	intBits = map[string]int{
+		"int": 0, "int8": 8, "int16": 16, "int32": 32, "rune": 32, "int64": 64,
+	}
+	uintBits = map[string]int{
+		"uint": 0, "uint8": 8, "byte": 8, "uint16": 16, "uint32": 32, "uint64": 64,
+	}
+	floatBits = map[string]int{
+		"float32": 32, "float64": 64,
+	}
+)
+
+// isByteSlice reports whether t is []byte which is base64 encoded.
+func isByteSlice(t *ast.ArrayType) bool {
+	id, ok := t.Elt.(*ast.Ident)
+	return t.Len == nil && ok && (id.Name == "byte" || id.Name == "uint8")
+}
+
+func (g *generator) genUnmarshal(s *structInfo) {
+	fieldsVar := "scgenFields" + s.name
+	g.printf("\nvar %s = []string{", fieldsVar)
+	for i, f := range s.fields {
+		if i > 0 {
+			g.printf(", ")
+		}
+		g.printf("%q", f.name)
+	}
+	g.printf("}\n")
+
+	g.printf("\n// UnmarshalSC implements sc.Unmarshaler.\n")
+	g.printf("func (v *%s) UnmarshalSC(n scparse.ValueNode, vars sc.Variables) error {\n", s.name)
+	g.printf("dn, ok := n.(*scparse.DictionaryNode)\n")
+	g.printf("if !ok {\n")
+	g.printf("if _, ok := n.(*scparse.NullNode); ok {\nreturn nil\n}\n")
+	g.printf("return scgen.TypeError(n, v)\n")
+	g.printf("}\n")
+	g.printf("var errs sc.Errors\n")
+	for i, f := range s.fields {
+		if f.required {
+			g.printf("var seen%d bool\n", i)
+		}
+	}
+	g.printf("for _, mn := range dn.Members {\n")
+	g.printf("switch scgen.FieldName(mn.Key.KeyString(), %s) {\n", fieldsVar)
+	for i, f := range s.fields {
+		g.printf("case %q:\n", f.name)
+		if f.required {
+			g.printf("seen%d = true\n", i)
+		}
+		g.genDecode("v."+f.goName, f.typ, "mn.Value", 0)
+	}
+	g.printf("}\n")
+	g.printf("}\n")
+	for i, f := range s.fields {
+		if f.required {
+			g.printf("if !seen%d {\n", i)
+			g.printf("errs = append(errs, &sc.UnmarshalMissingFieldError{Struct: %q, Field: %q, Pos: dn.Pos})\n", s.name, f.name)
+			g.printf("}\n")
+		}
+	}
+	g.printf("return scgen.Result(errs)\n")
+	g.printf("}\n")
+}
+
+// genDecode generates code to decode the node in the variable node into target of type t.
+// depth is used to create unique variable names for nested values.
+func (g *generator) genDecode(target string, t ast.Expr, node string, depth int) {
+	fallback := func() {
+		g.printf("errs = scgen.AppendError(errs, sc.UnmarshalNode(%s, &%s, sc.WithVariables(vars)))\n", node, target)
+	}
+	decodeBasic := func(fn, conv, bits string) {
+		g.printf("if x, ok := scgen.%s(%s%s); ok {\n", fn, node, bits)
+		g.printf("%s = %s\n", target, conv)
+		g.printf("} else {\n")
+		fallback()
+		g.printf("}\n")
+	}
+
+	switch t := t.(type) {
+	case *ast.Ident:
+		if bits, ok := intBits[t.Name]; ok {
+			decodeBasic("Int", t.Name+"(x)", fmt.Sprintf(", %d", bits))
+			return
+		}
+		if bits, ok := uintBits[t.Name]; ok {
+			decodeBasic("Uint", t.Name+"(x)", fmt.Sprintf(", %d", bits))
+			return
+		}
+		if bits, ok := floatBits[t.Name]; ok {
+			decodeBasic("Float", t.Name+"(x)", fmt.Sprintf(", %d", bits))
+			return
+		}
+		switch t.Name {
+		case "string":
+			decodeBasic("String", "x", "")
+			return
+		case "bool":
+			decodeBasic("Bool", "x", "")
+			return
+		}
+		if g.structs[t.Name] {
+			g.printf("errs = scgen.AppendError(errs, %s.UnmarshalSC(%s, vars))\n", target, node)
+			return
+		}
+	case *ast.StarExpr:
+		g.printf("switch %s.(type) {\n", node)
+		g.printf("case *scparse.NullNode, *scparse.VariableNode, *scparse.ExpressionNode:\n")
+		fallback()
+		g.printf("default:\n")
+		g.printf("if %s == nil {\n%s = new(%s)\n}\n", target, target, g.typeString(t.X))
+		g.genDecode("(*"+target+")", t.X, node, depth)
+		g.printf("}\n")
+		return
+	case *ast.ArrayType:
+		if t.Len != nil || isByteSlice(t) {
+			break
+		}
+		ln, i, e := fmt.Sprintf("ln%d", depth), fmt.Sprintf("i%d", depth), fmt.Sprintf("e%d", depth)
+		ts := g.typeString(t)
+		g.printf("if %s, ok := %s.(*scparse.ListNode); ok {\n", ln, node)
+		g.printf("if cap(%s) < len(%s.Elements) {\n", target, ln)
+		g.printf("s := make(%s, len(%s), len(%s.Elements))\n", ts, target, ln)
+		g.printf("copy(s, %s)\n", target)
+		g.printf("%s = s\n", target)
+		g.printf("}\n")
+		g.printf("%s = %s[:len(%s.Elements)]\n", target, target, ln)
+		g.printf("for %s, %s := range %s.Elements {\n", i, e, ln)
+		g.genDecode(fmt.Sprintf("%s[%s]", target, i), t.Elt, e, depth+1)
+		g.printf("}\n")
+		g.printf("if len(%s.Elements) == 0 {\n%s = %s{}\n}\n", ln, target, ts)
+		g.printf("} else {\n")
+		fallback()
+		g.printf("}\n")
+		return
+	case *ast.MapType:
+		if id, ok := t.Key.(*ast.Ident); !ok || id.Name != "string" {
+			break
+		}
+		dn, mn, e := fmt.Sprintf("dn%d", depth), fmt.Sprintf("mn%d", depth), fmt.Sprintf("e%d", depth)
+		g.printf("if %s, ok := %s.(*scparse.DictionaryNode); ok {\n", dn, node)
+		g.printf("if %s == nil {\n%s = make(%s, len(%s.Members))\n}\n", target, target, g.typeString(t), dn)
+		g.printf("for _, %s := range %s.Members {\n", mn, dn)
+		g.printf("var %s %s\n", e, g.typeString(t.Value))
+		g.genDecode(e, t.Value, mn+".Value", depth+1)
+		g.printf("%s[%s.Key.KeyString()] = %s\n", target, mn, e)
+		g.printf("}\n")
+		g.printf("} else {\n")
+		fallback()
+		g.printf("}\n")
+		return
+	}
+	fallback()
+}
+
+func (g *generator) genMarshal(s *structInfo) {
+	g.printf("\n// MarshalSC implements sc.Marshaler.\n")
+	g.printf("func (v %s) MarshalSC() (scparse.ValueNode, error) {\n", s.name)
+	g.printf("members := make([]*scparse.MemberNode, 0, %d)\n", len(s.fields))
+	for _, f := range s.fields {
+		value := "v." + f.goName
+		if f.omitEmpty {
+			g.printf("if %s {\n", g.nonEmptyCond(value, f.typ))
+		} else {
+			g.printf("{\n")
+		}
+		g.printf("var vn scparse.ValueNode\n")
+		g.genEncode("vn", value, f.typ, 0)
+		g.printf("members = append(members, &scparse.MemberNode{Key: scgen.Key(%q), Value: vn})\n", f.name)
+		g.printf("}\n")
+	}
+	g.printf("return &scparse.DictionaryNode{Members: members}, nil\n")
+	g.printf("}\n")
+}
+
+// nonEmptyCond returns a condition that reports whether value of type t is not empty.
+func (g *generator) nonEmptyCond(value string, t ast.Expr) string {
+	switch t := t.(type) {
+	case *ast.Ident:
+		_, isInt := intBits[t.Name]
+		_, isUint := uintBits[t.Name]
+		_, isFloat := floatBits[t.Name]
+		switch {
+		case isInt || isUint || isFloat:
+			return value + " != 0"
+		case t.Name == "string":
+			return "len(" + value + ") != 0"
+		case t.Name == "bool":
+			return value
+		case t.Name == "any":
+			return value + " != nil"
+		case g.structs[t.Name]:
+			return "true"
+		}
+	case *ast.ArrayType, *ast.MapType:
+		return "len(" + value + ") != 0"
+	case *ast.StarExpr, *ast.InterfaceType:
+		return value + " != nil"
+	}
+	return "!scgen.IsEmpty(" + value + ")"
+}
+
+// genEncode generates code to encode value of type t and store the node in dst.
+// depth is used to create unique variable names for nested values.
+func (g *generator) genEncode(dst, value string, t ast.Expr, depth int) {
+	switch t := t.(type) {
+	case *ast.Ident:
+		switch {
+		case t.Name == "string":
+			g.printf("%s = scgen.StringNode(%s)\n", dst, value)
+			return
+		case t.Name == "bool":
+			g.printf("%s = &scparse.BoolNode{True: %s}\n", dst, value)
+			return
+		case intBits[t.Name] > 0 || t.Name == "int":
+			g.printf("%s = &scparse.NumberNode{IsInt: true, Int64: int64(%s)}\n", dst, value)
+			return
+		case uintBits[t.Name] > 0 || t.Name == "uint":
+			g.printf("%s = &scparse.NumberNode{IsUint: true, Uint64: uint64(%s)}\n", dst, value)
+			return
+		case t.Name == "float32":
+			// Use the same shortest representation as sc.Marshal
+			g.printf("%s = scgen.Float32Node(%s)\n", dst, value)
+			return
+		case floatBits[t.Name] > 0:
+			g.printf("%s = &scparse.NumberNode{IsFloat: true, Float64: float64(%s)}\n", dst, value)
+			return
+		case g.structs[t.Name]:
+			g.printf("n, err := %s.MarshalSC()\n", value)
+			g.printf("if err != nil {\nreturn nil, err\n}\n")
+			g.printf("%s = n\n", dst)
+			return
+		}
+	case *ast.StarExpr:
+		g.printf("if %s == nil {\n", value)
+		g.printf("%s = &scparse.NullNode{}\n", dst)
+		g.printf("} else {\n")
+		g.genEncode(dst, "(*"+value+")", t.X, depth)
+		g.printf("}\n")
+		return
+	case *ast.ArrayType:
+		if t.Len != nil || isByteSlice(t) {
+			break
+		}
+		els, i, e := fmt.Sprintf("els%d", depth), fmt.Sprintf("i%d", depth), fmt.Sprintf("e%d", depth)
+		g.printf("if %s == nil {\n", value)
+		g.printf("%s = &scparse.NullNode{}\n", dst)
+		g.printf("} else {\n")
+		g.printf("%s := make([]scparse.ValueNode, len(%s))\n", els, value)
+		g.printf("for %s, %s := range %s {\n", i, e, value)
+		g.genEncode(fmt.Sprintf("%s[%s]", els, i), e, t.Elt, depth+1)
+		g.printf("}\n")
+		g.printf("%s = &scparse.ListNode{Elements: %s}\n", dst, els)
+		g.printf("}\n")
+		return
+	}
+	g.printf("n, err := sc.MarshalNode(%s)\n", value)
+	g.printf("if err != nil {\nreturn nil, err\n}\n")
+	g.printf("%s = n\n", dst)
+}
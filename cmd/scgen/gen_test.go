@@ -0,0 +1,137 @@
+// Copyright (c) 2021 the SC authors. All rights reserved. MIT License.
+
+package main
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+// TestGenerateExample checks that the generated code in internal/example is up to date.
+func TestGenerateExample(t *testing.T) {
+	got, err := generate("internal/example/example.go", nil, config{})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	want, err := ioutil.ReadFile("internal/example/example_sc.go")
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if string(got) != string(want) {
+		t.Errorf("generated code does not match internal/example/example_sc.go, run go generate ./...\n%s", got)
+	}
+}
+
+func TestGenerateTypes(t *testing.T) {
+	src := `package p
+
+import (
+	"time"
+
+	y "gopkg.in/yaml.v3"
+)
+
+type A struct {
+	D []time.Duration
+	N map[string]*y.Node
+}
+
+type B struct {
+	Name string
+}
+
+type C struct {
+	Name string
+}
+`
+	got, err := generate("p.go", src, config{types: []string{"A", "C"}})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	for _, s := range []string{"import (\n\t\"time\"\n\n\ty \"gopkg.in/yaml.v3\"\n\n", "func (v *A) UnmarshalSC", "func (v C) MarshalSC"} {
+		if strings.Contains(string(got), s) {
+			continue
+		}
+		t.Errorf("generated code does not contain %s\n%s", s, got)
+	}
+	if strings.Contains(string(got), "func (v *B)") {
+		t.Errorf("generated code for B which was not requested\n%s", got)
+	}
+
+	got, err = generate("p.go", src, config{all: true})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if !strings.Contains(string(got), "func (v *B) UnmarshalSC") {
+		t.Errorf("generated code does not contain B with all set\n%s", got)
+	}
+}
+
+func TestGenerateErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     string
+		cfg     config
+		wantErr string
+	}{
+		{
+			name:    "no types",
+			src:     "package p\n\ntype A struct{}\n",
+			wantErr: "no struct types",
+		},
+		{
+			name:    "type not found",
+			src:     "package p\n\ntype A struct{}\n",
+			cfg:     config{types: []string{"B"}},
+			wantErr: "type B not found",
+		},
+		{
+			name:    "not a struct",
+			src:     "package p\n\ntype A int\n",
+			cfg:     config{types: []string{"A"}},
+			wantErr: "type A is not a struct",
+		},
+		{
+			name:    "string option",
+			src:     "package p\n\n//scgen:generate\ntype A struct {\n\tN int `sc:\"n,string\"`\n}\n",
+			wantErr: `field option "string" is not supported`,
+		},
+		{
+			name:    "remain option",
+			src:     "package p\n\n//scgen:generate\ntype A struct {\n\tM map[string]int `sc:\",remain\"`\n}\n",
+			wantErr: `field option "remain" is not supported`,
+		},
+		{
+			name:    "hex option",
+			src:     "package p\n\n//scgen:generate\ntype A struct {\n\tB []byte `sc:\"b,hex\"`\n}\n",
+			wantErr: `field option "hex" is not supported`,
+		},
+		{
+			name:    "format option",
+			src:     "package p\n\nimport \"time\"\n\n//scgen:generate\ntype A struct {\n\tT time.Time `sc:\"t,format=2006-01-02\"`\n}\n",
+			wantErr: `field option "format" is not supported`,
+		},
+		{
+			name:    "embedded",
+			src:     "package p\n\ntype B struct{}\n\n//scgen:generate\ntype A struct {\n\tB\n}\n",
+			wantErr: "embedded field B must be named",
+		},
+		{
+			name:    "duplicate name",
+			src:     "package p\n\n//scgen:generate\ntype A struct {\n\tX int `sc:\"a\"`\n\tY int `sc:\"a\"`\n}\n",
+			wantErr: `have the same name "a"`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := generate("p.go", tt.src, tt.cfg)
+			if err == nil {
+				t.Fatal("want error, got nil")
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("got error %q, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,45 @@
+// Copyright (c) 2021 the SC authors. All rights reserved. MIT License.
+
+// Package example contains types used to test the code generated by scgen.
+package example
+
+import "time"
+
+//go:generate go run github.com/sc-lang/go-sc/cmd/scgen
+
+//scgen:generate
+type Config struct {
+	Name     string            `sc:"name,required"`
+	Version  int               `sc:"version"`
+	Debug    bool              `sc:"debug,omitempty"`
+	Ratio    float32           `sc:"ratio"`
+	Tags     []string          `sc:"tags,omitempty"`
+	Labels   map[string]string `sc:"labels"`
+	Server   Server            `sc:"server"`
+	Backup   *Server           `sc:"backup"`
+	Services []*Service        `sc:"services"`
+	Timeout  time.Duration     `sc:"timeout,omitempty"`
+	Extra    interface{}       `sc:"extra,omitempty"`
+	Secret   []byte            `sc:"secret"`
+	internal string
+	Ignored  bool `sc:"-"`
+}
+
+//scgen:generate
+type Server struct {
+	Host  string `sc:"host"`
+	Port  uint16 `sc:"port"`
+	Limit int8
+}
+
+//scgen:generate
+type Service struct {
+	Name  string              `sc:"name"`
+	Ports [][]uint            `sc:"ports"`
+	Env   map[string][]string `sc:"env,omitempty"`
+}
+
+// NotGenerated does not have the directive.
+type NotGenerated struct {
+	Name string
+}
@@ -0,0 +1,448 @@
+// Code generated by scgen. DO NOT EDIT.
+
+package example
+
+import (
+	"github.com/sc-lang/go-sc"
+	"github.com/sc-lang/go-sc/scgen"
+	"github.com/sc-lang/go-sc/scparse"
+)
+
+var scgenFieldsConfig = []string{"name", "version", "debug", "ratio", "tags", "labels", "server", "backup", "services", "timeout", "extra", "secret"}
+
+// UnmarshalSC implements sc.Unmarshaler.
+func (v *Config) UnmarshalSC(n scparse.ValueNode, vars sc.Variables) error {
+	dn, ok := n.(*scparse.DictionaryNode)
+	if !ok {
+		if _, ok := n.(*scparse.NullNode); ok {
+			return nil
+		}
+		return scgen.TypeError(n, v)
+	}
+	var errs sc.Errors
+	var seen0 bool
+	for _, mn := range dn.Members {
+		switch scgen.FieldName(mn.Key.KeyString(), scgenFieldsConfig) {
+		case "name":
+			seen0 = true
+			if x, ok := scgen.String(mn.Value); ok {
+				v.Name = x
+			} else {
+				errs = scgen.AppendError(errs, sc.UnmarshalNode(mn.Value, &v.Name, sc.WithVariables(vars)))
+			}
+		case "version":
+			if x, ok := scgen.Int(mn.Value, 0); ok {
+				v.Version = int(x)
+			} else {
+				errs = scgen.AppendError(errs, sc.UnmarshalNode(mn.Value, &v.Version, sc.WithVariables(vars)))
+			}
+		case "debug":
+			if x, ok := scgen.Bool(mn.Value); ok {
+				v.Debug = x
+			} else {
+				errs = scgen.AppendError(errs, sc.UnmarshalNode(mn.Value, &v.Debug, sc.WithVariables(vars)))
+			}
+		case "ratio":
+			if x, ok := scgen.Float(mn.Value, 32); ok {
+				v.Ratio = float32(x)
+			} else {
+				errs = scgen.AppendError(errs, sc.UnmarshalNode(mn.Value, &v.Ratio, sc.WithVariables(vars)))
+			}
+		case "tags":
+			if ln0, ok := mn.Value.(*scparse.ListNode); ok {
+				if cap(v.Tags) < len(ln0.Elements) {
+					s := make([]string, len(v.Tags), len(ln0.Elements))
+					copy(s, v.Tags)
+					v.Tags = s
+				}
+				v.Tags = v.Tags[:len(ln0.Elements)]
+				for i0, e0 := range ln0.Elements {
+					if x, ok := scgen.String(e0); ok {
+						v.Tags[i0] = x
+					} else {
+						errs = scgen.AppendError(errs, sc.UnmarshalNode(e0, &v.Tags[i0], sc.WithVariables(vars)))
+					}
+				}
+				if len(ln0.Elements) == 0 {
+					v.Tags = []string{}
+				}
+			} else {
+				errs = scgen.AppendError(errs, sc.UnmarshalNode(mn.Value, &v.Tags, sc.WithVariables(vars)))
+			}
+		case "labels":
+			if dn0, ok := mn.Value.(*scparse.DictionaryNode); ok {
+				if v.Labels == nil {
+					v.Labels = make(map[string]string, len(dn0.Members))
+				}
+				for _, mn0 := range dn0.Members {
+					var e0 string
+					if x, ok := scgen.String(mn0.Value); ok {
+						e0 = x
+					} else {
+						errs = scgen.AppendError(errs, sc.UnmarshalNode(mn0.Value, &e0, sc.WithVariables(vars)))
+					}
+					v.Labels[mn0.Key.KeyString()] = e0
+				}
+			} else {
+				errs = scgen.AppendError(errs, sc.UnmarshalNode(mn.Value, &v.Labels, sc.WithVariables(vars)))
+			}
+		case "server":
+			errs = scgen.AppendError(errs, v.Server.UnmarshalSC(mn.Value, vars))
+		case "backup":
+			switch mn.Value.(type) {
+			case *scparse.NullNode, *scparse.VariableNode, *scparse.ExpressionNode:
+				errs = scgen.AppendError(errs, sc.UnmarshalNode(mn.Value, &v.Backup, sc.WithVariables(vars)))
+			default:
+				if v.Backup == nil {
+					v.Backup = new(Server)
+				}
+				errs = scgen.AppendError(errs, (*v.Backup).UnmarshalSC(mn.Value, vars))
+			}
+		case "services":
+			if ln0, ok := mn.Value.(*scparse.ListNode); ok {
+				if cap(v.Services) < len(ln0.Elements) {
+					s := make([]*Service, len(v.Services), len(ln0.Elements))
+					copy(s, v.Services)
+					v.Services = s
+				}
+				v.Services = v.Services[:len(ln0.Elements)]
+				for i0, e0 := range ln0.Elements {
+					switch e0.(type) {
+					case *scparse.NullNode, *scparse.VariableNode, *scparse.ExpressionNode:
+						errs = scgen.AppendError(errs, sc.UnmarshalNode(e0, &v.Services[i0], sc.WithVariables(vars)))
+					default:
+						if v.Services[i0] == nil {
+							v.Services[i0] = new(Service)
+						}
+						errs = scgen.AppendError(errs, (*v.Services[i0]).UnmarshalSC(e0, vars))
+					}
+				}
+				if len(ln0.Elements) == 0 {
+					v.Services = []*Service{}
+				}
+			} else {
+				errs = scgen.AppendError(errs, sc.UnmarshalNode(mn.Value, &v.Services, sc.WithVariables(vars)))
+			}
+		case "timeout":
+			errs = scgen.AppendError(errs, sc.UnmarshalNode(mn.Value, &v.Timeout, sc.WithVariables(vars)))
+		case "extra":
+			errs = scgen.AppendError(errs, sc.UnmarshalNode(mn.Value, &v.Extra, sc.WithVariables(vars)))
+		case "secret":
+			errs = scgen.AppendError(errs, sc.UnmarshalNode(mn.Value, &v.Secret, sc.WithVariables(vars)))
+		}
+	}
+	if !seen0 {
+		errs = append(errs, &sc.UnmarshalMissingFieldError{Struct: "Config", Field: "name", Pos: dn.Pos})
+	}
+	return scgen.Result(errs)
+}
+
+// MarshalSC implements sc.Marshaler.
+func (v Config) MarshalSC() (scparse.ValueNode, error) {
+	members := make([]*scparse.MemberNode, 0, 12)
+	{
+		var vn scparse.ValueNode
+		vn = scgen.StringNode(v.Name)
+		members = append(members, &scparse.MemberNode{Key: scgen.Key("name"), Value: vn})
+	}
+	{
+		var vn scparse.ValueNode
+		vn = &scparse.NumberNode{IsInt: true, Int64: int64(v.Version)}
+		members = append(members, &scparse.MemberNode{Key: scgen.Key("version"), Value: vn})
+	}
+	if v.Debug {
+		var vn scparse.ValueNode
+		vn = &scparse.BoolNode{True: v.Debug}
+		members = append(members, &scparse.MemberNode{Key: scgen.Key("debug"), Value: vn})
+	}
+	{
+		var vn scparse.ValueNode
+		vn = scgen.Float32Node(v.Ratio)
+		members = append(members, &scparse.MemberNode{Key: scgen.Key("ratio"), Value: vn})
+	}
+	if len(v.Tags) != 0 {
+		var vn scparse.ValueNode
+		if v.Tags == nil {
+			vn = &scparse.NullNode{}
+		} else {
+			els0 := make([]scparse.ValueNode, len(v.Tags))
+			for i0, e0 := range v.Tags {
+				els0[i0] = scgen.StringNode(e0)
+			}
+			vn = &scparse.ListNode{Elements: els0}
+		}
+		members = append(members, &scparse.MemberNode{Key: scgen.Key("tags"), Value: vn})
+	}
+	{
+		var vn scparse.ValueNode
+		n, err := sc.MarshalNode(v.Labels)
+		if err != nil {
+			return nil, err
+		}
+		vn = n
+		members = append(members, &scparse.MemberNode{Key: scgen.Key("labels"), Value: vn})
+	}
+	{
+		var vn scparse.ValueNode
+		n, err := v.Server.MarshalSC()
+		if err != nil {
+			return nil, err
+		}
+		vn = n
+		members = append(members, &scparse.MemberNode{Key: scgen.Key("server"), Value: vn})
+	}
+	{
+		var vn scparse.ValueNode
+		if v.Backup == nil {
+			vn = &scparse.NullNode{}
+		} else {
+			n, err := (*v.Backup).MarshalSC()
+			if err != nil {
+				return nil, err
+			}
+			vn = n
+		}
+		members = append(members, &scparse.MemberNode{Key: scgen.Key("backup"), Value: vn})
+	}
+	{
+		var vn scparse.ValueNode
+		if v.Services == nil {
+			vn = &scparse.NullNode{}
+		} else {
+			els0 := make([]scparse.ValueNode, len(v.Services))
+			for i0, e0 := range v.Services {
+				if e0 == nil {
+					els0[i0] = &scparse.NullNode{}
+				} else {
+					n, err := (*e0).MarshalSC()
+					if err != nil {
+						return nil, err
+					}
+					els0[i0] = n
+				}
+			}
+			vn = &scparse.ListNode{Elements: els0}
+		}
+		members = append(members, &scparse.MemberNode{Key: scgen.Key("services"), Value: vn})
+	}
+	if !scgen.IsEmpty(v.Timeout) {
+		var vn scparse.ValueNode
+		n, err := sc.MarshalNode(v.Timeout)
+		if err != nil {
+			return nil, err
+		}
+		vn = n
+		members = append(members, &scparse.MemberNode{Key: scgen.Key("timeout"), Value: vn})
+	}
+	if v.Extra != nil {
+		var vn scparse.ValueNode
+		n, err := sc.MarshalNode(v.Extra)
+		if err != nil {
+			return nil, err
+		}
+		vn = n
+		members = append(members, &scparse.MemberNode{Key: scgen.Key("extra"), Value: vn})
+	}
+	{
+		var vn scparse.ValueNode
+		n, err := sc.MarshalNode(v.Secret)
+		if err != nil {
+			return nil, err
+		}
+		vn = n
+		members = append(members, &scparse.MemberNode{Key: scgen.Key("secret"), Value: vn})
+	}
+	return &scparse.DictionaryNode{Members: members}, nil
+}
+
+var scgenFieldsServer = []string{"host", "port", "Limit"}
+
+// UnmarshalSC implements sc.Unmarshaler.
+func (v *Server) UnmarshalSC(n scparse.ValueNode, vars sc.Variables) error {
+	dn, ok := n.(*scparse.DictionaryNode)
+	if !ok {
+		if _, ok := n.(*scparse.NullNode); ok {
+			return nil
+		}
+		return scgen.TypeError(n, v)
+	}
+	var errs sc.Errors
+	for _, mn := range dn.Members {
+		switch scgen.FieldName(mn.Key.KeyString(), scgenFieldsServer) {
+		case "host":
+			if x, ok := scgen.String(mn.Value); ok {
+				v.Host = x
+			} else {
+				errs = scgen.AppendError(errs, sc.UnmarshalNode(mn.Value, &v.Host, sc.WithVariables(vars)))
+			}
+		case "port":
+			if x, ok := scgen.Uint(mn.Value, 16); ok {
+				v.Port = uint16(x)
+			} else {
+				errs = scgen.AppendError(errs, sc.UnmarshalNode(mn.Value, &v.Port, sc.WithVariables(vars)))
+			}
+		case "Limit":
+			if x, ok := scgen.Int(mn.Value, 8); ok {
+				v.Limit = int8(x)
+			} else {
+				errs = scgen.AppendError(errs, sc.UnmarshalNode(mn.Value, &v.Limit, sc.WithVariables(vars)))
+			}
+		}
+	}
+	return scgen.Result(errs)
+}
+
+// MarshalSC implements sc.Marshaler.
+func (v Server) MarshalSC() (scparse.ValueNode, error) {
+	members := make([]*scparse.MemberNode, 0, 3)
+	{
+		var vn scparse.ValueNode
+		vn = scgen.StringNode(v.Host)
+		members = append(members, &scparse.MemberNode{Key: scgen.Key("host"), Value: vn})
+	}
+	{
+		var vn scparse.ValueNode
+		vn = &scparse.NumberNode{IsUint: true, Uint64: uint64(v.Port)}
+		members = append(members, &scparse.MemberNode{Key: scgen.Key("port"), Value: vn})
+	}
+	{
+		var vn scparse.ValueNode
+		vn = &scparse.NumberNode{IsInt: true, Int64: int64(v.Limit)}
+		members = append(members, &scparse.MemberNode{Key: scgen.Key("Limit"), Value: vn})
+	}
+	return &scparse.DictionaryNode{Members: members}, nil
+}
+
+var scgenFieldsService = []string{"name", "ports", "env"}
+
+// UnmarshalSC implements sc.Unmarshaler.
+func (v *Service) UnmarshalSC(n scparse.ValueNode, vars sc.Variables) error {
+	dn, ok := n.(*scparse.DictionaryNode)
+	if !ok {
+		if _, ok := n.(*scparse.NullNode); ok {
+			return nil
+		}
+		return scgen.TypeError(n, v)
+	}
+	var errs sc.Errors
+	for _, mn := range dn.Members {
+		switch scgen.FieldName(mn.Key.KeyString(), scgenFieldsService) {
+		case "name":
+			if x, ok := scgen.String(mn.Value); ok {
+				v.Name = x
+			} else {
+				errs = scgen.AppendError(errs, sc.UnmarshalNode(mn.Value, &v.Name, sc.WithVariables(vars)))
+			}
+		case "ports":
+			if ln0, ok := mn.Value.(*scparse.ListNode); ok {
+				if cap(v.Ports) < len(ln0.Elements) {
+					s := make([][]uint, len(v.Ports), len(ln0.Elements))
+					copy(s, v.Ports)
+					v.Ports = s
+				}
+				v.Ports = v.Ports[:len(ln0.Elements)]
+				for i0, e0 := range ln0.Elements {
+					if ln1, ok := e0.(*scparse.ListNode); ok {
+						if cap(v.Ports[i0]) < len(ln1.Elements) {
+							s := make([]uint, len(v.Ports[i0]), len(ln1.Elements))
+							copy(s, v.Ports[i0])
+							v.Ports[i0] = s
+						}
+						v.Ports[i0] = v.Ports[i0][:len(ln1.Elements)]
+						for i1, e1 := range ln1.Elements {
+							if x, ok := scgen.Uint(e1, 0); ok {
+								v.Ports[i0][i1] = uint(x)
+							} else {
+								errs = scgen.AppendError(errs, sc.UnmarshalNode(e1, &v.Ports[i0][i1], sc.WithVariables(vars)))
+							}
+						}
+						if len(ln1.Elements) == 0 {
+							v.Ports[i0] = []uint{}
+						}
+					} else {
+						errs = scgen.AppendError(errs, sc.UnmarshalNode(e0, &v.Ports[i0], sc.WithVariables(vars)))
+					}
+				}
+				if len(ln0.Elements) == 0 {
+					v.Ports = [][]uint{}
+				}
+			} else {
+				errs = scgen.AppendError(errs, sc.UnmarshalNode(mn.Value, &v.Ports, sc.WithVariables(vars)))
+			}
+		case "env":
+			if dn0, ok := mn.Value.(*scparse.DictionaryNode); ok {
+				if v.Env == nil {
+					v.Env = make(map[string][]string, len(dn0.Members))
+				}
+				for _, mn0 := range dn0.Members {
+					var e0 []string
+					if ln1, ok := mn0.Value.(*scparse.ListNode); ok {
+						if cap(e0) < len(ln1.Elements) {
+							s := make([]string, len(e0), len(ln1.Elements))
+							copy(s, e0)
+							e0 = s
+						}
+						e0 = e0[:len(ln1.Elements)]
+						for i1, e1 := range ln1.Elements {
+							if x, ok := scgen.String(e1); ok {
+								e0[i1] = x
+							} else {
+								errs = scgen.AppendError(errs, sc.UnmarshalNode(e1, &e0[i1], sc.WithVariables(vars)))
+							}
+						}
+						if len(ln1.Elements) == 0 {
+							e0 = []string{}
+						}
+					} else {
+						errs = scgen.AppendError(errs, sc.UnmarshalNode(mn0.Value, &e0, sc.WithVariables(vars)))
+					}
+					v.Env[mn0.Key.KeyString()] = e0
+				}
+			} else {
+				errs = scgen.AppendError(errs, sc.UnmarshalNode(mn.Value, &v.Env, sc.WithVariables(vars)))
+			}
+		}
+	}
+	return scgen.Result(errs)
+}
+
+// MarshalSC implements sc.Marshaler.
+func (v Service) MarshalSC() (scparse.ValueNode, error) {
+	members := make([]*scparse.MemberNode, 0, 3)
+	{
+		var vn scparse.ValueNode
+		vn = scgen.StringNode(v.Name)
+		members = append(members, &scparse.MemberNode{Key: scgen.Key("name"), Value: vn})
+	}
+	{
+		var vn scparse.ValueNode
+		if v.Ports == nil {
+			vn = &scparse.NullNode{}
+		} else {
+			els0 := make([]scparse.ValueNode, len(v.Ports))
+			for i0, e0 := range v.Ports {
+				if e0 == nil {
+					els0[i0] = &scparse.NullNode{}
+				} else {
+					els1 := make([]scparse.ValueNode, len(e0))
+					for i1, e1 := range e0 {
+						els1[i1] = &scparse.NumberNode{IsUint: true, Uint64: uint64(e1)}
+					}
+					els0[i0] = &scparse.ListNode{Elements: els1}
+				}
+			}
+			vn = &scparse.ListNode{Elements: els0}
+		}
+		members = append(members, &scparse.MemberNode{Key: scgen.Key("ports"), Value: vn})
+	}
+	if len(v.Env) != 0 {
+		var vn scparse.ValueNode
+		n, err := sc.MarshalNode(v.Env)
+		if err != nil {
+			return nil, err
+		}
+		vn = n
+		members = append(members, &scparse.MemberNode{Key: scgen.Key("env"), Value: vn})
+	}
+	return &scparse.DictionaryNode{Members: members}, nil
+}
@@ -0,0 +1,226 @@
+// Copyright (c) 2021 the SC authors. All rights reserved. MIT License.
+
+package example_test
+
+import (
+	"errors"
+	"reflect"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/sc-lang/go-sc"
+	"github.com/sc-lang/go-sc/cmd/scgen/internal/example"
+)
+
+func TestUnmarshal(t *testing.T) {
+	input := `{
+		name: "app-${env}"
+		version: 3
+		debug: true
+		ratio: 0.5
+		tags: ["a", "b"]
+		labels: { team: "core" }
+		server: { host: "localhost", port: 8080, limit: -1 }
+		backup: { host: "backup", port: ${port} }
+		services: [
+			{ name: "web", ports: [[80, 443], []], env: { A: ["1"] } }
+			null
+		]
+		timeout: ${timeout}
+		extra: [1, true]
+		secret: "aGk="
+		unknown: 1
+	}`
+	vars, err := sc.NewVariables(map[string]interface{}{
+		"env":     "prod",
+		"port":    uint16(9090),
+		"timeout": 5 * time.Second,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	var got example.Config
+	if err := sc.Unmarshal([]byte(input), &got, sc.WithVariables(vars)); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	want := example.Config{
+		Name:    "app-prod",
+		Version: 3,
+		Debug:   true,
+		Ratio:   0.5,
+		Tags:    []string{"a", "b"},
+		Labels:  map[string]string{"team": "core"},
+		Server:  example.Server{Host: "localhost", Port: 8080, Limit: -1},
+		Backup:  &example.Server{Host: "backup", Port: 9090},
+		Services: []*example.Service{
+			{Name: "web", Ports: [][]uint{{80, 443}, {}}, Env: map[string][]string{"A": {"1"}}},
+			nil,
+		},
+		Timeout: 5 * time.Second,
+		Extra:   []interface{}{1, true},
+		Secret:  []byte("hi"),
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got\n\t%+v\nwant\n\t%+v", got, want)
+	}
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	var c example.Config
+	err := sc.Unmarshal([]byte(`{ version: "x", server: { port: -1 } }`), &c)
+	var errs sc.Errors
+	if !errors.As(err, &errs) {
+		t.Fatalf("got error %v, want sc.Errors", err)
+	}
+	if len(errs) != 3 {
+		t.Fatalf("got %d errors, want 3: %v", len(errs), errs)
+	}
+	var typeErr *sc.UnmarshalTypeError
+	if !errors.As(errs[0], &typeErr) || typeErr.Type != reflect.TypeOf(0) {
+		t.Errorf("got error %v, want type error for version", errs[0])
+	}
+	if !errors.As(errs[1], &typeErr) || typeErr.Type != reflect.TypeOf(uint16(0)) {
+		t.Errorf("got error %v, want type error for port", errs[1])
+	}
+	var missingErr *sc.UnmarshalMissingFieldError
+	if !errors.As(errs[2], &missingErr) || missingErr.Field != "name" {
+		t.Errorf("got error %v, want missing field error for name", errs[2])
+	}
+
+	err = sc.Unmarshal([]byte(`{ name: "a", server: [] }`), &c)
+	if !errors.As(err, &errs) || len(errs) != 1 {
+		t.Fatalf("got error %v, want 1 error", err)
+	}
+	if !errors.As(errs[0], &typeErr) || typeErr.Type != reflect.TypeOf(example.Server{}) {
+		t.Errorf("got error %v, want type error for server", errs[0])
+	}
+}
+
+func TestMarshal(t *testing.T) {
+	c := example.Config{
+		Name:     "app",
+		Version:  1,
+		Labels:   map[string]string{"b": "2", "a": "1"},
+		Server:   example.Server{Host: "localhost", Port: 80},
+		Services: []*example.Service{{Name: "web", Ports: [][]uint{{80}}}},
+		Timeout:  time.Second,
+	}
+	b, err := sc.Marshal(c)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	want := `{
+  name: "app"
+  version: 1
+  ratio: 0
+  labels: {
+    a: "1"
+    b: "2"
+  }
+  server: {
+    host: "localhost"
+    port: 80
+    Limit: 0
+  }
+  backup: null
+  services: [
+    {
+      name: "web"
+      ports: [
+        [
+          80
+        ]
+      ]
+    }
+  ]
+  timeout: 1000000000
+  secret: null
+}
+`
+	if string(b) != want {
+		t.Errorf("got\n%s\nwant\n%s", b, want)
+	}
+
+	var got example.Config
+	if err := sc.Unmarshal(b, &got); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if !reflect.DeepEqual(got, c) {
+		t.Errorf("round trip got\n\t%+v\nwant\n\t%+v", got, c)
+	}
+}
+
+func TestMarshalFloat32(t *testing.T) {
+	// The generated code must produce the same output as reflection
+	for _, f := range []float32{0.1, 0.75, 1.0 / 3, 1e-7, 3.4e38} {
+		gen, err := sc.Marshal(example.Config{Ratio: f})
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		refl, err := sc.Marshal(struct {
+			Ratio float32 `sc:"ratio"`
+		}{f})
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		want := regexp.MustCompile(`ratio: .*`).Find(refl)
+		if got := regexp.MustCompile(`ratio: .*`).Find(gen); string(got) != string(want) {
+			t.Errorf("got %s, want %s", got, want)
+		}
+	}
+}
+
+// Benchmarks
+
+var benchInput = []byte(`{
+	name: "bench"
+	version: 3
+	ratio: 0.75
+	tags: ["a", "b", "c", "d"]
+	labels: { team: "core", tier: "backend", region: "eu" }
+	server: { host: "localhost", port: 8080 }
+	services: [
+		{ name: "web", ports: [[80, 443]], env: { A: ["1"], B: ["2"] } }
+		{ name: "api", ports: [[8080]], env: { A: ["1"], B: ["2"] } }
+		{ name: "db", ports: [[5432]] }
+	]
+}`)
+
+// plainConfig is the same as example.Config without generated methods.
+type plainConfig struct {
+	Name    string            `sc:"name,required"`
+	Version int               `sc:"version"`
+	Ratio   float32           `sc:"ratio"`
+	Tags    []string          `sc:"tags,omitempty"`
+	Labels  map[string]string `sc:"labels"`
+	Server  struct {
+		Host string `sc:"host"`
+		Port uint16 `sc:"port"`
+	} `sc:"server"`
+	Services []*struct {
+		Name  string              `sc:"name"`
+		Ports [][]uint            `sc:"ports"`
+		Env   map[string][]string `sc:"env,omitempty"`
+	} `sc:"services"`
+}
+
+func BenchmarkUnmarshalReflect(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var c plainConfig
+		if err := sc.Unmarshal(benchInput, &c); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkUnmarshalGenerated(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		var c example.Config
+		if err := sc.Unmarshal(benchInput, &c); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
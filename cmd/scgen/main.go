@@ -0,0 +1,92 @@
+// Copyright (c) 2021 the SC authors. All rights reserved. MIT License.
+
+// Scgen generates UnmarshalSC and MarshalSC methods for Go struct types.
+//
+// The generated methods implement sc.Unmarshaler and sc.Marshaler without
+// using reflection for fields of basic types (bools, numbers and strings),
+// pointers, slices, maps with string keys, and other struct types that have
+// generated methods. Fields of any other type are decoded and encoded using
+// sc.UnmarshalNode and sc.MarshalNode.
+//
+// Usage:
+//
+//	scgen [flags] [file.go]
+//
+// Code is generated for the struct types in the file that have a
+// //scgen:generate comment in their documentation, or for the types named
+// with the -type flag. If no file is given, the file named by the GOFILE
+// environment variable is used, which makes scgen convenient to use with go generate:
+//
+//	//go:generate go run github.com/sc-lang/go-sc/cmd/scgen
+//
+//	//scgen:generate
+//	type Config struct {
+//		Name string `sc:"name"`
+//	}
+//
+// The generated code is written to file_sc.go unless the -output flag is given.
+//
+// Struct fields are handled the same way as the sc package, including the
+// omitempty and required tag options. The string, inline, remain, format, hex, base64url
+// and bytelist tag options and embedded structs without a name in the sc tag are not supported.
+//
+// The generated methods do not have access to the options passed to sc.Unmarshal,
+// other than the variables, and always behave as if the default options were used.
+// The ValidateSC method of a field is not called if the field is decoded by
+// generated code, only the ValidateSC method of the outermost value is called by sc.Unmarshal.
+// Struct fields are always encoded in the order they are declared.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+func main() {
+	typeNames := flag.String("type", "", "comma-separated list of type names; overrides //scgen:generate comments")
+	all := flag.Bool("all", false, "generate code for all struct types in the file")
+	output := flag.String("output", "", "output file name; default file_sc.go")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: scgen [flags] [file.go]\n\nFlags:\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	var filename string
+	switch flag.NArg() {
+	case 0:
+		filename = os.Getenv("GOFILE")
+		if filename == "" {
+			flag.Usage()
+			os.Exit(2)
+		}
+	case 1:
+		filename = flag.Arg(0)
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var cfg config
+	cfg.all = *all
+	if *typeNames != "" {
+		cfg.types = strings.Split(*typeNames, ",")
+	}
+	src, err := generate(filename, nil, cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "scgen: %v\n", err)
+		os.Exit(1)
+	}
+
+	outName := *output
+	if outName == "" {
+		outName = strings.TrimSuffix(filename, ".go") + "_sc.go"
+	}
+	if err := ioutil.WriteFile(outName, src, 0o644); err != nil {
+		fmt.Fprintf(os.Stderr, "scgen: %v\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,130 @@
+// Copyright (c) 2021 the SC authors. All rights reserved. MIT License.
+
+// Scvalidate checks that SC files are valid.
+//
+// Usage:
+//
+//	scvalidate [flags] path ...
+//
+// Each file is parsed and all syntax errors are reported. Given a directory,
+// scvalidate checks all .sc files in that directory, recursively. Each error is
+// reported with a code frame of the surrounding source. scvalidate exits with
+// status 1 if any file is invalid.
+//
+// Files can additionally be validated against a JSON Schema with the -schema flag,
+// for example a schema generated by sc.JSONSchema. The keywords type, enum, const,
+// properties, required, additionalProperties, items, minItems, maxItems, minLength,
+// maxLength, pattern, minimum, maximum, exclusiveMinimum, exclusiveMaximum, allOf,
+// anyOf, oneOf, and $ref to the schema or its $defs are supported, other keywords are
+// ignored. Variables and expressions match any schema since their values are not known.
+//
+// Files can also be validated by unmarshaling them into a Go type using the -plugin flag,
+// which loads a Go plugin (see the plugin package) that exports a variable of the type.
+// The variable is named Config unless the -symbol flag is given:
+//
+//	// Build with: go build -buildmode=plugin -o config.so
+//	package main
+//
+//	var Config myapp.Config
+//
+// The flags are:
+//
+//	-schema file
+//		Validate the files against the JSON Schema in file.
+//	-plugin file
+//		Validate the files by unmarshaling them into a variable exported by the Go plugin file.
+//	-symbol name
+//		The name of the variable exported by the plugin. The default is Config.
+//	-disallow-unknown-fields
+//		Report keys that do not match a field when unmarshaling into the plugin type.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func main() {
+	schemaFile := flag.String("schema", "", "validate against the JSON Schema in `file`")
+	pluginFile := flag.String("plugin", "", "validate by unmarshaling into a variable exported by the Go plugin `file`")
+	symbol := flag.String("symbol", "Config", "`name` of the variable exported by the plugin")
+	disallowUnknown := flag.Bool("disallow-unknown-fields", false, "report unknown keys when unmarshaling into the plugin type")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: scvalidate [flags] path ...\n\nFlags:\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var v validation
+	if *schemaFile != "" {
+		data, err := ioutil.ReadFile(*schemaFile)
+		if err == nil {
+			v.schema, err = parseSchema(data)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "scvalidate: %s: %v\n", *schemaFile, err)
+			os.Exit(2)
+		}
+	}
+	if *pluginFile != "" {
+		typ, err := loadPluginType(*pluginFile, *symbol)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "scvalidate: %v\n", err)
+			os.Exit(2)
+		}
+		v.typ = typ
+		v.disallowUnknownFields = *disallowUnknown
+	}
+
+	invalid := false
+	check := func(path string) {
+		errs, err := v.validateFile(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "scvalidate: %v\n", err)
+			invalid = true
+			return
+		}
+		for _, e := range errs {
+			fmt.Fprintln(os.Stderr, e)
+		}
+		if len(errs) > 0 {
+			invalid = true
+		}
+	}
+	for _, arg := range flag.Args() {
+		info, err := os.Stat(arg)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "scvalidate: %v\n", err)
+			invalid = true
+			continue
+		}
+		if !info.IsDir() {
+			check(arg)
+			continue
+		}
+		err = filepath.Walk(arg, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if !info.IsDir() && !strings.HasPrefix(info.Name(), ".") && strings.HasSuffix(info.Name(), ".sc") {
+				check(path)
+			}
+			return nil
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "scvalidate: %v\n", err)
+			invalid = true
+		}
+	}
+	if invalid {
+		os.Exit(1)
+	}
+}
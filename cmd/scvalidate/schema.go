@@ -0,0 +1,514 @@
+// Copyright (c) 2021 the SC authors. All rights reserved. MIT License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/sc-lang/go-sc/scparse"
+)
+
+// schemaError describes a value that does not match the schema.
+type schemaError struct {
+	Pos  scparse.Pos
+	Path string // Path of the value, empty for the top level dictionary.
+	Msg  string
+}
+
+func (e *schemaError) Error() string {
+	if e.Path == "" {
+		return fmt.Sprintf("%d:%d: %s", e.Pos.Line, e.Pos.Column, e.Msg)
+	}
+	return fmt.Sprintf("%d:%d: %s: %s", e.Pos.Line, e.Pos.Column, e.Path, e.Msg)
+}
+
+// schema is a JSON Schema. Only the keywords used by the validator are decoded,
+// other keywords, such as format, are ignored.
+type schema struct {
+	// always is set for the boolean schemas true and false.
+	always *bool
+
+	Ref                  string             `json:"$ref"`
+	Defs                 map[string]*schema `json:"$defs"`
+	Definitions          map[string]*schema `json:"definitions"`
+	Type                 schemaTypes        `json:"type"`
+	Enum                 []interface{}      `json:"enum"`
+	Const                *interface{}       `json:"const"`
+	Properties           map[string]*schema `json:"properties"`
+	Required             []string           `json:"required"`
+	AdditionalProperties *schema            `json:"additionalProperties"`
+	Items                *schema            `json:"items"`
+	MinItems             *int               `json:"minItems"`
+	MaxItems             *int               `json:"maxItems"`
+	MinLength            *int               `json:"minLength"`
+	MaxLength            *int               `json:"maxLength"`
+	Pattern              string             `json:"pattern"`
+	Minimum              *float64           `json:"minimum"`
+	Maximum              *float64           `json:"maximum"`
+	ExclusiveMinimum     *float64           `json:"exclusiveMinimum"`
+	ExclusiveMaximum     *float64           `json:"exclusiveMaximum"`
+	AllOf                []*schema          `json:"allOf"`
+	AnyOf                []*schema          `json:"anyOf"`
+	OneOf                []*schema          `json:"oneOf"`
+
+	pattern *regexp.Regexp
+}
+
+func (s *schema) UnmarshalJSON(data []byte) error {
+	var b bool
+	if err := json.Unmarshal(data, &b); err == nil {
+		s.always = &b
+		return nil
+	}
+	type plain schema
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode((*plain)(s)); err != nil {
+		return err
+	}
+	if s.Pattern != "" {
+		re, err := regexp.Compile(s.Pattern)
+		if err != nil {
+			return fmt.Errorf("invalid pattern %q: %v", s.Pattern, err)
+		}
+		s.pattern = re
+	}
+	return nil
+}
+
+// schemaTypes is the value of the type keyword, which is either a string or a list of strings.
+type schemaTypes []string
+
+func (t *schemaTypes) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*t = schemaTypes{s}
+		return nil
+	}
+	return json.Unmarshal(data, (*[]string)(t))
+}
+
+// parseSchema parses the JSON Schema in data.
+func parseSchema(data []byte) (*schema, error) {
+	var s schema
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("invalid schema: %v", err)
+	}
+	return &s, nil
+}
+
+// validator validates SC values against a schema.
+type validator struct {
+	root   *schema
+	errors []error
+}
+
+// validateSchema validates the document n against the schema root and returns
+// the values that do not match. Anchors are expanded before validating.
+//
+// Variables and expressions match any schema since their values are not known
+// until the document is unmarshaled. Strings that contain variables match any
+// string schema.
+func validateSchema(n *scparse.DictionaryNode, root *schema) ([]error, error) {
+	v, err := scparse.ExpandAnchors(n)
+	if err != nil {
+		return nil, err
+	}
+	val := validator{root: root}
+	if err := val.validate(v, root, ""); err != nil {
+		return nil, err
+	}
+	return val.errors, nil
+}
+
+func (val *validator) errorf(n scparse.Node, path, format string, args ...interface{}) {
+	val.errors = append(val.errors, &schemaError{Pos: n.Position(), Path: path, Msg: fmt.Sprintf(format, args...)})
+}
+
+// resolveRef returns the schema referenced by ref. Only references to the
+// root schema and its definitions are supported.
+func (val *validator) resolveRef(ref string) (*schema, error) {
+	if ref == "#" {
+		return val.root, nil
+	}
+	for _, prefix := range []string{"#/$defs/", "#/definitions/"} {
+		if !strings.HasPrefix(ref, prefix) {
+			continue
+		}
+		name := strings.NewReplacer("~1", "/", "~0", "~").Replace(ref[len(prefix):])
+		defs := val.root.Defs
+		if prefix == "#/definitions/" {
+			defs = val.root.Definitions
+		}
+		if s, ok := defs[name]; ok {
+			return s, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid schema: unsupported or unknown $ref %q", ref)
+}
+
+// matches reports whether n matches s without recording any errors.
+func (val *validator) matches(n scparse.ValueNode, s *schema) (bool, error) {
+	sub := validator{root: val.root}
+	if err := sub.validate(n, s, ""); err != nil {
+		return false, err
+	}
+	return len(sub.errors) == 0, nil
+}
+
+// allowsType reports whether the type keyword of s, or of the schema it references, allows n.
+func (val *validator) allowsType(n scparse.ValueNode, s *schema) (bool, error) {
+	if s.always != nil {
+		return *s.always, nil
+	}
+	if s.Ref != "" {
+		rs, err := val.resolveRef(s.Ref)
+		if err != nil {
+			return false, err
+		}
+		if ok, err := val.allowsType(n, rs); !ok || err != nil {
+			return false, err
+		}
+	}
+	if len(s.Type) == 0 {
+		return true, nil
+	}
+	typ := nodeType(n)
+	for _, t := range s.Type {
+		if t == typ || (t == "number" && typ == "integer") {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// validate validates n against s and records the errors found.
+// The returned error is only used for problems with the schema itself.
+func (val *validator) validate(n scparse.ValueNode, s *schema, path string) error {
+	if s.always != nil {
+		if !*s.always {
+			val.errorf(n, path, "no value is allowed")
+		}
+		return nil
+	}
+	if s.Ref != "" {
+		rs, err := val.resolveRef(s.Ref)
+		if err != nil {
+			return err
+		}
+		if err := val.validate(n, rs, path); err != nil {
+			return err
+		}
+	}
+	switch n.(type) {
+	case *scparse.VariableNode, *scparse.ExpressionNode:
+		// The value is not known
+		return nil
+	}
+
+	if len(s.Type) > 0 {
+		if ok, _ := val.allowsType(n, &schema{Type: s.Type}); !ok {
+			val.errorf(n, path, "expected %s, got %s", strings.Join(s.Type, " or "), nodeType(n))
+			// The other keywords would only produce confusing errors
+			return nil
+		}
+	}
+	if s.Enum != nil || s.Const != nil {
+		if err := val.validateValue(n, s, path); err != nil {
+			return err
+		}
+	}
+
+	for _, sub := range s.AllOf {
+		if err := val.validate(n, sub, path); err != nil {
+			return err
+		}
+	}
+	if len(s.AnyOf) > 0 {
+		matched := false
+		for _, sub := range s.AnyOf {
+			ok, err := val.matches(n, sub)
+			if err != nil {
+				return err
+			}
+			if ok {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			// If only one schema allows the type of the value, its errors are the most helpful
+			var candidates []*schema
+			for _, sub := range s.AnyOf {
+				ok, err := val.allowsType(n, sub)
+				if err != nil {
+					return err
+				}
+				if ok {
+					candidates = append(candidates, sub)
+				}
+			}
+			if len(candidates) == 1 {
+				if err := val.validate(n, candidates[0], path); err != nil {
+					return err
+				}
+			} else {
+				val.errorf(n, path, "value does not match any of the allowed schemas")
+			}
+		}
+	}
+	if len(s.OneOf) > 0 {
+		count := 0
+		for _, sub := range s.OneOf {
+			ok, err := val.matches(n, sub)
+			if err != nil {
+				return err
+			}
+			if ok {
+				count++
+			}
+		}
+		if count != 1 {
+			val.errorf(n, path, "value matches %d of the allowed schemas, must match exactly one", count)
+		}
+	}
+
+	switch n := n.(type) {
+	case *scparse.NumberNode:
+		val.validateNumber(n, s, path)
+	case *scparse.InterpolatedStringNode, *scparse.RawStringNode, *scparse.MultilineStringNode:
+		if str, ok := stringValue(n); ok {
+			val.validateString(n, str, s, path)
+		}
+	case *scparse.ListNode:
+		l := len(n.Elements)
+		if s.MinItems != nil && l < *s.MinItems {
+			val.errorf(n, path, "list has %d elements, the minimum is %d", l, *s.MinItems)
+		}
+		if s.MaxItems != nil && l > *s.MaxItems {
+			val.errorf(n, path, "list has %d elements, the maximum is %d", l, *s.MaxItems)
+		}
+		if s.Items != nil {
+			for i, e := range n.Elements {
+				if err := val.validate(e, s.Items, path+"["+strconv.Itoa(i)+"]"); err != nil {
+					return err
+				}
+			}
+		}
+	case *scparse.DictionaryNode:
+		return val.validateDict(n, s, path)
+	}
+	return nil
+}
+
+func (val *validator) validateDict(n *scparse.DictionaryNode, s *schema, path string) error {
+	for _, key := range s.Required {
+		if n.Member(key) == nil {
+			val.errorf(n, path, "missing required key %q", key)
+		}
+	}
+	for _, m := range n.Members {
+		key := m.Key.KeyString()
+		if last := n.Member(key); last != m {
+			// Only the last value of a duplicate key is used
+			continue
+		}
+		mpath := joinKey(path, key)
+		if ps, ok := s.Properties[key]; ok {
+			if err := val.validate(m.Value, ps, mpath); err != nil {
+				return err
+			}
+			continue
+		}
+		if s.AdditionalProperties == nil {
+			continue
+		}
+		if ap := s.AdditionalProperties; ap.always != nil && !*ap.always {
+			val.errorf(m.Key, path, "unknown key %q", key)
+			continue
+		}
+		if err := val.validate(m.Value, s.AdditionalProperties, mpath); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (val *validator) validateNumber(n *scparse.NumberNode, s *schema, path string) {
+	f := numberValue(n)
+	if s.Minimum != nil && f < *s.Minimum {
+		val.errorf(n, path, "%s is less than the minimum %v", n.Raw, *s.Minimum)
+	}
+	if s.Maximum != nil && f > *s.Maximum {
+		val.errorf(n, path, "%s is greater than the maximum %v", n.Raw, *s.Maximum)
+	}
+	if s.ExclusiveMinimum != nil && f <= *s.ExclusiveMinimum {
+		val.errorf(n, path, "%s must be greater than %v", n.Raw, *s.ExclusiveMinimum)
+	}
+	if s.ExclusiveMaximum != nil && f >= *s.ExclusiveMaximum {
+		val.errorf(n, path, "%s must be less than %v", n.Raw, *s.ExclusiveMaximum)
+	}
+}
+
+func (val *validator) validateString(n scparse.ValueNode, str string, s *schema, path string) {
+	l := utf8.RuneCountInString(str)
+	if s.MinLength != nil && l < *s.MinLength {
+		val.errorf(n, path, "string has %d characters, the minimum is %d", l, *s.MinLength)
+	}
+	if s.MaxLength != nil && l > *s.MaxLength {
+		val.errorf(n, path, "string has %d characters, the maximum is %d", l, *s.MaxLength)
+	}
+	if s.pattern != nil && !s.pattern.MatchString(str) {
+		val.errorf(n, path, "string %q does not match the pattern %q", str, s.Pattern)
+	}
+}
+
+// validateValue checks the enum and const keywords of s.
+func (val *validator) validateValue(n scparse.ValueNode, s *schema, path string) error {
+	v, err := jsonValue(n)
+	if err != nil {
+		// The value contains variables
+		return nil
+	}
+	if s.Const != nil {
+		c, err := normalize(*s.Const)
+		if err != nil {
+			return err
+		}
+		if !reflect.DeepEqual(v, c) {
+			val.errorf(n, path, "value must be %s", formatJSON(*s.Const))
+		}
+	}
+	if s.Enum != nil {
+		for _, e := range s.Enum {
+			e, err := normalize(e)
+			if err != nil {
+				return err
+			}
+			if reflect.DeepEqual(v, e) {
+				return nil
+			}
+		}
+		allowed := make([]string, len(s.Enum))
+		for i, e := range s.Enum {
+			allowed[i] = formatJSON(e)
+		}
+		val.errorf(n, path, "value must be one of %s", strings.Join(allowed, ", "))
+	}
+	return nil
+}
+
+// nodeType returns the JSON type of n.
+func nodeType(n scparse.ValueNode) string {
+	switch n := n.(type) {
+	case *scparse.NullNode:
+		return "null"
+	case *scparse.BoolNode:
+		return "boolean"
+	case *scparse.NumberNode:
+		if f := numberValue(n); f == math.Trunc(f) {
+			return "integer"
+		}
+		return "number"
+	case *scparse.ListNode:
+		return "array"
+	case *scparse.DictionaryNode:
+		return "object"
+	}
+	return "string"
+}
+
+func numberValue(n *scparse.NumberNode) float64 {
+	switch {
+	case n.IsInt:
+		return float64(n.Int64)
+	case n.IsUint:
+		return float64(n.Uint64)
+	}
+	return n.Float64
+}
+
+// stringValue returns the value of the string n. ok is false if it contains variables.
+func stringValue(n scparse.ValueNode) (s string, ok bool) {
+	switch n := n.(type) {
+	case *scparse.RawStringNode:
+		return n.Value, true
+	case *scparse.MultilineStringNode:
+		return n.Value, true
+	case *scparse.InterpolatedStringNode:
+		var sb strings.Builder
+		for _, c := range n.Components {
+			sn, ok := c.(*scparse.StringNode)
+			if !ok {
+				return "", false
+			}
+			sb.WriteString(sn.Value)
+		}
+		return sb.String(), true
+	}
+	return "", false
+}
+
+// jsonValue returns the value of n in the same form as a decoded JSON value,
+// so that it can be compared to the values in a schema.
+func jsonValue(n scparse.ValueNode) (interface{}, error) {
+	v, err := scparse.ToGo(n)
+	if err != nil {
+		return nil, err
+	}
+	return normalize(v)
+}
+
+// normalize converts v to the form returned by json.Unmarshal into an interface{}.
+func normalize(v interface{}) (interface{}, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var out interface{}
+	if err := json.Unmarshal(b, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func formatJSON(v interface{}) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
+
+// joinKey appends the key k to path, ex: server.port.
+// Keys that are not identifiers are quoted.
+func joinKey(path, k string) string {
+	if !isIdentifier(k) {
+		k = strconv.Quote(k)
+	}
+	if path == "" {
+		return k
+	}
+	return path + "." + k
+}
+
+func isIdentifier(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r != '_' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			return false
+		}
+	}
+	return true
+}
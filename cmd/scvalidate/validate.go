@@ -0,0 +1,89 @@
+// Copyright (c) 2021 the SC authors. All rights reserved. MIT License.
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"plugin"
+	"reflect"
+
+	"github.com/sc-lang/go-sc"
+	"github.com/sc-lang/go-sc/scparse"
+)
+
+// validation describes how files are validated.
+type validation struct {
+	schema *schema // The schema files must match, nil if not set.
+	// The type files are unmarshaled into, nil if not set.
+	typ                   reflect.Type
+	disallowUnknownFields bool
+}
+
+// loadPluginType returns the type of the variable symbol exported by the Go plugin in filename.
+func loadPluginType(filename, symbol string) (reflect.Type, error) {
+	p, err := plugin.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	sym, err := p.Lookup(symbol)
+	if err != nil {
+		return nil, err
+	}
+	// Looking up a variable returns a pointer to it
+	t := reflect.TypeOf(sym)
+	if t.Kind() != reflect.Ptr || t.Elem().Kind() == reflect.Func {
+		return nil, fmt.Errorf("%s: symbol %s must be a variable", filename, symbol)
+	}
+	return t.Elem(), nil
+}
+
+// validateFile validates the file filename and returns the problems found,
+// each formatted with a code frame. err is only set if the file could not be read.
+func (v *validation) validateFile(filename string) (problems []string, err error) {
+	src, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return v.validate(filename, src), nil
+}
+
+// validate validates src, which was read from filename.
+func (v *validation) validate(filename string, src []byte) []string {
+	var problems []string
+	n, err := scparse.ParseTolerant(src, scparse.WithFilename(filename))
+	if errs, ok := err.(scparse.ErrorList); ok {
+		for _, e := range errs {
+			problems = append(problems, scparse.FormatError(src, e))
+		}
+	} else if err != nil {
+		problems = append(problems, scparse.FormatError(src, err))
+	}
+	if n == nil || err != nil {
+		// The document is incomplete so further errors would be misleading
+		return problems
+	}
+
+	if v.schema != nil {
+		errs, err := validateSchema(n, v.schema)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", filename, err))
+		}
+		for _, e := range errs {
+			se := e.(*schemaError)
+			problems = append(problems, scparse.FormatErrorAt(src, fmt.Errorf("%s:%v", filename, e), se.Pos))
+		}
+	}
+	if v.typ != nil {
+		ptr := reflect.New(v.typ)
+		err := sc.UnmarshalNode(n, ptr.Interface(), sc.WithDisallowUnknownFields(v.disallowUnknownFields))
+		if errs, ok := err.(sc.Errors); ok {
+			for _, e := range errs {
+				problems = append(problems, filename+": "+sc.FormatError(src, e))
+			}
+		} else if err != nil {
+			problems = append(problems, filename+": "+sc.FormatError(src, err))
+		}
+	}
+	return problems
+}
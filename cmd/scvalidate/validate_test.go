@@ -0,0 +1,153 @@
+// Copyright (c) 2021 the SC authors. All rights reserved. MIT License.
+
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sc-lang/go-sc"
+)
+
+type server struct {
+	Host string `sc:"host,required"`
+	Port uint16 `sc:"port"`
+}
+
+type config struct {
+	Name    string            `sc:"name,required"`
+	Servers []server          `sc:"servers"`
+	Backup  *server           `sc:"backup"`
+	Ratio   float64           `sc:"ratio"`
+	Labels  map[string]string `sc:"labels"`
+}
+
+func TestValidateSchema(t *testing.T) {
+	data, err := sc.JSONSchema(config{}, sc.WithSchemaDisallowUnknownFields(true))
+	if err != nil {
+		t.Fatalf("failed to generate schema: %v", err)
+	}
+	s, err := parseSchema(data)
+	if err != nil {
+		t.Fatalf("failed to parse schema: %v", err)
+	}
+	v := validation{schema: s}
+
+	src := `{
+  name: "app"
+  servers: [{host: "a", port: 80}, {host: "b", port: ${PORT}}]
+  backup: &b {host: "c"}
+  ratio: 1.5
+  labels: {env: "${ENV}", "team name": "x"}
+}`
+	if got := v.validate("config.sc", []byte(src)); len(got) != 0 {
+		t.Errorf("got problems for valid file:\n%s", strings.Join(got, "\n"))
+	}
+
+	src = `{
+  servers: [{host: "a", port: 70000}, {port: 1}]
+  backup: {host: 1}
+  ratio: "1.5"
+  labels: {env: 1}
+  extra: true
+}`
+	want := []string{
+		"config.sc:1:1: missing required key \"name\"\n" +
+			"> 1 | {\n" +
+			"    | ^\n" +
+			"  2 |   servers: [{host: \"a\", port: 70000}, {port: 1}]\n" +
+			"  3 |   backup: {host: 1}",
+		"config.sc:2:31: servers[0].port: 70000 is greater than the maximum 65535",
+		"config.sc:2:39: servers[1]: missing required key \"host\"",
+		"config.sc:3:18: backup.host: expected string, got integer",
+		"config.sc:4:10: ratio: expected number, got string",
+		"config.sc:5:17: labels.env: expected string, got integer",
+		"config.sc:6:3: unknown key \"extra\"",
+	}
+	got := v.validate("config.sc", []byte(src))
+	if len(got) != len(want) {
+		t.Fatalf("got %d problems, want %d:\n%s", len(got), len(want), strings.Join(got, "\n"))
+	}
+	for i := range want {
+		if !strings.HasPrefix(got[i], want[i]) {
+			t.Errorf("problem %d:\ngot\n%s\nwant prefix\n%s", i, got[i], want[i])
+		}
+	}
+}
+
+func TestValidateSchemaKeywords(t *testing.T) {
+	s, err := parseSchema([]byte(`{
+  "type": "object",
+  "properties": {
+    "level": {"enum": ["debug", "info"]},
+    "version": {"const": 2},
+    "name": {"type": "string", "minLength": 2, "maxLength": 4, "pattern": "^[a-z]+$"},
+    "tags": {"type": "array", "minItems": 1, "maxItems": 2},
+    "port": {"type": "integer", "exclusiveMinimum": 0},
+    "id": {"oneOf": [{"type": "string"}, {"type": "integer"}]},
+    "never": false
+  }
+}`))
+	if err != nil {
+		t.Fatalf("failed to parse schema: %v", err)
+	}
+	v := validation{schema: s}
+	src := `{level: "warn", version: 1, name: "ABCDE", tags: [], port: 0, id: true, never: null}`
+	want := []string{
+		`x.sc:1:9: level: value must be one of "debug", "info"`,
+		`x.sc:1:26: version: value must be 2`,
+		`x.sc:1:35: name: string has 5 characters, the maximum is 4`,
+		`x.sc:1:35: name: string "ABCDE" does not match the pattern "^[a-z]+$"`,
+		`x.sc:1:50: tags: list has 0 elements, the minimum is 1`,
+		`x.sc:1:60: port: 0 must be greater than 0`,
+		`x.sc:1:67: id: value matches 0 of the allowed schemas, must match exactly one`,
+		`x.sc:1:80: never: no value is allowed`,
+	}
+	got := v.validate("x.sc", []byte(src))
+	if len(got) != len(want) {
+		t.Fatalf("got %d problems, want %d:\n%s", len(got), len(want), strings.Join(got, "\n"))
+	}
+	for i := range want {
+		if first := strings.SplitN(got[i], "\n", 2)[0]; first != want[i] {
+			t.Errorf("problem %d: got %q, want %q", i, first, want[i])
+		}
+	}
+}
+
+func TestValidateSyntaxErrors(t *testing.T) {
+	v := validation{}
+	got := v.validate("x.sc", []byte("{\n  a: ,\n  b: 1\n  c: }\n"))
+	if len(got) != 2 {
+		t.Fatalf("got %d problems, want 2:\n%s", len(got), strings.Join(got, "\n"))
+	}
+	for i, line := range []string{"2", "4"} {
+		if !strings.HasPrefix(got[i], "sc: Parse Error: x.sc:"+line+":") {
+			t.Errorf("problem %d does not report line %s:\n%s", i, line, got[i])
+		}
+		if !strings.Contains(got[i], "> "+line+" |") {
+			t.Errorf("problem %d does not contain a code frame:\n%s", i, got[i])
+		}
+	}
+}
+
+func TestValidateType(t *testing.T) {
+	v := validation{typ: reflect.TypeOf(config{}), disallowUnknownFields: true}
+	got := v.validate("x.sc", []byte(`{name: "a", ratio: "x", other: 1}`))
+	if len(got) != 2 {
+		t.Fatalf("got %d problems, want 2:\n%s", len(got), strings.Join(got, "\n"))
+	}
+	want := []string{
+		"x.sc: sc: cannot unmarshal InterpolatedString into Go struct field config.ratio of type float64\n" +
+			"> 1 | {name: \"a\", ratio: \"x\", other: 1}\n" +
+			"    |                    ^",
+		"x.sc: sc: unknown field \"other\" in Go struct config\n" +
+			"> 1 | {name: \"a\", ratio: \"x\", other: 1}\n" +
+			"    |                         ^",
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("problem %d: got\n%s\nwant\n%s", i, got[i], want[i])
+		}
+	}
+}
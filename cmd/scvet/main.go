@@ -0,0 +1,24 @@
+// Copyright (c) 2021 the SC authors. All rights reserved. MIT License.
+
+// Scvet checks Go packages for mistakes in the use of the sc package,
+// such as malformed sc struct tags and methods with the wrong signature.
+// See github.com/sc-lang/go-sc/scvet for the list of checks.
+//
+// Usage:
+//
+//	scvet [flags] [packages]
+//
+// The -tag flag sets the name of the struct tag to check if sc.WithTagName is used.
+// scvet can also be run by go vet:
+//
+//	go vet -vettool=$(which scvet) ./...
+package main
+
+import (
+	"github.com/sc-lang/go-sc/scvet"
+	"golang.org/x/tools/go/analysis/singlechecker"
+)
+
+func main() {
+	singlechecker.Main(scvet.Analyzer)
+}
@@ -0,0 +1,410 @@
+// Copyright (c) 2021 the SC authors. All rights reserved. MIT License.
+
+package sc
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/sc-lang/go-sc/scparse"
+)
+
+var unmarshalerType = reflect.TypeOf((*Unmarshaler)(nil)).Elem()
+
+// A TypeDecoder decodes SC values into values of a single Go type.
+//
+// A TypeDecoder analyzes the type once when it is created and builds a decode
+// function for each field and element type. This avoids repeating most of the
+// reflection work performed by Unmarshal, which is useful when decoding many
+// documents with the same shape.
+//
+// Decoding with a TypeDecoder behaves exactly like Unmarshal with the options
+// that were given to CompileType. A TypeDecoder is safe for concurrent use.
+type TypeDecoder struct {
+	typ reflect.Type
+	dec decodeFunc
+	d   decoder // options applied to each decode
+}
+
+// CompileType returns a TypeDecoder that decodes values of type t.
+// The options are applied to every call to the decoder's Unmarshal methods.
+func CompileType(t reflect.Type, opts ...UnmarshalOption) (*TypeDecoder, error) {
+	if t == nil {
+		return nil, fmt.Errorf("sc: CompileType(nil)")
+	}
+	td := &TypeDecoder{typ: t}
+	if err := td.d.applyOptions(opts); err != nil {
+		return nil, err
+	}
+	if len(td.d.hooks) > 0 {
+		// Hooks must be consulted for every value, which the compiled functions skip.
+		td.dec = (*decoder).decodeValue
+	} else {
+		td.dec = compiledDecodeFunc(t, td.d.tags)
+	}
+	return td, nil
+}
+
+// Type returns the type decoded by td.
+func (td *TypeDecoder) Type() reflect.Type {
+	return td.typ
+}
+
+// Unmarshal parses the SC-encoded data and stores the result in the value pointed to by v.
+// v must be a non-nil pointer to a value of the type td was compiled for.
+func (td *TypeDecoder) Unmarshal(data []byte, v interface{}) error {
+	n, err := scparse.Parse(data, td.d.parseOpts...)
+	if err != nil {
+		return err
+	}
+	return td.UnmarshalNode(n, v)
+}
+
+// UnmarshalNode is like Unmarshal but it takes a ValueNode instead of SC-encoded data.
+func (td *TypeDecoder) UnmarshalNode(n scparse.ValueNode, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return &InvalidUnmarshalError{reflect.TypeOf(v)}
+	}
+	if rv.Type().Elem() != td.typ {
+		return fmt.Errorf("sc: TypeDecoder for %v cannot unmarshal into %v", td.typ, rv.Type())
+	}
+	d := td.d
+	return d.run(n, rv.Elem(), td.dec)
+}
+
+// decodeFunc decodes the node n into v.
+type decodeFunc func(d *decoder, n scparse.ValueNode, v reflect.Value) error
+
+var decodeFuncCache sync.Map // map[fieldCacheKey]decodeFunc
+
+// compiledDecodeFunc returns the decode function for t, compiling it if necessary.
+// tags determines the fields of structs.
+func compiledDecodeFunc(t reflect.Type, tags tagConfig) decodeFunc {
+	if f, ok := decodeFuncCache.Load(fieldCacheKey{t, tags}); ok {
+		return f.(decodeFunc)
+	}
+	c := compiler{tags: tags, inProgress: make(map[reflect.Type]*decodeFunc)}
+	f, _ := decodeFuncCache.LoadOrStore(fieldCacheKey{t, tags}, c.compile(t))
+	return f.(decodeFunc)
+}
+
+// compiler builds decode functions for a type and the types it contains.
+type compiler struct {
+	tags tagConfig
+	// inProgress holds the functions for the types currently being compiled.
+	// It is used to handle recursive types.
+	inProgress map[reflect.Type]*decodeFunc
+}
+
+func (c *compiler) compile(t reflect.Type) decodeFunc {
+	if f, ok := decodeFuncCache.Load(fieldCacheKey{t, c.tags}); ok {
+		return f.(decodeFunc)
+	}
+	if fp, ok := c.inProgress[t]; ok {
+		// Recursive type, fp will be set by the time this is called.
+		return func(d *decoder, n scparse.ValueNode, v reflect.Value) error {
+			return (*fp)(d, n, v)
+		}
+	}
+	fp := new(decodeFunc)
+	c.inProgress[t] = fp
+	*fp = c.compileType(t)
+	return *fp
+}
+
+// compileType builds the decode function for t. Each function handles the common
+// case directly and falls back to decodeValue for everything else, such as
+// variables, null values and type errors, so that the result is the same as Unmarshal.
+func (c *compiler) compileType(t reflect.Type) decodeFunc {
+	if !isPlainType(t) {
+		return (*decoder).decodeValue
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return func(d *decoder, n scparse.ValueNode, v reflect.Value) error {
+			if n, ok := n.(*scparse.BoolNode); ok {
+				v.SetBool(n.True)
+				return nil
+			}
+			return d.decodeValue(n, v)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return func(d *decoder, n scparse.ValueNode, v reflect.Value) error {
+			if n, ok := n.(*scparse.NumberNode); ok && n.IsInt && !v.OverflowInt(n.Int64) {
+				v.SetInt(n.Int64)
+				return nil
+			}
+			return d.decodeValue(n, v)
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return func(d *decoder, n scparse.ValueNode, v reflect.Value) error {
+			if n, ok := n.(*scparse.NumberNode); ok && n.IsUint && !v.OverflowUint(n.Uint64) {
+				v.SetUint(n.Uint64)
+				return nil
+			}
+			return d.decodeValue(n, v)
+		}
+	case reflect.Float32, reflect.Float64:
+		return func(d *decoder, n scparse.ValueNode, v reflect.Value) error {
+			if n, ok := n.(*scparse.NumberNode); ok && n.IsFloat && !v.OverflowFloat(n.Float64) {
+				v.SetFloat(n.Float64)
+				return nil
+			}
+			return d.decodeValue(n, v)
+		}
+	case reflect.String:
+		return decodeStringFast
+	case reflect.Ptr:
+		return c.compilePtr(t)
+	case reflect.Slice:
+		// []byte is base64 encoded, leave it to the general decoder.
+		if t.Elem().Kind() == reflect.Uint8 {
+			return (*decoder).decodeValue
+		}
+		return c.compileSlice(t)
+	case reflect.Map:
+		// Keys implementing encoding.TextUnmarshaler are left to the general decoder.
+		if t.Key().Kind() != reflect.String || reflect.PtrTo(t.Key()).Implements(textUnmarshalerType) {
+			return (*decoder).decodeValue
+		}
+		return c.compileMap(t)
+	case reflect.Struct:
+		return c.compileStruct(t)
+	}
+	return (*decoder).decodeValue
+}
+
+// isPlainType reports whether values of type t are decoded based only on their kind.
+// Types with custom or registered unmarshaling, validation, Dict, and the scparse node types are not plain.
+func isPlainType(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr && t.Name() != "" {
+		return false
+	}
+	if t.Kind() == reflect.Interface || t.PkgPath() == valueNodeType.PkgPath() || t == dictType {
+		return false
+	}
+	if registeredUnmarshal(t) != nil {
+		return false
+	}
+	for _, t := range [...]reflect.Type{t, reflect.PtrTo(t)} {
+		if t.Implements(unmarshalerType) || t.Implements(textUnmarshalerType) || t.Implements(validatorType) {
+			return false
+		}
+	}
+	return true
+}
+
+func decodeStringFast(d *decoder, n scparse.ValueNode, v reflect.Value) error {
+	switch n := n.(type) {
+	case *scparse.RawStringNode:
+		v.SetString(n.Value)
+		return nil
+	case *scparse.MultilineStringNode:
+		v.SetString(n.Value)
+		return nil
+	case *scparse.InterpolatedStringNode:
+		switch len(n.Components) {
+		case 0:
+			v.SetString("")
+			return nil
+		case 1:
+			// Strings without variables don't need to be interpolated.
+			if s, ok := n.Components[0].(*scparse.StringNode); ok {
+				v.SetString(s.Value)
+				return nil
+			}
+		}
+	}
+	return d.decodeValue(n, v)
+}
+
+func (c *compiler) compilePtr(t reflect.Type) decodeFunc {
+	elemType := t.Elem()
+	decodeElem := c.compile(elemType)
+	return func(d *decoder, n scparse.ValueNode, v reflect.Value) error {
+		switch n.(type) {
+		case *scparse.NullNode, *scparse.VariableNode, *scparse.ExpressionNode:
+			// These may set the pointer to nil.
+			return d.decodeValue(n, v)
+		}
+		if v.IsNil() {
+			v.Set(reflect.New(elemType))
+		}
+		return decodeElem(d, n, v.Elem())
+	}
+}
+
+func (c *compiler) compileSlice(t reflect.Type) decodeFunc {
+	decodeElem := c.compile(t.Elem())
+	return func(d *decoder, n scparse.ValueNode, v reflect.Value) error {
+		ln, ok := n.(*scparse.ListNode)
+		if !ok {
+			return d.decodeValue(n, v)
+		}
+		for i, e := range ln.Elements {
+			// Grow slice if necessary
+			if i >= v.Cap() {
+				newcap := v.Cap() + v.Cap()/2
+				if newcap < 4 {
+					newcap = 4
+				}
+				newv := reflect.MakeSlice(t, v.Len(), newcap)
+				reflect.Copy(newv, v)
+				v.Set(newv)
+			}
+			if i >= v.Len() {
+				v.SetLen(i + 1)
+			}
+			if d.errorsFull() {
+				continue
+			}
+			if err := decodeElem(d, e, v.Index(i)); err != nil {
+				return err
+			}
+		}
+
+		count := len(ln.Elements)
+		if count < v.Len() {
+			v.SetLen(count)
+		}
+		// Handle empty slice
+		if count == 0 {
+			v.Set(reflect.MakeSlice(t, 0, 0))
+		}
+		return nil
+	}
+}
+
+func (c *compiler) compileMap(t reflect.Type) decodeFunc {
+	keyType := t.Key()
+	elemType := t.Elem()
+	decodeElem := c.compile(elemType)
+	return func(d *decoder, n scparse.ValueNode, v reflect.Value) error {
+		dn, ok := n.(*scparse.DictionaryNode)
+		if !ok {
+			return d.decodeValue(n, v)
+		}
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(t))
+		}
+		if d.duplicateKeys == scparse.DuplicateKeysError {
+			d.checkDuplicateKeys(dn)
+		}
+
+		var mapElem reflect.Value
+		for _, mn := range dn.Members {
+			if !mapElem.IsValid() {
+				mapElem = reflect.New(elemType).Elem()
+			} else {
+				mapElem.Set(reflect.Zero(elemType))
+			}
+			if !d.errorsFull() {
+				if err := decodeElem(d, mn.Value, mapElem); err != nil {
+					return err
+				}
+			}
+			kv := reflect.ValueOf(mn.Key.KeyString()).Convert(keyType)
+			v.SetMapIndex(kv, mapElem)
+		}
+		return nil
+	}
+}
+
+func (c *compiler) compileStruct(t reflect.Type) decodeFunc {
+	fields := cachedTypeFields(t, c.tags)
+	if fields.remain != nil || fields.sourceInfo != nil {
+		// Unknown fields need to be collected and positions need to be recorded,
+		// leave it to the general decoder.
+		return (*decoder).decodeValue
+	}
+
+	decodeFields := make([]decodeFunc, len(fields.list))
+	for i, f := range fields.list {
+		ft := t
+		for _, i := range f.index {
+			// Embedded pointers need to be allocated while decoding,
+			// leave those structs to the general decoder.
+			if ft.Kind() == reflect.Ptr {
+				return (*decoder).decodeValue
+			}
+			ft = ft.Field(i).Type
+		}
+		if f.quoted {
+			decodeFields[i] = (*decoder).decodeQuoted
+		} else if layout := f.timeFormat; layout != "" {
+			decodeFields[i] = func(d *decoder, n scparse.ValueNode, v reflect.Value) error {
+				if d.decodeTime(n, v, layout) {
+					return nil
+				}
+				return d.decodeValue(n, v)
+			}
+		} else if enc := f.bytes; enc != bytesBase64 {
+			decodeFields[i] = func(d *decoder, n scparse.ValueNode, v reflect.Value) error {
+				if d.decodeBytes(n, v, enc) {
+					return nil
+				}
+				return d.decodeValue(n, v)
+			}
+		} else {
+			// f.typ is dereferenced for pointer fields so use the actual field type
+			decodeFields[i] = c.compile(ft)
+		}
+	}
+
+	return func(d *decoder, n scparse.ValueNode, v reflect.Value) error {
+		dn, ok := n.(*scparse.DictionaryNode)
+		if !ok {
+			return d.decodeValue(n, v)
+		}
+		var seen []bool
+		if fields.hasRequired {
+			seen = make([]bool, len(fields.list))
+		}
+		if d.duplicateKeys == scparse.DuplicateKeysError {
+			d.checkDuplicateKeys(dn)
+		}
+
+		origErrorContext := d.errorContext
+		for _, mn := range dn.Members {
+			key := mn.Key.KeyString()
+			fi, ok := fields.nameIndex[key]
+			if !ok {
+				fi = -1
+				for i := range fields.list {
+					if strings.EqualFold(fields.list[i].name, key) {
+						fi = i
+						break
+					}
+				}
+			}
+			if fi < 0 {
+				if d.disallowUnknownFields {
+					d.saveError(&UnmarshalUnknownFieldError{Key: key, Struct: t.Name(), Pos: mn.Key.Position()})
+				}
+				continue
+			}
+			if seen != nil {
+				seen[fi] = true
+			}
+			if d.errorsFull() {
+				continue
+			}
+
+			f := &fields.list[fi]
+			d.errorContext.FieldStack = append(d.errorContext.FieldStack, f.name)
+			d.errorContext.Struct = t
+			if err := decodeFields[fi](d, mn.Value, v.FieldByIndex(f.index)); err != nil {
+				return err
+			}
+			d.errorContext.FieldStack = d.errorContext.FieldStack[:len(origErrorContext.FieldStack)]
+			d.errorContext.Struct = origErrorContext.Struct
+		}
+
+		d.checkRequiredFields(dn, t, fields, seen)
+		return nil
+	}
+}
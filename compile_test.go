@@ -0,0 +1,238 @@
+// Copyright (c) 2021 the SC authors. All rights reserved. MIT License.
+
+package sc_test
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sc-lang/go-sc"
+	"github.com/sc-lang/go-sc/scparse"
+)
+
+type treeNode struct {
+	Name     string      `sc:"name"`
+	Children []*treeNode `sc:"children"`
+}
+
+func TestTypeDecoderRecursiveType(t *testing.T) {
+	td, err := sc.CompileType(reflect.TypeOf(treeNode{}))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	input := `{
+		name: "root"
+		children: [
+			{ name: "a", children: [{ name: "b" }] }
+			{ name: "c", children: null }
+		]
+	}`
+	var got treeNode
+	if err := td.Unmarshal([]byte(input), &got); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	want := treeNode{
+		Name: "root",
+		Children: []*treeNode{
+			{Name: "a", Children: []*treeNode{{Name: "b"}}},
+			{Name: "c"},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestTypeDecoderErrors(t *testing.T) {
+	type Server struct {
+		Host string `sc:"host,required"`
+		Port int    `sc:"port"`
+	}
+	type Config struct {
+		Name    string            `sc:"name"`
+		Servers []Server          `sc:"servers"`
+		Labels  map[string]string `sc:"labels"`
+	}
+	tests := []struct {
+		name  string
+		input string
+		opts  []sc.UnmarshalOption
+	}{
+		{"type errors", `{ name: 1, servers: [{ host: "a", port: "80" }], labels: { a: true } }`, nil},
+		{"missing field", `{ servers: [{ port: 80 }] }`, nil},
+		{"unknown field", `{ name: "a", nope: 1 }`, []sc.UnmarshalOption{sc.WithDisallowUnknownFields(true)}},
+		{"duplicate keys", `{ name: "a", name: "b" }`, []sc.UnmarshalOption{sc.WithDisallowDuplicateKeys(true)}},
+		{"max errors", `{ name: 1, servers: [{ port: "a" }, { port: "b" }] }`, []sc.UnmarshalOption{sc.WithMaxErrors(2)}},
+		{"unknown variable", `{ name: ${name} }`, []sc.UnmarshalOption{sc.WithDisallowUnknownVariables(true)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var want Config
+			wantErr := sc.Unmarshal([]byte(tt.input), &want, tt.opts...)
+			if wantErr == nil {
+				t.Fatal("want error from Unmarshal, got nil")
+			}
+
+			td, err := sc.CompileType(reflect.TypeOf(Config{}), tt.opts...)
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			var got Config
+			err = td.Unmarshal([]byte(tt.input), &got)
+			if !reflect.DeepEqual(err, wantErr) {
+				t.Errorf("got error\n\t%v\nwant\n\t%v", err, wantErr)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("got value %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestTypeDecoderInvalidArgs(t *testing.T) {
+	if _, err := sc.CompileType(nil); err == nil {
+		t.Error("want error for nil type, got nil")
+	}
+	if _, err := sc.CompileType(reflect.TypeOf(0), sc.WithVariablesMap(1)); err == nil {
+		t.Error("want error for invalid option, got nil")
+	}
+
+	type Config struct {
+		A int `sc:"a"`
+	}
+	td, err := sc.CompileType(reflect.TypeOf(Config{}))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	var c Config
+	var i int
+	tests := []struct {
+		name string
+		v    interface{}
+	}{
+		{"nil", nil},
+		{"non-pointer", c},
+		{"nil pointer", (*Config)(nil)},
+		{"wrong type", &i},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := td.Unmarshal([]byte(`{ a: 1 }`), tt.v); err == nil {
+				t.Error("want error, got nil")
+			}
+		})
+	}
+
+	var ive *sc.InvalidUnmarshalError
+	if err := td.Unmarshal([]byte(`{ a: 1 }`), c); !errors.As(err, &ive) {
+		t.Errorf("got error %v, want InvalidUnmarshalError", err)
+	}
+	if err := td.Unmarshal([]byte(`{ a: 1 }`), &c); err != nil || c.A != 1 {
+		t.Errorf("got %+v, %v, want A = 1, nil", c, err)
+	}
+}
+
+// Benchmarks
+
+type benchConfig struct {
+	Name     string            `sc:"name"`
+	Version  int               `sc:"version"`
+	Debug    bool              `sc:"debug"`
+	Ratio    float64           `sc:"ratio"`
+	Tags     []string          `sc:"tags"`
+	Labels   map[string]string `sc:"labels"`
+	Services []benchService    `sc:"services"`
+}
+
+type benchService struct {
+	Name     string   `sc:"name"`
+	Image    string   `sc:"image"`
+	Port     int      `sc:"port"`
+	Replicas *int     `sc:"replicas"`
+	Env      []string `sc:"env"`
+}
+
+func benchInput() []byte {
+	var sb strings.Builder
+	sb.WriteString(`{
+		name: "bench"
+		version: 3
+		debug: false
+		ratio: 0.75
+		tags: ["a", "b", "c", "d"]
+		labels: { team: "core", tier: "backend", region: "eu" }
+		services: [
+	`)
+	for i := 0; i < 20; i++ {
+		fmt.Fprintf(&sb, `{ name: "svc-%d", image: "registry/svc:%d", port: %d, replicas: 3, env: ["A=1", "B=2"] }
+`, i, i, 8000+i)
+	}
+	sb.WriteString("]\n}")
+	return []byte(sb.String())
+}
+
+func BenchmarkUnmarshalNode(b *testing.B) {
+	n, err := scparse.Parse(benchInput())
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var c benchConfig
+		if err := sc.UnmarshalNode(n, &c); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkTypeDecoderUnmarshalNode(b *testing.B) {
+	n, err := scparse.Parse(benchInput())
+	if err != nil {
+		b.Fatal(err)
+	}
+	td, err := sc.CompileType(reflect.TypeOf(benchConfig{}))
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var c benchConfig
+		if err := td.UnmarshalNode(n, &c); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkUnmarshal(b *testing.B) {
+	data := benchInput()
+	b.ReportAllocs()
+	b.SetBytes(int64(len(data)))
+	for i := 0; i < b.N; i++ {
+		var c benchConfig
+		if err := sc.Unmarshal(data, &c); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkTypeDecoderUnmarshal(b *testing.B) {
+	data := benchInput()
+	td, err := sc.CompileType(reflect.TypeOf(benchConfig{}))
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var c benchConfig
+		if err := td.Unmarshal(data, &c); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -4,10 +4,13 @@ package sc
 
 import (
 	"encoding"
-	"encoding/base64"
 	"fmt"
 	"reflect"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/sc-lang/go-sc/scparse"
 )
@@ -16,6 +19,9 @@ var (
 	nodeType            = reflect.TypeOf((*scparse.Node)(nil)).Elem()
 	valueNodeType       = reflect.TypeOf((*scparse.ValueNode)(nil)).Elem()
 	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	validatorType       = reflect.TypeOf((*Validator)(nil)).Elem()
+	durationType        = reflect.TypeOf(time.Duration(0))
+	timeType            = reflect.TypeOf(time.Time{})
 )
 
 // A large amount of the reflection code in this file is adapted from
@@ -36,47 +42,265 @@ type decoder struct {
 	}
 	errors                Errors
 	vars                  Variables
+	documentVars          string
+	parseOpts             []scparse.ParseOption
 	disallowUnknownFields bool
 	disallowUnknownVars   bool
+	requiredVars          map[string]bool
+	disallowNull          bool
+	duplicateKeys         scparse.DuplicateKeyPolicy
+	tags                  tagConfig
+	strictVarTypes        bool
+	keepUnknownVarText    bool
+	weakTypes             bool
+	durationStrings       bool
+	timeFormat            string
+	varFormatter          func(name string, v interface{}) (string, error)
+	funcs                 map[string]Func
+	hooks                 []DecodeHook
+	path                  string
+	maxErrors             int
+	sortErrors            bool
+	orderedDicts          bool // store dictionaries in empty interfaces as *Dict
+	report                *UnmarshalReport
+	// referencedVars holds the variables looked up while decoding, by name,
+	// and whether they had a value. It is only tracked if report is set.
+	referencedVars map[string]bool
+	optErr         error  // error from an invalid option
+	scratch        []byte // reusable buffer for building strings
+}
+
+// maxPooledScratch is the largest scratch buffer kept when a decoder is reused.
+// This prevents a single large document from pinning memory.
+const maxPooledScratch = 64 << 10
+
+var decoderPool = sync.Pool{
+	New: func() interface{} { return new(decoder) },
+}
+
+// newDecoder returns a decoder from the pool. Call release once it is no longer needed.
+func newDecoder() *decoder {
+	return decoderPool.Get().(*decoder)
+}
+
+// release returns d to the pool so its buffers can be reused by a later decode.
+// d must not be used after calling release.
+func (d *decoder) release() {
+	d.reset()
+	decoderPool.Put(d)
+}
+
+// reset clears the options and state of d but keeps its buffers.
+func (d *decoder) reset() {
+	fieldStack, scratch := d.errorContext.FieldStack[:0], d.scratch[:0]
+	if cap(scratch) > maxPooledScratch {
+		scratch = nil
+	}
+	*d = decoder{}
+	d.errorContext.FieldStack = fieldStack
+	d.scratch = scratch
+}
+
+// applyOptions applies opts to d. It returns the first error
+// caused by an option that was given an invalid argument.
+func (d *decoder) applyOptions(opts []UnmarshalOption) error {
+	for _, opt := range opts {
+		opt(d)
+	}
+	return d.optErr
+}
+
+// optionError records err as the error caused by an invalid option.
+// Only the first error is kept.
+func (d *decoder) optionError(err error) {
+	if d.optErr == nil {
+		d.optErr = err
+	}
 }
 
 // saveError saves err by adding it to the list of errors.
 // It will add context to the error with information from d.errorContext.
+// If err is an Errors, for example returned by an Unmarshaler, each error is saved.
 func (d *decoder) saveError(err error) {
+	if errs, ok := err.(Errors); ok {
+		for _, err := range errs {
+			d.saveError(err)
+		}
+		return
+	}
+	if d.errorsFull() {
+		return
+	}
 	if d.errorContext.Struct != nil || len(d.errorContext.FieldStack) > 0 {
 		switch err := err.(type) {
 		case *UnmarshalTypeError:
 			err.Struct = d.errorContext.Struct.Name()
 			err.Field = strings.Join(d.errorContext.FieldStack, ".")
+		case *UnmarshalVariableTypeError:
+			err.Struct = d.errorContext.Struct.Name()
+			err.Field = strings.Join(d.errorContext.FieldStack, ".")
+		case *ValidationError:
+			err.Struct = d.errorContext.Struct.Name()
+			err.Field = strings.Join(d.errorContext.FieldStack, ".")
+		case *DecodeHookError:
+			err.Struct = d.errorContext.Struct.Name()
+			err.Field = strings.Join(d.errorContext.FieldStack, ".")
 		}
 	}
 	d.errors = append(d.errors, err)
 }
 
+// errorsFull reports whether the maximum number of errors has been collected.
+// If errors are sorted, all errors are collected and the limit is applied after sorting
+// so that the first errors by position are kept.
+func (d *decoder) errorsFull() bool {
+	return d.maxErrors > 0 && !d.sortErrors && len(d.errors) >= d.maxErrors
+}
+
 func (d *decoder) unmarshal(n scparse.ValueNode, v interface{}) error {
 	rv := reflect.ValueOf(v)
 	// v must be a pointer and not nil
 	if rv.Kind() != reflect.Ptr || rv.IsNil() {
 		return &InvalidUnmarshalError{reflect.TypeOf(v)}
 	}
-
 	// Decode rv not rv.Elem because the Unmarshaler interface test
 	// must be applied at the top level of the value.
-	err := d.decodeValue(n, rv)
+	return d.run(n, rv, (*decoder).decodeValue)
+}
+
+// run decodes n into v using decode and returns the collected errors.
+// If a decode path is set, the node at the path is decoded instead of n.
+func (d *decoder) run(n scparse.ValueNode, v reflect.Value, decode decodeFunc) error {
+	// Duplicate keys are reported while decoding so that all of them are found,
+	// other policies are applied up front so the rest of decoding is unaffected.
+	if dn, ok := n.(*scparse.DictionaryNode); ok && d.duplicateKeys != scparse.DuplicateKeysLastWins && d.duplicateKeys != scparse.DuplicateKeysError {
+		var err error
+		if n, err = scparse.ResolveDuplicateKeys(dn, d.duplicateKeys); err != nil {
+			return err
+		}
+	}
+	// Aliases are replaced by the values they reference so that decoding does not need to handle them
+	n, err := scparse.ExpandAnchors(n)
 	if err != nil {
+		return err
+	}
+	if dn, ok := n.(*scparse.DictionaryNode); ok && d.documentVars != "" {
+		// Document variables only apply to this document
+		defer func(vars Variables) { d.vars = vars }(d.vars)
+		n = d.extractDocumentVariables(dn)
+	}
+	if d.report != nil {
+		// Runs before the document variables are removed so they are included
+		d.referencedVars = make(map[string]bool)
+		defer d.fillReport()
+	}
+	if d.path != "" {
+		elems, err := parsePath(d.path)
+		if err != nil {
+			return err
+		}
+		n, err = lookupNode(n, elems)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := decode(d, n, v); err != nil {
 		d.saveError(err)
 	}
 	if len(d.errors) > 0 {
+		if d.sortErrors {
+			sortErrors(d.errors)
+			if d.maxErrors > 0 && len(d.errors) > d.maxErrors {
+				d.errors = d.errors[:d.maxErrors]
+			}
+		}
 		return d.errors
 	}
 	return nil
 }
 
+// extractDocumentVariables adds the variables defined in the document variables member
+// of n to d.vars. It returns a copy of n without the member.
+func (d *decoder) extractDocumentVariables(n *scparse.DictionaryNode) *scparse.DictionaryNode {
+	var docVars *scparse.MemberNode
+	members := make([]*scparse.MemberNode, 0, len(n.Members))
+	for _, mn := range n.Members {
+		if mn.Key.KeyString() == d.documentVars {
+			docVars = mn
+			continue
+		}
+		members = append(members, mn)
+	}
+	if docVars == nil {
+		return n
+	}
+	dn, ok := docVars.Value.(*scparse.DictionaryNode)
+	if !ok {
+		d.saveError(newUnmarshalTypeError(docVars.Value, reflect.TypeOf(map[string]interface{}{})))
+	} else {
+		vars := Variables{v: reflect.ValueOf(d.dictionaryInterface(dn)), kt: reflect.TypeOf("")}
+		d.vars = MergeVariables(vars, d.vars)
+	}
+	c := *n
+	c.Members = members
+	return &c
+}
+
+// fillReport sets d.report based on the variables referenced while decoding.
+func (d *decoder) fillReport() {
+	r := UnmarshalReport{}
+	usedNames := make(map[string]bool)
+	for name, found := range d.referencedVars {
+		if found {
+			r.UsedVariables = append(r.UsedVariables, name)
+		} else {
+			r.UnknownVariables = append(r.UnknownVariables, name)
+		}
+		usedNames[strings.SplitN(name, ".", 2)[0]] = true
+	}
+	for _, name := range d.vars.names() {
+		if !usedNames[name] {
+			r.UnusedVariables = append(r.UnusedVariables, name)
+			usedNames[name] = true // only report each name once
+		}
+	}
+	sort.Strings(r.UsedVariables)
+	sort.Strings(r.UnknownVariables)
+	sort.Strings(r.UnusedVariables)
+	*d.report = r
+}
+
+// sortErrors sorts errs by their position in the SC input.
+// Errors without a position are placed last.
+func sortErrors(errs Errors) {
+	key := func(err error) int {
+		pos := errorPos(err)
+		if pos == (scparse.Pos{}) {
+			return int(^uint(0) >> 1) // max int
+		}
+		return pos.Byte
+	}
+	sort.SliceStable(errs, func(i, j int) bool {
+		return key(errs[i]) < key(errs[j])
+	})
+}
+
 func (d *decoder) decodeValue(n scparse.ValueNode, v reflect.Value) error {
 	// If v can't be set just ignore it
 	if !v.IsValid() {
 		return nil
 	}
+	// Stop decoding once the error limit has been reached
+	if d.errorsFull() {
+		return nil
+	}
+	if (len(d.hooks) > 0 || len(registeredTypes()) > 0) && d.runHooks(n, v) {
+		return nil
+	}
+	if d.timeFormat != "" && d.decodeTime(n, v, d.timeFormat) {
+		return nil
+	}
 
 	switch n := n.(type) {
 	case *scparse.NullNode:
@@ -88,11 +312,22 @@ func (d *decoder) decodeValue(n scparse.ValueNode, v reflect.Value) error {
 	case *scparse.InterpolatedStringNode:
 		return d.decodeInterpolatedString(n, v)
 	case *scparse.RawStringNode:
-		return d.decodeRawString(n, v)
+		return d.decodeRawString(n, n.Value, v)
+	case *scparse.MultilineStringNode:
+		return d.decodeRawString(n, n.Value, v)
 	case *scparse.VariableNode:
 		return d.decodeVariable(n, v)
+	case *scparse.ExpressionNode:
+		return d.decodeExpression(n, v)
 	case *scparse.DictionaryNode:
-		return d.decodeDictionary(n, v)
+		nerrs := len(d.errors)
+		if err := d.decodeDictionary(n, v); err != nil {
+			return err
+		}
+		if len(d.errors) == nerrs {
+			d.validate(n, v)
+		}
+		return nil
 	case *scparse.ListNode:
 		return d.decodeList(n, v)
 	default:
@@ -100,6 +335,129 @@ func (d *decoder) decodeValue(n scparse.ValueNode, v reflect.Value) error {
 	}
 }
 
+// runHooks consults the decode hooks and the unmarshal functions registered with
+// RegisterType for n and v. It reports whether n was handled, in which case the result
+// has been stored in v. If v is a pointer that is not handled, the type it points to
+// is tried next and the pointer is allocated if needed.
+func (d *decoder) runHooks(n scparse.ValueNode, v reflect.Value) bool {
+	for t := v.Type(); ; t = t.Elem() {
+		for _, hook := range d.hooks {
+			val, ok, err := hook(n, t)
+			if ok || err != nil {
+				d.setHookResult(n, v, t, val, err)
+				return true
+			}
+		}
+		if unmarshal := registeredUnmarshal(t); unmarshal != nil {
+			val, err := unmarshal(n, d.vars)
+			d.setHookResult(n, v, t, val, err)
+			return true
+		}
+		if _, ok := n.(*scparse.NullNode); ok || t.Kind() != reflect.Ptr {
+			return false
+		}
+	}
+}
+
+// setHookResult stores the result of decoding n into a value of type t in v.
+// t is either the type of v or a type v points to, pointers are allocated as needed.
+func (d *decoder) setHookResult(n scparse.ValueNode, v reflect.Value, t reflect.Type, val interface{}, err error) {
+	for v.Type() != t {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+	if err == nil {
+		if v.CanSet() {
+			err = setHookValue(v, val)
+		} else {
+			err = setTopLevelHookValue(v, val)
+		}
+	}
+	if err != nil {
+		d.saveError(&DecodeHookError{Type: t, Err: err, Pos: n.Position()})
+	}
+}
+
+// setHookValue sets v to the value returned by a decode hook.
+// Values are only converted between types of the same kind or between number types,
+// ex: an int can be converted to a time.Duration but not to a string.
+func setHookValue(v reflect.Value, val interface{}) error {
+	if val == nil {
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+	rv := reflect.ValueOf(val)
+	switch {
+	case rv.Type().AssignableTo(v.Type()):
+		v.Set(rv)
+	case rv.Type().ConvertibleTo(v.Type()) && (rv.Kind() == v.Kind() || isNumberKind(rv.Kind()) && isNumberKind(v.Kind())):
+		v.Set(rv.Convert(v.Type()))
+	default:
+		return fmt.Errorf("decode hook returned value of type %s", rv.Type())
+	}
+	return nil
+}
+
+// setTopLevelHookValue is like setHookValue but for the pointer passed to Unmarshal,
+// which cannot be set. Instead, the value it points to is set to the value val points to.
+func setTopLevelHookValue(v reflect.Value, val interface{}) error {
+	p := reflect.New(v.Type()).Elem()
+	if err := setHookValue(p, val); err != nil {
+		return err
+	}
+	if p.IsNil() {
+		v.Elem().Set(reflect.Zero(v.Type().Elem()))
+	} else {
+		v.Elem().Set(p.Elem())
+	}
+	return nil
+}
+
+// isNumberKind reports whether k is the kind of an integer or float type.
+func isNumberKind(k reflect.Kind) bool {
+	switch k {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// validate calls the ValidateSC method of v, or the value v points to,
+// if it implements Validator. n is the dictionary v was decoded from.
+func (d *decoder) validate(n *scparse.DictionaryNode, v reflect.Value) {
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+	if v.CanAddr() {
+		v = v.Addr()
+	}
+	if !v.Type().Implements(validatorType) || !v.CanInterface() {
+		return
+	}
+	err := v.Interface().(Validator).ValidateSC()
+	if err == nil {
+		return
+	}
+	t := v.Type()
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	errs, ok := err.(Errors)
+	if !ok {
+		errs = Errors{err}
+	}
+	for _, err := range errs {
+		d.saveError(&ValidationError{Type: t, Err: err, Pos: n.Pos})
+	}
+}
+
 func (d *decoder) decodeNull(n *scparse.NullNode, v reflect.Value) error {
 	// Check for unmarshaler.
 	u, ut, pv := indirect(v, true)
@@ -121,11 +479,29 @@ func (d *decoder) decodeNull(n *scparse.NullNode, v reflect.Value) error {
 		fallthrough // Handle nil assignment below
 	case reflect.Ptr, reflect.Map, reflect.Slice:
 		v.Set(reflect.Zero(v.Type()))
+	case reflect.String:
+		if d.disallowNull {
+			d.saveError(newUnmarshalTypeError(n, v.Type()))
+			break
+		}
+		if d.weakTypes {
+			v.SetString("")
+		}
 	case reflect.Struct:
 		if v.Type() == reflect.TypeOf((*scparse.NullNode)(nil)).Elem() {
 			v.Set(reflect.ValueOf(n).Elem())
 			break
 		}
+		if v.Type() == dictType {
+			// Treat Dict the same as a map
+			v.Set(reflect.Zero(dictType))
+			break
+		}
+		fallthrough
+	default:
+		if d.disallowNull {
+			d.saveError(newUnmarshalTypeError(n, v.Type()))
+		}
 		// otherwise ignore null, the zero value will be used
 	}
 	return nil
@@ -146,6 +522,12 @@ func (d *decoder) decodeBool(n *scparse.BoolNode, v reflect.Value) error {
 	switch v.Kind() {
 	case reflect.Bool:
 		v.SetBool(n.True)
+	case reflect.String:
+		if !d.weakTypes {
+			d.saveError(newUnmarshalTypeError(n, v.Type()))
+			break
+		}
+		v.SetString(strconv.FormatBool(n.True))
 	case reflect.Interface:
 		if t := v.Type(); t == nodeType || t == valueNodeType {
 			v.Set(reflect.ValueOf(n))
@@ -218,6 +600,13 @@ func (d *decoder) decodeNumber(n *scparse.NumberNode, v reflect.Value) error {
 		}
 		v.SetFloat(n.Float64)
 
+	case reflect.String:
+		if !d.weakTypes {
+			d.saveError(newUnmarshalTypeError(n, v.Type()))
+			break
+		}
+		v.SetString(numberString(n))
+
 	case reflect.Struct:
 		if v.Type() == reflect.TypeOf((*scparse.NumberNode)(nil)).Elem() {
 			v.Set(reflect.ValueOf(n).Elem())
@@ -244,28 +633,15 @@ func (d *decoder) decodeInterpolatedString(n *scparse.InterpolatedStringNode, v
 	}
 
 	// Hold off on checking TextUnmarshaler because we need to expand variables first
-	var sb strings.Builder
-	for _, c := range n.Components {
-		switch c := c.(type) {
-		case *scparse.StringNode:
-			sb.WriteString(c.Value)
-		case *scparse.VariableNode:
-			// Lookup variable value
-			val, ok := d.vars.Lookup(c)
-			if !ok && d.disallowUnknownVars {
-				d.saveError(&UnmarshalUnknownVariableError{Variable: c.Identifier.Name, Pos: c.Pos})
-				return nil
-			}
-			if val == nil {
-				break // coerce to empty string
-			}
-			sb.WriteString(fmt.Sprint(val))
-		default:
-			panic(fmt.Errorf("impossible: invalid node type in InterpolatedString: %T", c))
-		}
+	s, ok := d.interpolate(n)
+	if !ok {
+		return nil
 	}
 	if ut != nil {
-		return ut.UnmarshalText([]byte(sb.String()))
+		return ut.UnmarshalText([]byte(s))
+	}
+	if d.decodeDuration(n, s, pv) {
+		return nil
 	}
 
 	v = pv
@@ -276,22 +652,28 @@ func (d *decoder) decodeInterpolatedString(n *scparse.InterpolatedStringNode, v
 			d.saveError(newUnmarshalTypeError(n, v.Type()))
 			break
 		}
-		src := []byte(sb.String())
-		b := make([]byte, base64.StdEncoding.DecodedLen(len(src)))
-		n, err := base64.StdEncoding.Decode(b, src)
+		b, err := decodeByteString(s, bytesBase64)
 		if err != nil {
 			d.saveError(err)
 			break
 		}
-		v.SetBytes(b[:n])
+		v.SetBytes(b)
 	case reflect.String:
-		v.SetString(sb.String())
+		v.SetString(s)
 	case reflect.Interface:
 		if v.NumMethod() == 0 {
-			v.Set(reflect.ValueOf(sb.String()))
+			v.Set(reflect.ValueOf(s))
 			break
 		}
 		d.saveError(newUnmarshalTypeError(n, v.Type()))
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		if !d.weakTypes {
+			d.saveError(newUnmarshalTypeError(n, v.Type()))
+			break
+		}
+		d.decodeParsedString(n, s, v)
 	case reflect.Struct:
 		if v.Type() == reflect.TypeOf((*scparse.RawStringNode)(nil)).Elem() {
 			v.Set(reflect.ValueOf(n).Elem())
@@ -304,14 +686,201 @@ func (d *decoder) decodeInterpolatedString(n *scparse.InterpolatedStringNode, v
 	return nil
 }
 
-func (d *decoder) decodeRawString(n *scparse.RawStringNode, v reflect.Value) error {
+// interpolate expands the variables in n and returns the resulting string.
+// If a variable is unknown and unknown variables are disallowed, an error
+// is saved and false is returned.
+func (d *decoder) interpolate(n *scparse.InterpolatedStringNode) (string, bool) {
+	// Strings without variables don't need to be copied.
+	if len(n.Components) == 1 {
+		if c, ok := n.Components[0].(*scparse.StringNode); ok {
+			return c.Value, true
+		}
+	}
+
+	// Build the string in the scratch buffer to avoid growing a new buffer for each string.
+	buf := d.scratch[:0]
+	defer func() { d.scratch = buf[:0] }()
+	for _, c := range n.Components {
+		switch c := c.(type) {
+		case *scparse.StringNode:
+			buf = append(buf, c.Value...)
+		case *scparse.VariableNode:
+			// Lookup variable value
+			val, ok, err := d.lookupVariableInterface(c)
+			if err != nil {
+				d.saveError(err)
+				return "", false
+			}
+			if !ok && c.Default != nil {
+				buf = append(buf, c.Default.Value...)
+				break
+			}
+			if !ok && d.disallowUnknown(c) {
+				d.saveError(&UnmarshalUnknownVariableError{Variable: c.Identifier.Name, Pos: c.Pos})
+				return "", false
+			}
+			if !ok && d.keepUnknownVarText {
+				buf = append(buf, c.String()...)
+				break
+			}
+			vs, err := d.formatVariable(c.Identifier.Name, val)
+			if err != nil {
+				d.saveError(err)
+				return "", false
+			}
+			buf = append(buf, vs...)
+		case *scparse.ExpressionNode:
+			val, ok, err := d.evalExpression(c)
+			if err != nil {
+				d.saveError(err)
+				return "", false
+			}
+			if !ok && d.keepUnknownVarText {
+				buf = append(buf, c.String()...)
+				break
+			}
+			vs, err := d.formatVariable(c.String(), val)
+			if err != nil {
+				d.saveError(err)
+				return "", false
+			}
+			buf = append(buf, vs...)
+		default:
+			panic(fmt.Errorf("impossible: invalid node type in InterpolatedString: %T", c))
+		}
+	}
+	return string(buf), true
+}
+
+// lookupVariable finds the value of the variable n. If n is a function call,
+// the function is called and its result is returned.
+// The invalid value is returned if the variable is unknown.
+func (d *decoder) lookupVariable(n *scparse.VariableNode) (reflect.Value, error) {
+	if !n.IsCall {
+		v := d.vars.lookup(n)
+		if d.referencedVars != nil {
+			d.referencedVars[n.Identifier.Name] = d.referencedVars[n.Identifier.Name] || v.IsValid()
+		}
+		return v, nil
+	}
+	f, ok := d.funcs[n.Identifier.Name]
+	if !ok {
+		return reflect.Value{}, &UnmarshalFuncError{Func: n.Identifier.Name, Pos: n.Pos}
+	}
+	args := make([]interface{}, len(n.Args))
+	for i, a := range n.Args {
+		switch a := a.(type) {
+		case *scparse.StringNode:
+			args[i] = a.Value
+		case *scparse.VariableNode:
+			val, ok, err := d.lookupVariableInterface(a)
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			if !ok && d.disallowUnknown(a) {
+				return reflect.Value{}, &UnmarshalUnknownVariableError{Variable: a.Identifier.Name, Pos: a.Pos}
+			}
+			args[i] = val
+		default:
+			panic(fmt.Errorf("impossible: invalid node type in function arguments: %T", a))
+		}
+	}
+	res, err := f(args...)
+	if err != nil {
+		return reflect.Value{}, &UnmarshalFuncError{Func: n.Identifier.Name, Err: err, Pos: n.Pos}
+	}
+	if res == nil && n.Default != nil {
+		// Use the default value
+		return reflect.Value{}, nil
+	}
+	// Use an interface value so that a nil result is still a valid value
+	return reflect.ValueOf(&res).Elem(), nil
+}
+
+// disallowUnknown reports whether the variable n is an error if it is unknown.
+func (d *decoder) disallowUnknown(n *scparse.VariableNode) bool {
+	return d.disallowUnknownVars || d.requiredVars[n.Identifier.Name]
+}
+
+// lookupVariableInterface is like lookupVariable but returns the value as an interface{}.
+// The second return value reports whether the variable was found.
+func (d *decoder) lookupVariableInterface(n *scparse.VariableNode) (interface{}, bool, error) {
+	v, err := d.lookupVariable(n)
+	if err != nil || !v.IsValid() {
+		return nil, false, err
+	}
+	return v.Interface(), true, nil
+}
+
+// evalExpression evaluates the expression n using the variables and functions of d.
+// The second return value reports whether the result has a value.
+func (d *decoder) evalExpression(n *scparse.ExpressionNode) (interface{}, bool, error) {
+	// The result is the last operand evaluated, so if it has no value
+	// it must be the last variable without a value.
+	// If the expression has no value, the first required variable without a value is reported.
+	var unknown, required *scparse.VariableNode
+	val, ok, err := n.Eval(func(vn *scparse.VariableNode) (interface{}, bool, error) {
+		val, ok, err := d.lookupVariableInterface(vn)
+		if err == nil && !ok {
+			unknown = vn
+			if required == nil && d.requiredVars[vn.Identifier.Name] {
+				required = vn
+			}
+		}
+		return val, ok, err
+	})
+	if err == nil && !ok {
+		if required != nil {
+			unknown = required
+		}
+		if d.disallowUnknown(unknown) {
+			err = &UnmarshalUnknownVariableError{Variable: unknown.Identifier.Name, Pos: unknown.Pos}
+		}
+	}
+	return val, ok, err
+}
+
+// formatVariable converts the value of the variable name to a string so it
+// can be interpolated in a string. If a variable formatter was provided,
+// it will be used. Otherwise, the default formatting rules are used.
+func (d *decoder) formatVariable(name string, val interface{}) (string, error) {
+	if d.varFormatter != nil {
+		s, err := d.varFormatter(name, val)
+		if err != nil {
+			return "", fmt.Errorf("sc: cannot format variable %q: %w", name, err)
+		}
+		return s, nil
+	}
+	switch val := val.(type) {
+	case nil:
+		return "", nil // coerce to empty string
+	case string:
+		return val, nil
+	case []byte:
+		return string(val), nil
+	case encoding.TextMarshaler:
+		b, err := val.MarshalText()
+		if err != nil {
+			return "", fmt.Errorf("sc: cannot format variable %q: %w", name, err)
+		}
+		return string(b), nil
+	}
+	return fmt.Sprint(val), nil
+}
+
+// decodeRawString decodes the value s of n, which is a string that does not
+// need to be interpolated, i.e. a raw or multi-line string.
+func (d *decoder) decodeRawString(n scparse.ValueNode, s string, v reflect.Value) error {
 	// Check for unmarshaler.
 	u, ut, pv := indirect(v, false)
 	if u != nil {
 		return u.UnmarshalSC(n, d.vars)
 	}
 	if ut != nil {
-		return ut.UnmarshalText([]byte(n.Value))
+		return ut.UnmarshalText([]byte(s))
+	}
+	if d.decodeDuration(n, s, pv) {
+		return nil
 	}
 
 	v = pv
@@ -322,28 +891,34 @@ func (d *decoder) decodeRawString(n *scparse.RawStringNode, v reflect.Value) err
 			d.saveError(newUnmarshalTypeError(n, v.Type()))
 			break
 		}
-		src := []byte(n.Value)
-		b := make([]byte, base64.StdEncoding.DecodedLen(len(src)))
-		n, err := base64.StdEncoding.Decode(b, src)
+		b, err := decodeByteString(s, bytesBase64)
 		if err != nil {
 			d.saveError(err)
 			break
 		}
-		v.SetBytes(b[:n])
+		v.SetBytes(b)
 	case reflect.String:
-		v.SetString(n.Value)
+		v.SetString(s)
 	case reflect.Interface:
 		if t := v.Type(); t == nodeType || t == valueNodeType {
 			v.Set(reflect.ValueOf(n))
 			break
 		}
 		if v.NumMethod() == 0 {
-			v.Set(reflect.ValueOf(n.Value))
+			v.Set(reflect.ValueOf(s))
 			break
 		}
 		d.saveError(newUnmarshalTypeError(n, v.Type()))
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		if !d.weakTypes {
+			d.saveError(newUnmarshalTypeError(n, v.Type()))
+			break
+		}
+		d.decodeParsedString(n, s, v)
 	case reflect.Struct:
-		if v.Type() == reflect.TypeOf((*scparse.RawStringNode)(nil)).Elem() {
+		if v.Type() == reflect.TypeOf(n).Elem() {
 			v.Set(reflect.ValueOf(n).Elem())
 			break
 		}
@@ -376,29 +951,123 @@ func (d *decoder) decodeVariable(n *scparse.VariableNode, v reflect.Value) error
 	}
 
 	// Lookup variable value
-	val := d.vars.lookup(n)
+	val, err := d.lookupVariable(n)
+	if err != nil {
+		d.saveError(err)
+		return nil
+	}
 	if !val.IsValid() {
-		if d.disallowUnknownVars {
+		if n.Default != nil {
+			// Defaults are strings, they are parsed if v is a bool or number
+			return d.decodeQuoted(defaultString(n), v)
+		}
+		if d.disallowUnknown(n) {
 			d.saveError(&UnmarshalUnknownVariableError{Variable: n.Identifier.Name, Pos: n.Pos})
+			return nil
+		}
+		if d.keepUnknownVarText && pv.Kind() == reflect.String {
+			pv.SetString(n.String())
+			return nil
 		}
 		// Use the zero value of v
 		return nil
 	}
+	d.setVariable(n, n.Identifier.Name, val, pv)
+	return nil
+}
+
+func (d *decoder) decodeExpression(n *scparse.ExpressionNode, v reflect.Value) error {
+	// Check for unmarshaler.
+	u, ut, pv := indirect(v, false)
+	if u != nil {
+		return u.UnmarshalSC(n, d.vars)
+	}
+	if ut != nil {
+		d.saveError(newUnmarshalTypeError(n, v.Type()))
+		return nil
+	}
+
+	t := v.Type()
+	if t == nodeType || t == valueNodeType {
+		v.Set(reflect.ValueOf(n))
+		return nil
+	}
+	if t == reflect.TypeOf((*scparse.ExpressionNode)(nil)).Elem() {
+		v.Set(reflect.ValueOf(n).Elem())
+		return nil
+	}
+
+	val, ok, err := d.evalExpression(n)
+	if err != nil {
+		d.saveError(err)
+		return nil
+	}
+	if !ok {
+		if d.keepUnknownVarText && pv.Kind() == reflect.String {
+			pv.SetString(n.String())
+		}
+		// Use the zero value of v
+		return nil
+	}
+	if s, ok := val.(string); ok && (pv.Kind() == reflect.Bool || isNumberKind(pv.Kind())) {
+		// Strings are parsed the same as default values, ex: ${debug ?? "true"}
+		sn := &scparse.StringNode{Pos: n.Pos, Value: s}
+		return d.decodeQuoted(&scparse.InterpolatedStringNode{Pos: n.Pos, Components: []scparse.StringContentNode{sn}}, v)
+	}
+	d.setVariable(n, n.String(), reflect.ValueOf(&val).Elem(), pv)
+	return nil
+}
+
+// setVariable sets v to val, which is the value of the variable or expression n.
+func (d *decoder) setVariable(n scparse.ValueNode, name string, val, v reflect.Value) {
 	// Unwrap interface
 	if val.Kind() == reflect.Interface && !val.IsNil() {
 		val = val.Elem()
 	}
 
-	v = pv
+	t := v.Type()
 	switch valt := val.Type(); {
 	case valt.AssignableTo(t):
 		v.Set(val)
+	case val.Kind() == reflect.String && d.decodeDuration(n, val.String(), v):
+		// Parsed the same as a duration string literal
+	case d.weakTypes && d.setWeakVariable(n, val, v):
+		// Converted the same as a literal of the same type
+	case d.strictVarTypes:
+		d.saveError(&UnmarshalVariableTypeError{Variable: name, VariableType: valt, Type: t, Pos: n.Position()})
 	case valt.ConvertibleTo(t):
 		v.Set(val.Convert(t))
 	default:
 		d.saveError(newUnmarshalTypeError(n, t))
 	}
-	return nil
+}
+
+// setWeakVariable converts val, which is the value of the variable or expression n,
+// to the type of v the same way literals are converted when weak types are enabled.
+// It reports whether val was converted.
+func (d *decoder) setWeakVariable(n scparse.ValueNode, val, v reflect.Value) bool {
+	if v.Kind() != reflect.String {
+		return val.Kind() == reflect.String && d.decodeParsedString(n, val.String(), v)
+	}
+	switch val.Kind() {
+	case reflect.Interface:
+		// A nil value, which is the same as null
+		if d.disallowNull {
+			return false
+		}
+		v.SetString("")
+	case reflect.Bool:
+		v.SetString(strconv.FormatBool(val.Bool()))
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v.SetString(strconv.FormatInt(val.Int(), 10))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		v.SetString(strconv.FormatUint(val.Uint(), 10))
+	case reflect.Float32, reflect.Float64:
+		v.SetString(strconv.FormatFloat(val.Float(), 'g', -1, val.Type().Bits()))
+	default:
+		return false
+	}
+	return true
 }
 
 func (d *decoder) decodeDictionary(n *scparse.DictionaryNode, v reflect.Value) error {
@@ -428,8 +1097,13 @@ func (d *decoder) decodeDictionary(n *scparse.DictionaryNode, v reflect.Value) e
 		v.Set(reflect.ValueOf(n).Elem())
 		return nil
 	}
+	if t == dictType {
+		v.Set(reflect.ValueOf(d.orderedDictionary(n)).Elem())
+		return nil
+	}
 
 	var fields structFields
+	var seen []bool
 
 	// Check type of target:
 	//   struct or map[T1]T2 where T1 is a string or an encoding.TextUnmarshaler
@@ -448,12 +1122,20 @@ func (d *decoder) decodeDictionary(n *scparse.DictionaryNode, v reflect.Value) e
 			v.Set(reflect.MakeMap(t))
 		}
 	case reflect.Struct:
-		fields = cachedTypeFields(t)
+		fields = cachedTypeFields(t, d.tags)
+		if fields.hasRequired {
+			// Keep track of which fields were seen so missing required fields can be reported
+			seen = make([]bool, len(fields.list))
+		}
 	default:
 		d.saveError(newUnmarshalTypeError(n, t))
 		return nil
 	}
 
+	if d.duplicateKeys == scparse.DuplicateKeysError {
+		d.checkDuplicateKeys(n)
+	}
+
 	var mapElem reflect.Value
 	origErrorContext := d.errorContext
 
@@ -462,6 +1144,7 @@ func (d *decoder) decodeDictionary(n *scparse.DictionaryNode, v reflect.Value) e
 
 		// Figure out field corresponding to key.
 		var subv reflect.Value
+		var f *field
 
 		if v.Kind() == reflect.Map {
 			elemType := t.Elem()
@@ -472,52 +1155,52 @@ func (d *decoder) decodeDictionary(n *scparse.DictionaryNode, v reflect.Value) e
 			}
 			subv = mapElem
 		} else {
-			var f *field
-			if i, ok := fields.nameIndex[key]; ok {
+			fi, ok := fields.nameIndex[key]
+			if ok {
 				// Found an exact name match.
-				f = &fields.list[i]
+				f = &fields.list[fi]
 			} else {
 				// Fall back to the expensive case-insensitive linear serach.
 				for i := range fields.list {
 					ff := &fields.list[i]
 					if strings.EqualFold(ff.name, key) {
 						f = ff
+						fi = i
 						break
 					}
 				}
 			}
 			if f != nil {
-				subv = v
-				for _, i := range f.index {
-					if subv.Kind() == reflect.Ptr {
-						if subv.IsNil() {
-							// If a struct embeds a pointer to an unexported type,
-							// it is not possible to set a newly allocated value
-							// since the field is unexported.
-							//
-							// See https://golang.org/issue/21357
-							if !subv.CanSet() {
-								d.saveError(fmt.Errorf("sc: cannot set embedded pointer to unexported struct: %v", subv.Type().Elem()))
-								// Invalidate subv to ensure d.decodeValue(subv) skips over
-								// the SC value without assigning it to subv.
-								subv = reflect.Value{}
-								break
-							}
-							subv.Set(reflect.New(subv.Type().Elem()))
-						}
-						subv = subv.Elem()
-					}
-					subv = subv.Field(i)
+				if seen != nil {
+					seen[fi] = true
 				}
+				subv = d.fieldValue(v, f.index)
 				d.errorContext.FieldStack = append(d.errorContext.FieldStack, f.name)
 				d.errorContext.Struct = t
+			} else if fields.remain != nil {
+				// Keep the unknown field in the remain map
+				if err := d.decodeRemain(mn, v, fields.remain); err != nil {
+					return err
+				}
+				continue
 			} else if d.disallowUnknownFields {
-				d.saveError(fmt.Errorf("sc: unknown field %q", key))
+				d.saveError(&UnmarshalUnknownFieldError{Key: key, Struct: t.Name(), Pos: mn.Key.Position()})
 			}
 			// ignore unknown field
 		}
 
-		if err := d.decodeValue(mn.Value, subv); err != nil {
+		var err error
+		switch {
+		case f != nil && f.quoted:
+			err = d.decodeQuoted(mn.Value, subv)
+		case f != nil && f.timeFormat != "" && d.decodeTime(mn.Value, subv, f.timeFormat):
+			// Parsed using the layout from the format tag option
+		case f != nil && f.bytes != bytesBase64 && d.decodeBytes(mn.Value, subv, f.bytes):
+			// Decoded using the encoding from the tag options
+		default:
+			err = d.decodeValue(mn.Value, subv)
+		}
+		if err != nil {
 			return err
 		}
 
@@ -550,9 +1233,261 @@ func (d *decoder) decodeDictionary(n *scparse.DictionaryNode, v reflect.Value) e
 		d.errorContext.FieldStack = d.errorContext.FieldStack[:len(origErrorContext.FieldStack)]
 		d.errorContext.Struct = origErrorContext.Struct
 	}
+
+	d.checkRequiredFields(n, t, fields, seen)
+	if fields.sourceInfo != nil {
+		d.setSourceInfo(n, v, fields.sourceInfo)
+	}
+	return nil
+}
+
+// fieldValue returns the field of the struct v with the given index sequence.
+// Nil embedded pointers are allocated. If this is not possible, an error is saved
+// and the invalid value is returned so that decoding the field is skipped.
+func (d *decoder) fieldValue(v reflect.Value, index []int) reflect.Value {
+	for _, i := range index {
+		if v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				// If a struct embeds a pointer to an unexported type,
+				// it is not possible to set a newly allocated value
+				// since the field is unexported.
+				//
+				// See https://golang.org/issue/21357
+				if !v.CanSet() {
+					d.saveError(fmt.Errorf("sc: cannot set embedded pointer to unexported struct: %v", v.Type().Elem()))
+					return reflect.Value{}
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(i)
+	}
+	return v
+}
+
+// decodeRemain decodes the member mn, which does not match any field of the struct v,
+// into the map field f that has the remain option.
+func (d *decoder) decodeRemain(mn *scparse.MemberNode, v reflect.Value, f *field) error {
+	mv := d.fieldValue(v, f.index)
+	if !mv.IsValid() {
+		return nil
+	}
+	if mv.IsNil() {
+		mv.Set(reflect.MakeMap(f.typ))
+	}
+	elem := reflect.New(f.typ.Elem()).Elem()
+	origErrorContext := d.errorContext
+	d.errorContext.FieldStack = append(d.errorContext.FieldStack, f.name)
+	d.errorContext.Struct = v.Type()
+	err := d.decodeValue(mn.Value, elem)
+	d.errorContext.FieldStack = d.errorContext.FieldStack[:len(origErrorContext.FieldStack)]
+	d.errorContext.Struct = origErrorContext.Struct
+	if err != nil {
+		return err
+	}
+	mv.SetMapIndex(reflect.ValueOf(mn.Key.KeyString()).Convert(f.typ.Key()), elem)
 	return nil
 }
 
+// checkRequiredFields saves an error for each required field of the struct type t
+// that was not seen while decoding n.
+func (d *decoder) checkRequiredFields(n *scparse.DictionaryNode, t reflect.Type, fields structFields, seen []bool) {
+	for i, ok := range seen {
+		if ok || !fields.list[i].required {
+			continue
+		}
+		field := fields.list[i].name
+		if len(d.errorContext.FieldStack) > 0 {
+			field = strings.Join(d.errorContext.FieldStack, ".") + "." + field
+		}
+		d.saveError(&UnmarshalMissingFieldError{Struct: t.Name(), Field: field, Pos: n.Pos})
+	}
+}
+
+// checkDuplicateKeys saves an error for each key that occurs more than once in n.
+func (d *decoder) checkDuplicateKeys(n *scparse.DictionaryNode) {
+	keys := make(map[string]scparse.Pos, len(n.Members))
+	for _, mn := range n.Members {
+		k := mn.Key.KeyString()
+		if prev, ok := keys[k]; ok {
+			d.saveError(&scparse.DuplicateKeyError{Key: k, Pos: mn.Key.Position(), PrevPos: prev})
+			continue
+		}
+		keys[k] = mn.Key.Position()
+	}
+}
+
+// defaultString returns an InterpolatedStringNode containing the default value of n.
+func defaultString(n *scparse.VariableNode) *scparse.InterpolatedStringNode {
+	sn := &scparse.StringNode{Pos: n.Default.Pos, Value: n.Default.Value}
+	return &scparse.InterpolatedStringNode{Pos: n.Pos, Components: []scparse.StringContentNode{sn}}
+}
+
+// decodeDuration parses the string s from n into v if v is a time.Duration
+// and duration strings are enabled. It reports whether v was handled.
+func (d *decoder) decodeDuration(n scparse.ValueNode, s string, v reflect.Value) bool {
+	if !d.durationStrings || v.Type() != durationType {
+		return false
+	}
+	dur, err := time.ParseDuration(s)
+	if err != nil {
+		d.saveError(newUnmarshalTypeError(n, v.Type()))
+		return true
+	}
+	v.SetInt(int64(dur))
+	return true
+}
+
+// decodeTime parses n into v using layout if n is a string, or a variable with a string value,
+// and v is a time.Time or a pointer to one. It reports whether n was handled, other values
+// are left to be decoded normally.
+func (d *decoder) decodeTime(n scparse.ValueNode, v reflect.Value, layout string) bool {
+	if !v.IsValid() {
+		return false
+	}
+	t := v.Type()
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t != timeType {
+		return false
+	}
+	var s string
+	switch sn := n.(type) {
+	case *scparse.InterpolatedStringNode:
+		var ok bool
+		if s, ok = d.interpolate(sn); !ok {
+			return true
+		}
+	case *scparse.RawStringNode:
+		s = sn.Value
+	case *scparse.MultilineStringNode:
+		s = sn.Value
+	case *scparse.VariableNode:
+		// Variables with string values are parsed the same as strings,
+		// other values are decoded normally.
+		val, err := d.lookupVariable(sn)
+		if err != nil {
+			return false
+		}
+		if val.Kind() == reflect.Interface && !val.IsNil() {
+			val = val.Elem()
+		}
+		switch {
+		case val.Kind() == reflect.String:
+			s = val.String()
+		case !val.IsValid() && sn.Default != nil:
+			s = sn.Default.Value
+		default:
+			return false
+		}
+	default:
+		return false
+	}
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+	tv, err := time.Parse(layout, s)
+	if err != nil {
+		d.saveError(newUnmarshalTypeError(n, v.Type()))
+		return true
+	}
+	v.Set(reflect.ValueOf(tv))
+	return true
+}
+
+// decodeQuoted decodes a value for a field with the "string" tag option.
+// If n is a string, the string value is parsed into v based on the kind of v.
+// Otherwise, n is decoded normally.
+func (d *decoder) decodeQuoted(n scparse.ValueNode, v reflect.Value) error {
+	if !v.IsValid() {
+		return nil
+	}
+	var s string
+	switch sn := n.(type) {
+	case *scparse.InterpolatedStringNode:
+		var ok bool
+		if s, ok = d.interpolate(sn); !ok {
+			return nil
+		}
+	case *scparse.RawStringNode:
+		s = sn.Value
+	case *scparse.MultilineStringNode:
+		s = sn.Value
+	default:
+		return d.decodeValue(n, v)
+	}
+
+	u, ut, pv := indirect(v, false)
+	if u != nil || ut != nil {
+		return d.decodeValue(n, v)
+	}
+	if d.decodeDuration(n, s, pv) {
+		return nil
+	}
+	if !d.decodeParsedString(n, s, pv) {
+		return d.decodeValue(n, v)
+	}
+	return nil
+}
+
+// decodeParsedString parses the string value s of n into v if v is a bool or number.
+// If s cannot be parsed, an error is saved. It reports whether v is a bool or number.
+func (d *decoder) decodeParsedString(n scparse.ValueNode, s string, v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Bool:
+		switch s {
+		case "true":
+			v.SetBool(true)
+		case "false":
+			v.SetBool(false)
+		default:
+			d.saveError(newUnmarshalTypeError(n, v.Type()))
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			d.saveError(newUnmarshalTypeError(n, v.Type()))
+			break
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		u, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			d.saveError(newUnmarshalTypeError(n, v.Type()))
+			break
+		}
+		v.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			d.saveError(newUnmarshalTypeError(n, v.Type()))
+			break
+		}
+		v.SetFloat(f)
+	default:
+		return false
+	}
+	return true
+}
+
+// numberString returns the text of n, formatting its value if n was not parsed.
+func numberString(n *scparse.NumberNode) string {
+	switch {
+	case n.Raw != "":
+		return n.Raw
+	case n.IsInt:
+		return strconv.FormatInt(n.Int64, 10)
+	case n.IsUint:
+		return strconv.FormatUint(n.Uint64, 10)
+	}
+	return strconv.FormatFloat(n.Float64, 'g', -1, 64)
+}
+
 func (d *decoder) decodeList(n *scparse.ListNode, v reflect.Value) error {
 	// Check for unmarshaler.
 	u, ut, pv := indirect(v, false)
@@ -661,37 +1596,46 @@ func (d *decoder) valueInterface(n scparse.ValueNode) interface{} {
 		}
 		return n.Float64
 	case *scparse.InterpolatedStringNode:
-		var sb strings.Builder
-		for _, c := range n.Components {
-			switch c := c.(type) {
-			case *scparse.StringNode:
-				sb.WriteString(c.Value)
-			case *scparse.VariableNode:
-				// Lookup variable value
-				val, ok := d.vars.Lookup(c)
-				if !ok && d.disallowUnknownVars {
-					d.saveError(&UnmarshalUnknownVariableError{Variable: c.Identifier.Name, Pos: c.Pos})
-					return nil
-				}
-				if val == nil {
-					break // coerce to empty string
-				}
-				sb.WriteString(fmt.Sprint(val))
-			default:
-				panic(fmt.Errorf("impossible: invalid node type in InterpolatedString: %T", c))
-			}
+		s, ok := d.interpolate(n)
+		if !ok {
+			return nil
 		}
-		return sb.String()
+		return s
 	case *scparse.RawStringNode:
 		return n.Value
+	case *scparse.MultilineStringNode:
+		return n.Value
 	case *scparse.VariableNode:
-		val, ok := d.vars.Lookup(n)
-		if !ok && d.disallowUnknownVars {
+		val, ok, err := d.lookupVariableInterface(n)
+		if err != nil {
+			d.saveError(err)
+			return nil
+		}
+		if !ok && n.Default != nil {
+			return n.Default.Value
+		}
+		if !ok && d.disallowUnknown(n) {
 			d.saveError(&UnmarshalUnknownVariableError{Variable: n.Identifier.Name, Pos: n.Pos})
 			return nil
 		}
+		if !ok && d.keepUnknownVarText {
+			return n.String()
+		}
+		return val
+	case *scparse.ExpressionNode:
+		val, ok, err := d.evalExpression(n)
+		if err != nil {
+			d.saveError(err)
+			return nil
+		}
+		if !ok && d.keepUnknownVarText {
+			return n.String()
+		}
 		return val
 	case *scparse.DictionaryNode:
+		if d.orderedDicts {
+			return d.orderedDictionary(n)
+		}
 		return d.dictionaryInterface(n)
 	case *scparse.ListNode:
 		return d.listInterface(n)
@@ -702,6 +1646,9 @@ func (d *decoder) valueInterface(n scparse.ValueNode) interface{} {
 
 // dictionaryInterface is like decodeDictionary but returns map[string]interface{}
 func (d *decoder) dictionaryInterface(n *scparse.DictionaryNode) map[string]interface{} {
+	if d.duplicateKeys == scparse.DuplicateKeysError {
+		d.checkDuplicateKeys(n)
+	}
 	m := make(map[string]interface{})
 	for _, mn := range n.Members {
 		m[mn.Key.KeyString()] = d.valueInterface(mn.Value)
@@ -6,10 +6,13 @@ import (
 	"bytes"
 	"encoding"
 	"errors"
+	"fmt"
+	"net"
 	"reflect"
 	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/sc-lang/go-sc"
 	"github.com/sc-lang/go-sc/scparse"
@@ -110,6 +113,25 @@ type embed struct {
 	Num int
 }
 
+// Used to test inlining
+
+type Inline struct {
+	Name    string
+	Server  Server  `sc:",inline"`
+	Limits  *Limits `sc:"limits,inline"` // Name is ignored
+	Port    int     // Overrides Server.Port
+	Ignored int     `sc:",inline"` // Not a struct, option has no effect
+}
+
+type Server struct {
+	Host string
+	Port int
+}
+
+type Limits struct {
+	Memory int
+}
+
 // Test number possibilities
 
 type nums struct {
@@ -121,6 +143,16 @@ type nums struct {
 	Float64 float64
 }
 
+// Test the string tag option
+
+type quoted struct {
+	Port    int     `sc:",string"`
+	Size    *uint64 `sc:",string"`
+	Ratio   float32 `sc:",string"`
+	Enabled bool    `sc:",string"`
+	Name    string  `sc:",string"`
+}
+
 // Types for variables
 
 type Key string
@@ -205,6 +237,37 @@ func TestUnmarshal(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "inline",
+			input: `{
+				Name: "app"
+				Host: "localhost"
+				Port: 8080
+				Memory: 256
+				Ignored: 1
+			}`,
+			v: &Inline{},
+			want: &Inline{
+				Name:    "app",
+				Server:  Server{Host: "localhost"},
+				Limits:  &Limits{Memory: 256},
+				Port:    8080,
+				Ignored: 1,
+			},
+		},
+		{
+			name: "string option",
+			input: `{
+				Port: "8080"
+				Size: "${size}"
+				Ratio: 0.5
+				Enabled: ` + "`true`" + `
+				Name: "foo"
+			}`,
+			vars: map[string]interface{}{"size": uint64(1024)},
+			v:    &quoted{},
+			want: &quoted{Port: 8080, Size: func() *uint64 { u := uint64(1024); return &u }(), Ratio: 0.5, Enabled: true, Name: "foo"},
+		},
 		{
 			name: "encoding.TextUnmarshaler",
 			input: `{
@@ -259,6 +322,46 @@ func TestUnmarshal(t *testing.T) {
 			v:    &map[string]interface{}{},
 			want: &map[string]interface{}{"num": 3, "x": "z", "path": "/foo/bin/bar/baz"},
 		},
+		{
+			name: "variables: paths",
+			input: `{
+				host: ${server.host}
+				port: ${server.port}
+				url: "http://${server.host}:${server.port}/${server.paths.api}"
+				missing: ${server.missing.value}
+			}`,
+			vars: map[string]interface{}{
+				"server": struct {
+					Host  string
+					Port  int `sc:"port"`
+					Paths map[string]string
+				}{"localhost", 8080, map[string]string{"api": "v1"}},
+			},
+			v:    &map[string]interface{}{},
+			want: &map[string]interface{}{"host": "localhost", "port": 8080, "url": "http://localhost:8080/v1", "missing": nil},
+		},
+		{
+			name: "variables: defaults",
+			input: `{
+				Region: ${region:-us-east-1}
+				Port: ${port:-8080}
+				Debug: ${debug:-true}
+				URL: "http://${host:-localhost}:${port:-8080}"
+			}`,
+			vars: map[string]interface{}{"host": "example.com"},
+			v: &struct {
+				Region string
+				Port   int
+				Debug  bool
+				URL    string
+			}{},
+			want: &struct {
+				Region string
+				Port   int
+				Debug  bool
+				URL    string
+			}{"us-east-1", 8080, true, "http://example.com:8080"},
+		},
 		{
 			name: "variables: missing",
 			input: `{
@@ -291,6 +394,20 @@ func TestUnmarshal(t *testing.T) {
 			if !reflect.DeepEqual(tt.v, tt.want) {
 				t.Errorf("got unmarshaled value\n\t%#v\nwant\n\t%#v", tt.v, tt.want)
 			}
+
+			// A compiled decoder must produce the same result
+			typ := reflect.TypeOf(tt.v).Elem()
+			td, err := sc.CompileType(typ, sc.WithVariables(vars))
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			v := reflect.New(typ).Interface()
+			if err := td.Unmarshal([]byte(tt.input), v); err != nil {
+				t.Fatalf("unexpected error from TypeDecoder %v", err)
+			}
+			if !reflect.DeepEqual(v, tt.want) {
+				t.Errorf("got value from TypeDecoder\n\t%#v\nwant\n\t%#v", v, tt.want)
+			}
 		})
 	}
 }
@@ -422,11 +539,37 @@ func TestUnmarshalErrors(t *testing.T) {
 		t.Errorf("got error\n\t%+v\nwant\n\t%+v", *unknownVarErr, wantUnknownVarErr)
 	}
 
+	// 5
+	var unknownFieldErr *sc.UnmarshalUnknownFieldError
+	if !errors.As(errs[4], &unknownFieldErr) {
+		t.Fatalf("got error of type %T, want %T", errs[4], unknownFieldErr)
+	}
+	wantUnknownFieldErr := sc.UnmarshalUnknownFieldError{
+		Key:    "FieldF",
+		Struct: "V",
+		Pos:    scparse.Pos{Line: 7, Column: 3, Byte: 89},
+	}
+	if *unknownFieldErr != wantUnknownFieldErr {
+		t.Errorf("got error\n\t%+v\nwant\n\t%+v", *unknownFieldErr, wantUnknownFieldErr)
+	}
+
 	wantText := `sc: cannot unmarshal Number into Go struct field V.FieldB of type int
 sc: cannot unmarshal Number into Go struct field V.FieldC of type bool
 sc: unknown variable "num"
 sc: unknown variable "x"
-sc: unknown field "FieldF"`
+sc: unknown field "FieldF" in Go struct V`
+	if err.Error() != wantText {
+		t.Errorf("got error string\n\t%s\nwant\n\t%s", err, wantText)
+	}
+}
+
+func TestUnmarshalStringOptionError(t *testing.T) {
+	err := sc.Unmarshal([]byte(`{ Port: "80a", Enabled: "yes" }`), &quoted{})
+	if err == nil {
+		t.Fatalf("want error")
+	}
+	wantText := `sc: cannot unmarshal InterpolatedString into Go struct field quoted.Port of type int
+sc: cannot unmarshal InterpolatedString into Go struct field quoted.Enabled of type bool`
 	if err.Error() != wantText {
 		t.Errorf("got error string\n\t%s\nwant\n\t%s", err, wantText)
 	}
@@ -444,3 +587,1960 @@ func TestUnmarshalParseError(t *testing.T) {
 	// Just check that an *scparse.Error is returned.
 	// scparse has tests to check the error contents, we can assume it is correct here.
 }
+
+func TestUnmarshalParseOptions(t *testing.T) {
+	err := sc.Unmarshal([]byte(`{ a: { b: {} } }`), &map[string]interface{}{}, sc.WithParseOptions(scparse.WithMaxDepth(2)))
+	if err == nil {
+		t.Fatalf("want error")
+	}
+	var limitErr *scparse.LimitError
+	if !errors.As(err, &limitErr) {
+		t.Errorf("got error of type %T, want *scparse.LimitError", err)
+	}
+}
+
+func TestUnmarshalDottedKeys(t *testing.T) {
+	type http struct {
+		Port int
+	}
+	type server struct {
+		Host string
+		HTTP http
+	}
+	type config struct {
+		Server server
+	}
+	data := []byte(`{
+  server.host: "localhost"
+  server.http.port: 8080
+}`)
+	want := config{Server: server{Host: "localhost", HTTP: http{Port: 8080}}}
+	var got config
+	if err := sc.Unmarshal(data, &got, sc.WithParseOptions(scparse.WithDottedKeys(true))); err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	if got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestUnmarshalAnchors(t *testing.T) {
+	type resources struct {
+		CPU    int `sc:"cpu"`
+		Memory int `sc:"memory"`
+	}
+	type service struct {
+		Name      string    `sc:"name"`
+		Resources resources `sc:"resources"`
+	}
+	type config struct {
+		Services []service `sc:"services"`
+	}
+	data := []byte(`{
+  services: [
+    { name: "api", resources: &res { cpu: 2, memory: 512 } }
+    { name: "worker", resources: *res }
+  ]
+}`)
+	res := resources{CPU: 2, Memory: 512}
+	want := config{Services: []service{{"api", res}, {"worker", res}}}
+	var got config
+	if err := sc.Unmarshal(data, &got); err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	err := sc.Unmarshal([]byte(`{ services: &a [*a] }`), &got)
+	var anchorErr *scparse.AnchorError
+	if !errors.As(err, &anchorErr) {
+		t.Errorf("got error %v, want *scparse.AnchorError", err)
+	}
+
+	// Aliases that expand to too many values are rejected when a limit is set
+	err = sc.Unmarshal([]byte(`{ a: &a [1, 1, 1], b: &b [*a, *a, *a], c: [*b, *b, *b] }`), &map[string]interface{}{},
+		sc.WithParseOptions(scparse.WithMaxAliasExpansion(20)))
+	var limitErr *scparse.LimitError
+	if !errors.As(err, &limitErr) || limitErr.Limit != scparse.LimitAliasExpansion {
+		t.Errorf("got error %v, want alias expansion *scparse.LimitError", err)
+	}
+}
+
+func TestUnmarshalFuncs(t *testing.T) {
+	type config struct {
+		Path  string
+		Port  int
+		Debug bool
+	}
+	funcs := map[string]sc.Func{
+		"env": func(args ...interface{}) (interface{}, error) {
+			return map[string]interface{}{"HOME": "/home/sc", "PORT": 8080}[args[0].(string)], nil
+		},
+		"join": func(args ...interface{}) (interface{}, error) {
+			var sb strings.Builder
+			for _, a := range args {
+				fmt.Fprint(&sb, a)
+			}
+			return sb.String(), nil
+		},
+		"fail": func(args ...interface{}) (interface{}, error) {
+			return nil, errors.New("boom")
+		},
+	}
+	vars := sc.MustVariables(map[string]interface{}{"dir": ".config"})
+	data := []byte(`{
+  path: "${join(env("HOME"), "/", dir)}/sc"
+  port: ${env("PORT")}
+  debug: ${env("DEBUG"):-true}
+}`)
+	want := config{Path: "/home/sc/.config/sc", Port: 8080, Debug: true}
+	var got config
+	if err := sc.Unmarshal(data, &got, sc.WithVariables(vars), sc.WithFuncs(funcs)); err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	if got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{`{ path: ${upper(dir)} }`, `sc: unknown function "upper"`},
+		{`{ path: "${fail()}" }`, `sc: calling function "fail": boom`},
+	}
+	for _, tt := range tests {
+		err := sc.Unmarshal([]byte(tt.input), &got, sc.WithVariables(vars), sc.WithFuncs(funcs))
+		errs, ok := err.(sc.Errors)
+		if !ok || len(errs) != 1 {
+			t.Errorf("got error %v, want 1 error", err)
+			continue
+		}
+		var funcErr *sc.UnmarshalFuncError
+		if !errors.As(errs[0], &funcErr) {
+			t.Errorf("got error of type %T, want %T", errs[0], funcErr)
+			continue
+		}
+		if funcErr.Error() != tt.want {
+			t.Errorf("got error %q, want %q", funcErr.Error(), tt.want)
+		}
+	}
+}
+
+func TestUnmarshalExpressions(t *testing.T) {
+	type config struct {
+		Region   string
+		Host     string
+		Replicas int
+		Debug    bool
+	}
+	data := []byte(`{
+  region: ${region ?? "us-east-1"}
+  host: "db.${env == "prod" ? "internal" : "local"}"
+  replicas: ${env == "prod" ? replicas : "1"}
+  debug: ${env != "prod"}
+}`)
+	tests := []struct {
+		name string
+		vars map[string]interface{}
+		want config
+	}{
+		{
+			name: "prod",
+			vars: map[string]interface{}{"env": "prod", "replicas": 3},
+			want: config{Region: "us-east-1", Host: "db.internal", Replicas: 3},
+		},
+		{
+			name: "dev",
+			vars: map[string]interface{}{"env": "dev", "region": "eu-west-1", "replicas": 3},
+			want: config{Region: "eu-west-1", Host: "db.local", Replicas: 1, Debug: true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got config
+			err := sc.Unmarshal(data, &got, sc.WithVariables(sc.MustVariables(tt.vars)))
+			if err != nil {
+				t.Fatalf("unexpected error %s", err)
+			}
+			if got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	var got config
+	err := sc.Unmarshal([]byte(`{ region: ${region ?? zone} }`), &got, sc.WithDisallowUnknownVariables(true))
+	errs, ok := err.(sc.Errors)
+	if !ok || len(errs) != 1 {
+		t.Fatalf("got error %v, want 1 error", err)
+	}
+	var unknownVarErr *sc.UnmarshalUnknownVariableError
+	if !errors.As(errs[0], &unknownVarErr) {
+		t.Fatalf("got error of type %T, want %T", errs[0], unknownVarErr)
+	}
+	if unknownVarErr.Variable != "zone" {
+		t.Errorf("got unknown variable %q, want %q", unknownVarErr.Variable, "zone")
+	}
+}
+
+func TestUnmarshalRawNode(t *testing.T) {
+	type plugin struct {
+		Type   string
+		Config sc.RawNode
+	}
+	type dockerConfig struct {
+		Image string
+		Port  int
+	}
+	var p plugin
+	vars := sc.MustVariables(map[string]interface{}{"tag": "1.16"})
+	err := sc.Unmarshal([]byte(`{
+		type: "docker"
+		config: { image: "golang:${tag}", port: 8080 }
+	}`), &p, sc.WithVariables(vars))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if p.Config.Node.Type() != scparse.NodeDictionary {
+		t.Fatalf("got node type %s, want %s", p.Config.Node.Type(), scparse.NodeDictionary)
+	}
+
+	var dc dockerConfig
+	if err := p.Config.Decode(&dc); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	want := dockerConfig{Image: "golang:1.16", Port: 8080}
+	if dc != want {
+		t.Errorf("got decoded value\n\t%+v\nwant\n\t%+v", dc, want)
+	}
+
+	// Marshaling should produce the original value.
+	b, err := sc.Marshal(p)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	wantText := `{
+  Type: "docker"
+  Config: {
+    image: "golang:${tag}"
+    port: 8080
+  }
+}
+`
+	if string(b) != wantText {
+		t.Errorf("got marshaled value\n\t%#v\nwant\n\t%#v", string(b), wantText)
+	}
+}
+
+func TestUnmarshalMissingFieldError(t *testing.T) {
+	type Inner struct {
+		ID   int `sc:"id,required"`
+		Name string
+	}
+	type Outer struct {
+		Host  string `sc:"host,required"`
+		Port  int    `sc:"port,required"`
+		Inner Inner  `sc:"inner"`
+	}
+	err := sc.Unmarshal([]byte(`{
+		Host: "localhost"
+		inner: {
+			name: "foo"
+		}
+	}`), &Outer{})
+	if err == nil {
+		t.Fatalf("want error")
+	}
+	var errs sc.Errors
+	if !errors.As(err, &errs) {
+		t.Fatalf("got error of type %T, want Errors", err)
+	}
+	want := []sc.UnmarshalMissingFieldError{
+		{Struct: "Inner", Field: "inner.id", Pos: scparse.Pos{Line: 3, Column: 10, Byte: 31}},
+		{Struct: "Outer", Field: "port", Pos: scparse.Pos{Line: 1, Column: 1, Byte: 0}},
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("got %d errors, want %d", len(errs), len(want))
+	}
+	for i, e := range errs {
+		var missingErr *sc.UnmarshalMissingFieldError
+		if !errors.As(e, &missingErr) {
+			t.Fatalf("got error of type %T, want %T", e, missingErr)
+		}
+		if *missingErr != want[i] {
+			t.Errorf("got error\n\t%+v\nwant\n\t%+v", *missingErr, want[i])
+		}
+	}
+	wantText := `sc: missing required Go struct field Inner.inner.id
+sc: missing required Go struct field Outer.port`
+	if err.Error() != wantText {
+		t.Errorf("got error string\n\t%s\nwant\n\t%s", err, wantText)
+	}
+}
+
+func TestUnmarshalDuplicateKeys(t *testing.T) {
+	input := []byte(`{
+		name: "foo"
+		nested: { a: 1, b: 2, a: 3 }
+		name: "bar"
+	}`)
+	var v map[string]interface{}
+	err := sc.Unmarshal(input, &v, sc.WithDisallowDuplicateKeys(true))
+	if err == nil {
+		t.Fatalf("want error")
+	}
+	var errs sc.Errors
+	if !errors.As(err, &errs) {
+		t.Fatalf("got error of type %T, want Errors", err)
+	}
+	want := []scparse.DuplicateKeyError{
+		{Key: "name", Pos: scparse.Pos{Line: 4, Column: 3, Byte: 49}, PrevPos: scparse.Pos{Line: 2, Column: 3, Byte: 4}},
+		{Key: "a", Pos: scparse.Pos{Line: 3, Column: 25, Byte: 40}, PrevPos: scparse.Pos{Line: 3, Column: 13, Byte: 28}},
+	}
+	if len(errs) != len(want) {
+		t.Fatalf("got %d errors, want %d", len(errs), len(want))
+	}
+	for i, e := range errs {
+		var dupErr *scparse.DuplicateKeyError
+		if !errors.As(e, &dupErr) {
+			t.Fatalf("got error of type %T, want %T", e, dupErr)
+		}
+		if *dupErr != want[i] {
+			t.Errorf("got error\n\t%+v\nwant\n\t%+v", *dupErr, want[i])
+		}
+	}
+
+	// Last value wins by default
+	v = nil
+	if err := sc.Unmarshal(input, &v); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if v["name"] != "bar" {
+		t.Errorf("got name %v, want bar", v["name"])
+	}
+}
+
+func TestUnmarshalDuplicateKeyPolicy(t *testing.T) {
+	input := []byte(`{
+		name: "foo"
+		db: { host: "localhost", port: 5432 }
+		name: "bar"
+		db: { port: 6543 }
+	}`)
+	tests := []struct {
+		policy scparse.DuplicateKeyPolicy
+		want   map[string]interface{}
+	}{
+		{scparse.DuplicateKeysLastWins, map[string]interface{}{"name": "bar", "db": map[string]interface{}{"port": 6543}}},
+		{scparse.DuplicateKeysFirstWins, map[string]interface{}{"name": "foo", "db": map[string]interface{}{"host": "localhost", "port": 5432}}},
+		{scparse.DuplicateKeysDeepMerge, map[string]interface{}{"name": "bar", "db": map[string]interface{}{"host": "localhost", "port": 6543}}},
+	}
+	for _, tt := range tests {
+		var v map[string]interface{}
+		if err := sc.Unmarshal(input, &v, sc.WithDuplicateKeyPolicy(tt.policy)); err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		if !reflect.DeepEqual(v, tt.want) {
+			t.Errorf("got unmarshaled value\n\t%#v\nwant\n\t%#v", v, tt.want)
+		}
+
+		v = nil
+		dec := sc.NewDecoder(bytes.NewReader(input), sc.WithDuplicateKeyPolicy(tt.policy))
+		if err := dec.Decode(&v); err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		if !reflect.DeepEqual(v, tt.want) {
+			t.Errorf("got decoded value\n\t%#v\nwant\n\t%#v", v, tt.want)
+		}
+	}
+
+	var v map[string]interface{}
+	err := sc.Unmarshal(input, &v, sc.WithDuplicateKeyPolicy(scparse.DuplicateKeysError))
+	var errs sc.Errors
+	if !errors.As(err, &errs) || len(errs) != 2 {
+		t.Fatalf("got error %v, want 2 duplicate key errors", err)
+	}
+}
+
+func TestUnmarshalTagName(t *testing.T) {
+	type Config struct {
+		Name    string `json:"name" yaml:"title"`
+		Port    int    `json:"http_port,string"`
+		Ignored string `json:"-"`
+		Both    string `sc:"sc_both" json:"json_both"`
+		Plain   string
+	}
+	input := []byte(`{
+		name: "foo"
+		title: "bar"
+		http_port: "8080"
+		Ignored: "x"
+		sc_both: "sc"
+		json_both: "json"
+		plain: "p"
+	}`)
+	tests := []struct {
+		name string
+		opts []sc.UnmarshalOption
+		want Config
+	}{
+		{"default", nil, Config{Name: "foo", Ignored: "x", Both: "sc", Plain: "p"}},
+		{"json fallback", []sc.UnmarshalOption{sc.WithFallbackToJSONTags(true)}, Config{Name: "foo", Port: 8080, Both: "sc", Plain: "p"}},
+		{"json tag", []sc.UnmarshalOption{sc.WithTagName("json")}, Config{Name: "foo", Port: 8080, Both: "json", Plain: "p"}},
+		{"yaml tag with fallback", []sc.UnmarshalOption{sc.WithTagName("yaml"), sc.WithFallbackToJSONTags(true)}, Config{Name: "bar", Port: 8080, Both: "json", Plain: "p"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got Config
+			if err := sc.Unmarshal(input, &got, tt.opts...); err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+
+			td, err := sc.CompileType(reflect.TypeOf(Config{}), tt.opts...)
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			got = Config{}
+			if err := td.Unmarshal(input, &got); err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v from TypeDecoder, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	dec := sc.NewDecoder(bytes.NewReader(input), sc.WithTagName("json"))
+	var got Config
+	if err := dec.Decode(&got); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if got.Both != "json" {
+		t.Errorf("got Both %q, want json", got.Both)
+	}
+}
+
+func TestUnmarshalMultilineString(t *testing.T) {
+	type Config struct {
+		Script string                 `sc:"script"`
+		Any    interface{}            `sc:"any"`
+		Node   scparse.ValueNode      `sc:"node"`
+		Map    map[string]interface{} `sc:"map"`
+	}
+	input := []byte(`{
+  script: """
+    echo "${HOME}"
+      exit 1
+    """
+  any: """
+    any
+    """
+  node: """
+    node
+    """
+  map: {
+    k: """
+      v
+      """
+  }
+}`)
+	want := Config{
+		Script: "echo \"${HOME}\"\n  exit 1",
+		Any:    "any",
+		Map:    map[string]interface{}{"k": "v"},
+	}
+
+	check := func(got Config) {
+		t.Helper()
+		if n, ok := got.Node.(*scparse.MultilineStringNode); !ok || n.Value != "node" {
+			t.Errorf("got node %#v, want multi-line string node with value %q", got.Node, "node")
+		}
+		got.Node = nil
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("got %+v, want %+v", got, want)
+		}
+	}
+
+	var got Config
+	if err := sc.Unmarshal(input, &got); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	check(got)
+
+	td, err := sc.CompileType(reflect.TypeOf(Config{}))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	got = Config{}
+	if err := td.Unmarshal(input, &got); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	check(got)
+}
+
+func TestUnmarshalByteEncoding(t *testing.T) {
+	type Blobs struct {
+		Std  []byte  `sc:"std"`
+		Hex  []byte  `sc:"hex,hex"`
+		URL  []byte  `sc:"url,base64url"`
+		List []byte  `sc:"list,bytelist"`
+		Ptr  *[]byte `sc:"ptr,hex"`
+	}
+	input := []byte(`{
+		std: "+/8="
+		hex: "fbff"
+		url: "-_8"
+		list: [251, 255]
+		ptr: "${ptr}"
+	}`)
+	vars := sc.MustVariables(map[string]interface{}{"ptr": "FBFF"})
+	b := []byte{0xfb, 0xff}
+	want := Blobs{Std: b, Hex: b, URL: b, List: b, Ptr: &b}
+
+	var got Blobs
+	if err := sc.Unmarshal(input, &got, sc.WithVariables(vars)); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	td, err := sc.CompileType(reflect.TypeOf(Blobs{}), sc.WithVariables(vars))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	got = Blobs{}
+	if err := td.Unmarshal(input, &got); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v from TypeDecoder, want %+v", got, want)
+	}
+
+	// Padding is optional for base64url and lists are always allowed
+	got = Blobs{}
+	if err := sc.Unmarshal([]byte(`{ url: "-_8=", hex: [251, 255] }`), &got); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if !bytes.Equal(got.URL, b) || !bytes.Equal(got.Hex, b) {
+		t.Errorf("got url %v and hex %v, want %v", got.URL, got.Hex, b)
+	}
+
+	err = sc.Unmarshal([]byte(`{ hex: "+/8=" }`), &got)
+	var errs sc.Errors
+	if !errors.As(err, &errs) || len(errs) != 1 {
+		t.Fatalf("got error %v, want 1 error", err)
+	}
+}
+
+func TestUnmarshalTimeFormat(t *testing.T) {
+	type Event struct {
+		Name    string     `sc:"name"`
+		Date    time.Time  `sc:"date,format=2006-01-02"`
+		End     *time.Time `sc:"end,format=2006-01-02"`
+		Created time.Time  `sc:"created"`
+	}
+	input := []byte(`{
+		name: "launch"
+		date: "2021-03-04"
+		end: "${end}"
+		created: "04/03/2021 10:30"
+	}`)
+	vars := sc.MustVariables(map[string]interface{}{"end": "2021-03-05"})
+	end := time.Date(2021, 3, 5, 0, 0, 0, 0, time.UTC)
+	want := Event{
+		Name:    "launch",
+		Date:    time.Date(2021, 3, 4, 0, 0, 0, 0, time.UTC),
+		End:     &end,
+		Created: time.Date(2021, 3, 4, 10, 30, 0, 0, time.UTC),
+	}
+	opts := []sc.UnmarshalOption{sc.WithVariables(vars), sc.WithTimeFormat("02/01/2006 15:04")}
+
+	var got Event
+	if err := sc.Unmarshal(input, &got, opts...); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	td, err := sc.CompileType(reflect.TypeOf(Event{}), opts...)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	got = Event{}
+	if err := td.Unmarshal(input, &got); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v from TypeDecoder, want %+v", got, want)
+	}
+
+	// Without WithTimeFormat, RFC 3339 is used for fields without a format
+	got = Event{}
+	input = []byte(`{ date: "2021-03-04", created: "2021-03-04T10:30:00Z" }`)
+	if err := sc.Unmarshal(input, &got); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if !got.Date.Equal(want.Date) || !got.Created.Equal(want.Created) {
+		t.Errorf("got %+v, want date %v and created %v", got, want.Date, want.Created)
+	}
+
+	// Strings that do not match the layout are type errors
+	err = sc.Unmarshal([]byte(`{ date: "2021-03-04T10:30:00Z" }`), &got)
+	var errs sc.Errors
+	if !errors.As(err, &errs) || len(errs) != 1 {
+		t.Fatalf("got error %v, want 1 error", err)
+	}
+	wantErr := &sc.UnmarshalTypeError{
+		NodeType: scparse.NodeInterpolatedString,
+		Type:     reflect.TypeOf(time.Time{}),
+		Pos:      scparse.Pos{Line: 1, Column: 9, Byte: 8},
+		Struct:   "Event",
+		Field:    "date",
+	}
+	if !reflect.DeepEqual(errs[0], wantErr) {
+		t.Errorf("got error %#v, want %#v", errs[0], wantErr)
+	}
+
+	// Layouts of the time package can be used by name, and standalone variables are parsed
+	type Log struct {
+		At      time.Time  `sc:"at,format=RFC1123"`
+		Start   time.Time  `sc:"start,format=2006-01-02"`
+		Default *time.Time `sc:"default,format=2006-01-02"`
+	}
+	vars = sc.MustVariables(map[string]interface{}{"start": "2021-03-04"})
+	input = []byte(`{
+		at: "Thu, 04 Mar 2021 10:30:00 UTC"
+		start: ${start}
+		default: ${missing:-2021-03-05}
+	}`)
+	var log Log
+	if err := sc.Unmarshal(input, &log, sc.WithVariables(vars)); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	wantLog := Log{
+		At:      time.Date(2021, 3, 4, 10, 30, 0, 0, time.UTC),
+		Start:   want.Date,
+		Default: &end,
+	}
+	if !log.At.Equal(wantLog.At) || log.Start != wantLog.Start || !reflect.DeepEqual(log.Default, wantLog.Default) {
+		t.Errorf("got %+v, want %+v", log, wantLog)
+	}
+	td, err = sc.CompileType(reflect.TypeOf(Log{}), sc.WithVariables(vars))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	log = Log{}
+	if err := td.Unmarshal(input, &log); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if !log.At.Equal(wantLog.At) || log.Start != wantLog.Start || !reflect.DeepEqual(log.Default, wantLog.Default) {
+		t.Errorf("got %+v from TypeDecoder, want %+v", log, wantLog)
+	}
+}
+
+func TestUnmarshalDurationStrings(t *testing.T) {
+	type Config struct {
+		Timeout  time.Duration   `sc:"timeout"`
+		Interval time.Duration   `sc:"interval"`
+		Retry    *time.Duration  `sc:"retry"`
+		Backoff  []time.Duration `sc:"backoff"`
+		Quoted   time.Duration   `sc:"quoted,string"`
+		Default  time.Duration   `sc:"default"`
+	}
+	input := []byte(`{
+		timeout: "30s"
+		interval: 5000000000
+		retry: "1m30s"
+		backoff: ["100ms", "${backoff}"]
+		quoted: "2h"
+		default: ${missing:-5m}
+	}`)
+	vars := sc.MustVariables(map[string]interface{}{"backoff": "1s"})
+	retry := 90 * time.Second
+	want := Config{
+		Timeout:  30 * time.Second,
+		Interval: 5 * time.Second,
+		Retry:    &retry,
+		Backoff:  []time.Duration{100 * time.Millisecond, time.Second},
+		Quoted:   2 * time.Hour,
+		Default:  5 * time.Minute,
+	}
+
+	var got Config
+	if err := sc.Unmarshal(input, &got, sc.WithVariables(vars), sc.WithDurationStrings(true)); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	td, err := sc.CompileType(reflect.TypeOf(Config{}), sc.WithVariables(vars), sc.WithDurationStrings(true))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	got = Config{}
+	if err := td.Unmarshal(input, &got); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v from TypeDecoder, want %+v", got, want)
+	}
+
+	// Strings are type errors without the option
+	err = sc.Unmarshal([]byte(`{ timeout: "30s" }`), &got)
+	var errs sc.Errors
+	if !errors.As(err, &errs) || len(errs) != 1 {
+		t.Fatalf("got error %v, want 1 error", err)
+	}
+	if _, ok := errs[0].(*sc.UnmarshalTypeError); !ok {
+		t.Errorf("got error of type %T, want *sc.UnmarshalTypeError", errs[0])
+	}
+
+	// String variables are parsed, not only defaults
+	type V struct {
+		D time.Duration `sc:"d"`
+	}
+	var v V
+	tvars := sc.MustVariables(map[string]interface{}{"t": "1m"})
+	if err := sc.Unmarshal([]byte(`{ d: ${t:-30s} }`), &v, sc.WithVariables(tvars), sc.WithDurationStrings(true)); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if v.D != time.Minute {
+		t.Errorf("got %v, want %v", v.D, time.Minute)
+	}
+	tvars = sc.MustVariables(map[string]interface{}{"t": "soon"})
+	err = sc.Unmarshal([]byte(`{ d: ${t} }`), &v, sc.WithVariables(tvars), sc.WithDurationStrings(true))
+	if !errors.As(err, &errs) || len(errs) != 1 {
+		t.Fatalf("got error %v, want 1 error", err)
+	}
+
+	// Invalid durations are type errors
+	err = sc.Unmarshal([]byte(`{ timeout: "30 seconds" }`), &got, sc.WithDurationStrings(true))
+	if !errors.As(err, &errs) || len(errs) != 1 {
+		t.Fatalf("got error %v, want 1 error", err)
+	}
+	wantErr := &sc.UnmarshalTypeError{
+		NodeType: scparse.NodeInterpolatedString,
+		Type:     reflect.TypeOf(time.Duration(0)),
+		Pos:      scparse.Pos{Line: 1, Column: 12, Byte: 11},
+		Struct:   "Config",
+		Field:    "timeout",
+	}
+	if !reflect.DeepEqual(errs[0], wantErr) {
+		t.Errorf("got error %#v, want %#v", errs[0], wantErr)
+	}
+}
+
+func TestUnmarshalWeakTypes(t *testing.T) {
+	type Config struct {
+		Env     map[string]string `sc:"env"`
+		Port    int               `sc:"port"`
+		Ratio   float64           `sc:"ratio"`
+		Debug   bool              `sc:"debug"`
+		Workers *uint8            `sc:"workers"`
+	}
+	input := []byte(`{
+		env: {
+			PORT: 8080
+			RATIO: 0.5
+			BIG: 1e3
+			DEBUG: true
+			EMPTY: null
+			NAME: "api"
+		}
+		port: "8080"
+		ratio: "0.25"
+		debug: "false"
+		workers: "4"
+	}`)
+	workers := uint8(4)
+	want := Config{
+		Env: map[string]string{
+			"PORT":  "8080",
+			"RATIO": "0.5",
+			"BIG":   "1e3",
+			"DEBUG": "true",
+			"EMPTY": "",
+			"NAME":  "api",
+		},
+		Port:    8080,
+		Ratio:   0.25,
+		Debug:   false,
+		Workers: &workers,
+	}
+
+	var got Config
+	if err := sc.Unmarshal(input, &got, sc.WithWeakTypes(true)); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	// Values are type errors without the option
+	got = Config{}
+	err := sc.Unmarshal([]byte(`{ env: { PORT: 8080 }, port: "8080" }`), &got)
+	var errs sc.Errors
+	if !errors.As(err, &errs) || len(errs) != 2 {
+		t.Fatalf("got error %v, want 2 errors", err)
+	}
+
+	// Strings that cannot be parsed are type errors
+	err = sc.Unmarshal([]byte(`{ port: "http" }`), &got, sc.WithWeakTypes(true))
+	if !errors.As(err, &errs) || len(errs) != 1 {
+		t.Fatalf("got error %v, want 1 error", err)
+	}
+	wantErr := &sc.UnmarshalTypeError{
+		NodeType: scparse.NodeInterpolatedString,
+		Type:     reflect.TypeOf(0),
+		Pos:      scparse.Pos{Line: 1, Column: 9, Byte: 8},
+		Struct:   "Config",
+		Field:    "port",
+	}
+	if !reflect.DeepEqual(errs[0], wantErr) {
+		t.Errorf("got error %#v, want %#v", errs[0], wantErr)
+	}
+
+	// Variables are converted the same as literals
+	vars := sc.MustVariables(map[string]interface{}{
+		"port":  8080,
+		"ratio": float32(0.5),
+		"debug": true,
+		"none":  nil,
+		"sport": "9090",
+		"sflag": "true",
+		"bad":   "http",
+	})
+	got = Config{}
+	input = []byte(`{
+		env: { PORT: ${port}, RATIO: ${ratio}, DEBUG: ${debug}, NONE: ${none} }
+		port: ${sport}
+		ratio: ${ratio}
+		debug: ${sflag}
+	}`)
+	if err := sc.Unmarshal(input, &got, sc.WithVariables(vars), sc.WithWeakTypes(true)); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	want = Config{
+		Env:   map[string]string{"PORT": "8080", "RATIO": "0.5", "DEBUG": "true", "NONE": ""},
+		Port:  9090,
+		Ratio: 0.5,
+		Debug: true,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+	err = sc.Unmarshal([]byte(`{ port: ${bad} }`), &got, sc.WithVariables(vars), sc.WithWeakTypes(true))
+	if !errors.As(err, &errs) || len(errs) != 1 {
+		t.Fatalf("got error %v, want 1 error", err)
+	}
+	if want := "sc: cannot unmarshal Variable into Go struct field Config.port of type int"; errs[0].Error() != want {
+		t.Errorf("got error\n\t%s\nwant\n\t%s", errs[0], want)
+	}
+}
+
+func TestUnmarshalDisallowNullForNonPointer(t *testing.T) {
+	type Config struct {
+		Name  string         `sc:"name"`
+		Port  int            `sc:"port"`
+		Debug bool           `sc:"debug"`
+		Retry *int           `sc:"retry"`
+		Tags  []string       `sc:"tags"`
+		Extra interface{}    `sc:"extra"`
+		Env   map[string]int `sc:"env"`
+	}
+	input := []byte(`{
+		name: null
+		port: null
+		debug: null
+		retry: null
+		tags: null
+		extra: null
+		env: { a: null }
+	}`)
+
+	// Null is ignored by default
+	got := Config{Name: "api", Port: 80}
+	if err := sc.Unmarshal(input, &got); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if want := (Config{Name: "api", Port: 80, Env: map[string]int{"a": 0}}); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	for _, opt := range []sc.UnmarshalOption{
+		sc.WithDisallowNullForNonPointer(true),
+		// Takes precedence over weak types
+		sc.WithWeakTypes(true),
+	} {
+		err := sc.Unmarshal(input, &Config{}, sc.WithDisallowNullForNonPointer(true), opt)
+		var errs sc.Errors
+		if !errors.As(err, &errs) || len(errs) != 4 {
+			t.Fatalf("got error %v, want 4 errors", err)
+		}
+		wantErr := &sc.UnmarshalTypeError{
+			NodeType: scparse.NodeNull,
+			Type:     reflect.TypeOf(""),
+			Pos:      scparse.Pos{Line: 2, Column: 9, Byte: 10},
+			Struct:   "Config",
+			Field:    "name",
+		}
+		if !reflect.DeepEqual(errs[0], wantErr) {
+			t.Errorf("got error %#v, want %#v", errs[0], wantErr)
+		}
+		wantText := `sc: cannot unmarshal Null into Go struct field Config.name of type string
+sc: cannot unmarshal Null into Go struct field Config.port of type int
+sc: cannot unmarshal Null into Go struct field Config.debug of type bool
+sc: cannot unmarshal Null into Go struct field Config.env of type int`
+		if err.Error() != wantText {
+			t.Errorf("got error string\n\t%s\nwant\n\t%s", err, wantText)
+		}
+	}
+}
+
+func TestUnmarshalRemain(t *testing.T) {
+	type Base struct {
+		Extra map[string]interface{} `sc:",remain"`
+	}
+	type Plugin struct {
+		Name string `sc:"name"`
+		*Base
+	}
+	type Nodes struct {
+		Name  string                       `sc:"name"`
+		Extra map[string]scparse.ValueNode `sc:"extra,remain"`
+	}
+	type Invalid struct {
+		Name  string `sc:"name"`
+		Extra []int  `sc:"rest,remain"`
+	}
+	input := []byte(`{
+		name: "foo"
+		extra: 1
+		opts: { debug: true }
+	}`)
+
+	var p Plugin
+	if err := sc.Unmarshal(input, &p, sc.WithDisallowUnknownFields(true)); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	want := Plugin{Name: "foo", Base: &Base{Extra: map[string]interface{}{
+		"extra": 1,
+		"opts":  map[string]interface{}{"debug": true},
+	}}}
+	if !reflect.DeepEqual(p, want) {
+		t.Errorf("got %+v, want %+v", p, want)
+	}
+
+	var n Nodes
+	if err := sc.Unmarshal(input, &n); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if len(n.Extra) != 2 {
+		t.Fatalf("got %d remaining nodes, want 2", len(n.Extra))
+	}
+	if _, ok := n.Extra["opts"].(*scparse.DictionaryNode); !ok {
+		t.Errorf("got node of type %T, want *scparse.DictionaryNode", n.Extra["opts"])
+	}
+
+	// The option is ignored if the field is not a map
+	var inv Invalid
+	if err := sc.Unmarshal(input, &inv); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if inv.Extra != nil {
+		t.Errorf("got Extra %v, want nil", inv.Extra)
+	}
+
+	// Errors include the remain field
+	var typed struct {
+		Extra map[string]int `sc:",remain"`
+	}
+	err := sc.Unmarshal([]byte(`{ a: 1, b: "x" }`), &typed)
+	wantErr := `sc: cannot unmarshal InterpolatedString into Go struct field .Extra of type int`
+	if err == nil || err.Error() != wantErr {
+		t.Errorf("got error\n\t%v\nwant\n\t%s", err, wantErr)
+	}
+}
+
+type validatedServer struct {
+	Host string `sc:"host"`
+	Port int    `sc:"port"`
+}
+
+func (s *validatedServer) ValidateSC() error {
+	if s.Port <= 0 {
+		return fmt.Errorf("port must be positive, got %d", s.Port)
+	}
+	return nil
+}
+
+type validatedConfig struct {
+	Servers []validatedServer `sc:"servers"`
+	Primary *validatedServer  `sc:"primary"`
+	Min     int               `sc:"min"`
+	Max     int               `sc:"max"`
+}
+
+var errMinMax = errors.New("min must be less than max")
+
+func (c validatedConfig) ValidateSC() error {
+	var errs sc.Errors
+	if c.Min > c.Max {
+		errs = append(errs, errMinMax)
+	}
+	if c.Primary == nil {
+		errs = append(errs, errors.New("primary is required"))
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+func TestUnmarshalValidator(t *testing.T) {
+	input := []byte(`{
+	servers: [{ host: "a", port: 80 }]
+	min: 2
+	max: 1
+}`)
+	var c validatedConfig
+	err := sc.Unmarshal(input, &c)
+	var errs sc.Errors
+	if !errors.As(err, &errs) {
+		t.Fatalf("got error %v, want Errors", err)
+	}
+	wantErrs := []string{
+		"sc: invalid Go value of type sc_test.validatedConfig: min must be less than max",
+		"sc: invalid Go value of type sc_test.validatedConfig: primary is required",
+	}
+	wantPos := []scparse.Pos{{Line: 1, Column: 1}, {Line: 1, Column: 1}}
+	if len(errs) != len(wantErrs) {
+		t.Fatalf("got errors %v, want %d errors", errs, len(wantErrs))
+	}
+	for i, e := range errs {
+		var ve *sc.ValidationError
+		if !errors.As(e, &ve) {
+			t.Fatalf("got error of type %T, want %T", e, ve)
+		}
+		if e.Error() != wantErrs[i] {
+			t.Errorf("got error\n\t%s\nwant\n\t%s", e, wantErrs[i])
+		}
+		if ve.Pos != wantPos[i] {
+			t.Errorf("got position %+v, want %+v", ve.Pos, wantPos[i])
+		}
+	}
+	if !errors.Is(errs[0], errMinMax) {
+		t.Errorf("want error to wrap errMinMax")
+	}
+
+	// TypeDecoder calls ValidateSC too
+	td, err := sc.CompileType(reflect.TypeOf(validatedConfig{}))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	var c2 validatedConfig
+	if err := td.Unmarshal(input, &c2); !reflect.DeepEqual(err, errs) {
+		t.Errorf("got error %v from TypeDecoder, want %v", err, errs)
+	}
+
+	// ValidateSC is only called on values that were unmarshaled without errors,
+	// including errors from nested values
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{`{ primary: { host: 1, port: 1 } }`, "sc: cannot unmarshal Number into Go struct field validatedServer.primary.host of type string"},
+		{`{ servers: [{ host: "a", port: 0 }] }`, "sc: invalid Go struct field validatedConfig.servers of type sc_test.validatedServer: port must be positive, got 0"},
+	}
+	for _, tt := range tests {
+		c = validatedConfig{}
+		err = sc.Unmarshal([]byte(tt.input), &c)
+		if err == nil || err.Error() != tt.want {
+			t.Errorf("got error\n\t%v\nwant\n\t%s", err, tt.want)
+		}
+	}
+
+	c = validatedConfig{}
+	if err := sc.Unmarshal([]byte(`{ primary: { host: "a", port: 1 }, max: 1 }`), &c); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestUnmarshalDecodeHook(t *testing.T) {
+	durationHook := func(n scparse.ValueNode, target reflect.Type) (interface{}, bool, error) {
+		if target != reflect.TypeOf(time.Duration(0)) {
+			return nil, false, nil
+		}
+		s, ok := n.(*scparse.InterpolatedStringNode)
+		if !ok || len(s.Components) != 1 {
+			return nil, false, nil
+		}
+		d, err := time.ParseDuration(s.Components[0].(*scparse.StringNode).Value)
+		return d, true, err
+	}
+	ipHook := func(n scparse.ValueNode, target reflect.Type) (interface{}, bool, error) {
+		if target != reflect.TypeOf(net.IP{}) {
+			return nil, false, nil
+		}
+		if _, ok := n.(*scparse.NullNode); ok {
+			return nil, true, nil
+		}
+		return net.ParseIP(strings.Trim(n.String(), `"`)), true, nil
+	}
+	type Config struct {
+		Timeout  time.Duration  `sc:"timeout"`
+		Interval *time.Duration `sc:"interval"`
+		Retries  time.Duration  `sc:"retries"`
+		Addr     net.IP         `sc:"addr"`
+		Backup   net.IP         `sc:"backup"`
+		Name     string         `sc:"name"`
+	}
+	input := []byte(`{
+		timeout: "1m30s"
+		interval: "5s"
+		retries: 3
+		addr: "10.0.0.1"
+		backup: null
+		name: "foo"
+	}`)
+	interval := 5 * time.Second
+	want := Config{Timeout: 90 * time.Second, Interval: &interval, Retries: 3, Addr: net.ParseIP("10.0.0.1"), Name: "foo"}
+	opts := []sc.UnmarshalOption{sc.WithDecodeHook(durationHook), sc.WithDecodeHook(ipHook)}
+
+	var got Config
+	if err := sc.Unmarshal(input, &got, opts...); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	td, err := sc.CompileType(reflect.TypeOf(Config{}), opts...)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	got = Config{}
+	if err := td.Unmarshal(input, &got); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v from TypeDecoder, want %+v", got, want)
+	}
+
+	// Errors from hooks and invalid values are reported with the position of the node
+	badHook := func(n scparse.ValueNode, target reflect.Type) (interface{}, bool, error) {
+		if target.Kind() == reflect.String {
+			return 1, true, nil
+		}
+		return nil, false, nil
+	}
+	err = sc.Unmarshal([]byte(`{ timeout: "soon", name: "foo" }`), &got, sc.WithDecodeHook(durationHook), sc.WithDecodeHook(badHook))
+	var errs sc.Errors
+	if !errors.As(err, &errs) || len(errs) != 2 {
+		t.Fatalf("got error %v, want 2 errors", err)
+	}
+	wantErrs := []string{
+		`sc: cannot decode into Go struct field Config.timeout of type time.Duration: time: invalid duration "soon"`,
+		`sc: cannot decode into Go struct field Config.name of type string: decode hook returned value of type int`,
+	}
+	wantPos := []scparse.Pos{{Line: 1, Column: 12, Byte: 11}, {Line: 1, Column: 26, Byte: 25}}
+	for i, e := range errs {
+		var he *sc.DecodeHookError
+		if !errors.As(e, &he) {
+			t.Fatalf("got error of type %T, want %T", e, he)
+		}
+		if e.Error() != wantErrs[i] {
+			t.Errorf("got error\n\t%s\nwant\n\t%s", e, wantErrs[i])
+		}
+		if he.Pos != wantPos[i] {
+			t.Errorf("got position %+v, want %+v", he.Pos, wantPos[i])
+		}
+	}
+}
+
+func TestUnmarshalDecodeHookTopLevel(t *testing.T) {
+	type Config struct {
+		Name string `sc:"name"`
+	}
+	configType := reflect.TypeOf(&Config{})
+	hook := func(n scparse.ValueNode, target reflect.Type) (interface{}, bool, error) {
+		if target != configType {
+			return nil, false, nil
+		}
+		if _, ok := n.(*scparse.NullNode); ok {
+			return nil, true, nil
+		}
+		return &Config{Name: "hooked"}, true, nil
+	}
+
+	// The pointer passed to Unmarshal cannot be set, the value it points to is set instead
+	var got Config
+	if err := sc.Unmarshal([]byte(`{ name: "foo" }`), &got, sc.WithDecodeHook(hook)); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if want := (Config{Name: "hooked"}); got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	var p *Config
+	if err := sc.Unmarshal([]byte(`{ name: "foo" }`), &p, sc.WithDecodeHook(hook)); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if p == nil || p.Name != "hooked" {
+		t.Errorf("got %+v, want pointer to hooked config", p)
+	}
+
+	// A nil result zeroes the value
+	got = Config{Name: "foo"}
+	hook = func(n scparse.ValueNode, target reflect.Type) (interface{}, bool, error) {
+		return nil, target == configType, nil
+	}
+	if err := sc.Unmarshal([]byte(`{ name: "foo" }`), &got, sc.WithDecodeHook(hook)); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if got != (Config{}) {
+		t.Errorf("got %+v, want zero value", got)
+	}
+}
+
+func TestUnmarshalReader(t *testing.T) {
+	var v map[string]interface{}
+	vars := sc.MustVariables(map[string]interface{}{"name": "foo"})
+	err := sc.UnmarshalReader(strings.NewReader(`{ name: ${name} }`), &v, sc.WithVariables(vars))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	want := map[string]interface{}{"name": "foo"}
+	if !reflect.DeepEqual(v, want) {
+		t.Errorf("got unmarshaled value\n\t%#v\nwant\n\t%#v", v, want)
+	}
+}
+
+func TestDecoderErrorsNotShared(t *testing.T) {
+	r := strings.NewReader(`{ a: 1 }`)
+	dec := sc.NewDecoder(r)
+	dec.DisallowUnknownFields(true)
+	var v struct{ B int }
+	if err := dec.Decode(&v); err == nil {
+		t.Fatalf("want error")
+	}
+	r.Reset(`{ b: 1 }`)
+	if err := dec.Decode(&v); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestDecoderReset(t *testing.T) {
+	dec := sc.NewDecoder(strings.NewReader(`{ name: "a-${n}" }`), sc.WithVariablesMap(map[string]int{"n": 1}))
+	type config struct {
+		Name  string `sc:"name"`
+		Inner struct {
+			Port int `sc:"port"`
+		} `sc:"inner"`
+	}
+	var v config
+	if err := dec.Decode(&v); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if v.Name != "a-1" {
+		t.Errorf("got name %q, want a-1", v.Name)
+	}
+
+	// Options are kept after Reset
+	dec.Reset(strings.NewReader(`{ name: "b-${n}", inner: { port: "x" } }`))
+	v = config{}
+	var errs sc.Errors
+	var typeErr *sc.UnmarshalTypeError
+	err := dec.Decode(&v)
+	if !errors.As(err, &errs) || !errors.As(errs[0], &typeErr) || typeErr.Field != "inner.port" {
+		t.Fatalf("got error %v, want type error for inner.port", err)
+	}
+	if v.Name != "b-1" {
+		t.Errorf("got name %q, want b-1", v.Name)
+	}
+
+	// Field context from a previous call does not leak into the next
+	dec.Reset(strings.NewReader(`{ name: 1 }`))
+	err = dec.Decode(&v)
+	if !errors.As(err, &errs) || !errors.As(errs[0], &typeErr) || typeErr.Field != "name" {
+		t.Errorf("got error %v, want type error for name", err)
+	}
+}
+
+func TestDecoderResetPreserveSource(t *testing.T) {
+	// The decoder reuses its buffer, make sure ASTs from previous calls are not affected.
+	dec := sc.NewDecoder(strings.NewReader("{\n  a:   1 // one\n}\n"), sc.WithParseOptions(scparse.WithPreserveSource(true)))
+	var first scparse.ValueNode
+	if err := dec.Decode(&first); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	dec.Reset(strings.NewReader("{\n  zzzzzzzzzzzzzzzzzzzz: 2\n}\n"))
+	var second scparse.ValueNode
+	if err := dec.Decode(&second); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	const want = "{\n  a:   1 // one\n}\n"
+	if got := string(scparse.Format(first.(*scparse.DictionaryNode))); got != want {
+		t.Errorf("got formatted first document\n\t%q\nwant\n\t%q", got, want)
+	}
+}
+
+func TestUnmarshalOptionsNotShared(t *testing.T) {
+	// Decoders are reused internally, make sure options from one call are not kept.
+	var v struct{ A int }
+	if err := sc.Unmarshal([]byte(`{ b: 1 }`), &v, sc.WithDisallowUnknownFields(true)); err == nil {
+		t.Fatalf("want error")
+	}
+	if err := sc.Unmarshal([]byte(`{ b: 1 }`), &v); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestNewDecoderOptions(t *testing.T) {
+	dec := sc.NewDecoder(strings.NewReader(`{ a: 1, b: ${b} }`),
+		sc.WithDisallowUnknownFields(true),
+		sc.WithVariablesMap(map[string]int{"b": 2}),
+	)
+	var v struct{ A, B int }
+	if err := dec.Decode(&v); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if v.A != 1 || v.B != 2 {
+		t.Errorf("got %+v, want {A:1 B:2}", v)
+	}
+
+	dec = sc.NewDecoder(strings.NewReader(`{ a: 1, c: 3 }`), sc.WithDisallowUnknownFields(true))
+	var errs sc.Errors
+	if err := dec.Decode(&v); !errors.As(err, &errs) {
+		t.Errorf("got error %v, want Errors", err)
+	}
+
+	// Invalid options cause Decode to fail
+	dec = sc.NewDecoder(strings.NewReader(`{ a: 1 }`), sc.WithVariablesMap("invalid"))
+	wantText := "sc: invalid type string used for variables"
+	if err := dec.Decode(&v); err == nil || err.Error() != wantText {
+		t.Errorf("got error %v, want %q", err, wantText)
+	}
+}
+
+func TestMergeVariables(t *testing.T) {
+	defaults := sc.MustVariables(map[string]interface{}{"host": "localhost", "port": 80, "debug": false})
+	env := sc.MustVariables(map[Key]string{"port": "8080"})
+	overrides := sc.MustVariables(Data{"debug": true})
+	vars := sc.MergeVariables(defaults, env, overrides)
+
+	var v map[string]interface{}
+	err := sc.Unmarshal([]byte(`{
+		host: ${host}
+		port: ${port}
+		debug: ${debug}
+		missing: ${missing}
+	}`), &v, sc.WithVariables(vars))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	want := map[string]interface{}{"host": "localhost", "port": "8080", "debug": true, "missing": nil}
+	if !reflect.DeepEqual(v, want) {
+		t.Errorf("got unmarshaled value\n\t%#v\nwant\n\t%#v", v, want)
+	}
+}
+
+func TestUnmarshalStrictVariableTypes(t *testing.T) {
+	type V struct {
+		Count int
+		Ratio float64
+		Name  Key
+	}
+	input := []byte(`{
+		count: ${count}
+		ratio: ${ratio}
+		name: ${name}
+	}`)
+	vars := sc.MustVariables(map[string]interface{}{"count": 2.5, "ratio": 0.5, "name": "foo"})
+
+	// Conversions are allowed by default
+	var v V
+	if err := sc.Unmarshal(input, &v, sc.WithVariables(vars)); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if want := (V{2, 0.5, "foo"}); v != want {
+		t.Errorf("got unmarshaled value\n\t%+v\nwant\n\t%+v", v, want)
+	}
+
+	err := sc.Unmarshal(input, &V{}, sc.WithVariables(vars), sc.WithStrictVariableTypes(true))
+	if err == nil {
+		t.Fatalf("want error")
+	}
+	var errs sc.Errors
+	if !errors.As(err, &errs) {
+		t.Fatalf("got error of type %T, want Errors", err)
+	}
+	if len(errs) != 2 {
+		t.Fatalf("got %d errors, want %d", len(errs), 2)
+	}
+	var varTypeErr *sc.UnmarshalVariableTypeError
+	if !errors.As(errs[0], &varTypeErr) {
+		t.Fatalf("got error of type %T, want %T", errs[0], varTypeErr)
+	}
+	want := sc.UnmarshalVariableTypeError{
+		Variable:     "count",
+		VariableType: reflect.TypeOf(float64(0)),
+		Type:         reflect.TypeOf(int(0)),
+		Pos:          scparse.Pos{Line: 2, Column: 10, Byte: 11},
+		Struct:       "V",
+		Field:        "Count",
+	}
+	if *varTypeErr != want {
+		t.Errorf("got error\n\t%+v\nwant\n\t%+v", *varTypeErr, want)
+	}
+	wantText := `sc: cannot unmarshal variable "count" of type float64 into Go struct field V.Count of type int
+sc: cannot unmarshal variable "name" of type string into Go struct field V.Name of type sc_test.Key`
+	if err.Error() != wantText {
+		t.Errorf("got error string\n\t%s\nwant\n\t%s", err, wantText)
+	}
+
+	// Compiled decoders and expressions are also strict
+	td, err := sc.CompileType(reflect.TypeOf(V{}), sc.WithVariables(vars), sc.WithStrictVariableTypes(true))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if err := td.Unmarshal(input, &V{}); err == nil || err.Error() != wantText {
+		t.Errorf("got error from TypeDecoder\n\t%v\nwant\n\t%s", err, wantText)
+	}
+	err = sc.Unmarshal([]byte(`{ count: ${count ?? ratio} }`), &V{}, sc.WithVariables(vars), sc.WithStrictVariableTypes(true))
+	wantText = `sc: cannot unmarshal variable "${count ?? ratio}" of type float64 into Go struct field V.Count of type int`
+	if err == nil || err.Error() != wantText {
+		t.Errorf("got error from expression\n\t%v\nwant\n\t%s", err, wantText)
+	}
+}
+
+func TestUnmarshalReport(t *testing.T) {
+	type Config struct {
+		Host    string   `sc:"host"`
+		Port    int      `sc:"port"`
+		Region  string   `sc:"region"`
+		Tags    []string `sc:"tags"`
+		Timeout string   `sc:"timeout"`
+	}
+	input := []byte(`{
+		vars: { timeout: "30s", unused_doc: 1 }
+		host: "${server.host}:${server.port}"
+		port: ${server.port}
+		region: ${regoin:-us-east-1}
+		tags: [${tag ?? "none"}, "${upper(env)}"]
+		timeout: ${timeout}
+	}`)
+	vars := sc.MustVariables(map[string]interface{}{
+		"server": map[string]interface{}{"host": "localhost", "port": 8080},
+		"region": "eu-west-1",
+		"env":    "prod",
+	})
+	funcs := map[string]sc.Func{
+		"upper": func(args ...interface{}) (interface{}, error) {
+			return strings.ToUpper(args[0].(string)), nil
+		},
+	}
+	want := sc.UnmarshalReport{
+		UsedVariables:    []string{"env", "server.host", "server.port", "timeout"},
+		UnknownVariables: []string{"regoin", "tag"},
+		UnusedVariables:  []string{"region", "unused_doc"},
+	}
+
+	var report sc.UnmarshalReport
+	opts := []sc.UnmarshalOption{sc.WithVariables(vars), sc.WithDocumentVariables("vars"), sc.WithFuncs(funcs), sc.WithReport(&report)}
+	var got Config
+	if err := sc.Unmarshal(input, &got, opts...); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if !reflect.DeepEqual(report, want) {
+		t.Errorf("got report\n\t%+v\nwant\n\t%+v", report, want)
+	}
+
+	report = sc.UnmarshalReport{}
+	td, err := sc.CompileType(reflect.TypeOf(Config{}), opts...)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if err := td.Unmarshal(input, &got); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if !reflect.DeepEqual(report, want) {
+		t.Errorf("got report from TypeDecoder\n\t%+v\nwant\n\t%+v", report, want)
+	}
+
+	// Only the variables in the decode path are used
+	var port int
+	if err := sc.Unmarshal(input, &port, sc.WithVariables(vars), sc.WithDecodePath("port"), sc.WithReport(&report)); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	want = sc.UnmarshalReport{
+		UsedVariables:   []string{"server.port"},
+		UnusedVariables: []string{"env", "region"},
+	}
+	if !reflect.DeepEqual(report, want) {
+		t.Errorf("got report with decode path\n\t%+v\nwant\n\t%+v", report, want)
+	}
+}
+
+func TestUnmarshalRequiredVariables(t *testing.T) {
+	type Config struct {
+		Password string   `sc:"password"`
+		Region   string   `sc:"region"`
+		Host     string   `sc:"host"`
+		Zone     string   `sc:"zone"`
+		Tags     []string `sc:"tags"`
+	}
+	input := []byte(`{
+		password: ${db_password}
+		region: "${region}"
+		host: ${server.host:-localhost}
+		zone: ${zone ?? region}
+		tags: [${tag}]
+	}`)
+
+	// Optional variables are ignored, required ones are errors
+	var got Config
+	err := sc.Unmarshal(input, &got, sc.WithRequiredVariables("db_password", "region"), sc.WithRequiredVariables("server.host"))
+	var errs sc.Errors
+	if !errors.As(err, &errs) {
+		t.Fatalf("got error %v, want Errors", err)
+	}
+	wantErrs := []sc.UnmarshalUnknownVariableError{
+		{Variable: "db_password", Pos: scparse.Pos{Line: 2, Column: 13, Byte: 14}},
+		{Variable: "region", Pos: scparse.Pos{Line: 3, Column: 12, Byte: 40}},
+		{Variable: "region", Pos: scparse.Pos{Line: 5, Column: 19, Byte: 103}},
+	}
+	if len(errs) != len(wantErrs) {
+		t.Fatalf("got %d errors, want %d: %v", len(errs), len(wantErrs), errs)
+	}
+	for i, err := range errs {
+		var unknownErr *sc.UnmarshalUnknownVariableError
+		if !errors.As(err, &unknownErr) || *unknownErr != wantErrs[i] {
+			t.Errorf("got error %#v, want %+v", err, wantErrs[i])
+		}
+	}
+	if got.Host != "localhost" {
+		t.Errorf("got host %q, want default value", got.Host)
+	}
+
+	// No errors once the required variables are provided
+	vars := sc.MustVariables(map[string]interface{}{"db_password": "secret", "region": "eu"})
+	got = Config{}
+	dec := sc.NewDecoder(bytes.NewReader(input), sc.WithVariables(vars), sc.WithRequiredVariables("db_password", "region"))
+	if err := dec.Decode(&got); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	want := Config{Password: "secret", Region: "eu", Host: "localhost", Zone: "eu", Tags: []string{""}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestUnmarshalStrict(t *testing.T) {
+	type Config struct {
+		Name string `sc:"name"`
+		Port int    `sc:"port"`
+	}
+	input := []byte(`{
+		name: ${name}
+		port: ${port}
+		port: 80
+		extra: true
+	}`)
+	vars := sc.MustVariables(map[string]interface{}{"port": 8080.5})
+
+	// Everything is allowed by default
+	if err := sc.Unmarshal(input, &Config{}, sc.WithVariables(vars)); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	wantText := `sc: unknown variable "name"
+sc: cannot unmarshal variable "port" of type float64 into Go struct field Config.port of type int
+sc: 4:3: duplicate key "port", previously defined at 3:3
+sc: unknown field "extra" in Go struct Config`
+	err := sc.Unmarshal(input, &Config{}, sc.WithVariables(vars), sc.WithStrict(true), sc.WithSortErrors(true))
+	if err == nil || err.Error() != wantText {
+		t.Errorf("got error\n\t%v\nwant\n\t%s", err, wantText)
+	}
+
+	// Later options take precedence
+	dec := sc.NewDecoder(bytes.NewReader(input), sc.WithVariables(vars), sc.WithStrict(true))
+	dec.DisallowUnknownFields(false)
+	dec.StrictVariableTypes(false)
+	err = dec.Decode(&Config{})
+	wantText = `sc: 4:3: duplicate key "port", previously defined at 3:3
+sc: unknown variable "name"`
+	if err == nil || err.Error() != wantText {
+		t.Errorf("got error from Decoder\n\t%v\nwant\n\t%s", err, wantText)
+	}
+
+	dec = sc.NewDecoder(bytes.NewReader(input), sc.WithVariables(vars), sc.WithStrict(true), sc.WithStrict(false))
+	if err := dec.Decode(&Config{}); err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestUnmarshalVariableFormatting(t *testing.T) {
+	input := []byte(`{
+		ports: "${ports}"
+		data: "${data}"
+		ratio: "${ratio}"
+	}`)
+	vars := sc.MustVariables(map[string]interface{}{
+		"ports": &portsConfig{Src: 80, Dst: 8080},
+		"data":  []byte("raw"),
+		"ratio": 0.25,
+	})
+
+	var v map[string]string
+	if err := sc.Unmarshal(input, &v, sc.WithVariables(vars)); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	want := map[string]string{"ports": "80:8080", "data": "raw", "ratio": "0.25"}
+	if !reflect.DeepEqual(v, want) {
+		t.Errorf("got unmarshaled value\n\t%#v\nwant\n\t%#v", v, want)
+	}
+
+	formatErr := errors.New("cannot format")
+	formatter := func(name string, v interface{}) (string, error) {
+		if f, ok := v.(float64); ok {
+			return strconv.FormatFloat(f*100, 'f', 0, 64) + "%", nil
+		}
+		if name == "data" {
+			return "", formatErr
+		}
+		return fmt.Sprint(v), nil
+	}
+	v = nil
+	err := sc.Unmarshal(input, &v, sc.WithVariables(vars), sc.WithVariableFormatter(formatter))
+	var errs sc.Errors
+	if !errors.As(err, &errs) {
+		t.Fatalf("got error of type %T, want Errors", err)
+	}
+	if len(errs) != 1 || !errors.Is(errs[0], formatErr) {
+		t.Fatalf("got errors %v, want %v", errs, formatErr)
+	}
+	// The formatter replaces the default formatting entirely
+	want = map[string]string{"ports": "&{80 8080}", "data": "", "ratio": "25%"}
+	if !reflect.DeepEqual(v, want) {
+		t.Errorf("got unmarshaled value\n\t%#v\nwant\n\t%#v", v, want)
+	}
+}
+
+func TestUnmarshalKeepUnknownVariableText(t *testing.T) {
+	input := []byte(`{
+		url: "https://${host}:${port}/${path:-api}"
+		token: ${token}
+		port: ${port}
+		extra: "${missing}"
+	}`)
+	vars := sc.MustVariables(map[string]interface{}{"host": "example.com"})
+
+	var v struct {
+		URL   string      `sc:"url"`
+		Token string      `sc:"token"`
+		Port  int         `sc:"port"`
+		Extra interface{} `sc:"extra"`
+	}
+	err := sc.Unmarshal(input, &v, sc.WithVariables(vars), sc.WithKeepUnknownVariableText(true))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if want := "https://example.com:${port}/api"; v.URL != want {
+		t.Errorf("got url %q, want %q", v.URL, want)
+	}
+	if want := "${token}"; v.Token != want {
+		t.Errorf("got token %q, want %q", v.Token, want)
+	}
+	if v.Port != 0 {
+		t.Errorf("got port %d, want 0", v.Port)
+	}
+	if want := "${missing}"; v.Extra != want {
+		t.Errorf("got extra %#v, want %q", v.Extra, want)
+	}
+
+	// Disallowing unknown variables takes precedence
+	err = sc.Unmarshal(input, &v, sc.WithKeepUnknownVariableText(true), sc.WithDisallowUnknownVariables(true))
+	var errs sc.Errors
+	if !errors.As(err, &errs) {
+		t.Fatalf("got error of type %T, want Errors", err)
+	}
+	var verr *sc.UnmarshalUnknownVariableError
+	if !errors.As(errs[0], &verr) {
+		t.Errorf("got error %v, want %T", errs[0], verr)
+	}
+}
+
+func TestUnmarshalDecodePath(t *testing.T) {
+	input := []byte(`{
+		name: "shared"
+		services: {
+			api: {
+				image: "api:latest"
+				ports: [8080, 8443]
+			}
+		}
+	}`)
+
+	type service struct {
+		Image string `sc:"image"`
+		Ports []int  `sc:"ports"`
+	}
+	var s service
+	if err := sc.Unmarshal(input, &s, sc.WithDecodePath("services.api")); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	want := service{Image: "api:latest", Ports: []int{8080, 8443}}
+	if !reflect.DeepEqual(s, want) {
+		t.Errorf("got %+v, want %+v", s, want)
+	}
+
+	n, err := scparse.Parse(input)
+	if err != nil {
+		t.Fatalf("unexpected parse error %v", err)
+	}
+	var port int
+	if err := sc.UnmarshalNode(n, &port, sc.WithDecodePath("services.api.ports[1]")); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if port != 8443 {
+		t.Errorf("got port %d, want 8443", port)
+	}
+
+	dec := sc.NewDecoder(bytes.NewReader(input))
+	dec.DecodePath("name")
+	var name string
+	if err := dec.Decode(&name); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if name != "shared" {
+		t.Errorf("got name %q, want %q", name, "shared")
+	}
+
+	err = sc.Unmarshal(input, &s, sc.WithDecodePath("services.web"))
+	var pathErr *sc.PathError
+	if !errors.As(err, &pathErr) {
+		t.Fatalf("got error %v, want *sc.PathError", err)
+	}
+	if pathErr.Path != "services.web" {
+		t.Errorf("got error path %q, want %q", pathErr.Path, "services.web")
+	}
+}
+
+func TestUnmarshalErrorControls(t *testing.T) {
+	type V struct {
+		A int    `sc:"a"`
+		C string `sc:"c,required"`
+	}
+	input := []byte(`{
+		a: "one"
+		b: "two"
+		list: [{ a: true }, { a: false }]
+	}`)
+	type L struct {
+		A    int `sc:"a"`
+		B    int `sc:"b"`
+		List []V `sc:"list"`
+	}
+	errorTexts := func(err error) []string {
+		var errs sc.Errors
+		if !errors.As(err, &errs) {
+			t.Fatalf("got error of type %T, want Errors", err)
+		}
+		var texts []string
+		for _, e := range errs {
+			texts = append(texts, e.Error())
+		}
+		return texts
+	}
+
+	tests := []struct {
+		name string
+		opts []sc.UnmarshalOption
+		want []string
+	}{
+		{
+			name: "default",
+			want: []string{
+				"sc: cannot unmarshal InterpolatedString into Go struct field L.a of type int",
+				"sc: cannot unmarshal InterpolatedString into Go struct field L.b of type int",
+				"sc: cannot unmarshal Bool into Go struct field V.list.a of type int",
+				"sc: missing required Go struct field V.list.c",
+				"sc: cannot unmarshal Bool into Go struct field V.list.a of type int",
+				"sc: missing required Go struct field V.list.c",
+			},
+		},
+		{
+			name: "max errors",
+			opts: []sc.UnmarshalOption{sc.WithMaxErrors(3)},
+			want: []string{
+				"sc: cannot unmarshal InterpolatedString into Go struct field L.a of type int",
+				"sc: cannot unmarshal InterpolatedString into Go struct field L.b of type int",
+				"sc: cannot unmarshal Bool into Go struct field V.list.a of type int",
+			},
+		},
+		{
+			name: "sort errors",
+			opts: []sc.UnmarshalOption{sc.WithSortErrors(true), sc.WithMaxErrors(4)},
+			want: []string{
+				"sc: cannot unmarshal InterpolatedString into Go struct field L.a of type int",
+				"sc: cannot unmarshal InterpolatedString into Go struct field L.b of type int",
+				// The dictionary is before its members
+				"sc: missing required Go struct field V.list.c",
+				"sc: cannot unmarshal Bool into Go struct field V.list.a of type int",
+			},
+		},
+		{
+			// The limit is applied after sorting, the required field error is
+			// encountered after V.list.a but it is positioned before it.
+			name: "sort errors before limit",
+			opts: []sc.UnmarshalOption{sc.WithMaxErrors(3), sc.WithSortErrors(true)},
+			want: []string{
+				"sc: cannot unmarshal InterpolatedString into Go struct field L.a of type int",
+				"sc: cannot unmarshal InterpolatedString into Go struct field L.b of type int",
+				"sc: missing required Go struct field V.list.c",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var l L
+			got := errorTexts(sc.Unmarshal(input, &l, tt.opts...))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got errors\n\t%s\nwant\n\t%s", strings.Join(got, "\n\t"), strings.Join(tt.want, "\n\t"))
+			}
+		})
+	}
+}
+
+func TestUnmarshalVariablesMap(t *testing.T) {
+	input := []byte(`{ port: ${port} }`)
+	var v struct {
+		Port int `sc:"port"`
+	}
+	err := sc.Unmarshal(input, &v, sc.WithVariablesMap(map[string]int{"port": 8080}))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if v.Port != 8080 {
+		t.Errorf("got port %d, want 8080", v.Port)
+	}
+
+	// Invalid variables cause an error before unmarshaling
+	v.Port = 0
+	err = sc.Unmarshal(input, &v, sc.WithVariablesMap(map[int]int{1: 2}))
+	wantText := "sc: invalid key type int in variables map"
+	if err == nil || err.Error() != wantText {
+		t.Errorf("got error %v, want %q", err, wantText)
+	}
+	err = sc.UnmarshalNode(&scparse.NullNode{}, &v, sc.WithVariablesMap([]string{"a"}))
+	wantText = "sc: invalid type []string used for variables"
+	if err == nil || err.Error() != wantText {
+		t.Errorf("got error %v, want %q", err, wantText)
+	}
+	if _, err := sc.Resolve(&scparse.NullNode{}, sc.WithVariablesMap(1)); err == nil {
+		t.Error("want error from Resolve, got nil")
+	}
+}
+
+func TestUnmarshalDocumentVariables(t *testing.T) {
+	type config struct {
+		Region string `sc:"region"`
+		Bucket string `sc:"bucket"`
+		Port   int    `sc:"port"`
+	}
+	input := []byte(`{
+  vars: {
+    region: "us-east-1"
+    port: 8080
+    bucket: "assets-${env}"
+  }
+  region: ${region}
+  bucket: "${bucket}-${region}"
+  port: ${port}
+}`)
+	want := config{Region: "us-east-1", Bucket: "assets-prod-us-east-1", Port: 8080}
+	opts := []sc.UnmarshalOption{
+		sc.WithDocumentVariables("vars"),
+		sc.WithVariablesMap(map[string]string{"env": "prod"}),
+		sc.WithDisallowUnknownFields(true),
+	}
+	var got config
+	if err := sc.Unmarshal(input, &got, opts...); err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	if got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	// Provided variables take precedence
+	opts = append(opts, sc.WithVariablesMap(map[string]string{"env": "prod", "region": "eu-west-1"}))
+	want = config{Region: "eu-west-1", Bucket: "assets-prod-eu-west-1", Port: 8080}
+	got = config{}
+	if err := sc.Unmarshal(input, &got, opts...); err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	if got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	err := sc.Unmarshal([]byte(`{ vars: 1, region: "a" }`), &got, sc.WithDocumentVariables("vars"))
+	var errs sc.Errors
+	if !errors.As(err, &errs) || len(errs) != 1 {
+		t.Fatalf("got error %v, want 1 error", err)
+	}
+	var typeErr *sc.UnmarshalTypeError
+	if !errors.As(errs[0], &typeErr) {
+		t.Errorf("got error %T, want *sc.UnmarshalTypeError", errs[0])
+	}
+
+	n, err := scparse.Parse(input)
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	rn, err := sc.Resolve(n, sc.WithDocumentVariables("vars"), sc.WithVariablesMap(map[string]string{"env": "dev"}))
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	wantText := `{
+  region: "us-east-1"
+  bucket: "assets-dev-us-east-1"
+  port: 8080
+}
+`
+	if got := string(scparse.Format(rn.(*scparse.DictionaryNode))); got != wantText {
+		t.Errorf("got resolved\n%s\nwant\n%s", got, wantText)
+	}
+}
+
+func BenchmarkDecoderReset(b *testing.B) {
+	data := []byte(`{ name: "svc-${id}", port: 8080, tags: ["a", "b"], limits: { cpu: 2, memory: 512 } }`)
+	type config struct {
+		Name   string   `sc:"name"`
+		Port   int      `sc:"port"`
+		Tags   []string `sc:"tags"`
+		Limits struct {
+			CPU    int `sc:"cpu"`
+			Memory int `sc:"memory"`
+		} `sc:"limits"`
+	}
+	r := bytes.NewReader(data)
+	dec := sc.NewDecoder(r, sc.WithVariablesMap(map[string]int{"id": 1}))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		r.Reset(data)
+		dec.Reset(r)
+		var c config
+		if err := dec.Decode(&c); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
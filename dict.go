@@ -0,0 +1,127 @@
+// Copyright (c) 2021 the SC authors. All rights reserved. MIT License.
+
+package sc
+
+import (
+	"reflect"
+
+	"github.com/sc-lang/go-sc/scparse"
+)
+
+var dictType = reflect.TypeOf(Dict{})
+
+// DictMember is a member of a Dict.
+type DictMember struct {
+	Key   string
+	Value interface{}
+}
+
+// Dict is a dictionary that preserves the order of its members. It can be used
+// instead of map[string]interface{} when the order of the members of an SC
+// dictionary is meaningful.
+//
+// When unmarshaling into a Dict, the members are stored in the order they appear
+// in the SC dictionary. Values are stored the same way as when unmarshaling into an
+// empty interface, except that nested dictionaries are stored as *Dict instead of
+// map[string]interface{}, so that their order is preserved as well. If a key occurs
+// more than once, the last value is used and the member keeps the position of the first one.
+// When marshaling a Dict, the members are encoded in order.
+//
+// Members are looked up with a linear search, so a Dict is best suited for
+// dictionaries with a small number of members, which most config dictionaries are.
+// The zero value is an empty Dict ready to use.
+type Dict struct {
+	members []DictMember
+}
+
+// NewDict returns a Dict containing members. If a key occurs more than once,
+// the last value is used.
+func NewDict(members ...DictMember) *Dict {
+	d := &Dict{members: make([]DictMember, 0, len(members))}
+	for _, m := range members {
+		d.Set(m.Key, m.Value)
+	}
+	return d
+}
+
+// Len returns the number of members in d.
+func (d *Dict) Len() int {
+	return len(d.members)
+}
+
+// Members returns the members of d in order. The returned slice must not be modified.
+func (d *Dict) Members() []DictMember {
+	return d.members
+}
+
+// Keys returns the keys of d in order.
+func (d *Dict) Keys() []string {
+	keys := make([]string, len(d.members))
+	for i, m := range d.members {
+		keys[i] = m.Key
+	}
+	return keys
+}
+
+func (d *Dict) index(key string) int {
+	for i, m := range d.members {
+		if m.Key == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// Get returns the value of key. ok reports whether d contains key.
+func (d *Dict) Get(key string) (value interface{}, ok bool) {
+	if i := d.index(key); i != -1 {
+		return d.members[i].Value, true
+	}
+	return nil, false
+}
+
+// Set sets the value of key. If d already contains key, the value is replaced
+// and the member keeps its position, otherwise a member is added to the end of d.
+func (d *Dict) Set(key string, value interface{}) {
+	if i := d.index(key); i != -1 {
+		d.members[i].Value = value
+		return
+	}
+	d.members = append(d.members, DictMember{Key: key, Value: value})
+}
+
+// Delete removes key from d. It reports whether d contained key.
+func (d *Dict) Delete(key string) bool {
+	i := d.index(key)
+	if i == -1 {
+		return false
+	}
+	d.members = append(d.members[:i], d.members[i+1:]...)
+	return true
+}
+
+// orderedDictionary is like dictionaryInterface but returns a *Dict.
+func (d *decoder) orderedDictionary(n *scparse.DictionaryNode) *Dict {
+	if d.duplicateKeys == scparse.DuplicateKeysError {
+		d.checkDuplicateKeys(n)
+	}
+	prev := d.orderedDicts
+	d.orderedDicts = true
+	defer func() { d.orderedDicts = prev }()
+	dict := &Dict{members: make([]DictMember, 0, len(n.Members))}
+	for _, mn := range n.Members {
+		dict.Set(mn.Key.KeyString(), d.valueInterface(mn.Value))
+	}
+	return dict
+}
+
+func (e *encoder) encodeDict(v reflect.Value) scparse.ValueNode {
+	members := v.Interface().(Dict).members
+	n := &scparse.DictionaryNode{Members: make([]*scparse.MemberNode, len(members))}
+	for i := range members {
+		// Use the interface value so that nil values are handled
+		mv := reflect.ValueOf(&members[i].Value).Elem()
+		n.Members[i] = e.encodeMember(members[i].Key, mv, e.encodeValue(mv))
+	}
+	return n
+}
@@ -0,0 +1,154 @@
+// Copyright (c) 2021 the SC authors. All rights reserved. MIT License.
+
+package sc_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sc-lang/go-sc"
+)
+
+func TestDict(t *testing.T) {
+	d := sc.NewDict(sc.DictMember{Key: "b", Value: 1}, sc.DictMember{Key: "a", Value: 2}, sc.DictMember{Key: "b", Value: 3})
+	if got, want := d.Keys(), []string{"b", "a"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got keys %v, want %v", got, want)
+	}
+	if v, ok := d.Get("b"); !ok || v != 3 {
+		t.Errorf("got b = %v, %t, want 3, true", v, ok)
+	}
+	d.Set("c", 4)
+	d.Set("a", 5)
+	if !d.Delete("b") {
+		t.Error("expected b to be deleted")
+	}
+	if d.Delete("x") {
+		t.Error("expected x to not be deleted")
+	}
+	want := []sc.DictMember{{Key: "a", Value: 5}, {Key: "c", Value: 4}}
+	if got := d.Members(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got members %v, want %v", got, want)
+	}
+	if _, ok := d.Get("b"); ok || d.Len() != 2 {
+		t.Errorf("got len %d, want 2", d.Len())
+	}
+
+	var zero sc.Dict
+	zero.Set("a", 1)
+	if zero.Len() != 1 {
+		t.Errorf("got len %d, want 1", zero.Len())
+	}
+}
+
+const dictInput = `{
+  z: 1
+  a: {
+    y: "${name}"
+    b: [{ q: true, p: null }]
+  }
+  m: 2.5
+  z: 3
+}
+`
+
+func TestUnmarshalDict(t *testing.T) {
+	want := sc.NewDict(
+		sc.DictMember{Key: "z", Value: 3},
+		sc.DictMember{Key: "a", Value: sc.NewDict(
+			sc.DictMember{Key: "y", Value: "x"},
+			sc.DictMember{Key: "b", Value: []interface{}{
+				sc.NewDict(sc.DictMember{Key: "q", Value: true}, sc.DictMember{Key: "p", Value: nil}),
+			}},
+		)},
+		sc.DictMember{Key: "m", Value: 2.5},
+	)
+	opts := []sc.UnmarshalOption{sc.WithVariables(sc.MustVariables(map[string]string{"name": "x"}))}
+
+	var d sc.Dict
+	if err := sc.Unmarshal([]byte(dictInput), &d, opts...); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(&d, want) {
+		t.Errorf("got %#v, want %#v", d, want)
+	}
+
+	// Dict fields use the same decoding when compiled
+	type config struct {
+		D    *sc.Dict
+		Null sc.Dict
+	}
+	td, err := sc.CompileType(reflect.TypeOf(config{}), opts...)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	c := config{Null: *sc.NewDict(sc.DictMember{Key: "a", Value: 1})}
+	if err := td.Unmarshal([]byte("{D: "+strings.TrimSpace(dictInput)+", Null: null}"), &c); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(c.D, want) {
+		t.Errorf("got %#v, want %#v", c.D, want)
+	}
+	if c.Null.Len() != 0 {
+		t.Errorf("expected null to clear the dict, got %v", c.Null.Members())
+	}
+
+	var m map[string]interface{}
+	if err := sc.Unmarshal([]byte(`{a: {b: 1}}`), &m); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := m["a"].(map[string]interface{}); !ok {
+		t.Errorf("expected maps to be used outside of a Dict, got %T", m["a"])
+	}
+
+	if err := sc.Unmarshal([]byte(`{a: [1]}`), &struct{ A sc.Dict }{}); err == nil {
+		t.Error("expected error unmarshaling a list into a Dict")
+	}
+}
+
+func TestMarshalDict(t *testing.T) {
+	type config struct {
+		D     sc.Dict
+		Empty sc.Dict `sc:",omitempty"`
+	}
+	d := sc.NewDict(
+		sc.DictMember{Key: "z", Value: 1},
+		sc.DictMember{Key: "a", Value: sc.NewDict(sc.DictMember{Key: "y", Value: nil}, sc.DictMember{Key: "b", Value: []int{1}})},
+	)
+	b, err := sc.Marshal(config{D: *d})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := `{
+  D: {
+    z: 1
+    a: {
+      y: null
+      b: [
+        1
+      ]
+    }
+  }
+}
+`
+	if string(b) != want {
+		t.Errorf("got\n%s\nwant\n%s", b, want)
+	}
+
+	// Round trip
+	var got sc.Dict
+	if err := sc.Unmarshal([]byte(dictInput), &got); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	b, err = sc.Marshal(&got)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var again sc.Dict
+	if err := sc.Unmarshal(b, &again); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(got, again) {
+		t.Errorf("round trip changed the dict\ngot  %#v\nwant %#v", again, got)
+	}
+}
@@ -0,0 +1,116 @@
+// Copyright (c) 2021 the SC authors. All rights reserved. MIT License.
+
+package sc_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/sc-lang/go-sc"
+	"github.com/sc-lang/go-sc/scparse"
+)
+
+func TestEdit(t *testing.T) {
+	const input = `// Deployment
+{
+    name: "api",
+    version: 1, // bumped by CI
+    server: {
+        host: "localhost"
+        ports: [
+            80,
+            443,
+        ]
+    },
+
+    tags: [ "a",   "b" ],
+}
+`
+	tests := []struct {
+		name string
+		edit func(n *scparse.DictionaryNode)
+		old  string
+		new  string
+	}{
+		{
+			name: "top level member",
+			edit: func(n *scparse.DictionaryNode) {
+				n.Set("name", scparse.NewString("web"))
+			},
+			old: `name: "api",`,
+			new: `name: "web",`,
+		},
+		{
+			name: "comments are kept",
+			edit: func(n *scparse.DictionaryNode) {
+				v := n.Get("version").(*scparse.NumberNode)
+				v.Raw = "2"
+			},
+			old: "version: 1,",
+			new: "version: 2,",
+		},
+		{
+			name: "nested member",
+			edit: func(n *scparse.DictionaryNode) {
+				n.GetPath("server").(*scparse.DictionaryNode).Set("host", scparse.NewString("0.0.0.0"))
+			},
+			old: `host: "localhost"`,
+			new: `host: "0.0.0.0"`,
+		},
+		{
+			name: "list element",
+			edit: func(n *scparse.DictionaryNode) {
+				ports := n.GetPath("server.ports").(*scparse.ListNode)
+				ports.Elements[1] = &scparse.NumberNode{IsInt: true, Int64: 8443}
+			},
+			old: "443,",
+			new: "8443,",
+		},
+		{
+			name: "multi-line value",
+			edit: func(n *scparse.DictionaryNode) {
+				n.Set("name", scparse.NewDict(scparse.NewMember("first", scparse.NewString("api"))))
+			},
+			old: `name: "api",`,
+			new: "name: {\n        first: \"api\"\n    },",
+		},
+	}
+	indents := []struct {
+		name   string
+		indent string
+	}{
+		{"spaces", "    "},
+		{"tabs", "\t"},
+	}
+	for _, tt := range tests {
+		for _, in := range indents {
+			t.Run(tt.name+"/"+in.name, func(t *testing.T) {
+				// Use the same indentation for the input and the expected output
+				reindent := strings.NewReplacer("    ", in.indent)
+				input := reindent.Replace(input)
+				got, err := sc.Edit([]byte(input), func(n *scparse.DictionaryNode) error {
+					tt.edit(n)
+					return nil
+				})
+				if err != nil {
+					t.Fatalf("unexpected error %v", err)
+				}
+				want := strings.Replace(input, tt.old, reindent.Replace(tt.new), 1)
+				if string(got) != want {
+					t.Errorf("got edited document\n%s\nwant\n%s", got, want)
+				}
+			})
+		}
+	}
+}
+
+func TestEditError(t *testing.T) {
+	editErr := errors.New("edit failed")
+	_, err := sc.Edit([]byte(`{ a: 1 }`), func(n *scparse.DictionaryNode) error {
+		return editErr
+	})
+	if err != editErr {
+		t.Errorf("got error %v, want %v", err, editErr)
+	}
+}
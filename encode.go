@@ -4,25 +4,43 @@ package sc
 
 import (
 	"encoding"
-	"encoding/base64"
 	"fmt"
 	"reflect"
 	"sort"
+	"strconv"
+	"strings"
+	"time"
 	"unicode"
 
 	"github.com/sc-lang/go-sc/scparse"
 )
 
 var (
-	marshalerType     = reflect.TypeOf((*Marshaler)(nil)).Elem()
-	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	marshalerType        = reflect.TypeOf((*Marshaler)(nil)).Elem()
+	textMarshalerType    = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	commentMarshalerType = reflect.TypeOf((*CommentMarshaler)(nil)).Elem()
+	zeroerType           = reflect.TypeOf((*zeroer)(nil)).Elem()
 )
 
 // scError is an error wrapper to distinguish intentional panics.
 type scError struct{ error }
 
 // encoder encodes Go values into SC nodes.
-type encoder struct{}
+type encoder struct {
+	formatOpts scparse.FormatOptions
+	fieldOrder FieldOrder
+	tags       tagConfig
+	// durationStrings encodes time.Duration values as strings instead of numbers
+	durationStrings bool
+	timeFormat      string
+	// emptyCollections encodes nil slices and maps as empty lists and dictionaries instead of null
+	emptyCollections bool
+	// mapKeyLess is used to sort map keys, nil means they are sorted lexicographically
+	mapKeyLess func(a, b string) bool
+	secretMode SecretMode
+	// templateVars encodes fields with the asvar option as variables
+	templateVars bool
+}
 
 // error terminates encoding by panicking with err.
 func (e *encoder) error(err error) {
@@ -34,7 +52,26 @@ func (e *encoder) marshalErrorf(v reflect.Value, format string, args ...interfac
 	e.error(&MarshalError{Value: v, Context: fmt.Sprintf(format, args...)})
 }
 
-func (e *encoder) marshal(v interface{}) (n *scparse.DictionaryNode, err error) {
+// format formats n using the encoder's format options.
+func (e *encoder) format(n *scparse.DictionaryNode) []byte {
+	return scparse.FormatWithOptions(n, e.formatOpts)
+}
+
+func (e *encoder) marshal(v interface{}) (*scparse.DictionaryNode, error) {
+	vn, err := e.marshalValue(v)
+	if err != nil {
+		return nil, err
+	}
+	n, ok := vn.(*scparse.DictionaryNode)
+	if !ok {
+		vv := reflect.ValueOf(v)
+		return nil, &MarshalError{Value: vv, Context: fmt.Sprintf("unsupported type: %T", v)}
+	}
+	return n, nil
+}
+
+// marshalValue is like marshal but allows v to be encoded as any SC value.
+func (e *encoder) marshalValue(v interface{}) (n scparse.ValueNode, err error) {
 	defer func() {
 		if r := recover(); r != nil {
 			if serr, ok := r.(scError); ok {
@@ -46,17 +83,43 @@ func (e *encoder) marshal(v interface{}) (n *scparse.DictionaryNode, err error)
 	}()
 
 	vv := reflect.ValueOf(v)
-	vn := e.encodeValue(vv)
-	var ok bool
-	n, ok = vn.(*scparse.DictionaryNode)
-	if !ok {
-		e.marshalErrorf(vv, "unsupported type: %s", vv.Type())
+	if !vv.IsValid() {
+		return &scparse.NullNode{}, nil
 	}
-	return n, nil
+	return e.encodeValueWithComments(vv), nil
+}
+
+// encodeValueWithComments is like encodeValue but also attaches the comments
+// provided by v if it implements CommentMarshaler.
+func (e *encoder) encodeValueWithComments(v reflect.Value) scparse.ValueNode {
+	n := e.encodeValue(v)
+	if cg, ok := marshalComments(v); ok {
+		addComments(n.Comments(), cg)
+	}
+	return n
+}
+
+// encodeMember creates a member node with key and the encoded value vn of v.
+// Comments provided by v if it implements CommentMarshaler are attached
+// so that head and foot comments surround the whole member.
+func (e *encoder) encodeMember(key string, v reflect.Value, vn scparse.ValueNode) *scparse.MemberNode {
+	m := &scparse.MemberNode{Key: e.encodeKey(key), Value: vn}
+	if cg, ok := marshalComments(v); ok {
+		addComments(m.Key.Comments(), scparse.CommentGroup{Head: cg.Head})
+		addComments(&m.CommentGroup, scparse.CommentGroup{Foot: cg.Foot})
+		addComments(vn.Comments(), scparse.CommentGroup{Inline: cg.Inline, Inner: cg.Inner})
+	}
+	return m
 }
 
 func (e *encoder) encodeValue(v reflect.Value) scparse.ValueNode {
+	if n, ok := e.encodeRegistered(v); ok {
+		return n
+	}
 	t := v.Type()
+	if t == timeType && e.timeFormat != "" {
+		return e.encodeTime(v, e.timeFormat)
+	}
 	if t.Implements(nodeType) {
 		return e.encodeNode(v)
 	}
@@ -76,6 +139,12 @@ func (e *encoder) encodeValue(v reflect.Value) scparse.ValueNode {
 	if t.Implements(textMarshalerType) {
 		return e.encodeTextMarshaler(v)
 	}
+	if t == dictType {
+		return e.encodeDict(v)
+	}
+	if t == durationType && e.durationStrings {
+		return newDoubleString(time.Duration(v.Int()).String())
+	}
 
 	switch v.Kind() {
 	case reflect.Bool:
@@ -84,7 +153,12 @@ func (e *encoder) encodeValue(v reflect.Value) scparse.ValueNode {
 		return &scparse.NumberNode{IsUint: true, Uint64: v.Uint()}
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
 		return &scparse.NumberNode{IsInt: true, Int64: v.Int()}
-	case reflect.Float32, reflect.Float64:
+	case reflect.Float32:
+		// Use the shortest value that round trips as a float32,
+		// ex: 0.1 instead of 0.10000000149011612
+		f, _ := strconv.ParseFloat(strconv.FormatFloat(v.Float(), 'g', -1, 32), 64)
+		return &scparse.NumberNode{IsFloat: true, Float64: f}
+	case reflect.Float64:
 		return &scparse.NumberNode{IsFloat: true, Float64: v.Float()}
 	case reflect.String:
 		return newDoubleString(v.String())
@@ -159,21 +233,18 @@ func (e *encoder) encodeInterfaceOrPtr(v reflect.Value) scparse.ValueNode {
 
 func (e *encoder) encodeArrayOrSlice(v reflect.Value) scparse.ValueNode {
 	if v.Kind() == reflect.Slice {
-		if v.IsNil() {
-			return &scparse.NullNode{}
-		}
 		// []byte is encoded as a base64 string
-		if t := v.Type(); t.Elem().Kind() == reflect.Uint8 {
-			p := reflect.PtrTo(t.Elem())
-			if !p.Implements(marshalerType) && !p.Implements(textMarshalerType) {
-				return newDoubleString(base64.StdEncoding.EncodeToString(v.Bytes()))
-			}
+		if isByteSlice(v.Type()) {
+			return e.encodeBytes(v, bytesBase64)
+		}
+		if v.IsNil() && !e.emptyCollections {
+			return &scparse.NullNode{}
 		}
 	}
 	vlen := v.Len()
 	elements := make([]scparse.ValueNode, vlen)
 	for i := 0; i < vlen; i++ {
-		elements[i] = e.encodeValue(v.Index(i))
+		elements[i] = e.encodeValueWithComments(v.Index(i))
 	}
 	return &scparse.ListNode{Elements: elements}
 }
@@ -183,7 +254,7 @@ func (e *encoder) encodeMap(v reflect.Value) scparse.ValueNode {
 	if kt := v.Type().Key(); kt.Kind() != reflect.String && !kt.Implements(textMarshalerType) {
 		e.marshalErrorf(v, "unsupported map with key type: %s", kt)
 	}
-	if v.IsNil() {
+	if v.IsNil() && !e.emptyCollections {
 		return &scparse.NullNode{}
 	}
 
@@ -198,43 +269,148 @@ func (e *encoder) encodeMap(v reflect.Value) scparse.ValueNode {
 		mapKeys[i] = mk
 	}
 	// Sort the keys. This ensures that encoding is deterministic.
-	sort.Slice(mapKeys, func(i, j int) bool {
-		return mapKeys[i].s < mapKeys[j].s
-	})
+	if less := e.mapKeyLess; less != nil {
+		sort.SliceStable(mapKeys, func(i, j int) bool {
+			return less(mapKeys[i].s, mapKeys[j].s)
+		})
+	} else {
+		sort.Slice(mapKeys, func(i, j int) bool {
+			return mapKeys[i].s < mapKeys[j].s
+		})
+	}
 
 	members := make([]*scparse.MemberNode, len(mapKeys))
 	for i, mk := range mapKeys {
-		vn := e.encodeValue(v.MapIndex(mk.v))
-		members[i] = &scparse.MemberNode{Key: e.encodeKey(mk.s), Value: vn}
+		mv := v.MapIndex(mk.v)
+		members[i] = e.encodeMember(mk.s, mv, e.encodeValue(mv))
 	}
 	return &scparse.DictionaryNode{Members: members}
 }
 
 func (e *encoder) encodeStruct(v reflect.Value) scparse.ValueNode {
-	fields := cachedTypeFields(v.Type())
-	members := make([]*scparse.MemberNode, 0, len(fields.list))
-Loop:
-	for i := range fields.list {
-		f := &fields.list[i]
-		fv := v
-		for _, j := range f.index {
-			if fv.Kind() == reflect.Ptr {
-				if fv.IsNil() {
-					continue Loop
-				}
-				fv = fv.Elem()
-			}
-			fv = fv.Field(j)
+	fields := cachedTypeFields(v.Type(), e.tags)
+	list := fields.list
+	if e.fieldOrder == FieldOrderTag && fields.ordered != nil {
+		list = fields.ordered
+	}
+	members := make([]*scparse.MemberNode, 0, len(list))
+	for i := range list {
+		f := &list[i]
+		fv := fieldByIndex(v, f.index)
+		if !fv.IsValid() {
+			continue
 		}
-		if f.omitEmpty && isEmpty(fv) {
+		if f.omitEmpty && isEmpty(fv) || f.omitNil && isNil(fv) || f.omitZero && isZero(fv) {
 			continue
 		}
-		m := &scparse.MemberNode{Key: e.encodeKey(f.name), Value: e.encodeValue(fv)}
-		members = append(members, m)
+		var vn scparse.ValueNode
+		if f.asVar != "" && e.templateVars {
+			vn = e.encodeVariable(fv, f.asVar)
+		} else if f.secret && e.secretMode != SecretsShown {
+			vn = e.encodeSecret(fv, f.name)
+		} else if f.quoted {
+			vn = e.encodeQuoted(fv)
+		} else if f.timeFormat != "" {
+			vn = e.encodeTime(fv, f.timeFormat)
+		} else if f.bytes != bytesBase64 {
+			vn = e.encodeBytes(fv, f.bytes)
+		} else {
+			vn = e.encodeValue(fv)
+		}
+		members = append(members, e.encodeMember(f.name, fv, vn))
+	}
+	if f := fields.remain; f != nil {
+		// The members of the remain map are encoded as if they were fields.
+		// Fields take precedence over map keys with the same name.
+		if fv := fieldByIndex(v, f.index); fv.IsValid() && !fv.IsNil() {
+			for _, mn := range e.encodeMap(fv).(*scparse.DictionaryNode).Members {
+				if _, ok := fields.nameIndex[mn.Key.KeyString()]; !ok {
+					members = append(members, mn)
+				}
+			}
+		}
+	}
+	if e.fieldOrder == FieldOrderKey {
+		sort.SliceStable(members, func(i, j int) bool {
+			return members[i].Key.KeyString() < members[j].Key.KeyString()
+		})
 	}
 	return &scparse.DictionaryNode{Members: members}
 }
 
+// encodeSecret encodes the value v of a field with the secret option and the key name
+// according to the encoder's SecretMode.
+func (e *encoder) encodeSecret(v reflect.Value, name string) scparse.ValueNode {
+	if e.secretMode == SecretsAsVariables {
+		if !isValidVariableName(name) {
+			e.marshalErrorf(v, "secret field %q is not a valid variable name", name)
+		}
+		return scparse.NewVariable(name)
+	}
+	return newDoubleString(redactedSecret)
+}
+
+// encodeVariable encodes the value v of a field with the asvar option as the variable name.
+func (e *encoder) encodeVariable(v reflect.Value, name string) scparse.ValueNode {
+	if !isValidVariableName(name) {
+		e.marshalErrorf(v, "invalid variable name %q", name)
+	}
+	return scparse.NewVariable(name)
+}
+
+// fieldByIndex returns the field of the struct v with the given index sequence.
+// If an embedded pointer is nil, the invalid value is returned.
+func fieldByIndex(v reflect.Value, index []int) reflect.Value {
+	for _, i := range index {
+		if v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return reflect.Value{}
+			}
+			v = v.Elem()
+		}
+		v = v.Field(i)
+	}
+	return v
+}
+
+// encodeQuoted encodes a value for a field with the "string" tag option.
+// Booleans and numbers are encoded as strings, all other values are encoded normally.
+func (e *encoder) encodeQuoted(v reflect.Value) scparse.ValueNode {
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() || v.Type().Implements(marshalerType) || v.Type().Implements(textMarshalerType) {
+			return e.encodeValue(v)
+		}
+		v = v.Elem()
+	}
+	t := v.Type()
+	if t.Implements(marshalerType) || t.Implements(textMarshalerType) || t == durationType && e.durationStrings {
+		return e.encodeValue(v)
+	}
+	switch v.Kind() {
+	case reflect.Bool:
+		return newDoubleString(strconv.FormatBool(v.Bool()))
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return newDoubleString(strconv.FormatInt(v.Int(), 10))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return newDoubleString(strconv.FormatUint(v.Uint(), 10))
+	case reflect.Float32, reflect.Float64:
+		return newDoubleString(strconv.FormatFloat(v.Float(), 'g', -1, t.Bits()))
+	}
+	return e.encodeValue(v)
+}
+
+// encodeTime encodes v, which is a time.Time or a pointer to one, as a string
+// formatted using layout.
+func (e *encoder) encodeTime(v reflect.Value, layout string) scparse.ValueNode {
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return &scparse.NullNode{}
+		}
+		v = v.Elem()
+	}
+	return newDoubleString(v.Interface().(time.Time).Format(layout))
+}
+
 func (e *encoder) encodeKey(s string) scparse.KeyNode {
 	needsQuote := false
 	for i, r := range s {
@@ -249,6 +425,37 @@ func (e *encoder) encodeKey(s string) scparse.KeyNode {
 	return &scparse.IdentifierNode{Name: s}
 }
 
+// marshalComments returns the comments provided by v if v, or the value it
+// points to, implements CommentMarshaler.
+func marshalComments(v reflect.Value) (scparse.CommentGroup, bool) {
+	for {
+		if (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) && v.IsNil() {
+			return scparse.CommentGroup{}, false
+		}
+		if v.Type().Implements(commentMarshalerType) {
+			return v.Interface().(CommentMarshaler).MarshalSCComments(), true
+		}
+		if v.CanAddr() && reflect.PtrTo(v.Type()).Implements(commentMarshalerType) {
+			return v.Addr().Interface().(CommentMarshaler).MarshalSCComments(), true
+		}
+		if v.Kind() != reflect.Ptr && v.Kind() != reflect.Interface {
+			return scparse.CommentGroup{}, false
+		}
+		v = v.Elem()
+	}
+}
+
+// addComments adds the comments in cg to dst. Head comments are placed
+// before any existing head comments, all others are placed after.
+func addComments(dst *scparse.CommentGroup, cg scparse.CommentGroup) {
+	if len(cg.Head) > 0 {
+		dst.Head = append(append([]scparse.Comment(nil), cg.Head...), dst.Head...)
+	}
+	dst.Inline = append(dst.Inline, cg.Inline...)
+	dst.Foot = append(dst.Foot, cg.Foot...)
+	dst.Inner = append(dst.Inner, cg.Inner...)
+}
+
 type mapKey struct {
 	v reflect.Value
 	s string
@@ -267,7 +474,81 @@ func resolveMapKey(v reflect.Value) (mapKey, error) {
 	return mapKey{v, string(b)}, err
 }
 
+// NaturalLess reports whether a sorts before b in natural order. Strings are
+// compared byte by byte, except that runs of digits are compared by their numeric
+// value, ex: "item2" sorts before "item10". It can be used with WithMapKeyLess.
+func NaturalLess(a, b string) bool {
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		if !isDigit(a[i]) || !isDigit(b[j]) {
+			if a[i] != b[j] {
+				return a[i] < b[j]
+			}
+			i++
+			j++
+			continue
+		}
+		// Compare runs of digits by their value, ignoring leading zeros
+		si, sj := i, j
+		for i < len(a) && isDigit(a[i]) {
+			i++
+		}
+		for j < len(b) && isDigit(b[j]) {
+			j++
+		}
+		da, db := strings.TrimLeft(a[si:i], "0"), strings.TrimLeft(b[sj:j], "0")
+		if len(da) != len(db) {
+			return len(da) < len(db)
+		}
+		if da != db {
+			return da < db
+		}
+	}
+	if ra, rb := len(a)-i, len(b)-j; ra != rb {
+		return ra < rb
+	}
+	// Equal apart from leading zeros, fall back to byte order so that the order is total
+	return a < b
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+// zeroer is the interface implemented by types that can report whether they are
+// the zero value, ex: time.Time. It is used by the omitzero option.
+type zeroer interface {
+	IsZero() bool
+}
+
+// isZero reports whether v is the zero value. If v implements zeroer,
+// its IsZero method is used, otherwise v is compared to the zero value of its type.
+// Nil pointers and interfaces are always zero.
+func isZero(v reflect.Value) bool {
+	switch k := v.Kind(); {
+	case (k == reflect.Ptr || k == reflect.Interface) && v.IsNil():
+		return true
+	case v.Type().Implements(zeroerType):
+		return v.Interface().(zeroer).IsZero()
+	case v.CanAddr() && reflect.PtrTo(v.Type()).Implements(zeroerType):
+		return v.Addr().Interface().(zeroer).IsZero()
+	}
+	return v.IsZero()
+}
+
+// isNil reports whether v is a nil pointer, interface, map or slice.
+func isNil(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Interface, reflect.Map, reflect.Ptr, reflect.Slice:
+		return v.IsNil()
+	}
+	return false
+}
+
 func isEmpty(v reflect.Value) bool {
+	if v.Type() == dictType {
+		return len(v.Interface().(Dict).members) == 0
+	}
 	switch v.Kind() {
 	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
 		return v.Len() == 0
@@ -291,3 +572,21 @@ func newDoubleString(s string) *scparse.InterpolatedStringNode {
 	sn := &scparse.StringNode{Value: s}
 	return &scparse.InterpolatedStringNode{Components: []scparse.StringContentNode{sn}}
 }
+
+// isValidVariableName reports whether name is a valid variable name that
+// can be used in a variable node. Each component of a variable path must start
+// with a letter or underscore followed by letters, digits, or underscores.
+func isValidVariableName(name string) bool {
+	for _, part := range strings.Split(name, ".") {
+		if part == "" {
+			return false
+		}
+		for i, r := range part {
+			if r == '_' || unicode.IsLetter(r) || (i > 0 && unicode.IsDigit(r)) {
+				continue
+			}
+			return false
+		}
+	}
+	return true
+}
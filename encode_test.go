@@ -6,9 +6,11 @@ import (
 	"encoding"
 	"errors"
 	"fmt"
+	"reflect"
 	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/sc-lang/go-sc"
 	"github.com/sc-lang/go-sc/scparse"
@@ -128,6 +130,22 @@ func TestMarshal(t *testing.T) {
   Float32: -1.5
   Float64: 22.22
 }
+`,
+		},
+		{
+			name: "float values",
+			in: struct {
+				Float32 float32
+				Whole   float64
+				Small   float64
+				Large   float64
+			}{0.1, 3, 1e-7, 1e21},
+			want: `{
+  Float32: 0.1
+  Whole: 3
+  Small: 1e-07
+  Large: 1e+21
+}
 `,
 		},
 		{
@@ -269,6 +287,23 @@ lines`},
   Sto: {}
   p: null
 }
+`,
+		},
+		{
+			name: "string option",
+			in: quoted{
+				Port:    8080,
+				Ratio:   0.25,
+				Enabled: true,
+				Name:    "foo",
+			},
+			want: `{
+  Port: "8080"
+  Size: null
+  Ratio: "0.25"
+  Enabled: "true"
+  Name: "foo"
+}
 `,
 		},
 		{
@@ -381,6 +416,35 @@ lines`},
     x: 15
   }
 }
+`,
+		},
+		{
+			name: "inline",
+			in: Inline{
+				Name:    "app",
+				Server:  Server{Host: "localhost", Port: 80},
+				Limits:  &Limits{Memory: 256},
+				Port:    8080,
+				Ignored: 1,
+			},
+			want: `{
+  Name: "app"
+  Host: "localhost"
+  Memory: 256
+  Port: 8080
+  Ignored: 1
+}
+`,
+		},
+		{
+			name: "inline nil pointer",
+			in:   Inline{Name: "app"},
+			want: `{
+  Name: "app"
+  Host: ""
+  Port: 0
+  Ignored: 0
+}
 `,
 		},
 		{
@@ -488,6 +552,804 @@ lines`},
 	}
 }
 
+func TestMarshalVar(t *testing.T) {
+	in := struct {
+		Host  string
+		Port  sc.Var
+		Tags  []sc.Var
+		Env   map[string]sc.Var
+		Proxy *sc.Var
+	}{
+		Host:  "localhost",
+		Port:  sc.Var("port"),
+		Tags:  []sc.Var{"tag", "server.tag"},
+		Env:   map[string]sc.Var{"HOME": "home"},
+		Proxy: nil,
+	}
+	want := `{
+  Host: "localhost"
+  Port: ${port}
+  Tags: [
+    ${tag}
+    ${server.tag}
+  ]
+  Env: {
+    HOME: ${home}
+  }
+  Proxy: null
+}
+`
+	b, err := sc.Marshal(in)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if got := string(b); got != want {
+		t.Errorf("got marshaled value\n\t%#v\nwant\n\t%#v", got, want)
+	}
+
+	// The output should be valid SC that refers to the variables.
+	var out struct {
+		Port int
+		Tags []string
+	}
+	vars := sc.MustVariables(map[string]interface{}{
+		"port":   8080,
+		"tag":    "a",
+		"server": map[string]string{"tag": "b"},
+		"home":   "/root",
+	})
+	if err := sc.Unmarshal(b, &out, sc.WithVariables(vars)); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if out.Port != 8080 || !reflect.DeepEqual(out.Tags, []string{"a", "b"}) {
+		t.Errorf("got unmarshaled value %+v", out)
+	}
+}
+
+func TestMarshalNode(t *testing.T) {
+	tests := []struct {
+		name string
+		in   interface{}
+		want scparse.ValueNode
+	}{
+		{"nil", nil, &scparse.NullNode{}},
+		{"number", 10, &scparse.NumberNode{IsInt: true, Int64: 10}},
+		{"bool", true, &scparse.BoolNode{True: true}},
+		{
+			"list",
+			[]interface{}{false, uint(2)},
+			&scparse.ListNode{Elements: []scparse.ValueNode{
+				&scparse.BoolNode{True: false},
+				&scparse.NumberNode{IsUint: true, Uint64: 2},
+			}},
+		},
+		{"variable", sc.Var("foo"), &scparse.VariableNode{Identifier: &scparse.IdentifierNode{Name: "foo"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := sc.MarshalNode(tt.in)
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got node\n\t%#v\nwant\n\t%#v", got, tt.want)
+			}
+		})
+	}
+
+	_, err := sc.MarshalNode(make(chan bool))
+	var merr *sc.MarshalError
+	if !errors.As(err, &merr) {
+		t.Errorf("got error of type %T, want %T", err, merr)
+	}
+}
+
+type documented struct {
+	Value int
+}
+
+func (d documented) MarshalSCComments() scparse.CommentGroup {
+	return scparse.CommentGroup{
+		Head:   []scparse.Comment{{Text: " A documented value"}},
+		Inline: []scparse.Comment{{Text: " inline"}},
+	}
+}
+
+type port int
+
+func (p *port) MarshalSCComments() scparse.CommentGroup {
+	return scparse.CommentGroup{Inline: []scparse.Comment{{Text: " port number"}}}
+}
+
+func TestMarshalCommentMarshaler(t *testing.T) {
+	type config struct {
+		Doc   documented
+		Ptr   *documented
+		Nil   *documented
+		Port  port
+		Items []documented
+	}
+	in := &config{
+		Doc:   documented{1},
+		Ptr:   &documented{2},
+		Port:  8080,
+		Items: []documented{{3}},
+	}
+	want := `{
+  // A documented value
+  Doc: {
+    Value: 1
+  } // inline
+  // A documented value
+  Ptr: {
+    Value: 2
+  } // inline
+  Nil: null
+  Port: 8080 // port number
+  Items: [
+    // A documented value
+    {
+      Value: 3
+    } // inline
+  ]
+}
+`
+	b, err := sc.Marshal(in)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if got := string(b); got != want {
+		t.Errorf("got marshaled value\n\t%#v\nwant\n\t%#v", got, want)
+	}
+}
+
+func TestMarshalFormatOptions(t *testing.T) {
+	in := map[string]interface{}{"name": "foo", "list": []int{1}}
+	opts := scparse.FormatOptions{Indent: "\t", Newline: "\r\n"}
+	want := "{\r\n\tlist: [\r\n\t\t1\r\n\t]\r\n\tname: \"foo\"\r\n}\r\n"
+	b, err := sc.Marshal(in, sc.WithFormatOptions(opts))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if got := string(b); got != want {
+		t.Errorf("got marshaled value\n\t%#v\nwant\n\t%#v", got, want)
+	}
+
+	var buf strings.Builder
+	enc := sc.NewEncoder(&buf)
+	enc.FormatOptions(opts)
+	if err := enc.Encode(in); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if got := buf.String(); got != want {
+		t.Errorf("got encoded value\n\t%#v\nwant\n\t%#v", got, want)
+	}
+}
+
+func TestMarshalFloatFormat(t *testing.T) {
+	in := map[string]float64{"a": 22.22, "b": 1e-7, "c": 1e21}
+	tests := []struct {
+		format scparse.FloatFormat
+		want   string
+	}{
+		{scparse.FloatAuto, "{\n  a: 22.22\n  b: 1e-07\n  c: 1e+21\n}\n"},
+		{scparse.FloatFixed, "{\n  a: 22.22\n  b: 0.0000001\n  c: 1000000000000000000000.0\n}\n"},
+		{scparse.FloatExponent, "{\n  a: 2.222e+01\n  b: 1e-07\n  c: 1e+21\n}\n"},
+	}
+	for _, tt := range tests {
+		b, err := sc.Marshal(in, sc.WithFormatOptions(scparse.FormatOptions{FloatFormat: tt.format}))
+		if err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		if got := string(b); got != tt.want {
+			t.Errorf("got marshaled value\n\t%#v\nwant\n\t%#v", got, tt.want)
+		}
+		// All formats must round trip
+		var out map[string]float64
+		if err := sc.Unmarshal(b, &out); err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+		if !reflect.DeepEqual(out, in) {
+			t.Errorf("got round tripped value %v, want %v", out, in)
+		}
+	}
+}
+
+func TestMarshalRemain(t *testing.T) {
+	type Plugin struct {
+		Name  string                 `sc:"name"`
+		Extra map[string]interface{} `sc:",remain"`
+	}
+	in := Plugin{Name: "foo", Extra: map[string]interface{}{"b": 2, "a": 1, "name": "ignored"}}
+	b, err := sc.Marshal(in)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	want := "{\n  name: \"foo\"\n  a: 1\n  b: 2\n}\n"
+	if got := string(b); got != want {
+		t.Errorf("got marshaled value\n\t%#v\nwant\n\t%#v", got, want)
+	}
+
+	var out Plugin
+	if err := sc.Unmarshal(b, &out); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	wantOut := Plugin{Name: "foo", Extra: map[string]interface{}{"a": 1, "b": 2}}
+	if !reflect.DeepEqual(out, wantOut) {
+		t.Errorf("got round tripped value %+v, want %+v", out, wantOut)
+	}
+}
+
+func TestMarshalTagName(t *testing.T) {
+	type Config struct {
+		Name  string `json:"name"`
+		Port  int    `json:"port,omitempty"`
+		Both  string `sc:"sc_both" json:"json_both"`
+		Plain string
+	}
+	in := Config{Name: "foo", Both: "b", Plain: "p"}
+	tests := []struct {
+		name string
+		opts []sc.MarshalOption
+		want string
+	}{
+		{"default", nil, "{\n  Name: \"foo\"\n  Port: 0\n  sc_both: \"b\"\n  Plain: \"p\"\n}\n"},
+		{"json fallback", []sc.MarshalOption{sc.WithMarshalFallbackToJSONTags(true)}, "{\n  name: \"foo\"\n  sc_both: \"b\"\n  Plain: \"p\"\n}\n"},
+		{"json tag", []sc.MarshalOption{sc.WithMarshalTagName("json")}, "{\n  name: \"foo\"\n  json_both: \"b\"\n  Plain: \"p\"\n}\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := sc.Marshal(in, tt.opts...)
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if got := string(b); got != tt.want {
+				t.Errorf("got marshaled value\n\t%#v\nwant\n\t%#v", got, tt.want)
+			}
+		})
+	}
+
+	var buf strings.Builder
+	enc := sc.NewEncoder(&buf)
+	enc.FallbackToJSONTags(true)
+	if err := enc.Encode(in); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if want := tests[1].want; buf.String() != want {
+		t.Errorf("got encoded value\n\t%#v\nwant\n\t%#v", buf.String(), want)
+	}
+}
+
+func TestMarshalByteEncoding(t *testing.T) {
+	b := []byte{0xfb, 0xff}
+	in := struct {
+		Std  []byte  `sc:"std"`
+		Hex  []byte  `sc:"hex,hex"`
+		URL  []byte  `sc:"url,base64url"`
+		List []byte  `sc:"list,bytelist"`
+		Ptr  *[]byte `sc:"ptr,hex"`
+		Nil  []byte  `sc:"nil,hex"`
+	}{Std: b, Hex: b, URL: b, List: b, Ptr: &b}
+
+	got, err := sc.Marshal(in)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	want := `{
+  std: "+/8="
+  hex: "fbff"
+  url: "-_8"
+  list: [
+    251
+    255
+  ]
+  ptr: "fbff"
+  nil: null
+}
+`
+	if string(got) != want {
+		t.Errorf("got marshaled value\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestMarshalTimeFormat(t *testing.T) {
+	end := time.Date(2021, 3, 5, 0, 0, 0, 0, time.UTC)
+	in := struct {
+		Date    time.Time  `sc:"date,format=2006-01-02"`
+		End     *time.Time `sc:"end,format=2006-01-02"`
+		Missing *time.Time `sc:"missing,format=2006-01-02"`
+		Created time.Time  `sc:"created"`
+	}{
+		Date:    time.Date(2021, 3, 4, 0, 0, 0, 0, time.UTC),
+		End:     &end,
+		Created: time.Date(2021, 3, 4, 10, 30, 0, 0, time.UTC),
+	}
+
+	b, err := sc.Marshal(in)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	want := "{\n  date: \"2021-03-04\"\n  end: \"2021-03-05\"\n  missing: null\n  created: \"2021-03-04T10:30:00Z\"\n}\n"
+	if got := string(b); got != want {
+		t.Errorf("got marshaled value\n\t%#v\nwant\n\t%#v", got, want)
+	}
+
+	b, err = sc.Marshal(in, sc.WithMarshalTimeFormat("02/01/2006 15:04"))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	want = "{\n  date: \"2021-03-04\"\n  end: \"2021-03-05\"\n  missing: null\n  created: \"04/03/2021 10:30\"\n}\n"
+	if got := string(b); got != want {
+		t.Errorf("got marshaled value\n\t%#v\nwant\n\t%#v", got, want)
+	}
+
+	var buf strings.Builder
+	enc := sc.NewEncoder(&buf)
+	enc.TimeFormat("02/01/2006 15:04")
+	if err := enc.Encode(in); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if buf.String() != want {
+		t.Errorf("got encoded value\n\t%#v\nwant\n\t%#v", buf.String(), want)
+	}
+}
+
+func TestMarshalDurationStrings(t *testing.T) {
+	retry := 90 * time.Second
+	in := struct {
+		Timeout time.Duration            `sc:"timeout"`
+		Retry   *time.Duration           `sc:"retry"`
+		Quoted  time.Duration            `sc:"quoted,string"`
+		Backoff map[string]time.Duration `sc:"backoff"`
+	}{30 * time.Second, &retry, time.Hour, map[string]time.Duration{"min": 100 * time.Millisecond}}
+
+	b, err := sc.Marshal(in, sc.WithMarshalDurationStrings(true))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	want := "{\n  timeout: \"30s\"\n  retry: \"1m30s\"\n  quoted: \"1h0m0s\"\n  backoff: {\n    min: \"100ms\"\n  }\n}\n"
+	if got := string(b); got != want {
+		t.Errorf("got marshaled value\n\t%#v\nwant\n\t%#v", got, want)
+	}
+
+	var buf strings.Builder
+	enc := sc.NewEncoder(&buf)
+	enc.DurationStrings(true)
+	if err := enc.Encode(in); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if buf.String() != want {
+		t.Errorf("got encoded value\n\t%#v\nwant\n\t%#v", buf.String(), want)
+	}
+
+	// Durations are numbers by default
+	b, err = sc.Marshal(in)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	want = "{\n  timeout: 30000000000\n  retry: 90000000000\n  quoted: \"3600000000000\"\n  backoff: {\n    min: 100000000\n  }\n}\n"
+	if got := string(b); got != want {
+		t.Errorf("got marshaled value\n\t%#v\nwant\n\t%#v", got, want)
+	}
+}
+
+func TestMarshalEmptyCollections(t *testing.T) {
+	type inner struct {
+		Tags []string `sc:"tags"`
+	}
+	in := struct {
+		Tags    []string          `sc:"tags"`
+		Env     map[string]string `sc:"env"`
+		Data    []byte            `sc:"data"`
+		Key     []byte            `sc:"key,bytelist"`
+		Inner   *inner            `sc:"inner"`
+		List    []inner           `sc:"list"`
+		Omitted []string          `sc:"omitted,omitnil"`
+		Empty   []string          `sc:"empty,omitnil"`
+		Ptr     *int              `sc:"ptr,omitnil"`
+		Any     interface{}       `sc:"any,omitnil"`
+	}{List: []inner{{}}, Empty: []string{}}
+
+	b, err := sc.Marshal(in, sc.WithMarshalEmptyCollections(true))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	want := "{\n  tags: []\n  env: {}\n  data: \"\"\n  key: []\n  inner: null\n  list: [\n    {\n      tags: []\n    }\n  ]\n  empty: []\n}\n"
+	if got := string(b); got != want {
+		t.Errorf("got marshaled value\n\t%#v\nwant\n\t%#v", got, want)
+	}
+
+	var buf strings.Builder
+	enc := sc.NewEncoder(&buf)
+	enc.EmptyCollections(true)
+	if err := enc.Encode(in); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if buf.String() != want {
+		t.Errorf("got encoded value\n\t%#v\nwant\n\t%#v", buf.String(), want)
+	}
+
+	// Nil slices and maps are null by default
+	b, err = sc.Marshal(in)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	want = "{\n  tags: null\n  env: null\n  data: null\n  key: null\n  inner: null\n  list: [\n    {\n      tags: null\n    }\n  ]\n  empty: []\n}\n"
+	if got := string(b); got != want {
+		t.Errorf("got marshaled value\n\t%#v\nwant\n\t%#v", got, want)
+	}
+}
+
+// unset is a number that reports -1 as its zero value.
+type unset int
+
+func (u *unset) IsZero() bool {
+	return *u == -1
+}
+
+func TestMarshalOmitZero(t *testing.T) {
+	type point struct{ X, Y int }
+	type config struct {
+		Created time.Time `sc:"created,omitzero"`
+		Origin  point     `sc:"origin,omitzero"`
+		Size    [2]int    `sc:"size,omitzero"`
+		Port    int       `sc:"port,omitzero"`
+		Tags    []string  `sc:"tags,omitzero"`
+		Limit   unset     `sc:"limit,omitzero"`
+		Ptr     *point    `sc:"ptr,omitzero"`
+		Empty   point     `sc:"empty,omitempty"`
+	}
+	tests := []struct {
+		name string
+		in   config
+		want string
+	}{
+		{
+			name: "zero",
+			in:   config{Limit: -1},
+			want: "{\n  empty: {\n    X: 0\n    Y: 0\n  }\n}\n",
+		},
+		{
+			name: "non-zero",
+			in: config{
+				Created: time.Date(2021, 3, 4, 0, 0, 0, 0, time.UTC),
+				Origin:  point{Y: 1},
+				Size:    [2]int{0, 2},
+				Port:    80,
+				Tags:    []string{},
+				Ptr:     &point{},
+			},
+			want: "{\n  created: \"2021-03-04T00:00:00Z\"\n  origin: {\n    X: 0\n    Y: 1\n  }\n  size: [\n    0\n    2\n  ]\n  port: 80\n  tags: []\n  limit: 0\n  ptr: {\n    X: 0\n    Y: 0\n  }\n  empty: {\n    X: 0\n    Y: 0\n  }\n}\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Use a pointer so that IsZero methods with pointer receivers are used
+			b, err := sc.Marshal(&tt.in)
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if got := string(b); got != tt.want {
+				t.Errorf("got marshaled value\n\t%#v\nwant\n\t%#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMarshalSortKeys(t *testing.T) {
+	type inner struct {
+		Zeta  int
+		Alpha int
+	}
+	in := struct {
+		Name  string
+		Inner inner
+		Age   int `sc:"age"`
+	}{"foo", inner{1, 2}, 10}
+	want := `{
+  Inner: {
+    Alpha: 2
+    Zeta: 1
+  }
+  Name: "foo"
+  age: 10
+}
+`
+	b, err := sc.Marshal(in, sc.WithSortKeys(true))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if got := string(b); got != want {
+		t.Errorf("got marshaled value\n\t%#v\nwant\n\t%#v", got, want)
+	}
+}
+
+func TestMarshalFieldOrder(t *testing.T) {
+	type Base struct {
+		ID      string `sc:"id,order=1"`
+		Created string `sc:"created"`
+	}
+	type config struct {
+		Name  string            `sc:"name,order=2"`
+		Extra map[string]string `sc:",remain"`
+		Port  int               `sc:"port"`
+		Base
+		Kind string `sc:"kind,order=0"`
+	}
+	in := config{
+		Name:  "api",
+		Extra: map[string]string{"alpha": "a"},
+		Port:  80,
+		Base:  Base{ID: "1", Created: "today"},
+		Kind:  "service",
+	}
+	tests := []struct {
+		name  string
+		order sc.FieldOrder
+		want  string
+	}{
+		{
+			name:  "declaration",
+			order: sc.FieldOrderDeclaration,
+			want:  "{\n  name: \"api\"\n  port: 80\n  id: \"1\"\n  created: \"today\"\n  kind: \"service\"\n  alpha: \"a\"\n}\n",
+		},
+		{
+			name:  "key",
+			order: sc.FieldOrderKey,
+			want:  "{\n  alpha: \"a\"\n  created: \"today\"\n  id: \"1\"\n  kind: \"service\"\n  name: \"api\"\n  port: 80\n}\n",
+		},
+		{
+			name:  "tag",
+			order: sc.FieldOrderTag,
+			want:  "{\n  kind: \"service\"\n  id: \"1\"\n  name: \"api\"\n  port: 80\n  created: \"today\"\n  alpha: \"a\"\n}\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := sc.Marshal(in, sc.WithFieldOrder(tt.order))
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if got := string(b); got != tt.want {
+				t.Errorf("got marshaled value\n\t%#v\nwant\n\t%#v", got, tt.want)
+			}
+
+			var buf strings.Builder
+			enc := sc.NewEncoder(&buf)
+			enc.FieldOrder(tt.order)
+			if err := enc.Encode(in); err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if buf.String() != tt.want {
+				t.Errorf("got encoded value\n\t%#v\nwant\n\t%#v", buf.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestMarshalMapKeyLess(t *testing.T) {
+	in := map[string]interface{}{
+		"v10": 1,
+		"v2":  2,
+		"v1":  3,
+		"z": map[string]int{
+			"b": 1,
+			"a": 2,
+		},
+	}
+	reverse := func(a, b string) bool { return a > b }
+	tests := []struct {
+		name string
+		less func(a, b string) bool
+		want string
+	}{
+		{"default", nil, "{\n  v1: 3\n  v10: 1\n  v2: 2\n  z: {\n    a: 2\n    b: 1\n  }\n}\n"},
+		{"natural", sc.NaturalLess, "{\n  v1: 3\n  v2: 2\n  v10: 1\n  z: {\n    a: 2\n    b: 1\n  }\n}\n"},
+		{"reverse", reverse, "{\n  z: {\n    b: 1\n    a: 2\n  }\n  v2: 2\n  v10: 1\n  v1: 3\n}\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := sc.Marshal(in, sc.WithMapKeyLess(tt.less))
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if got := string(b); got != tt.want {
+				t.Errorf("got marshaled value\n\t%#v\nwant\n\t%#v", got, tt.want)
+			}
+
+			var buf strings.Builder
+			enc := sc.NewEncoder(&buf)
+			enc.MapKeyLess(tt.less)
+			if err := enc.Encode(in); err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if buf.String() != tt.want {
+				t.Errorf("got encoded value\n\t%#v\nwant\n\t%#v", buf.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestNaturalLess(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"2", "10", true},
+		{"10", "2", false},
+		{"item2", "item10", true},
+		{"item10", "item2", false},
+		{"a1b2", "a1b10", true},
+		{"a", "b", true},
+		{"a", "a", false},
+		{"a", "a1", true},
+		{"a1", "a", false},
+		{"01", "1", true},
+		{"1", "01", false},
+		{"007", "8", true},
+		{"1a", "1b", true},
+		{"x9", "xa", true},
+		{"", "a", true},
+		{"a", "", false},
+	}
+	for _, tt := range tests {
+		if got := sc.NaturalLess(tt.a, tt.b); got != tt.want {
+			t.Errorf("NaturalLess(%q, %q) = %t, want %t", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestMarshalSecretMode(t *testing.T) {
+	type database struct {
+		User     string  `sc:"user"`
+		Password string  `sc:"password,secret"`
+		Token    *string `sc:"token,secret,omitnil"`
+	}
+	type config struct {
+		Name string            `sc:"name"`
+		DB   database          `sc:"db"`
+		Keys map[string]string `sc:"api_keys,secret"`
+	}
+	in := config{
+		Name: "api",
+		DB:   database{User: "admin", Password: "hunter2"},
+		Keys: map[string]string{"github": "abc"},
+	}
+	tests := []struct {
+		name string
+		mode sc.SecretMode
+		want string
+	}{
+		{
+			name: "shown",
+			mode: sc.SecretsShown,
+			want: "{\n  name: \"api\"\n  db: {\n    user: \"admin\"\n    password: \"hunter2\"\n  }\n  api_keys: {\n    github: \"abc\"\n  }\n}\n",
+		},
+		{
+			name: "redacted",
+			mode: sc.SecretsRedacted,
+			want: "{\n  name: \"api\"\n  db: {\n    user: \"admin\"\n    password: \"***\"\n  }\n  api_keys: \"***\"\n}\n",
+		},
+		{
+			name: "variables",
+			mode: sc.SecretsAsVariables,
+			want: "{\n  name: \"api\"\n  db: {\n    user: \"admin\"\n    password: ${password}\n  }\n  api_keys: ${api_keys}\n}\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := sc.Marshal(in, sc.WithSecretMode(tt.mode))
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if got := string(b); got != tt.want {
+				t.Errorf("got marshaled value\n\t%#v\nwant\n\t%#v", got, tt.want)
+			}
+
+			var buf strings.Builder
+			enc := sc.NewEncoder(&buf)
+			enc.SecretMode(tt.mode)
+			if err := enc.Encode(in); err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if buf.String() != tt.want {
+				t.Errorf("got encoded value\n\t%#v\nwant\n\t%#v", buf.String(), tt.want)
+			}
+		})
+	}
+
+	// The output with variables can be unmarshaled with the secrets provided as variables
+	b, err := sc.Marshal(in, sc.WithSecretMode(sc.SecretsAsVariables))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	var got config
+	vars := sc.MustVariables(map[string]interface{}{"password": "hunter2", "api_keys": map[string]string{"github": "abc"}})
+	if err := sc.Unmarshal(b, &got, sc.WithVariables(vars)); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if !reflect.DeepEqual(got, in) {
+		t.Errorf("got unmarshaled value %+v, want %+v", got, in)
+	}
+
+	// Keys that are not variable names are an error
+	_, err = sc.Marshal(struct {
+		Key string `sc:"api-key,secret"`
+	}{"abc"}, sc.WithSecretMode(sc.SecretsAsVariables))
+	var marshalErr *sc.MarshalError
+	if !errors.As(err, &marshalErr) {
+		t.Fatalf("got error %v, want *sc.MarshalError", err)
+	}
+	if want := `sc: secret field "api-key" is not a valid variable name`; err.Error() != want {
+		t.Errorf("got error %q, want %q", err, want)
+	}
+}
+
+func TestMarshalTemplateVariables(t *testing.T) {
+	type server struct {
+		Host     string   `sc:"host,asvar=server.host"`
+		Port     int      `sc:"port,asvar=port"`
+		Tags     []string `sc:"tags"`
+		Password string   `sc:"password,secret,asvar=db_password"`
+	}
+	in := server{Host: "localhost", Port: 8080, Tags: []string{"a"}, Password: "hunter2"}
+	want := "{\n  host: ${server.host}\n  port: ${port}\n  tags: [\n    \"a\"\n  ]\n  password: ${db_password}\n}\n"
+
+	b, err := sc.Marshal(in, sc.WithTemplateVariables(true), sc.WithSecretMode(sc.SecretsRedacted))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if got := string(b); got != want {
+		t.Errorf("got marshaled value\n\t%#v\nwant\n\t%#v", got, want)
+	}
+
+	var buf strings.Builder
+	enc := sc.NewEncoder(&buf)
+	enc.TemplateVariables(true)
+	if err := enc.Encode(in); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if buf.String() != want {
+		t.Errorf("got encoded value\n\t%#v\nwant\n\t%#v", buf.String(), want)
+	}
+
+	// The template can be unmarshaled with variables
+	var got server
+	vars := sc.MustVariables(map[string]interface{}{
+		"server":      map[string]string{"host": "example.com"},
+		"port":        443,
+		"db_password": "secret",
+	})
+	if err := sc.Unmarshal(b, &got, sc.WithVariables(vars)); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if wantGot := (server{Host: "example.com", Port: 443, Tags: []string{"a"}, Password: "secret"}); !reflect.DeepEqual(got, wantGot) {
+		t.Errorf("got unmarshaled value %+v, want %+v", got, wantGot)
+	}
+
+	// The option is ignored by default
+	b, err = sc.Marshal(in)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	want = "{\n  host: \"localhost\"\n  port: 8080\n  tags: [\n    \"a\"\n  ]\n  password: \"hunter2\"\n}\n"
+	if got := string(b); got != want {
+		t.Errorf("got marshaled value\n\t%#v\nwant\n\t%#v", got, want)
+	}
+
+	// Invalid variable names are an error
+	_, err = sc.Marshal(struct {
+		Port int `sc:"port,asvar=1port"`
+	}{80}, sc.WithTemplateVariables(true))
+	var marshalErr *sc.MarshalError
+	if !errors.As(err, &marshalErr) {
+		t.Fatalf("got error %v, want *sc.MarshalError", err)
+	}
+	if want := `sc: invalid variable name "1port"`; err.Error() != want {
+		t.Errorf("got error %q, want %q", err, want)
+	}
+}
+
 func TestMarshalError(t *testing.T) {
 	tests := []struct {
 		name string
@@ -511,6 +1373,13 @@ func TestMarshalError(t *testing.T) {
 			}{&scparse.IdentifierNode{Name: "key"}},
 			want: "sc: unsupported node type *scparse.IdentifierNode; not a value node",
 		},
+		{
+			name: "invalid variable name",
+			in: struct {
+				Port sc.Var
+			}{sc.Var("1port")},
+			want: `sc: invalid variable name "1port"`,
+		},
 		{
 			name: "map with non-string keys",
 			in: map[int]interface{}{
@@ -554,3 +1423,40 @@ func TestMarshalPanic(t *testing.T) {
 	_, _ = sc.Marshal(&marshalPanic{})
 	t.Error("want Marshal to panic")
 }
+
+func TestEncoder(t *testing.T) {
+	type config struct {
+		Name   string `sc:"name"`
+		Memory int    `sc:"memory"`
+	}
+	var buf strings.Builder
+	enc := sc.NewEncoder(&buf)
+	for _, v := range []config{{"foo", 256}, {"bar", 512}} {
+		if err := enc.Encode(v); err != nil {
+			t.Fatalf("unexpected error %v", err)
+		}
+	}
+	want := `{
+  name: "foo"
+  memory: 256
+}
+{
+  name: "bar"
+  memory: 512
+}
+`
+	if got := buf.String(); got != want {
+		t.Errorf("got encoded value\n\t%#v\nwant\n\t%#v", got, want)
+	}
+
+	// Marshal errors should be returned and nothing written.
+	buf.Reset()
+	err := enc.Encode(make(chan bool))
+	var merr *sc.MarshalError
+	if !errors.As(err, &merr) {
+		t.Errorf("got error of type %T, want %T", err, merr)
+	}
+	if buf.Len() > 0 {
+		t.Errorf("want nothing written on error, got %q", buf.String())
+	}
+}
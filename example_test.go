@@ -53,6 +53,34 @@ func ExampleUnmarshal_variables() {
 	// {Code:145 Path:/home/ted/data}
 }
 
+func ExampleWithVariablesMap() {
+	scData := []byte(`{
+  host: ${HOST}
+  url: "http://${HOST}:${PORT}"
+}`)
+	type Config struct {
+		Host string `sc:"host"`
+		URL  string `sc:"url"`
+	}
+	var config Config
+	env := map[string]string{
+		"HOST": "localhost",
+		"PORT": "8080",
+	}
+	err := sc.Unmarshal(scData, &config, sc.WithVariablesMap(env))
+	if err != nil {
+		fmt.Printf("error: %v\n", err)
+	}
+	fmt.Printf("%+v\n", config)
+
+	// Invalid maps are reported by Unmarshal
+	err = sc.Unmarshal(scData, &config, sc.WithVariablesMap(map[int]string{}))
+	fmt.Println(err)
+	// Output:
+	// {Host:localhost URL:http://localhost:8080}
+	// sc: invalid key type int in variables map
+}
+
 func ExampleUnmarshalNode() {
 	scData := []byte(`{
   name: "test"
@@ -114,3 +142,91 @@ func ExampleMarshal() {
 	//   required: true
 	// }
 }
+
+func ExampleMarshalNode() {
+	type Config struct {
+		Name   string `sc:"name"`
+		Memory int    `sc:"memory"`
+	}
+	n, err := sc.MarshalNode(Config{Name: "foo", Memory: 256})
+	if err != nil {
+		fmt.Printf("error: %v\n", err)
+	}
+	// Document the memory field before formatting
+	dict := n.(*scparse.DictionaryNode)
+	dict.Members[1].Comments().Head = []scparse.Comment{{Text: " Memory in MB"}}
+	fmt.Printf("%s\n", scparse.Format(dict))
+
+	// Output:
+	// {
+	//   name: "foo"
+	//   // Memory in MB
+	//   memory: 256
+	// }
+}
+
+func ExampleEdit() {
+	scData := []byte(`{
+  // The deployed version
+  version: 1,  name: "foo"
+  memory:   256
+}`)
+	b, err := sc.Edit(scData, func(n *scparse.DictionaryNode) error {
+		n.Members[0].Value = &scparse.NumberNode{IsInt: true, Int64: 2}
+		return nil
+	})
+	if err != nil {
+		fmt.Printf("error: %v\n", err)
+	}
+	fmt.Printf("%s\n", b)
+
+	// Output:
+	// {
+	//   // The deployed version
+	//   version: 2,  name: "foo"
+	//   memory:   256
+	// }
+}
+
+func ExampleListVariables() {
+	scData := []byte(`{
+  code: ${id}
+  path: "/home/${user}/data"
+  owner: ${user}
+}`)
+	refs, err := sc.ListVariables(scData)
+	if err != nil {
+		fmt.Printf("error: %v\n", err)
+	}
+	for _, ref := range refs {
+		fmt.Printf("%s: %d reference(s)\n", ref.Name, len(ref.Positions))
+	}
+
+	// Output:
+	// id: 1 reference(s)
+	// user: 2 reference(s)
+}
+
+func ExampleFormatError() {
+	scData := []byte(`{
+  name: "foo"
+  port: "80"
+}`)
+	type Config struct {
+		Name string `sc:"name"`
+		Port int    `sc:"port"`
+	}
+	var c Config
+	err := sc.Unmarshal(scData, &c)
+	if err != nil {
+		fmt.Println(sc.FormatError(scData, err))
+	}
+
+	// Output:
+	// sc: cannot unmarshal InterpolatedString into Go struct field Config.port of type int
+	//   1 | {
+	//   2 |   name: "foo"
+	// > 3 |   port: "80"
+	//     |         ^
+	//   4 | }
+}
@@ -5,8 +5,10 @@ package sc
 import (
 	"reflect"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 )
 
 // Struct field handling is adapted from code in encoding/json.
@@ -16,11 +18,21 @@ import (
 
 // A field represents a single field found in a struct.
 type field struct {
-	name      string
-	tag       bool  // whether the field has a `sc` tag
-	index     []int // represents the depth of an anonymous field
-	typ       reflect.Type
-	omitEmpty bool
+	name       string
+	tag        bool  // whether the field has a `sc` tag
+	index      []int // represents the depth of an anonymous field
+	typ        reflect.Type
+	omitEmpty  bool
+	omitNil    bool
+	omitZero   bool
+	secret     bool         // whether the value is replaced based on the encoder's SecretMode
+	asVar      string       // name of the variable used for templates, empty if not set
+	quoted     bool         // whether the value should be encoded as a string
+	required   bool         // whether the field must be present when decoding
+	timeFormat string       // layout used for time.Time values, empty if not set
+	bytes      byteEncoding // encoding used for []byte values
+	order      int          // position set by the order option, only used if hasOrder is true
+	hasOrder   bool
 }
 
 // byIndex sorts field by index sequence.
@@ -47,14 +59,40 @@ func (x byIndex) Less(i, j int) bool {
 }
 
 type structFields struct {
-	list      []field
-	nameIndex map[string]int
+	list        []field
+	nameIndex   map[string]int
+	hasRequired bool   // whether any field in list is required
+	remain      *field // field with the remain option, nil if there is none
+	// ordered is list sorted by the order option, nil if no field has the option
+	ordered []field
+	// sourceInfo is the first field of type SourceInfo, nil if there is none
+	sourceInfo *field
+}
+
+// tagConfig controls which struct tags are used to find the SC fields of a struct.
+// The zero value uses the sc tag.
+type tagConfig struct {
+	name         string // name of the tag, "sc" if empty
+	fallbackJSON bool   // use the json tag for fields without a tag
+}
+
+// lookup returns the tag for the struct field sf.
+func (c tagConfig) lookup(sf reflect.StructField) string {
+	name := c.name
+	if name == "" {
+		name = "sc"
+	}
+	tag, ok := sf.Tag.Lookup(name)
+	if !ok && c.fallbackJSON {
+		tag = sf.Tag.Get("json")
+	}
+	return tag
 }
 
 // typeFields returns a list of fields that SC should recognize for the given type.
 // The algorithm is breadth-first search over the set of structs to include - the
 // top struct and then any reachable anonymous structs.
-func typeFields(t reflect.Type) structFields {
+func typeFields(t reflect.Type, tags tagConfig) structFields {
 	// Anonymous fields to explore at the current level and the next.
 	current := []field{}
 	next := []field{{typ: t}}
@@ -67,6 +105,10 @@ func typeFields(t reflect.Type) structFields {
 
 	// Fields found.
 	var fields []field
+	// The first field with the remain option.
+	var remain *field
+	// The first field of type SourceInfo.
+	var sourceInfo *field
 
 	for len(next) > 0 {
 		current, next = next, current[:0]
@@ -97,7 +139,7 @@ func typeFields(t reflect.Type) structFields {
 					// Ignore unexported non-embedded fields.
 					continue
 				}
-				tag := sf.Tag.Get("sc")
+				tag := tags.lookup(sf)
 				if tag == "-" {
 					continue
 				}
@@ -106,24 +148,85 @@ func typeFields(t reflect.Type) structFields {
 				copy(index, f.index)
 				index[len(f.index)] = i
 
+				// Fields with the remain option hold the members that do not
+				// match any other field, they are not matched by name.
+				if opts.Contains("remain") && isRemainType(sf.Type) {
+					if remain == nil {
+						remain = &field{name: sf.Name, index: index, typ: sf.Type}
+					}
+					continue
+				}
+
+				// SourceInfo fields are set to the positions of the dictionary,
+				// they are not matched by name.
+				if sf.Type == sourceInfoType {
+					if sourceInfo == nil {
+						sourceInfo = &field{name: sf.Name, index: index, typ: sf.Type}
+					}
+					continue
+				}
+
 				ft := sf.Type
 				if ft.Name() == "" && ft.Kind() == reflect.Ptr {
 					// Follow pointer.
 					ft = ft.Elem()
 				}
 
+				// Inlined structs are treated like anonymous structs, their fields
+				// are promoted regardless of the name of the field.
+				inline := opts.Contains("inline") && ft.Kind() == reflect.Struct
+
 				// Record found field and index sequence.
-				if name != "" || !sf.Anonymous || ft.Kind() != reflect.Struct {
+				if !inline && (name != "" || !sf.Anonymous || ft.Kind() != reflect.Struct) {
 					tagged := name != ""
 					if name == "" {
 						name = sf.Name
 					}
+					// Only floats, integers, and booleans can be quoted.
+					quoted := false
+					if opts.Contains("string") {
+						switch ft.Kind() {
+						case reflect.Bool,
+							reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+							reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+							reflect.Float32, reflect.Float64:
+							quoted = true
+						}
+					}
+					// Only time.Time values can have a format.
+					var timeFormat string
+					if ft == timeType {
+						timeFormat, _ = opts.Value("format")
+						if layout, ok := namedTimeLayouts[timeFormat]; ok {
+							timeFormat = layout
+						}
+					}
+					// Only []byte values can have a byte encoding.
+					bytes := bytesBase64
+					if isByteSlice(ft) {
+						bytes = byteEncodingOption(opts)
+					}
+					asVar, _ := opts.Value("asvar")
+					// Invalid orders are ignored, scvet reports them.
+					orderValue, _ := opts.Value("order")
+					order, err := strconv.Atoi(orderValue)
+					hasOrder := err == nil
 					field := field{
-						name:      name,
-						tag:       tagged,
-						index:     index,
-						typ:       ft,
-						omitEmpty: opts.Contains("omitempty"),
+						name:       name,
+						tag:        tagged,
+						index:      index,
+						typ:        ft,
+						omitEmpty:  opts.Contains("omitempty"),
+						omitNil:    opts.Contains("omitnil"),
+						omitZero:   opts.Contains("omitzero"),
+						secret:     opts.Contains("secret"),
+						asVar:      asVar,
+						quoted:     quoted,
+						required:   opts.Contains("required"),
+						timeFormat: timeFormat,
+						bytes:      bytes,
+						order:      order,
+						hasOrder:   hasOrder,
 					}
 					fields = append(fields, field)
 					if count[f.typ] > 1 {
@@ -194,10 +297,36 @@ func typeFields(t reflect.Type) structFields {
 	sort.Sort(byIndex(fields))
 
 	nameIndex := make(map[string]int, len(fields))
+	hasRequired := false
+	hasOrder := false
 	for i, field := range fields {
 		nameIndex[field.name] = i
+		if field.required {
+			hasRequired = true
+		}
+		if field.hasOrder {
+			hasOrder = true
+		}
 	}
-	return structFields{fields, nameIndex}
+	var ordered []field
+	if hasOrder {
+		// Fields with an order come first, the rest keep their declaration order.
+		ordered = append([]field(nil), fields...)
+		sort.SliceStable(ordered, func(i, j int) bool {
+			x, y := ordered[i], ordered[j]
+			if x.hasOrder != y.hasOrder {
+				return x.hasOrder
+			}
+			return x.hasOrder && x.order < y.order
+		})
+	}
+	return structFields{fields, nameIndex, hasRequired, remain, ordered, sourceInfo}
+}
+
+// isRemainType reports whether t can be used for a field with the remain option.
+// It must be a map with string keys.
+func isRemainType(t reflect.Type) bool {
+	return t.Kind() == reflect.Map && t.Key().Kind() == reflect.String
 }
 
 // dominantField looks through the fields, all of which are known to
@@ -216,14 +345,42 @@ func dominantField(fields []field) (field, bool) {
 	return fields[0], true
 }
 
-var fieldCache sync.Map // map[reflect.Type]structFields
+// namedTimeLayouts are the layouts of the time package that can be used by name in
+// the format tag option, ex: format=RFC1123. This allows using layouts that contain
+// commas, which cannot be written in a tag since commas separate the options.
+var namedTimeLayouts = map[string]string{
+	"ANSIC":       time.ANSIC,
+	"UnixDate":    time.UnixDate,
+	"RubyDate":    time.RubyDate,
+	"RFC822":      time.RFC822,
+	"RFC822Z":     time.RFC822Z,
+	"RFC850":      time.RFC850,
+	"RFC1123":     time.RFC1123,
+	"RFC1123Z":    time.RFC1123Z,
+	"RFC3339":     time.RFC3339,
+	"RFC3339Nano": time.RFC3339Nano,
+	"Kitchen":     time.Kitchen,
+	"Stamp":       time.Stamp,
+	"StampMilli":  time.StampMilli,
+	"StampMicro":  time.StampMicro,
+	"StampNano":   time.StampNano,
+}
+
+// fieldCacheKey is the key used for fieldCache.
+type fieldCacheKey struct {
+	t    reflect.Type
+	tags tagConfig
+}
+
+var fieldCache sync.Map // map[fieldCacheKey]structFields
 
 // cachedTypeFields is like typeFields but uses a cache to avoid repeated work.
-func cachedTypeFields(t reflect.Type) structFields {
-	if f, ok := fieldCache.Load(t); ok {
+func cachedTypeFields(t reflect.Type, tags tagConfig) structFields {
+	key := fieldCacheKey{t, tags}
+	if f, ok := fieldCache.Load(key); ok {
 		return f.(structFields)
 	}
-	f, _ := fieldCache.LoadOrStore(t, typeFields(t))
+	f, _ := fieldCache.LoadOrStore(key, typeFields(t, tags))
 	return f.(structFields)
 }
 
@@ -263,3 +420,21 @@ func (o tagOptions) Contains(optionName string) bool {
 	}
 	return false
 }
+
+// Value returns the value of an option of the form name=value in a
+// comma-separated list of options and whether the option was found.
+func (o tagOptions) Value(optionName string) (string, bool) {
+	s := string(o)
+	for s != "" {
+		var next string
+		i := strings.Index(s, ",")
+		if i >= 0 {
+			s, next = s[:i], s[i+1:]
+		}
+		if strings.HasPrefix(s, optionName+"=") {
+			return s[len(optionName)+1:], true
+		}
+		s = next
+	}
+	return "", false
+}
@@ -0,0 +1,40 @@
+// Copyright (c) 2021 the SC authors. All rights reserved. MIT License.
+
+//go:build go1.18
+// +build go1.18
+
+package sc
+
+import (
+	"reflect"
+
+	"github.com/sc-lang/go-sc/scparse"
+)
+
+// Decode is like Unmarshal but it returns the unmarshaled value instead of
+// storing it in a value provided by the caller.
+//
+// See the documentation for Unmarshal for details on the unmarshal process.
+// If a non-nil error is returned, the result may be partially unmarshaled.
+func Decode[T any](data []byte, opts ...UnmarshalOption) (T, error) {
+	var v T
+	err := Unmarshal(data, &v, opts...)
+	return v, err
+}
+
+// DecodeNode is like UnmarshalNode but it returns the unmarshaled value instead of
+// storing it in a value provided by the caller.
+//
+// See the documentation for Unmarshal for details on the unmarshal process.
+// If a non-nil error is returned, the result may be partially unmarshaled.
+func DecodeNode[T any](n scparse.ValueNode, opts ...UnmarshalOption) (T, error) {
+	var v T
+	err := UnmarshalNode(n, &v, opts...)
+	return v, err
+}
+
+// NewTypeDecoder returns a TypeDecoder that decodes values of type T.
+// It is equivalent to calling CompileType with the type of T.
+func NewTypeDecoder[T any](opts ...UnmarshalOption) (*TypeDecoder, error) {
+	return CompileType(reflect.TypeOf((*T)(nil)).Elem(), opts...)
+}
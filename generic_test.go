@@ -0,0 +1,79 @@
+// Copyright (c) 2021 the SC authors. All rights reserved. MIT License.
+
+//go:build go1.18
+// +build go1.18
+
+package sc_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/sc-lang/go-sc"
+	"github.com/sc-lang/go-sc/scparse"
+)
+
+func TestDecode(t *testing.T) {
+	type Config struct {
+		Name  string   `sc:"name"`
+		Ports []int    `sc:"ports"`
+		Tags  []string `sc:"tags"`
+	}
+	c, err := sc.Decode[Config]([]byte(`{ name: "api", ports: [80, ${port}] }`),
+		sc.WithVariablesMap(map[string]int{"port": 443}))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	want := Config{Name: "api", Ports: []int{80, 443}}
+	if !reflect.DeepEqual(c, want) {
+		t.Errorf("got %+v, want %+v", c, want)
+	}
+
+	m, err := sc.Decode[map[string]interface{}]([]byte(`{ a: 1 }`))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if !reflect.DeepEqual(m, map[string]interface{}{"a": 1}) {
+		t.Errorf("got %#v, want map with a: 1", m)
+	}
+
+	_, err = sc.Decode[Config]([]byte(`{ name: 1 }`))
+	var errs sc.Errors
+	if !errors.As(err, &errs) {
+		t.Errorf("got error %v, want Errors", err)
+	}
+}
+
+func TestDecodeNode(t *testing.T) {
+	n := scparse.NewList(scparse.NewInt(1), scparse.NewInt(2))
+	got, err := sc.DecodeNode[[]uint8](n)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if want := []uint8{1, 2}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestNewTypeDecoder(t *testing.T) {
+	type Config struct {
+		Name string `sc:"name"`
+		Port int    `sc:"port"`
+	}
+	td, err := sc.NewTypeDecoder[Config](sc.WithVariablesMap(map[string]int{"port": 8080}))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if td.Type() != reflect.TypeOf(Config{}) {
+		t.Errorf("got type %v, want Config", td.Type())
+	}
+	var c Config
+	if err := td.Unmarshal([]byte(`{ name: "api", port: ${port} }`), &c); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	want := Config{Name: "api", Port: 8080}
+	if c != want {
+		t.Errorf("got %+v, want %+v", c, want)
+	}
+}
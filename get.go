@@ -0,0 +1,43 @@
+// Copyright (c) 2021 the SC authors. All rights reserved. MIT License.
+
+package sc
+
+import (
+	"github.com/sc-lang/go-sc/scparse"
+)
+
+// Get parses the SC-encoded data, finds the value at path, and stores the result in
+// the value pointed to by v. Only the value at path is unmarshaled, the rest of the
+// document is ignored.
+//
+// Paths consist of keys separated by dots and list indices in brackets,
+// ex: services.api.ports[0].src. Keys that are not identifiers must be double quoted,
+// ex: headers."content-type". An empty path refers to the whole document.
+//
+// If the value cannot be found, Get returns a *PathError.
+// See the documentation for Unmarshal for details on the unmarshal process.
+//
+// Get is equivalent to calling Unmarshal with WithDecodePath(path).
+func Get(data []byte, path string, v interface{}, opts ...UnmarshalOption) error {
+	opts = append(opts[:len(opts):len(opts)], WithDecodePath(path))
+	return Unmarshal(data, v, opts...)
+}
+
+// GetNode is like Get but it returns the node at path instead of unmarshaling it.
+// See the documentation for Get for the path format.
+// Anchors and aliases are expanded before the path is looked up, see scparse.ExpandAnchors.
+func GetNode(data []byte, path string, opts ...scparse.ParseOption) (scparse.ValueNode, error) {
+	elems, err := parsePath(path)
+	if err != nil {
+		return nil, err
+	}
+	dn, err := scparse.Parse(data, opts...)
+	if err != nil {
+		return nil, err
+	}
+	n, err := scparse.ExpandAnchors(dn)
+	if err != nil {
+		return nil, err
+	}
+	return lookupNode(n, elems)
+}
@@ -0,0 +1,107 @@
+// Copyright (c) 2021 the SC authors. All rights reserved. MIT License.
+
+package sc_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/sc-lang/go-sc"
+	"github.com/sc-lang/go-sc/scparse"
+)
+
+var getInput = []byte(`{
+  services: {
+    api: {
+      ports: [
+        { src: 8080, dst: 80 }
+        { src: 8443, dst: 443 }
+      ]
+      image: ${image:-"api:latest"}
+    }
+  }
+  "content-type": "json"
+}`)
+
+func TestGet(t *testing.T) {
+	var port int
+	if err := sc.Get(getInput, "services.api.ports[1].src", &port); err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if port != 8443 {
+		t.Errorf("got port %d, want 8443", port)
+	}
+
+	vars, err := sc.NewVariables(map[string]string{"image": "api:v2"})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	var image string
+	err = sc.Get(getInput, "services.api.image", &image, sc.WithVariables(vars))
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if image != "api:v2" {
+		t.Errorf("got image %q, want %q", image, "api:v2")
+	}
+
+	var ct string
+	if err := sc.Get(getInput, `"content-type"`, &ct); err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if ct != "json" {
+		t.Errorf("got content-type %q, want %q", ct, "json")
+	}
+}
+
+func TestGetNode(t *testing.T) {
+	n, err := sc.GetNode(getInput, "services.api.ports[0]")
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	dn, ok := n.(*scparse.DictionaryNode)
+	if !ok {
+		t.Fatalf("got node type %T, want *scparse.DictionaryNode", n)
+	}
+	if got := dn.Get("dst").String(); got != "80" {
+		t.Errorf("got dst %s, want 80", got)
+	}
+
+	n, err = sc.GetNode(getInput, "")
+	if err != nil {
+		t.Fatalf("want nil error, got %v", err)
+	}
+	if _, ok := n.(*scparse.DictionaryNode); !ok {
+		t.Errorf("got root node type %T, want *scparse.DictionaryNode", n)
+	}
+}
+
+func TestGetError(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+		want string
+	}{
+		{"missing key", "services.web", "sc: services.web: value not found"},
+		{"index out of range", "services.api.ports[2]", "sc: services.api.ports[2]: value not found"},
+		{"index dictionary", "services[0]", "sc: services[0]: cannot index a dictionary"},
+		{"key of list", "services.api.ports.src", "sc: services.api.ports.src: cannot access a key of a list"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var v interface{}
+			err := sc.Get(getInput, tt.path, &v)
+			var pathErr *sc.PathError
+			if !errors.As(err, &pathErr) {
+				t.Fatalf("got error %v, want *sc.PathError", err)
+			}
+			if err.Error() != tt.want {
+				t.Errorf("got error %q, want %q", err.Error(), tt.want)
+			}
+		})
+	}
+
+	if _, err := sc.GetNode(getInput, "services..api"); err == nil {
+		t.Error("want error for invalid path, got nil")
+	}
+}
@@ -1,3 +1,11 @@
 module github.com/sc-lang/go-sc
 
 go 1.16
+
+require (
+	github.com/BurntSushi/toml v1.2.1
+	golang.org/x/mod v0.6.0-dev // indirect
+	golang.org/x/sys v0.12.0 // indirect
+	golang.org/x/tools v0.1.0
+	gopkg.in/yaml.v3 v3.0.1
+)
@@ -0,0 +1,16 @@
+// Copyright (c) 2021 the SC authors. All rights reserved. MIT License.
+
+// Package sccomment implements comment handling shared by the scjson, scyaml,
+// and sctoml converters.
+package sccomment
+
+import "github.com/sc-lang/go-sc/scparse"
+
+// Concat returns the comments in each of groups in order.
+func Concat(groups ...[]scparse.Comment) []scparse.Comment {
+	var comments []scparse.Comment
+	for _, g := range groups {
+		comments = append(comments, g...)
+	}
+	return comments
+}
@@ -0,0 +1,145 @@
+// Copyright (c) 2021 the SC authors. All rights reserved. MIT License.
+
+package sc
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/sc-lang/go-sc/scparse"
+)
+
+// Layer is a source of an SC document that is loaded by Layered.
+// Use FileLayer, OptionalFileLayer, BytesLayer, or ReaderLayer to create a Layer.
+type Layer struct {
+	name     string
+	load     func() ([]byte, error)
+	optional bool
+}
+
+// Name returns the name of the layer. For file layers this is the path of the file.
+func (l Layer) Name() string {
+	return l.name
+}
+
+// FileLayer returns a Layer that reads the SC document in the file at path.
+// It is an error if the file does not exist.
+func FileLayer(path string) Layer {
+	return Layer{name: path, load: func() ([]byte, error) {
+		return ioutil.ReadFile(path)
+	}}
+}
+
+// OptionalFileLayer is like FileLayer but the layer is skipped if the file does not exist.
+// This is useful for local overrides that are not always present.
+func OptionalFileLayer(path string) Layer {
+	l := FileLayer(path)
+	l.optional = true
+	return l
+}
+
+// BytesLayer returns a Layer containing the SC document data.
+// name is used to identify the layer in errors.
+func BytesLayer(name string, data []byte) Layer {
+	return Layer{name: name, load: func() ([]byte, error) {
+		return data, nil
+	}}
+}
+
+// ReaderLayer returns a Layer that reads the SC document from r.
+// name is used to identify the layer in errors.
+func ReaderLayer(name string, r io.Reader) Layer {
+	return Layer{name: name, load: func() ([]byte, error) {
+		return ioutil.ReadAll(r)
+	}}
+}
+
+// Layered loads configuration that is split across multiple SC documents,
+// ex: a base file, an environment specific file, and local overrides.
+//
+// The layers are merged in order using MergeNodes, so each layer takes precedence
+// over the layers before it. The merged document is then unmarshaled as a whole.
+// Because of this, variables are resolved across layers: a variable referenced
+// in one layer can be provided by the document variables of another
+// (see WithDocumentVariables), and later layers can override document variables
+// set by earlier ones.
+//
+// Positions in errors returned by Decode refer to the layer that contained the
+// offending value, however the name of the layer is not included.
+type Layered struct {
+	// Layers are the sources to load, from lowest to highest precedence.
+	Layers []Layer
+	// MergeOptions are used when merging the layers.
+	MergeOptions []MergeOption
+	// UnmarshalOptions are used when parsing each layer and unmarshaling
+	// the merged document.
+	UnmarshalOptions []UnmarshalOption
+}
+
+// Load loads, parses, and merges the layers and returns the resulting document.
+// The duplicate keys in each layer are resolved before it is merged using the policy
+// set by WithDuplicateKeyPolicy or WithDisallowDuplicateKeys in UnmarshalOptions.
+// The anchors and aliases of each layer are also expanded before it is merged,
+// so aliases can only reference anchors in the same layer.
+// If a layer cannot be loaded or parsed, or contains a duplicate key that is
+// not allowed, Load returns a *LayerError.
+// If there are no layers, Load returns an empty dictionary.
+func (l *Layered) Load() (*scparse.DictionaryNode, error) {
+	d := newDecoder()
+	defer d.release()
+	if err := d.applyOptions(l.UnmarshalOptions); err != nil {
+		return nil, err
+	}
+	merged := &scparse.DictionaryNode{}
+	for _, layer := range l.Layers {
+		data, err := layer.load()
+		if err != nil {
+			if layer.optional && os.IsNotExist(err) {
+				continue
+			}
+			return nil, &LayerError{Layer: layer.name, Err: err}
+		}
+		n, err := scparse.Parse(data, d.parseOpts...)
+		if err != nil {
+			return nil, &LayerError{Layer: layer.name, Err: err}
+		}
+		// Otherwise MergeNodes would merge the duplicate keys within the layer
+		if n, err = scparse.ResolveDuplicateKeys(n, d.duplicateKeys); err != nil {
+			return nil, &LayerError{Layer: layer.name, Err: err}
+		}
+		// Anchors are expanded before merging since a later layer may replace
+		// an anchor that is referenced by an alias elsewhere in the layer.
+		expanded, err := scparse.ExpandAnchors(n)
+		if err != nil {
+			return nil, &LayerError{Layer: layer.name, Err: err}
+		}
+		merged = MergeNodes(merged, expanded.(*scparse.DictionaryNode), l.MergeOptions...)
+	}
+	return merged, nil
+}
+
+// Decode loads and merges the layers and unmarshals the result into v.
+// See the documentation for Unmarshal for details on the unmarshal process.
+func (l *Layered) Decode(v interface{}) error {
+	n, err := l.Load()
+	if err != nil {
+		return err
+	}
+	return UnmarshalNode(n, v, l.UnmarshalOptions...)
+}
+
+// LayerError describes an error that occurred while loading or parsing a layer.
+type LayerError struct {
+	Layer string // The name of the layer.
+	Err   error
+}
+
+func (e *LayerError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Layer, e.Err)
+}
+
+func (e *LayerError) Unwrap() error {
+	return e.Err
+}
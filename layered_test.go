@@ -0,0 +1,173 @@
+// Copyright (c) 2021 the SC authors. All rights reserved. MIT License.
+
+package sc_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sc-lang/go-sc"
+	"github.com/sc-lang/go-sc/scparse"
+)
+
+func TestLayered(t *testing.T) {
+	type Server struct {
+		Host string `sc:"host"`
+		Port int    `sc:"port"`
+	}
+	type Config struct {
+		Name   string   `sc:"name"`
+		Server Server   `sc:"server"`
+		Tags   []string `sc:"tags"`
+		URL    string   `sc:"url"`
+	}
+
+	dir := t.TempDir()
+	basePath := filepath.Join(dir, "base.sc")
+	base := `{
+  vars: { env: "dev" }
+  name: "api"
+  server: { host: "localhost", port: 8080 }
+  tags: ["base"]
+  url: "https://${env}.example.com"
+}`
+	if err := os.WriteFile(basePath, []byte(base), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	prod := `{
+  vars: { env: "prod" }
+  server: { host: "0.0.0.0" }
+  tags: ["prod"]
+}`
+	local := `{ server: { port: ${port} } }`
+
+	l := sc.Layered{
+		Layers: []sc.Layer{
+			sc.FileLayer(basePath),
+			sc.ReaderLayer("prod.sc", strings.NewReader(prod)),
+			sc.OptionalFileLayer(filepath.Join(dir, "missing.sc")),
+			sc.BytesLayer("local.sc", []byte(local)),
+		},
+		MergeOptions: []sc.MergeOption{sc.WithListMerge(sc.ListAppend)},
+		UnmarshalOptions: []sc.UnmarshalOption{
+			sc.WithDocumentVariables("vars"),
+			sc.WithVariablesMap(map[string]int{"port": 9000}),
+		},
+	}
+	var got Config
+	if err := l.Decode(&got); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := Config{
+		Name:   "api",
+		Server: Server{Host: "0.0.0.0", Port: 9000},
+		Tags:   []string{"base", "prod"},
+		URL:    "https://prod.example.com",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got unmarshaled value\n\t%#v\nwant\n\t%#v", got, want)
+	}
+}
+
+func TestLayeredAnchors(t *testing.T) {
+	l := sc.Layered{Layers: []sc.Layer{
+		sc.BytesLayer("base.sc", []byte(`{ a: &d { p: 1, q: 2 }, b: *d }`)),
+		sc.BytesLayer("override.sc", []byte(`{ a: { p: 5 } }`)),
+	}}
+	var got map[string]map[string]int
+	if err := l.Decode(&got); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := map[string]map[string]int{
+		"a": {"p": 5, "q": 2},
+		"b": {"p": 1, "q": 2},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got unmarshaled value\n\t%#v\nwant\n\t%#v", got, want)
+	}
+}
+
+func TestLayeredErrors(t *testing.T) {
+	var v map[string]interface{}
+	l := sc.Layered{Layers: []sc.Layer{
+		sc.BytesLayer("base.sc", []byte(`{ a: 1 }`)),
+		sc.BytesLayer("bad.sc", []byte(`{ a: }`)),
+	}}
+	err := l.Decode(&v)
+	var layerErr *sc.LayerError
+	if !errors.As(err, &layerErr) {
+		t.Fatalf("got error %v, want *sc.LayerError", err)
+	}
+	if layerErr.Layer != "bad.sc" {
+		t.Errorf("got layer %q, want %q", layerErr.Layer, "bad.sc")
+	}
+
+	missing := filepath.Join(t.TempDir(), "missing.sc")
+	l = sc.Layered{Layers: []sc.Layer{sc.FileLayer(missing)}}
+	err = l.Decode(&v)
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("got error %v, want %v", err, os.ErrNotExist)
+	}
+}
+
+func TestLayeredDuplicateKeys(t *testing.T) {
+	type A struct {
+		X int `sc:"x"`
+		Y int `sc:"y"`
+	}
+	type Config struct {
+		A A   `sc:"a"`
+		B int `sc:"b"`
+	}
+	layers := []sc.Layer{
+		sc.BytesLayer("base.sc", []byte(`{ a: { x: 1 }, a: { y: 2 }, b: 1 }`)),
+		sc.BytesLayer("override.sc", []byte(`{ b: 2, b: 3 }`)),
+	}
+	tests := []struct {
+		name string
+		opts []sc.UnmarshalOption
+		want Config
+	}{
+		{
+			name: "last wins",
+			want: Config{A: A{Y: 2}, B: 3},
+		},
+		{
+			name: "deep merge",
+			opts: []sc.UnmarshalOption{sc.WithDuplicateKeyPolicy(scparse.DuplicateKeysDeepMerge)},
+			want: Config{A: A{X: 1, Y: 2}, B: 3},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := sc.Layered{Layers: layers, UnmarshalOptions: tt.opts}
+			var got Config
+			if err := l.Decode(&got); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("got unmarshaled value\n\t%#v\nwant\n\t%#v", got, tt.want)
+			}
+		})
+	}
+
+	for _, opt := range []sc.UnmarshalOption{
+		sc.WithDisallowDuplicateKeys(true),
+		sc.WithDuplicateKeyPolicy(scparse.DuplicateKeysError),
+	} {
+		l := sc.Layered{Layers: layers[1:], UnmarshalOptions: []sc.UnmarshalOption{opt}}
+		_, err := l.Load()
+		var layerErr *sc.LayerError
+		if !errors.As(err, &layerErr) {
+			t.Fatalf("got error %v, want *sc.LayerError", err)
+		}
+		var dupErr *scparse.DuplicateKeyError
+		if layerErr.Layer != "override.sc" || !errors.As(err, &dupErr) || dupErr.Key != "b" {
+			t.Errorf("got error %v, want duplicate key b in override.sc", err)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+// Copyright (c) 2021 the SC authors. All rights reserved. MIT License.
+
+package sc
+
+import "github.com/sc-lang/go-sc/scparse"
+
+// MergeOption is an option that can be provided to Merge to customize
+// how documents are merged.
+//
+// The signature contains an unexported type so that only options defined in this
+// package are valid.
+type MergeOption func(*merger)
+
+// ListMergeStrategy controls how lists are merged.
+type ListMergeStrategy int
+
+const (
+	// ListReplace replaces the base list with the overlay list.
+	ListReplace ListMergeStrategy = iota
+	// ListAppend appends the elements of the overlay list to the base list.
+	ListAppend
+)
+
+// WithDeepMerge controls how dictionaries are merged.
+//
+// By default, dictionaries are merged recursively: members of the overlay
+// dictionary replace or are added to the members of the base dictionary.
+// If set to false, an overlay dictionary replaces the base dictionary entirely.
+// The top level dictionaries are always merged.
+func WithDeepMerge(b bool) MergeOption {
+	return func(m *merger) {
+		m.shallow = !b
+	}
+}
+
+// WithListMerge sets the strategy used to merge lists. By default, ListReplace is used.
+func WithListMerge(s ListMergeStrategy) MergeOption {
+	return func(m *merger) {
+		m.listStrategy = s
+	}
+}
+
+// WithNullDeletes controls how null values in the overlay are handled.
+//
+// By default, a null value in the overlay replaces the base value like any other value.
+// If set to true, a dictionary member with a null value in the overlay causes
+// the member with the same key to be removed from the base dictionary.
+func WithNullDeletes(b bool) MergeOption {
+	return func(m *merger) {
+		m.nullDeletes = b
+	}
+}
+
+// Merge merges the SC document overlay on top of the SC document base and returns
+// the resulting document. This is useful for layered configuration,
+// ex: a base configuration with environment specific overrides.
+//
+// Members of overlay take precedence over members of base with the same key.
+// See the documentation for each MergeOption to learn how values are merged.
+// Comments from both documents are kept. If a member is in both documents, the
+// comments before the overlay member are added after the comments before the base member.
+func Merge(base, overlay []byte, opts ...MergeOption) ([]byte, error) {
+	bn, err := scparse.Parse(base)
+	if err != nil {
+		return nil, err
+	}
+	on, err := scparse.Parse(overlay)
+	if err != nil {
+		return nil, err
+	}
+	return scparse.Format(MergeNodes(bn, on, opts...)), nil
+}
+
+// MergeNodes is like Merge but merges the ASTs of the documents.
+// It returns a new AST, base and overlay are not modified.
+func MergeNodes(base, overlay *scparse.DictionaryNode, opts ...MergeOption) *scparse.DictionaryNode {
+	var m merger
+	for _, opt := range opts {
+		opt(&m)
+	}
+	n := copyValue(base).(*scparse.DictionaryNode)
+	m.mergeDictionary(n, overlay)
+	return n
+}
+
+// merger merges ASTs.
+type merger struct {
+	shallow      bool
+	listStrategy ListMergeStrategy
+	nullDeletes  bool
+}
+
+// mergeDictionary merges overlay into n, modifying n.
+func (m *merger) mergeDictionary(n, overlay *scparse.DictionaryNode) {
+	for _, om := range overlay.Members {
+		k := om.Key.KeyString()
+		if _, ok := om.Value.(*scparse.NullNode); ok && m.nullDeletes {
+			n.Delete(k)
+			continue
+		}
+		bm := n.Member(k)
+		if bm == nil {
+			n.Members = append(n.Members, copyMember(om))
+			continue
+		}
+		mergeHeadComments(bm, om)
+		bm.Value = m.mergeValue(bm.Value, om.Value)
+	}
+}
+
+// mergeHeadComments adds the comments before the overlay member om
+// to the comments before the base member bm.
+func mergeHeadComments(bm, om *scparse.MemberNode) {
+	bm.CommentGroup.Head = append(bm.CommentGroup.Head, om.CommentGroup.Head...)
+	bk := bm.Key.Comments()
+	bk.Head = append(bk.Head, om.Key.Comments().Head...)
+}
+
+// mergeValue merges overlay into n and returns the result. n may be modified.
+func (m *merger) mergeValue(n, overlay scparse.ValueNode) scparse.ValueNode {
+	switch n := n.(type) {
+	case *scparse.DictionaryNode:
+		if o, ok := overlay.(*scparse.DictionaryNode); ok && !m.shallow {
+			m.mergeDictionary(n, o)
+			return n
+		}
+	case *scparse.ListNode:
+		if o, ok := overlay.(*scparse.ListNode); ok && m.listStrategy == ListAppend {
+			for _, e := range o.Elements {
+				n.Elements = append(n.Elements, copyValue(e))
+			}
+			return n
+		}
+	}
+	return copyValue(overlay)
+}
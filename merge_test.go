@@ -0,0 +1,92 @@
+// Copyright (c) 2021 the SC authors. All rights reserved. MIT License.
+
+package sc_test
+
+import (
+	"testing"
+
+	"github.com/sc-lang/go-sc"
+)
+
+func TestMerge(t *testing.T) {
+	base := []byte(`{
+  // Service name
+  name: "api"
+  // Number of instances
+  replicas: 1
+  tags: ["base"]
+  server: {
+    host: "localhost"
+    port: ${port:-8080}
+  }
+  debug: true
+}`)
+	overlay := []byte(`{
+  // Scaled for prod
+  replicas: 3
+  tags: ["prod"]
+  server: { host: "0.0.0.0" }
+  debug: null
+  // Added in prod
+  region: "us-east-1"
+}`)
+	tests := []struct {
+		name string
+		opts []sc.MergeOption
+		want string
+	}{
+		{
+			name: "default",
+			want: `{
+  // Service name
+  name: "api"
+  // Number of instances
+  // Scaled for prod
+  replicas: 3
+  tags: [
+    "prod"
+  ]
+  server: {
+    host: "0.0.0.0"
+    port: ${port:-8080}
+  }
+  debug: null
+  // Added in prod
+  region: "us-east-1"
+}
+`,
+		},
+		{
+			name: "append lists, shallow, null deletes",
+			opts: []sc.MergeOption{sc.WithListMerge(sc.ListAppend), sc.WithDeepMerge(false), sc.WithNullDeletes(true)},
+			want: `{
+  // Service name
+  name: "api"
+  // Number of instances
+  // Scaled for prod
+  replicas: 3
+  tags: [
+    "base"
+    "prod"
+  ]
+  server: {
+    host: "0.0.0.0"
+  }
+  // Added in prod
+  region: "us-east-1"
+}
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := sc.Merge(base, overlay, tt.opts...)
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if got := string(b); got != tt.want {
+				t.Errorf("got merged document\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
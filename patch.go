@@ -0,0 +1,197 @@
+// Copyright (c) 2021 the SC authors. All rights reserved. MIT License.
+
+package sc
+
+import (
+	"strings"
+
+	"github.com/sc-lang/go-sc/scparse"
+)
+
+// PatchOp is the type of a patch operation.
+type PatchOp string
+
+const (
+	// PatchAdd adds a value. If the parent is a dictionary, the member with the key
+	// is added or replaced. If the parent is a list, the value is inserted at the index,
+	// which may be equal to the length of the list to append the value.
+	PatchAdd PatchOp = "add"
+	// PatchRemove removes an existing value.
+	PatchRemove PatchOp = "remove"
+	// PatchReplace replaces an existing value.
+	PatchReplace PatchOp = "replace"
+)
+
+// PatchOperation is a single change to an SC document.
+//
+// Path is the location of the value in the document. Paths consist of keys separated
+// by dots and list indices in brackets, ex: server.ports[1]. Keys that are not identifiers
+// must be double quoted, ex: headers."content-type". This is the same format used by scparse.Diff.
+//
+// PatchOperation can be marshaled and unmarshaled so that patches can be stored as SC.
+type PatchOperation struct {
+	Op    PatchOp           `sc:"op"`
+	Path  string            `sc:"path"`
+	Value scparse.ValueNode `sc:"value,omitempty"` // The new value for add and replace operations.
+}
+
+// Patch is a list of operations that are applied in order to an SC document.
+// It is analogous to JSON Patch (RFC 6902).
+type Patch []PatchOperation
+
+// CreatePatch returns a patch that turns the SC document a into the SC document b.
+// See scparse.Diff for details on how the documents are compared.
+func CreatePatch(a, b []byte) (Patch, error) {
+	an, err := scparse.Parse(a)
+	if err != nil {
+		return nil, err
+	}
+	bn, err := scparse.Parse(b)
+	if err != nil {
+		return nil, err
+	}
+	return CreatePatchNodes(an, bn), nil
+}
+
+// CreatePatchNodes is like CreatePatch but compares the ASTs of the documents.
+func CreatePatchNodes(a, b *scparse.DictionaryNode) Patch {
+	changes := scparse.Diff(a, b)
+	patch := make(Patch, 0, len(changes))
+	for i := 0; i < len(changes); i++ {
+		c := changes[i]
+		switch c.Type {
+		case scparse.ChangeAdded:
+			patch = append(patch, PatchOperation{Op: PatchAdd, Path: c.Path, Value: copyValue(c.New)})
+		case scparse.ChangeModified:
+			patch = append(patch, PatchOperation{Op: PatchReplace, Path: c.Path, Value: copyValue(c.New)})
+		case scparse.ChangeRemoved:
+			// Elements removed from the end of a list are reported in ascending order.
+			// They must be removed in descending order so the indices remain valid.
+			j := i + 1
+			for j < len(changes) && changes[j].Type == scparse.ChangeRemoved && isSiblingElement(c.Path, changes[j].Path) {
+				j++
+			}
+			for k := j - 1; k >= i; k-- {
+				patch = append(patch, PatchOperation{Op: PatchRemove, Path: changes[k].Path})
+			}
+			i = j - 1
+		}
+	}
+	return patch
+}
+
+// isSiblingElement reports whether the paths a and b refer to elements of the same list.
+func isSiblingElement(a, b string) bool {
+	if !strings.HasSuffix(a, "]") || !strings.HasSuffix(b, "]") {
+		return false
+	}
+	return a[:strings.LastIndexByte(a, '[')] == b[:strings.LastIndexByte(b, '[')]
+}
+
+// ApplyPatch applies patch to the SC document data and returns the resulting document.
+// If an operation cannot be applied, an error is returned.
+//
+// Only the parts of the document that are changed by the patch are reformatted,
+// comments and formatting are preserved elsewhere. See Edit for details.
+func ApplyPatch(data []byte, patch Patch) ([]byte, error) {
+	return Edit(data, func(n *scparse.DictionaryNode) error {
+		return ApplyPatchNode(n, patch)
+	})
+}
+
+// ApplyPatchNode is like ApplyPatch but applies the patch to an AST, modifying it.
+// If an error occurs, the operations before the failing operation will have been applied.
+func ApplyPatchNode(n *scparse.DictionaryNode, patch Patch) error {
+	for _, op := range patch {
+		if err := applyPatchOperation(n, op); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func applyPatchOperation(n *scparse.DictionaryNode, op PatchOperation) error {
+	elems, err := parsePath(op.Path)
+	if err != nil {
+		return err
+	}
+	patchErr := func(context string) error {
+		return &PathError{Path: op.Path, Context: "cannot " + string(op.Op) + ": " + context}
+	}
+	if len(elems) == 0 {
+		return patchErr("path must not be empty")
+	}
+	switch op.Op {
+	case PatchAdd, PatchReplace:
+		if op.Value == nil {
+			return patchErr("missing value")
+		}
+	case PatchRemove:
+	default:
+		return patchErr("unknown operation")
+	}
+	parent, err := lookupNode(n, elems[:len(elems)-1])
+	if err != nil {
+		return err
+	}
+	last := elems[len(elems)-1]
+	switch p := parent.(type) {
+	case *scparse.DictionaryNode:
+		if last.isIndex {
+			return patchErr("cannot index a dictionary")
+		}
+		old := p.Get(last.key)
+		switch op.Op {
+		case PatchAdd:
+			p.Set(last.key, patchValue(old, op.Value))
+		case PatchReplace:
+			if old == nil {
+				return patchErr("value not found")
+			}
+			p.Set(last.key, patchValue(old, op.Value))
+		case PatchRemove:
+			if !p.Delete(last.key) {
+				return patchErr("value not found")
+			}
+		}
+	case *scparse.ListNode:
+		if !last.isIndex {
+			return patchErr("cannot access a key of a list")
+		}
+		i := last.index
+		switch op.Op {
+		case PatchAdd:
+			if i > len(p.Elements) {
+				return patchErr("index out of range")
+			}
+			p.Elements = append(p.Elements, nil)
+			copy(p.Elements[i+1:], p.Elements[i:])
+			p.Elements[i] = patchValue(nil, op.Value)
+		case PatchReplace:
+			if i >= len(p.Elements) {
+				return patchErr("value not found")
+			}
+			p.Elements[i] = patchValue(p.Elements[i], op.Value)
+		case PatchRemove:
+			if i >= len(p.Elements) {
+				return patchErr("value not found")
+			}
+			p.Elements = append(p.Elements[:i], p.Elements[i+1:]...)
+		}
+	default:
+		return patchErr("parent is not a dictionary or list")
+	}
+	return nil
+}
+
+// patchValue returns a copy of v to replace old. If v has no comments,
+// the comments of old are kept.
+func patchValue(old, v scparse.ValueNode) scparse.ValueNode {
+	v = copyValue(v)
+	if old != nil {
+		if cg := v.Comments(); len(cg.Head) == 0 && len(cg.Inline) == 0 && len(cg.Foot) == 0 && len(cg.Inner) == 0 {
+			*cg = copyComments(*old.Comments())
+		}
+	}
+	return v
+}
@@ -0,0 +1,161 @@
+// Copyright (c) 2021 the SC authors. All rights reserved. MIT License.
+
+package sc_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/sc-lang/go-sc"
+	"github.com/sc-lang/go-sc/scparse"
+)
+
+func TestPatch(t *testing.T) {
+	a := []byte(`{
+  // Service name
+  name: "api"
+  replicas: 1 // scaled later
+  ports: [80, 443, 8080, 9090]
+  "content-type": "text/plain"
+  debug: true
+}`)
+	b := []byte(`{
+  name: "api"
+  replicas: 3
+  ports: [80, 8443]
+  "content-type": "text/html"
+  region: "us-east-1"
+}`)
+	patch, err := sc.CreatePatch(a, b)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	var ops []string
+	for _, op := range patch {
+		ops = append(ops, string(op.Op)+" "+op.Path)
+	}
+	wantOps := []string{
+		"replace replicas",
+		"replace ports[1]",
+		"remove ports[3]",
+		"remove ports[2]",
+		`replace "content-type"`,
+		"remove debug",
+		"add region",
+	}
+	if !equalStrings(ops, wantOps) {
+		t.Errorf("got operations\n\t%q\nwant\n\t%q", ops, wantOps)
+	}
+
+	got, err := sc.ApplyPatch(a, patch)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	want := `{
+  // Service name
+  name: "api"
+  replicas: 3 // scaled later
+  ports: [
+    80
+    8443
+  ]
+  "content-type": "text/html"
+  region: "us-east-1"
+}`
+	if string(got) != want {
+		t.Errorf("got patched document\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestPatchMarshal(t *testing.T) {
+	input := []byte(`{
+  ops: [
+    { op: "add", path: "servers[1]", value: { host: "b" } }
+    { op: "remove", path: "servers[0].port" }
+    { op: "replace", path: "meta.\"owner.name\"", value: "ops" }
+  ]
+}`)
+	var v struct {
+		Ops sc.Patch `sc:"ops"`
+	}
+	if err := sc.Unmarshal(input, &v); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	doc := []byte(`{
+  servers: [{ host: "a", port: 80 }]
+  meta: { "owner.name": "dev" }
+}`)
+	got, err := sc.ApplyPatch(doc, v.Ops)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	want := `{
+  servers: [
+    {
+      host: "a"
+    }
+    {
+      host: "b"
+    }
+  ]
+  meta: { "owner.name": "ops" }
+}`
+	if string(got) != want {
+		t.Errorf("got patched document\n%s\nwant\n%s", got, want)
+	}
+	// The patch should survive a round trip
+	b, err := sc.Marshal(v)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if err := sc.Unmarshal(b, &v); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if got2, err := sc.ApplyPatch(doc, v.Ops); err != nil || string(got2) != want {
+		t.Errorf("got patched document after round trip\n%s\nerror %v", got2, err)
+	}
+}
+
+func TestPatchError(t *testing.T) {
+	doc := []byte(`{ list: [1], name: "foo" }`)
+	tests := []struct {
+		name string
+		op   sc.PatchOperation
+		want string
+	}{
+		{"missing key", sc.PatchOperation{Op: sc.PatchRemove, Path: "missing"}, "sc: missing: cannot remove: value not found"},
+		{"index out of range", sc.PatchOperation{Op: sc.PatchAdd, Path: "list[2]", Value: scparse.NewInt(1)}, "sc: list[2]: cannot add: index out of range"},
+		{"missing parent", sc.PatchOperation{Op: sc.PatchAdd, Path: "a.b", Value: scparse.NewInt(1)}, "sc: a: value not found"},
+		{"scalar parent", sc.PatchOperation{Op: sc.PatchRemove, Path: "name.foo"}, "sc: name.foo: cannot remove: parent is not a dictionary or list"},
+		{"missing value", sc.PatchOperation{Op: sc.PatchReplace, Path: "name"}, "sc: name: cannot replace: missing value"},
+		{"invalid path", sc.PatchOperation{Op: sc.PatchRemove, Path: "list[x]"}, `sc: invalid path "list[x]": invalid index "x"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := sc.ApplyPatch(doc, sc.Patch{tt.op})
+			if err == nil {
+				t.Fatal("want error")
+			}
+			if err.Error() != tt.want {
+				t.Errorf("got error\n\t%s\nwant\n\t%s", err, tt.want)
+			}
+		})
+	}
+	_, err := sc.ApplyPatch(doc, sc.Patch{{Op: sc.PatchRemove, Path: "missing"}})
+	var perr *sc.PathError
+	if !errors.As(err, &perr) {
+		t.Errorf("got error of type %T, want %T", err, perr)
+	}
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,140 @@
+// Copyright (c) 2021 the SC authors. All rights reserved. MIT License.
+
+package sc
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/sc-lang/go-sc/scparse"
+)
+
+// pathElem is a single element of a path to a value in an SC document.
+// It is either a dictionary key or a list index.
+type pathElem struct {
+	key     string
+	index   int
+	isIndex bool
+}
+
+func (e pathElem) String() string {
+	if e.isIndex {
+		return "[" + strconv.Itoa(e.index) + "]"
+	}
+	return e.key
+}
+
+// parsePath parses a path to a value in an SC document. Paths consist of
+// keys separated by dots and list indices in brackets, ex: server.ports[1].
+// Keys that are not identifiers must be double quoted, ex: headers."content-type".
+// This is the same format used by scparse.Diff. An empty path refers to the root.
+func parsePath(path string) ([]pathElem, error) {
+	var elems []pathElem
+	errorf := func(format string, args ...interface{}) ([]pathElem, error) {
+		return nil, fmt.Errorf("sc: invalid path %q: %s", path, fmt.Sprintf(format, args...))
+	}
+	s := path
+	for i := 0; s != ""; i++ {
+		if i > 0 && s[0] != '[' {
+			if s[0] != '.' {
+				return errorf("unexpected character %q", s[0])
+			}
+			s = s[1:]
+		}
+		switch {
+		case s == "":
+			return errorf("missing key after '.'")
+		case s[0] == '[':
+			end := strings.IndexByte(s, ']')
+			if end < 0 {
+				return errorf("missing ']'")
+			}
+			index, err := strconv.Atoi(s[1:end])
+			if err != nil || index < 0 {
+				return errorf("invalid index %q", s[1:end])
+			}
+			elems = append(elems, pathElem{index: index, isIndex: true})
+			s = s[end+1:]
+		case s[0] == '"':
+			// Find the closing quote, skipping escaped characters
+			end := 1
+			for ; end < len(s) && s[end] != '"'; end++ {
+				if s[end] == '\\' {
+					end++
+				}
+			}
+			if end >= len(s) {
+				return errorf("unterminated quoted key")
+			}
+			key, err := strconv.Unquote(s[:end+1])
+			if err != nil {
+				return errorf("invalid quoted key %s", s[:end+1])
+			}
+			elems = append(elems, pathElem{key: key})
+			s = s[end+1:]
+		default:
+			end := strings.IndexFunc(s, func(r rune) bool {
+				return r != '_' && !unicode.IsLetter(r) && !unicode.IsDigit(r)
+			})
+			if end < 0 {
+				end = len(s)
+			}
+			if end == 0 {
+				return errorf("unexpected character %q", s[0])
+			}
+			elems = append(elems, pathElem{key: s[:end]})
+			s = s[end:]
+		}
+	}
+	return elems, nil
+}
+
+// lookupNode returns the value at the path elems in n.
+func lookupNode(n scparse.ValueNode, elems []pathElem) (scparse.ValueNode, error) {
+	for i, e := range elems {
+		switch v := n.(type) {
+		case *scparse.DictionaryNode:
+			if e.isIndex {
+				return nil, &PathError{Path: formatPath(elems[:i+1]), Context: "cannot index a dictionary"}
+			}
+			n = v.Get(e.key)
+		case *scparse.ListNode:
+			if !e.isIndex {
+				return nil, &PathError{Path: formatPath(elems[:i+1]), Context: "cannot access a key of a list"}
+			}
+			if e.index >= len(v.Elements) {
+				n = nil
+			} else {
+				n = v.Elements[e.index]
+			}
+		default:
+			return nil, &PathError{Path: formatPath(elems[:i+1]), Context: fmt.Sprintf("parent is a %s, not a dictionary or list", v.Type())}
+		}
+		if n == nil {
+			return nil, &PathError{Path: formatPath(elems[:i+1]), Context: "value not found"}
+		}
+	}
+	return n, nil
+}
+
+// formatPath converts elems back to a path string.
+func formatPath(elems []pathElem) string {
+	var sb strings.Builder
+	for i, e := range elems {
+		if e.isIndex {
+			sb.WriteString(e.String())
+			continue
+		}
+		if i > 0 {
+			sb.WriteByte('.')
+		}
+		if _, ok := scparse.NewKey(e.key).(*scparse.IdentifierNode); ok {
+			sb.WriteString(e.key)
+		} else {
+			sb.WriteString(strconv.Quote(e.key))
+		}
+	}
+	return sb.String()
+}
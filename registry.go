@@ -0,0 +1,104 @@
+// Copyright (c) 2021 the SC authors. All rights reserved. MIT License.
+
+package sc
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"sync/atomic"
+
+	"github.com/sc-lang/go-sc/scparse"
+)
+
+// MarshalFunc marshals v into an SC value. v has the type it was registered with.
+type MarshalFunc func(v interface{}) (scparse.ValueNode, error)
+
+// UnmarshalFunc unmarshals the SC value n. The returned value must be assignable
+// to the type it was registered with. vars are the variables provided to Unmarshal.
+type UnmarshalFunc func(n scparse.ValueNode, vars Variables) (interface{}, error)
+
+// typeCodec holds the functions registered for a type.
+type typeCodec struct {
+	marshal   MarshalFunc
+	unmarshal UnmarshalFunc
+}
+
+// typeRegistry holds the registered types. The map is replaced on each
+// registration so that it can be read without locking.
+var typeRegistry struct {
+	mu     sync.Mutex   // serializes registrations
+	codecs atomic.Value // map[reflect.Type]typeCodec
+}
+
+// RegisterType registers functions that are used to marshal and unmarshal
+// values of type t everywhere in the program. This allows types that cannot
+// implement Marshaler and Unmarshaler, ex: time.Time or url.URL, to have a
+// consistent SC encoding without wrapper types.
+//
+// Registered functions take precedence over the Marshaler, Unmarshaler,
+// encoding.TextMarshaler and encoding.TextUnmarshaler implementations of t.
+// Hooks provided with WithDecodeHook take precedence over registered functions.
+// Either function may be nil, in which case values of type t are handled
+// normally in that direction. Registering t again replaces the functions,
+// registering it with two nil functions removes it.
+//
+// Errors returned by unmarshal are reported as a *DecodeHookError.
+// RegisterType is safe to call concurrently, but it is intended to be
+// called during program initialization.
+func RegisterType(t reflect.Type, marshal MarshalFunc, unmarshal UnmarshalFunc) {
+	if t == nil {
+		panic("sc: RegisterType(nil)")
+	}
+	typeRegistry.mu.Lock()
+	defer typeRegistry.mu.Unlock()
+	old, _ := typeRegistry.codecs.Load().(map[reflect.Type]typeCodec)
+	codecs := make(map[reflect.Type]typeCodec, len(old)+1)
+	for k, v := range old {
+		codecs[k] = v
+	}
+	if marshal == nil && unmarshal == nil {
+		delete(codecs, t)
+	} else {
+		codecs[t] = typeCodec{marshal, unmarshal}
+	}
+	typeRegistry.codecs.Store(codecs)
+
+	// Compiled decode functions may use the fast path for t
+	decodeFuncCache.Range(func(k, _ interface{}) bool {
+		decodeFuncCache.Delete(k)
+		return true
+	})
+}
+
+// registeredTypes returns the registered types.
+func registeredTypes() map[reflect.Type]typeCodec {
+	codecs, _ := typeRegistry.codecs.Load().(map[reflect.Type]typeCodec)
+	return codecs
+}
+
+// registeredUnmarshal returns the unmarshal function registered for t, if any.
+func registeredUnmarshal(t reflect.Type) UnmarshalFunc {
+	return registeredTypes()[t].unmarshal
+}
+
+// encodeRegistered encodes v using the marshal function registered for its type.
+// It reports whether a function was registered.
+func (e *encoder) encodeRegistered(v reflect.Value) (scparse.ValueNode, bool) {
+	codecs := registeredTypes()
+	if len(codecs) == 0 {
+		return nil, false
+	}
+	marshal := codecs[v.Type()].marshal
+	if marshal == nil {
+		return nil, false
+	}
+	n, err := marshal(v.Interface())
+	if err != nil {
+		e.error(fmt.Errorf("sc: error marshaling type %s: %w", v.Type(), err))
+	}
+	if n == nil {
+		return &scparse.NullNode{}, true
+	}
+	return n, true
+}
@@ -0,0 +1,186 @@
+// Copyright (c) 2021 the SC authors. All rights reserved. MIT License.
+
+package sc_test
+
+import (
+	"errors"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/sc-lang/go-sc"
+	"github.com/sc-lang/go-sc/scparse"
+)
+
+var urlType = reflect.TypeOf(url.URL{})
+
+func marshalURL(v interface{}) (scparse.ValueNode, error) {
+	u := v.(url.URL)
+	return scparse.NewString(u.String()), nil
+}
+
+func unmarshalURL(n scparse.ValueNode, vars sc.Variables) (interface{}, error) {
+	var s string
+	if err := (sc.RawNode{Node: n, Vars: vars}).Decode(&s); err != nil {
+		return nil, err
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, err
+	}
+	return *u, nil
+}
+
+func TestRegisterType(t *testing.T) {
+	sc.RegisterType(urlType, marshalURL, unmarshalURL)
+	defer sc.RegisterType(urlType, nil, nil)
+
+	type Config struct {
+		Endpoint url.URL    `sc:"endpoint"`
+		Proxy    *url.URL   `sc:"proxy"`
+		Mirrors  []url.URL  `sc:"mirrors"`
+		Backup   *url.URL   `sc:"backup"`
+		Extra    []*url.URL `sc:"extra"`
+	}
+	input := []byte(`{
+  endpoint: "https://example.com/api"
+  proxy: "http://${host}:8080"
+  mirrors: ["https://a.example.com"]
+  backup: null
+  extra: ["https://b.example.com"]
+}
+`)
+	vars := sc.MustVariables(map[string]interface{}{"host": "proxy.local"})
+	mustParse := func(s string) *url.URL {
+		u, err := url.Parse(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return u
+	}
+	want := Config{
+		Endpoint: *mustParse("https://example.com/api"),
+		Proxy:    mustParse("http://proxy.local:8080"),
+		Mirrors:  []url.URL{*mustParse("https://a.example.com")},
+		Extra:    []*url.URL{mustParse("https://b.example.com")},
+	}
+
+	var got Config
+	if err := sc.Unmarshal(input, &got, sc.WithVariables(vars)); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	td, err := sc.CompileType(reflect.TypeOf(Config{}), sc.WithVariables(vars))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	got = Config{}
+	if err := td.Unmarshal(input, &got); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v from TypeDecoder, want %+v", got, want)
+	}
+
+	b, err := sc.Marshal(want)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	wantSC := `{
+  endpoint: "https://example.com/api"
+  proxy: "http://proxy.local:8080"
+  mirrors: [
+    "https://a.example.com"
+  ]
+  backup: null
+  extra: [
+    "https://b.example.com"
+  ]
+}
+`
+	if string(b) != wantSC {
+		t.Errorf("got marshaled value\n%s\nwant\n%s", b, wantSC)
+	}
+
+	// Errors are reported with the position of the node
+	err = sc.Unmarshal([]byte(`{ endpoint: ":bad" }`), &got)
+	var errs sc.Errors
+	if !errors.As(err, &errs) || len(errs) != 1 {
+		t.Fatalf("got error %v, want 1 error", err)
+	}
+	var he *sc.DecodeHookError
+	if !errors.As(errs[0], &he) {
+		t.Fatalf("got error of type %T, want %T", errs[0], he)
+	}
+	if want := (scparse.Pos{Line: 1, Column: 13, Byte: 12}); he.Pos != want {
+		t.Errorf("got position %+v, want %+v", he.Pos, want)
+	}
+}
+
+func TestRegisterTypePointerTopLevel(t *testing.T) {
+	urlPtrType := reflect.TypeOf(&url.URL{})
+	sc.RegisterType(urlPtrType, nil, func(n scparse.ValueNode, vars sc.Variables) (interface{}, error) {
+		u, err := unmarshalURL(n, vars)
+		if err != nil {
+			return nil, err
+		}
+		v := u.(url.URL)
+		return &v, nil
+	})
+	defer sc.RegisterType(urlPtrType, nil, nil)
+
+	// Errors from the unmarshal function are reported for the top-level value
+	var got url.URL
+	err := sc.Unmarshal([]byte(`{ endpoint: "https://example.com" }`), &got)
+	var errs sc.Errors
+	var he *sc.DecodeHookError
+	if !errors.As(err, &errs) || !errors.As(errs[0], &he) {
+		t.Fatalf("got error %v, want %T", err, he)
+	}
+	var u *url.URL
+	if err := sc.UnmarshalNode(scparse.NewString("https://example.com/api"), &u); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if u == nil || u.String() != "https://example.com/api" {
+		t.Errorf("got %v, want https://example.com/api", u)
+	}
+	if err := sc.UnmarshalNode(scparse.NewString("https://example.com/v2"), &got); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if got.String() != "https://example.com/v2" {
+		t.Errorf("got %v, want https://example.com/v2", got.String())
+	}
+}
+
+func TestRegisterTypeUnregister(t *testing.T) {
+	type celsius float64
+	ct := reflect.TypeOf(celsius(0))
+	sc.RegisterType(ct, func(v interface{}) (scparse.ValueNode, error) {
+		return scparse.NewString("hot"), nil
+	}, nil)
+
+	b, err := sc.Marshal(map[string]celsius{"t": 30})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if want := "{\n  t: \"hot\"\n}\n"; string(b) != want {
+		t.Errorf("got %q, want %q", b, want)
+	}
+	// Unmarshal function is nil so the value is decoded normally
+	var m map[string]celsius
+	if err := sc.Unmarshal([]byte(`{ t: 30 }`), &m); err != nil || m["t"] != 30 {
+		t.Errorf("got %v, %v, want t = 30", m, err)
+	}
+
+	sc.RegisterType(ct, nil, nil)
+	b, err = sc.Marshal(map[string]celsius{"t": 30})
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if want := "{\n  t: 30\n}\n"; string(b) != want {
+		t.Errorf("got %q after unregistering, want %q", b, want)
+	}
+}
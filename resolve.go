@@ -0,0 +1,379 @@
+// Copyright (c) 2021 the SC authors. All rights reserved. MIT License.
+
+package sc
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sc-lang/go-sc/scparse"
+)
+
+// resolver creates a copy of an AST with variables replaced by their values.
+type resolver struct {
+	d decoder
+	e encoder
+}
+
+func (r *resolver) resolve(n scparse.ValueNode) (scparse.ValueNode, error) {
+	switch n := n.(type) {
+	case *scparse.NullNode:
+		c := *n
+		c.CommentGroup = copyComments(n.CommentGroup)
+		return &c, nil
+	case *scparse.BoolNode:
+		c := *n
+		c.CommentGroup = copyComments(n.CommentGroup)
+		return &c, nil
+	case *scparse.NumberNode:
+		c := *n
+		c.CommentGroup = copyComments(n.CommentGroup)
+		return &c, nil
+	case *scparse.RawStringNode:
+		c := *n
+		c.CommentGroup = copyComments(n.CommentGroup)
+		return &c, nil
+	case *scparse.MultilineStringNode:
+		c := *n
+		c.CommentGroup = copyComments(n.CommentGroup)
+		return &c, nil
+	case *scparse.VariableNode:
+		return r.resolveVariable(n)
+	case *scparse.ExpressionNode:
+		return r.resolveExpression(n)
+	case *scparse.InterpolatedStringNode:
+		return r.resolveInterpolatedString(n)
+	case *scparse.ListNode:
+		elements := make([]scparse.ValueNode, len(n.Elements))
+		for i, e := range n.Elements {
+			en, err := r.resolve(e)
+			if err != nil {
+				return nil, err
+			}
+			elements[i] = en
+		}
+		return &scparse.ListNode{Pos: n.Pos, CommentGroup: copyComments(n.CommentGroup), Elements: elements, End: n.End}, nil
+	case *scparse.DictionaryNode:
+		members := make([]*scparse.MemberNode, len(n.Members))
+		for i, m := range n.Members {
+			vn, err := r.resolve(m.Value)
+			if err != nil {
+				return nil, err
+			}
+			members[i] = &scparse.MemberNode{
+				Pos:          m.Pos,
+				CommentGroup: copyComments(m.CommentGroup),
+				Key:          copyKey(m.Key),
+				Value:        vn,
+			}
+		}
+		return &scparse.DictionaryNode{Pos: n.Pos, CommentGroup: copyComments(n.CommentGroup), Members: members, End: n.End}, nil
+	default:
+		panic(fmt.Errorf("impossible: invalid node type used as value: %T", n))
+	}
+}
+
+func (r *resolver) resolveVariable(n *scparse.VariableNode) (scparse.ValueNode, error) {
+	val, ok, err := r.d.lookupVariableInterface(n)
+	if err != nil {
+		return nil, err
+	}
+	if !ok && n.Default != nil {
+		sn := defaultString(n)
+		sn.CommentGroup = copyComments(n.CommentGroup)
+		return sn, nil
+	}
+	if !ok {
+		if r.d.disallowUnknown(n) {
+			return nil, &UnmarshalUnknownVariableError{Variable: n.Identifier.Name, Pos: n.Pos}
+		}
+		// Leave the variable as is
+		return copyVariable(n), nil
+	}
+	return r.resolveValue(n, val)
+}
+
+func (r *resolver) resolveExpression(n *scparse.ExpressionNode) (scparse.ValueNode, error) {
+	val, ok, err := r.d.evalExpression(n)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		// Leave the expression as is
+		return copyExpression(n), nil
+	}
+	return r.resolveValue(n, val)
+}
+
+// resolveValue returns the node for val, which is the value of the variable or expression n.
+func (r *resolver) resolveValue(n scparse.ValueNode, val interface{}) (scparse.ValueNode, error) {
+	vn, err := r.e.marshalValue(val)
+	if err != nil {
+		return nil, err
+	}
+	// The value might have been a node, copy it so that it isn't modified.
+	vn = copyValue(vn)
+	// Keep the original comments and position so the resolved AST
+	// looks as close to the original as possible.
+	*vn.Comments() = copyComments(*n.Comments())
+	setPos(vn, n.Position())
+	return vn, nil
+}
+
+func (r *resolver) resolveInterpolatedString(n *scparse.InterpolatedStringNode) (scparse.ValueNode, error) {
+	var components []scparse.StringContentNode
+	// Adjacent strings are combined into a single StringNode
+	var sn *scparse.StringNode
+	var sb strings.Builder
+	flush := func() {
+		if sn != nil {
+			sn.Value = sb.String()
+			components = append(components, sn)
+			sn = nil
+			sb.Reset()
+		}
+	}
+	for _, c := range n.Components {
+		switch c := c.(type) {
+		case *scparse.StringNode:
+			if sn == nil {
+				sn = &scparse.StringNode{Pos: c.Pos}
+			}
+			sb.WriteString(c.Value)
+		case *scparse.VariableNode:
+			val, ok, err := r.d.lookupVariableInterface(c)
+			if err != nil {
+				return nil, err
+			}
+			if !ok && c.Default != nil {
+				if sn == nil {
+					sn = &scparse.StringNode{Pos: c.Pos}
+				}
+				sb.WriteString(c.Default.Value)
+				break
+			}
+			if !ok {
+				if r.d.disallowUnknown(c) {
+					return nil, &UnmarshalUnknownVariableError{Variable: c.Identifier.Name, Pos: c.Pos}
+				}
+				flush()
+				components = append(components, copyVariable(c))
+				break
+			}
+			if sn == nil {
+				sn = &scparse.StringNode{Pos: c.Pos}
+			}
+			vs, err := r.d.formatVariable(c.Identifier.Name, val)
+			if err != nil {
+				return nil, err
+			}
+			sb.WriteString(vs)
+		case *scparse.ExpressionNode:
+			val, ok, err := r.d.evalExpression(c)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				flush()
+				components = append(components, copyExpression(c))
+				break
+			}
+			if sn == nil {
+				sn = &scparse.StringNode{Pos: c.Pos}
+			}
+			vs, err := r.d.formatVariable(c.String(), val)
+			if err != nil {
+				return nil, err
+			}
+			sb.WriteString(vs)
+		default:
+			panic(fmt.Errorf("impossible: invalid node type in InterpolatedString: %T", c))
+		}
+	}
+	flush()
+	return &scparse.InterpolatedStringNode{Pos: n.Pos, CommentGroup: copyComments(n.CommentGroup), Components: components, End: n.End}, nil
+}
+
+// copyValue returns a deep copy of n.
+func copyValue(n scparse.ValueNode) scparse.ValueNode {
+	switch n := n.(type) {
+	case *scparse.NullNode:
+		c := *n
+		c.CommentGroup = copyComments(n.CommentGroup)
+		return &c
+	case *scparse.BoolNode:
+		c := *n
+		c.CommentGroup = copyComments(n.CommentGroup)
+		return &c
+	case *scparse.NumberNode:
+		c := *n
+		c.CommentGroup = copyComments(n.CommentGroup)
+		return &c
+	case *scparse.RawStringNode:
+		c := *n
+		c.CommentGroup = copyComments(n.CommentGroup)
+		return &c
+	case *scparse.MultilineStringNode:
+		c := *n
+		c.CommentGroup = copyComments(n.CommentGroup)
+		return &c
+	case *scparse.VariableNode:
+		return copyVariable(n)
+	case *scparse.ExpressionNode:
+		return copyExpression(n)
+	case *scparse.AnchorNode:
+		name := copyKey(n.Name).(*scparse.IdentifierNode)
+		return &scparse.AnchorNode{Pos: n.Pos, CommentGroup: copyComments(n.CommentGroup), Name: name, Value: copyValue(n.Value)}
+	case *scparse.AliasNode:
+		name := copyKey(n.Name).(*scparse.IdentifierNode)
+		return &scparse.AliasNode{Pos: n.Pos, CommentGroup: copyComments(n.CommentGroup), Name: name}
+	case *scparse.InterpolatedStringNode:
+		components := make([]scparse.StringContentNode, len(n.Components))
+		for i, c := range n.Components {
+			switch c := c.(type) {
+			case *scparse.StringNode:
+				sc := *c
+				sc.CommentGroup = copyComments(c.CommentGroup)
+				components[i] = &sc
+			case *scparse.VariableNode:
+				components[i] = copyVariable(c)
+			case *scparse.ExpressionNode:
+				components[i] = copyExpression(c)
+			default:
+				panic(fmt.Errorf("impossible: invalid node type in InterpolatedString: %T", c))
+			}
+		}
+		return &scparse.InterpolatedStringNode{Pos: n.Pos, CommentGroup: copyComments(n.CommentGroup), Components: components, End: n.End}
+	case *scparse.ListNode:
+		elements := make([]scparse.ValueNode, len(n.Elements))
+		for i, e := range n.Elements {
+			elements[i] = copyValue(e)
+		}
+		return &scparse.ListNode{Pos: n.Pos, CommentGroup: copyComments(n.CommentGroup), Elements: elements, End: n.End}
+	case *scparse.DictionaryNode:
+		members := make([]*scparse.MemberNode, len(n.Members))
+		for i, m := range n.Members {
+			members[i] = copyMember(m)
+		}
+		return &scparse.DictionaryNode{Pos: n.Pos, CommentGroup: copyComments(n.CommentGroup), Members: members, End: n.End}
+	default:
+		panic(fmt.Errorf("impossible: invalid node type used as value: %T", n))
+	}
+}
+
+// copyMember returns a deep copy of m.
+func copyMember(m *scparse.MemberNode) *scparse.MemberNode {
+	return &scparse.MemberNode{
+		Pos:          m.Pos,
+		CommentGroup: copyComments(m.CommentGroup),
+		Key:          copyKey(m.Key),
+		Value:        copyValue(m.Value),
+	}
+}
+
+// copyVariable returns a copy of the variable node n.
+func copyVariable(n *scparse.VariableNode) *scparse.VariableNode {
+	id := *n.Identifier
+	id.CommentGroup = copyComments(n.Identifier.CommentGroup)
+	c := &scparse.VariableNode{Pos: n.Pos, CommentGroup: copyComments(n.CommentGroup), Identifier: &id, IsCall: n.IsCall, Rparen: n.Rparen}
+	for _, a := range n.Args {
+		switch a := a.(type) {
+		case *scparse.StringNode:
+			sc := *a
+			sc.CommentGroup = copyComments(a.CommentGroup)
+			c.Args = append(c.Args, &sc)
+		case *scparse.VariableNode:
+			c.Args = append(c.Args, copyVariable(a))
+		}
+	}
+	if n.Default != nil {
+		def := *n.Default
+		def.CommentGroup = copyComments(n.Default.CommentGroup)
+		c.Default = &def
+	}
+	return c
+}
+
+// copyExpression returns a copy of the expression node n.
+func copyExpression(n *scparse.ExpressionNode) *scparse.ExpressionNode {
+	c := &scparse.ExpressionNode{Pos: n.Pos, CommentGroup: copyComments(n.CommentGroup), Op: n.Op, End: n.End}
+	c.Operands = make([]scparse.StringContentNode, len(n.Operands))
+	for i, o := range n.Operands {
+		switch o := o.(type) {
+		case *scparse.StringNode:
+			sc := *o
+			sc.CommentGroup = copyComments(o.CommentGroup)
+			c.Operands[i] = &sc
+		case *scparse.VariableNode:
+			c.Operands[i] = copyVariable(o)
+		case *scparse.ExpressionNode:
+			c.Operands[i] = copyExpression(o)
+		}
+	}
+	return c
+}
+
+// copyKey returns a copy of the key node k.
+func copyKey(k scparse.KeyNode) scparse.KeyNode {
+	switch k := k.(type) {
+	case *scparse.IdentifierNode:
+		c := *k
+		c.CommentGroup = copyComments(k.CommentGroup)
+		return &c
+	case *scparse.StringNode:
+		c := *k
+		c.CommentGroup = copyComments(k.CommentGroup)
+		return &c
+	case *scparse.RawStringNode:
+		c := *k
+		c.CommentGroup = copyComments(k.CommentGroup)
+		return &c
+	default:
+		panic(fmt.Errorf("impossible: invalid node type used as key: %T", k))
+	}
+}
+
+// copyComments returns a copy of cg that does not share any memory with cg.
+func copyComments(cg scparse.CommentGroup) scparse.CommentGroup {
+	cp := func(c []scparse.Comment) []scparse.Comment {
+		if c == nil {
+			return nil
+		}
+		return append([]scparse.Comment(nil), c...)
+	}
+	return scparse.CommentGroup{
+		Head:   cp(cg.Head),
+		Inline: cp(cg.Inline),
+		Foot:   cp(cg.Foot),
+		Inner:  cp(cg.Inner),
+
+		BlankLinesBefore: cg.BlankLinesBefore,
+	}
+}
+
+// setPos sets the position of n to pos.
+// This is used for nodes created from Go values which do not have positions.
+func setPos(n scparse.ValueNode, pos scparse.Pos) {
+	switch n := n.(type) {
+	case *scparse.NullNode:
+		n.Pos = pos
+	case *scparse.BoolNode:
+		n.Pos = pos
+	case *scparse.NumberNode:
+		n.Pos = pos
+	case *scparse.RawStringNode:
+		n.Pos = pos
+	case *scparse.MultilineStringNode:
+		n.Pos = pos
+	case *scparse.VariableNode:
+		n.Pos = pos
+	case *scparse.ExpressionNode:
+		n.Pos = pos
+	case *scparse.InterpolatedStringNode:
+		n.Pos = pos
+	case *scparse.ListNode:
+		n.Pos = pos
+	case *scparse.DictionaryNode:
+		n.Pos = pos
+	}
+}
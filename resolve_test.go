@@ -0,0 +1,62 @@
+// Copyright (c) 2021 the SC authors. All rights reserved. MIT License.
+
+package sc_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/sc-lang/go-sc"
+	"github.com/sc-lang/go-sc/scparse"
+)
+
+func TestResolve(t *testing.T) {
+	input := []byte(`{
+  image: "golang:${tag}-slim"
+  port: ${port} // the port
+  env: ${env}
+  path: "${home}/${missing}/bin"
+  unknown: ${missing}
+}`)
+	n, err := scparse.Parse(input)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	vars := sc.MustVariables(map[string]interface{}{
+		"tag":  1.16,
+		"port": 8080,
+		"env":  map[string]string{"DEBUG": "true"},
+		"home": "/home/ted",
+	})
+	rn, err := sc.Resolve(n, sc.WithVariables(vars))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	want := `{
+  image: "golang:1.16-slim"
+  port: 8080 // the port
+  env: {
+    DEBUG: "true"
+  }
+  path: "/home/ted/${missing}/bin"
+  unknown: ${missing}
+}
+`
+	got := string(scparse.Format(rn.(*scparse.DictionaryNode)))
+	if got != want {
+		t.Errorf("got resolved value\n\t%#v\nwant\n\t%#v", got, want)
+	}
+	// Original AST must not be modified
+	if got := string(scparse.Format(n)); got == want {
+		t.Errorf("original AST was modified")
+	}
+
+	_, err = sc.Resolve(n, sc.WithVariables(vars), sc.WithDisallowUnknownVariables(true))
+	var unknownVarErr *sc.UnmarshalUnknownVariableError
+	if !errors.As(err, &unknownVarErr) {
+		t.Fatalf("got error of type %T, want %T", err, unknownVarErr)
+	}
+	if unknownVarErr.Variable != "missing" {
+		t.Errorf("got variable %q, want %q", unknownVarErr.Variable, "missing")
+	}
+}
@@ -7,6 +7,7 @@
 package sc
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"reflect"
@@ -30,12 +31,19 @@ import (
 //
 // Struct fields are only unmarshaled if they are exported and are unmarshaled using the
 // field name as the default key. Custom keys may be defined via the "sc" name
-// in the field tag.
+// in the field tag. If a field has the "required" tag option and the SC dictionary does
+// not contain a matching key, an UnmarshalMissingFieldError is recorded. Fields of a
+// struct field with the "inline" tag option are unmarshaled as if they were fields of
+// the outer struct. Dictionary members that do not match any field are unmarshaled
+// into the field with the "remain" tag option, if there is one.
+// See Marshal for more details.
+// WithTagName and WithFallbackToJSONTags allow other tags, ex: json, to be used instead.
 //
 // Unmarshal supports unmarshaling into node types defined in the scparse package.
 // This can allow for delaying the unmarshaling process and for accessing parts of the
 // node like comments. UnmarshalNode may be used to continue the unmarshaling process and
-// unmarshal the node into a Go value.
+// unmarshal the node into a Go value. RawNode can be used to also retain the variables
+// provided to Unmarshal so they are available when the node is unmarshaled later.
 //
 // If the target value is an empty interface, Unmarshal stores SC values into the following Go values:
 //
@@ -46,24 +54,36 @@ import (
 //  []interface{} for SC lists
 //  map[string]interface{} for SC dictionaries
 //
+// Use Dict instead of map[string]interface{} to preserve the order of dictionary members.
+//
 // If a SC value is not appropriate for a given target type, Umarshal skips that field and
 // completes the unmarshaling as best it can. Unmarshal keeps track of all non-critical
 // errors encountered and returns a sc.Errors which is a list of error values that can be
 // checked individually. If an error is encountered while parsing the SC value,
 // Unmarshal will immediately return the parse error, which will likely be a *scparse.Error.
 //
+// Anchors and aliases are expanded before unmarshaling, so an alias is unmarshaled
+// the same as the value it references. If they cannot be expanded, Unmarshal
+// immediately returns a *scparse.AnchorError. See scparse.ExpandAnchors for details.
+//
+// Variables can contain expressions, ex: ${env == "prod" ? "db.internal" : "localhost"},
+// which are evaluated using the provided variables and unmarshaled the same as a variable
+// with the value of the result. If the result is a string and the Go value is a bool
+// or number, the string is parsed the same as a default value, ex: ${replicas ?? "1"}.
+// See scparse.ExpressionNode for the supported operators.
+//
 // Unmarshal can optionally be provided additional option arguments that modify the unmarshal process.
 // For example, sc.WithVariables can be used to provide values for SC variables that will be expanded
 // during unmarshaling. See the documentation for each UnmarshalOption to learn more.
 func Unmarshal(data []byte, v interface{}, opts ...UnmarshalOption) error {
-	n, err := scparse.Parse(data)
-	if err != nil {
+	d := newDecoder()
+	defer d.release()
+	if err := d.applyOptions(opts); err != nil {
 		return err
 	}
-
-	var d decoder
-	for _, opt := range opts {
-		opt(&d)
+	n, err := scparse.Parse(data, d.parseOpts...)
+	if err != nil {
+		return err
 	}
 	return d.unmarshal(n, v)
 }
@@ -75,9 +95,10 @@ func Unmarshal(data []byte, v interface{}, opts ...UnmarshalOption) error {
 //
 // See the documentation for Unmarshal for details on the unmarshal process.
 func UnmarshalNode(n scparse.ValueNode, v interface{}, opts ...UnmarshalOption) error {
-	var d decoder
-	for _, opt := range opts {
-		opt(&d)
+	d := newDecoder()
+	defer d.release()
+	if err := d.applyOptions(opts); err != nil {
+		return err
 	}
 	return d.unmarshal(n, v)
 }
@@ -86,7 +107,8 @@ func UnmarshalNode(n scparse.ValueNode, v interface{}, opts ...UnmarshalOption)
 // behaviour during the unmarshaling process.
 //
 // The signature contains an unexported type so that only options defined in this
-// package are valid.
+// package are valid. Options that are given invalid arguments cause Unmarshal
+// to return an error before any unmarshaling takes place.
 type UnmarshalOption func(*decoder)
 
 // WithVariables sets the variables that should be used during unmarshaling.
@@ -96,18 +118,117 @@ func WithVariables(vars Variables) UnmarshalOption {
 	}
 }
 
+// WithVariablesMap is like WithVariables but it creates the variables from
+// the map v using NewVariables. If v is not a valid type, the error from
+// NewVariables will be returned by Unmarshal.
+//
+// This is a shorthand for calling NewVariables and WithVariables when the variables
+// are only needed for a single call to Unmarshal.
+func WithVariablesMap(v interface{}) UnmarshalOption {
+	return func(d *decoder) {
+		vars, err := NewVariables(v)
+		if err != nil {
+			d.optionError(err)
+			return
+		}
+		d.vars = vars
+	}
+}
+
+// WithDocumentVariables sets the key of a top level dictionary member that defines
+// variables in the SC document itself, ex: with WithDocumentVariables("vars"),
+//
+//	vars: { region: "us-east-1" }
+//	bucket: "assets-${region}"
+//
+// The member is removed from the document before it is unmarshaled and each of its
+// members can be referenced as a variable in the rest of the document.
+// The value of the member must be a dictionary.
+//
+// Variables provided using WithVariables take precedence over document variables,
+// this allows the document to define defaults that can be overridden by the program.
+// Document variables may reference provided variables, but not other document variables.
+//
+// By default, no member is treated as document variables.
+func WithDocumentVariables(key string) UnmarshalOption {
+	return func(d *decoder) {
+		d.documentVars = key
+	}
+}
+
+// WithParseOptions sets the options that are used when parsing the SC data.
+// This can be used to place limits on the input, ex: scparse.WithMaxDepth and
+// scparse.WithMaxAliasExpansion, which is important when unmarshaling untrusted input,
+// or to enable syntax extensions, ex: scparse.WithDottedKeys.
+//
+// The options have no effect when using UnmarshalNode since the data has already been parsed.
+func WithParseOptions(opts ...scparse.ParseOption) UnmarshalOption {
+	return func(d *decoder) {
+		d.parseOpts = opts
+	}
+}
+
+// WithStrict controls whether Unmarshal uses the strictest checks for mistakes in the input.
+// It is the same as providing WithDisallowUnknownFields, WithDisallowUnknownVariables,
+// WithDisallowDuplicateKeys, and WithStrictVariableTypes with b.
+// Options provided after WithStrict take precedence, ex: WithStrict(true) followed by
+// WithDisallowUnknownFields(false) enables all checks except for unknown fields.
+//
+// By default, all of these checks are disabled.
+func WithStrict(b bool) UnmarshalOption {
+	return func(d *decoder) {
+		d.disallowUnknownFields = b
+		d.disallowUnknownVars = b
+		d.strictVarTypes = b
+		if b {
+			d.duplicateKeys = scparse.DuplicateKeysError
+		} else {
+			d.duplicateKeys = scparse.DuplicateKeysLastWins
+		}
+	}
+}
+
 // WithDisallowUnknownFields controls how Unmarshal will behave when the destination
 // is a struct and the input contains dictionary keys which do not match any
 // non-ignored, exported fields in the destination.
 //
 // By default, unknown fields are silently ignored. If set to true,
-// unknown fields will instead cause an error to be returned during unmarshaling.
+// unknown fields will instead cause an UnmarshalUnknownFieldError to be returned during unmarshaling.
 func WithDisallowUnknownFields(b bool) UnmarshalOption {
 	return func(d *decoder) {
 		d.disallowUnknownFields = b
 	}
 }
 
+// WithDisallowDuplicateKeys controls how Unmarshal will behave when an SC dictionary
+// contains the same key more than once.
+//
+// By default, duplicate keys are silently allowed and the last value for a key is used.
+// If set to true, each duplicate key will instead cause a *scparse.DuplicateKeyError
+// to be returned during unmarshaling.
+// This is the same as using WithDuplicateKeyPolicy(scparse.DuplicateKeysError).
+func WithDisallowDuplicateKeys(b bool) UnmarshalOption {
+	return func(d *decoder) {
+		if b {
+			d.duplicateKeys = scparse.DuplicateKeysError
+		} else {
+			d.duplicateKeys = scparse.DuplicateKeysLastWins
+		}
+	}
+}
+
+// WithDuplicateKeyPolicy sets how Unmarshal handles an SC dictionary
+// that contains the same key more than once.
+//
+// By default, scparse.DuplicateKeysLastWins is used and the last value for a key is used.
+// With scparse.DuplicateKeysError, each duplicate key will cause a *scparse.DuplicateKeyError
+// to be returned during unmarshaling. See scparse.DuplicateKeyPolicy for the other policies.
+func WithDuplicateKeyPolicy(policy scparse.DuplicateKeyPolicy) UnmarshalOption {
+	return func(d *decoder) {
+		d.duplicateKeys = policy
+	}
+}
+
 // TODO(@cszatmary): Does it make sense to allow unknown variables by default?
 // Should it be the other way around?
 
@@ -121,12 +242,273 @@ func WithDisallowUnknownFields(b bool) UnmarshalOption {
 // How variables are ignored depends on where the variable occurs in the SC source.
 // If the variable is a standalone value, the zero value of the destination Go value
 // will be used. If the variable is interpolated in a string, it will be treated as an empty string.
+//
+// Variables with a default value, ex: ${region:-us-east-1}, are never treated as unknown.
+// Instead, the default value is used. Default values are strings. If a standalone variable
+// is unmarshaled into a boolean or number, the default value is parsed into the
+// destination type.
 func WithDisallowUnknownVariables(b bool) UnmarshalOption {
 	return func(d *decoder) {
 		d.disallowUnknownVars = b
 	}
 }
 
+// WithDisallowNullForNonPointer controls how Unmarshal will behave when an SC null
+// is unmarshaled into a Go value that cannot be nil, ex: a bool, number, string or struct.
+//
+// By default, null leaves the destination unchanged, so an explicit null cannot be
+// distinguished from a missing value. If set to true, null will instead cause an
+// UnmarshalTypeError to be returned during unmarshaling. Null is still allowed for pointers,
+// interfaces, maps and slices, and takes precedence over WithWeakTypes.
+func WithDisallowNullForNonPointer(b bool) UnmarshalOption {
+	return func(d *decoder) {
+		d.disallowNull = b
+	}
+}
+
+// WithRequiredVariables makes the variables with the given names required.
+// If a required variable is unknown, an UnmarshalUnknownVariableError is returned during
+// unmarshaling, the same as if WithDisallowUnknownVariables was enabled for that variable only.
+// This allows mixing required variables, ex: secrets, with optional ones.
+// Names are matched against the full variable name, ex: "server.host" for ${server.host}.
+// Variables with a default value are never unknown.
+//
+// WithRequiredVariables can be provided multiple times, the names are combined.
+// Variables that are not referenced by the SC input are not checked.
+func WithRequiredVariables(names ...string) UnmarshalOption {
+	return func(d *decoder) {
+		// Create a new set so that decoders do not share it
+		set := make(map[string]bool, len(d.requiredVars)+len(names))
+		for name := range d.requiredVars {
+			set[name] = true
+		}
+		for _, name := range names {
+			set[name] = true
+		}
+		d.requiredVars = set
+	}
+}
+
+// WithStrictVariableTypes controls how Unmarshal will behave when the value of a
+// standalone variable is not the same type as the destination Go value.
+//
+// By default, the variable value is converted to the destination type if possible.
+// For example, a float64 variable can be unmarshaled into an int, which may
+// lose information. If set to true, the variable value must be assignable to the
+// destination, otherwise an UnmarshalVariableTypeError will be returned.
+func WithStrictVariableTypes(b bool) UnmarshalOption {
+	return func(d *decoder) {
+		d.strictVarTypes = b
+	}
+}
+
+// WithKeepUnknownVariableText controls how Unmarshal will behave when an unknown
+// variable is decoded into a string.
+//
+// By default, unknown variables interpolated in strings are treated as empty strings,
+// and standalone unknown variables leave the destination string unchanged.
+// If set to true, the original variable text, ex: ${name}, is kept in the decoded string
+// instead. This allows partially resolved strings to be passed to a later resolution stage.
+//
+// WithDisallowUnknownVariables takes precedence over this option.
+func WithKeepUnknownVariableText(b bool) UnmarshalOption {
+	return func(d *decoder) {
+		d.keepUnknownVarText = b
+	}
+}
+
+// WithVariableFormatter sets the function used to convert variable values to strings
+// when variables are interpolated in SC strings. f is called with the name of the
+// variable and its value. If f returns an error, the string will not be unmarshaled
+// and the error will be returned during unmarshaling. For expressions, f is called with
+// the text of the expression as the name, ex: ${region ?? "us-east-1"}.
+//
+// By default, nil is converted to an empty string, strings and byte slices are used as is,
+// values implementing encoding.TextMarshaler use the result of MarshalText,
+// and all other values are formatted using fmt.Sprint.
+func WithVariableFormatter(f func(name string, v interface{}) (string, error)) UnmarshalOption {
+	return func(d *decoder) {
+		d.varFormatter = f
+	}
+}
+
+// Func is a function that can be called in an SC variable, ex: ${upper(name)}.
+// It is called with the values of the arguments and returns the value of the call.
+// String literal arguments are passed as strings, variable arguments are passed as the
+// value of the variable, or nil if the variable is unknown, and function call arguments
+// are passed as the value returned by the call.
+//
+// The result of a call is used the same way as the value of a variable, ex: it can
+// be unmarshaled into any Go value it is assignable or convertible to.
+// If a call has a default value, ex: ${env("PORT"):-8080}, the default value is used
+// when the function returns nil.
+type Func func(args ...interface{}) (interface{}, error)
+
+// WithFuncs sets the functions that can be called in SC variables during unmarshaling.
+// The keys of funcs are the function names, ex: "env" for ${env("HOME")}.
+//
+// Calling a function that is not in funcs, or a function that returns an error,
+// causes an UnmarshalFuncError to be returned during unmarshaling.
+// By default, there are no functions.
+func WithFuncs(funcs map[string]Func) UnmarshalOption {
+	return func(d *decoder) {
+		d.funcs = funcs
+	}
+}
+
+// UnmarshalReport describes the variables used while unmarshaling.
+// It can be used to find mistakes in the names of variables, both in the SC document
+// and in the variables provided to Unmarshal. See WithReport.
+// Each list is sorted.
+type UnmarshalReport struct {
+	// UsedVariables are the variables that were referenced and had a value,
+	// ex: server.host for ${server.host}.
+	UsedVariables []string
+	// UnknownVariables are the variables that were referenced but did not have a value.
+	// This includes variables whose default value was used.
+	UnknownVariables []string
+	// UnusedVariables are the top level variables that were provided but never referenced,
+	// including document variables.
+	UnusedVariables []string
+}
+
+// WithReport sets r to a report of the variables used by each call to Unmarshal.
+// Only variables referenced in the unmarshaled values are used, ex: variables referenced
+// outside of the path set with WithDecodePath are not. r is set even if unmarshaling
+// returns an error, unless the input cannot be decoded at all, ex: for a parse error.
+//
+// Since r is overwritten by each call, the same r must not be used by concurrent
+// calls, ex: to the Unmarshal method of a TypeDecoder.
+func WithReport(r *UnmarshalReport) UnmarshalOption {
+	return func(d *decoder) {
+		d.report = r
+	}
+}
+
+// DecodeHook is a function that can take over decoding the SC node n into a
+// Go value of type target. If the hook handles n, it returns the decoded value
+// and true. The value must be assignable to target, or convertible to it if both
+// are numbers or have the same kind, ex: an int64 for a time.Duration. A nil value
+// means the zero value of target. If the hook does not handle n, it returns false
+// and the next hook or the built-in decoding logic is used instead.
+// If the hook returns an error, it is reported as a *DecodeHookError.
+//
+// n is the node as it appears in the SC document, variables are not expanded.
+// Hooks that do not handle variables should return false for nodes containing them.
+type DecodeHook func(n scparse.ValueNode, target reflect.Type) (interface{}, bool, error)
+
+// WithDecodeHook adds a hook that is consulted before the built-in decoding logic
+// for each value that is unmarshaled. This allows converting SC values into types
+// that do not implement Unmarshaler, ex: strings into time.Duration or net.IP values.
+// WithDecodeHook can be provided multiple times, hooks are consulted in the order they were added.
+//
+// If target is a pointer type and the hook does not handle it, the hook is consulted
+// again with the type being pointed to. See DecodeHook for more details.
+func WithDecodeHook(hook DecodeHook) UnmarshalOption {
+	return func(d *decoder) {
+		d.hooks = append(d.hooks[:len(d.hooks):len(d.hooks)], hook)
+	}
+}
+
+// WithDecodePath sets the path of the value in the SC document that should be unmarshaled.
+// Only the value at path is unmarshaled, the rest of the document is ignored.
+// This allows unmarshaling a single section of a document without declaring wrapper types.
+//
+// Paths consist of keys separated by dots and list indices in brackets,
+// ex: services.api.ports[0]. Keys that are not identifiers must be double quoted,
+// ex: headers."content-type". If the path is invalid or the value cannot be found,
+// an error is returned. An empty path refers to the whole document, which is the default.
+func WithDecodePath(path string) UnmarshalOption {
+	return func(d *decoder) {
+		d.path = path
+	}
+}
+
+// WithMaxErrors sets the maximum number of errors that are collected during unmarshaling.
+// Once the limit is reached, unmarshaling stops and the collected errors are returned.
+// This avoids reporting thousands of errors caused by a single systemic mistake in a large document.
+// If WithSortErrors is also enabled, unmarshaling does not stop early. Instead, all errors
+// are collected and sorted, and the first n errors by position are returned.
+//
+// By default, there is no limit. A value <= 0 also means there is no limit.
+func WithMaxErrors(n int) UnmarshalOption {
+	return func(d *decoder) {
+		d.maxErrors = n
+	}
+}
+
+// WithSortErrors controls the order of the errors returned by Unmarshal.
+//
+// By default, errors are returned in the order they were encountered while
+// unmarshaling, which depends on the destination Go type. If set to true, errors are
+// sorted by their position in the SC input. Errors without a position are placed last.
+func WithSortErrors(b bool) UnmarshalOption {
+	return func(d *decoder) {
+		d.sortErrors = b
+	}
+}
+
+// WithTagName sets the name of the struct tag used to determine the SC key
+// and options of struct fields, ex: "yaml" to use `yaml:"name,omitempty"` tags.
+//
+// By default, the sc tag is used. An empty name also means the sc tag is used.
+func WithTagName(name string) UnmarshalOption {
+	return func(d *decoder) {
+		d.tags.name = name
+	}
+}
+
+// WithFallbackToJSONTags controls whether the json struct tag is used for
+// struct fields that do not have an sc tag, or the tag set by WithTagName.
+// This allows structs that are already annotated for encoding/json to be
+// used without duplicating every tag.
+//
+// By default, the json tag is ignored.
+func WithFallbackToJSONTags(b bool) UnmarshalOption {
+	return func(d *decoder) {
+		d.tags.fallbackJSON = b
+	}
+}
+
+// WithDurationStrings controls whether SC strings can be unmarshaled into
+// time.Duration values. If set to true, strings are parsed with time.ParseDuration,
+// ex: "30s" or "1h30m". This includes variables with string values.
+// Numbers are always unmarshaled as a number of nanoseconds.
+//
+// By default, only numbers can be unmarshaled into time.Duration values.
+func WithDurationStrings(b bool) UnmarshalOption {
+	return func(d *decoder) {
+		d.durationStrings = b
+	}
+}
+
+// WithWeakTypes controls whether SC values are converted between strings, numbers and bools
+// when they do not match the type of the destination Go value.
+//
+// By default, the SC value must match the destination type. If set to true, numbers and bools
+// are unmarshaled into strings using their text, ex: 8080 becomes "8080", and null is unmarshaled
+// into strings as an empty string. Strings are unmarshaled into bools and numbers if they can be
+// parsed as the destination type, ex: "true" or "8080", otherwise an UnmarshalTypeError is returned.
+// The values of variables are converted the same way, ex: a variable with the int value 8080
+// is unmarshaled into a string as "8080".
+// This allows values like map[string]string to be unmarshaled without quoting every value.
+func WithWeakTypes(b bool) UnmarshalOption {
+	return func(d *decoder) {
+		d.weakTypes = b
+	}
+}
+
+// WithTimeFormat sets the layout used to parse SC strings, and variables with string values,
+// into time.Time values, see time.Parse. The "format" tag option of a struct field takes precedence.
+//
+// By default, time.Time values are unmarshaled using their UnmarshalText method,
+// which requires the RFC 3339 format. An empty layout also means the default is used.
+func WithTimeFormat(layout string) UnmarshalOption {
+	return func(d *decoder) {
+		d.timeFormat = layout
+	}
+}
+
 // Unmarshaler is the interface implemented by types that can unmarshal
 // a SC description of themselves. This can be used to customize the unmarshaling
 // process for a type.
@@ -134,23 +516,102 @@ type Unmarshaler interface {
 	UnmarshalSC(scparse.ValueNode, Variables) error
 }
 
-// TODO(@cszatmary): Does it make sense to have Decoder?
-// It doesn't have the same behaviour as other decoders, ex json.Decoder,
-// because it reads everything from the reader. Maybe we should replace
-// this with a UnmarshalReader function instead?
+// Validator is the interface implemented by types that can validate themselves
+// once they have been unmarshaled from an SC dictionary. This allows checking
+// constraints between fields without implementing Unmarshaler.
+//
+// ValidateSC is called after the value has been unmarshaled successfully,
+// it is not called if an error occurred while unmarshaling the value.
+// A non-nil error is reported as a *ValidationError that contains the position
+// of the dictionary. If the error is an Errors, each error is reported separately.
+type Validator interface {
+	ValidateSC() error
+}
+
+// RawNode holds an SC value that has not been decoded yet.
+// It implements Unmarshaler and Marshaler and can be used to delay decoding
+// part of an SC document until more information is known, ex: the type of a plugin
+// whose configuration is stored in the document.
+//
+// RawNode is similar to unmarshaling into a scparse.ValueNode, however, it also
+// retains the variables that were provided during unmarshaling so that
+// they can be used when the node is decoded later.
+type RawNode struct {
+	Node scparse.ValueNode // The SC value. It is nil if the value was missing.
+	Vars Variables         // The variables provided when Node was unmarshaled.
+}
+
+// UnmarshalSC stores n and vars in r.
+func (r *RawNode) UnmarshalSC(n scparse.ValueNode, vars Variables) error {
+	r.Node = n
+	r.Vars = vars
+	return nil
+}
+
+// MarshalSC returns r.Node, or a null node if r.Node is nil.
+func (r RawNode) MarshalSC() (scparse.ValueNode, error) {
+	if r.Node == nil {
+		return &scparse.NullNode{}, nil
+	}
+	return r.Node, nil
+}
+
+// Decode unmarshals the raw node into the value pointed to by v.
+// The variables stored in r are used unless opts contains WithVariables.
+// If r.Node is nil, it is treated as SC null.
+//
+// See the documentation for Unmarshal for details on the unmarshal process.
+func (r RawNode) Decode(v interface{}, opts ...UnmarshalOption) error {
+	n := r.Node
+	if n == nil {
+		n = &scparse.NullNode{}
+	}
+	opts = append([]UnmarshalOption{WithVariables(r.Vars)}, opts...)
+	return UnmarshalNode(n, v, opts...)
+}
+
+// UnmarshalReader is like Unmarshal but it reads the SC-encoded data from r.
+//
+// UnmarshalReader reads the entire contents of r and expects r to only contain
+// a single SC document. See the documentation for Unmarshal for details on the
+// unmarshal process.
+func UnmarshalReader(r io.Reader, v interface{}, opts ...UnmarshalOption) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	return Unmarshal(data, v, opts...)
+}
 
 // A Decoder reads and decodes SC values from an input stream.
+//
+// Unlike decoders for other formats, ex: json.Decoder, a Decoder does not
+// decode a stream of values. Each call to Decode reads the entire contents of
+// the reader and decodes it as a single SC document. A Decoder is useful for
+// configuring decoding options once. For one-off decoding, UnmarshalReader is simpler.
+//
+// A Decoder can be reused with Reset. Buffers used while decoding are kept
+// between calls to Decode, which reduces allocations when decoding many documents.
 type Decoder struct {
-	r io.Reader
-	d decoder
+	r   io.Reader
+	d   decoder
+	buf bytes.Buffer // holds the contents of r
 }
 
 // NewDecoder returns a new decoder that reads from r.
 //
 // The decoder will read the entire contents of r and expects r to
 // only contain valid SC data.
-func NewDecoder(r io.Reader) *Decoder {
-	return &Decoder{r: r}
+//
+// NewDecoder can optionally be provided options which are used for every call to Decode.
+// Any UnmarshalOption can be used. The Decoder methods only cover some of the options,
+// providing options to NewDecoder is the preferred way to configure a Decoder.
+// If an option is invalid, the error is returned by Decode.
+func NewDecoder(r io.Reader, opts ...UnmarshalOption) *Decoder {
+	dec := &Decoder{r: r}
+	// The error is stored in dec.d and returned by Decode
+	_ = dec.d.applyOptions(opts)
+	return dec
 }
 
 // Variables sets the variables that should be used during decoding.
@@ -158,6 +619,11 @@ func (dec *Decoder) Variables(vars Variables) {
 	dec.d.vars = vars
 }
 
+// ParseOptions sets the options that are used when parsing the SC data.
+func (dec *Decoder) ParseOptions(opts ...scparse.ParseOption) {
+	dec.d.parseOpts = opts
+}
+
 // DisallowUnknownFields controls how the Decoder will behave when the destination
 // is a struct and the input contains dictionary keys which do not match any
 // non-ignored, exported fields in the destination.
@@ -168,6 +634,21 @@ func (dec *Decoder) DisallowUnknownFields(b bool) {
 	dec.d.disallowUnknownFields = b
 }
 
+// DisallowDuplicateKeys controls how the Decoder will behave when an SC dictionary
+// contains the same key more than once.
+//
+// By default, duplicate keys are silently allowed and the last value for a key is used.
+// If set to true, each duplicate key will instead cause a *scparse.DuplicateKeyError
+// to be returned during decoding.
+// This is the same as providing WithDuplicateKeyPolicy(scparse.DuplicateKeysError) to NewDecoder.
+func (dec *Decoder) DisallowDuplicateKeys(b bool) {
+	if b {
+		dec.d.duplicateKeys = scparse.DuplicateKeysError
+	} else {
+		dec.d.duplicateKeys = scparse.DuplicateKeysLastWins
+	}
+}
+
 // DisallowUnknownVariables controls how the Decoder will behave when a variable is being
 // decoded and no matching variable value is found.
 //
@@ -182,19 +663,81 @@ func (dec *Decoder) DisallowUnknownVariables(b bool) {
 	dec.d.disallowUnknownVars = b
 }
 
+// StrictVariableTypes controls how the Decoder will behave when the value of a
+// standalone variable is not the same type as the destination Go value.
+//
+// By default, the variable value is converted to the destination type if possible.
+// If set to true, the variable value must be assignable to the destination,
+// otherwise an UnmarshalVariableTypeError will be returned.
+func (dec *Decoder) StrictVariableTypes(b bool) {
+	dec.d.strictVarTypes = b
+}
+
+// KeepUnknownVariableText controls how the Decoder will behave when an unknown
+// variable is decoded into a string.
+//
+// See WithKeepUnknownVariableText for more details.
+func (dec *Decoder) KeepUnknownVariableText(b bool) {
+	dec.d.keepUnknownVarText = b
+}
+
+// VariableFormatter sets the function used to convert variable values to strings
+// when variables are interpolated in SC strings.
+//
+// See WithVariableFormatter for more details.
+func (dec *Decoder) VariableFormatter(f func(name string, v interface{}) (string, error)) {
+	dec.d.varFormatter = f
+}
+
+// MaxErrors sets the maximum number of errors that are collected during decoding.
+//
+// See WithMaxErrors for more details.
+func (dec *Decoder) MaxErrors(n int) {
+	dec.d.maxErrors = n
+}
+
+// SortErrors controls whether errors are sorted by their position in the SC input.
+//
+// See WithSortErrors for more details.
+func (dec *Decoder) SortErrors(b bool) {
+	dec.d.sortErrors = b
+}
+
+// DecodePath sets the path of the value in the SC document that should be decoded.
+//
+// See WithDecodePath for more details.
+func (dec *Decoder) DecodePath(path string) {
+	dec.d.path = path
+}
+
 // Decode reads the SC-encoded value from its input and stores it in the value pointed to by v.
 //
 // See the documentation for Unmarshal for details about the decoding process.
 func (dec *Decoder) Decode(v interface{}) error {
-	data, err := io.ReadAll(dec.r)
-	if err != nil {
+	if dec.d.optErr != nil {
+		return dec.d.optErr
+	}
+	dec.buf.Reset()
+	if _, err := dec.buf.ReadFrom(dec.r); err != nil {
 		return err
 	}
-	n, err := scparse.Parse(data)
+	n, err := scparse.Parse(dec.buf.Bytes(), dec.d.parseOpts...)
 	if err != nil {
 		return err
 	}
-	return dec.d.unmarshal(n, v)
+	// Use a copy so that errors from one call do not leak into the next
+	d := dec.d
+	err = d.unmarshal(n, v)
+	// Keep the buffers for the next call
+	dec.d.errorContext.FieldStack = d.errorContext.FieldStack[:0]
+	dec.d.scratch = d.scratch[:0]
+	return err
+}
+
+// Reset changes the reader that dec reads from to r. The options of dec are kept.
+// This allows a Decoder to be reused instead of allocating a new one for each input.
+func (dec *Decoder) Reset(r io.Reader) {
+	dec.r = r
 }
 
 // UnmarshalTypeError describes a SC value that was not
@@ -214,6 +757,53 @@ func (e *UnmarshalTypeError) Error() string {
 	return fmt.Sprintf("sc: cannot unmarshal %s into Go value of type %s", e.NodeType, e.Type.String())
 }
 
+// UnmarshalVariableTypeError describes a variable value that was not
+// assignable to a Go value of a specified type.
+// It is only returned if WithStrictVariableTypes is enabled.
+type UnmarshalVariableTypeError struct {
+	Variable     string       // The name of the variable.
+	VariableType reflect.Type // Type of the variable value.
+	Type         reflect.Type // Type of Go value.
+	Pos          scparse.Pos  // Position of the SC node in the input text.
+	Struct       string       // Name of the struct type containing the field.
+	Field        string       // The full path from the root struct to the field.
+}
+
+func (e *UnmarshalVariableTypeError) Error() string {
+	if e.Struct != "" || e.Field != "" {
+		return fmt.Sprintf("sc: cannot unmarshal variable %q of type %s into Go struct field %s.%s of type %s", e.Variable, e.VariableType, e.Struct, e.Field, e.Type)
+	}
+	return fmt.Sprintf("sc: cannot unmarshal variable %q of type %s into Go value of type %s", e.Variable, e.VariableType, e.Type)
+}
+
+// UnmarshalMissingFieldError describes a struct field with the "required" tag option
+// that did not have a corresponding member in an SC dictionary.
+type UnmarshalMissingFieldError struct {
+	Struct string      // Name of the struct type containing the field.
+	Field  string      // The full path from the root struct to the field.
+	Pos    scparse.Pos // Position of the SC dictionary in the input text.
+}
+
+func (e *UnmarshalMissingFieldError) Error() string {
+	return fmt.Sprintf("sc: missing required Go struct field %s.%s", e.Struct, e.Field)
+}
+
+// UnmarshalUnknownFieldError describes a key in an SC dictionary that did not
+// match any field in the destination Go struct.
+// It is only returned if WithDisallowUnknownFields is enabled.
+type UnmarshalUnknownFieldError struct {
+	Key    string      // The dictionary key.
+	Struct string      // Name of the struct type being unmarshaled into.
+	Pos    scparse.Pos // Position of the key in the input text.
+}
+
+func (e *UnmarshalUnknownFieldError) Error() string {
+	if e.Struct != "" {
+		return fmt.Sprintf("sc: unknown field %q in Go struct %s", e.Key, e.Struct)
+	}
+	return fmt.Sprintf("sc: unknown field %q", e.Key)
+}
+
 // UnmarshalUnknownVariableError describes a SC variable that did not have an
 // associated value during unmarshaling.
 type UnmarshalUnknownVariableError struct {
@@ -225,6 +815,67 @@ func (e *UnmarshalUnknownVariableError) Error() string {
 	return fmt.Sprintf("sc: unknown variable %q", e.Variable)
 }
 
+// UnmarshalFuncError describes a function call in an SC variable that failed,
+// either because the function does not exist or because it returned an error.
+type UnmarshalFuncError struct {
+	Func string      // The name of the function.
+	Err  error       // The error returned by the function. It is nil if the function does not exist.
+	Pos  scparse.Pos // Position of the SC node in the input text.
+}
+
+func (e *UnmarshalFuncError) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("sc: unknown function %q", e.Func)
+	}
+	return fmt.Sprintf("sc: calling function %q: %v", e.Func, e.Err)
+}
+
+func (e *UnmarshalFuncError) Unwrap() error {
+	return e.Err
+}
+
+// ValidationError describes an error returned by the ValidateSC method
+// of a value that implements Validator.
+type ValidationError struct {
+	Type   reflect.Type // Type of the Go value that failed validation.
+	Err    error        // The error returned by ValidateSC.
+	Pos    scparse.Pos  // Position of the SC dictionary in the input text.
+	Struct string       // Name of the struct type containing the field.
+	Field  string       // The full path from the root struct to the field.
+}
+
+func (e *ValidationError) Error() string {
+	if e.Struct != "" || e.Field != "" {
+		return fmt.Sprintf("sc: invalid Go struct field %s.%s of type %s: %v", e.Struct, e.Field, e.Type, e.Err)
+	}
+	return fmt.Sprintf("sc: invalid Go value of type %s: %v", e.Type, e.Err)
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// DecodeHookError describes an error returned by a DecodeHook, or a value returned
+// by a DecodeHook that could not be assigned to the Go value.
+type DecodeHookError struct {
+	Type   reflect.Type // Type of the Go value.
+	Err    error        // The details of the error.
+	Pos    scparse.Pos  // Position of the SC node in the input text.
+	Struct string       // Name of the struct type containing the field.
+	Field  string       // The full path from the root struct to the field.
+}
+
+func (e *DecodeHookError) Error() string {
+	if e.Struct != "" || e.Field != "" {
+		return fmt.Sprintf("sc: cannot decode into Go struct field %s.%s of type %s: %v", e.Struct, e.Field, e.Type, e.Err)
+	}
+	return fmt.Sprintf("sc: cannot decode into Go value of type %s: %v", e.Type, e.Err)
+}
+
+func (e *DecodeHookError) Unwrap() error {
+	return e.Err
+}
+
 // InvalidUnmarshalError describes an invalid argument passed to Unmarshal.
 // (The argument to Unmarshal must be a non-nil pointer.)
 type InvalidUnmarshalError struct {
@@ -261,6 +912,60 @@ func (e Errors) Error() string {
 	return sb.String()
 }
 
+// FormatError formats err for display to humans. If err contains the position
+// in the SC input where the error occurred, the result contains the error message
+// followed by a code frame of the surrounding source, see scparse.Snippet.
+// Otherwise, the result is the error message. data must be the SC input that
+// was being unmarshaled when err occurred.
+//
+// If err is an Errors, each error in the list is formatted.
+func FormatError(data []byte, err error) string {
+	if errs, ok := err.(Errors); ok {
+		var parts []string
+		for _, e := range errs {
+			parts = append(parts, FormatError(data, e))
+		}
+		return strings.Join(parts, "\n")
+	}
+	pos := errorPos(err)
+	if pos == (scparse.Pos{}) {
+		return scparse.FormatError(data, err)
+	}
+	return scparse.FormatErrorAt(data, err, pos)
+}
+
+// errorPos returns the position in the SC input where err occurred.
+// It returns the zero value if err does not have a position.
+func errorPos(err error) scparse.Pos {
+	switch e := err.(type) {
+	case *UnmarshalTypeError:
+		return e.Pos
+	case *UnmarshalVariableTypeError:
+		return e.Pos
+	case *UnmarshalMissingFieldError:
+		return e.Pos
+	case *UnmarshalUnknownFieldError:
+		return e.Pos
+	case *UnmarshalUnknownVariableError:
+		return e.Pos
+	case *UnmarshalFuncError:
+		return e.Pos
+	case *ValidationError:
+		return e.Pos
+	case *DecodeHookError:
+		return e.Pos
+	case *scparse.Error:
+		return e.Pos
+	case *scparse.DuplicateKeyError:
+		return e.Pos
+	case *scparse.LimitError:
+		return e.Pos
+	case *scparse.UnresolvedVariableError:
+		return e.Pos
+	}
+	return scparse.Pos{}
+}
+
 // Variables represents a set of variables provided during the unmarshaling process.
 // It allows for looking up a variable value from a VariableNode.
 //
@@ -271,17 +976,19 @@ type Variables struct {
 	v reflect.Value
 	// The type of the map key. Cached for easy conversion during lookups.
 	kt reflect.Type
+	// Additional sets of variables that are searched, in order, if a variable
+	// is not found in v. Used to support merging variables.
+	fallbacks []Variables
 }
 
-// TODO(@cszatmary): A possible alternative to requiring NewVariables/MustVariables
-// is to change the definition of UnmarshalOption to return an error.
-// Then options (like WithVariables) could return an error which Unmarshal would
-// check when calling them. Something to consider as it would mean less API.
-// The downside is it would not be as clear if the an error was caused by incorrect variables.
-
 // NewVariables creates a new Variables instance using the variable values v.
 // v must be a map whose keys are a string type.
 //
+// Variables can reference nested values using a path, ex: ${server.host}.
+// The first component of the path is looked up in v. Each following component
+// is looked up in the previous value, which must be a map with string keys or a struct.
+// Struct fields are matched the same way as during unmarshaling.
+//
 // If v is not a valid type, an error will be returned.
 // NewVariables(nil) returns the zero value.
 func NewVariables(v interface{}) (Variables, error) {
@@ -309,13 +1016,111 @@ func MustVariables(v interface{}) Variables {
 	return vars
 }
 
+// MergeVariables combines multiple sets of variables into a single Variables instance.
+// When looking up a variable, later arguments take precedence over earlier ones.
+// This allows for layering variables, ex: defaults, followed by environment
+// variables, followed by values for a specific invocation.
+//
+// The sets of variables are not copied, therefore, any changes to the underlying
+// maps will be reflected in the merged variables.
+func MergeVariables(vars ...Variables) Variables {
+	var merged Variables
+	for i := len(vars) - 1; i >= 0; i-- {
+		merged.fallbacks = append(merged.fallbacks, vars[i])
+	}
+	return merged
+}
+
+// names returns the names of the top level variables in vars, including the
+// variables of merged sets. A name may be returned more than once.
+func (vars Variables) names() []string {
+	var names []string
+	if vars.v.IsValid() {
+		for _, k := range vars.v.MapKeys() {
+			names = append(names, k.String())
+		}
+	}
+	for _, fb := range vars.fallbacks {
+		names = append(names, fb.names()...)
+	}
+	return names
+}
+
 func (vars Variables) lookup(n *scparse.VariableNode) reflect.Value {
-	// Handle zero value vars
-	if !vars.v.IsValid() {
-		return reflect.Value{}
+	return vars.lookupPath(n.Path())
+}
+
+func (vars Variables) lookupPath(path []string) reflect.Value {
+	// v is invalid for zero value or merged vars
+	if vars.v.IsValid() {
+		kv := reflect.ValueOf(path[0])
+		v := vars.v.MapIndex(kv.Convert(vars.kt))
+		for _, key := range path[1:] {
+			if !v.IsValid() {
+				break
+			}
+			v = lookupKey(v, key)
+		}
+		if v.IsValid() {
+			return v
+		}
+	}
+	for _, fb := range vars.fallbacks {
+		if v := fb.lookupPath(path); v.IsValid() {
+			return v
+		}
 	}
-	kv := reflect.ValueOf(n.Identifier.Name)
-	return vars.v.MapIndex(kv.Convert(vars.kt))
+	return reflect.Value{}
+}
+
+// lookupKey finds the value of key in v. v can be a map with string keys or a struct.
+// Pointers and interfaces are followed. If no value is found, the invalid value is returned.
+func lookupKey(v reflect.Value, key string) reflect.Value {
+	for v.Kind() == reflect.Interface || v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Map:
+		kt := v.Type().Key()
+		if kt.Kind() != reflect.String {
+			return reflect.Value{}
+		}
+		return v.MapIndex(reflect.ValueOf(key).Convert(kt))
+	case reflect.Struct:
+		fields := cachedTypeFields(v.Type(), tagConfig{})
+		var f *field
+		if i, ok := fields.nameIndex[key]; ok {
+			f = &fields.list[i]
+		} else {
+			for i := range fields.list {
+				if strings.EqualFold(fields.list[i].name, key) {
+					f = &fields.list[i]
+					break
+				}
+			}
+		}
+		if f == nil {
+			return reflect.Value{}
+		}
+		for _, i := range f.index {
+			if v.Kind() == reflect.Ptr {
+				if v.IsNil() {
+					return reflect.Value{}
+				}
+				v = v.Elem()
+			}
+			v = v.Field(i)
+		}
+		// Fields promoted through unexported embedded structs cannot be accessed
+		if !v.CanInterface() {
+			return reflect.Value{}
+		}
+		return v
+	}
+	return reflect.Value{}
 }
 
 // Lookup finds the variable value matching n if it exists.
@@ -328,6 +1133,71 @@ func (vars Variables) Lookup(n *scparse.VariableNode) (interface{}, bool) {
 	return v.Interface(), true
 }
 
+// ListVariables parses the SC-encoded data and returns all variables referenced in it.
+// Each variable is returned once, along with the positions of all references to it.
+// This can be used to determine which variables need to be provided before unmarshaling.
+//
+// See scparse.ListVariables for more details.
+func ListVariables(data []byte) ([]scparse.VariableRef, error) {
+	n, err := scparse.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	return scparse.ListVariables(n), nil
+}
+
+// Edit parses the SC document data, calls fn with the parsed AST so that it can be
+// modified, and returns the resulting document. If fn returns an error, Edit returns it.
+//
+// Unlike formatting with scparse.Format, the parts of the document that were not
+// modified by fn are kept exactly as they appeared in data, including comments and
+// whitespace. Only the modified dictionary members and list elements are reformatted,
+// at the indentation of the lines they replace and keeping the commas after them.
+// This allows for making targeted changes to user owned documents, ex: bumping a version,
+// without rewriting the whole document. See scparse.WithPreserveSource for more details.
+func Edit(data []byte, fn func(*scparse.DictionaryNode) error) ([]byte, error) {
+	n, err := scparse.Parse(data, scparse.WithPreserveSource(true))
+	if err != nil {
+		return nil, err
+	}
+	if err := fn(n); err != nil {
+		return nil, err
+	}
+	return scparse.Format(n), nil
+}
+
+// Resolve returns a copy of n where each variable has been replaced with its value.
+// The variables are provided using the WithVariables option. Variable values are
+// converted to SC nodes the same way as Marshal. Variables interpolated in strings
+// are replaced with their string representation. Expressions are replaced with their
+// result, and function calls are replaced with their result if WithFuncs is provided.
+//
+// By default, unknown variables, and expressions that evaluate to an unknown variable,
+// are left as is in the returned AST. If WithDisallowUnknownVariables(true) is provided,
+// an UnmarshalUnknownVariableError will be returned for the first unknown variable instead.
+// If WithDocumentVariables is provided, the document variables member is removed from n
+// and its variables are used as well. Other options have no effect.
+//
+// Resolve allows for rendering an SC document with a set of variables while still
+// producing SC. The result can be formatted using scparse.Format.
+func Resolve(n scparse.ValueNode, opts ...UnmarshalOption) (scparse.ValueNode, error) {
+	var r resolver
+	if err := r.d.applyOptions(opts); err != nil {
+		return nil, err
+	}
+	n, err := scparse.ExpandAnchors(n)
+	if err != nil {
+		return nil, err
+	}
+	if dn, ok := n.(*scparse.DictionaryNode); ok && r.d.documentVars != "" {
+		n = r.d.extractDocumentVariables(dn)
+		if len(r.d.errors) > 0 {
+			return nil, r.d.errors[0]
+		}
+	}
+	return r.resolve(n)
+}
+
 ///// Marshaling & Encoding /////
 
 // Marshal encodes v into an SC representation and returns the data.
@@ -344,17 +1214,371 @@ func (vars Variables) Lookup(n *scparse.VariableNode) (interface{}, bool) {
 // optionally followed by a comma-separated list of options. The name of the field
 // can be omitted to specify options without overridding the default field name.
 // If the tag value is "-", then the field will be omitted.
+// WithMarshalTagName and WithMarshalFallbackToJSONTags allow other tags, ex: json, to be used instead.
 //
 // The "omitempty" option causes the field to be omitted if it is an empty value.
 // Empty values are false, 0, a nil pointer, a nil interface value,
 // and an empty array, slice, map, or string.
-func Marshal(v interface{}) ([]byte, error) {
+//
+// The "omitnil" option causes the field to be omitted if it is a nil pointer,
+// interface, map or slice. Unlike "omitempty", empty non-nil values are still encoded.
+//
+// The "omitzero" option causes the field to be omitted if it is the zero value of its type.
+// If the field has an IsZero() bool method, ex: time.Time, the method is used instead.
+// Unlike "omitempty", this also applies to structs and arrays.
+//
+// The "string" option signals that a field is stored as a string. It applies only to fields
+// of boolean, integer, or floating point types, or pointers to these types. The field
+// is marshaled as an SC string containing the value. When unmarshaling, the string is
+// parsed into the field. Non-string SC values are unmarshaled normally, this allows
+// the value to be provided either as a string or as an SC value of the matching type.
+//
+// The "inline" option causes the fields of a struct field to be encoded as if they
+// were fields of the outer struct, the same way the fields of an embedded struct are.
+// This allows for flattening structs that cannot be embedded. The field name is ignored.
+// The option has no effect if the field is not a struct or a pointer to a struct.
+//
+// The "remain" option designates a map field with string keys, ex: map[string]interface{}
+// or map[string]scparse.ValueNode, that holds the dictionary members that do not
+// match any other field. When unmarshaling, such members are stored in the map instead
+// of being ignored. When marshaling, the map entries are encoded as members of the
+// struct's dictionary after the fields, unless a field has the same name.
+// The field name is ignored. The option has no effect if the field is not a map with string keys.
+//
+// The "secret" option marks a field that contains sensitive data, ex: a password.
+// The value of the field is replaced when marshaling based on WithSecretMode.
+// The option has no effect on unmarshaling.
+//
+// The "asvar=name" option causes the field to be encoded as a reference to the
+// variable name, ex: `sc:"port,asvar=port"` encodes ${port}, when marshaling with
+// WithTemplateVariables. The option has no effect on unmarshaling.
+//
+// The "order=n" option sets the position of the field when marshaling with
+// WithFieldOrder(FieldOrderTag), ex: `sc:"name,order=1"`. n must be an integer.
+// The option has no effect on unmarshaling or with other field orders.
+//
+// The "format=layout" option sets the layout used to format and parse a time.Time field,
+// ex: `sc:"created,format=2006-01-02"`. See the time package for the layout syntax.
+// Since options are separated by commas, the layout cannot contain a comma. Instead, the
+// layouts defined by the time package can be used by name, ex: format=RFC1123 for time.RFC1123.
+// When unmarshaling, variables with string values are parsed using the layout as well.
+// The option has no effect if the field is not a time.Time or a pointer to one.
+//
+// By default, []byte values are encoded as standard base64 strings. The "hex" and
+// "base64url" options cause a []byte field to be encoded as a hex or unpadded URL-safe
+// base64 string instead, and the "bytelist" option causes it to be encoded as a list of numbers.
+// When unmarshaling, strings are decoded using the same encoding, padding is optional for base64url.
+// A list of numbers can always be unmarshaled into a []byte.
+// The options have no effect if the field is not a []byte or a pointer to one.
+//
+// Marshal can optionally be provided additional option arguments that modify the marshal process.
+// See the documentation for each MarshalOption to learn more.
+func Marshal(v interface{}, opts ...MarshalOption) ([]byte, error) {
 	var e encoder
+	for _, opt := range opts {
+		opt(&e)
+	}
 	n, err := e.marshal(v)
 	if err != nil {
 		return nil, err
 	}
-	return scparse.Format(n), nil
+	return e.format(n), nil
+}
+
+// MarshalNode returns the SC AST of v. Unlike Marshal, v is not required to
+// encode to a dictionary, any Go value that can be represented as an SC value is allowed.
+//
+// The returned node can be modified, ex: to attach comments or merge it into
+// another document, before it is formatted with scparse.Format.
+//
+// See the documentation for Marshal for details about the encoding process.
+func MarshalNode(v interface{}, opts ...MarshalOption) (scparse.ValueNode, error) {
+	var e encoder
+	for _, opt := range opts {
+		opt(&e)
+	}
+	return e.marshalValue(v)
+}
+
+// MarshalOption is an option that can be provided to Marshal to customize
+// behaviour during the marshaling process.
+//
+// The signature contains an unexported type so that only options defined in this
+// package are valid.
+type MarshalOption func(*encoder)
+
+// WithSortKeys controls the order struct fields are encoded in.
+//
+// By default, struct fields are encoded in the order they are declared.
+// If set to true, struct fields are sorted by their key instead. Map keys are always sorted.
+// To also sort dictionaries that come from nodes or Marshaler implementations,
+// use the SortKeys format option.
+// This is the same as using WithFieldOrder(FieldOrderKey).
+func WithSortKeys(b bool) MarshalOption {
+	return func(e *encoder) {
+		if b {
+			e.fieldOrder = FieldOrderKey
+		} else {
+			e.fieldOrder = FieldOrderDeclaration
+		}
+	}
+}
+
+// WithMapKeyLess sets the function used to sort the keys of maps. less reports
+// whether the key a should be encoded before the key b. Keys that are neither less
+// than each other keep their map order, which is random, so less should be a total order.
+// Map keys that implement encoding.TextMarshaler are sorted by their text.
+// NaturalLess can be used to sort keys containing numbers by their value.
+//
+// By default, or if less is nil, map keys are sorted lexicographically, ex: "10" sorts before "2".
+func WithMapKeyLess(less func(a, b string) bool) MarshalOption {
+	return func(e *encoder) {
+		e.mapKeyLess = less
+	}
+}
+
+// SecretMode controls how struct fields with the "secret" tag option are marshaled.
+type SecretMode int
+
+const (
+	// SecretsShown encodes secret fields like any other field. This is the default.
+	SecretsShown SecretMode = iota
+	// SecretsRedacted encodes the value of secret fields as the string "***".
+	SecretsRedacted
+	// SecretsAsVariables encodes the value of secret fields as a variable named
+	// after the key of the field, ex: ${password}. This allows the output to be
+	// unmarshaled with the secrets provided as variables.
+	// If the key is not a valid variable name, a MarshalError is returned.
+	SecretsAsVariables
+)
+
+// redactedSecret is the value of secret fields with SecretsRedacted.
+const redactedSecret = "***"
+
+// WithSecretMode sets how struct fields with the "secret" tag option are marshaled.
+// This allows values like passwords and tokens to be left out when a configuration
+// is written to logs. Secret fields that are omitted by omitempty, omitnil or omitzero
+// are still omitted.
+//
+// By default, SecretsShown is used.
+func WithSecretMode(mode SecretMode) MarshalOption {
+	return func(e *encoder) {
+		e.secretMode = mode
+	}
+}
+
+// WithTemplateVariables controls whether struct fields with the "asvar=name" tag option
+// are marshaled as a reference to the variable name, ex: ${port}, instead of their value.
+// This allows SC templates to be generated from Go values, the template can then be
+// unmarshaled with the variables provided using WithVariables.
+// If the name is not a valid variable name, a MarshalError is returned.
+// The option takes precedence over WithSecretMode.
+//
+// By default, the asvar option is ignored and fields are encoded normally.
+func WithTemplateVariables(b bool) MarshalOption {
+	return func(e *encoder) {
+		e.templateVars = b
+	}
+}
+
+// FieldOrder controls the order struct fields are encoded in.
+type FieldOrder int
+
+const (
+	// FieldOrderDeclaration encodes struct fields in the order they are declared.
+	// The fields of embedded and inlined structs are encoded in place of the struct.
+	// This is the default.
+	FieldOrderDeclaration FieldOrder = iota
+	// FieldOrderKey sorts struct fields by their key.
+	FieldOrderKey
+	// FieldOrderTag sorts struct fields by the "order=n" tag option, ex: `sc:"name,order=1"`.
+	// Fields with a lower order are encoded first. Fields without the option are encoded
+	// after the fields with the option. Fields with the same order, or without the option,
+	// are encoded in the order they are declared.
+	FieldOrderTag
+)
+
+// WithFieldOrder sets the order struct fields are encoded in.
+// Members of a field with the remain option are encoded after the other fields,
+// unless the order is FieldOrderKey.
+//
+// By default, FieldOrderDeclaration is used.
+func WithFieldOrder(order FieldOrder) MarshalOption {
+	return func(e *encoder) {
+		e.fieldOrder = order
+	}
+}
+
+// WithFormatOptions sets the options used to format the SC output,
+// ex: the indentation and newline style. See scparse.FormatOptions for details.
+func WithFormatOptions(opts scparse.FormatOptions) MarshalOption {
+	return func(e *encoder) {
+		e.formatOpts = opts
+	}
+}
+
+// WithMarshalTagName sets the name of the struct tag used to determine the SC key
+// and options of struct fields. It is the Marshal equivalent of WithTagName.
+//
+// By default, the sc tag is used. An empty name also means the sc tag is used.
+func WithMarshalTagName(name string) MarshalOption {
+	return func(e *encoder) {
+		e.tags.name = name
+	}
+}
+
+// WithMarshalFallbackToJSONTags controls whether the json struct tag is used for
+// struct fields that do not have an sc tag, or the tag set by WithMarshalTagName.
+// It is the Marshal equivalent of WithFallbackToJSONTags.
+//
+// By default, the json tag is ignored.
+func WithMarshalFallbackToJSONTags(b bool) MarshalOption {
+	return func(e *encoder) {
+		e.tags.fallbackJSON = b
+	}
+}
+
+// WithMarshalDurationStrings controls how time.Duration values are marshaled.
+// If set to true, they are encoded as SC strings in the format used by
+// time.Duration.String, ex: "1h30m0s". It is the Marshal equivalent of WithDurationStrings.
+//
+// By default, time.Duration values are encoded as a number of nanoseconds.
+func WithMarshalDurationStrings(b bool) MarshalOption {
+	return func(e *encoder) {
+		e.durationStrings = b
+	}
+}
+
+// WithMarshalEmptyCollections controls how nil slices and maps are marshaled.
+// If set to true, nil slices are encoded as empty SC lists and nil maps as empty
+// SC dictionaries. A nil []byte is encoded as an empty value in its byte encoding.
+// Nil pointers and interfaces are still encoded as null.
+//
+// By default, nil slices and maps are encoded as null.
+func WithMarshalEmptyCollections(b bool) MarshalOption {
+	return func(e *encoder) {
+		e.emptyCollections = b
+	}
+}
+
+// WithMarshalTimeFormat sets the layout used to format time.Time values, see time.Time.Format.
+// The "format" tag option of a struct field takes precedence.
+// It is the Marshal equivalent of WithTimeFormat.
+//
+// By default, time.Time values are marshaled using their MarshalText method,
+// which uses the RFC 3339 format. An empty layout also means the default is used.
+func WithMarshalTimeFormat(layout string) MarshalOption {
+	return func(e *encoder) {
+		e.timeFormat = layout
+	}
+}
+
+// An Encoder writes SC values to an output stream.
+type Encoder struct {
+	w io.Writer
+	e encoder
+}
+
+// NewEncoder returns a new encoder that writes to w.
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w}
+}
+
+// SortKeys controls whether struct fields are encoded sorted by key.
+//
+// See WithSortKeys for more details.
+func (enc *Encoder) SortKeys(b bool) {
+	if b {
+		enc.e.fieldOrder = FieldOrderKey
+	} else {
+		enc.e.fieldOrder = FieldOrderDeclaration
+	}
+}
+
+// FieldOrder sets the order struct fields are encoded in.
+//
+// See WithFieldOrder for more details.
+func (enc *Encoder) FieldOrder(order FieldOrder) {
+	enc.e.fieldOrder = order
+}
+
+// MapKeyLess sets the function used to sort the keys of maps.
+//
+// See WithMapKeyLess for more details.
+func (enc *Encoder) MapKeyLess(less func(a, b string) bool) {
+	enc.e.mapKeyLess = less
+}
+
+// SecretMode sets how struct fields with the "secret" tag option are encoded.
+//
+// See WithSecretMode for more details.
+func (enc *Encoder) SecretMode(mode SecretMode) {
+	enc.e.secretMode = mode
+}
+
+// TemplateVariables controls whether struct fields with the "asvar=name" tag option
+// are encoded as a reference to the variable name.
+//
+// See WithTemplateVariables for more details.
+func (enc *Encoder) TemplateVariables(b bool) {
+	enc.e.templateVars = b
+}
+
+// FormatOptions sets the options used to format the SC output.
+//
+// See WithFormatOptions for more details.
+func (enc *Encoder) FormatOptions(opts scparse.FormatOptions) {
+	enc.e.formatOpts = opts
+}
+
+// TagName sets the name of the struct tag used to determine the SC key
+// and options of struct fields.
+//
+// See WithMarshalTagName for more details.
+func (enc *Encoder) TagName(name string) {
+	enc.e.tags.name = name
+}
+
+// FallbackToJSONTags controls whether the json struct tag is used for
+// struct fields that do not have an sc tag.
+//
+// See WithMarshalFallbackToJSONTags for more details.
+func (enc *Encoder) FallbackToJSONTags(b bool) {
+	enc.e.tags.fallbackJSON = b
+}
+
+// DurationStrings controls whether time.Duration values are encoded as strings.
+//
+// See WithMarshalDurationStrings for more details.
+func (enc *Encoder) DurationStrings(b bool) {
+	enc.e.durationStrings = b
+}
+
+// EmptyCollections controls whether nil slices and maps are encoded as empty
+// lists and dictionaries instead of null.
+//
+// See WithMarshalEmptyCollections for more details.
+func (enc *Encoder) EmptyCollections(b bool) {
+	enc.e.emptyCollections = b
+}
+
+// TimeFormat sets the layout used to format time.Time values.
+//
+// See WithMarshalTimeFormat for more details.
+func (enc *Encoder) TimeFormat(layout string) {
+	enc.e.timeFormat = layout
+}
+
+// Encode writes the SC encoding of v to the stream.
+//
+// See the documentation for Marshal for details about the encoding process.
+func (enc *Encoder) Encode(v interface{}) error {
+	n, err := enc.e.marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = enc.w.Write(enc.e.format(n))
+	return err
 }
 
 // Marshaler is the interface implemented by types that can marshal
@@ -363,6 +1587,32 @@ type Marshaler interface {
 	MarshalSC() (scparse.ValueNode, error)
 }
 
+// Var is a variable reference. When marshaled it is encoded as a variable
+// with the given name, i.e. `${name}`, instead of a string literal.
+// This allows for generating SC documents that contain unresolved variables.
+// Var can be used anywhere a value is allowed, including as an element of a slice or map.
+//
+// The name must be a valid SC variable name, it may be a path like "server.port".
+type Var string
+
+// MarshalSC implements the Marshaler interface.
+func (v Var) MarshalSC() (scparse.ValueNode, error) {
+	if !isValidVariableName(string(v)) {
+		return nil, &MarshalError{Value: reflect.ValueOf(v), Context: fmt.Sprintf("invalid variable name %q", string(v))}
+	}
+	return &scparse.VariableNode{Identifier: &scparse.IdentifierNode{Name: string(v)}}, nil
+}
+
+// CommentMarshaler is the interface implemented by types that can provide comments
+// to attach to the SC value they are marshaled into. It can be implemented alongside
+// Marshaler, encoding.TextMarshaler, or by any other type.
+//
+// When the value is a member of a dictionary, i.e. a struct field or map value,
+// head comments are placed before the member key and foot comments after the member.
+type CommentMarshaler interface {
+	MarshalSCComments() scparse.CommentGroup
+}
+
 // MarshalError is returned by Marshal and describes an error that occurred
 // during marshaling.
 type MarshalError struct {
@@ -373,3 +1623,101 @@ type MarshalError struct {
 func (e *MarshalError) Error() string {
 	return "sc: " + e.Context
 }
+
+// PathError describes an error accessing a value at a path in an SC document.
+type PathError struct {
+	Path    string // The path that caused the error.
+	Context string // The details of the error.
+}
+
+func (e *PathError) Error() string {
+	return fmt.Sprintf("sc: %s: %s", e.Path, e.Context)
+}
+
+///// Schemas /////
+
+// JSONSchema returns a JSON Schema (draft 2020-12) that describes the SC documents
+// that can be unmarshaled into v. v must be a struct or a map or a pointer to one of
+// these types. Only the type of v is used, so it may be a nil pointer, ex: (*Config)(nil).
+// Since JSON Schema validates the data model shared by SC and JSON, the schema can be
+// used to validate SC documents after converting them to JSON, ex: with the scjson package.
+//
+// The schema follows the rules used by Unmarshal: struct fields use the same keys and
+// options, including the "required", "string", "format", "inline", and "remain" options,
+// see Marshal for details. Fields with the "required" option are listed as required and
+// the members matched by a "remain" field are described by additionalProperties.
+//
+// The "default" struct tag sets the default value of a field in the schema, ex:
+// `sc:"port" default:"8080"`. The value is parsed as an SC value, ex: default:"[1, 2]",
+// except for string fields where it is used as is. The default is only informative,
+// Unmarshal does not use it.
+//
+// Types that implement Unmarshaler or are registered with RegisterType can decode any
+// SC value, so their schema accepts any value. Types that implement encoding.TextUnmarshaler
+// are described as strings. Pointers, slices, maps, and interfaces also accept null.
+// Named struct types are placed in $defs so that recursive types can be described.
+func JSONSchema(v interface{}, opts ...SchemaOption) ([]byte, error) {
+	var g schemaGenerator
+	for _, opt := range opts {
+		opt(&g)
+	}
+	return g.generate(reflect.TypeOf(v))
+}
+
+// SchemaOption is an option that can be provided to JSONSchema to customize the schema.
+//
+// The signature contains an unexported type so that only options defined in this
+// package are valid.
+type SchemaOption func(*schemaGenerator)
+
+// WithSchemaTagName sets the name of the struct tag used to determine the SC key
+// and options of struct fields. It is the JSONSchema equivalent of WithTagName.
+//
+// By default, the sc tag is used. An empty name also means the sc tag is used.
+func WithSchemaTagName(name string) SchemaOption {
+	return func(g *schemaGenerator) {
+		g.tags.name = name
+	}
+}
+
+// WithSchemaFallbackToJSONTags controls whether the json struct tag is used for
+// struct fields that do not have an sc tag, or the tag set by WithSchemaTagName.
+// It is the JSONSchema equivalent of WithFallbackToJSONTags.
+//
+// By default, the json tag is ignored.
+func WithSchemaFallbackToJSONTags(b bool) SchemaOption {
+	return func(g *schemaGenerator) {
+		g.tags.fallbackJSON = b
+	}
+}
+
+// WithSchemaDurationStrings controls whether time.Duration values may be strings in the schema.
+// It should match the WithDurationStrings option used with Unmarshal.
+//
+// By default, time.Duration values must be numbers.
+func WithSchemaDurationStrings(b bool) SchemaOption {
+	return func(g *schemaGenerator) {
+		g.durationStrings = b
+	}
+}
+
+// WithSchemaDisallowUnknownFields controls whether the schema rejects dictionary members
+// that do not match a struct field. It should match the WithDisallowUnknownFields option
+// used with Unmarshal. It has no effect on structs with a "remain" field.
+//
+// By default, unknown members are allowed.
+func WithSchemaDisallowUnknownFields(b bool) SchemaOption {
+	return func(g *schemaGenerator) {
+		g.disallowUnknownFields = b
+	}
+}
+
+// SchemaError is returned by JSONSchema and describes a type that cannot be described by a schema.
+type SchemaError struct {
+	Type    reflect.Type // The type that caused the error.
+	Context string       // The details of the error.
+}
+
+func (e *SchemaError) Error() string {
+	return fmt.Sprintf("sc: cannot generate schema for %s: %s", e.Type, e.Context)
+}
@@ -0,0 +1,191 @@
+// Copyright (c) 2021 the SC authors. All rights reserved. MIT License.
+
+// Package scgen provides the runtime support used by code generated by the
+// scgen command (github.com/sc-lang/go-sc/cmd/scgen).
+//
+// The functions in this package are not intended to be called directly.
+// They implement the common cases of decoding and encoding SC values without
+// reflection. Each decode function reports whether it handled the node. If it
+// did not, generated code falls back to sc.UnmarshalNode, which provides the
+// full decoding behaviour including variable expansion and error reporting.
+package scgen
+
+import (
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/sc-lang/go-sc"
+	"github.com/sc-lang/go-sc/scparse"
+)
+
+// String returns the value of n if it is a raw string or a string without variables.
+func String(n scparse.ValueNode) (string, bool) {
+	switch n := n.(type) {
+	case *scparse.RawStringNode:
+		return n.Value, true
+	case *scparse.MultilineStringNode:
+		return n.Value, true
+	case *scparse.InterpolatedStringNode:
+		switch len(n.Components) {
+		case 0:
+			return "", true
+		case 1:
+			if s, ok := n.Components[0].(*scparse.StringNode); ok {
+				return s.Value, true
+			}
+		}
+	}
+	return "", false
+}
+
+// Bool returns the value of n if it is a bool.
+func Bool(n scparse.ValueNode) (bool, bool) {
+	if n, ok := n.(*scparse.BoolNode); ok {
+		return n.True, true
+	}
+	return false, false
+}
+
+// Int returns the value of n if it is an integer that fits in bitSize bits.
+// A bitSize of 0 means the size of int.
+func Int(n scparse.ValueNode, bitSize int) (int64, bool) {
+	nn, ok := n.(*scparse.NumberNode)
+	if !ok || !nn.IsInt {
+		return 0, false
+	}
+	if bitSize == 0 {
+		bitSize = strconv.IntSize
+	}
+	if bitSize < 64 {
+		max := int64(1)<<(bitSize-1) - 1
+		if nn.Int64 < -max-1 || nn.Int64 > max {
+			return 0, false
+		}
+	}
+	return nn.Int64, true
+}
+
+// Uint returns the value of n if it is an unsigned integer that fits in bitSize bits.
+// A bitSize of 0 means the size of uint.
+func Uint(n scparse.ValueNode, bitSize int) (uint64, bool) {
+	nn, ok := n.(*scparse.NumberNode)
+	if !ok || !nn.IsUint {
+		return 0, false
+	}
+	if bitSize == 0 {
+		bitSize = strconv.IntSize
+	}
+	if bitSize < 64 && nn.Uint64 > uint64(1)<<bitSize-1 {
+		return 0, false
+	}
+	return nn.Uint64, true
+}
+
+// Float returns the value of n if it is a number that fits in a float of bitSize bits.
+func Float(n scparse.ValueNode, bitSize int) (float64, bool) {
+	nn, ok := n.(*scparse.NumberNode)
+	if !ok || !nn.IsFloat {
+		return 0, false
+	}
+	if bitSize == 32 {
+		// Same check as reflect.Value.OverflowFloat
+		f := math.Abs(nn.Float64)
+		if math.MaxFloat32 < f && f <= math.MaxFloat64 {
+			return 0, false
+		}
+	}
+	return nn.Float64, true
+}
+
+// FieldName returns the name in names that key refers to.
+// An exact match is preferred, otherwise the first case-insensitive match is used.
+// If there is no match, an empty string is returned.
+func FieldName(key string, names []string) string {
+	for _, name := range names {
+		if key == name {
+			return name
+		}
+	}
+	for _, name := range names {
+		if strings.EqualFold(key, name) {
+			return name
+		}
+	}
+	return ""
+}
+
+// AppendError appends err to errs if it is not nil.
+// If err is an sc.Errors, each error it contains is appended.
+func AppendError(errs sc.Errors, err error) sc.Errors {
+	if err == nil {
+		return errs
+	}
+	if e, ok := err.(sc.Errors); ok {
+		return append(errs, e...)
+	}
+	return append(errs, err)
+}
+
+// Result returns the error to return from a generated UnmarshalSC method.
+func Result(errs sc.Errors) error {
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// TypeError returns an error for a node that cannot be decoded into v,
+// which must be a pointer to the destination value.
+func TypeError(n scparse.ValueNode, v interface{}) error {
+	return &sc.UnmarshalTypeError{NodeType: n.Type(), Type: reflect.TypeOf(v).Elem(), Pos: n.Position()}
+}
+
+// Key returns the key node for a dictionary member named s.
+func Key(s string) scparse.KeyNode {
+	for i, r := range s {
+		if r != '_' && !unicode.IsLetter(r) && (i == 0 || !unicode.IsDigit(r)) {
+			return &scparse.StringNode{Value: s}
+		}
+	}
+	return &scparse.IdentifierNode{Name: s}
+}
+
+// StringNode returns a string node with the value s.
+func StringNode(s string) *scparse.InterpolatedStringNode {
+	sn := &scparse.StringNode{Value: s}
+	return &scparse.InterpolatedStringNode{Components: []scparse.StringContentNode{sn}}
+}
+
+// Float32Node returns a number node with the value f. The shortest value that
+// round trips as a float32 is used, ex: 0.1 instead of 0.10000000149011612,
+// the same as sc.Marshal.
+func Float32Node(f float32) *scparse.NumberNode {
+	v, _ := strconv.ParseFloat(strconv.FormatFloat(float64(f), 'g', -1, 32), 64)
+	return &scparse.NumberNode{IsFloat: true, Float64: v}
+}
+
+// IsEmpty reports whether v is empty as defined by the omitempty struct tag option.
+// It is used for fields whose kind is not known when the code is generated.
+func IsEmpty(v interface{}) bool {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Invalid:
+		return true
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return rv.Len() == 0
+	case reflect.Bool:
+		return !rv.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return rv.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return rv.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return rv.IsNil()
+	}
+	return false
+}
@@ -0,0 +1,124 @@
+// Copyright (c) 2021 the SC authors. All rights reserved. MIT License.
+
+package scgen_test
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/sc-lang/go-sc"
+	"github.com/sc-lang/go-sc/scgen"
+	"github.com/sc-lang/go-sc/scparse"
+)
+
+func TestNumbers(t *testing.T) {
+	intNode := func(i int64) *scparse.NumberNode {
+		return &scparse.NumberNode{IsInt: true, Int64: i}
+	}
+	uintNode := func(u uint64) *scparse.NumberNode {
+		return &scparse.NumberNode{IsUint: true, Uint64: u}
+	}
+	floatNode := func(f float64) *scparse.NumberNode {
+		return &scparse.NumberNode{IsFloat: true, Float64: f}
+	}
+	tests := []struct {
+		name string
+		ok   bool
+		got  bool
+	}{
+		{"int8 max", true, okOf(scgen.Int(intNode(127), 8))},
+		{"int8 min", true, okOf(scgen.Int(intNode(-128), 8))},
+		{"int8 overflow", false, okOf(scgen.Int(intNode(128), 8))},
+		{"int8 underflow", false, okOf(scgen.Int(intNode(-129), 8))},
+		{"int64", true, okOf(scgen.Int(intNode(math.MinInt64), 64))},
+		{"int from uint", false, okOf(scgen.Int(uintNode(1), 0))},
+		{"uint16 max", true, okOf(scgen.Uint(uintNode(65535), 16))},
+		{"uint16 overflow", false, okOf(scgen.Uint(uintNode(65536), 16))},
+		{"uint64", true, okOf(scgen.Uint(uintNode(math.MaxUint64), 64))},
+		{"float32", true, okOf(scgen.Float(floatNode(1.5), 32))},
+		{"float32 overflow", false, okOf(scgen.Float(floatNode(math.MaxFloat64), 32))},
+		{"float64", true, okOf(scgen.Float(floatNode(math.MaxFloat64), 64))},
+		{"not a number", false, okOf(scgen.Int(&scparse.BoolNode{}, 0))},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.ok {
+			t.Errorf("%s: got ok %t, want %t", tt.name, tt.got, tt.ok)
+		}
+	}
+}
+
+func okOf(_ interface{}, ok bool) bool {
+	return ok
+}
+
+func TestString(t *testing.T) {
+	n, err := scparse.Parse([]byte("{ a: \"foo\", b: `raw`, c: \"\", d: \"x${v}\" }"))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	tests := []struct {
+		want string
+		ok   bool
+	}{
+		{"foo", true},
+		{"raw", true},
+		{"", true},
+		{"", false},
+	}
+	for i, tt := range tests {
+		s, ok := scgen.String(n.Members[i].Value)
+		if s != tt.want || ok != tt.ok {
+			t.Errorf("member %d: got %q, %t, want %q, %t", i, s, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestFieldName(t *testing.T) {
+	names := []string{"name", "Name", "port"}
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{"name", "name"},
+		{"Name", "Name"},
+		{"NAME", "name"},
+		{"Port", "port"},
+		{"host", ""},
+	}
+	for _, tt := range tests {
+		if got := scgen.FieldName(tt.key, names); got != tt.want {
+			t.Errorf("FieldName(%q) = %q, want %q", tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestAppendError(t *testing.T) {
+	err1 := errors.New("one")
+	err2 := errors.New("two")
+	var errs sc.Errors
+	errs = scgen.AppendError(errs, nil)
+	errs = scgen.AppendError(errs, err1)
+	errs = scgen.AppendError(errs, sc.Errors{err2})
+	if len(errs) != 2 || errs[0] != err1 || errs[1] != err2 {
+		t.Errorf("got %v, want [one two]", errs)
+	}
+	if err := scgen.Result(nil); err != nil {
+		t.Errorf("got error %v for no errors, want nil", err)
+	}
+}
+
+func TestKey(t *testing.T) {
+	if _, ok := scgen.Key("name_1").(*scparse.IdentifierNode); !ok {
+		t.Errorf("want identifier for name_1")
+	}
+	if _, ok := scgen.Key("web-app").(*scparse.StringNode); !ok {
+		t.Errorf("want string for web-app")
+	}
+}
+
+func TestFloat32Node(t *testing.T) {
+	if n := scgen.Float32Node(0.1); n.Float64 != 0.1 {
+		t.Errorf("got %v, want 0.1", n.Float64)
+	}
+}
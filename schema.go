@@ -0,0 +1,284 @@
+// Copyright (c) 2021 the SC authors. All rights reserved. MIT License.
+
+package sc
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+
+	"github.com/sc-lang/go-sc/scparse"
+)
+
+// schemaGenerator generates a JSON Schema for a Go type.
+type schemaGenerator struct {
+	tags                  tagConfig
+	durationStrings       bool
+	disallowUnknownFields bool
+
+	defs map[string]interface{}
+	// names contains the $defs names of the struct types that have been seen.
+	names map[reflect.Type]string
+}
+
+type schema = map[string]interface{}
+
+func (g *schemaGenerator) generate(t reflect.Type) ([]byte, error) {
+	if t == nil {
+		return nil, &SchemaError{Type: t, Context: "nil value"}
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct && t.Kind() != reflect.Map {
+		return nil, &SchemaError{Type: t, Context: "value must be a struct or a map"}
+	}
+	g.defs = make(map[string]interface{})
+	g.names = make(map[reflect.Type]string)
+	var s schema
+	var err error
+	if t.Kind() == reflect.Struct && t != dictType {
+		// The root struct is described at the top level instead of in $defs
+		g.names[t] = ""
+		s, err = g.structSchema(t)
+	} else {
+		s, err = g.schema(t)
+	}
+	if err != nil {
+		return nil, err
+	}
+	s["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	if len(g.defs) > 0 {
+		s["$defs"] = g.defs
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(s); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// schema returns the schema of values of type t.
+func (g *schemaGenerator) schema(t reflect.Type) (schema, error) {
+	if t.Kind() == reflect.Ptr {
+		s, err := g.schema(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return nullable(s), nil
+	}
+	// Types that decode themselves can accept anything
+	if registeredUnmarshal(t) != nil || reflect.PtrTo(t).Implements(unmarshalerType) ||
+		t.PkgPath() == nodeType.PkgPath() {
+		return schema{}, nil
+	}
+	switch t {
+	case dictType:
+		return nullable(schema{"type": "object"}), nil
+	case timeType:
+		return schema{"type": "string", "format": "date-time"}, nil
+	case durationType:
+		if g.durationStrings {
+			return schema{"type": []string{"integer", "string"}}, nil
+		}
+		return schema{"type": "integer"}, nil
+	}
+	if reflect.PtrTo(t).Implements(textUnmarshalerType) {
+		return schema{"type": "string"}, nil
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return schema{"type": "boolean"}, nil
+	case reflect.Int, reflect.Int64:
+		return schema{"type": "integer"}, nil
+	case reflect.Int8, reflect.Int16, reflect.Int32:
+		bits := t.Bits()
+		return schema{"type": "integer", "minimum": -1 << (bits - 1), "maximum": 1<<(bits-1) - 1}, nil
+	case reflect.Uint, reflect.Uint64, reflect.Uintptr:
+		return schema{"type": "integer", "minimum": 0}, nil
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return schema{"type": "integer", "minimum": 0, "maximum": uint64(1)<<t.Bits() - 1}, nil
+	case reflect.Float32, reflect.Float64:
+		return schema{"type": "number"}, nil
+	case reflect.String:
+		return schema{"type": "string"}, nil
+	case reflect.Interface:
+		return schema{}, nil
+	case reflect.Slice, reflect.Array:
+		if isByteSlice(t) {
+			return nullable(bytesSchema(bytesBase64)), nil
+		}
+		items, err := g.schema(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		s := schema{"type": "array", "items": items}
+		if t.Kind() == reflect.Array {
+			return s, nil
+		}
+		return nullable(s), nil
+	case reflect.Map:
+		elem, err := g.schema(t.Elem())
+		if err != nil {
+			return nil, err
+		}
+		return nullable(schema{"type": "object", "additionalProperties": elem}), nil
+	case reflect.Struct:
+		return g.structRef(t)
+	}
+	return nil, &SchemaError{Type: t, Context: "unsupported type"}
+}
+
+// structRef returns a reference to the schema of the struct type t.
+// Anonymous structs are described inline.
+func (g *schemaGenerator) structRef(t reflect.Type) (schema, error) {
+	if t.Name() == "" {
+		return g.structSchema(t)
+	}
+	if name, ok := g.names[t]; ok {
+		if name == "" {
+			return schema{"$ref": "#"}, nil
+		}
+		return schema{"$ref": "#/$defs/" + name}, nil
+	}
+	name := t.Name()
+	if _, ok := g.defs[name]; ok {
+		// Different types with the same name in different packages
+		name = strings.ReplaceAll(t.String(), ".", "_")
+	}
+	// Reserve the name before generating the schema in case t is recursive
+	g.names[t] = name
+	g.defs[name] = nil
+	s, err := g.structSchema(t)
+	if err != nil {
+		return nil, err
+	}
+	g.defs[name] = s
+	return schema{"$ref": "#/$defs/" + name}, nil
+}
+
+// structSchema returns the schema of the struct type t.
+func (g *schemaGenerator) structSchema(t reflect.Type) (schema, error) {
+	fields := cachedTypeFields(t, g.tags)
+	props := make(schema, len(fields.list))
+	var required []string
+	for _, f := range fields.list {
+		sf := t.FieldByIndex(f.index)
+		s, err := g.fieldSchema(f, sf.Type)
+		if err != nil {
+			return nil, err
+		}
+		if def, ok := sf.Tag.Lookup("default"); ok {
+			v, err := g.defaultValue(def, f.typ)
+			if err != nil {
+				return nil, &SchemaError{Type: t, Context: fmt.Sprintf("invalid default for field %s: %v", sf.Name, err)}
+			}
+			s["default"] = v
+		}
+		props[f.name] = s
+		if f.required {
+			required = append(required, f.name)
+		}
+	}
+	s := schema{"type": "object", "properties": props}
+	if len(required) > 0 {
+		s["required"] = required
+	}
+	switch {
+	case fields.remain != nil:
+		elem, err := g.schema(fields.remain.typ.Elem())
+		if err != nil {
+			return nil, err
+		}
+		s["additionalProperties"] = elem
+	case g.disallowUnknownFields:
+		s["additionalProperties"] = false
+	}
+	return s, nil
+}
+
+// fieldSchema returns the schema of the struct field f, which has the type ft.
+func (g *schemaGenerator) fieldSchema(f field, ft reflect.Type) (schema, error) {
+	var s schema
+	switch {
+	case f.timeFormat != "":
+		// A custom layout is not a date-time
+		s = schema{"type": "string"}
+	case isByteSlice(f.typ):
+		s = bytesSchema(f.bytes)
+	default:
+		var err error
+		if s, err = g.schema(f.typ); err != nil {
+			return nil, err
+		}
+		if f.quoted {
+			// Quoted fields accept both a string and a value of their type
+			s = addType(s, "string")
+		}
+	}
+	if ft.Kind() == reflect.Ptr {
+		s = nullable(s)
+	}
+	return s, nil
+}
+
+// bytesSchema returns the schema of a []byte value with the encoding enc.
+// A list of numbers is accepted for every encoding.
+func bytesSchema(enc byteEncoding) schema {
+	s := schema{"type": []string{"string", "array"}, "items": schema{"type": "integer", "minimum": 0, "maximum": math.MaxUint8}}
+	if enc == bytesList {
+		s["type"] = "array"
+	}
+	return s
+}
+
+// nullable returns s modified to also accept null.
+func nullable(s schema) schema {
+	return addType(s, "null")
+}
+
+// addType returns s modified to also accept values of the JSON type typ.
+func addType(s schema, typ string) schema {
+	switch t := s["type"].(type) {
+	case nil:
+		if len(s) == 0 {
+			// Already accepts anything
+			return s
+		}
+		return schema{"anyOf": []interface{}{s, schema{"type": typ}}}
+	case string:
+		if t != typ {
+			s["type"] = []string{t, typ}
+		}
+	case []string:
+		for _, x := range t {
+			if x == typ {
+				return s
+			}
+		}
+		s["type"] = append(t, typ)
+	}
+	return s
+}
+
+// defaultValue converts the value of the default struct tag def for a field of type t
+// to a Go value. An error is returned if def cannot be unmarshaled into t.
+func (g *schemaGenerator) defaultValue(def string, t reflect.Type) (interface{}, error) {
+	var n scparse.ValueNode = scparse.NewString(def)
+	if t.Kind() != reflect.String {
+		// Values that are not valid SC, ex: 1h for a time.Duration, are strings
+		if d, err := scparse.Parse([]byte("{v: " + def + "}")); err == nil && len(d.Members) == 1 {
+			n = d.Members[0].Value
+		}
+	}
+	if err := UnmarshalNode(n, reflect.New(t).Interface(), WithDurationStrings(g.durationStrings)); err != nil {
+		return nil, err
+	}
+	return scparse.ToGo(n)
+}
@@ -0,0 +1,121 @@
+// Copyright (c) 2021 the SC authors. All rights reserved. MIT License.
+
+package sc_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/sc-lang/go-sc"
+)
+
+type schemaTree struct {
+	Name     string        `sc:"name,required"`
+	Children []*schemaTree `sc:"children"`
+}
+
+type schemaConfig struct {
+	Host    string            `sc:"host,required" default:"localhost"`
+	Port    int               `sc:"port" default:"8080"`
+	Timeout time.Duration     `sc:"timeout" default:"30s"`
+	Ratio   *float64          `sc:"ratio,string"`
+	Tags    []string          `sc:"tags" default:"[\"a\", \"b\"]"`
+	Level   int8              `sc:"level"`
+	Key     []byte            `sc:"key,hex"`
+	Created time.Time         `sc:"created,format=2006-01-02"`
+	Updated time.Time         `sc:"updated"`
+	Tree    schemaTree        `sc:"tree"`
+	Next    *schemaConfig     `sc:"next"`
+	Any     interface{}       `sc:"any"`
+	Order   sc.Dict           `sc:"order"`
+	Skipped string            `sc:"-"`
+	Extra   map[string]string `sc:",remain"`
+}
+
+func TestJSONSchema(t *testing.T) {
+	got, err := sc.JSONSchema((*schemaConfig)(nil), sc.WithSchemaDurationStrings(true))
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	want := `{"$defs":{"schemaTree":{"properties":{` +
+		`"children":{"items":{"anyOf":[{"$ref":"#/$defs/schemaTree"},{"type":"null"}]},"type":["array","null"]},` +
+		`"name":{"type":"string"}},"required":["name"],"type":"object"}},` +
+		`"$schema":"https://json-schema.org/draft/2020-12/schema",` +
+		`"additionalProperties":{"type":"string"},` +
+		`"properties":{` +
+		`"any":{},` +
+		`"created":{"type":"string"},` +
+		`"host":{"default":"localhost","type":"string"},` +
+		`"key":{"items":{"maximum":255,"minimum":0,"type":"integer"},"type":["string","array"]},` +
+		`"level":{"maximum":127,"minimum":-128,"type":"integer"},` +
+		`"next":{"anyOf":[{"$ref":"#"},{"type":"null"}]},` +
+		`"order":{"type":["object","null"]},` +
+		`"port":{"default":8080,"type":"integer"},` +
+		`"ratio":{"type":["number","string","null"]},` +
+		`"tags":{"default":["a","b"],"items":{"type":"string"},"type":["array","null"]},` +
+		`"timeout":{"default":"30s","type":["integer","string"]},` +
+		`"tree":{"$ref":"#/$defs/schemaTree"},` +
+		`"updated":{"format":"date-time","type":"string"}},` +
+		`"required":["host"],"type":"object"}`
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, got); err != nil {
+		t.Fatalf("invalid JSON: %s", err)
+	}
+	if buf.String() != want {
+		t.Errorf("got schema\n%s\nwant\n%s", buf.String(), want)
+	}
+}
+
+func TestJSONSchemaOptions(t *testing.T) {
+	type S struct {
+		Name  string `json:"name"`
+		Other string `sc:"other"`
+	}
+	got, err := sc.JSONSchema(map[string]S{},
+		sc.WithSchemaFallbackToJSONTags(true), sc.WithSchemaDisallowUnknownFields(true))
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	want := `{"$defs":{"S":{"additionalProperties":false,"properties":{"name":{"type":"string"},"other":{"type":"string"}},"type":"object"}},` +
+		`"$schema":"https://json-schema.org/draft/2020-12/schema","additionalProperties":{"$ref":"#/$defs/S"},"type":["object","null"]}`
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, got); err != nil {
+		t.Fatalf("invalid JSON: %s", err)
+	}
+	if buf.String() != want {
+		t.Errorf("got schema\n%s\nwant\n%s", buf.String(), want)
+	}
+}
+
+func TestJSONSchemaError(t *testing.T) {
+	type badDefault struct {
+		Port int `sc:"port" default:"abc"`
+	}
+	type badType struct {
+		C chan int `sc:"c"`
+	}
+	tests := []struct {
+		name string
+		v    interface{}
+		want string
+	}{
+		{"not a struct", 1, "sc: cannot generate schema for int: value must be a struct or a map"},
+		{"invalid default", badDefault{}, "sc: cannot generate schema for sc_test.badDefault: invalid default for field Port: sc: cannot unmarshal InterpolatedString into Go value of type int"},
+		{"unsupported type", badType{}, "sc: cannot generate schema for chan int: unsupported type"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := sc.JSONSchema(tt.v)
+			var schemaErr *sc.SchemaError
+			if !errors.As(err, &schemaErr) {
+				t.Fatalf("got error %v, want *sc.SchemaError", err)
+			}
+			if err.Error() != tt.want {
+				t.Errorf("got error %q, want %q", err, tt.want)
+			}
+		})
+	}
+}
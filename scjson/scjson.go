@@ -0,0 +1,441 @@
+// Copyright (c) 2021 the SC authors. All rights reserved. MIT License.
+
+// Package scjson converts SC documents to JSON and JSON documents to SC.
+//
+// Numbers are converted using their literal text, so no precision is lost and
+// integers and floats keep their form, ex: 1.0 stays 1.0 instead of becoming 1.
+package scjson
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/sc-lang/go-sc/internal/sccomment"
+	"github.com/sc-lang/go-sc/scparse"
+)
+
+// Option is an option that customizes the conversion performed by ToJSON and FromJSON.
+type Option func(*options)
+
+type options struct {
+	sortKeys bool
+	comments bool
+	indent   string
+}
+
+// WithSortedKeys sets whether the keys of dictionaries and objects are sorted.
+// By default, keys are kept in the order they appear in the input.
+func WithSortedKeys(b bool) Option {
+	return func(o *options) {
+		o.sortKeys = b
+	}
+}
+
+// WithComments sets whether ToJSON keeps the comments of the SC document.
+// If true, the output is JSON with comments (JSONC), which is accepted by many
+// tools but is not valid JSON. Comments are always indented on their own line,
+// so if no indent was set with WithIndent, two spaces are used.
+// By default, comments are dropped.
+func WithComments(b bool) Option {
+	return func(o *options) {
+		o.comments = b
+	}
+}
+
+// WithIndent sets the string used for each level of indentation in the output of ToJSON.
+// By default, the output is compact.
+func WithIndent(indent string) Option {
+	return func(o *options) {
+		o.indent = indent
+	}
+}
+
+func newOptions(opts []Option) options {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// ToJSON converts the SC document data to JSON.
+//
+// Variables and expressions are replaced by their default values the same way as
+// scparse.ToGo, so a variable without a default value causes an
+// *scparse.UnresolvedVariableError to be returned. Anchors and aliases are expanded.
+func ToJSON(data []byte, opts ...Option) ([]byte, error) {
+	n, err := scparse.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	return NodeToJSON(n, opts...)
+}
+
+// NodeToJSON is like ToJSON but converts the SC value n.
+func NodeToJSON(n scparse.ValueNode, opts ...Option) ([]byte, error) {
+	n, err := scparse.ExpandAnchors(n)
+	if err != nil {
+		return nil, err
+	}
+	e := encoder{opts: newOptions(opts)}
+	if e.opts.comments && e.opts.indent == "" {
+		e.opts.indent = "  "
+	}
+	if e.opts.comments {
+		e.writeComments(n.Comments().Head)
+	}
+	if err := e.encode(n); err != nil {
+		return nil, err
+	}
+	if e.opts.comments {
+		e.writeInlineComments(n.Comments().Inline)
+		for _, c := range n.Comments().Foot {
+			e.newline()
+			e.writeComment(c)
+		}
+	}
+	return e.buf.Bytes(), nil
+}
+
+// encoder writes JSON for an SC AST.
+type encoder struct {
+	buf   bytes.Buffer
+	opts  options
+	depth int
+}
+
+func (e *encoder) encode(n scparse.ValueNode) error {
+	switch n := n.(type) {
+	case *scparse.NullNode:
+		e.buf.WriteString("null")
+	case *scparse.BoolNode:
+		e.buf.WriteString(strconv.FormatBool(n.True))
+	case *scparse.NumberNode:
+		e.buf.WriteString(jsonNumber(n))
+	case *scparse.RawStringNode:
+		e.writeString(n.Value)
+	case *scparse.MultilineStringNode:
+		e.writeString(n.Value)
+	case *scparse.InterpolatedStringNode, *scparse.VariableNode, *scparse.ExpressionNode:
+		v, err := scparse.ToGo(n)
+		if err != nil {
+			return err
+		}
+		switch v := v.(type) {
+		case nil:
+			e.buf.WriteString("null")
+		case bool:
+			e.buf.WriteString(strconv.FormatBool(v))
+		case string:
+			e.writeString(v)
+		default:
+			panic(fmt.Errorf("impossible: unexpected value of type %T for %T", v, n))
+		}
+	case *scparse.ListNode:
+		e.buf.WriteByte('[')
+		e.depth++
+		for i, el := range n.Elements {
+			e.writeElemStart(el.Comments().Head)
+			if err := e.encode(el); err != nil {
+				return err
+			}
+			e.writeElemEnd(i == len(n.Elements)-1, *el.Comments())
+		}
+		e.writeEnd(']', len(n.Elements) == 0, n.Comments().Inner)
+	case *scparse.DictionaryNode:
+		members := n.Members
+		if e.opts.sortKeys {
+			members = append([]*scparse.MemberNode(nil), members...)
+			sort.SliceStable(members, func(i, j int) bool {
+				return members[i].Key.KeyString() < members[j].Key.KeyString()
+			})
+		}
+		e.buf.WriteByte('{')
+		e.depth++
+		for i, m := range members {
+			e.writeElemStart(sccomment.Concat(m.Comments().Head, m.Key.Comments().Head))
+			e.writeString(m.Key.KeyString())
+			e.buf.WriteByte(':')
+			if e.opts.indent != "" {
+				e.buf.WriteByte(' ')
+			}
+			if err := e.encode(m.Value); err != nil {
+				return err
+			}
+			e.writeElemEnd(i == len(members)-1, scparse.CommentGroup{
+				Inline: sccomment.Concat(m.Comments().Inline, m.Key.Comments().Inline, m.Value.Comments().Inline),
+				Foot:   m.Comments().Foot,
+			})
+		}
+		e.writeEnd('}', len(members) == 0, n.Comments().Inner)
+	default:
+		panic(fmt.Errorf("impossible: invalid node type used as value: %T", n))
+	}
+	return nil
+}
+
+// writeElemStart starts a list element or object member with the head comments head.
+func (e *encoder) writeElemStart(head []scparse.Comment) {
+	if e.opts.indent == "" {
+		return
+	}
+	e.newline()
+	if e.opts.comments {
+		e.writeComments(head)
+	}
+}
+
+// writeElemEnd ends a list element or object member with the inline and foot comments of cg.
+func (e *encoder) writeElemEnd(last bool, cg scparse.CommentGroup) {
+	if !last {
+		e.buf.WriteByte(',')
+	}
+	if e.opts.comments {
+		e.writeInlineComments(cg.Inline)
+		for _, c := range cg.Foot {
+			e.newline()
+			e.writeComment(c)
+		}
+	}
+}
+
+// writeEnd writes the closing bracket of a list or object.
+// The inner comments of an empty list or object are written before the bracket.
+func (e *encoder) writeEnd(bracket byte, empty bool, inner []scparse.Comment) {
+	if e.opts.comments && empty {
+		for _, c := range inner {
+			e.newline()
+			e.writeComment(c)
+		}
+		if len(inner) > 0 {
+			empty = false
+		}
+	}
+	e.depth--
+	if !empty && e.opts.indent != "" {
+		e.newline()
+	}
+	e.buf.WriteByte(bracket)
+}
+
+func (e *encoder) newline() {
+	e.buf.WriteByte('\n')
+	for i := 0; i < e.depth; i++ {
+		e.buf.WriteString(e.opts.indent)
+	}
+}
+
+// writeComments writes each comment on its own line.
+func (e *encoder) writeComments(comments []scparse.Comment) {
+	for _, c := range comments {
+		e.writeComment(c)
+		e.newline()
+	}
+}
+
+func (e *encoder) writeInlineComments(comments []scparse.Comment) {
+	for _, c := range comments {
+		e.buf.WriteByte(' ')
+		e.writeComment(c)
+	}
+}
+
+func (e *encoder) writeComment(c scparse.Comment) {
+	if c.IsBlock {
+		e.buf.WriteString("/*")
+		e.buf.WriteString(c.Text)
+		e.buf.WriteString("*/")
+		return
+	}
+	e.buf.WriteString("//")
+	e.buf.WriteString(c.Text)
+}
+
+// writeString writes s as a JSON string. Unlike encoding/json, HTML characters are not escaped.
+func (e *encoder) writeString(s string) {
+	const hex = "0123456789abcdef"
+	e.buf.WriteByte('"')
+	start := 0
+	for i := 0; i < len(s); {
+		if b := s[i]; b < utf8.RuneSelf {
+			if b >= ' ' && b != '"' && b != '\\' {
+				i++
+				continue
+			}
+			e.buf.WriteString(s[start:i])
+			switch b {
+			case '"', '\\':
+				e.buf.WriteByte('\\')
+				e.buf.WriteByte(b)
+			case '\n':
+				e.buf.WriteString(`\n`)
+			case '\r':
+				e.buf.WriteString(`\r`)
+			case '\t':
+				e.buf.WriteString(`\t`)
+			default:
+				e.buf.WriteString(`\u00`)
+				e.buf.WriteByte(hex[b>>4])
+				e.buf.WriteByte(hex[b&0xF])
+			}
+			i++
+			start = i
+			continue
+		}
+		c, size := utf8.DecodeRuneInString(s[i:])
+		if c == utf8.RuneError && size == 1 {
+			e.buf.WriteString(s[start:i])
+			e.buf.WriteString(`\ufffd`)
+			i += size
+			start = i
+			continue
+		}
+		i += size
+	}
+	e.buf.WriteString(s[start:])
+	e.buf.WriteByte('"')
+}
+
+// jsonNumber returns the JSON representation of n. The literal is used
+// if it is valid JSON, otherwise it is formatted from the value of n.
+func jsonNumber(n *scparse.NumberNode) string {
+	if n.Raw != "" && json.Valid([]byte(n.Raw)) {
+		return n.Raw
+	}
+	switch {
+	case n.IsInt:
+		return strconv.FormatInt(n.Int64, 10)
+	case n.IsUint:
+		return strconv.FormatUint(n.Uint64, 10)
+	}
+	s := strconv.FormatFloat(n.Float64, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".e") {
+		// Keep it a float
+		s += ".0"
+	}
+	return s
+}
+
+// FromJSON converts the JSON document data to SC. The top level JSON value must be an object.
+// The result is formatted using scparse.Format.
+//
+// Strings are converted literally, ex: "${HOME}" is not converted to a variable.
+// WithComments and WithIndent have no effect.
+func FromJSON(data []byte, opts ...Option) ([]byte, error) {
+	n, err := JSONToNode(data, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return scparse.Format(n), nil
+}
+
+// JSONToNode is like FromJSON but returns the SC AST instead of formatting it.
+func JSONToNode(data []byte, opts ...Option) (*scparse.DictionaryNode, error) {
+	d := decoder{dec: json.NewDecoder(bytes.NewReader(data)), opts: newOptions(opts)}
+	d.dec.UseNumber()
+	tok, err := d.dec.Token()
+	if err != nil {
+		return nil, fmt.Errorf("scjson: invalid JSON: %w", err)
+	}
+	if tok != json.Delim('{') {
+		return nil, errors.New("scjson: top level JSON value must be an object")
+	}
+	n, err := d.decodeObject()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := d.dec.Token(); err != io.EOF {
+		if err == nil {
+			err = errors.New("unexpected data after top level value")
+		}
+		return nil, fmt.Errorf("scjson: invalid JSON: %w", err)
+	}
+	return n, nil
+}
+
+// decoder converts JSON tokens to an SC AST.
+type decoder struct {
+	dec  *json.Decoder
+	opts options
+}
+
+func (d *decoder) decode(tok json.Token) (scparse.ValueNode, error) {
+	switch tok := tok.(type) {
+	case nil:
+		return scparse.NewNull(), nil
+	case bool:
+		return scparse.NewBool(tok), nil
+	case json.Number:
+		n, err := scparse.NewNumber(string(tok))
+		if err != nil {
+			return nil, fmt.Errorf("scjson: cannot convert number %s: %w", tok, err)
+		}
+		return n, nil
+	case string:
+		return scparse.NewString(tok), nil
+	case json.Delim:
+		if tok == '{' {
+			return d.decodeObject()
+		}
+		return d.decodeArray()
+	}
+	panic(fmt.Errorf("impossible: unexpected JSON token %T", tok))
+}
+
+// decodeObject decodes the members of an object. The opening brace has already been consumed.
+func (d *decoder) decodeObject() (*scparse.DictionaryNode, error) {
+	n := scparse.NewDict()
+	for d.dec.More() {
+		tok, err := d.dec.Token()
+		if err != nil {
+			return nil, fmt.Errorf("scjson: invalid JSON: %w", err)
+		}
+		key := tok.(string)
+		if tok, err = d.dec.Token(); err != nil {
+			return nil, fmt.Errorf("scjson: invalid JSON: %w", err)
+		}
+		v, err := d.decode(tok)
+		if err != nil {
+			return nil, err
+		}
+		n.Members = append(n.Members, scparse.NewMember(key, v))
+	}
+	// Closing brace
+	if _, err := d.dec.Token(); err != nil {
+		return nil, fmt.Errorf("scjson: invalid JSON: %w", err)
+	}
+	if d.opts.sortKeys {
+		sort.SliceStable(n.Members, func(i, j int) bool {
+			return n.Members[i].Key.KeyString() < n.Members[j].Key.KeyString()
+		})
+	}
+	return n, nil
+}
+
+// decodeArray decodes the elements of an array. The opening bracket has already been consumed.
+func (d *decoder) decodeArray() (*scparse.ListNode, error) {
+	n := scparse.NewList()
+	for d.dec.More() {
+		tok, err := d.dec.Token()
+		if err != nil {
+			return nil, fmt.Errorf("scjson: invalid JSON: %w", err)
+		}
+		v, err := d.decode(tok)
+		if err != nil {
+			return nil, err
+		}
+		n.Elements = append(n.Elements, v)
+	}
+	// Closing bracket
+	if _, err := d.dec.Token(); err != nil {
+		return nil, fmt.Errorf("scjson: invalid JSON: %w", err)
+	}
+	return n, nil
+}
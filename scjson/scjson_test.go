@@ -0,0 +1,171 @@
+// Copyright (c) 2021 the SC authors. All rights reserved. MIT License.
+
+package scjson_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/sc-lang/go-sc/scjson"
+	"github.com/sc-lang/go-sc/scparse"
+)
+
+func TestToJSON(t *testing.T) {
+	input := `// Service config
+{
+  // The name
+  name: "api<v1>" // inline
+  port: ${port:-8080}
+  ratio: 1.0
+  padded: 007
+  tags: [` + "`a`" + `, "b\n"]
+  empty: {
+    // nothing here
+  }
+  limits: &limits { cpu: 2 }
+  worker: *limits
+  // the end
+}
+// after`
+	tests := []struct {
+		name string
+		opts []scjson.Option
+		want string
+	}{
+		{
+			name: "compact",
+			want: `{"name":"api<v1>","port":"8080","ratio":1.0,"padded":7,"tags":["a","b\n"],"empty":{},"limits":{"cpu":2},"worker":{"cpu":2}}`,
+		},
+		{
+			name: "indent",
+			opts: []scjson.Option{scjson.WithIndent("\t")},
+			want: "{\n\t\"name\": \"api<v1>\",\n\t\"port\": \"8080\",\n\t\"ratio\": 1.0,\n\t\"padded\": 7,\n\t\"tags\": [\n\t\t\"a\",\n\t\t\"b\\n\"\n\t],\n" +
+				"\t\"empty\": {},\n\t\"limits\": {\n\t\t\"cpu\": 2\n\t},\n\t\"worker\": {\n\t\t\"cpu\": 2\n\t}\n}",
+		},
+		{
+			name: "comments and sorted keys",
+			opts: []scjson.Option{scjson.WithComments(true), scjson.WithSortedKeys(true)},
+			want: `// Service config
+{
+  "empty": {
+    // nothing here
+  },
+  "limits": {
+    "cpu": 2
+  },
+  // The name
+  "name": "api<v1>", // inline
+  "padded": 7,
+  "port": "8080",
+  "ratio": 1.0,
+  "tags": [
+    "a",
+    "b\n"
+  ],
+  "worker": {
+    "cpu": 2
+  }
+  // the end
+}
+// after`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := scjson.ToJSON([]byte(input), tt.opts...)
+			if err != nil {
+				t.Fatalf("unexpected error %s", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("got JSON\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+
+	_, err := scjson.ToJSON([]byte(`{ host: ${host} }`))
+	var varErr *scparse.UnresolvedVariableError
+	if !errors.As(err, &varErr) {
+		t.Errorf("got error %v, want *scparse.UnresolvedVariableError", err)
+	}
+}
+
+func TestFromJSON(t *testing.T) {
+	input := `{
+  "name": "${not a variable}",
+  "sizes": [1, 1.0, 2e3, 18446744073709551615],
+  "nested": {"z": null, "a": true},
+  "with space": {}
+}`
+	tests := []struct {
+		name string
+		opts []scjson.Option
+		want string
+	}{
+		{
+			name: "document order",
+			want: `{
+  name: "\${not a variable}"
+  sizes: [1, 1.0, 2e3, 18446744073709551615]
+  nested: { z: null, a: true }
+  "with space": {}
+}
+`,
+		},
+		{
+			name: "sorted keys",
+			opts: []scjson.Option{scjson.WithSortedKeys(true)},
+			want: `{
+  name: "\${not a variable}"
+  nested: { a: true, z: null }
+  sizes: [1, 1.0, 2e3, 18446744073709551615]
+  "with space": {}
+}
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n, err := scjson.JSONToNode([]byte(input), tt.opts...)
+			if err != nil {
+				t.Fatalf("unexpected error %s", err)
+			}
+			got := scparse.FormatWithOptions(n, scparse.FormatOptions{LineWidth: 80})
+			if string(got) != tt.want {
+				t.Errorf("got SC\n%s\nwant\n%s", got, tt.want)
+			}
+			// Converting back should produce the same values
+			b, err := scjson.NodeToJSON(n)
+			if err != nil {
+				t.Fatalf("unexpected error %s", err)
+			}
+			n2, err := scjson.JSONToNode(b, tt.opts...)
+			if err != nil {
+				t.Fatalf("unexpected error %s", err)
+			}
+			if eq, diff := scparse.Equal(n, n2); !eq {
+				t.Errorf("round trip changed the document:\n%s\n%s", b, diff)
+			}
+		})
+	}
+}
+
+func TestFromJSONError(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"not an object", `[1, 2]`, "scjson: top level JSON value must be an object"},
+		{"trailing data", `{"a": 1} {}`, "scjson: invalid JSON: unexpected data after top level value"},
+		{"syntax error", `{"a": }`, "scjson: invalid JSON: missing value after object key"},
+		{"number out of range", `{"a": 1e999}`, `scjson: cannot convert number 1e999: invalid number syntax: "1e999"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := scjson.FromJSON([]byte(tt.input))
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("got error %v, want %s", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,290 @@
+// Copyright (c) 2021 the SC authors. All rights reserved. MIT License.
+
+package sclint
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/sc-lang/go-sc/scparse"
+)
+
+// DefaultMaxDepth is the nesting depth used by MaxDepth in DefaultRules.
+const DefaultMaxDepth = 5
+
+// DuplicateKeys returns a rule that reports keys that occur more than once in the
+// same dictionary. Only the last value of such a key is used when the document is decoded.
+func DuplicateKeys() *Rule {
+	return &Rule{
+		Name:     "duplicate-keys",
+		Doc:      "reports keys that occur more than once in the same dictionary",
+		Severity: SeverityWarning,
+		Run: func(p *Pass) {
+			scparse.Inspect(p.Root, func(n scparse.Node) bool {
+				d, ok := n.(*scparse.DictionaryNode)
+				if !ok {
+					return true
+				}
+				seen := make(map[string]scparse.Pos, len(d.Members))
+				for _, m := range d.Members {
+					key := m.Key.KeyString()
+					pos := m.Key.Position()
+					if prev, ok := seen[key]; ok {
+						p.Report(pos, "duplicate key %q, previously defined at %d:%d", key, prev.Line, prev.Column)
+					}
+					seen[key] = pos
+				}
+				return true
+			})
+		},
+	}
+}
+
+// namingStyle identifies the style of a multi-word key.
+type namingStyle int
+
+const (
+	styleNone           namingStyle = iota // A single word, which is consistent with every style.
+	styleMixed                             // A combination of styles, ex: Foo_bar.
+	styleCamel                             // fooBar
+	stylePascal                            // FooBar
+	styleSnake                             // foo_bar
+	styleScreamingSnake                    // FOO_BAR
+	styleKebab                             // foo-bar
+)
+
+func (s namingStyle) String() string {
+	return [...]string{
+		"",
+		"mixed",
+		"camelCase",
+		"PascalCase",
+		"snake_case",
+		"SCREAMING_SNAKE_CASE",
+		"kebab-case",
+	}[s]
+}
+
+// keyStyle returns the naming style of key. ok is false if key is not a name,
+// ex: a path or a hostname, in which case it is not checked.
+func keyStyle(key string) (style namingStyle, ok bool) {
+	var upper, lower, underscore, hyphen bool
+	for i, r := range key {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+		case r == '_' && i > 0:
+			underscore = true
+		case r == '-' && i > 0:
+			hyphen = true
+		default:
+			return styleNone, false
+		}
+	}
+	switch {
+	case underscore && hyphen:
+		return styleMixed, true
+	case underscore && upper && lower:
+		return styleMixed, true
+	case underscore && upper:
+		return styleScreamingSnake, true
+	case underscore:
+		return styleSnake, true
+	case hyphen && upper:
+		return styleMixed, true
+	case hyphen:
+		return styleKebab, true
+	case upper && lower:
+		if first := []rune(key)[0]; unicode.IsUpper(first) {
+			// A capitalized word, ex: Name, could be any style
+			if strings.IndexFunc(key[1:], unicode.IsUpper) == -1 {
+				return styleNone, true
+			}
+			return stylePascal, true
+		}
+		return styleCamel, true
+	}
+	// A single word, ex: name or NAME
+	return styleNone, true
+}
+
+// InconsistentKeyNaming returns a rule that reports keys that do not follow the naming
+// style used by most keys in the document, ex: a snake_case key in a document that
+// mostly uses camelCase. Keys that consist of a single word are consistent with every
+// style, and keys that contain characters other than letters, digits, underscores,
+// and hyphens, ex: hostnames or paths, are ignored.
+func InconsistentKeyNaming() *Rule {
+	return &Rule{
+		Name:     "inconsistent-key-naming",
+		Doc:      "reports keys that do not follow the naming style used by most keys",
+		Severity: SeverityWarning,
+		Run: func(p *Pass) {
+			type styledKey struct {
+				key   scparse.KeyNode
+				style namingStyle
+			}
+			var keys []styledKey
+			counts := make(map[namingStyle]int)
+			var order []namingStyle // styles in the order they are first used
+			scparse.Inspect(p.Root, func(n scparse.Node) bool {
+				m, ok := n.(*scparse.MemberNode)
+				if !ok {
+					return true
+				}
+				style, ok := keyStyle(m.Key.KeyString())
+				if !ok || style == styleNone {
+					return true
+				}
+				keys = append(keys, styledKey{m.Key, style})
+				if style != styleMixed {
+					if counts[style] == 0 {
+						order = append(order, style)
+					}
+					counts[style]++
+				}
+				return true
+			})
+			// The most common style wins, ties are broken by the style used first
+			dominant := styleNone
+			for _, s := range order {
+				if counts[s] > counts[dominant] {
+					dominant = s
+				}
+			}
+			for _, k := range keys {
+				switch {
+				case k.style == styleMixed:
+					p.Report(k.key.Position(), "key %q mixes naming styles", k.key.KeyString())
+				case k.style != dominant:
+					p.Report(k.key.Position(), "key %q is %s but most keys are %s", k.key.KeyString(), k.style, dominant)
+				}
+			}
+		},
+	}
+}
+
+// UnusedVariables returns a rule that reports anchors that are never referenced by an alias
+// and variables in declared that are never referenced by the document. A declared variable
+// is used if it or one of its fields is referenced, ex: ${server.host} uses server.
+// Unused variables are reported at the position of the document.
+func UnusedVariables(declared []string) *Rule {
+	return &Rule{
+		Name:     "unused-variables",
+		Doc:      "reports anchors and declared variables that are never used",
+		Severity: SeverityWarning,
+		Run: func(p *Pass) {
+			var anchors []*scparse.AnchorNode
+			aliased := make(map[string]bool)
+			scparse.Inspect(p.Root, func(n scparse.Node) bool {
+				switch n := n.(type) {
+				case *scparse.AnchorNode:
+					anchors = append(anchors, n)
+				case *scparse.AliasNode:
+					aliased[n.Name.Name] = true
+				}
+				return true
+			})
+			for _, a := range anchors {
+				if !aliased[a.Name.Name] {
+					p.Report(a.Pos, "anchor %q is never used", a.Name.Name)
+				}
+			}
+
+			refs := scparse.ListVariables(p.Root)
+			for _, name := range declared {
+				used := false
+				for _, r := range refs {
+					if r.Name == name || strings.HasPrefix(r.Name, name+".") {
+						used = true
+						break
+					}
+				}
+				if !used {
+					p.Report(p.Root.Pos, "variable %q is never used", name)
+				}
+			}
+		},
+	}
+}
+
+// MaxDepth returns a rule that reports lists and dictionaries that are nested
+// more than max levels deep. The top level dictionary is not counted, so a
+// dictionary that is the value of a top level member is at depth 1.
+// Only the outermost list or dictionary that is too deep is reported.
+func MaxDepth(max int) *Rule {
+	return &Rule{
+		Name:     "max-depth",
+		Doc:      "reports lists and dictionaries that are nested too deeply",
+		Severity: SeverityInfo,
+		Run: func(p *Pass) {
+			inspectValues(p.Root, func(v scparse.ValueNode, path []scparse.Node) bool {
+				switch v.(type) {
+				case *scparse.ListNode, *scparse.DictionaryNode:
+				default:
+					return true
+				}
+				depth := 0
+				for _, n := range path[1:] {
+					switch n.(type) {
+					case *scparse.ListNode, *scparse.DictionaryNode:
+						depth++
+					}
+				}
+				if depth > max {
+					p.Report(v.Position(), "%s is nested %d levels deep, the maximum is %d", scparse.PathString(path), depth, max)
+					return false
+				}
+				return true
+			})
+		},
+	}
+}
+
+// EmptyValues returns a rule that reports empty strings, lists, and dictionaries, which
+// are often placeholders that were never filled in. Null is not reported since it
+// explicitly indicates that there is no value.
+func EmptyValues() *Rule {
+	return &Rule{
+		Name:     "empty-values",
+		Doc:      "reports empty strings, lists, and dictionaries",
+		Severity: SeverityInfo,
+		Run: func(p *Pass) {
+			inspectValues(p.Root, func(v scparse.ValueNode, path []scparse.Node) bool {
+				if len(path) == 1 {
+					// The top level dictionary
+					return true
+				}
+				var kind string
+				switch v := v.(type) {
+				case *scparse.InterpolatedStringNode:
+					if len(v.Components) == 0 {
+						kind = "string"
+					}
+				case *scparse.RawStringNode:
+					if v.Value == "" {
+						kind = "string"
+					}
+				case *scparse.MultilineStringNode:
+					if v.Value == "" {
+						kind = "string"
+					}
+				case *scparse.ListNode:
+					if len(v.Elements) == 0 {
+						kind = "list"
+					}
+				case *scparse.DictionaryNode:
+					if len(v.Members) == 0 {
+						kind = "dictionary"
+					}
+				}
+				if kind != "" {
+					p.Report(v.Position(), "%s is an empty %s", scparse.PathString(path), kind)
+				}
+				return true
+			})
+		},
+	}
+}
@@ -0,0 +1,146 @@
+// Copyright (c) 2021 the SC authors. All rights reserved. MIT License.
+
+// Package sclint checks SC documents for likely mistakes and style problems.
+//
+// A linter is made up of rules. Each rule inspects the AST of a document and reports
+// diagnostics, which contain the position of the problem, a severity, and a message.
+// This package provides a set of common rules, see DefaultRules, and new rules can
+// be written by creating a Rule with a Run function.
+//
+// Lint is usually used together with scparse.Parse:
+//
+//	n, err := scparse.Parse(input)
+//	if err != nil {
+//		return err
+//	}
+//	for _, d := range sclint.Lint(n) {
+//		fmt.Printf("%s:%s\n", filename, d)
+//	}
+package sclint
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/sc-lang/go-sc/scparse"
+)
+
+// Severity identifies how serious a problem reported by a rule is.
+type Severity int
+
+const (
+	SeverityError   Severity = iota // The document is very likely wrong.
+	SeverityWarning                 // The document is probably wrong or hard to maintain.
+	SeverityInfo                    // The document could be improved.
+)
+
+func (s Severity) String() string {
+	return [...]string{
+		"error",
+		"warning",
+		"info",
+	}[s]
+}
+
+// Diagnostic describes a problem found in a document.
+type Diagnostic struct {
+	Pos      scparse.Pos // Position of the problem in the document.
+	Severity Severity
+	Rule     string // The name of the rule that reported the problem.
+	Message  string
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%d:%d: %s: %s (%s)", d.Pos.Line, d.Pos.Column, d.Severity, d.Message, d.Rule)
+}
+
+// Rule is a check that is performed on a document.
+type Rule struct {
+	// Name identifies the rule, ex: duplicate-keys.
+	// It is included in each diagnostic reported by the rule.
+	Name string
+	// Doc is a short description of what the rule checks.
+	Doc string
+	// Severity is the severity of the diagnostics reported by the rule.
+	Severity Severity
+	// Run performs the check. Problems are reported using Pass.Report.
+	Run func(*Pass)
+}
+
+// Pass provides a Rule with the document being checked and a way to report problems.
+type Pass struct {
+	Rule *Rule                   // The rule being run.
+	Root *scparse.DictionaryNode // The root of the document.
+
+	diagnostics []Diagnostic
+}
+
+// Report reports a problem at pos. The message is formatted using fmt.Sprintf.
+func (p *Pass) Report(pos scparse.Pos, format string, args ...interface{}) {
+	p.diagnostics = append(p.diagnostics, Diagnostic{
+		Pos:      pos,
+		Severity: p.Rule.Severity,
+		Rule:     p.Rule.Name,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// Lint runs rules on the document n and returns the problems found, ordered by position.
+// Problems at the same position are ordered by rule name.
+// If no rules are provided, DefaultRules is used.
+func Lint(n *scparse.DictionaryNode, rules ...*Rule) []Diagnostic {
+	if len(rules) == 0 {
+		rules = DefaultRules()
+	}
+	var diagnostics []Diagnostic
+	for _, r := range rules {
+		p := &Pass{Rule: r, Root: n}
+		r.Run(p)
+		diagnostics = append(diagnostics, p.diagnostics...)
+	}
+	sort.SliceStable(diagnostics, func(i, j int) bool {
+		a, b := diagnostics[i], diagnostics[j]
+		if a.Pos.Byte != b.Pos.Byte {
+			return a.Pos.Byte < b.Pos.Byte
+		}
+		return a.Rule < b.Rule
+	})
+	return diagnostics
+}
+
+// DefaultRules returns the rules that are used by Lint if no rules are provided.
+// It contains every rule in this package with its default settings.
+func DefaultRules() []*Rule {
+	return []*Rule{
+		DuplicateKeys(),
+		InconsistentKeyNaming(),
+		UnusedVariables(nil),
+		MaxDepth(DefaultMaxDepth),
+		EmptyValues(),
+	}
+}
+
+// inspectValues calls f for each value in the AST rooted at root along with the path
+// of nodes from root to the value. If f returns false, the children of the value are skipped.
+// The values of anchors are visited, the anchors themselves are not.
+func inspectValues(root scparse.Node, f func(v scparse.ValueNode, path []scparse.Node) bool) {
+	var stack []scparse.Node
+	scparse.Inspect(root, func(n scparse.Node) bool {
+		if n == nil {
+			stack = stack[:len(stack)-1]
+			return false
+		}
+		stack = append(stack, n)
+		switch n := n.(type) {
+		case *scparse.MemberNode, *scparse.AnchorNode:
+			return true
+		case scparse.ValueNode:
+			if f(n, stack) {
+				return true
+			}
+		}
+		// The children will not be visited so there is no call with nil
+		stack = stack[:len(stack)-1]
+		return false
+	})
+}
@@ -0,0 +1,145 @@
+// Copyright (c) 2021 the SC authors. All rights reserved. MIT License.
+
+package sclint_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/sc-lang/go-sc/sclint"
+	"github.com/sc-lang/go-sc/scparse"
+)
+
+func lint(t *testing.T, input string, rules ...*sclint.Rule) []string {
+	t.Helper()
+	n, err := scparse.Parse([]byte(input))
+	if err != nil {
+		t.Fatalf("failed to parse input: %s", err)
+	}
+	var got []string
+	for _, d := range sclint.Lint(n, rules...) {
+		got = append(got, d.String())
+	}
+	return got
+}
+
+func TestRules(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		rule  *sclint.Rule
+		want  []string
+	}{
+		{
+			"duplicate keys",
+			"{a: 1, b: {c: 1, c: 2}, a: 3}",
+			sclint.DuplicateKeys(),
+			[]string{
+				`1:18: warning: duplicate key "c", previously defined at 1:12 (duplicate-keys)`,
+				`1:25: warning: duplicate key "a", previously defined at 1:2 (duplicate-keys)`,
+			},
+		},
+		{
+			"duplicate keys with different quoting",
+			"{a: 1, \"a\": 2, `b`: 3}",
+			sclint.DuplicateKeys(),
+			[]string{`1:8: warning: duplicate key "a", previously defined at 1:2 (duplicate-keys)`},
+		},
+		{
+			"consistent key naming",
+			`{serverName: 1, port: 2, Name: 3, "example.com": 4, dbConfig: {maxConns: 5}}`,
+			sclint.InconsistentKeyNaming(),
+			nil,
+		},
+		{
+			"inconsistent key naming",
+			`{server_name: 1, db_config: {max_conns: 2, idleTimeout: 3}, "log-level": 4, Bad_Key: 5}`,
+			sclint.InconsistentKeyNaming(),
+			[]string{
+				`1:44: warning: key "idleTimeout" is camelCase but most keys are snake_case (inconsistent-key-naming)`,
+				`1:61: warning: key "log-level" is kebab-case but most keys are snake_case (inconsistent-key-naming)`,
+				`1:77: warning: key "Bad_Key" mixes naming styles (inconsistent-key-naming)`,
+			},
+		},
+		{
+			"unused variables",
+			"{a: &x 1, b: &y 2, c: *y, d: \"${server.port}\", e: ${fn(region)}}",
+			sclint.UnusedVariables([]string{"server", "region", "port", "env"}),
+			[]string{
+				`1:1: warning: variable "port" is never used (unused-variables)`,
+				`1:1: warning: variable "env" is never used (unused-variables)`,
+				`1:5: warning: anchor "x" is never used (unused-variables)`,
+			},
+		},
+		{
+			"max depth",
+			"{a: {b: [{c: 1}], d: [[[1]]]}}",
+			sclint.MaxDepth(2),
+			[]string{
+				`1:10: info: a.b[0] is nested 3 levels deep, the maximum is 2 (max-depth)`,
+				`1:23: info: a.d[0] is nested 3 levels deep, the maximum is 2 (max-depth)`,
+			},
+		},
+		{
+			"empty values",
+			"{a: \"\", b: ``, c: [\"x\", \"\"], d: {}, e: &e [], f: null, g: 0, h: \"\"\"\n\"\"\"}",
+			sclint.EmptyValues(),
+			[]string{
+				`1:5: info: a is an empty string (empty-values)`,
+				`1:12: info: b is an empty string (empty-values)`,
+				`1:25: info: c[1] is an empty string (empty-values)`,
+				`1:33: info: d is an empty dictionary (empty-values)`,
+				`1:43: info: e is an empty list (empty-values)`,
+				`1:65: info: h is an empty string (empty-values)`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := lint(t, tt.input, tt.rule)
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("got diagnostics\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+func TestLintDefaultRules(t *testing.T) {
+	input := `{
+  serverName: "localhost"
+  server_port: 8080
+  serverName: ""
+}`
+	got := lint(t, input)
+	want := []string{
+		`3:3: warning: key "server_port" is snake_case but most keys are camelCase (inconsistent-key-naming)`,
+		`4:3: warning: duplicate key "serverName", previously defined at 2:3 (duplicate-keys)`,
+		`4:15: info: serverName is an empty string (empty-values)`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got diagnostics\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestCustomRule(t *testing.T) {
+	noNulls := &sclint.Rule{
+		Name:     "no-nulls",
+		Severity: sclint.SeverityError,
+		Run: func(p *sclint.Pass) {
+			scparse.Inspect(p.Root, func(n scparse.Node) bool {
+				if n, ok := n.(*scparse.NullNode); ok {
+					p.Report(n.Pos, "null is not allowed")
+				}
+				return true
+			})
+		},
+	}
+	got := lint(t, "{b: {}, a: null}", noNulls, sclint.EmptyValues())
+	want := []string{
+		`1:5: info: b is an empty dictionary (empty-values)`,
+		`1:12: error: null is not allowed (no-nulls)`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got diagnostics\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
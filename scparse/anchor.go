@@ -0,0 +1,260 @@
+// Copyright (c) 2021 the SC authors. All rights reserved. MIT License.
+
+package scparse
+
+import (
+	"fmt"
+	"strings"
+)
+
+// AnchorError describes an anchor or alias that could not be expanded by ExpandAnchors.
+type AnchorError struct {
+	Name string // The name of the anchor.
+	Pos  Pos    // The position of the anchor or alias that caused the error.
+	// PrevPos is the position of the previous definition if the anchor
+	// is defined more than once.
+	PrevPos Pos
+	// Cycle contains the names of the anchors that reference each other
+	// if the anchor references itself, ex: [a b a].
+	Cycle []string
+}
+
+func (e *AnchorError) Error() string {
+	switch {
+	case len(e.Cycle) > 0:
+		return fmt.Sprintf("sc: %d:%d: anchor cycle %s", e.Pos.Line, e.Pos.Column, strings.Join(e.Cycle, " -> "))
+	case e.PrevPos != (Pos{}):
+		return fmt.Sprintf("sc: %d:%d: duplicate anchor %q, previously defined at %d:%d", e.Pos.Line, e.Pos.Column, e.Name, e.PrevPos.Line, e.PrevPos.Column)
+	}
+	return fmt.Sprintf("sc: %d:%d: unknown anchor %q", e.Pos.Line, e.Pos.Column, e.Name)
+}
+
+// ExpandAnchors returns a copy of n where each AnchorNode is replaced by its value
+// and each AliasNode is replaced by the value of the anchor it references.
+// An alias may reference an anchor that is defined later in n.
+//
+// The value of an anchor is shared by all aliases that reference it, so large values
+// are not duplicated. The returned AST shares all nodes that do not contain anchors
+// or aliases with n. If n contains no anchors or aliases, n is returned.
+// The comments of anchors and aliases are not kept.
+//
+// An *AnchorError is returned if an alias references an unknown anchor, an anchor
+// is defined more than once, or anchors reference each other in a cycle, ex: a: &a [*a].
+//
+// Since aliases are shared, the returned AST may be much larger than n when walked in full,
+// ex: when encoding or unmarshaling it. Use WithMaxAliasExpansion when parsing untrusted input.
+func ExpandAnchors(n ValueNode) (ValueNode, error) {
+	x := anchorExpander{anchors: make(map[string]*AnchorNode)}
+	found, err := x.collect(n)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return n, nil
+	}
+	x.expanded = make(map[string]ValueNode, len(x.anchors))
+	return x.expand(n)
+}
+
+// anchorExpander expands the anchors and aliases in an AST.
+type anchorExpander struct {
+	anchors  map[string]*AnchorNode
+	expanded map[string]ValueNode // expanded values of anchors
+	active   []string             // anchors being expanded, used to detect cycles
+}
+
+// collect records the anchors defined in n. It reports whether n contains any anchors or aliases.
+func (x *anchorExpander) collect(n ValueNode) (found bool, err error) {
+	switch n := n.(type) {
+	case *AnchorNode:
+		if prev, ok := x.anchors[n.Name.Name]; ok {
+			return false, &AnchorError{Name: n.Name.Name, Pos: n.Pos, PrevPos: prev.Pos}
+		}
+		x.anchors[n.Name.Name] = n
+		if _, err := x.collect(n.Value); err != nil {
+			return false, err
+		}
+		return true, nil
+	case *AliasNode:
+		return true, nil
+	case *ListNode:
+		for _, e := range n.Elements {
+			f, err := x.collect(e)
+			if err != nil {
+				return false, err
+			}
+			found = found || f
+		}
+	case *DictionaryNode:
+		for _, m := range n.Members {
+			f, err := x.collect(m.Value)
+			if err != nil {
+				return false, err
+			}
+			found = found || f
+		}
+	}
+	return found, nil
+}
+
+// expand returns n with its anchors and aliases expanded. It returns n if nothing needed to change.
+func (x *anchorExpander) expand(n ValueNode) (ValueNode, error) {
+	switch n := n.(type) {
+	case *AnchorNode:
+		return x.expandAnchor(n.Name.Name, n.Pos)
+	case *AliasNode:
+		if _, ok := x.anchors[n.Name.Name]; !ok {
+			return nil, &AnchorError{Name: n.Name.Name, Pos: n.Pos}
+		}
+		return x.expandAnchor(n.Name.Name, n.Pos)
+	case *ListNode:
+		var elems []ValueNode
+		for i, e := range n.Elements {
+			xe, err := x.expand(e)
+			if err != nil {
+				return nil, err
+			}
+			if xe != e && elems == nil {
+				elems = make([]ValueNode, len(n.Elements))
+				copy(elems, n.Elements)
+			}
+			if elems != nil {
+				elems[i] = xe
+			}
+		}
+		if elems == nil {
+			return n, nil
+		}
+		return &ListNode{Pos: n.Pos, CommentGroup: n.CommentGroup, Elements: elems, End: n.End}, nil
+	case *DictionaryNode:
+		var members []*MemberNode
+		for i, m := range n.Members {
+			v, err := x.expand(m.Value)
+			if err != nil {
+				return nil, err
+			}
+			if v != m.Value && members == nil {
+				members = make([]*MemberNode, len(n.Members))
+				copy(members, n.Members)
+			}
+			if members != nil && v != m.Value {
+				members[i] = &MemberNode{Pos: m.Pos, CommentGroup: m.CommentGroup, Key: m.Key, Value: v}
+			}
+		}
+		if members == nil {
+			return n, nil
+		}
+		return &DictionaryNode{Pos: n.Pos, CommentGroup: n.CommentGroup, Members: members, End: n.End}, nil
+	}
+	return n, nil
+}
+
+// expandAnchor returns the expanded value of the anchor name, which is referenced at pos.
+func (x *anchorExpander) expandAnchor(name string, pos Pos) (ValueNode, error) {
+	if v, ok := x.expanded[name]; ok {
+		return v, nil
+	}
+	for i, a := range x.active {
+		if a == name {
+			cycle := append(x.active[i:len(x.active):len(x.active)], name)
+			return nil, &AnchorError{Name: name, Pos: pos, Cycle: cycle}
+		}
+	}
+	x.active = append(x.active, name)
+	v, err := x.expand(x.anchors[name].Value)
+	x.active = x.active[:len(x.active)-1]
+	if err != nil {
+		return nil, err
+	}
+	x.expanded[name] = v
+	return v, nil
+}
+
+// expandedSize returns the number of values in n once its anchors and aliases are expanded.
+// Counting stops once max is exceeded, in which case a value greater than max is returned.
+// Invalid anchors and aliases are counted as a single value, they are reported by ExpandAnchors.
+func expandedSize(n ValueNode, max int) int {
+	c := sizeCounter{anchors: make(map[string]*AnchorNode), sizes: make(map[string]int), max: max}
+	c.collect(n)
+	return c.size(n)
+}
+
+// sizeCounter computes the expanded size of an AST. The size of each anchor
+// is only computed once so the work is proportional to the size of the AST.
+type sizeCounter struct {
+	anchors map[string]*AnchorNode
+	sizes   map[string]int // expanded sizes of anchors, -1 while being computed
+	max     int
+}
+
+// collect records the anchors defined in n. Only the first definition of an anchor is kept.
+func (c *sizeCounter) collect(n ValueNode) {
+	switch n := n.(type) {
+	case *AnchorNode:
+		if _, ok := c.anchors[n.Name.Name]; !ok {
+			c.anchors[n.Name.Name] = n
+		}
+		c.collect(n.Value)
+	case *ListNode:
+		for _, e := range n.Elements {
+			c.collect(e)
+		}
+	case *DictionaryNode:
+		for _, m := range n.Members {
+			c.collect(m.Value)
+		}
+	}
+}
+
+func (c *sizeCounter) size(n ValueNode) int {
+	switch n := n.(type) {
+	case *AnchorNode:
+		return c.anchorSize(n.Name.Name)
+	case *AliasNode:
+		return c.anchorSize(n.Name.Name)
+	case *ListNode:
+		size := 1
+		for _, e := range n.Elements {
+			if size = c.add(size, c.size(e)); size > c.max {
+				break
+			}
+		}
+		return size
+	case *DictionaryNode:
+		size := 1
+		for _, m := range n.Members {
+			if size = c.add(size, c.size(m.Value)); size > c.max {
+				break
+			}
+		}
+		return size
+	}
+	return 1
+}
+
+// anchorSize returns the expanded size of the value of the anchor name.
+func (c *sizeCounter) anchorSize(name string) int {
+	a, ok := c.anchors[name]
+	if !ok {
+		return 1
+	}
+	if size, ok := c.sizes[name]; ok {
+		if size < 0 {
+			// Cycle
+			return 1
+		}
+		return size
+	}
+	c.sizes[name] = -1
+	size := c.size(a.Value)
+	c.sizes[name] = size
+	return size
+}
+
+// add returns a+b, capped at max+1 so that it cannot overflow.
+func (c *sizeCounter) add(a, b int) int {
+	if b > c.max-a {
+		return c.max + 1
+	}
+	return a + b
+}
@@ -0,0 +1,140 @@
+// Copyright (c) 2021 the SC authors. All rights reserved. MIT License.
+
+package scparse
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExpandAnchors(t *testing.T) {
+	input := `{
+  worker: *worker
+  defaults: &res { cpu: 2, memory: 512 }
+  services: [
+    &api { name: "api", resources: *res }
+    *api
+  ]
+  worker: &worker { resources: *res }
+}`
+	n, err := Parse([]byte(input))
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	orig := formatNode(n)
+	got, err := ExpandAnchors(n)
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	want := `{
+  worker: { resources: { cpu: 2, memory: 512 } }
+  defaults: { cpu: 2, memory: 512 }
+  services: [
+    { name: "api", resources: { cpu: 2, memory: 512 } }
+    { name: "api", resources: { cpu: 2, memory: 512 } }
+  ]
+  worker: { resources: { cpu: 2, memory: 512 } }
+}
+`
+	if s := string(FormatWithOptions(got.(*DictionaryNode), FormatOptions{LineWidth: 80})); s != want {
+		t.Errorf("got\n%s\nwant\n%s", s, want)
+	}
+	if formatNode(n) != orig {
+		t.Errorf("input AST was modified")
+	}
+	// Aliases share the anchored value
+	dn := got.(*DictionaryNode)
+	if dn.Get("defaults") != dn.Get("worker").(*DictionaryNode).Get("resources") {
+		t.Errorf("alias does not share the anchored value")
+	}
+
+	// Nothing to expand
+	n, err = Parse([]byte(`{ a: [1, { b: 2 }] }`))
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	if got, err := ExpandAnchors(n); err != nil || got != ValueNode(n) {
+		t.Errorf("got %v, %v, want the input node", got, err)
+	}
+}
+
+func TestExpandAnchorsError(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		want     AnchorError
+		wantText string
+	}{
+		{
+			name:     "unknown anchor",
+			input:    `{ a: *b }`,
+			want:     AnchorError{Name: "b", Pos: Pos{1, 6, 5}},
+			wantText: `sc: 1:6: unknown anchor "b"`,
+		},
+		{
+			name:     "duplicate anchor",
+			input:    `{ a: &x 1, b: &x 2 }`,
+			want:     AnchorError{Name: "x", Pos: Pos{1, 15, 14}, PrevPos: Pos{1, 6, 5}},
+			wantText: `sc: 1:15: duplicate anchor "x", previously defined at 1:6`,
+		},
+		{
+			name:     "self reference",
+			input:    `{ a: &a [*a] }`,
+			want:     AnchorError{Name: "a", Pos: Pos{1, 10, 9}, Cycle: []string{"a", "a"}},
+			wantText: `sc: 1:10: anchor cycle a -> a`,
+		},
+		{
+			name:     "cycle",
+			input:    `{ a: &a { b: *b }, b: &b { a: *a } }`,
+			want:     AnchorError{Name: "a", Pos: Pos{1, 31, 30}, Cycle: []string{"a", "b", "a"}},
+			wantText: `sc: 1:31: anchor cycle a -> b -> a`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n, err := Parse([]byte(tt.input))
+			if err != nil {
+				t.Fatalf("unexpected error %s", err)
+			}
+			_, err = ExpandAnchors(n)
+			var anchorErr *AnchorError
+			if !errors.As(err, &anchorErr) {
+				t.Fatalf("got err %#v, want *AnchorError", err)
+			}
+			if !reflect.DeepEqual(*anchorErr, tt.want) {
+				t.Errorf("got err\n\t%+v\nwant\n\t%+v", *anchorErr, tt.want)
+			}
+			if err.Error() != tt.wantText {
+				t.Errorf("got error string\n\t%s\nwant\n\t%s", err, tt.wantText)
+			}
+		})
+	}
+}
+
+func TestParseMaxAliasExpansion(t *testing.T) {
+	// Each level doubles the size of the previous one, fully expanded this would
+	// contain more values than fit in an int.
+	var b strings.Builder
+	b.WriteString("{\n  l0: &l0 [1, 1]\n")
+	for i := 1; i < 80; i++ {
+		fmt.Fprintf(&b, "  l%d: &l%d [*l%d, *l%d]\n", i, i, i-1, i-1)
+	}
+	b.WriteString("}")
+	_, err := Parse([]byte(b.String()), WithMaxAliasExpansion(1_000_000))
+	var limitErr *LimitError
+	if !errors.As(err, &limitErr) || limitErr.Limit != LimitAliasExpansion {
+		t.Fatalf("got err %v, want alias expansion limit error", err)
+	}
+
+	// Invalid anchors are reported by ExpandAnchors, not the limit
+	n, err := Parse([]byte(`{ a: &a [*a, *b] }`), WithMaxAliasExpansion(10))
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	if _, err := ExpandAnchors(n); err == nil {
+		t.Errorf("want error from ExpandAnchors")
+	}
+}
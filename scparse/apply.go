@@ -0,0 +1,318 @@
+// Copyright (c) 2021 the SC authors. All rights reserved. MIT License.
+
+package scparse
+
+import "fmt"
+
+// An ApplyFunc is invoked by Apply for each node n, even if n is nil,
+// before and/or after the node's children, using a Cursor describing
+// the current node and providing operations on it.
+//
+// The return value of ApplyFunc controls the syntax tree traversal.
+// See Apply for details.
+type ApplyFunc func(*Cursor) bool
+
+// Apply traverses an AST recursively, starting with root, and calling pre
+// and post for each node as described below. Apply returns the AST, possibly
+// modified. It is similar to golang.org/x/tools/go/ast/astutil.Apply.
+//
+// If pre is not nil, it is called for each node before the node's children
+// are traversed (pre-order). If pre returns false, no children are traversed,
+// and post is not called for that node.
+//
+// If post is not nil, and a prior call of pre didn't return false, post is
+// called for each node after its children are traversed (post-order). If post
+// returns false, traversal is terminated and Apply returns immediately.
+//
+// Only fields that refer to AST nodes are considered children; the children
+// of each node are the same as described by Walk. Children are traversed in
+// the order they appear in the source.
+//
+// Modifications made with the Cursor methods are reflected in the traversal:
+// Nodes inserted with InsertBefore or InsertAfter are not traversed. If the current
+// node is replaced in pre, the children of the new node are traversed. The children
+// of a node deleted in pre are still traversed, post is called as usual.
+func Apply(root Node, pre, post ApplyFunc) (result Node) {
+	defer func() {
+		if r := recover(); r != nil && r != abort {
+			panic(r)
+		}
+		result = root
+	}()
+	a := &application{pre: pre, post: post, root: &root}
+	a.apply(nil, "", nil, root)
+	return
+}
+
+var abort = new(int) // singleton, to signal termination of Apply
+
+// A Cursor describes a node encountered during Apply.
+// Information about the node and its parent is available
+// from the Node, Parent, Name, and Index methods.
+//
+// The methods Replace, Delete, InsertBefore, and InsertAfter
+// can be used to change the AST without disrupting Apply.
+type Cursor struct {
+	parent Node
+	name   string
+	iter   *iterator // valid if non-nil
+	node   Node
+	root   *Node // the root node, used when parent is nil
+}
+
+// Node returns the current Node.
+func (c *Cursor) Node() Node { return c.node }
+
+// Parent returns the parent of the current Node.
+// It is nil if the current Node is the root.
+func (c *Cursor) Parent() Node { return c.parent }
+
+// Name returns the name of the parent Node field that contains the current Node.
+// If the parent is a *DictionaryNode and the current Node is a member, Name returns "Members".
+func (c *Cursor) Name() string { return c.name }
+
+// Index reports the index >= 0 of the current Node in the slice of Nodes that
+// contains it, or a value < 0 if the current Node is not part of a slice.
+// The index of the current node changes if InsertBefore is called while
+// processing the current node.
+func (c *Cursor) Index() int {
+	if c.iter != nil {
+		return c.iter.index
+	}
+	return -1
+}
+
+// Replace replaces the current Node with n. The replacement node is not passed
+// to pre, however, its children are traversed if Replace is called from pre.
+// If n has no comments, the comments of the current Node are moved to n
+// so that they are not lost. If both are members, the same applies to the
+// comments of their keys.
+//
+// Replace panics if n is not a valid type for the field that contains the current Node,
+// ex: a ValueNode is required if the current node is a list element.
+func (c *Cursor) Replace(n Node) {
+	if cg := n.Comments(); !hasComments(*cg) && c.node != nil {
+		*cg = *c.node.Comments()
+	}
+	// Comments before a member are attached to the key
+	if m, ok := n.(*MemberNode); ok {
+		if old, ok := c.node.(*MemberNode); ok && !hasComments(*m.Key.Comments()) {
+			*m.Key.Comments() = *old.Key.Comments()
+		}
+	}
+	switch {
+	case c.parent == nil:
+		*c.root = n
+	case c.iter != nil:
+		c.setElem(c.iter.index, n)
+	default:
+		c.setField(n)
+	}
+	c.node = n
+}
+
+// Delete deletes the current Node from its containing slice.
+// If the current Node is the last element of the slice, its foot comments
+// are moved to the new last element, or to the parent if the slice is now empty.
+//
+// Delete panics if the current Node is not part of a slice.
+func (c *Cursor) Delete() {
+	if c.iter == nil {
+		panic("Delete node not contained in slice")
+	}
+	i := c.iter.index
+	foot := c.node.Comments().Foot
+	switch p := c.parent.(type) {
+	case *DictionaryNode:
+		p.Members = append(p.Members[:i], p.Members[i+1:]...)
+		if i == len(p.Members) && len(foot) > 0 {
+			if i > 0 {
+				cg := p.Members[i-1].Comments()
+				cg.Foot = append(cg.Foot, foot...)
+			} else {
+				p.CommentGroup.Inner = append(p.CommentGroup.Inner, foot...)
+			}
+		}
+	case *ListNode:
+		p.Elements = append(p.Elements[:i], p.Elements[i+1:]...)
+		if i == len(p.Elements) && len(foot) > 0 {
+			if i > 0 {
+				cg := p.Elements[i-1].Comments()
+				cg.Foot = append(cg.Foot, foot...)
+			} else {
+				p.CommentGroup.Inner = append(p.CommentGroup.Inner, foot...)
+			}
+		}
+	case *InterpolatedStringNode:
+		p.Components = append(p.Components[:i], p.Components[i+1:]...)
+	case *VariableNode:
+		p.Args = append(p.Args[:i], p.Args[i+1:]...)
+	case *ExpressionNode:
+		p.Operands = append(p.Operands[:i], p.Operands[i+1:]...)
+	}
+	c.iter.step--
+}
+
+// InsertAfter inserts n after the current Node in its containing slice.
+// Apply does not walk n.
+//
+// InsertAfter panics if the current Node is not part of a slice.
+func (c *Cursor) InsertAfter(n Node) {
+	if c.iter == nil {
+		panic("InsertAfter node not contained in slice")
+	}
+	c.insert(c.iter.index+1, n)
+	c.iter.step++
+}
+
+// InsertBefore inserts n before the current Node in its containing slice.
+// Apply does not walk n.
+//
+// InsertBefore panics if the current Node is not part of a slice.
+func (c *Cursor) InsertBefore(n Node) {
+	if c.iter == nil {
+		panic("InsertBefore node not contained in slice")
+	}
+	c.insert(c.iter.index, n)
+	c.iter.index++
+}
+
+// insert inserts n at index i of the slice containing the current Node.
+func (c *Cursor) insert(i int, n Node) {
+	switch p := c.parent.(type) {
+	case *DictionaryNode:
+		p.Members = append(p.Members, nil)
+		copy(p.Members[i+1:], p.Members[i:])
+	case *ListNode:
+		p.Elements = append(p.Elements, nil)
+		copy(p.Elements[i+1:], p.Elements[i:])
+	case *InterpolatedStringNode:
+		p.Components = append(p.Components, nil)
+		copy(p.Components[i+1:], p.Components[i:])
+	case *VariableNode:
+		p.Args = append(p.Args, nil)
+		copy(p.Args[i+1:], p.Args[i:])
+	case *ExpressionNode:
+		p.Operands = append(p.Operands, nil)
+		copy(p.Operands[i+1:], p.Operands[i:])
+	}
+	c.setElem(i, n)
+}
+
+// setElem sets the element at index i of the slice containing the current Node.
+func (c *Cursor) setElem(i int, n Node) {
+	switch p := c.parent.(type) {
+	case *DictionaryNode:
+		p.Members[i] = n.(*MemberNode)
+	case *ListNode:
+		p.Elements[i] = n.(ValueNode)
+	case *InterpolatedStringNode:
+		p.Components[i] = n.(StringContentNode)
+	case *VariableNode:
+		p.Args[i] = n.(StringContentNode)
+	case *ExpressionNode:
+		p.Operands[i] = n.(StringContentNode)
+	default:
+		panic(fmt.Errorf("impossible: unexpected parent type %T", p))
+	}
+}
+
+// setField sets the field of the parent that contains the current Node.
+func (c *Cursor) setField(n Node) {
+	switch p := c.parent.(type) {
+	case *MemberNode:
+		if c.name == "Key" {
+			p.Key = n.(KeyNode)
+		} else {
+			p.Value = n.(ValueNode)
+		}
+	case *VariableNode:
+		if c.name == "Identifier" {
+			p.Identifier = n.(*IdentifierNode)
+		} else {
+			p.Default = n.(*StringNode)
+		}
+	case *AnchorNode:
+		if c.name == "Name" {
+			p.Name = n.(*IdentifierNode)
+		} else {
+			p.Value = n.(ValueNode)
+		}
+	case *AliasNode:
+		p.Name = n.(*IdentifierNode)
+	default:
+		panic(fmt.Errorf("impossible: unexpected parent type %T", p))
+	}
+}
+
+// application carries all the shared data so we can pass it around cheaply.
+type application struct {
+	pre, post ApplyFunc
+	root      *Node
+	cursor    Cursor
+	iter      iterator
+}
+
+// iterator tracks the position of the current node in a slice.
+type iterator struct {
+	index, step int
+}
+
+func (a *application) apply(parent Node, name string, iter *iterator, n Node) {
+	// avoid heap-allocating a new cursor for each apply call; reuse a.cursor instead
+	saved := a.cursor
+	a.cursor = Cursor{parent: parent, name: name, iter: iter, node: n, root: a.root}
+	if a.pre != nil && !a.pre(&a.cursor) {
+		a.cursor = saved
+		return
+	}
+
+	// walk children
+	switch n := a.cursor.node.(type) {
+	case nil, *NullNode, *BoolNode, *NumberNode, *StringNode, *RawStringNode, *MultilineStringNode, *IdentifierNode:
+		// No children
+	case *VariableNode:
+		a.apply(n, "Identifier", nil, n.Identifier)
+		a.applyList(n, "Args", func() int { return len(n.Args) }, func(i int) Node { return n.Args[i] })
+		if n.Default != nil {
+			a.apply(n, "Default", nil, n.Default)
+		}
+	case *AnchorNode:
+		a.apply(n, "Name", nil, n.Name)
+		a.apply(n, "Value", nil, n.Value)
+	case *AliasNode:
+		a.apply(n, "Name", nil, n.Name)
+	case *ExpressionNode:
+		a.applyList(n, "Operands", func() int { return len(n.Operands) }, func(i int) Node { return n.Operands[i] })
+	case *InterpolatedStringNode:
+		a.applyList(n, "Components", func() int { return len(n.Components) }, func(i int) Node { return n.Components[i] })
+	case *ListNode:
+		a.applyList(n, "Elements", func() int { return len(n.Elements) }, func(i int) Node { return n.Elements[i] })
+	case *MemberNode:
+		a.apply(n, "Key", nil, n.Key)
+		a.apply(n, "Value", nil, n.Value)
+	case *DictionaryNode:
+		a.applyList(n, "Members", func() int { return len(n.Members) }, func(i int) Node { return n.Members[i] })
+	default:
+		panic(fmt.Errorf("scparse.Apply: unexpected node type %T", n))
+	}
+
+	if a.post != nil && !a.post(&a.cursor) {
+		panic(abort)
+	}
+
+	a.cursor = saved
+}
+
+func (a *application) applyList(parent Node, name string, length func() int, elem func(int) Node) {
+	// avoid heap-allocating a new iterator for each applyList call; reuse a.iter instead
+	saved := a.iter
+	a.iter.index = 0
+	for a.iter.index < length() {
+		// a.iter.step may be mutated by Cursor methods
+		a.iter.step = 1
+		a.apply(parent, name, &a.iter, elem(a.iter.index))
+		a.iter.index += a.iter.step
+	}
+	a.iter = saved
+}
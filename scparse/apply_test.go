@@ -0,0 +1,93 @@
+// Copyright (c) 2021 the SC authors. All rights reserved. MIT License.
+
+package scparse
+
+import "testing"
+
+func TestApply(t *testing.T) {
+	n, err := Parse([]byte(`{
+  // Name of the service
+  name: "foo"
+  debug: true
+  ports: [80, 8080]
+  // The last member
+  legacy: null
+  // Foot comment
+}`))
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	result := Apply(n, func(c *Cursor) bool {
+		switch node := c.Node().(type) {
+		case *MemberNode:
+			switch node.Key.KeyString() {
+			case "name":
+				// Comments are kept when replacing
+				c.Replace(&MemberNode{
+					Key:   &IdentifierNode{Name: "name"},
+					Value: &InterpolatedStringNode{Components: []StringContentNode{&StringNode{Value: "bar"}}},
+				})
+			case "debug":
+				c.InsertBefore(&MemberNode{Key: &IdentifierNode{Name: "env"}, Value: &RawStringNode{Value: "prod"}})
+				c.Delete()
+			case "legacy":
+				c.Delete()
+			}
+		case *NumberNode:
+			if c.Name() == "Elements" && c.Index() == 0 {
+				c.InsertAfter(&NumberNode{IsInt: true, Int64: 443, IsUint: true, Uint64: 443, IsFloat: true, Float64: 443})
+			}
+		}
+		return true
+	}, nil)
+	want := `{
+  // Name of the service
+  name: "bar"
+  env: ` + "`prod`" + `
+  ports: [
+    80
+    443
+    8080
+  ]
+  // Foot comment
+}
+`
+	if got := string(Format(result.(*DictionaryNode))); got != want {
+		t.Errorf("got formatted SC\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestApplyReplaceRoot(t *testing.T) {
+	n, err := Parse([]byte(`{ a: 1 }`))
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	root := &DictionaryNode{}
+	result := Apply(n, func(c *Cursor) bool {
+		if c.Parent() == nil {
+			c.Replace(root)
+		}
+		return true
+	}, nil)
+	if result != root {
+		t.Errorf("got result %v, want replaced root", result)
+	}
+}
+
+func TestApplyAbort(t *testing.T) {
+	n, err := Parse([]byte(`{ a: 1, b: 2, c: 3 }`))
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	var keys []string
+	Apply(n, nil, func(c *Cursor) bool {
+		if m, ok := c.Node().(*MemberNode); ok {
+			keys = append(keys, m.Key.KeyString())
+			return m.Key.KeyString() != "b"
+		}
+		return true
+	})
+	if ok, diff := deepEqual(keys, []string{"a", "b"}); !ok {
+		t.Errorf("keys not equal:\n%s", diff)
+	}
+}
@@ -0,0 +1,133 @@
+// Copyright (c) 2021 the SC authors. All rights reserved. MIT License.
+
+package scparse
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// The functions in this file create nodes that are equivalent to the nodes
+// created by Parse for the same value. They can be used to build an AST
+// programmatically. The nodes have no position or comments.
+
+// NewNull returns a null node.
+func NewNull() *NullNode {
+	return &NullNode{}
+}
+
+// NewBool returns a node with the boolean value b.
+func NewBool(b bool) *BoolNode {
+	return &BoolNode{True: b}
+}
+
+// NewInt returns a number node with the integer value i.
+func NewInt(i int64) *NumberNode {
+	return mustNumber(strconv.FormatInt(i, 10))
+}
+
+// NewUint returns a number node with the unsigned integer value u.
+func NewUint(u uint64) *NumberNode {
+	return mustNumber(strconv.FormatUint(u, 10))
+}
+
+// NewFloat returns a number node with the floating point value f.
+// If f is an integer, the node will also have an integer value, ex: 2.0 is
+// represented as 2. NewFloat panics if f is NaN or an infinity since they
+// cannot be represented in SC.
+func NewFloat(f float64) *NumberNode {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		panic(fmt.Errorf("scparse.NewFloat: %v cannot be represented in SC", f))
+	}
+	return mustNumber(strconv.FormatFloat(f, 'g', -1, 64))
+}
+
+// NewNumber returns a number node for the number literal raw, ex: 1.50.
+// The literal is kept as is so it is printed the same way. An error is returned
+// if raw is not a valid SC number or is out of range.
+func NewNumber(raw string) (*NumberNode, error) {
+	if !isNumberLiteral(raw) {
+		return nil, fmt.Errorf("scparse.NewNumber: invalid number syntax %q", raw)
+	}
+	return newNumber(Pos{}, raw)
+}
+
+// isNumberLiteral reports whether s matches the syntax of a number accepted by the lexer.
+func isNumberLiteral(s string) bool {
+	i := 0
+	digits := func() bool {
+		start := i
+		for i < len(s) && '0' <= s[i] && s[i] <= '9' {
+			i++
+		}
+		return i > start
+	}
+	if i < len(s) && s[i] == '-' {
+		i++
+	}
+	if !digits() {
+		return false
+	}
+	if i < len(s) && s[i] == '.' {
+		i++
+		digits()
+	}
+	if i < len(s) && (s[i] == 'e' || s[i] == 'E') {
+		i++
+		if i < len(s) && (s[i] == '+' || s[i] == '-') {
+			i++
+		}
+		if !digits() {
+			return false
+		}
+	}
+	return i == len(s)
+}
+
+func mustNumber(raw string) *NumberNode {
+	n, err := newNumber(Pos{}, raw)
+	if err != nil {
+		panic(fmt.Errorf("impossible: invalid number %q: %v", raw, err))
+	}
+	return n
+}
+
+// NewString returns a string node with the value s.
+// Variables are not interpolated, s is used literally.
+func NewString(s string) *InterpolatedStringNode {
+	if s == "" {
+		return &InterpolatedStringNode{}
+	}
+	return &InterpolatedStringNode{Components: []StringContentNode{&StringNode{Value: s}}}
+}
+
+// NewVariable returns a variable node that references the variable name.
+func NewVariable(name string) *VariableNode {
+	return &VariableNode{Identifier: &IdentifierNode{Name: name}}
+}
+
+// NewList returns a list node containing elems.
+func NewList(elems ...ValueNode) *ListNode {
+	return &ListNode{Elements: elems}
+}
+
+// NewDict returns a dictionary node containing members.
+func NewDict(members ...*MemberNode) *DictionaryNode {
+	return &DictionaryNode{Members: members}
+}
+
+// NewMember returns a dictionary member with the given key and value.
+// The key is an identifier if it is a valid identifier, otherwise it is a string.
+func NewMember(key string, value ValueNode) *MemberNode {
+	return &MemberNode{Key: NewKey(key), Value: value}
+}
+
+// NewKey returns a key node for s. It is an identifier if s is a valid
+// identifier, otherwise it is a string.
+func NewKey(s string) KeyNode {
+	if isIdentifier(s) {
+		return &IdentifierNode{Name: s}
+	}
+	return &StringNode{Value: s}
+}
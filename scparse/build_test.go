@@ -0,0 +1,52 @@
+// Copyright (c) 2021 the SC authors. All rights reserved. MIT License.
+
+package scparse
+
+import (
+	"math"
+	"testing"
+)
+
+func TestBuild(t *testing.T) {
+	want, err := Parse([]byte(`{
+  name: "foo"
+  "content-type": "text/plain"
+  empty: ""
+  enabled: true
+  missing: null
+  count: -3
+  big: 18446744073709551615
+  ratio: 0.25
+  whole: 2
+  port: ${port}
+  list: [1, "a"]
+}`))
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	got := NewDict(
+		NewMember("name", NewString("foo")),
+		NewMember("content-type", NewString("text/plain")),
+		NewMember("empty", NewString("")),
+		NewMember("enabled", NewBool(true)),
+		NewMember("missing", NewNull()),
+		NewMember("count", NewInt(-3)),
+		NewMember("big", NewUint(18446744073709551615)),
+		NewMember("ratio", NewFloat(0.25)),
+		NewMember("whole", NewFloat(2.0)),
+		NewMember("port", NewVariable("port")),
+		NewMember("list", NewList(NewInt(1), NewString("a"))),
+	)
+	if ok, diff := deepEqual(got, want, "Pos", "End"); !ok {
+		t.Errorf("ASTs not equal:\n%s", diff)
+	}
+}
+
+func TestNewFloatPanic(t *testing.T) {
+	defer func() {
+		if r := recover(); r == nil {
+			t.Error("want NewFloat to panic")
+		}
+	}()
+	NewFloat(math.Inf(1))
+}
@@ -0,0 +1,177 @@
+// Copyright (c) 2021 the SC authors. All rights reserved. MIT License.
+
+package scparse
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Canonicalize returns a normalized copy of n. Two documents that
+// represent the same data will have identical canonical forms, which makes
+// the canonical form suitable for comparing or hashing documents.
+//
+// The canonical form has the following properties:
+//
+//   - Comments and positions are removed.
+//   - Dictionary members are sorted by key.
+//   - Keys are identifiers unless they must be quoted, in which case they are strings.
+//   - Raw strings are converted to strings. Strings use minimal escaping when formatted.
+//   - Adjacent string components of interpolated strings are combined.
+//   - Numbers are formatted in their shortest form, ex: 1.0 and 1e0 both become 1.
+//   - Anchors and aliases are expanded, unless they are invalid. See ExpandAnchors.
+//
+// n is not modified.
+func Canonicalize(n *DictionaryNode) *DictionaryNode {
+	return canonicalValue(expandAnchorsIfValid(n)).(*DictionaryNode)
+}
+
+// expandAnchorsIfValid returns n with its anchors and aliases expanded.
+// If they cannot be expanded, n is returned as is.
+func expandAnchorsIfValid(n *DictionaryNode) *DictionaryNode {
+	if xn, err := ExpandAnchors(n); err == nil {
+		return xn.(*DictionaryNode)
+	}
+	return n
+}
+
+func canonicalValue(n ValueNode) ValueNode {
+	switch n := n.(type) {
+	case *NullNode:
+		return &NullNode{}
+	case *BoolNode:
+		return &BoolNode{True: n.True}
+	case *NumberNode:
+		return canonicalNumber(n)
+	case *RawStringNode:
+		return &InterpolatedStringNode{Components: []StringContentNode{&StringNode{Value: n.Value}}}
+	case *MultilineStringNode:
+		return &InterpolatedStringNode{Components: []StringContentNode{&StringNode{Value: n.Value}}}
+	case *InterpolatedStringNode:
+		return canonicalString(n)
+	case *VariableNode:
+		return canonicalVariable(n)
+	case *ExpressionNode:
+		return canonicalExpression(n)
+	case *AnchorNode:
+		return &AnchorNode{Name: &IdentifierNode{Name: n.Name.Name}, Value: canonicalValue(n.Value)}
+	case *AliasNode:
+		return &AliasNode{Name: &IdentifierNode{Name: n.Name.Name}}
+	case *ListNode:
+		elements := make([]ValueNode, len(n.Elements))
+		for i, e := range n.Elements {
+			elements[i] = canonicalValue(e)
+		}
+		return &ListNode{Elements: elements}
+	case *DictionaryNode:
+		members := make([]*MemberNode, len(n.Members))
+		for i, m := range n.Members {
+			members[i] = &MemberNode{Key: NewKey(m.Key.KeyString()), Value: canonicalValue(m.Value)}
+		}
+		sort.SliceStable(members, func(i, j int) bool {
+			return members[i].Key.KeyString() < members[j].Key.KeyString()
+		})
+		return &DictionaryNode{Members: members}
+	default:
+		panic(fmt.Errorf("impossible: unexpected node type %T", n))
+	}
+}
+
+// canonicalNumber returns a number node whose raw value is the shortest
+// representation of the number.
+func canonicalNumber(n *NumberNode) *NumberNode {
+	var raw string
+	switch {
+	case n.IsInt:
+		raw = strconv.FormatInt(n.Int64, 10)
+	case n.IsUint:
+		raw = strconv.FormatUint(n.Uint64, 10)
+	case n.IsFloat && !math.IsInf(n.Float64, 0) && !math.IsNaN(n.Float64):
+		raw = strconv.FormatFloat(n.Float64, 'g', -1, 64)
+	default:
+		// Not representable in SC, keep the values as is
+		return &NumberNode{IsUint: n.IsUint, IsInt: n.IsInt, IsFloat: n.IsFloat, Uint64: n.Uint64, Int64: n.Int64, Float64: n.Float64}
+	}
+	cn, err := newNumber(Pos{}, raw)
+	if err != nil {
+		panic(fmt.Errorf("impossible: canonical number %q is invalid: %v", raw, err))
+	}
+	return cn
+}
+
+// canonicalString combines adjacent string components and removes empty ones.
+func canonicalString(n *InterpolatedStringNode) *InterpolatedStringNode {
+	var components []StringContentNode
+	var sb strings.Builder
+	flush := func() {
+		if sb.Len() > 0 {
+			components = append(components, &StringNode{Value: sb.String()})
+			sb.Reset()
+		}
+	}
+	for _, c := range n.Components {
+		switch c := c.(type) {
+		case *StringNode:
+			sb.WriteString(c.Value)
+		case *VariableNode:
+			flush()
+			components = append(components, canonicalVariable(c))
+		case *ExpressionNode:
+			flush()
+			components = append(components, canonicalExpression(c))
+		default:
+			panic(fmt.Errorf("impossible: unexpected node type %T in InterpolatedStringNode", c))
+		}
+	}
+	flush()
+	return &InterpolatedStringNode{Components: components}
+}
+
+func canonicalVariable(n *VariableNode) *VariableNode {
+	cn := &VariableNode{Identifier: &IdentifierNode{Name: n.Identifier.Name}, IsCall: n.IsCall}
+	if n.Default != nil {
+		cn.Default = &StringNode{Value: n.Default.Value}
+	}
+	for _, a := range n.Args {
+		switch a := a.(type) {
+		case *StringNode:
+			cn.Args = append(cn.Args, &StringNode{Value: a.Value})
+		case *VariableNode:
+			cn.Args = append(cn.Args, canonicalVariable(a))
+		}
+	}
+	return cn
+}
+
+func canonicalExpression(n *ExpressionNode) *ExpressionNode {
+	cn := &ExpressionNode{Op: n.Op, Operands: make([]StringContentNode, len(n.Operands))}
+	for i, o := range n.Operands {
+		switch o := o.(type) {
+		case *StringNode:
+			cn.Operands[i] = &StringNode{Value: o.Value}
+		case *VariableNode:
+			cn.Operands[i] = canonicalVariable(o)
+		case *ExpressionNode:
+			cn.Operands[i] = canonicalExpression(o)
+		}
+	}
+	return cn
+}
+
+// isIdentifier reports whether s can be used as an identifier key.
+func isIdentifier(s string) bool {
+	switch s {
+	case "", "null", "true", "false":
+		return false
+	}
+	for i, r := range s {
+		if r != '_' && !unicode.IsLetter(r) && (i == 0 || !unicode.IsDigit(r)) {
+			return false
+		}
+	}
+	return true
+}
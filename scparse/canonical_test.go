@@ -0,0 +1,56 @@
+// Copyright (c) 2021 the SC authors. All rights reserved. MIT License.
+
+package scparse
+
+import "testing"
+
+func TestCanonicalize(t *testing.T) {
+	a, err := Parse([]byte(`{
+  // Comment
+  name: ` + "`foo`" + `
+  "port": 8.08e3
+  ratio: 0.50
+  "true": true
+  "a b": null
+  list: [1.0, "x${y}z", "a" /* block */]
+  path: "${home:-/root}/data"
+}`))
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	b, err := Parse([]byte(`{
+  path: "${home:-/root}/data", list: [1, "x${y}z", ` + "`a`" + `]
+  "a b": null, "true": true, ratio: 5e-1, port: 8080, name: "foo"
+}`))
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+
+	want := `{
+  "a b": null
+  list: [
+    1
+    "x${y}z"
+    "a"
+  ]
+  name: "foo"
+  path: "${home:-/root}/data"
+  port: 8080
+  ratio: 0.5
+  "true": true
+}
+`
+	for _, n := range []*DictionaryNode{a, b} {
+		got := FormatWithOptions(n, FormatOptions{Canonical: true})
+		if string(got) != want {
+			t.Errorf("got formatted SC\n%s\nwant\n%s", got, want)
+		}
+	}
+	if ok, diff := deepEqual(Canonicalize(a), Canonicalize(b)); !ok {
+		t.Errorf("canonical ASTs not equal:\n%s", diff)
+	}
+	// The original AST should not be modified
+	if k := a.Members[0].Key.KeyString(); k != "name" {
+		t.Errorf("got first key %q, want name", k)
+	}
+}
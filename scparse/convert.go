@@ -0,0 +1,132 @@
+// Copyright (c) 2021 the SC authors. All rights reserved. MIT License.
+
+package scparse
+
+import (
+	"fmt"
+	"strings"
+)
+
+// UnresolvedVariableError is returned by ToGo when a variable without
+// a default value is encountered. Variables can only be converted
+// to Go values if they have a default value.
+type UnresolvedVariableError struct {
+	Name string // The name of the variable.
+	Pos  Pos    // The position of the variable.
+}
+
+func (e *UnresolvedVariableError) Error() string {
+	return fmt.Sprintf("sc: %d:%d: cannot convert unresolved variable %q", e.Pos.Line, e.Pos.Column, e.Name)
+}
+
+// ToGo converts n to a plain Go value. The returned values have the same types
+// as the values produced by sc.Unmarshal when decoding into an interface{}:
+//
+//	nil for null
+//	bool for booleans
+//	int for numbers that are integers, float64 for all other numbers
+//	string for strings
+//	[]interface{} for lists
+//	map[string]interface{} for dictionaries
+//
+// Variables are replaced by their default value. If a variable has no default value,
+// an UnresolvedVariableError is returned. Use sc.UnmarshalNode to substitute variable values.
+// Anchors and aliases are expanded, an *AnchorError is returned if this fails.
+func ToGo(n ValueNode) (interface{}, error) {
+	n, err := ExpandAnchors(n)
+	if err != nil {
+		return nil, err
+	}
+	return toGo(n)
+}
+
+func toGo(n ValueNode) (interface{}, error) {
+	switch n := n.(type) {
+	case *NullNode:
+		return nil, nil
+	case *BoolNode:
+		return n.True, nil
+	case *NumberNode:
+		if n.IsInt {
+			return int(n.Int64), nil
+		}
+		return n.Float64, nil
+	case *RawStringNode:
+		return n.Value, nil
+	case *MultilineStringNode:
+		return n.Value, nil
+	case *InterpolatedStringNode:
+		var sb strings.Builder
+		for _, c := range n.Components {
+			switch c := c.(type) {
+			case *StringNode:
+				sb.WriteString(c.Value)
+			case *VariableNode:
+				s, err := variableDefault(c)
+				if err != nil {
+					return nil, err
+				}
+				sb.WriteString(s)
+			case *ExpressionNode:
+				v, err := evalDefaults(c)
+				if err != nil {
+					return nil, err
+				}
+				if v != nil {
+					sb.WriteString(fmt.Sprint(v))
+				}
+			default:
+				panic(fmt.Errorf("impossible: invalid node type in InterpolatedString: %T", c))
+			}
+		}
+		return sb.String(), nil
+	case *VariableNode:
+		return variableDefault(n)
+	case *ExpressionNode:
+		return evalDefaults(n)
+	case *ListNode:
+		l := make([]interface{}, len(n.Elements))
+		for i, e := range n.Elements {
+			v, err := toGo(e)
+			if err != nil {
+				return nil, err
+			}
+			l[i] = v
+		}
+		return l, nil
+	case *DictionaryNode:
+		m := make(map[string]interface{}, len(n.Members))
+		for _, mn := range n.Members {
+			v, err := toGo(mn.Value)
+			if err != nil {
+				return nil, err
+			}
+			m[mn.Key.KeyString()] = v
+		}
+		return m, nil
+	default:
+		panic(fmt.Errorf("impossible: invalid node type used as value: %T", n))
+	}
+}
+
+// evalDefaults evaluates n using only its string literal operands since variables have no value.
+// An error is returned if the result is a variable.
+func evalDefaults(n *ExpressionNode) (interface{}, error) {
+	var unknown *VariableNode
+	v, ok, _ := n.Eval(func(n *VariableNode) (interface{}, bool, error) {
+		unknown = n
+		return nil, false, nil
+	})
+	if !ok {
+		return nil, &UnresolvedVariableError{Name: unknown.Identifier.Name, Pos: unknown.Pos}
+	}
+	return v, nil
+}
+
+// variableDefault returns the default value of n or an error if it has none.
+func variableDefault(n *VariableNode) (string, error) {
+	if n.Default == nil {
+		return "", &UnresolvedVariableError{Name: n.Identifier.Name, Pos: n.Pos}
+	}
+	return n.Default.Value, nil
+}
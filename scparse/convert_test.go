@@ -0,0 +1,63 @@
+// Copyright (c) 2021 the SC authors. All rights reserved. MIT License.
+
+package scparse
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestToGo(t *testing.T) {
+	n, err := Parse([]byte(`{
+  name: "foo"
+  raw: ` + "`bar`" + `
+  count: 3
+  ratio: 0.5
+  enabled: true
+  missing: null
+  region: ${region:-us-east-1}
+  path: "/home/${user:-root}/data"
+  list: [1, "two", [false]]
+  nested: { key: "value" }
+}`))
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	got, err := ToGo(n)
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	want := map[string]interface{}{
+		"name":    "foo",
+		"raw":     "bar",
+		"count":   3,
+		"ratio":   0.5,
+		"enabled": true,
+		"missing": nil,
+		"region":  "us-east-1",
+		"path":    "/home/root/data",
+		"list":    []interface{}{1, "two", []interface{}{false}},
+		"nested":  map[string]interface{}{"key": "value"},
+	}
+	if ok, diff := deepEqual(got, want); !ok {
+		t.Errorf("values not equal:\n%s", diff)
+	}
+}
+
+func TestToGoUnresolvedVariable(t *testing.T) {
+	n, err := Parse([]byte(`{
+  list: ["/home/${user}"]
+}`))
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	_, err = ToGo(n)
+	var verr *UnresolvedVariableError
+	if !errors.As(err, &verr) {
+		t.Fatalf("got error %v, want %T", err, verr)
+	}
+	want := &UnresolvedVariableError{Name: "user", Pos: Pos{2, 17, 18}}
+	if ok, diff := deepEqual(verr, want); !ok {
+		t.Errorf("errors not equal:\n%s", diff)
+	}
+}
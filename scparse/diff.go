@@ -0,0 +1,144 @@
+// Copyright (c) 2021 the SC authors. All rights reserved. MIT License.
+
+package scparse
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// ChangeType identifies the type of a Change.
+type ChangeType int
+
+const (
+	ChangeAdded    ChangeType = iota // A value was added.
+	ChangeRemoved                    // A value was removed.
+	ChangeModified                   // A value was changed.
+)
+
+func (t ChangeType) String() string {
+	return [...]string{
+		"added",
+		"removed",
+		"modified",
+	}[t]
+}
+
+// Change describes a difference between two SC documents.
+type Change struct {
+	Type ChangeType
+	// Path is the location of the changed value, ex: server.ports[1].
+	// Keys that are not identifiers are quoted, ex: headers."content-type".
+	Path string
+	Old  ValueNode // The old value, nil if the value was added.
+	New  ValueNode // The new value, nil if the value was removed.
+}
+
+func (c Change) String() string {
+	switch c.Type {
+	case ChangeAdded:
+		return fmt.Sprintf("%s: added %s", c.Path, c.New)
+	case ChangeRemoved:
+		return fmt.Sprintf("%s: removed %s", c.Path, c.Old)
+	default:
+		return fmt.Sprintf("%s: %s -> %s", c.Path, c.Old, c.New)
+	}
+}
+
+// Diff returns the semantic differences between the documents a and b.
+// The differences are described as changes needed to turn a into b.
+//
+// Values are compared semantically, differences in formatting, comments,
+// member order, or number and string representation are ignored,
+// ex: 1.0 and 1 are equal, as are "a" and `a`. Dictionaries are compared key by key;
+// if a key occurs more than once, the last occurrence is used. Lists are compared
+// element by element; elements that only exist in one list are added or removed.
+//
+// Anchors and aliases are expanded before comparing, so an alias is equal to the
+// value it references.
+//
+// The changes are ordered by the position of the values in a, followed by
+// the values that were added in b.
+func Diff(a, b *DictionaryNode) []Change {
+	a, b = expandAnchorsIfValid(a), expandAnchorsIfValid(b)
+	var d differ
+	d.diff("", a, b)
+	return d.changes
+}
+
+type differ struct {
+	changes []Change
+}
+
+func (d *differ) add(t ChangeType, path string, old, new ValueNode) {
+	d.changes = append(d.changes, Change{Type: t, Path: path, Old: old, New: new})
+}
+
+func (d *differ) diff(path string, a, b ValueNode) {
+	switch a := a.(type) {
+	case *DictionaryNode:
+		if b, ok := b.(*DictionaryNode); ok {
+			d.diffDictionary(path, a, b)
+			return
+		}
+	case *ListNode:
+		if b, ok := b.(*ListNode); ok {
+			d.diffList(path, a, b)
+			return
+		}
+	}
+	if eq, _ := Equal(canonicalValue(a), canonicalValue(b)); !eq {
+		d.add(ChangeModified, path, a, b)
+	}
+}
+
+func (d *differ) diffDictionary(path string, a, b *DictionaryNode) {
+	seen := make(map[string]bool)
+	for _, m := range a.Members {
+		k := m.Key.KeyString()
+		if seen[k] {
+			continue
+		}
+		seen[k] = true
+		av := a.Get(k)
+		kpath := joinKey(path, k)
+		if bv := b.Get(k); bv != nil {
+			d.diff(kpath, av, bv)
+		} else {
+			d.add(ChangeRemoved, kpath, av, nil)
+		}
+	}
+	for _, m := range b.Members {
+		k := m.Key.KeyString()
+		if seen[k] {
+			continue
+		}
+		seen[k] = true
+		d.add(ChangeAdded, joinKey(path, k), nil, b.Get(k))
+	}
+}
+
+func (d *differ) diffList(path string, a, b *ListNode) {
+	for i := 0; i < len(a.Elements) || i < len(b.Elements); i++ {
+		ipath := path + "[" + strconv.Itoa(i) + "]"
+		switch {
+		case i >= len(a.Elements):
+			d.add(ChangeAdded, ipath, nil, b.Elements[i])
+		case i >= len(b.Elements):
+			d.add(ChangeRemoved, ipath, a.Elements[i], nil)
+		default:
+			d.diff(ipath, a.Elements[i], b.Elements[i])
+		}
+	}
+}
+
+// joinKey appends the key k to path.
+func joinKey(path, k string) string {
+	if !isIdentifier(k) {
+		k = strconv.Quote(k)
+	}
+	if path == "" {
+		return k
+	}
+	return path + "." + k
+}
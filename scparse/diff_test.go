@@ -0,0 +1,56 @@
+// Copyright (c) 2021 the SC authors. All rights reserved. MIT License.
+
+package scparse
+
+import "testing"
+
+func TestDiff(t *testing.T) {
+	a, err := Parse([]byte(`{
+  name: "foo"
+  version: 1.0
+  removed: true
+  server: {
+    host: "localhost"
+    ports: [80, 443, 8080]
+  }
+  "content-type": ` + "`text/plain`" + `
+  env: "${env:-dev}"
+}`))
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	b, err := Parse([]byte(`{
+  // Comments and order don't matter
+  env: "${env:-dev}"
+  "content-type": "text/html"
+  server: { host: "localhost", ports: [80, 8443] }
+  version: 1
+  name: "foo"
+  added: null
+}`))
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	var got []string
+	for _, c := range Diff(a, b) {
+		got = append(got, c.String())
+	}
+	want := []string{
+		"removed: removed true",
+		"server.ports[1]: 443 -> 8443",
+		"server.ports[2]: removed 8080",
+		`"content-type": ` + "`text/plain`" + ` -> "text/html"`,
+		"added: added null",
+	}
+	if ok, diff := deepEqual(got, want); !ok {
+		t.Errorf("changes not equal:\n%s", diff)
+	}
+
+	changes := Diff(a, b)
+	if c := changes[1]; c.Type != ChangeModified || c.Old.Position() != (Pos{7, 17, 97}) {
+		t.Errorf("got change %+v with position %v", c, c.Old.Position())
+	}
+	if changes := Diff(a, a); len(changes) != 0 {
+		t.Errorf("got changes %v, want none", changes)
+	}
+}
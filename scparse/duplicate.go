@@ -0,0 +1,141 @@
+// Copyright (c) 2021 the SC authors. All rights reserved. MIT License.
+
+package scparse
+
+// DuplicateKeyPolicy controls how a dictionary that contains the same key
+// more than once is handled.
+type DuplicateKeyPolicy int
+
+const (
+	// DuplicateKeysLastWins uses the last value for a key. This is the default.
+	// Parse keeps all members in the AST, since consumers of the AST,
+	// ex: sc.Unmarshal, use the last value.
+	DuplicateKeysLastWins DuplicateKeyPolicy = iota
+	// DuplicateKeysFirstWins uses the first value for a key.
+	// Later members with the same key are ignored.
+	DuplicateKeysFirstWins
+	// DuplicateKeysError causes a duplicate key to be an error.
+	DuplicateKeysError
+	// DuplicateKeysDeepMerge recursively merges the values for a key if they
+	// are all dictionaries. Members of later dictionaries take precedence.
+	// Otherwise, the last value for a key is used.
+	DuplicateKeysDeepMerge
+)
+
+// ResolveDuplicateKeys returns n with the duplicate keys in all dictionaries
+// resolved according to policy, so that each key occurs at most once.
+// A member that replaces an earlier member with the same key takes its place.
+//
+// n is not modified, only the dictionaries that need to change are copied.
+// The returned AST shares all other nodes with n.
+// If policy is DuplicateKeysError, a *DuplicateKeyError is returned for
+// the first duplicate key found.
+func ResolveDuplicateKeys(n *DictionaryNode, policy DuplicateKeyPolicy) (*DictionaryNode, error) {
+	v, err := resolveDuplicates(n, policy)
+	if err != nil {
+		return nil, err
+	}
+	return v.(*DictionaryNode), nil
+}
+
+// resolveDuplicates resolves the duplicate keys in n and its children.
+// It returns n if nothing needed to change.
+func resolveDuplicates(n ValueNode, policy DuplicateKeyPolicy) (ValueNode, error) {
+	switch n := n.(type) {
+	case *AnchorNode:
+		v, err := resolveDuplicates(n.Value, policy)
+		if err != nil || v == n.Value {
+			return n, err
+		}
+		return &AnchorNode{Pos: n.Pos, CommentGroup: n.CommentGroup, Name: n.Name, Value: v}, nil
+	case *ListNode:
+		var elems []ValueNode
+		for i, e := range n.Elements {
+			re, err := resolveDuplicates(e, policy)
+			if err != nil {
+				return nil, err
+			}
+			if re != e && elems == nil {
+				elems = make([]ValueNode, len(n.Elements))
+				copy(elems, n.Elements)
+			}
+			if elems != nil {
+				elems[i] = re
+			}
+		}
+		if elems == nil {
+			return n, nil
+		}
+		return &ListNode{Pos: n.Pos, CommentGroup: n.CommentGroup, Elements: elems}, nil
+	case *DictionaryNode:
+		changed := false
+		members := make([]*MemberNode, 0, len(n.Members))
+		keys := make(map[string]int, len(n.Members))
+		for _, m := range n.Members {
+			v, err := resolveDuplicates(m.Value, policy)
+			if err != nil {
+				return nil, err
+			}
+			if v != m.Value {
+				m = &MemberNode{Pos: m.Pos, CommentGroup: m.CommentGroup, Key: m.Key, Value: v}
+				changed = true
+			}
+			l := len(members)
+			if members, err = addMember(members, keys, m, policy); err != nil {
+				return nil, err
+			}
+			if len(members) == l {
+				changed = true
+			}
+		}
+		if !changed {
+			return n, nil
+		}
+		return &DictionaryNode{Pos: n.Pos, CommentGroup: n.CommentGroup, Members: members}, nil
+	}
+	return n, nil
+}
+
+// addMember adds m to members, resolving a duplicate key according to policy.
+// keys maps each key in members to its index. members must not contain duplicate keys.
+// The members are not modified, if values are merged a new member is created.
+func addMember(members []*MemberNode, keys map[string]int, m *MemberNode, policy DuplicateKeyPolicy) ([]*MemberNode, error) {
+	k := m.Key.KeyString()
+	i, ok := keys[k]
+	if !ok {
+		keys[k] = len(members)
+		return append(members, m), nil
+	}
+	prev := members[i]
+	switch policy {
+	case DuplicateKeysFirstWins:
+		return members, nil
+	case DuplicateKeysError:
+		return nil, &DuplicateKeyError{Key: k, Pos: m.Key.Position(), PrevPos: prev.Key.Position()}
+	case DuplicateKeysDeepMerge:
+		prevDict, ok1 := prev.Value.(*DictionaryNode)
+		dict, ok2 := m.Value.(*DictionaryNode)
+		if ok1 && ok2 {
+			members[i] = &MemberNode{Pos: prev.Pos, CommentGroup: prev.CommentGroup, Key: prev.Key, Value: mergeDictionaries(prevDict, dict)}
+			return members, nil
+		}
+	}
+	members[i] = m
+	return members, nil
+}
+
+// mergeDictionaries returns a new dictionary containing the members of a and b
+// where the members of b take precedence. Neither a or b may contain duplicate keys.
+func mergeDictionaries(a, b *DictionaryNode) *DictionaryNode {
+	members := make([]*MemberNode, len(a.Members), len(a.Members)+len(b.Members))
+	copy(members, a.Members)
+	keys := make(map[string]int, len(members))
+	for i, m := range members {
+		keys[m.Key.KeyString()] = i
+	}
+	for _, m := range b.Members {
+		// The error can be ignored since it is only returned by DuplicateKeysError
+		members, _ = addMember(members, keys, m, DuplicateKeysDeepMerge)
+	}
+	return &DictionaryNode{Pos: a.Pos, CommentGroup: a.CommentGroup, Members: members}
+}
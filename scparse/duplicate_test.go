@@ -0,0 +1,146 @@
+// Copyright (c) 2021 the SC authors. All rights reserved. MIT License.
+
+package scparse
+
+import (
+	"errors"
+	"testing"
+)
+
+const duplicateKeysInput = `{
+  name: "a"
+  db: { host: "localhost", port: 5432 }
+  list: [{ x: 1, x: 2 }]
+  name: "b"
+  db: { port: 6543, opts: { ssl: true } }
+  db: { opts: { timeout: 10 } }
+}`
+
+func TestResolveDuplicateKeys(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy DuplicateKeyPolicy
+		want   string
+	}{
+		{
+			name:   "last wins",
+			policy: DuplicateKeysLastWins,
+			want: `{
+  name: "b"
+  db: { opts: { timeout: 10 } }
+  list: [{ x: 2 }]
+}
+`,
+		},
+		{
+			name:   "first wins",
+			policy: DuplicateKeysFirstWins,
+			want: `{
+  name: "a"
+  db: { host: "localhost", port: 5432 }
+  list: [{ x: 1 }]
+}
+`,
+		},
+		{
+			name:   "deep merge",
+			policy: DuplicateKeysDeepMerge,
+			want: `{
+  name: "b"
+  db: { host: "localhost", port: 6543, opts: { ssl: true, timeout: 10 } }
+  list: [{ x: 2 }]
+}
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n, err := Parse([]byte(duplicateKeysInput))
+			if err != nil {
+				t.Fatalf("unexpected error %s", err)
+			}
+			orig := formatNode(n)
+			got, err := ResolveDuplicateKeys(n, tt.policy)
+			if err != nil {
+				t.Fatalf("unexpected error %s", err)
+			}
+			if s := string(FormatWithOptions(got, FormatOptions{LineWidth: 80})); s != tt.want {
+				t.Errorf("got\n%s\nwant\n%s", s, tt.want)
+			}
+			if formatNode(n) != orig {
+				t.Errorf("input AST was modified")
+			}
+
+			// Parsing with the policy should give the same result
+			pn, err := Parse([]byte(duplicateKeysInput), WithDuplicateKeyPolicy(tt.policy))
+			if err != nil {
+				t.Fatalf("unexpected error %s", err)
+			}
+			if tt.policy == DuplicateKeysLastWins {
+				// All members are kept
+				if len(pn.Members) != 6 {
+					t.Errorf("got %d members, want 6", len(pn.Members))
+				}
+				return
+			}
+			if s := string(FormatWithOptions(pn, FormatOptions{LineWidth: 80})); s != tt.want {
+				t.Errorf("got parsed\n%s\nwant\n%s", s, tt.want)
+			}
+		})
+	}
+}
+
+func TestResolveDuplicateKeysError(t *testing.T) {
+	n, err := Parse([]byte(duplicateKeysInput))
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	_, err = ResolveDuplicateKeys(n, DuplicateKeysError)
+	var dupErr *DuplicateKeyError
+	if !errors.As(err, &dupErr) {
+		t.Fatalf("got err %#v, want *DuplicateKeyError", err)
+	}
+	want := DuplicateKeyError{Key: "x", Pos: Pos{4, 18, 71}, PrevPos: Pos{4, 12, 65}}
+	if *dupErr != want {
+		t.Errorf("got err\n\t%+v\nwant\n\t%+v", *dupErr, want)
+	}
+}
+
+func TestResolveDuplicateKeysUnchanged(t *testing.T) {
+	n, err := Parse([]byte(`{ a: 1, b: { c: [{ d: 2 }] } }`))
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	got, err := ResolveDuplicateKeys(n, DuplicateKeysDeepMerge)
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	if got != n {
+		t.Errorf("want same AST to be returned when there are no duplicate keys")
+	}
+}
+
+func TestParseDuplicateKeyPolicyPreserveSource(t *testing.T) {
+	n, err := Parse([]byte(duplicateKeysInput), WithDuplicateKeyPolicy(DuplicateKeysDeepMerge), WithPreserveSource(true))
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	n.Set("name", NewString("c"))
+	// Unmodified members are printed as written
+	want := `{
+  name: "c"
+  db: {
+    host: "localhost"
+    port: 6543
+    opts: {
+      ssl: true
+      timeout: 10
+    }
+  }
+  list: [{ x: 1, x: 2 }]
+}
+`
+	if got := string(Format(n)); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,254 @@
+// Copyright (c) 2021 the SC authors. All rights reserved. MIT License.
+
+package scparse
+
+import (
+	"fmt"
+	"strings"
+)
+
+// CompareOption is an option that can be provided to Equal to customize
+// how nodes are compared.
+//
+// The signature contains an unexported type so that only options defined in this
+// package are valid.
+type CompareOption func(*nodeComparer)
+
+// IgnorePositions causes Equal to ignore the positions of nodes and comments.
+// This is useful for comparing an AST that was parsed with one that was built
+// programmatically or modified.
+func IgnorePositions() CompareOption {
+	return func(c *nodeComparer) {
+		c.ignorePositions = true
+	}
+}
+
+// IgnoreComments causes Equal to ignore all comments as well as blank lines between nodes.
+func IgnoreComments() CompareOption {
+	return func(c *nodeComparer) {
+		c.ignoreComments = true
+	}
+}
+
+// Equal reports whether the ASTs a and b are structurally equal, that is,
+// they have the same node types with the same field values. If they are not equal,
+// diff describes each difference, one per line, using the path to the field that
+// differs, ex: "Members[1].Value.Int64: 1 != 2".
+//
+// By default, all fields are compared including positions and comments.
+// Use IgnorePositions and IgnoreComments to customize the comparison.
+func Equal(a, b Node, opts ...CompareOption) (equal bool, diff string) {
+	c := &nodeComparer{}
+	for _, opt := range opts {
+		opt(c)
+	}
+	c.node(a, b)
+	return c.buf.Len() == 0, c.buf.String()
+}
+
+// nodeComparer holds the state for comparing two nodes.
+type nodeComparer struct {
+	ignorePositions bool
+	ignoreComments  bool
+
+	path []string // path to the current field
+	buf  strings.Builder
+}
+
+func (c *nodeComparer) push(format string, args ...interface{}) {
+	c.path = append(c.path, fmt.Sprintf(format, args...))
+}
+
+func (c *nodeComparer) pop() {
+	c.path = c.path[:len(c.path)-1]
+}
+
+// diff records a difference at the current path.
+func (c *nodeComparer) diff(a, b interface{}) {
+	path := strings.Join(c.path, ".")
+	path = strings.ReplaceAll(path, ".[", "[")
+	if path == "" {
+		path = "<root>"
+	}
+	fmt.Fprintf(&c.buf, "%s: %v != %v\n", path, a, b)
+}
+
+// field compares the values of a field.
+func (c *nodeComparer) field(name string, a, b interface{}) {
+	if a != b {
+		c.push("%s", name)
+		c.diff(a, b)
+		c.pop()
+	}
+}
+
+func (c *nodeComparer) pos(a, b Pos) {
+	if !c.ignorePositions {
+		c.field("Pos", a, b)
+	}
+}
+
+func (c *nodeComparer) endPos(a, b Pos) {
+	if !c.ignorePositions {
+		c.field("End", a, b)
+	}
+}
+
+func (c *nodeComparer) commentGroup(a, b *CommentGroup) {
+	if c.ignoreComments {
+		return
+	}
+	c.push("CommentGroup")
+	c.comments("Head", a.Head, b.Head)
+	c.comments("Inline", a.Inline, b.Inline)
+	c.comments("Foot", a.Foot, b.Foot)
+	c.comments("Inner", a.Inner, b.Inner)
+	c.field("BlankLinesBefore", a.BlankLinesBefore, b.BlankLinesBefore)
+	c.pop()
+}
+
+func (c *nodeComparer) comments(name string, a, b []Comment) {
+	c.push("%s", name)
+	defer c.pop()
+	if len(a) != len(b) {
+		c.diff(fmt.Sprintf("%d comments", len(a)), fmt.Sprintf("%d comments", len(b)))
+		return
+	}
+	for i := range a {
+		c.push("[%d]", i)
+		c.pos(a[i].Pos, b[i].Pos)
+		c.field("Text", a[i].Text, b[i].Text)
+		c.field("IsBlock", a[i].IsBlock, b[i].IsBlock)
+		c.pop()
+	}
+}
+
+func (c *nodeComparer) node(a, b Node) {
+	if a == nil || b == nil {
+		if a != nil || b != nil {
+			c.diff(nodeTypeString(a), nodeTypeString(b))
+		}
+		return
+	}
+	if a.Type() != b.Type() {
+		c.diff(a.Type(), b.Type())
+		return
+	}
+	c.pos(a.Position(), b.Position())
+	c.commentGroup(a.Comments(), b.Comments())
+
+	switch a := a.(type) {
+	case *NullNode:
+		// No fields
+	case *BoolNode:
+		b := b.(*BoolNode)
+		c.field("True", a.True, b.True)
+	case *NumberNode:
+		b := b.(*NumberNode)
+		c.field("IsUint", a.IsUint, b.IsUint)
+		c.field("IsInt", a.IsInt, b.IsInt)
+		c.field("IsFloat", a.IsFloat, b.IsFloat)
+		c.field("Uint64", a.Uint64, b.Uint64)
+		c.field("Int64", a.Int64, b.Int64)
+		c.field("Float64", a.Float64, b.Float64)
+		c.field("Raw", a.Raw, b.Raw)
+	case *StringNode:
+		b := b.(*StringNode)
+		c.field("Value", fmt.Sprintf("%q", a.Value), fmt.Sprintf("%q", b.Value))
+	case *RawStringNode:
+		b := b.(*RawStringNode)
+		c.field("Value", fmt.Sprintf("%q", a.Value), fmt.Sprintf("%q", b.Value))
+	case *MultilineStringNode:
+		b := b.(*MultilineStringNode)
+		c.field("Value", fmt.Sprintf("%q", a.Value), fmt.Sprintf("%q", b.Value))
+	case *IdentifierNode:
+		b := b.(*IdentifierNode)
+		c.field("Name", a.Name, b.Name)
+	case *VariableNode:
+		b := b.(*VariableNode)
+		c.child("Identifier", a.Identifier, b.Identifier)
+		c.field("IsCall", a.IsCall, b.IsCall)
+		c.list("Args", len(a.Args), len(b.Args),
+			func(i int) Node { return a.Args[i] },
+			func(i int) Node { return b.Args[i] })
+		if !c.ignorePositions {
+			c.field("Rparen", a.Rparen, b.Rparen)
+		}
+		var ad, bd Node
+		if a.Default != nil {
+			ad = a.Default
+		}
+		if b.Default != nil {
+			bd = b.Default
+		}
+		c.child("Default", ad, bd)
+	case *AnchorNode:
+		b := b.(*AnchorNode)
+		c.child("Name", a.Name, b.Name)
+		c.child("Value", a.Value, b.Value)
+	case *AliasNode:
+		b := b.(*AliasNode)
+		c.child("Name", a.Name, b.Name)
+	case *ExpressionNode:
+		b := b.(*ExpressionNode)
+		c.field("Op", a.Op, b.Op)
+		c.list("Operands", len(a.Operands), len(b.Operands),
+			func(i int) Node { return a.Operands[i] },
+			func(i int) Node { return b.Operands[i] })
+		c.endPos(a.End, b.End)
+	case *InterpolatedStringNode:
+		b := b.(*InterpolatedStringNode)
+		c.endPos(a.End, b.End)
+		c.list("Components", len(a.Components), len(b.Components),
+			func(i int) Node { return a.Components[i] },
+			func(i int) Node { return b.Components[i] })
+	case *ListNode:
+		b := b.(*ListNode)
+		c.endPos(a.End, b.End)
+		c.list("Elements", len(a.Elements), len(b.Elements),
+			func(i int) Node { return a.Elements[i] },
+			func(i int) Node { return b.Elements[i] })
+	case *MemberNode:
+		b := b.(*MemberNode)
+		c.child("Key", a.Key, b.Key)
+		c.child("Value", a.Value, b.Value)
+	case *DictionaryNode:
+		b := b.(*DictionaryNode)
+		c.endPos(a.End, b.End)
+		c.list("Members", len(a.Members), len(b.Members),
+			func(i int) Node { return a.Members[i] },
+			func(i int) Node { return b.Members[i] })
+	default:
+		panic(fmt.Errorf("scparse.Equal: unexpected node type %T", a))
+	}
+}
+
+func (c *nodeComparer) child(name string, a, b Node) {
+	c.push("%s", name)
+	c.node(a, b)
+	c.pop()
+}
+
+func (c *nodeComparer) list(name string, alen, blen int, aElem, bElem func(i int) Node) {
+	c.push("%s", name)
+	defer c.pop()
+	for i := 0; i < alen || i < blen; i++ {
+		c.push("[%d]", i)
+		switch {
+		case i >= alen:
+			c.diff("<missing>", bElem(i).Type())
+		case i >= blen:
+			c.diff(aElem(i).Type(), "<missing>")
+		default:
+			c.node(aElem(i), bElem(i))
+		}
+		c.pop()
+	}
+}
+
+func nodeTypeString(n Node) string {
+	if n == nil {
+		return "<nil>"
+	}
+	return n.Type().String()
+}
@@ -0,0 +1,66 @@
+// Copyright (c) 2021 the SC authors. All rights reserved. MIT License.
+
+package scparse
+
+import "testing"
+
+func TestEqual(t *testing.T) {
+	parse := func(s string) *DictionaryNode {
+		n, err := Parse([]byte(s))
+		if err != nil {
+			t.Fatalf("unexpected error %s", err)
+		}
+		return n
+	}
+	a := parse(`{
+  // Comment
+  name: "foo"
+  list: [1, 2]
+}`)
+	b := parse(`{ name: "foo", list: [1, 2] }`)
+	c := parse(`{ name: "bar", list: [1] }`)
+	// The member with the stray bracket is skipped instead of containing the end of a list
+	tolerant, _ := ParseTolerant([]byte(`{a: ], b: 1}`))
+
+	tests := []struct {
+		name string
+		a, b Node
+		opts []CompareOption
+		diff string
+	}{
+		{"identical", a, a, nil, ""},
+		{"ignore positions and comments", a, b, []CompareOption{IgnorePositions(), IgnoreComments()}, ""},
+		{
+			"comments differ",
+			a, b,
+			[]CompareOption{IgnorePositions()},
+			"Members[0].Key.CommentGroup.Head: 1 comments != 0 comments\n",
+		},
+		{
+			"values differ",
+			b, c,
+			[]CompareOption{IgnorePositions()},
+			"Members[0].Value.Components[0].Value: \"foo\" != \"bar\"\n" +
+				"Members[1].Value.Elements[1]: Number != <missing>\n",
+		},
+		{
+			"types differ",
+			NewInt(1), NewString("1"),
+			nil,
+			"<root>: Number != InterpolatedString\n",
+		},
+		{"stray bracket", tolerant, NewDict(NewMember("b", NewInt(1))), []CompareOption{IgnorePositions()}, ""},
+		{"built matches parsed", NewDict(NewMember("name", NewString("foo")), NewMember("list", NewList(NewInt(1), NewInt(2)))), b, []CompareOption{IgnorePositions()}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			equal, diff := Equal(tt.a, tt.b, tt.opts...)
+			if equal != (tt.diff == "") {
+				t.Errorf("got equal %t, want %t", equal, tt.diff == "")
+			}
+			if diff != tt.diff {
+				t.Errorf("got diff\n%s\nwant\n%s", diff, tt.diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+// Copyright (c) 2021 the SC authors. All rights reserved. MIT License.
+
+package scparse
+
+import "fmt"
+
+// Eval evaluates the expression n. lookup is called to get the value of each variable
+// or function call operand and reports whether the variable has a value.
+// ok reports whether the result has a value, it is false if the result is an operand
+// that lookup reported as having no value.
+//
+// Expressions are evaluated as follows:
+//
+//   - a ?? b evaluates to a if it has a value that is not nil, otherwise b.
+//   - a == b evaluates to true if a and b are equal, otherwise false. Operands are
+//     equal if their values are formatted the same by fmt.Sprint, so ${port == "8080"}
+//     is true if port is the number 8080. Operands without a value or with a nil value
+//     are only equal to each other. a != b is the opposite of a == b.
+//   - cond ? a : b evaluates to a if cond is true, otherwise b. A condition is true
+//     if it has a value that is not nil, false, or the empty string.
+//
+// Operands are only evaluated if they are needed, ex: b is not evaluated in a ?? b if a
+// has a value. Evaluation stops at the first error returned by lookup.
+func (n *ExpressionNode) Eval(lookup func(n *VariableNode) (val interface{}, ok bool, err error)) (val interface{}, ok bool, err error) {
+	switch n.Op {
+	case OpCoalesce:
+		val, ok, err = evalOperand(n.Operands[0], lookup)
+		if err != nil || (ok && val != nil) {
+			return val, ok, err
+		}
+		return evalOperand(n.Operands[1], lookup)
+	case OpEqual, OpNotEqual:
+		a, aok, err := evalOperand(n.Operands[0], lookup)
+		if err != nil {
+			return nil, false, err
+		}
+		b, bok, err := evalOperand(n.Operands[1], lookup)
+		if err != nil {
+			return nil, false, err
+		}
+		var eq bool
+		if aok && a != nil && bok && b != nil {
+			eq = fmt.Sprint(a) == fmt.Sprint(b)
+		} else {
+			eq = (!aok || a == nil) && (!bok || b == nil)
+		}
+		return eq == (n.Op == OpEqual), true, nil
+	case OpConditional:
+		cond, ok, err := evalOperand(n.Operands[0], lookup)
+		if err != nil {
+			return nil, false, err
+		}
+		if ok && isTrue(cond) {
+			return evalOperand(n.Operands[1], lookup)
+		}
+		return evalOperand(n.Operands[2], lookup)
+	}
+	panic(fmt.Errorf("impossible: unknown expression operator %q", n.Op))
+}
+
+// evalOperand evaluates the operand n of an expression.
+func evalOperand(n StringContentNode, lookup func(n *VariableNode) (interface{}, bool, error)) (interface{}, bool, error) {
+	switch n := n.(type) {
+	case *StringNode:
+		return n.Value, true, nil
+	case *VariableNode:
+		return lookup(n)
+	case *ExpressionNode:
+		return n.Eval(lookup)
+	}
+	panic(fmt.Errorf("impossible: invalid node type in expression: %T", n))
+}
+
+// isTrue reports whether the value of a condition is true.
+func isTrue(v interface{}) bool {
+	switch v := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case string:
+		return v != ""
+	}
+	return true
+}
@@ -0,0 +1,68 @@
+// Copyright (c) 2021 the SC authors. All rights reserved. MIT License.
+
+package scparse
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestEval(t *testing.T) {
+	vars := map[string]interface{}{
+		"env":   "prod",
+		"port":  8080,
+		"debug": false,
+		"empty": "",
+		"none":  nil,
+	}
+	lookup := func(n *VariableNode) (interface{}, bool, error) {
+		if n.IsCall {
+			return nil, false, errors.New("boom")
+		}
+		v, ok := vars[n.Identifier.Name]
+		return v, ok, nil
+	}
+	tests := []struct {
+		input  string
+		want   interface{}
+		wantOK bool
+	}{
+		{`${unknown ?? "x"}`, "x", true},
+		{`${none ?? env}`, "prod", true},
+		{`${env ?? fail()}`, "prod", true},
+		{`${unknown ?? none ?? missing}`, nil, false},
+		{`${port == "8080"}`, true, true},
+		{`${env != "prod"}`, false, true},
+		{`${unknown == none}`, true, true},
+		{`${unknown == ""}`, false, true},
+		{`${env == "prod" ? "a" : fail()}`, "a", true},
+		{`${debug ? "a" : "b"}`, "b", true},
+		{`${empty ? "a" : "b"}`, "b", true},
+		{`${port ? "a" : "b"}`, "a", true},
+		{`${unknown ? "a" : missing}`, nil, false},
+		{`${(unknown ?? env) == "prod" ? port : "b"}`, 8080, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			n, err := Parse([]byte("{ a: " + tt.input + " }"))
+			if err != nil {
+				t.Fatalf("unexpected error %s", err)
+			}
+			got, ok, err := n.Get("a").(*ExpressionNode).Eval(lookup)
+			if err != nil {
+				t.Fatalf("unexpected error %s", err)
+			}
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("got %v, %t, want %v, %t", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+
+	n, err := Parse([]byte(`{ a: ${unknown ?? fail()} }`))
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	if _, _, err := n.Get("a").(*ExpressionNode).Eval(lookup); err == nil || err.Error() != "boom" {
+		t.Errorf("got error %v, want boom", err)
+	}
+}
@@ -0,0 +1,186 @@
+// Copyright (c) 2021 the SC authors. All rights reserved. MIT License.
+
+package scparse
+
+import "strconv"
+
+// NodeAt returns the innermost node in the AST rooted at root that covers pos
+// along with the path of nodes from root to the node. The first element
+// of path is root and the last element is n. Only pos.Byte is used.
+//
+// The source of a node ends at its last character, ex: the closing bracket of a list.
+// Positions between nodes, such as whitespace and commas, are covered by the parent node.
+// The end of a string key is not recorded in the AST so positions up to the
+// value of the member are considered part of the key.
+//
+// If pos is not within root, NodeAt returns nil, nil.
+func NodeAt(root Node, pos Pos) (n Node, path []Node) {
+	off := pos.Byte
+	end, ok := endOffset(root)
+	if off < root.Position().Byte || (ok && off >= end) {
+		return nil, nil
+	}
+	path = nodeAt(root, off, path)
+	return path[len(path)-1], path
+}
+
+// PathTo returns the path of nodes from root to target. The first element
+// of the path is root and the last element is target. Nodes are compared by identity.
+// If target is not in the AST rooted at root, PathTo returns nil.
+func PathTo(root, target Node) []Node {
+	var stack []Node
+	found := false
+	Inspect(root, func(n Node) bool {
+		if found {
+			return false
+		}
+		if n == nil {
+			stack = stack[:len(stack)-1]
+			return false
+		}
+		stack = append(stack, n)
+		if n == target {
+			found = true
+			return false
+		}
+		return true
+	})
+	if !found {
+		return nil
+	}
+	return stack
+}
+
+// PathString returns the path to the value at the end of path in the format used
+// by Diff, ex: services.api.ports[1]. path must be a path of nodes as returned
+// by PathTo or NodeAt. Nodes in the path that are not values of a dictionary or
+// list, such as keys or the components of a string, do not contribute to the result.
+func PathString(path []Node) string {
+	var s string
+	for i := 1; i < len(path); i++ {
+		switch parent := path[i-1].(type) {
+		case *MemberNode:
+			if path[i] == parent.Value {
+				s = joinKey(s, parent.Key.KeyString())
+			}
+		case *ListNode:
+			for j, e := range parent.Elements {
+				if e == path[i] {
+					s += "[" + strconv.Itoa(j) + "]"
+					break
+				}
+			}
+		}
+	}
+	return s
+}
+
+func nodeAt(n Node, off int, path []Node) []Node {
+	path = append(path, n)
+	children := childNodes(n)
+	for i, c := range children {
+		if off < c.Position().Byte {
+			break
+		}
+		end, ok := endOffset(c)
+		if !ok {
+			// Use the start of the next node as the end
+			if i+1 < len(children) {
+				end = children[i+1].Position().Byte
+			} else if pend, ok := endOffset(n); ok {
+				end = pend - 1
+			}
+		}
+		if off < end {
+			return nodeAt(c, off, path)
+		}
+	}
+	return path
+}
+
+// childNodes returns the children of n in the order they appear in the source.
+func childNodes(n Node) []Node {
+	var children []Node
+	switch n := n.(type) {
+	case *VariableNode:
+		children = append(children, n.Identifier)
+		for _, a := range n.Args {
+			children = append(children, a)
+		}
+		if n.Default != nil {
+			children = append(children, n.Default)
+		}
+	case *AnchorNode:
+		children = append(children, n.Name, n.Value)
+	case *AliasNode:
+		children = append(children, n.Name)
+	case *ExpressionNode:
+		for _, o := range n.Operands {
+			children = append(children, o)
+		}
+	case *InterpolatedStringNode:
+		for _, c := range n.Components {
+			children = append(children, c)
+		}
+	case *ListNode:
+		for _, e := range n.Elements {
+			children = append(children, e)
+		}
+	case *MemberNode:
+		children = append(children, n.Key, n.Value)
+	case *DictionaryNode:
+		for _, m := range n.Members {
+			children = append(children, m)
+		}
+	}
+	return children
+}
+
+// endOffset returns the byte offset immediately after the source of n.
+// ok is false if the end cannot be determined from the AST.
+func endOffset(n Node) (end int, ok bool) {
+	start := n.Position().Byte
+	switch n := n.(type) {
+	case *NullNode, *BoolNode:
+		return start + len(n.String()), true
+	case *NumberNode:
+		return start + len(n.Raw), true
+	case *RawStringNode:
+		return start + len(n.Value) + len("``"), true
+	case *MultilineStringNode:
+		return n.End.Byte + len(`"""`), true
+	case *IdentifierNode:
+		return start + len(n.Name), true
+	case *VariableNode:
+		if n.Default != nil {
+			return n.Default.Pos.Byte + len(":-") + len(n.Default.Value) + len("}"), true
+		}
+		end := n.Identifier.Pos.Byte + len(n.Identifier.Name)
+		if n.IsCall {
+			end = n.Rparen.Byte + len(")")
+		}
+		// Function call arguments are not wrapped in ${}
+		if n.Pos != n.Identifier.Pos {
+			end += len("}")
+		}
+		return end, true
+	case *AnchorNode:
+		return endOffset(n.Value)
+	case *AliasNode:
+		return endOffset(n.Name)
+	case *ExpressionNode:
+		if n.End != (Pos{}) {
+			return n.End.Byte + len("}"), true
+		}
+		return endOffset(n.Operands[len(n.Operands)-1])
+	case *InterpolatedStringNode:
+		return n.End.Byte + 1, true
+	case *ListNode:
+		return n.End.Byte + 1, true
+	case *DictionaryNode:
+		return n.End.Byte + 1, true
+	case *MemberNode:
+		return endOffset(n.Value)
+	}
+	return 0, false
+}
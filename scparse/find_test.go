@@ -0,0 +1,123 @@
+// Copyright (c) 2021 the SC authors. All rights reserved. MIT License.
+
+package scparse
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNodeAt(t *testing.T) {
+	input := `{
+  name: "foo-${env:-dev}"
+  "the list": [1, null, ` + "`raw`" + `]
+  nested: { enabled: true }
+}`
+	n, err := Parse([]byte(input))
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	tests := []struct {
+		at   string // text at the position, the position is the first byte of the match
+		skip int    // number of bytes to skip past the start of at
+		want string // the types of the nodes in the path
+	}{
+		{"{", 0, "Dictionary"},
+		{"name", 2, "Dictionary Member Identifier"},
+		{": \"foo", 0, "Dictionary Member"},
+		{"\"foo", 0, "Dictionary Member InterpolatedString"},
+		{"foo", 1, "Dictionary Member InterpolatedString String"},
+		{"${env", 0, "Dictionary Member InterpolatedString Variable"},
+		{"env", 1, "Dictionary Member InterpolatedString Variable Identifier"},
+		{":-dev", 3, "Dictionary Member InterpolatedString Variable String"},
+		{"}\"", 0, "Dictionary Member InterpolatedString Variable"},
+		{"}\"", 1, "Dictionary Member InterpolatedString"},
+		{"\n  \"the", 0, "Dictionary"},
+		{"the list", 0, "Dictionary Member String"},
+		{"[1", 0, "Dictionary Member List"},
+		{"1,", 0, "Dictionary Member List Number"},
+		{", null", 0, "Dictionary Member List"},
+		{"null", 3, "Dictionary Member List Null"},
+		{"`raw`", 4, "Dictionary Member List RawString"},
+		{"true", 0, "Dictionary Member Dictionary Member Bool"},
+		{" }\n}", 0, "Dictionary Member Dictionary"},
+		{"}\n}", 0, "Dictionary Member Dictionary"},
+		{"\n}", 1, "Dictionary"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.at, func(t *testing.T) {
+			i := strings.Index(input, tt.at)
+			if i < 0 {
+				t.Fatalf("%q not found in input", tt.at)
+			}
+			got, path := NodeAt(n, Pos{Byte: i + tt.skip})
+			var types []string
+			for _, p := range path {
+				types = append(types, p.Type().String())
+			}
+			if s := strings.Join(types, " "); s != tt.want {
+				t.Errorf("got path %s, want %s", s, tt.want)
+			}
+			if len(path) > 0 && got != path[len(path)-1] {
+				t.Errorf("got node %v, want last node in path %v", got, path[len(path)-1])
+			}
+		})
+	}
+
+	if got, path := NodeAt(n, Pos{Byte: len(input)}); got != nil || path != nil {
+		t.Errorf("got %v, %v for position after the document, want nil", got, path)
+	}
+}
+
+func TestPathTo(t *testing.T) {
+	n, err := Parse([]byte(`{
+  services: {
+    api: {
+      ports: [8080, { src: 443, dst: 8443 }]
+    }
+    "web-app": { image: "web:${tag}" }
+  }
+}`))
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	api := n.Get("services").(*DictionaryNode).Get("api").(*DictionaryNode)
+	ports := api.Get("ports").(*ListNode)
+	dst := ports.Elements[1].(*DictionaryNode).Member("dst")
+	webApp := n.Get("services").(*DictionaryNode).Get("web-app").(*DictionaryNode)
+	tag := webApp.Get("image").(*InterpolatedStringNode).Components[1]
+
+	tests := []struct {
+		name   string
+		target Node
+		types  string
+		path   string
+	}{
+		{"root", n, "Dictionary", ""},
+		{"list", ports, "Dictionary Member Dictionary Member Dictionary Member List", "services.api.ports"},
+		{"list element", ports.Elements[0], "Dictionary Member Dictionary Member Dictionary Member List Number", "services.api.ports[0]"},
+		{"member", dst, "Dictionary Member Dictionary Member Dictionary Member List Dictionary Member", "services.api.ports[1]"},
+		{"member value", dst.Value, "Dictionary Member Dictionary Member Dictionary Member List Dictionary Member Number", "services.api.ports[1].dst"},
+		{"key", dst.Key, "Dictionary Member Dictionary Member Dictionary Member List Dictionary Member Identifier", "services.api.ports[1]"},
+		{"variable", tag, "Dictionary Member Dictionary Member Dictionary Member InterpolatedString Variable", `services."web-app".image`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := PathTo(n, tt.target)
+			var types []string
+			for _, p := range path {
+				types = append(types, p.Type().String())
+			}
+			if s := strings.Join(types, " "); s != tt.types {
+				t.Errorf("got path %s, want %s", s, tt.types)
+			}
+			if s := PathString(path); s != tt.path {
+				t.Errorf("got path string %q, want %q", s, tt.path)
+			}
+		})
+	}
+
+	if path := PathTo(n, &NullNode{}); path != nil {
+		t.Errorf("got path %v for node not in AST, want nil", path)
+	}
+}
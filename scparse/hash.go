@@ -0,0 +1,244 @@
+// Copyright (c) 2021 the SC authors. All rights reserved. MIT License.
+
+package scparse
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"sort"
+	"strings"
+)
+
+// HashOption is an option that can be provided to Hash to customize
+// which parts of an AST contribute to the hash.
+//
+// The signature contains an unexported type so that only options defined in this
+// package are valid.
+type HashOption func(*hasher)
+
+// HashIgnoreComments causes Hash to ignore all comments.
+func HashIgnoreComments() HashOption {
+	return func(h *hasher) {
+		h.ignoreComments = true
+	}
+}
+
+// HashIgnoreKeyOrder causes Hash to ignore the order of dictionary members.
+// The order of members with the same key is still significant since it
+// determines which value is used.
+func HashIgnoreKeyOrder() HashOption {
+	return func(h *hasher) {
+		h.ignoreKeyOrder = true
+	}
+}
+
+// Hash returns a SHA-256 hash of the content of the AST rooted at n. It can be used
+// to detect whether a document has changed in a way that affects its meaning,
+// without formatting or diffing it. The hash is stable, it does not change between
+// runs of a program or versions of this package.
+//
+// Only the content of n is hashed, the representation is not. Positions, blank lines,
+// and the style of comments are ignored, and values are normalized the
+// same way as by Canonicalize. For example, 1.0 and 1e0 have the same hash, as do a
+// raw string and a string with the same value, and a quoted key and an identifier key.
+// Anchors and aliases are expanded if possible, see ExpandAnchors, in which case
+// their comments are ignored.
+//
+// By default, the text of comments and the order of dictionary members are part of the hash.
+// Use HashIgnoreComments and HashIgnoreKeyOrder to customize the hash.
+func Hash(n Node, opts ...HashOption) [sha256.Size]byte {
+	h := &hasher{h: sha256.New()}
+	for _, opt := range opts {
+		opt(h)
+	}
+	switch v := n.(type) {
+	case ValueNode:
+		n = expandAnchors(v)
+	case *MemberNode:
+		m := *v
+		m.Value = expandAnchors(v.Value)
+		n = &m
+	}
+	h.node(n)
+	var sum [sha256.Size]byte
+	h.h.Sum(sum[:0])
+	return sum
+}
+
+// expandAnchors returns n with its anchors and aliases expanded.
+// If they cannot be expanded, n is returned as is.
+func expandAnchors(n ValueNode) ValueNode {
+	if xn, err := ExpandAnchors(n); err == nil {
+		return xn
+	}
+	return n
+}
+
+// hasher holds the state for hashing a node.
+type hasher struct {
+	ignoreComments bool
+	ignoreKeyOrder bool
+
+	h hash.Hash
+}
+
+// Tags that identify the type of each hashed value.
+const (
+	hashNull       = 'n'
+	hashTrue       = 't'
+	hashFalse      = 'f'
+	hashNumber     = '#'
+	hashString     = 's'
+	hashInterp     = 'i'
+	hashVariable   = 'v'
+	hashExpression = 'e'
+	hashAnchor     = '&'
+	hashAlias      = '*'
+	hashList       = 'l'
+	hashDictionary = 'd'
+	hashMember     = 'm'
+	hashComments   = 'c'
+)
+
+func (h *hasher) tag(t byte) {
+	h.h.Write([]byte{t})
+}
+
+func (h *hasher) int(n int) {
+	var b [binary.MaxVarintLen64]byte
+	h.h.Write(b[:binary.PutUvarint(b[:], uint64(n))])
+}
+
+// string writes s prefixed by its length so that adjacent strings cannot be confused.
+func (h *hasher) string(s string) {
+	h.int(len(s))
+	h.h.Write([]byte(s))
+}
+
+func (h *hasher) comments(c *CommentGroup) {
+	if h.ignoreComments {
+		return
+	}
+	h.tag(hashComments)
+	for _, comments := range [][]Comment{c.Head, c.Inline, c.Foot, c.Inner} {
+		h.int(len(comments))
+		for _, cm := range comments {
+			// Leading and trailing spaces are formatting, ex: /* c */ and // c are equivalent
+			h.string(strings.TrimSpace(cm.Text))
+		}
+	}
+}
+
+func (h *hasher) node(n Node) {
+	switch n := n.(type) {
+	case *NullNode:
+		h.tag(hashNull)
+	case *BoolNode:
+		if n.True {
+			h.tag(hashTrue)
+		} else {
+			h.tag(hashFalse)
+		}
+	case *NumberNode:
+		h.tag(hashNumber)
+		cn := canonicalNumber(n)
+		if cn.Raw != "" {
+			h.string(cn.Raw)
+		} else {
+			// Not representable in SC, ex: NaN
+			h.string(fmt.Sprintf("%t %t %t %d %d %v", cn.IsUint, cn.IsInt, cn.IsFloat, cn.Uint64, cn.Int64, cn.Float64))
+		}
+	case *StringNode:
+		h.tag(hashString)
+		h.string(n.Value)
+	case *RawStringNode:
+		h.tag(hashString)
+		h.string(n.Value)
+	case *MultilineStringNode:
+		h.tag(hashString)
+		h.string(n.Value)
+	case *IdentifierNode:
+		// Identifiers are hashed like strings so quoted and unquoted keys are equivalent
+		h.tag(hashString)
+		h.string(n.Name)
+	case *InterpolatedStringNode:
+		cn := canonicalString(n)
+		switch len(cn.Components) {
+		case 0:
+			h.tag(hashString)
+			h.string("")
+		case 1:
+			if s, ok := cn.Components[0].(*StringNode); ok {
+				h.tag(hashString)
+				h.string(s.Value)
+				break
+			}
+			fallthrough
+		default:
+			h.tag(hashInterp)
+			h.int(len(cn.Components))
+			for _, c := range cn.Components {
+				h.node(c)
+			}
+		}
+	case *VariableNode:
+		h.tag(hashVariable)
+		h.string(n.Identifier.Name)
+		if n.IsCall {
+			h.int(len(n.Args) + 1)
+			for _, a := range n.Args {
+				h.node(a)
+			}
+		} else {
+			h.int(0)
+		}
+		if n.Default != nil {
+			h.tag(hashString)
+			h.string(n.Default.Value)
+		} else {
+			h.tag(hashNull)
+		}
+	case *ExpressionNode:
+		h.tag(hashExpression)
+		h.string(n.Op)
+		h.int(len(n.Operands))
+		for _, o := range n.Operands {
+			h.node(o)
+		}
+	case *AnchorNode:
+		h.tag(hashAnchor)
+		h.string(n.Name.Name)
+		h.node(n.Value)
+	case *AliasNode:
+		h.tag(hashAlias)
+		h.string(n.Name.Name)
+	case *ListNode:
+		h.tag(hashList)
+		h.int(len(n.Elements))
+		for _, e := range n.Elements {
+			h.node(e)
+		}
+	case *MemberNode:
+		h.tag(hashMember)
+		h.node(n.Key)
+		h.node(n.Value)
+	case *DictionaryNode:
+		h.tag(hashDictionary)
+		members := n.Members
+		if h.ignoreKeyOrder {
+			members = append([]*MemberNode(nil), members...)
+			sort.SliceStable(members, func(i, j int) bool {
+				return members[i].Key.KeyString() < members[j].Key.KeyString()
+			})
+		}
+		h.int(len(members))
+		for _, m := range members {
+			h.node(m)
+		}
+	default:
+		panic(fmt.Errorf("impossible: unexpected node type %T", n))
+	}
+	h.comments(n.Comments())
+}
@@ -0,0 +1,67 @@
+// Copyright (c) 2021 the SC authors. All rights reserved. MIT License.
+
+package scparse
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestHash(t *testing.T) {
+	tests := []struct {
+		name  string
+		a, b  string
+		opts  []HashOption
+		equal bool
+	}{
+		{"identical", "{a: 1}", "{a: 1}", nil, true},
+		{"formatting", "{a: 1, b: [1, 2]}", "{\n  a: 1\n\n  b: [\n    1,\n    2,\n  ]\n}", nil, true},
+		{"different value", "{a: 1}", "{a: 2}", nil, false},
+		{"different key", "{a: 1}", "{b: 1}", nil, false},
+		{"number representation", "{a: 1.0, b: 1e3}", "{a: 1, b: 1000}", nil, true},
+		{"string representation", "{a: \"x\", b: \"y\" + \"z\"}", "{\"a\": `x`, b: \"yz\"}", nil, true},
+		{"multiline string", "{a: \"\"\"\n  x\n  \"\"\"}", "{a: \"x\"}", nil, true},
+		{"variable", "{a: \"${x}\"}", "{a: ${x}}", nil, false},
+		{"default", "{a: ${x:-1}}", "{a: ${x:-2}}", nil, false},
+		{"call", "{a: ${f()}}", "{a: ${f}}", nil, false},
+		{"string boundaries", "{a: [\"ab\", \"c\"]}", "{a: [\"a\", \"bc\"]}", nil, false},
+		{"types", "{a: \"1\"}", "{a: 1}", nil, false},
+		{"anchors", "{a: &x [1], b: *x}", "{a: [1], b: [1]}", nil, true},
+		{"comments", "{a: 1 // c\n}", "{a: 1 /* c */\n}", nil, true},
+		{"different comments", "{a: 1 // c\n}", "{a: 1 // d\n}", nil, false},
+		{"ignore comments", "{\n  // x\n  a: 1 // c\n}", "{a: 1}", []HashOption{HashIgnoreComments()}, true},
+		{"key order", "{a: 1, b: 2}", "{b: 2, a: 1}", nil, false},
+		{"ignore key order", "{a: 1, b: {c: 3, d: 4}}", "{b: {d: 4, c: 3}, a: 1}", []HashOption{HashIgnoreKeyOrder()}, true},
+		{"duplicate key order", "{a: 1, a: 2}", "{a: 2, a: 1}", []HashOption{HashIgnoreKeyOrder()}, false},
+		{"list order", "{a: [1, 2]}", "{a: [2, 1]}", []HashOption{HashIgnoreKeyOrder()}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := Parse([]byte(tt.a))
+			if err != nil {
+				t.Fatalf("failed to parse a: %v", err)
+			}
+			b, err := Parse([]byte(tt.b))
+			if err != nil {
+				t.Fatalf("failed to parse b: %v", err)
+			}
+			ha, hb := Hash(a, tt.opts...), Hash(b, tt.opts...)
+			if (ha == hb) != tt.equal {
+				t.Errorf("got equal hashes %t, want %t", ha == hb, tt.equal)
+			}
+		})
+	}
+}
+
+// TestHashStable checks that the hash does not change unintentionally,
+// since callers may store it.
+func TestHashStable(t *testing.T) {
+	n, err := Parse([]byte("{a: 1}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	const want = "a843c84cafc5bf36e1a027955eaf286e70211d787a67e6c4a5b7f52f3a6c1353"
+	if got := fmt.Sprintf("%x", Hash(n)); got != want {
+		t.Errorf("got hash %s, want %s", got, want)
+	}
+}
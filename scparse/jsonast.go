@@ -0,0 +1,176 @@
+// Copyright (c) 2021 the SC authors. All rights reserved. MIT License.
+
+package scparse
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// ToJSONAST returns a JSON representation of the AST n so that it can be consumed by
+// tools that are not written in Go. The representation is stable: the same AST always
+// produces the same output, and the keys of objects are sorted.
+//
+// Each node is an object with a "type" key containing the name of its NodeType, ex: "Dictionary",
+// and a "pos" key containing its position as an object with "line", "column", and "byte" keys.
+// If the node has comments, they are in a "comments" object with "head", "inline", "foot", and
+// "inner" lists and a "blankLinesBefore" count, each of which is omitted if empty. A comment is
+// an object with "text", "isBlock", and "pos" keys. The other keys depend on the type of node:
+//
+//	Bool:               "value"
+//	Number:             "raw", "isInt", "isUint", "isFloat", and "int", "uint", or "float" if set
+//	String:             "value"
+//	InterpolatedString: "components", "end"
+//	RawString:          "value"
+//	MultilineString:    "value", "end"
+//	Identifier:         "name"
+//	Variable:           "identifier", "default" if set, and "isCall", "args", "rparen" for calls
+//	Expression:         "op", "operands", and "end" if it is not nested
+//	Anchor:             "name", "value"
+//	Alias:              "name"
+//	List:               "elements", "end"
+//	Member:             "key", "value"
+//	Dictionary:         "members", "end"
+//
+// Child nodes and lists of child nodes use the same representation.
+func ToJSONAST(n Node) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(jsonAST(n)); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+type jsonObject = map[string]interface{}
+
+func jsonAST(n Node) jsonObject {
+	o := jsonObject{
+		"type": n.Type().String(),
+		"pos":  jsonPos(n.Position()),
+	}
+	if c := jsonComments(*n.Comments()); c != nil {
+		o["comments"] = c
+	}
+	switch n := n.(type) {
+	case *NullNode:
+		// No other fields
+	case *BoolNode:
+		o["value"] = n.True
+	case *NumberNode:
+		o["raw"] = n.Raw
+		o["isInt"] = n.IsInt
+		o["isUint"] = n.IsUint
+		o["isFloat"] = n.IsFloat
+		if n.IsInt {
+			o["int"] = n.Int64
+		}
+		if n.IsUint {
+			o["uint"] = n.Uint64
+		}
+		if n.IsFloat {
+			o["float"] = n.Float64
+		}
+	case *StringNode:
+		o["value"] = n.Value
+	case *InterpolatedStringNode:
+		components := make([]jsonObject, len(n.Components))
+		for i, c := range n.Components {
+			components[i] = jsonAST(c)
+		}
+		o["components"] = components
+		o["end"] = jsonPos(n.End)
+	case *RawStringNode:
+		o["value"] = n.Value
+	case *MultilineStringNode:
+		o["value"] = n.Value
+		o["end"] = jsonPos(n.End)
+	case *IdentifierNode:
+		o["name"] = n.Name
+	case *VariableNode:
+		o["identifier"] = jsonAST(n.Identifier)
+		if n.Default != nil {
+			o["default"] = jsonAST(n.Default)
+		}
+		if n.IsCall {
+			args := make([]jsonObject, len(n.Args))
+			for i, a := range n.Args {
+				args[i] = jsonAST(a)
+			}
+			o["isCall"] = true
+			o["args"] = args
+			o["rparen"] = jsonPos(n.Rparen)
+		}
+	case *ExpressionNode:
+		operands := make([]jsonObject, len(n.Operands))
+		for i, op := range n.Operands {
+			operands[i] = jsonAST(op)
+		}
+		o["op"] = n.Op
+		o["operands"] = operands
+		if n.End != (Pos{}) {
+			o["end"] = jsonPos(n.End)
+		}
+	case *AnchorNode:
+		o["name"] = jsonAST(n.Name)
+		o["value"] = jsonAST(n.Value)
+	case *AliasNode:
+		o["name"] = jsonAST(n.Name)
+	case *ListNode:
+		elements := make([]jsonObject, len(n.Elements))
+		for i, e := range n.Elements {
+			elements[i] = jsonAST(e)
+		}
+		o["elements"] = elements
+		o["end"] = jsonPos(n.End)
+	case *MemberNode:
+		o["key"] = jsonAST(n.Key)
+		o["value"] = jsonAST(n.Value)
+	case *DictionaryNode:
+		members := make([]jsonObject, len(n.Members))
+		for i, m := range n.Members {
+			members[i] = jsonAST(m)
+		}
+		o["members"] = members
+		o["end"] = jsonPos(n.End)
+	default:
+		panic(fmt.Errorf("impossible: unknown node type %T", n))
+	}
+	return o
+}
+
+func jsonPos(p Pos) jsonObject {
+	return jsonObject{"line": p.Line, "column": p.Column, "byte": p.Byte}
+}
+
+// jsonComments returns the JSON representation of cg or nil if it is empty.
+func jsonComments(cg CommentGroup) jsonObject {
+	o := jsonObject{}
+	for _, g := range []struct {
+		key      string
+		comments []Comment
+	}{
+		{"head", cg.Head},
+		{"inline", cg.Inline},
+		{"foot", cg.Foot},
+		{"inner", cg.Inner},
+	} {
+		if len(g.comments) == 0 {
+			continue
+		}
+		comments := make([]jsonObject, len(g.comments))
+		for i, c := range g.comments {
+			comments[i] = jsonObject{"text": c.Text, "isBlock": c.IsBlock, "pos": jsonPos(c.Pos)}
+		}
+		o[g.key] = comments
+	}
+	if cg.BlankLinesBefore > 0 {
+		o["blankLinesBefore"] = cg.BlankLinesBefore
+	}
+	if len(o) == 0 {
+		return nil
+	}
+	return o
+}
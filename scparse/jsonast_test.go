@@ -0,0 +1,54 @@
+// Copyright (c) 2021 the SC authors. All rights reserved. MIT License.
+
+package scparse
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestToJSONAST(t *testing.T) {
+	n, err := Parse([]byte("{\n  // The port\n  port: ${port:-8080}\n  ratio: 0.5 // half\n}"))
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	got, err := ToJSONAST(n)
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	want := `{"end":{"byte":59,"column":1,"line":5},"members":[` +
+		`{"key":{"comments":{"head":[{"isBlock":false,"pos":{"byte":4,"column":3,"line":2},"text":" The port"}]},` +
+		`"name":"port","pos":{"byte":18,"column":3,"line":3},"type":"Identifier"},"pos":{"byte":18,"column":3,"line":3},"type":"Member",` +
+		`"value":{"default":{"pos":{"byte":30,"column":15,"line":3},"type":"String","value":"8080"},` +
+		`"identifier":{"name":"port","pos":{"byte":26,"column":11,"line":3},"type":"Identifier"},"pos":{"byte":24,"column":9,"line":3},"type":"Variable"}},` +
+		`{"key":{"name":"ratio","pos":{"byte":40,"column":3,"line":4},"type":"Identifier"},"pos":{"byte":40,"column":3,"line":4},"type":"Member",` +
+		`"value":{"comments":{"inline":[{"isBlock":false,"pos":{"byte":51,"column":14,"line":4},"text":" half"}]},` +
+		`"float":0.5,"isFloat":true,"isInt":false,"isUint":false,"pos":{"byte":47,"column":10,"line":4},"raw":"0.5","type":"Number"}}],` +
+		`"pos":{"byte":0,"column":1,"line":1},"type":"Dictionary"}`
+	if string(got) != want {
+		t.Errorf("got JSON\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestToJSONASTAllNodes(t *testing.T) {
+	// Make sure every node type in the parse tests can be converted
+	for _, tt := range parseTests {
+		t.Run(tt.name, func(t *testing.T) {
+			n, err := Parse([]byte(tt.input))
+			if err != nil {
+				t.Fatalf("unexpected error %s", err)
+			}
+			b, err := ToJSONAST(n)
+			if err != nil {
+				t.Fatalf("unexpected error %s", err)
+			}
+			var v map[string]interface{}
+			if err := json.Unmarshal(b, &v); err != nil {
+				t.Fatalf("invalid JSON: %s", err)
+			}
+			if v["type"] != "Dictionary" {
+				t.Errorf("got type %v, want Dictionary", v["type"])
+			}
+		})
+	}
+}
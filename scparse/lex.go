@@ -16,12 +16,18 @@ type tokenType int
 const (
 	tokenError tokenType = iota // val contains error details
 	tokenEOF
-	tokenBool       // bool literal, either true or false
-	tokenNumber     // number literal
-	tokenString     // double quoted string (excludes quotes)
-	tokenRawString  // raw quoted string (includes quotes)
-	tokenIdentifier // alphanumberic identifier starting with a letter
-	tokenComment    // a comment, either // or /* style
+	tokenBool            // bool literal, either true or false
+	tokenNumber          // number literal
+	tokenString          // double quoted string (excludes quotes)
+	tokenRawString       // raw quoted string (includes quotes)
+	tokenMultilineString // triple quoted multi-line string (includes quotes)
+	tokenIdentifier      // alphanumberic identifier starting with a letter
+	tokenComment         // a comment, either // or /* style
+	tokenDefault         // default value of a variable (includes :-)
+	tokenAnchor          // anchor name (includes &)
+	tokenAlias           // alias name (includes *)
+	tokenArgString       // double quoted function argument or expression operand (includes quotes)
+	tokenOperator        // operator in an expression: ??, ==, != or ?
 	// Everything from here on is a symbol or keyword
 	tokenSymbol           // only used as a delimiter for token types
 	tokenLeftSquareParen  // [
@@ -32,6 +38,9 @@ const (
 	tokenQuote            // "
 	tokenColon            // :
 	tokenComma            // ,
+	tokenPlus             // +
+	tokenLeftParen        // (
+	tokenRightParen       // )
 	tokenNull             // the null literal
 )
 
@@ -45,8 +54,14 @@ func (typ tokenType) String() string {
 		"Number",
 		"String",
 		"RawString",
+		"MultilineString",
 		"Identifier",
 		"Comment",
+		"Default",
+		"Anchor",
+		"Alias",
+		"ArgString",
+		"Operator",
 		"Symbol", // Unused but required so the index works
 		"LeftSquareParen",
 		"RightSquareParen",
@@ -56,6 +71,9 @@ func (typ tokenType) String() string {
 		"Quote",
 		"Colon",
 		"Comma",
+		"Plus",
+		"LeftParen",
+		"RightParen",
 		"Null",
 	}[typ]
 }
@@ -64,7 +82,7 @@ func (typ tokenType) String() string {
 type token struct {
 	typ tokenType // The type of this token.
 	pos Pos       // The position of this token in the input text.
-	val string    // The value of this token.
+	val []byte    // The value of this token. It references the input text to avoid allocating.
 }
 
 // String returns a string representation of the token.
@@ -73,7 +91,7 @@ func (t token) String() string {
 	case t.typ == tokenEOF:
 		return "EOF"
 	case t.typ == tokenError:
-		return t.val
+		return string(t.val)
 	case t.typ > tokenSymbol:
 		return fmt.Sprintf("<%s>", t.val)
 	case tokenString <= t.typ && t.typ <= tokenComment && len(t.val) > 10:
@@ -103,15 +121,21 @@ const (
 
 // lexer holds the state of the scanner.
 type lexer struct {
-	input       []byte     // the text being scanned
-	pos         int        // current byte position in the input
-	start       int        // start position of this token
-	width       int        // width of the last rune read from input
-	tokens      chan token // channel of scanned tokens
-	line        int        // 1 + number of newlines seen
-	startLine   int        // start line of this token
-	insertComma bool       // should insert a comma before next newline
-	mode        lexerMode  // the mode the lexer is currently in
+	input       []byte    // the text being scanned
+	pos         int       // current byte position in the input
+	start       int       // start position of this token
+	width       int       // width of the last rune read from input
+	state       stateFn   // the next state to run, nil once scanning has ended
+	tokens      []token   // scanned tokens that have not been returned yet
+	head        int       // index of the next token in tokens to return
+	line        int       // 1 + number of newlines seen
+	startLine   int       // start line of this token
+	insertComma bool      // should insert a comma before next newline
+	mode        lexerMode // the mode the lexer is currently in
+	tolerant    bool      // resume scanning after an error
+	dottedKeys  bool      // allow dots in identifiers
+	callDepth   int       // nesting depth of function calls in a variable
+	inExpr      bool      // scanning an expression in a variable
 }
 
 // next returns the next rune in the input.
@@ -164,15 +188,19 @@ func (l *lexer) tokenPos() Pos {
 
 // emit passes a token back to the client.
 func (l *lexer) emit(t tokenType) {
-	l.tokens <- token{
-		typ: t,
-		pos: l.tokenPos(),
-		val: string(l.input[l.start:l.pos]),
+	var val []byte
+	if l.pos > l.start {
+		// Limit the capacity so the input can never be modified through val
+		val = l.input[l.start:l.pos:l.pos]
 	}
+	l.tokens = append(l.tokens, token{typ: t, pos: l.tokenPos(), val: val})
 	l.start = l.pos
 	l.startLine = l.line
 }
 
+// automaticCommaVal is the value of automatic comma tokens.
+var automaticCommaVal = []byte("automatic ,")
+
 // emitAutomaticComma performs automatic comma insertion by emitting a comma token.
 // Unlike emit, it will not update start and startLine.
 // If insertComma is false, this method will no-op.
@@ -181,12 +209,12 @@ func (l *lexer) emitAutomaticComma() {
 		return
 	}
 	l.insertComma = false
-	l.tokens <- token{
+	l.tokens = append(l.tokens, token{
 		typ: tokenComma,
 		pos: l.tokenPos(),
 		// Make debugging easier by highlighting that this is an automatic comma.
-		val: "automatic ,",
-	}
+		val: automaticCommaVal,
+	})
 }
 
 // ignore skips over the pending input before this point.
@@ -214,46 +242,49 @@ func (l *lexer) acceptRun(validSet string) {
 
 // errorf returns an error token and terminates the scan by passing back
 // a nil pointer that will be the next state, terminating l.nextToken.
+// If l.tolerant is set, scanning resumes on the next line instead.
 func (l *lexer) errorf(format string, args ...interface{}) stateFn {
-	l.tokens <- token{
+	l.tokens = append(l.tokens, token{
 		typ: tokenError,
 		pos: l.tokenPos(),
-		val: fmt.Sprintf(format, args...),
+		val: []byte(fmt.Sprintf(format, args...)),
+	})
+	if l.tolerant {
+		return lexSkipLine
 	}
 	return nil
 }
 
 // nextToken returns the next token from the input.
-// Called by the parser, not in the lexing goroutine.
+// It runs the state machine until at least one token has been emitted.
+// Once the scan has terminated, nextToken will continue to return
+// EOF tokens.
 func (l *lexer) nextToken() token {
-	return <-l.tokens
-}
-
-// drain drains the output so the lexing goroutine will exit.
-// Called by the parser, not in the lexing goroutine.
-func (l *lexer) drain() {
-	for range l.tokens {
-	}
-}
-
-// run runs the state machine for the lexer.
-func (l *lexer) run() {
-	for state := lexText; state != nil; {
-		state = state(l)
+	if l.head == len(l.tokens) {
+		// All pending tokens have been consumed, reuse the buffer.
+		l.tokens = l.tokens[:0]
+		l.head = 0
+		for len(l.tokens) == 0 {
+			if l.state == nil {
+				return token{typ: tokenEOF, pos: l.tokenPos()}
+			}
+			l.state = l.state(l)
+		}
 	}
-	close(l.tokens)
+	tok := l.tokens[l.head]
+	l.head++
+	return tok
 }
 
 // lex creates a new scanner for the input text.
 func lex(input []byte) *lexer {
-	l := &lexer{
+	return &lexer{
 		input:     input,
-		tokens:    make(chan token),
+		state:     lexText,
+		tokens:    make([]token, 0, 2),
 		line:      1,
 		startLine: 1,
 	}
-	go l.run()
-	return l
 }
 
 // state functions
@@ -282,6 +313,10 @@ func lexText(l *lexer) stateFn {
 		l.emit(tokenComma)
 		// Explicit comma provided
 		l.insertComma = false
+	case r == '+':
+		l.emit(tokenPlus)
+		// The concatenation continues on the next line
+		l.insertComma = false
 	case r == '[':
 		l.emit(tokenLeftSquareParen)
 	case r == ']':
@@ -293,11 +328,18 @@ func lexText(l *lexer) stateFn {
 		l.emit(tokenRightCurlyParen)
 		l.insertComma = true
 	case r == '"':
+		if bytes.HasPrefix(l.input[l.pos:], []byte(`""`)) {
+			return lexMultilineString
+		}
 		return lexQuote
 	case r == '`':
 		return lexRawQuote
 	case r == '$':
 		return lexVariable
+	case r == '&':
+		return lexAnchor
+	case r == '*':
+		return lexAlias
 	case r == '-' || ('0' <= r && r <= '9'):
 		l.backup()
 		return lexNumber
@@ -310,6 +352,34 @@ func lexText(l *lexer) stateFn {
 	return lexText
 }
 
+// lexSkipLine skips the rest of the current line after an error
+// so that scanning can resume.
+func lexSkipLine(l *lexer) stateFn {
+	if l.pos > 0 && l.input[l.pos-1] == '\n' {
+		// The error was caused by the end of the line, ex: an unterminated string,
+		// so the rest of the line has already been skipped
+		l.backup()
+	}
+	for {
+		r := l.next()
+		if r == eof {
+			break
+		}
+		if isEndOfLine(r) {
+			l.backup()
+			break
+		}
+	}
+	// Lines have already been counted by next
+	l.start = l.pos
+	l.startLine = l.line
+	l.mode = lexerModeNormal
+	l.inExpr = false
+	// Terminate the element that contained the error
+	l.insertComma = true
+	return lexText
+}
+
 // lexSpace scans and ignores a sequence of whitespace or newlines.
 func lexSpace(l *lexer) stateFn {
 	for {
@@ -461,6 +531,34 @@ Loop:
 	return lexText
 }
 
+// lexMultilineString scans a triple quoted multi-line string.
+// The first quote has already been scanned.
+func lexMultilineString(l *lexer) stateFn {
+	const quotes = `"""`
+	l.pos += len(quotes) - 1
+	// Nothing but whitespace can follow the opening quotes
+	i := bytes.IndexByte(l.input[l.pos:], '\n')
+	if i < 0 || len(bytes.Trim(l.input[l.pos:l.pos+i], " \t\r")) > 0 {
+		return l.errorf("multi-line string must start on the line after the opening quotes")
+	}
+	contentStart := l.pos + i + 1
+	end := bytes.Index(l.input[contentStart:], []byte(quotes))
+	if end < 0 {
+		return l.errorf("unterminated multi-line string")
+	}
+	end += contentStart
+	// The closing quotes must be on their own line so that their indentation is known
+	lineStart := bytes.LastIndexByte(l.input[:end], '\n') + 1
+	if len(bytes.Trim(l.input[lineStart:end], " \t")) > 0 {
+		return l.errorf("closing quotes of multi-line string must be on their own line")
+	}
+	l.pos = end + len(quotes)
+	l.line += bytes.Count(l.input[l.start:l.pos], []byte{'\n'})
+	l.emit(tokenMultilineString)
+	l.insertComma = true
+	return lexText
+}
+
 // lexNumber scans a number: int or float.
 func lexNumber(l *lexer) stateFn {
 	// Optional negative sign
@@ -487,11 +585,42 @@ func lexNumber(l *lexer) stateFn {
 	return lexText
 }
 
+// lexAnchor scans an anchor, ex: &name. The & has already been consumed.
+func lexAnchor(l *lexer) stateFn {
+	if !l.scanAnchorName() {
+		return l.errorf("expected name after '&'")
+	}
+	l.emit(tokenAnchor)
+	// The anchored value may be on the next line
+	l.insertComma = false
+	return lexText
+}
+
+// lexAlias scans an alias, ex: *name. The * has already been consumed.
+func lexAlias(l *lexer) stateFn {
+	if !l.scanAnchorName() {
+		return l.errorf("expected name after '*'")
+	}
+	l.emit(tokenAlias)
+	l.insertComma = true
+	return lexText
+}
+
+// scanAnchorName consumes the alphanumeric name of an anchor or alias.
+// It reports whether the name was non-empty.
+func (l *lexer) scanAnchorName() bool {
+	start := l.pos
+	for isAlphaNumeric(l.peek()) {
+		l.next()
+	}
+	return l.pos > start
+}
+
 // lexIdentifier scans an alphanumeric identifier.
 func lexIdentifier(l *lexer) stateFn {
 	for {
 		r := l.next()
-		if isAlphaNumeric(r) {
+		if isAlphaNumeric(r) || (r == '.' && l.dottedKeys) {
 			// consume and keep going
 			continue
 		}
@@ -528,6 +657,7 @@ func lexVariable(l *lexer) stateFn {
 		return l.errorf("bad character %#U after '$', expected '{'", r)
 	}
 	l.emit(tokenVariableStart)
+	l.callDepth = 0
 	// return lexFieldOrVariable(l, tokenIdentifier)
 	// If at terminator this is a lexical error
 	// We must have a name after a variable or field
@@ -535,26 +665,187 @@ func lexVariable(l *lexer) stateFn {
 		return l.errorf("variable name missing after '${'")
 	}
 
+	if l.peek() == '(' {
+		// Expression that starts with a group, ex: ${(a ?? b) == "x"}
+		return lexExpression
+	}
+
 	// Scan variable name
 	// First rune in the variable name must be a letter
-	r := l.next()
-	if r != '_' && !unicode.IsLetter(r) {
+	if r := l.next(); r != '_' && !unicode.IsLetter(r) {
 		return l.errorf("bad character %#U after '${'", r)
 	}
+	if next := l.scanVariableName(); next != nil {
+		return next
+	}
+	if l.peek() == '(' {
+		// Function call, ex: ${upper(name)}
+		l.emit(tokenIdentifier)
+		return lexArguments
+	}
+	if !l.atTerminator() && !l.atOperator() {
+		return l.errorf("bad character %#U", l.peek())
+	}
+	l.emit(tokenIdentifier)
+	return lexVariableEnd
+}
+
+// scanVariableName scans the rest of a variable name after the first rune.
+// It returns a non-nil state if the name is invalid.
+func (l *lexer) scanVariableName() stateFn {
 	for {
-		r = l.next()
+		r := l.next()
+		if r == '.' {
+			// Variable path, the next component must also start with a letter
+			r = l.next()
+			if r != '_' && !unicode.IsLetter(r) {
+				return l.errorf("bad character %#U after '.' in variable name", r)
+			}
+			continue
+		}
 		if !isAlphaNumeric(r) {
 			l.backup()
-			break
+			return nil
 		}
 	}
-	if !l.atTerminator() {
-		return l.errorf("bad character %#U", r)
+}
+
+// lexVariableEnd scans what follows the name or function call of a variable,
+// either an optional default value or the rest of an expression.
+func lexVariableEnd(l *lexer) stateFn {
+	if l.inExpr || l.atOperator() {
+		return lexExpression
+	}
+	if bytes.HasPrefix(l.input[l.pos:], []byte(":-")) {
+		return lexDefault
 	}
-	l.emit(tokenIdentifier)
 	return lexText
 }
 
+// lexArguments scans the arguments of a function call in a variable, ex: ${join(a, ", ")}.
+// Each argument is either a double quoted string, a variable name, or another function call.
+func lexArguments(l *lexer) stateFn {
+	for {
+		switch r := l.next(); {
+		case r == ' ' || r == '\t':
+			l.ignore()
+		case r == '(':
+			l.emit(tokenLeftParen)
+			l.callDepth++
+		case r == ')':
+			l.emit(tokenRightParen)
+			l.callDepth--
+			if l.callDepth == 0 {
+				return lexVariableEnd
+			}
+		case r == ',':
+			l.emit(tokenComma)
+		case r == '"':
+			if !l.scanArgString() {
+				return l.errorf("unterminated string in function arguments")
+			}
+			l.emit(tokenArgString)
+		case r == '_' || unicode.IsLetter(r):
+			if next := l.scanVariableName(); next != nil {
+				return next
+			}
+			l.emit(tokenIdentifier)
+		case r == eof || r == '\n':
+			return l.errorf("unterminated function call in variable")
+		default:
+			return l.errorf("bad character %#U in function arguments", r)
+		}
+	}
+}
+
+// lexExpression scans the rest of an expression in a variable, ex: the ?? "us-east-1"
+// in ${region ?? "us-east-1"}. The expression ends at the closing }.
+func lexExpression(l *lexer) stateFn {
+	l.inExpr = true
+	for {
+		switch r := l.next(); {
+		case r == ' ' || r == '\t':
+			l.ignore()
+		case r == '}':
+			l.backup()
+			l.inExpr = false
+			return lexText
+		case r == '?':
+			l.accept("?")
+			l.emit(tokenOperator)
+		case r == '=' || r == '!':
+			if !l.accept("=") {
+				return l.errorf("bad character %#U in expression, expected '%c='", r, r)
+			}
+			l.emit(tokenOperator)
+		case r == ':':
+			l.emit(tokenColon)
+		case r == '(':
+			l.emit(tokenLeftParen)
+		case r == ')':
+			l.emit(tokenRightParen)
+		case r == '"':
+			if !l.scanArgString() {
+				return l.errorf("unterminated string in expression")
+			}
+			l.emit(tokenArgString)
+		case r == '_' || unicode.IsLetter(r):
+			if next := l.scanVariableName(); next != nil {
+				return next
+			}
+			l.emit(tokenIdentifier)
+			if l.peek() == '(' {
+				l.callDepth = 0
+				return lexArguments
+			}
+		case r == eof || r == '\n':
+			return l.errorf("unterminated expression in variable")
+		default:
+			return l.errorf("bad character %#U in expression", r)
+		}
+	}
+}
+
+// scanArgString consumes a double quoted string in a function call or expression.
+// The opening quote has already been consumed. It reports whether the string was terminated.
+func (l *lexer) scanArgString() bool {
+	for {
+		switch l.next() {
+		case '\\':
+			if r := l.next(); r != eof && r != '\n' {
+				break
+			}
+			fallthrough
+		case eof, '\n':
+			return false
+		case '"':
+			return true
+		}
+	}
+}
+
+// atOperator reports whether the input is at an expression operator, ignoring leading spaces.
+func (l *lexer) atOperator() bool {
+	rest := bytes.TrimLeft(l.input[l.pos:], " \t")
+	return len(rest) > 0 && (rest[0] == '?' || rest[0] == '=' || rest[0] == '!')
+}
+
+// lexDefault scans the default value of a variable. The value is
+// all text from the :- up to the closing }.
+func lexDefault(l *lexer) stateFn {
+	l.pos += len(":-")
+	for {
+		switch l.next() {
+		case '}':
+			l.backup()
+			l.emit(tokenDefault)
+			return lexText
+		case eof, '\n':
+			return l.errorf("unterminated variable default value")
+		}
+	}
+}
+
 // atTerminator reports whether the input is at a valid termination
 // character after an identifier.
 func (l *lexer) atTerminator() bool {
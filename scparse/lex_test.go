@@ -25,7 +25,10 @@ var (
 )
 
 func mkToken(typ tokenType, val string) token {
-	return token{typ: typ, val: val}
+	if val == "" {
+		return token{typ: typ}
+	}
+	return token{typ: typ, val: []byte(val)}
 }
 
 // collectTokens gathers the emitted tokens into a slice.
@@ -75,6 +78,56 @@ func TestLex(t *testing.T) {
 		{"raw string with newline", "`a multi\nline string`", []token{
 			mkToken(tokenRawString, "`a multi\nline string`"), tEOF,
 		}},
+		{"multi-line string", "\"\"\"  \n  a \"quoted\" `string`\n  \"\"\"", []token{
+			mkToken(tokenMultilineString, "\"\"\"  \n  a \"quoted\" `string`\n  \"\"\""), tEOF,
+		}},
+		{"empty string", `""`, []token{tQuote, tQuote, tEOF}},
+		{"anchor and alias", "&base { }, *base", []token{
+			mkToken(tokenAnchor, "&base"),
+			tLcurly,
+			tRcurly,
+			tComma,
+			mkToken(tokenAlias, "*base"),
+			tEOF,
+		}},
+		{"concatenation", "\"a\" +\n`b`", []token{
+			tQuote,
+			mkToken(tokenString, "a"),
+			tQuote,
+			mkToken(tokenPlus, "+"),
+			mkToken(tokenRawString, "`b`"),
+			tEOF,
+		}},
+		{"function call", `${join(env("HOME"), "/x")}`, []token{
+			tVarStart,
+			mkToken(tokenIdentifier, "join"),
+			mkToken(tokenLeftParen, "("),
+			mkToken(tokenIdentifier, "env"),
+			mkToken(tokenLeftParen, "("),
+			mkToken(tokenArgString, `"HOME"`),
+			mkToken(tokenRightParen, ")"),
+			tComma,
+			mkToken(tokenArgString, `"/x"`),
+			mkToken(tokenRightParen, ")"),
+			tRcurly,
+			tEOF,
+		}},
+		{"expression", `${env == "prod" ? a : (b ?? "c")}`, []token{
+			tVarStart,
+			mkToken(tokenIdentifier, "env"),
+			mkToken(tokenOperator, "=="),
+			mkToken(tokenArgString, `"prod"`),
+			mkToken(tokenOperator, "?"),
+			mkToken(tokenIdentifier, "a"),
+			tColon,
+			mkToken(tokenLeftParen, "("),
+			mkToken(tokenIdentifier, "b"),
+			mkToken(tokenOperator, "??"),
+			mkToken(tokenArgString, `"c"`),
+			mkToken(tokenRightParen, ")"),
+			tRcurly,
+			tEOF,
+		}},
 		{"parens", "{[]}", []token{tLcurly, tLsquare, tRsquare, tRcurly, tEOF}},
 		{"symbols", ":,", []token{tColon, tComma, tEOF}},
 		{"numbers", "24 -42 0000.1756 13.79 1E3 1.5e-3 7e+5", []token{
@@ -96,6 +149,28 @@ func TestLex(t *testing.T) {
 			tRcurly,
 			tEOF,
 		}},
+		{"variable paths", "${server.host} ${a._b.c1}", []token{
+			tVarStart,
+			mkToken(tokenIdentifier, "server.host"),
+			tRcurly,
+			tVarStart,
+			mkToken(tokenIdentifier, "a._b.c1"),
+			tRcurly,
+			tEOF,
+		}},
+		{"variable defaults", `${region:-us-east-1} "${port:-80}"`, []token{
+			tVarStart,
+			mkToken(tokenIdentifier, "region"),
+			mkToken(tokenDefault, ":-us-east-1"),
+			tRcurly,
+			tQuote,
+			tVarStart,
+			mkToken(tokenIdentifier, "port"),
+			mkToken(tokenDefault, ":-80"),
+			tRcurly,
+			tQuote,
+			tEOF,
+		}},
 		{"identifiers", "data _type foo123 a573bcd", []token{
 			mkToken(tokenIdentifier, "data"),
 			mkToken(tokenIdentifier, "_type"),
@@ -140,6 +215,17 @@ func TestLex(t *testing.T) {
 		{"invalid var name", "${1abc}", []token{
 			tVarStart, mkToken(tokenError, "bad character U+0031 '1' after '${'"),
 		}},
+		{"invalid var path", "${foo.1}", []token{
+			tVarStart, mkToken(tokenError, "bad character U+0031 '1' after '.' in variable name"),
+		}},
+		{"var path trailing dot", "${foo.}", []token{
+			tVarStart, mkToken(tokenError, "bad character U+007D '}' after '.' in variable name"),
+		}},
+		{"unterminated var default", "${foo:-bar", []token{
+			tVarStart,
+			mkToken(tokenIdentifier, "foo"),
+			mkToken(tokenError, "unterminated variable default value"),
+		}},
 		// more complex example
 		{"complex config", `{
 	foo: [true
@@ -188,45 +274,45 @@ func TestLex(t *testing.T) {
 func TestLexPos(t *testing.T) {
 	tests := []lexTest{
 		{"empty", "", []token{
-			{tokenEOF, Pos{1, 1, 0}, ""},
+			{tokenEOF, Pos{1, 1, 0}, nil},
 		}},
 		{"symbols", `[{}]:,`, []token{
-			{tokenLeftSquareParen, Pos{1, 1, 0}, "["},
-			{tokenLeftCurlyParen, Pos{1, 2, 1}, "{"},
-			{tokenRightCurlyParen, Pos{1, 3, 2}, "}"},
-			{tokenRightSquareParen, Pos{1, 4, 3}, "]"},
-			{tokenColon, Pos{1, 5, 4}, ":"},
-			{tokenComma, Pos{1, 6, 5}, ","},
-			{tokenEOF, Pos{1, 7, 6}, ""},
+			{tokenLeftSquareParen, Pos{1, 1, 0}, []byte("[")},
+			{tokenLeftCurlyParen, Pos{1, 2, 1}, []byte("{")},
+			{tokenRightCurlyParen, Pos{1, 3, 2}, []byte("}")},
+			{tokenRightSquareParen, Pos{1, 4, 3}, []byte("]")},
+			{tokenColon, Pos{1, 5, 4}, []byte(":")},
+			{tokenComma, Pos{1, 6, 5}, []byte(",")},
+			{tokenEOF, Pos{1, 7, 6}, nil},
 		}},
 		{"multiline", "[true,\nfalse,\nnull\n]\n", []token{
-			{tokenLeftSquareParen, Pos{1, 1, 0}, "["},
-			{tokenBool, Pos{1, 2, 1}, "true"},
-			{tokenComma, Pos{1, 6, 5}, ","},
-			{tokenBool, Pos{2, 1, 7}, "false"},
-			{tokenComma, Pos{2, 6, 12}, ","},
-			{tokenNull, Pos{3, 1, 14}, "null"},
-			{tokenComma, Pos{3, 5, 18}, "automatic ,"},
-			{tokenRightSquareParen, Pos{4, 1, 19}, "]"},
-			{tokenComma, Pos{4, 2, 20}, "automatic ,"},
-			{tokenEOF, Pos{5, 1, 21}, ""},
+			{tokenLeftSquareParen, Pos{1, 1, 0}, []byte("[")},
+			{tokenBool, Pos{1, 2, 1}, []byte("true")},
+			{tokenComma, Pos{1, 6, 5}, []byte(",")},
+			{tokenBool, Pos{2, 1, 7}, []byte("false")},
+			{tokenComma, Pos{2, 6, 12}, []byte(",")},
+			{tokenNull, Pos{3, 1, 14}, []byte("null")},
+			{tokenComma, Pos{3, 5, 18}, []byte("automatic ,")},
+			{tokenRightSquareParen, Pos{4, 1, 19}, []byte("]")},
+			{tokenComma, Pos{4, 2, 20}, []byte("automatic ,")},
+			{tokenEOF, Pos{5, 1, 21}, nil},
 		}},
 		// check multi-byte runes
 		{"emojis", `"😂abc"` + "\n" + `"foo🚀"`, []token{
-			{tokenQuote, Pos{1, 1, 0}, `"`},
-			{tokenString, Pos{1, 2, 1}, "😂abc"},
-			{tokenQuote, Pos{1, 6, 8}, `"`},
-			{tokenComma, Pos{1, 7, 9}, "automatic ,"},
-			{tokenQuote, Pos{2, 1, 10}, `"`},
-			{tokenString, Pos{2, 2, 11}, "foo🚀"},
-			{tokenQuote, Pos{2, 6, 18}, `"`},
-			{tokenEOF, Pos{2, 7, 19}, ""},
+			{tokenQuote, Pos{1, 1, 0}, []byte(`"`)},
+			{tokenString, Pos{1, 2, 1}, []byte("😂abc")},
+			{tokenQuote, Pos{1, 6, 8}, []byte(`"`)},
+			{tokenComma, Pos{1, 7, 9}, []byte("automatic ,")},
+			{tokenQuote, Pos{2, 1, 10}, []byte(`"`)},
+			{tokenString, Pos{2, 2, 11}, []byte("foo🚀")},
+			{tokenQuote, Pos{2, 6, 18}, []byte(`"`)},
+			{tokenEOF, Pos{2, 7, 19}, nil},
 		}},
 		// check errors have correct position
 		{"error", ":\nnull @", []token{
-			{tokenColon, Pos{1, 1, 0}, ":"},
-			{tokenNull, Pos{2, 1, 2}, "null"},
-			{tokenError, Pos{2, 6, 7}, "unrecognized character scanned: U+0040 '@'"},
+			{tokenColon, Pos{1, 1, 0}, []byte(":")},
+			{tokenNull, Pos{2, 1, 2}, []byte("null")},
+			{tokenError, Pos{2, 6, 7}, []byte("unrecognized character scanned: U+0040 '@'")},
 		}},
 	}
 	for _, tt := range tests {
@@ -239,26 +325,30 @@ func TestLexPos(t *testing.T) {
 	}
 }
 
-// Test that an error shuts down the lexing goroutine.
-func TestErrorShutdown(t *testing.T) {
-	input := []byte(`{ foo: "`) // will cause lex error
-	lexer := lex(input)
-	_, err := parseLexer(input, lexer)
-	if err == nil {
-		t.Fatalf("expected error")
+// Test that the lexer keeps returning EOF once it has stopped scanning.
+func TestLexAfterEnd(t *testing.T) {
+	tests := []lexTest{
+		{"eof", "null", []token{tNull, tEOF, tEOF, tEOF}},
+		{"error", `{ foo: "`, []token{
+			tLcurly,
+			mkToken(tokenIdentifier, "foo"),
+			tColon,
+			tQuote,
+			mkToken(tokenError, "unterminated string"),
+			tEOF,
+			tEOF,
+		}},
 	}
-	// The error should have drained the input. Therefore, the lexer should be shut down.
-	token, ok := <-lexer.tokens
-	if ok {
-		t.Fatalf("lexer was not drained, got token %v", token)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := lex([]byte(tt.input))
+			tokens := make([]token, len(tt.tokens))
+			for i := range tokens {
+				tokens[i] = l.nextToken()
+			}
+			if ok, diff := deepEqual(tokens, tt.tokens, "pos"); !ok {
+				t.Errorf("tokens not equal:\n%s", diff)
+			}
+		})
 	}
 }
-
-// parseLexer is like Parse but it lets us pass in the lexer instead of building it.
-func parseLexer(input []byte, lex *lexer) (n *DictionaryNode, err error) {
-	p := &parser{lex: lex}
-	defer p.recover(&err)
-	n = p.parse()
-	p.lex = nil
-	return n, nil
-}
@@ -0,0 +1,141 @@
+// Copyright (c) 2021 the SC authors. All rights reserved. MIT License.
+
+package scparse
+
+import (
+	"bytes"
+	"sort"
+	"unicode/utf8"
+)
+
+// LineIndex converts between byte offsets and positions in source text.
+// It records the offset at which each line starts, so that the line containing
+// an offset can be found with a binary search.
+//
+// The columns of the positions are computed the same way as by Parse: they are
+// counted in unicode characters starting at 1.
+//
+// A LineIndex retains the source text it was created from, which must not be modified.
+type LineIndex struct {
+	src   []byte
+	lines []int // Byte offset of the start of each line.
+}
+
+// NewLineIndex creates a LineIndex for src.
+func NewLineIndex(src []byte) *LineIndex {
+	x := &LineIndex{src: src, lines: []int{0}}
+	x.lines = appendLineStarts(x.lines, src, 0)
+	return x
+}
+
+// appendLineStarts appends the offsets of the lines that start in b to lines.
+// off is the offset of b in the source text.
+func appendLineStarts(lines []int, b []byte, off int) []int {
+	for {
+		i := bytes.IndexByte(b, '\n')
+		if i == -1 {
+			return lines
+		}
+		off += i + 1
+		lines = append(lines, off)
+		b = b[i+1:]
+	}
+}
+
+// Source returns the source text of the index.
+func (x *LineIndex) Source() []byte {
+	return x.src
+}
+
+// LineCount returns the number of lines in the source text.
+// A source text that ends with a newline has an empty last line.
+func (x *LineIndex) LineCount() int {
+	return len(x.lines)
+}
+
+// LineStart returns the byte offset of the start of line.
+// Lines are counted starting at 1. line is clamped to the lines in the source text.
+func (x *LineIndex) LineStart(line int) int {
+	return x.lines[x.clampLine(line)-1]
+}
+
+func (x *LineIndex) clampLine(line int) int {
+	if line < 1 {
+		return 1
+	}
+	if line > len(x.lines) {
+		return len(x.lines)
+	}
+	return line
+}
+
+// Pos returns the position of the byte offset off. off is clamped to the source text,
+// so an offset after the end of the text returns the position at the end of the text.
+// If off is in the middle of a multi-byte character, the column is the column of that character.
+func (x *LineIndex) Pos(off int) Pos {
+	if off < 0 {
+		off = 0
+	} else if off > len(x.src) {
+		off = len(x.src)
+	}
+	// The index of the first line that starts after off
+	i := sort.SearchInts(x.lines, off+1)
+	start := x.lines[i-1]
+	return Pos{
+		Line:   i,
+		Column: utf8.RuneCount(x.src[start:off]) + 1,
+		Byte:   off,
+	}
+}
+
+// Offset returns the byte offset of the line and column of pos. pos.Byte is ignored.
+// The line is clamped to the lines in the source text and a column after the end of
+// the line is clamped to the end of the line, before the newline.
+func (x *LineIndex) Offset(pos Pos) int {
+	line := x.clampLine(pos.Line)
+	off := x.lines[line-1]
+	end := len(x.src)
+	if line < len(x.lines) {
+		// Exclude the newline
+		end = x.lines[line] - 1
+	}
+	for col := 1; col < pos.Column && off < end; col++ {
+		_, w := utf8.DecodeRune(x.src[off:end])
+		off += w
+	}
+	return off
+}
+
+// Apply updates the index to reflect edit and returns the edited source text, which
+// becomes the source text of the index. Only the lines after the start of the edit
+// are recomputed. It panics if the range of edit is not within the source text.
+//
+// Positions that were computed before the edit can be updated with Pos(MapOffset(edit, pos.Byte)).
+func (x *LineIndex) Apply(edit Edit) []byte {
+	src := edit.Apply(x.src)
+	// The lines that start after the end of the edit only move
+	i := sort.SearchInts(x.lines, edit.Start+1)
+	j := sort.SearchInts(x.lines, edit.End+1)
+	after := append([]int(nil), x.lines[j:]...)
+	lines := appendLineStarts(x.lines[:i], []byte(edit.Text), edit.Start)
+	delta := len(edit.Text) - (edit.End - edit.Start)
+	for _, off := range after {
+		lines = append(lines, off+delta)
+	}
+	x.src, x.lines = src, lines
+	return src
+}
+
+// MapOffset returns the offset in the edited source text that corresponds to the offset
+// off in the source text before edit was applied. Offsets before the edit are unchanged,
+// offsets after the edit, including the offset of an insertion, are moved by the change
+// in size, and offsets inside the replaced text are mapped to the end of the replacement.
+func MapOffset(edit Edit, off int) int {
+	switch {
+	case off < edit.Start:
+		return off
+	case off < edit.End:
+		return edit.Start + len(edit.Text)
+	}
+	return off + len(edit.Text) - (edit.End - edit.Start)
+}
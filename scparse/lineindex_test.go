@@ -0,0 +1,118 @@
+// Copyright (c) 2021 the SC authors. All rights reserved. MIT License.
+
+package scparse
+
+import (
+	"testing"
+)
+
+const lineIndexInput = "{\n  a: \"héllo\"\n\n  b: [1, 2] // ✓\n}\n"
+
+func TestLineIndex(t *testing.T) {
+	input := []byte(lineIndexInput)
+	x := NewLineIndex(input)
+	if got := x.LineCount(); got != 6 {
+		t.Errorf("got %d lines, want 6", got)
+	}
+	for off := 0; off <= len(input); off++ {
+		want := lineColumn(input, off)
+		if off < len(input) && input[off]&0xC0 == 0x80 {
+			// In the middle of a character
+			continue
+		}
+		got := x.Pos(off)
+		if got != want {
+			t.Errorf("Pos(%d): got %+v, want %+v", off, got, want)
+		}
+		if o := x.Offset(got); o != off {
+			t.Errorf("Offset(%+v): got %d, want %d", got, o, off)
+		}
+	}
+	// Tokens have the same positions as those computed by the lexer
+	for _, tok := range Tokenize(input) {
+		if got := x.Pos(tok.Pos.Byte); got != tok.Pos {
+			t.Errorf("token %s: got position %+v", tok, got)
+		}
+	}
+}
+
+func TestLineIndexClamp(t *testing.T) {
+	x := NewLineIndex([]byte(lineIndexInput))
+	tests := []struct {
+		pos  Pos
+		want int
+	}{
+		{Pos{Line: 0, Column: 1}, 0},
+		{Pos{Line: 1, Column: 10}, 1},
+		{Pos{Line: 2, Column: 100}, 15},
+		{Pos{Line: 3, Column: 5}, 16},
+		{Pos{Line: 100, Column: 1}, len(lineIndexInput)},
+	}
+	for _, tt := range tests {
+		if got := x.Offset(tt.pos); got != tt.want {
+			t.Errorf("Offset(%d:%d): got %d, want %d", tt.pos.Line, tt.pos.Column, got, tt.want)
+		}
+	}
+	if got, want := x.Pos(-1), (Pos{Line: 1, Column: 1}); got != want {
+		t.Errorf("Pos(-1): got %+v, want %+v", got, want)
+	}
+	if got, want := x.Pos(1000), lineColumn([]byte(lineIndexInput), len(lineIndexInput)); got != want {
+		t.Errorf("Pos(1000): got %+v, want %+v", got, want)
+	}
+	if got := x.LineStart(4); got != 17 {
+		t.Errorf("LineStart(4): got %d, want 17", got)
+	}
+}
+
+func TestLineIndexApply(t *testing.T) {
+	tests := []struct {
+		name string
+		edit Edit
+	}{
+		{"insert", Edit{Start: 3, End: 3, Text: "x"}},
+		{"insert lines", Edit{Start: 17, End: 17, Text: "c: 1\n  d: 2\n  "}},
+		{"delete lines", Edit{Start: 1, End: 17}},
+		{"replace newline", Edit{Start: 15, End: 16, Text: " "}},
+		{"replace lines", Edit{Start: 4, End: 30, Text: "\n\n"}},
+		{"at end", Edit{Start: len(lineIndexInput), End: len(lineIndexInput), Text: "\n// end"}},
+		{"everything", Edit{Start: 0, End: len(lineIndexInput), Text: "{}"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			x := NewLineIndex([]byte(lineIndexInput))
+			src := x.Apply(tt.edit)
+			if string(x.Source()) != string(src) {
+				t.Fatalf("Source does not return the edited source")
+			}
+			want := NewLineIndex(tt.edit.Apply([]byte(lineIndexInput)))
+			if string(src) != string(want.src) {
+				t.Fatalf("got source %q, want %q", src, want.src)
+			}
+			if len(x.lines) != len(want.lines) {
+				t.Fatalf("got line starts %v, want %v", x.lines, want.lines)
+			}
+			for i := range x.lines {
+				if x.lines[i] != want.lines[i] {
+					t.Fatalf("got line starts %v, want %v", x.lines, want.lines)
+				}
+			}
+		})
+	}
+}
+
+func TestMapOffset(t *testing.T) {
+	edit := Edit{Start: 5, End: 8, Text: "ab"}
+	tests := []struct{ off, want int }{
+		{0, 0},
+		{4, 4},
+		{5, 7},
+		{7, 7},
+		{8, 7},
+		{10, 9},
+	}
+	for _, tt := range tests {
+		if got := MapOffset(edit, tt.off); got != tt.want {
+			t.Errorf("MapOffset(%d): got %d, want %d", tt.off, got, tt.want)
+		}
+	}
+}
@@ -48,6 +48,10 @@ type CommentGroup struct {
 	// Only used for comments after the last element of a list or dictionary.
 	Foot  []Comment
 	Inner []Comment // Comments inside an empty list or dictionary.
+
+	// BlankLinesBefore is the number of blank lines before the node and its head comments.
+	// Only used for dictionary members and list elements other than the first.
+	BlankLinesBefore int
 }
 
 // NodeType identifies the type of an AST node.
@@ -66,6 +70,10 @@ func (nt NodeType) String() string {
 		"List",
 		"Member",
 		"Dictionary",
+		"MultilineString",
+		"Anchor",
+		"Alias",
+		"Expression",
 		"end",
 	}[nt]
 }
@@ -82,6 +90,10 @@ const (
 	NodeList
 	NodeMember
 	NodeDictionary
+	NodeMultilineString
+	NodeAnchor
+	NodeAlias
+	NodeExpression
 	nodeEnd
 )
 
@@ -180,20 +192,26 @@ type NumberNode struct {
 func newNumber(pos Pos, raw string) (*NumberNode, error) {
 	n := &NumberNode{Pos: pos, Raw: raw}
 
-	// Check if it is an int first
-	u, err := strconv.ParseUint(raw, 10, 64)
-	if err == nil {
-		n.IsUint = true
-		n.Uint64 = u
-	}
-	i, err := strconv.ParseInt(raw, 10, 64)
-	if err == nil {
-		n.IsInt = true
-		n.Int64 = i
-		if i == 0 {
-			// ParseUint fails for -0, fix it here
+	// Check if it is an int first. Skip the parse functions that are
+	// certain to fail, since failures allocate an error.
+	maybeInt := !strings.ContainsAny(raw, ".eE")
+	if maybeInt && !strings.HasPrefix(raw, "-") {
+		u, err := strconv.ParseUint(raw, 10, 64)
+		if err == nil {
 			n.IsUint = true
-			n.Uint64 = 0
+			n.Uint64 = u
+		}
+	}
+	if maybeInt {
+		i, err := strconv.ParseInt(raw, 10, 64)
+		if err == nil {
+			n.IsInt = true
+			n.Int64 = i
+			if i == 0 {
+				// ParseUint fails for -0, fix it here
+				n.IsUint = true
+				n.Uint64 = 0
+			}
 		}
 	}
 
@@ -205,7 +223,8 @@ func newNumber(pos Pos, raw string) (*NumberNode, error) {
 		f, err := strconv.ParseFloat(raw, 64)
 		if err == nil {
 			// If it looks like an int, it's too large of a number
-			if !strings.ContainsAny(raw, ".eE") {
+			// unless it is a valid uint
+			if !n.IsUint && !strings.ContainsAny(raw, ".eE") {
 				return nil, fmt.Errorf("integer overflow: %q", raw)
 			}
 			n.IsFloat = true
@@ -261,6 +280,7 @@ type InterpolatedStringNode struct {
 	Pos          Pos
 	CommentGroup CommentGroup
 	Components   []StringContentNode // Each component is either a StringNode or VariableNode.
+	End          Pos                 // Position of the closing quote.
 }
 
 func (n *InterpolatedStringNode) String() string {
@@ -299,6 +319,32 @@ func (n *RawStringNode) writeTo(sb *strings.Builder) {
 	sb.WriteString(n.String())
 }
 
+// MultilineStringNode holds a triple quoted string that spans multiple lines, ex:
+//
+//	script: """
+//	  #!/bin/sh
+//	  echo "hello"
+//	  """
+//
+// The content starts on the line after the opening quotes and ends at the end of the line
+// before the closing quotes, which must be on their own line. The indentation of the closing
+// quotes is the common indentation of the content and is removed from each line.
+// Escape sequences and variables are not interpreted.
+type MultilineStringNode struct {
+	Pos          Pos
+	CommentGroup CommentGroup
+	Value        string // The string value, after quotes and indentation have been removed.
+	End          Pos    // Position of the closing quotes.
+}
+
+func (n *MultilineStringNode) String() string {
+	return `"""` + "\n" + n.Value + "\n" + `"""`
+}
+
+func (n *MultilineStringNode) writeTo(sb *strings.Builder) {
+	sb.WriteString(n.String())
+}
+
 // IdentifierNode holds an identifier.
 type IdentifierNode struct {
 	Pos          Pos
@@ -315,10 +361,34 @@ func (n *IdentifierNode) writeTo(sb *strings.Builder) {
 }
 
 // VariableNode holds a variable.
+//
+// The variable name may be a path made up of multiple components
+// separated by dots, ex: ${server.host}.
+//
+// A variable may have a default value which is used if the variable
+// is unknown, ex: ${region:-us-east-1}. The default value is the text after
+// the :- up to the closing }.
+//
+// A variable may instead be a call to a function provided by the program,
+// ex: ${upper(name)}. The arguments of a call are either string literals or
+// variable names or calls without the surrounding ${ and }, ex: ${join(env("PATH"), ":")}.
+// The position of an argument is the position of its name.
 type VariableNode struct {
 	Pos          Pos
 	CommentGroup CommentGroup
-	Identifier   *IdentifierNode // The variable name.
+	Identifier   *IdentifierNode // The variable or function name.
+	Default      *StringNode     // The default value. It is nil if there is no default.
+	IsCall       bool            // Whether the variable is a function call.
+	// Args contains the arguments of a function call. Each argument
+	// is either a StringNode or a VariableNode.
+	Args   []StringContentNode
+	Rparen Pos // Position of the closing parenthesis of a function call.
+}
+
+// Path returns the components of the variable name.
+// If the variable name is not a path, it will contain a single component.
+func (n *VariableNode) Path() []string {
+	return strings.Split(n.Identifier.Name, ".")
 }
 
 func (n *VariableNode) String() string {
@@ -329,15 +399,190 @@ func (n *VariableNode) String() string {
 
 func (n *VariableNode) writeTo(sb *strings.Builder) {
 	sb.WriteString("${")
+	n.writeName(sb)
+	if n.Default != nil {
+		sb.WriteString(":-")
+		sb.WriteString(n.Default.Value)
+	}
+	sb.WriteByte('}')
+}
+
+// argEscaper escapes string literals in function call arguments.
+var argEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "\t", `\t`)
+
+// writeName writes the variable name or function call without the surrounding ${ and }.
+func (n *VariableNode) writeName(sb *strings.Builder) {
 	n.Identifier.writeTo(sb)
+	if !n.IsCall {
+		return
+	}
+	sb.WriteByte('(')
+	for i, a := range n.Args {
+		if i > 0 {
+			sb.WriteString(", ")
+		}
+		switch a := a.(type) {
+		case *StringNode:
+			sb.WriteByte('"')
+			argEscaper.WriteString(sb, a.Value)
+			sb.WriteByte('"')
+		case *VariableNode:
+			a.writeName(sb)
+		}
+	}
+	sb.WriteByte(')')
+}
+
+// Operators that can be used in an ExpressionNode.
+const (
+	OpCoalesce    = "??" // a ?? b
+	OpEqual       = "==" // a == b
+	OpNotEqual    = "!=" // a != b
+	OpConditional = "?:" // cond ? a : b
+)
+
+// ExpressionNode holds an expression in a variable, ex: ${region ?? "us-east-1"}.
+//
+// The supported operators, from lowest to highest precedence, are:
+//
+//	cond ? a : b  conditional, evaluates to a if cond is true, otherwise b
+//	a ?? b        coalescing, evaluates to a if it has a value, otherwise b
+//	a == b        equality, also a != b
+//
+// Each operand is either a string literal, a variable name or function call without
+// the surrounding ${ and }, or another expression. Parentheses can be used for grouping.
+// The position of a nested expression is the position of its first operand.
+//
+// See Eval for how expressions are evaluated.
+type ExpressionNode struct {
+	Pos          Pos
+	CommentGroup CommentGroup
+	Op           string // The operator, one of the Op constants.
+	// Operands contains the operands of the expression. Each operand is a StringNode,
+	// a VariableNode, or an ExpressionNode. Conditional expressions have three operands:
+	// the condition, the value if true, and the value if false. Other expressions have two.
+	Operands []StringContentNode
+	End      Pos // Position of the closing brace. It is the zero value for nested expressions.
+}
+
+func (n *ExpressionNode) String() string {
+	var sb strings.Builder
+	n.writeTo(&sb)
+	return sb.String()
+}
+
+func (n *ExpressionNode) writeTo(sb *strings.Builder) {
+	sb.WriteString("${")
+	n.writeExpr(sb)
 	sb.WriteByte('}')
 }
 
+// precedence returns the precedence of the operator of n. Higher binds tighter.
+func (n *ExpressionNode) precedence() int {
+	switch n.Op {
+	case OpConditional:
+		return 1
+	case OpCoalesce:
+		return 2
+	}
+	return 3
+}
+
+// writeExpr writes the expression without the surrounding ${ and }.
+func (n *ExpressionNode) writeExpr(sb *strings.Builder) {
+	prec := n.precedence()
+	switch n.Op {
+	case OpConditional:
+		writeOperand(sb, n.Operands[0], prec+1)
+		sb.WriteString(" ? ")
+		writeOperand(sb, n.Operands[1], prec)
+		sb.WriteString(" : ")
+		writeOperand(sb, n.Operands[2], prec)
+	case OpCoalesce:
+		writeOperand(sb, n.Operands[0], prec)
+		sb.WriteString(" ?? ")
+		writeOperand(sb, n.Operands[1], prec+1)
+	default:
+		writeOperand(sb, n.Operands[0], prec+1)
+		sb.WriteByte(' ')
+		sb.WriteString(n.Op)
+		sb.WriteByte(' ')
+		writeOperand(sb, n.Operands[1], prec+1)
+	}
+}
+
+// writeOperand writes the operand n of an expression. Expressions with a precedence lower
+// than prec are wrapped in parentheses.
+func writeOperand(sb *strings.Builder, n StringContentNode, prec int) {
+	switch n := n.(type) {
+	case *StringNode:
+		sb.WriteByte('"')
+		argEscaper.WriteString(sb, n.Value)
+		sb.WriteByte('"')
+	case *VariableNode:
+		n.writeName(sb)
+	case *ExpressionNode:
+		if n.precedence() < prec {
+			sb.WriteByte('(')
+			n.writeExpr(sb)
+			sb.WriteByte(')')
+			break
+		}
+		n.writeExpr(sb)
+	}
+}
+
+// AnchorNode holds a value that is given a name so that it can be
+// referenced elsewhere in the document using an AliasNode, ex:
+//
+//	defaults: &resources { cpu: 2, memory: 512 }
+//	worker: *resources
+//
+// Use ExpandAnchors to replace anchors and aliases with the values they refer to.
+type AnchorNode struct {
+	Pos          Pos
+	CommentGroup CommentGroup
+	Name         *IdentifierNode // The anchor name.
+	Value        ValueNode       // The anchored value.
+}
+
+func (n *AnchorNode) String() string {
+	var sb strings.Builder
+	n.writeTo(&sb)
+	return sb.String()
+}
+
+func (n *AnchorNode) writeTo(sb *strings.Builder) {
+	sb.WriteByte('&')
+	n.Name.writeTo(sb)
+	sb.WriteByte(' ')
+	n.Value.writeTo(sb)
+}
+
+// AliasNode holds a reference to the value of an AnchorNode, ex: *resources.
+type AliasNode struct {
+	Pos          Pos
+	CommentGroup CommentGroup
+	Name         *IdentifierNode // The name of the referenced anchor.
+}
+
+func (n *AliasNode) String() string {
+	var sb strings.Builder
+	n.writeTo(&sb)
+	return sb.String()
+}
+
+func (n *AliasNode) writeTo(sb *strings.Builder) {
+	sb.WriteByte('*')
+	n.Name.writeTo(sb)
+}
+
 // ListNode holds a list that contains a sequence of nodes.
 type ListNode struct {
 	Pos          Pos
 	CommentGroup CommentGroup
 	Elements     []ValueNode // The elements in the order they were scanned.
+	End          Pos         // Position of the closing bracket.
 }
 
 func (n *ListNode) String() string {
@@ -383,6 +628,9 @@ type DictionaryNode struct {
 	Pos          Pos
 	CommentGroup CommentGroup
 	Members      []*MemberNode // The members in the order they were scanned.
+	End          Pos           // Position of the closing brace.
+
+	src *source // The original source if the document was parsed with WithPreserveSource.
 }
 
 func (n *DictionaryNode) String() string {
@@ -402,6 +650,75 @@ func (n *DictionaryNode) writeTo(sb *strings.Builder) {
 	sb.WriteByte('}')
 }
 
+// Member returns the member with the given key or nil if there is no such member.
+// Identifier, string, and raw string keys are all matched by their string value.
+// If the key occurs more than once, the last member is returned since it takes
+// precedence when decoding.
+func (n *DictionaryNode) Member(key string) *MemberNode {
+	for i := len(n.Members) - 1; i >= 0; i-- {
+		if m := n.Members[i]; m.Key.KeyString() == key {
+			return m
+		}
+	}
+	return nil
+}
+
+// Get returns the value of the member with the given key or nil
+// if there is no such member. See Member for details on how keys are matched.
+func (n *DictionaryNode) Get(key string) ValueNode {
+	if m := n.Member(key); m != nil {
+		return m.Value
+	}
+	return nil
+}
+
+// GetPath returns the value at the dot separated path of keys or nil if there is no
+// such value, ex: "server.tls.port". Each key except the last must refer to a dictionary.
+// Keys that contain dots cannot be accessed using GetPath.
+func (n *DictionaryNode) GetPath(path string) ValueNode {
+	keys := strings.Split(path, ".")
+	d := n
+	for _, k := range keys[:len(keys)-1] {
+		var ok bool
+		if d, ok = d.Get(k).(*DictionaryNode); !ok {
+			return nil
+		}
+	}
+	return d.Get(keys[len(keys)-1])
+}
+
+// Has reports whether n has a member with the given key.
+func (n *DictionaryNode) Has(key string) bool {
+	return n.Member(key) != nil
+}
+
+// Set sets the value of the member with the given key to value.
+// If there is no such member, a new member is added to the end of the dictionary.
+// The comments of an existing member are kept.
+func (n *DictionaryNode) Set(key string, value ValueNode) {
+	if m := n.Member(key); m != nil {
+		m.Value = value
+		return
+	}
+	n.Members = append(n.Members, NewMember(key, value))
+}
+
+// Delete removes all members with the given key. It reports whether any members were removed.
+func (n *DictionaryNode) Delete(key string) bool {
+	members := n.Members[:0]
+	for _, m := range n.Members {
+		if m.Key.KeyString() != key {
+			members = append(members, m)
+		}
+	}
+	deleted := len(members) < len(n.Members)
+	for i := len(members); i < len(n.Members); i++ {
+		n.Members[i] = nil // allow removed members to be garbage collected
+	}
+	n.Members = members
+	return deleted
+}
+
 // endNode represents the end of a list or dictionary.
 // It only exists to aid parsing, it is not added to the AST.
 type endNode struct {
@@ -420,8 +737,12 @@ func (n *NumberNode) Type() NodeType             { return NodeNumber }
 func (n *StringNode) Type() NodeType             { return NodeString }
 func (n *InterpolatedStringNode) Type() NodeType { return NodeInterpolatedString }
 func (n *RawStringNode) Type() NodeType          { return NodeRawString }
+func (n *MultilineStringNode) Type() NodeType    { return NodeMultilineString }
 func (n *IdentifierNode) Type() NodeType         { return NodeIdentifier }
 func (n *VariableNode) Type() NodeType           { return NodeVariable }
+func (n *AnchorNode) Type() NodeType             { return NodeAnchor }
+func (n *AliasNode) Type() NodeType              { return NodeAlias }
+func (n *ExpressionNode) Type() NodeType         { return NodeExpression }
 func (n *ListNode) Type() NodeType               { return NodeList }
 func (n *MemberNode) Type() NodeType             { return NodeMember }
 func (n *DictionaryNode) Type() NodeType         { return NodeDictionary }
@@ -433,8 +754,12 @@ func (n *NumberNode) Position() Pos             { return n.Pos }
 func (n *StringNode) Position() Pos             { return n.Pos }
 func (n *InterpolatedStringNode) Position() Pos { return n.Pos }
 func (n *RawStringNode) Position() Pos          { return n.Pos }
+func (n *MultilineStringNode) Position() Pos    { return n.Pos }
 func (n *IdentifierNode) Position() Pos         { return n.Pos }
 func (n *VariableNode) Position() Pos           { return n.Pos }
+func (n *AnchorNode) Position() Pos             { return n.Pos }
+func (n *AliasNode) Position() Pos              { return n.Pos }
+func (n *ExpressionNode) Position() Pos         { return n.Pos }
 func (n *ListNode) Position() Pos               { return n.Pos }
 func (n *MemberNode) Position() Pos             { return n.Pos }
 func (n *DictionaryNode) Position() Pos         { return n.Pos }
@@ -446,8 +771,12 @@ func (n *NumberNode) Comments() *CommentGroup             { return &n.CommentGro
 func (n *StringNode) Comments() *CommentGroup             { return &n.CommentGroup }
 func (n *InterpolatedStringNode) Comments() *CommentGroup { return &n.CommentGroup }
 func (n *RawStringNode) Comments() *CommentGroup          { return &n.CommentGroup }
+func (n *MultilineStringNode) Comments() *CommentGroup    { return &n.CommentGroup }
 func (n *IdentifierNode) Comments() *CommentGroup         { return &n.CommentGroup }
 func (n *VariableNode) Comments() *CommentGroup           { return &n.CommentGroup }
+func (n *AnchorNode) Comments() *CommentGroup             { return &n.CommentGroup }
+func (n *AliasNode) Comments() *CommentGroup              { return &n.CommentGroup }
+func (n *ExpressionNode) Comments() *CommentGroup         { return &n.CommentGroup }
 func (n *ListNode) Comments() *CommentGroup               { return &n.CommentGroup }
 func (n *MemberNode) Comments() *CommentGroup             { return &n.CommentGroup }
 func (n *DictionaryNode) Comments() *CommentGroup         { return &n.CommentGroup }
@@ -467,7 +796,11 @@ func (*BoolNode) valueNode()               {}
 func (*NumberNode) valueNode()             {}
 func (*InterpolatedStringNode) valueNode() {}
 func (*RawStringNode) valueNode()          {}
+func (*MultilineStringNode) valueNode()    {}
 func (*VariableNode) valueNode()           {}
+func (*AnchorNode) valueNode()             {}
+func (*AliasNode) valueNode()              {}
+func (*ExpressionNode) valueNode()         {}
 func (*ListNode) valueNode()               {}
 func (*DictionaryNode) valueNode()         {}
 func (*endNode) valueNode()                {}
@@ -476,5 +809,6 @@ func (*endNode) valueNode()                {}
 // stringContentNode() ensures that only nodes that can appear inside
 // an interpolated string can be assigned to a StringContentNode.
 
-func (*StringNode) stringContentNode()   {}
-func (*VariableNode) stringContentNode() {}
+func (*StringNode) stringContentNode()     {}
+func (*VariableNode) stringContentNode()   {}
+func (*ExpressionNode) stringContentNode() {}
@@ -0,0 +1,48 @@
+// Copyright (c) 2021 the SC authors. All rights reserved. MIT License.
+
+package scparse
+
+import "testing"
+
+func TestDictionaryAccessors(t *testing.T) {
+	n, err := Parse([]byte(`{
+  name: "foo"
+  "content-type": "text/plain"
+  server: { tls: { port: 443 } }
+  name: "bar"
+}`))
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	if got := n.Get("name").String(); got != `"bar"` {
+		t.Errorf("got name %s, want the last occurrence", got)
+	}
+	if !n.Has("content-type") || n.Has("missing") || n.Get("missing") != nil {
+		t.Error("got wrong result for Has or Get")
+	}
+	if got := n.GetPath("server.tls.port"); got == nil || got.String() != "443" {
+		t.Errorf("got server.tls.port %v, want 443", got)
+	}
+	if got := n.GetPath("name.tls"); got != nil {
+		t.Errorf("got name.tls %v, want nil", got)
+	}
+
+	n.Set("content-type", NewString("application/json"))
+	n.Set("port", NewInt(8080))
+	if !n.Delete("name") || n.Delete("name") {
+		t.Error("got wrong result for Delete")
+	}
+	want := `{
+  "content-type": "application/json"
+  server: {
+    tls: {
+      port: 443
+    }
+  }
+  port: 8080
+}
+`
+	if got := string(Format(n)); got != want {
+		t.Errorf("got formatted SC\n%s\nwant\n%s", got, want)
+	}
+}
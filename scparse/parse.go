@@ -13,6 +13,7 @@
 package scparse
 
 import (
+	"bytes"
 	"fmt"
 	"strings"
 	"unicode"
@@ -27,29 +28,305 @@ type Error struct {
 	Pos Pos
 	// Context contains the details of the error.
 	Context string
+	// Filename is the name of the file that was parsed.
+	// It is empty unless a filename was provided using ParseFile or WithFilename.
+	Filename string
 }
 
 func (e *Error) Error() string {
-	return fmt.Sprintf("sc: Parse Error: %d:%d: %s", e.Pos.Line, e.Pos.Column, e.Context)
+	return fmt.Sprintf("sc: Parse Error: %s: %s", formatPos(e.Filename, e.Pos), e.Context)
+}
+
+// ErrorList is a list of errors that occurred during parsing.
+// It is returned by ParseTolerant which reports all syntax errors in a document.
+type ErrorList []*Error
+
+func (e ErrorList) Error() string {
+	var sb strings.Builder
+	for i, err := range e {
+		if i > 0 {
+			sb.WriteByte('\n')
+		}
+		sb.WriteString(err.Error())
+	}
+	return sb.String()
+}
+
+// DuplicateKeyError describes a key that appears more than once in the same dictionary.
+type DuplicateKeyError struct {
+	Key      string // The duplicated key.
+	Pos      Pos    // Position of the duplicate key.
+	PrevPos  Pos    // Position of the previous occurrence of the key.
+	Filename string // Name of the file that was parsed, if provided.
+}
+
+func (e *DuplicateKeyError) Error() string {
+	return fmt.Sprintf("sc: %s: duplicate key %q, previously defined at %d:%d", formatPos(e.Filename, e.Pos), e.Key, e.PrevPos.Line, e.PrevPos.Column)
+}
+
+// Limit identifies a resource limit that can be placed on the parser.
+type Limit int
+
+const (
+	LimitDepth          Limit = iota // Maximum nesting depth of lists and dictionaries.
+	LimitInputSize                   // Maximum size of the input in bytes.
+	LimitMembers                     // Maximum number of members in a dictionary or elements in a list.
+	LimitAliasExpansion              // Maximum number of values in the document once aliases are expanded.
+)
+
+func (l Limit) String() string {
+	return [...]string{
+		"depth",
+		"input size",
+		"members",
+		"alias expansion",
+	}[l]
+}
+
+// LimitError describes a resource limit that was exceeded while parsing.
+type LimitError struct {
+	Limit Limit // The limit that was exceeded.
+	Max   int   // The maximum value allowed for the limit.
+	// Pos is the position in the input where the limit was exceeded.
+	// It is the zero value if the limit applies to the entire input.
+	Pos Pos
+	// Filename is the name of the file that was parsed, if provided.
+	Filename string
+}
+
+func (e *LimitError) Error() string {
+	if e.Pos == (Pos{}) {
+		if e.Filename != "" {
+			return fmt.Sprintf("sc: %s: %s limit of %d exceeded", e.Filename, e.Limit, e.Max)
+		}
+		return fmt.Sprintf("sc: %s limit of %d exceeded", e.Limit, e.Max)
+	}
+	return fmt.Sprintf("sc: %s: %s limit of %d exceeded", formatPos(e.Filename, e.Pos), e.Limit, e.Max)
+}
+
+// formatPos formats pos as line:column, prefixed by filename if it is not empty.
+func formatPos(filename string, pos Pos) string {
+	if filename == "" {
+		return fmt.Sprintf("%d:%d", pos.Line, pos.Column)
+	}
+	return fmt.Sprintf("%s:%d:%d", filename, pos.Line, pos.Column)
 }
 
 // Parse parses the SC source and generates an AST.
 // If err is not nil, it will contain details on the error
 // encountered and it's location in input.
-func Parse(input []byte) (n *DictionaryNode, err error) {
-	p := &parser{lex: lex(input)}
+//
+// Parse can optionally be provided additional option arguments that modify the parsing process.
+// See the documentation for each ParseOption to learn more.
+func Parse(input []byte, opts ...ParseOption) (*DictionaryNode, error) {
+	return parse(input, false, opts)
+}
+
+// ParseTolerant is like Parse but it does not stop at the first syntax error.
+// Instead, when an error occurs, it skips to the next dictionary member or
+// list element and continues parsing. This allows all syntax errors in a document
+// to be reported at once, ex: by an editor or linter.
+// If the error is an invalid token, ex: an unrecognized character, the rest of
+// the line containing the token is skipped.
+//
+// ParseTolerant returns the partial AST, which contains all members and elements
+// that were parsed successfully. If any syntax errors occurred, err will be an
+// ErrorList containing all of them. n may be nil if the document is so malformed
+// that no top level dictionary could be parsed.
+//
+// Errors that are not syntax errors, such as a *LimitError or *DuplicateKeyError,
+// still stop parsing immediately and are returned as is with a nil AST.
+func ParseTolerant(input []byte, opts ...ParseOption) (n *DictionaryNode, err error) {
+	return parse(input, true, opts)
+}
+
+func parse(input []byte, tolerant bool, opts []ParseOption) (n *DictionaryNode, err error) {
+	p := &parser{lex: lex(input), tolerant: tolerant}
+	p.lex.tolerant = tolerant
+	for _, opt := range opts {
+		opt(p)
+	}
+	p.lex.dottedKeys = p.dottedKeys
+	if p.preserveSource {
+		p.src = &source{input: string(input), spans: make(map[Node]*sourceSpan), containers: make(map[Node]*sourceSpan)}
+	}
+	if p.maxInputSize > 0 && len(input) > p.maxInputSize {
+		return nil, &LimitError{Limit: LimitInputSize, Max: p.maxInputSize, Filename: p.filename}
+	}
 	defer p.recover(&err)
 	n = p.parse()
-	// Dispose of the lexer since we don't need it anymore
-	p.lex = nil
+	if p.maxAliasExpansion > 0 && n != nil && expandedSize(n, p.maxAliasExpansion) > p.maxAliasExpansion {
+		return nil, &LimitError{Limit: LimitAliasExpansion, Max: p.maxAliasExpansion, Filename: p.filename}
+	}
+	if p.src != nil && n != nil {
+		p.src.finish(n)
+	}
+	if len(p.errors) > 0 {
+		for _, e := range p.errors {
+			e.Filename = p.filename
+		}
+		return n, p.errors
+	}
 	return n, nil
 }
 
+// ParseFile is like Parse but it records filename as the name of the file
+// that input was read from. The filename is included in any errors that are returned,
+// ex: prod.sc:12:3. It is equivalent to calling Parse with WithFilename(filename).
+func ParseFile(filename string, input []byte, opts ...ParseOption) (*DictionaryNode, error) {
+	opts = append(opts[:len(opts):len(opts)], WithFilename(filename))
+	return Parse(input, opts...)
+}
+
+// ParseOption is an option that can be provided to Parse to customize
+// behaviour during the parsing process.
+//
+// The signature contains an unexported type so that only options defined in this
+// package are valid.
+type ParseOption func(*parser)
+
+// WithDisallowDuplicateKeys controls how Parse will behave when a dictionary
+// contains the same key more than once.
+//
+// By default, duplicate keys are allowed and all members are kept in the AST.
+// If set to true, a duplicate key will cause a *DuplicateKeyError to be returned.
+// This is the same as using WithDuplicateKeyPolicy(DuplicateKeysError).
+func WithDisallowDuplicateKeys(b bool) ParseOption {
+	return func(p *parser) {
+		if b {
+			p.duplicateKeys = DuplicateKeysError
+		} else {
+			p.duplicateKeys = DuplicateKeysLastWins
+		}
+	}
+}
+
+// WithDuplicateKeyPolicy sets how Parse handles a dictionary that
+// contains the same key more than once.
+//
+// By default, DuplicateKeysLastWins is used and all members are kept in the AST.
+// With any other policy, duplicate keys are resolved while parsing so that
+// each key occurs at most once in a dictionary. See ResolveDuplicateKeys for details.
+func WithDuplicateKeyPolicy(policy DuplicateKeyPolicy) ParseOption {
+	return func(p *parser) {
+		p.duplicateKeys = policy
+	}
+}
+
+// WithPreserveSource controls whether Parse retains the original source text
+// in the returned AST.
+//
+// By default, the source text is not retained and Format prints the AST in
+// the standard style. If set to true, Format will print the parts of the AST
+// that have not been modified exactly as they appeared in the source text,
+// including whitespace, comma placement, and comments. If the AST is not
+// modified at all, Format returns the original source text.
+// This allows tools to edit SC documents without changing unrelated lines.
+//
+// A dictionary member or list element is considered modified if it would
+// be formatted differently than when it was parsed. Modified members and elements
+// are printed at the indentation of the text they replace, keeping the whitespace,
+// commas, and comments around them. If the members or elements of a dictionary or
+// list are added, removed, or reordered, the dictionary or list is reprinted, with
+// its unmodified members or elements still printed as written. Unless they are set in
+// FormatOptions, reprinted parts use the indentation and line endings of the source text.
+func WithPreserveSource(b bool) ParseOption {
+	return func(p *parser) {
+		p.preserveSource = b
+	}
+}
+
+// WithFilename sets the name of the file that the input was read from.
+// The filename is included in any errors returned by Parse.
+// This allows tools that load multiple files to report which file an error occurred in.
+func WithFilename(name string) ParseOption {
+	return func(p *parser) {
+		p.filename = name
+	}
+}
+
+// WithMaxDepth sets the maximum nesting depth of lists and dictionaries.
+// The top level dictionary has a depth of 1.
+// If the limit is exceeded, a *LimitError will be returned.
+//
+// By default, there is no limit. A value <= 0 also means there is no limit.
+func WithMaxDepth(n int) ParseOption {
+	return func(p *parser) {
+		p.maxDepth = n
+	}
+}
+
+// WithMaxInputSize sets the maximum size of the input in bytes.
+// If the limit is exceeded, a *LimitError will be returned before parsing starts.
+//
+// By default, there is no limit. A value <= 0 also means there is no limit.
+func WithMaxInputSize(n int) ParseOption {
+	return func(p *parser) {
+		p.maxInputSize = n
+	}
+}
+
+// WithMaxMembers sets the maximum number of members in a single dictionary
+// or elements in a single list.
+// If the limit is exceeded, a *LimitError will be returned.
+//
+// By default, there is no limit. A value <= 0 also means there is no limit.
+func WithMaxMembers(n int) ParseOption {
+	return func(p *parser) {
+		p.maxMembers = n
+	}
+}
+
+// WithMaxAliasExpansion sets the maximum number of values in the document once its
+// anchors and aliases are expanded, see ExpandAnchors. Each value is counted once for
+// every alias that references it, so nested aliases that would expand to a huge number
+// of values, ex: a: &a [1, 1], b: &b [*a, *a], c: &c [*b, *b], and so on, are rejected.
+// If the limit is exceeded, a *LimitError will be returned.
+//
+// By default, there is no limit. A value <= 0 also means there is no limit.
+func WithMaxAliasExpansion(n int) ParseOption {
+	return func(p *parser) {
+		p.maxAliasExpansion = n
+	}
+}
+
+// WithDottedKeys controls whether dots in dictionary keys create nested dictionaries.
+//
+// By default, dots are not allowed in identifiers. If set to true, an identifier key
+// containing dots is expanded into nested dictionaries, ex: server.http.port: 8080
+// is parsed the same as server: { http: { port: 8080 } }.
+// Members with dotted keys in the same dictionary are merged into an existing
+// member with the same key if its value is a dictionary, ex: server.host and
+// server.port both become members of the server dictionary.
+// String keys are never expanded, so "server.port" is a single key.
+func WithDottedKeys(b bool) ParseOption {
+	return func(p *parser) {
+		p.dottedKeys = b
+	}
+}
+
 // parser handles parsing a SC document into an AST.
 type parser struct {
 	lex       *lexer
 	token     token // one token lookahead
 	hasPeeked bool
+	depth     int               // current nesting depth of lists and dictionaries
+	lastEnd   int               // byte offset of the end of the last consumed token
+	buf       []byte            // reusable buffer for building string values
+	names     map[string]string // interned identifier names
+	src       *source
+	dotted    map[*DictionaryNode]bool // dictionaries created from dotted keys
+	tolerant  bool                     // recover from syntax errors
+	errors    ErrorList                // syntax errors, only used if tolerant is set
+
+	filename          string
+	preserveSource    bool
+	dottedKeys        bool
+	duplicateKeys     DuplicateKeyPolicy
+	maxDepth          int
+	maxInputSize      int
+	maxMembers        int
+	maxAliasExpansion int
 }
 
 // next returns the next token.
@@ -59,9 +336,28 @@ func (p *parser) next() token {
 	} else {
 		p.token = p.lex.nextToken()
 	}
+	// Automatic commas do not appear in the input
+	if p.token.typ != tokenComma || string(p.token.val) == "," {
+		p.lastEnd = p.token.pos.Byte + len(p.token.val)
+	}
 	return p.token
 }
 
+// intern returns b as a string. Identifiers, such as dictionary keys, are often
+// repeated in a document so the same string is reused for equal identifiers.
+func (p *parser) intern(b []byte) string {
+	// The compiler optimizes the string conversion so this does not allocate
+	if s, ok := p.names[string(b)]; ok {
+		return s
+	}
+	if p.names == nil {
+		p.names = make(map[string]string)
+	}
+	s := string(b)
+	p.names[s] = s
+	return s
+}
+
 // peek returns but does not consume the next token.
 func (p *parser) peek() token {
 	if p.hasPeeked {
@@ -77,6 +373,162 @@ func (p *parser) errorf(format string, args ...interface{}) {
 	panic(&Error{Pos: p.token.pos, Context: fmt.Sprintf(format, args...)})
 }
 
+// enter increases the nesting depth, terminating processing if
+// the maximum depth has been exceeded.
+func (p *parser) enter(pos Pos) {
+	p.depth++
+	if p.maxDepth > 0 && p.depth > p.maxDepth {
+		panic(&LimitError{Limit: LimitDepth, Max: p.maxDepth, Pos: pos})
+	}
+}
+
+// leave decreases the nesting depth.
+func (p *parser) leave() {
+	p.depth--
+}
+
+// checkMembers terminates processing if count exceeds the maximum number of members.
+func (p *parser) checkMembers(count int, pos Pos) {
+	if p.maxMembers > 0 && count > p.maxMembers {
+		panic(&LimitError{Limit: LimitMembers, Max: p.maxMembers, Pos: pos})
+	}
+}
+
+// try calls f. If p.tolerant is set, syntax errors that occur in f are recorded
+// instead of terminating processing. try reports whether f completed without an error.
+func (p *parser) try(f func()) (ok bool) {
+	if !p.tolerant {
+		f()
+		return true
+	}
+	defer func() {
+		if ok {
+			return
+		}
+		r := recover()
+		e, isErr := r.(*Error)
+		if !isErr {
+			panic(r)
+		}
+		p.errors = append(p.errors, e)
+	}()
+	f()
+	return true
+}
+
+// skipElement skips the remaining tokens of a dictionary member or list element
+// after a syntax error. closer is the type of the token that ends the current
+// dictionary or list. skipElement reports whether parsing of the dictionary or
+// list should continue. It returns false if the end of the input or the end of
+// a parent dictionary or list was reached.
+func (p *parser) skipElement(closer tokenType) bool {
+	// The lexer may be in the middle of a string if that is where the error occurred
+	inString := p.lex.mode == lexerModeString
+	inVariable := false
+	depth := 0
+	// Start with the token that caused the error, unless it has not been consumed
+	tok := p.token
+	first := true
+	if p.hasPeeked {
+		tok = p.next()
+		first = false
+	}
+	for ; ; tok, first = p.next(), false {
+		switch tok.typ {
+		case tokenError:
+			if !first {
+				p.errors = append(p.errors, &Error{Pos: tok.pos, Context: string(tok.val)})
+			}
+			// The lexer resumes scanning on the next line
+			inString = false
+			inVariable = false
+			depth = 0
+		case tokenEOF:
+			p.hasPeeked = true
+			return false
+		case tokenQuote:
+			// The lexer mode already accounts for the first token
+			if !first {
+				inString = !inString
+			}
+		case tokenVariableStart:
+			inVariable = true
+		case tokenLeftCurlyParen, tokenLeftSquareParen:
+			if !inString {
+				depth++
+			}
+		case tokenRightCurlyParen, tokenRightSquareParen:
+			if inVariable && tok.typ == tokenRightCurlyParen {
+				inVariable = false
+				break
+			}
+			if inString {
+				break
+			}
+			if depth > 0 {
+				depth--
+				break
+			}
+			// End of the current dictionary or list, or one of its parents.
+			// Leave it for the caller to handle.
+			p.hasPeeked = true
+			return tok.typ == closer
+		case tokenComma:
+			if depth == 0 && !inString {
+				return true
+			}
+		}
+	}
+}
+
+// recordSpan records the source text of n, which is between the byte offsets start and end.
+// comma reports whether the text includes the comma after n.
+// It is a no-op if the source is not being preserved.
+func (p *parser) recordSpan(n Node, start, end int, comma bool) {
+	if p.src == nil {
+		return
+	}
+	// Line comments end at the newline, which may be "\r\n"
+	if end > start && p.src.input[end-1] == '\r' {
+		end--
+	}
+	p.src.spans[n] = &sourceSpan{start: start, end: end, comma: comma}
+}
+
+// isAutomaticComma reports whether tok is a comma inserted by the lexer at the end of a line.
+func isAutomaticComma(tok token) bool {
+	return bytes.Equal(tok.val, automaticCommaVal)
+}
+
+// recordContainer records the source text of the list or dictionary n,
+// which is between the byte offsets start and end, including the brackets.
+// It is a no-op if the source is not being preserved.
+func (p *parser) recordContainer(n ValueNode, start, end int) {
+	if p.src != nil {
+		p.src.containers[n] = &sourceSpan{start: start, end: end}
+	}
+}
+
+// blankLinesBefore returns the number of blank lines in the input
+// directly before pos.
+func (p *parser) blankLinesBefore(pos Pos) int {
+	newlines := 0
+Loop:
+	for i := pos.Byte - 1; i >= 0; i-- {
+		switch p.lex.input[i] {
+		case '\n':
+			newlines++
+		case ' ', '\t', '\r':
+		default:
+			break Loop
+		}
+	}
+	if newlines < 2 {
+		return 0
+	}
+	return newlines - 1
+}
+
 // unexpected complains about the token and terminates processing.
 func (p *parser) unexpected(tok token, context string) {
 	if tok.typ == tokenError {
@@ -100,26 +552,39 @@ func (p *parser) recover(errp *error) {
 	if r == nil {
 		return
 	}
-	// Make sure it's an Error otherwise it is something more serious that we
-	// can't handle (ex: runtime.Error)
-	e, ok := r.(*Error)
-	if !ok {
+	// Make sure it's an error from this package otherwise it is something
+	// more serious that we can't handle (ex: runtime.Error)
+	switch e := r.(type) {
+	case *Error:
+		e.Filename = p.filename
+		*errp = e
+	case *DuplicateKeyError:
+		e.Filename = p.filename
+		*errp = e
+	case *LimitError:
+		e.Filename = p.filename
+		*errp = e
+	default:
 		panic(r)
 	}
-	if p.lex != nil {
-		p.lex.drain()
-	}
-	*errp = e
 }
 
 // parse is the top level parser that parses the SC document.
 func (p *parser) parse() *DictionaryNode {
-	n := p.parseValue()
+	var n ValueNode
+	if !p.try(func() { n = p.parseValue() }) {
+		return nil
+	}
 	if n.Type() != NodeDictionary {
-		// Overwrite the pos of the token so that the error is reported
-		// at the start of the node, not at the end
-		p.token.pos = n.Position()
-		p.errorf("top level value in SC document must be a dictionary")
+		ok := p.try(func() {
+			// Overwrite the pos of the token so that the error is reported
+			// at the start of the node, not at the end
+			p.token.pos = n.Position()
+			p.errorf("top level value in SC document must be a dictionary")
+		})
+		if !ok {
+			return nil
+		}
 	}
 
 	// Handle any remaining tokens. At this point all we can have are comments,
@@ -148,7 +613,7 @@ Loop:
 			}
 			fallthrough
 		default:
-			p.unexpected(p.next(), "end of document")
+			p.try(func() { p.unexpected(p.next(), "end of document") })
 		}
 	}
 	return n.(*DictionaryNode)
@@ -174,8 +639,14 @@ func (p *parser) parseValue() ValueNode {
 		node = p.parseString()
 	case tokenRawString:
 		node = p.parseRawString()
+	case tokenMultilineString:
+		node = p.parseMultilineString()
 	case tokenVariableStart:
 		node = p.parseVariable()
+	case tokenAnchor:
+		node = p.parseAnchor()
+	case tokenAlias:
+		node = p.parseAlias()
 	case tokenLeftCurlyParen:
 		node = p.parseDictionary()
 	case tokenLeftSquareParen:
@@ -186,6 +657,9 @@ func (p *parser) parseValue() ValueNode {
 	default:
 		p.unexpected(p.next(), "value")
 	}
+	if p.peek().typ == tokenPlus {
+		node = p.parseConcatenation(node)
+	}
 
 	c := node.Comments()
 	c.Head = append(c.Head, headComments...)
@@ -194,19 +668,29 @@ func (p *parser) parseValue() ValueNode {
 	return node
 }
 
+// parseRequiredValue is like parseValue but the end of a list is not allowed.
+// context describes where the value is being parsed.
+func (p *parser) parseRequiredValue(context string) ValueNode {
+	val := p.parseValue()
+	if _, ok := val.(*endNode); ok {
+		panic(&Error{Pos: val.Position(), Context: "unexpected <]> in " + context + ", expected value"})
+	}
+	return val
+}
+
 // parseComment parses either a // or /* comment
 func (p *parser) parseComment() Comment {
 	tok := p.next()
-	var text string
+	var text []byte
 	isBlock := false
-	if strings.HasPrefix(tok.val, "//") {
-		text = strings.TrimPrefix(tok.val, "//")
+	if bytes.HasPrefix(tok.val, []byte("//")) {
+		text = bytes.TrimPrefix(tok.val, []byte("//"))
 	} else {
-		text = strings.TrimPrefix(tok.val, "/*")
-		text = strings.TrimSuffix(text, "*/")
+		text = bytes.TrimPrefix(tok.val, []byte("/*"))
+		text = bytes.TrimSuffix(text, []byte("*/"))
 		isBlock = true
 	}
-	return Comment{Pos: tok.pos, Text: text, IsBlock: isBlock}
+	return Comment{Pos: tok.pos, Text: string(text), IsBlock: isBlock}
 }
 
 // parseInlineComments parses all comments that are on line.
@@ -231,7 +715,7 @@ func (p *parser) parseBool() *BoolNode {
 	tok := p.next()
 	// We have a 50% chance of being right :)
 	val := false
-	if tok.val == "true" {
+	if string(tok.val) == "true" {
 		val = true
 	}
 	return &BoolNode{Pos: tok.pos, True: val}
@@ -239,7 +723,7 @@ func (p *parser) parseBool() *BoolNode {
 
 func (p *parser) parseNumber() *NumberNode {
 	tok := p.next()
-	n, err := newNumber(tok.pos, tok.val)
+	n, err := newNumber(tok.pos, string(tok.val))
 	if err != nil {
 		p.errorf("%s", err)
 	}
@@ -250,7 +734,7 @@ func (p *parser) parseNumber() *NumberNode {
 // It does not allow interpolated strings.
 func (p *parser) parseStringKey() *StringNode {
 	startTok := p.next()
-	var sb strings.Builder
+	buf := p.buf[:0]
 Loop:
 	for {
 		switch tok := p.next(); tok.typ {
@@ -258,7 +742,7 @@ Loop:
 			break Loop
 		// Right curly paren is a false positive by the lexer
 		case tokenString, tokenRightCurlyParen:
-			sb.WriteString(p.unescapeString(tok.val))
+			buf = p.appendUnescaped(buf, tok.val)
 		case tokenVariableStart:
 			// Handle variable explicitly so we can give a good error message
 			p.unexpected(tok, "string key, dictionary keys cannot contain variables")
@@ -266,21 +750,23 @@ Loop:
 			p.unexpected(tok, "string key")
 		}
 	}
-	return &StringNode{Pos: startTok.pos, Value: sb.String()}
+	p.buf = buf
+	return &StringNode{Pos: startTok.pos, Value: string(buf)}
 }
 
 // parseString parses a string value that might have variables interpolated in it.
 func (p *parser) parseString() *InterpolatedStringNode {
 	startTok := p.next()
+	var endTok token
 	var components []StringContentNode
 	// To combine and normalize false positives into a single string
 	var sn *StringNode
-	var sb strings.Builder
+	buf := p.buf[:0]
 Loop:
 	for {
 		switch p.peek().typ {
 		case tokenQuote:
-			p.next()
+			endTok = p.next()
 			break Loop
 		// Right curly paren is a false positive by the lexer
 		case tokenString, tokenRightCurlyParen:
@@ -288,13 +774,13 @@ Loop:
 			if sn == nil {
 				sn = &StringNode{Pos: tok.pos}
 			}
-			sb.WriteString(p.unescapeString(tok.val))
+			buf = p.appendUnescaped(buf, tok.val)
 		case tokenVariableStart:
 			if sn != nil {
-				sn.Value = sb.String()
+				sn.Value = string(buf)
 				components = append(components, sn)
 				sn = nil
-				sb.Reset()
+				buf = buf[:0]
 			}
 			components = append(components, p.parseVariable())
 		default:
@@ -302,26 +788,265 @@ Loop:
 		}
 	}
 	if sn != nil {
-		sn.Value = sb.String()
+		sn.Value = string(buf)
 		components = append(components, sn)
 	}
-	return &InterpolatedStringNode{Pos: startTok.pos, Components: components}
+	p.buf = buf
+	return &InterpolatedStringNode{Pos: startTok.pos, Components: components, End: endTok.pos}
+}
+
+// parseConcatenation parses the strings joined to first with + and folds
+// them into a single string, ex: "https://" + "example.com".
+func (p *parser) parseConcatenation(first ValueNode) *InterpolatedStringNode {
+	n := &InterpolatedStringNode{Pos: first.Position()}
+	operand := first
+	for {
+		switch o := operand.(type) {
+		case *InterpolatedStringNode:
+			for _, c := range o.Components {
+				n.Components = appendStringComponent(n.Components, c)
+			}
+			n.End = o.End
+		case *RawStringNode:
+			n.Components = appendStringComponent(n.Components, &StringNode{Pos: o.Pos, Value: o.Value})
+			n.End = rawStringEnd(o)
+		default:
+			p.unexpected(p.next(), "value, only strings can be concatenated")
+		}
+		if p.peek().typ != tokenPlus {
+			return n
+		}
+		p.next()
+		switch p.peek().typ {
+		case tokenQuote:
+			operand = p.parseString()
+		case tokenRawString:
+			operand = p.parseRawString()
+		default:
+			p.unexpected(p.next(), "string concatenation, expected string")
+		}
+	}
+}
+
+// appendStringComponent appends c to components. Adjacent strings are combined.
+func appendStringComponent(components []StringContentNode, c StringContentNode) []StringContentNode {
+	if sn, ok := c.(*StringNode); ok && len(components) > 0 {
+		if last, ok := components[len(components)-1].(*StringNode); ok {
+			components[len(components)-1] = &StringNode{Pos: last.Pos, Value: last.Value + sn.Value}
+			return components
+		}
+	}
+	return append(components, c)
 }
 
 func (p *parser) parseRawString() *RawStringNode {
 	tok := p.next()
 	// Strip quotes
 	s := tok.val[1 : len(tok.val)-1]
-	return &RawStringNode{Pos: tok.pos, Value: s}
+	return &RawStringNode{Pos: tok.pos, Value: string(s)}
+}
+
+// rawStringEnd returns the position of the closing quote of n.
+func rawStringEnd(n *RawStringNode) Pos {
+	end := Pos{
+		Line:   n.Pos.Line + strings.Count(n.Value, "\n"),
+		Column: n.Pos.Column + utf8.RuneCountInString(n.Value) + 1,
+		Byte:   n.Pos.Byte + len(n.Value) + 1,
+	}
+	if i := strings.LastIndexByte(n.Value, '\n'); i >= 0 {
+		end.Column = utf8.RuneCountInString(n.Value[i+1:]) + 1
+	}
+	return end
+}
+
+func (p *parser) parseMultilineString() *MultilineStringNode {
+	tok := p.next()
+	endByte := tok.pos.Byte + len(tok.val) - 3
+	lastNL := bytes.LastIndexByte(tok.val, '\n')
+	endPos := Pos{
+		Line:   tok.pos.Line + bytes.Count(tok.val, []byte{'\n'}),
+		Column: utf8.RuneCount(tok.val[lastNL+1:len(tok.val)-3]) + 1,
+		Byte:   endByte,
+	}
+	// Strip quotes and the rest of the line after the opening quotes
+	body := tok.val[3 : len(tok.val)-3]
+	start := bytes.IndexByte(body, '\n') + 1
+	body = body[start:]
+	start += tok.pos.Byte + 3
+	// The last line contains the indentation of the closing quotes
+	end := bytes.LastIndexByte(body, '\n')
+	indent := body[end+1:]
+	if end < 0 {
+		// Empty string, the closing quotes are on the line after the opening quotes
+		return &MultilineStringNode{Pos: tok.pos, Value: "", End: endPos}
+	}
+
+	buf := p.buf[:0]
+	for i, line := range bytes.Split(body[:end], []byte{'\n'}) {
+		if i > 0 {
+			buf = append(buf, '\n')
+		}
+		lineStart := start
+		start += len(line) + 1
+		line = bytes.TrimSuffix(line, []byte{'\r'})
+		switch {
+		case bytes.HasPrefix(line, indent):
+			buf = append(buf, line[len(indent):]...)
+		case len(bytes.Trim(line, " \t")) > 0:
+			// Lines containing only whitespace may be indented less
+			pos := Pos{Line: tok.pos.Line + 1 + i, Column: 1, Byte: lineStart}
+			panic(&Error{Pos: pos, Context: "insufficient indentation in multi-line string, lines must be indented at least as much as the closing quotes"})
+		}
+	}
+	p.buf = buf
+	return &MultilineStringNode{Pos: tok.pos, Value: string(buf), End: endPos}
+}
+
+// variableNode is a node that is wrapped in ${ and }, i.e. a VariableNode or an ExpressionNode.
+type variableNode interface {
+	ValueNode
+	stringContentNode()
 }
 
-func (p *parser) parseVariable() *VariableNode {
+func (p *parser) parseVariable() variableNode {
 	// Variable start, i.e. ${
 	startTok := p.next()
-	idTok := p.expect(tokenIdentifier, "variable")
-	p.expect(tokenRightCurlyParen, "variable, expected '}'")
-	id := &IdentifierNode{Pos: idTok.pos, Name: idTok.val}
-	return &VariableNode{Pos: startTok.pos, Identifier: id}
+	if p.peek().typ != tokenIdentifier && p.peek().typ != tokenLeftParen {
+		p.unexpected(p.next(), "variable")
+	}
+	switch n := p.parseExpression().(type) {
+	case *VariableNode:
+		n.Pos = startTok.pos
+		if p.peek().typ == tokenDefault {
+			tok := p.next()
+			n.Default = &StringNode{Pos: tok.pos, Value: string(bytes.TrimPrefix(tok.val, []byte(":-")))}
+		}
+		p.expect(tokenRightCurlyParen, "variable, expected '}'")
+		return n
+	case *ExpressionNode:
+		n.Pos = startTok.pos
+		n.End = p.expect(tokenRightCurlyParen, "expression, expected '}'").pos
+		return n
+	case *StringNode:
+		panic(&Error{Pos: n.Pos, Context: "string literal in variable, expected name or expression"})
+	}
+	panic("impossible: unexpected node type in variable")
+}
+
+// parseExpression parses an expression in a variable. If there is no operator, the single
+// operand is returned. The position of the returned node is the position of its first operand.
+func (p *parser) parseExpression() StringContentNode {
+	cond := p.parseCoalesce()
+	if !p.peekOperator("?") {
+		return cond
+	}
+	p.next()
+	a := p.parseExpression()
+	p.expect(tokenColon, "conditional expression, expected ':'")
+	b := p.parseExpression()
+	return &ExpressionNode{Pos: cond.Position(), Op: OpConditional, Operands: []StringContentNode{cond, a, b}}
+}
+
+// parseCoalesce parses a sequence of operands joined by ??, which is left associative.
+func (p *parser) parseCoalesce() StringContentNode {
+	x := p.parseComparison()
+	for p.peekOperator(OpCoalesce) {
+		p.next()
+		y := p.parseComparison()
+		x = &ExpressionNode{Pos: x.Position(), Op: OpCoalesce, Operands: []StringContentNode{x, y}}
+	}
+	return x
+}
+
+// parseComparison parses an operand optionally followed by == or != and another operand.
+func (p *parser) parseComparison() StringContentNode {
+	x := p.parseOperand()
+	if p.peekOperator(OpEqual) || p.peekOperator(OpNotEqual) {
+		op := string(p.next().val)
+		y := p.parseOperand()
+		x = &ExpressionNode{Pos: x.Position(), Op: op, Operands: []StringContentNode{x, y}}
+	}
+	return x
+}
+
+// parseOperand parses a string literal, variable name, function call, or parenthesized expression.
+func (p *parser) parseOperand() StringContentNode {
+	switch tok := p.next(); tok.typ {
+	case tokenArgString:
+		return p.parseArgString(tok)
+	case tokenIdentifier:
+		return p.parseVariableName(tok)
+	case tokenLeftParen:
+		x := p.parseExpression()
+		p.expect(tokenRightParen, "expression, expected ')'")
+		return x
+	default:
+		p.unexpected(tok, "expression, expected operand")
+	}
+	return nil
+}
+
+// peekOperator reports whether the next token is the operator op.
+func (p *parser) peekOperator(op string) bool {
+	tok := p.peek()
+	return tok.typ == tokenOperator && string(tok.val) == op
+}
+
+// parseArgString parses a double quoted function argument or expression operand.
+func (p *parser) parseArgString(tok token) *StringNode {
+	// Strip quotes
+	s := p.appendUnescaped(nil, tok.val[1:len(tok.val)-1])
+	return &StringNode{Pos: tok.pos, Value: string(s)}
+}
+
+// parseVariableName parses the variable name or function call starting with idTok.
+// The position of the returned node is the position of the name.
+func (p *parser) parseVariableName(idTok token) *VariableNode {
+	id := &IdentifierNode{Pos: idTok.pos, Name: p.intern(idTok.val)}
+	n := &VariableNode{Pos: idTok.pos, Identifier: id}
+	if p.peek().typ != tokenLeftParen {
+		return n
+	}
+	p.next()
+	n.IsCall = true
+	for p.peek().typ != tokenRightParen {
+		if len(n.Args) > 0 {
+			p.expect(tokenComma, "function call, expected ',' or ')'")
+		}
+		switch tok := p.next(); tok.typ {
+		case tokenArgString:
+			n.Args = append(n.Args, p.parseArgString(tok))
+		case tokenIdentifier:
+			n.Args = append(n.Args, p.parseVariableName(tok))
+		default:
+			p.unexpected(tok, "function call, expected argument")
+		}
+	}
+	n.Rparen = p.next().pos
+	return n
+}
+
+func (p *parser) parseAnchor() *AnchorNode {
+	tok := p.next()
+	name := &IdentifierNode{
+		Pos:  Pos{Line: tok.pos.Line, Column: tok.pos.Column + 1, Byte: tok.pos.Byte + 1},
+		Name: p.intern(tok.val[1:]),
+	}
+	val := p.parseRequiredValue("anchor")
+	n := &AnchorNode{Pos: tok.pos, Name: name, Value: val}
+	// Inline comments belong to the anchor since it is the value of the member or element
+	c := val.Comments()
+	n.CommentGroup.Inline, c.Inline = c.Inline, nil
+	return n
+}
+
+func (p *parser) parseAlias() *AliasNode {
+	tok := p.next()
+	name := &IdentifierNode{
+		Pos:  Pos{Line: tok.pos.Line, Column: tok.pos.Column + 1, Byte: tok.pos.Byte + 1},
+		Name: p.intern(tok.val[1:]),
+	}
+	return &AliasNode{Pos: tok.pos, Name: name}
 }
 
 func (p *parser) parseMember() Node {
@@ -344,7 +1069,10 @@ func (p *parser) parseMember() Node {
 	switch p.peek().typ {
 	case tokenIdentifier:
 		tok := p.next()
-		key = &IdentifierNode{Pos: tok.pos, Name: tok.val}
+		if p.dottedKeys && bytes.IndexByte(tok.val, '.') >= 0 {
+			return p.parseDottedMember(tok, headComments)
+		}
+		key = &IdentifierNode{Pos: tok.pos, Name: p.intern(tok.val)}
 	case tokenQuote:
 		key = p.parseStringKey()
 	case tokenRawString:
@@ -359,37 +1087,199 @@ func (p *parser) parseMember() Node {
 	p.expect(tokenColon, "dictionary element, expected ':'")
 
 	// Value can be any value, and so we recurse
-	val := p.parseValue()
+	val := p.parseRequiredValue("dictionary element")
 	return &MemberNode{Pos: key.Position(), Key: key, Value: val}
 }
 
+// parseDottedMember parses a member whose key is the dotted identifier tok.
+// Each part of the key after the first becomes a nested dictionary.
+func (p *parser) parseDottedMember(tok token, headComments []Comment) *MemberNode {
+	var keys []*IdentifierNode
+	pos := tok.pos
+	for _, name := range bytes.Split(tok.val, []byte{'.'}) {
+		if len(name) == 0 {
+			panic(&Error{Pos: tok.pos, Context: fmt.Sprintf("invalid dotted key %q, empty key", tok.val)})
+		}
+		keys = append(keys, &IdentifierNode{Pos: pos, Name: p.intern(name)})
+		n := utf8.RuneCount(name) + 1
+		pos = Pos{Line: pos.Line, Column: pos.Column + n, Byte: pos.Byte + len(name) + 1}
+	}
+	key := keys[0]
+	key.Comments().Head = headComments
+	key.Comments().Inline = p.parseInlineComments(key.Position().Line)
+	p.expect(tokenColon, "dictionary element, expected ':'")
+
+	val := p.parseRequiredValue("dictionary element")
+	// The dictionaries have no closing brace, they end with the value
+	var end Pos
+	if off, ok := endOffset(val); ok {
+		end = p.posAt(val.Position(), off-1)
+	}
+	for i := len(keys) - 1; i > 0; i-- {
+		mem := &MemberNode{Pos: keys[i].Pos, Key: keys[i], Value: val}
+		dict := &DictionaryNode{Pos: keys[i].Pos, Members: []*MemberNode{mem}, End: end}
+		if p.dotted == nil {
+			p.dotted = make(map[*DictionaryNode]bool)
+		}
+		p.dotted[dict] = true
+		val = dict
+	}
+	return &MemberNode{Pos: key.Pos, Key: key, Value: val}
+}
+
+// innerValue returns the value of the innermost member of n if n was created
+// from a dotted key, otherwise it returns n.
+func (p *parser) innerValue(n ValueNode) ValueNode {
+	for {
+		dict, ok := n.(*DictionaryNode)
+		if !ok || !p.dotted[dict] {
+			return n
+		}
+		n = dict.Members[0].Value
+	}
+}
+
+// posAt returns the position of the byte offset off in the input.
+// from must be a position before off.
+func (p *parser) posAt(from Pos, off int) Pos {
+	pos := from
+	for _, r := range string(p.lex.input[from.Byte:off]) {
+		if r == '\n' {
+			pos.Line++
+			pos.Column = 0
+		}
+		pos.Column++
+	}
+	pos.Byte = off
+	return pos
+}
+
+// mergeDottedMember merges m into an existing member of members with the same key
+// if m was created from a dotted key and the value of the existing member is a dictionary.
+// It reports whether m was merged. The source text of the existing member
+// is discarded since it no longer matches the member.
+func (p *parser) mergeDottedMember(members []*MemberNode, m *MemberNode) bool {
+	dict, ok := m.Value.(*DictionaryNode)
+	if !ok || !p.dotted[dict] {
+		return false
+	}
+	for i := len(members) - 1; i >= 0; i-- {
+		existing := members[i]
+		if existing.Key.KeyString() != m.Key.KeyString() {
+			continue
+		}
+		target, ok := existing.Value.(*DictionaryNode)
+		if !ok {
+			return false
+		}
+		if p.src != nil {
+			delete(p.src.spans, existing)
+		}
+		inner := dict.Members[0]
+		if !p.mergeDottedMember(target.Members, inner) {
+			p.addNestedMember(target, inner)
+		}
+		return true
+	}
+	return false
+}
+
+// addNestedMember adds m to the dictionary of an existing member that a dotted key was
+// merged into. The duplicate key policy and member limit are applied the same as for
+// members of the dictionary being parsed.
+func (p *parser) addNestedMember(dict *DictionaryNode, m *MemberNode) {
+	if p.duplicateKeys == DuplicateKeysLastWins {
+		dict.Members = append(dict.Members, m)
+	} else {
+		keys := make(map[string]int, len(dict.Members))
+		for i, existing := range dict.Members {
+			keys[existing.Key.KeyString()] = i
+		}
+		members, err := addMember(dict.Members, keys, m, p.duplicateKeys)
+		if err != nil {
+			panic(err)
+		}
+		dict.Members = members
+	}
+	p.checkMembers(len(dict.Members), m.Pos)
+}
+
 func (p *parser) parseDictionary() *DictionaryNode {
 	startTok := p.next()
+	p.enter(startTok.pos)
+	defer p.leave()
 	var members []*MemberNode
 	var end Node
+	var keys map[string]int
+	if p.duplicateKeys != DuplicateKeysLastWins {
+		keys = make(map[string]int)
+	}
 	for {
-		mem := p.parseMember()
+		start := p.peek().pos
+		var mem Node
+		if !p.try(func() { mem = p.parseMember() }) {
+			if p.skipElement(tokenRightCurlyParen) {
+				continue
+			}
+			break
+		}
 		// Handle end of dictionary
 		if mem.Type() == nodeEnd {
 			end = mem
 			break
 		}
 		memNode := mem.(*MemberNode)
-		members = append(members, memNode)
+		if len(members) > 0 {
+			memNode.Comments().BlankLinesBefore = p.blankLinesBefore(start)
+		}
+		switch {
+		case p.dotted != nil && p.mergeDottedMember(members, memNode):
+			// Added to the dictionary of an existing member
+		case keys != nil:
+			var err error
+			if members, err = addMember(members, keys, memNode, p.duplicateKeys); err != nil {
+				panic(err)
+			}
+		default:
+			members = append(members, memNode)
+		}
+		p.checkMembers(len(members), memNode.Pos)
 		// Next token must either be comma or end of dictionary
 		if p.peek().typ == tokenRightCurlyParen {
 			// Have parseMember handle end of dictionary so it also parses comments
+			p.recordSpan(memNode, start.Byte, p.lastEnd, false)
 			continue
 		}
-		tok := p.expect(tokenComma, "dictionary, expected ','")
+		srcEnd := p.lastEnd
+		var tok token
+		if !p.try(func() { tok = p.expect(tokenComma, "dictionary, expected ','") }) {
+			if p.skipElement(tokenRightCurlyParen) {
+				continue
+			}
+			break
+		}
 		// Might be additional inline comments after the comma
-		c := memNode.Value.Comments()
+		c := p.innerValue(memNode.Value).Comments()
 		// Use the comma pos not the element pos because some elements
 		// might span multiple lines
-		c.Inline = append(c.Inline, p.parseInlineComments(tok.pos.Line)...)
+		inline := p.parseInlineComments(tok.pos.Line)
+		c.Inline = append(c.Inline, inline...)
+		if len(inline) > 0 {
+			// The comma must be kept in the source text to keep the comments
+			srcEnd = p.lastEnd
+		}
+		p.recordSpan(memNode, start.Byte, srcEnd, len(inline) > 0 && !isAutomaticComma(tok))
+	}
+	closed := end != nil
+	if !closed {
+		// The dictionary was not closed because of a syntax error
+		end = &endNode{Pos: p.peek().pos}
 	}
 
-	dict := &DictionaryNode{Pos: startTok.pos, Members: members}
+	dict := &DictionaryNode{Pos: startTok.pos, Members: members, End: end.Position()}
+	if closed {
+		p.recordContainer(dict, startTok.pos.Byte, end.Position().Byte+1)
+	}
 	dict.Comments().Inline = end.Comments().Inline
 	if len(members) == 0 {
 		dict.Comments().Inner = end.Comments().Head
@@ -402,31 +1292,66 @@ func (p *parser) parseDictionary() *DictionaryNode {
 
 func (p *parser) parseList() *ListNode {
 	startTok := p.next()
+	p.enter(startTok.pos)
+	defer p.leave()
 	var elements []ValueNode
 	var end Node
 	for {
-		el := p.parseValue()
+		start := p.peek().pos
+		var el ValueNode
+		if !p.try(func() { el = p.parseValue() }) {
+			if p.skipElement(tokenRightSquareParen) {
+				continue
+			}
+			break
+		}
 		// Handle end of list
 		if el.Type() == nodeEnd {
 			end = el
 			break
 		}
+		if len(elements) > 0 {
+			el.Comments().BlankLinesBefore = p.blankLinesBefore(start)
+		}
 
 		elements = append(elements, el)
+		p.checkMembers(len(elements), el.Position())
 		// Next token must either be comma or end of list
 		if p.peek().typ == tokenRightSquareParen {
 			// Have parseValue handle end of list so it also parses comments
+			p.recordSpan(el, start.Byte, p.lastEnd, false)
 			continue
 		}
-		tok := p.expect(tokenComma, "list, expected ','")
+		srcEnd := p.lastEnd
+		var tok token
+		if !p.try(func() { tok = p.expect(tokenComma, "list, expected ','") }) {
+			if p.skipElement(tokenRightSquareParen) {
+				continue
+			}
+			break
+		}
 		// Might be additional inline comments after the comma
 		c := el.Comments()
 		// Use the comma pos not the element pos because some elements
 		// might span multiple lines
-		c.Inline = append(c.Inline, p.parseInlineComments(tok.pos.Line)...)
+		inline := p.parseInlineComments(tok.pos.Line)
+		c.Inline = append(c.Inline, inline...)
+		if len(inline) > 0 {
+			// The comma must be kept in the source text to keep the comments
+			srcEnd = p.lastEnd
+		}
+		p.recordSpan(el, start.Byte, srcEnd, len(inline) > 0 && !isAutomaticComma(tok))
+	}
+	closed := end != nil
+	if !closed {
+		// The list was not closed because of a syntax error
+		end = &endNode{Pos: p.peek().pos}
 	}
 
-	list := &ListNode{Pos: startTok.pos, Elements: elements}
+	list := &ListNode{Pos: startTok.pos, Elements: elements, End: end.Position()}
+	if closed {
+		p.recordContainer(list, startTok.pos.Byte, end.Position().Byte+1)
+	}
 	// Handle comments on endNode
 	list.Comments().Inline = end.Comments().Inline
 	if len(elements) == 0 {
@@ -438,18 +1363,17 @@ func (p *parser) parseList() *ListNode {
 	return list
 }
 
-// The unescapeString and getu4 functions were adapted from encoding/json.
+// The appendUnescaped and getu4 functions were adapted from encoding/json.
 // Copyright 2010 The Go Authors. All rights reserved.
 // Use of this source code is governed by a BSD-style
 // license that can be found in the LICENSE file.
 
-// unescapeString converts a quoted SC string literal into an actual string.
-// It will replace escape characters with their actual values.
-// str should not be quoted.
-func (p *parser) unescapeString(str string) string {
-	s := []byte(str)
+// appendUnescaped appends the unescaped form of the SC string literal s to dst
+// and returns the extended buffer. It will replace escape characters with their
+// actual values. s should not be quoted.
+func (p *parser) appendUnescaped(dst, s []byte) []byte {
 	// Check for unusual characters. If there are none,
-	// then no unescaping is needed, so return the original string.
+	// then no unescaping is needed, so append the original string.
 	r := 0
 	for r < len(s) {
 		c := s[r]
@@ -466,21 +1390,9 @@ func (p *parser) unescapeString(str string) string {
 		}
 		r += size
 	}
-	if r == len(s) {
-		return str
-	}
-
-	b := make([]byte, len(s)+2*utf8.UTFMax)
-	w := copy(b, s[0:r])
+	dst = append(dst, s[:r]...)
+	var rbuf [utf8.UTFMax]byte
 	for r < len(s) {
-		// Out of room? Can only happen if s is full of
-		// malformed UTF-8 and we're replacing each
-		// byte with RuneError.
-		if w >= len(b)-2*utf8.UTFMax {
-			nb := make([]byte, (len(b)+utf8.UTFMax)*2)
-			copy(nb, b[0:w])
-			b = nb
-		}
 		switch c := s[r]; {
 		case c == '\\':
 			r++
@@ -491,29 +1403,23 @@ func (p *parser) unescapeString(str string) string {
 			default:
 				p.errorf(`invalid escape character '\%c' in string`, s[r])
 			case '"', '\\', '/', '\'', '$':
-				b[w] = s[r]
+				dst = append(dst, s[r])
 				r++
-				w++
 			case 'b':
-				b[w] = '\b'
+				dst = append(dst, '\b')
 				r++
-				w++
 			case 'f':
-				b[w] = '\f'
+				dst = append(dst, '\f')
 				r++
-				w++
 			case 'n':
-				b[w] = '\n'
+				dst = append(dst, '\n')
 				r++
-				w++
 			case 'r':
-				b[w] = '\r'
+				dst = append(dst, '\r')
 				r++
-				w++
 			case 't':
-				b[w] = '\t'
+				dst = append(dst, '\t')
 				r++
-				w++
 			case 'u':
 				r--
 				rr := getu4(s[r:])
@@ -526,13 +1432,15 @@ func (p *parser) unescapeString(str string) string {
 					if dec := utf16.DecodeRune(rr, rr1); dec != unicode.ReplacementChar {
 						// A valid pair; consume.
 						r += 6
-						w += utf8.EncodeRune(b[w:], dec)
+						n := utf8.EncodeRune(rbuf[:], dec)
+						dst = append(dst, rbuf[:n]...)
 						break
 					}
 					// Invalid surrogate; fall back to replacement rune.
 					rr = unicode.ReplacementChar
 				}
-				w += utf8.EncodeRune(b[w:], rr)
+				n := utf8.EncodeRune(rbuf[:], rr)
+				dst = append(dst, rbuf[:n]...)
 			}
 
 		// Quote, control characters are invalid.
@@ -543,18 +1451,18 @@ func (p *parser) unescapeString(str string) string {
 
 		// ASCII
 		case c < utf8.RuneSelf:
-			b[w] = c
+			dst = append(dst, c)
 			r++
-			w++
 
 		// Coerce to well-formed UTF-8.
 		default:
 			rr, size := utf8.DecodeRune(s[r:])
 			r += size
-			w += utf8.EncodeRune(b[w:], rr)
+			n := utf8.EncodeRune(rbuf[:], rr)
+			dst = append(dst, rbuf[:n]...)
 		}
 	}
-	return string(b[0:w])
+	return dst
 }
 
 // getu4 decodes \uXXXX from the beginning of s, returning the hex value,
@@ -111,6 +111,7 @@ var parseTests = []struct {
 								Value: "service",
 							},
 						},
+						End: Pos{2, 17, 18},
 					},
 				},
 				{
@@ -142,6 +143,7 @@ var parseTests = []struct {
 					},
 				},
 			},
+			End: Pos{5, 1, 51},
 		},
 	},
 	{
@@ -184,6 +186,7 @@ var parseTests = []struct {
 					},
 				},
 			},
+			End: Pos{1, 43, 42},
 		},
 	},
 	{
@@ -237,6 +240,7 @@ var parseTests = []struct {
 								Value: "/repo",
 							},
 						},
+						End: Pos{3, 26, 44},
 					},
 				},
 				{
@@ -264,9 +268,72 @@ var parseTests = []struct {
 								Value: "-slim",
 							},
 						},
+						End: Pos{4, 29, 75},
 					},
 				},
 			},
+			End: Pos{5, 1, 78},
+		},
+	},
+	{
+		name:   "variable defaults",
+		input:  `{ region: ${region:-us-east-1}, url: "http://${host:-localhost}/" }`,
+		output: "{\n  region: ${region:-us-east-1}\n  url: \"http://${host:-localhost}/\"\n}\n",
+		ast: &DictionaryNode{
+			Pos: Pos{1, 1, 0},
+			Members: []*MemberNode{
+				{
+					Pos: Pos{1, 3, 2},
+					Key: &IdentifierNode{
+						Pos:  Pos{1, 3, 2},
+						Name: "region",
+					},
+					Value: &VariableNode{
+						Pos: Pos{1, 11, 10},
+						Identifier: &IdentifierNode{
+							Pos:  Pos{1, 13, 12},
+							Name: "region",
+						},
+						Default: &StringNode{
+							Pos:   Pos{1, 19, 18},
+							Value: "us-east-1",
+						},
+					},
+				},
+				{
+					Pos: Pos{1, 33, 32},
+					Key: &IdentifierNode{
+						Pos:  Pos{1, 33, 32},
+						Name: "url",
+					},
+					Value: &InterpolatedStringNode{
+						Pos: Pos{1, 38, 37},
+						Components: []StringContentNode{
+							&StringNode{
+								Pos:   Pos{1, 39, 38},
+								Value: "http://",
+							},
+							&VariableNode{
+								Pos: Pos{1, 46, 45},
+								Identifier: &IdentifierNode{
+									Pos:  Pos{1, 48, 47},
+									Name: "host",
+								},
+								Default: &StringNode{
+									Pos:   Pos{1, 52, 51},
+									Value: "localhost",
+								},
+							},
+							&StringNode{
+								Pos:   Pos{1, 64, 63},
+								Value: "/",
+							},
+						},
+						End: Pos{1, 65, 64},
+					},
+				},
+			},
+			End: Pos{1, 67, 66},
 		},
 	},
 	{
@@ -331,6 +398,7 @@ var parseTests = []struct {
 								Raw:     "3.14",
 							},
 						},
+						End: Pos{2, 21, 22},
 					},
 				},
 				{
@@ -353,9 +421,11 @@ var parseTests = []struct {
 								},
 							},
 						},
+						End: Pos{5, 3, 55},
 					},
 				},
 			},
+			End: Pos{6, 1, 57},
 		},
 	},
 	{
@@ -464,6 +534,7 @@ var parseTests = []struct {
 								{Pos{10, 5, 166}, " don't forget me", false},
 							},
 						},
+						End: Pos{11, 3, 187},
 					},
 				},
 				{
@@ -479,9 +550,314 @@ var parseTests = []struct {
 								{Pos{13, 5, 208}, " don't forget me either ", true},
 							},
 						},
+						End: Pos{14, 3, 239},
 					},
 				},
 			},
+			End: Pos{15, 1, 241},
+		},
+	},
+	{
+		name: "multi-line strings",
+		input: `{
+  script: """
+    #!/bin/sh
+
+    echo "${HOME}"
+      indented
+    """
+  empty: """
+  """
+}`,
+		output: `{
+  script: """
+    #!/bin/sh
+
+    echo "${HOME}"
+      indented
+    """
+  empty: """
+    """
+}
+`,
+		ast: &DictionaryNode{
+			Pos: Pos{1, 1, 0},
+			Members: []*MemberNode{
+				{
+					Pos: Pos{2, 3, 4},
+					Key: &IdentifierNode{
+						Pos:  Pos{2, 3, 4},
+						Name: "script",
+					},
+					Value: &MultilineStringNode{
+						Pos:   Pos{2, 11, 12},
+						Value: "#!/bin/sh\n\necho \"${HOME}\"\n  indented",
+						End:   Pos{7, 5, 69},
+					},
+				},
+				{
+					Pos: Pos{8, 3, 75},
+					Key: &IdentifierNode{
+						Pos:  Pos{8, 3, 75},
+						Name: "empty",
+					},
+					Value: &MultilineStringNode{
+						Pos: Pos{8, 10, 82},
+						End: Pos{9, 3, 88},
+					},
+				},
+			},
+			End: Pos{10, 1, 92},
+		},
+	},
+	{
+		name: "anchors",
+		input: `{
+  base: &res { cpu: 2 } // shared
+  worker: *res
+}`,
+		output: `{
+  base: &res {
+    cpu: 2
+  } // shared
+  worker: *res
+}
+`,
+		ast: &DictionaryNode{
+			Pos: Pos{1, 1, 0},
+			Members: []*MemberNode{
+				{
+					Pos: Pos{2, 3, 4},
+					Key: &IdentifierNode{
+						Pos:  Pos{2, 3, 4},
+						Name: "base",
+					},
+					Value: &AnchorNode{
+						Pos: Pos{2, 9, 10},
+						CommentGroup: CommentGroup{
+							Inline: []Comment{
+								{Pos{2, 25, 26}, " shared", false},
+							},
+						},
+						Name: &IdentifierNode{
+							Pos:  Pos{2, 10, 11},
+							Name: "res",
+						},
+						Value: &DictionaryNode{
+							Pos: Pos{2, 14, 15},
+							Members: []*MemberNode{
+								{
+									Pos: Pos{2, 16, 17},
+									Key: &IdentifierNode{
+										Pos:  Pos{2, 16, 17},
+										Name: "cpu",
+									},
+									Value: &NumberNode{
+										Pos:   Pos{2, 21, 22},
+										IsInt: true, IsUint: true, IsFloat: true,
+										Int64: 2, Uint64: 2, Float64: 2,
+										Raw: "2",
+									},
+								},
+							},
+							End: Pos{2, 23, 24},
+						},
+					},
+				},
+				{
+					Pos: Pos{3, 3, 38},
+					Key: &IdentifierNode{
+						Pos:  Pos{3, 3, 38},
+						Name: "worker",
+					},
+					Value: &AliasNode{
+						Pos: Pos{3, 11, 46},
+						Name: &IdentifierNode{
+							Pos:  Pos{3, 12, 47},
+							Name: "res",
+						},
+					},
+				},
+			},
+			End: Pos{4, 1, 51},
+		},
+	},
+	{
+		name: "string concatenation",
+		input: `{
+  url: "https://" + ` + "`example.com`" + ` +
+    "/${path}"
+}`,
+		output: `{
+  url: "https://example.com/${path}"
+}
+`,
+		ast: &DictionaryNode{
+			Pos: Pos{1, 1, 0},
+			Members: []*MemberNode{
+				{
+					Pos: Pos{2, 3, 4},
+					Key: &IdentifierNode{
+						Pos:  Pos{2, 3, 4},
+						Name: "url",
+					},
+					Value: &InterpolatedStringNode{
+						Pos: Pos{2, 8, 9},
+						Components: []StringContentNode{
+							&StringNode{
+								Pos:   Pos{2, 9, 10},
+								Value: "https://example.com/",
+							},
+							&VariableNode{
+								Pos: Pos{3, 7, 44},
+								Identifier: &IdentifierNode{
+									Pos:  Pos{3, 9, 46},
+									Name: "path",
+								},
+							},
+						},
+						End: Pos{3, 14, 51},
+					},
+				},
+			},
+			End: Pos{4, 1, 53},
+		},
+	},
+	{
+		name:  "function calls",
+		input: `{ path: "${join(env("HOME"), "/.config")}/sc" }`,
+		output: `{
+  path: "${join(env("HOME"), "/.config")}/sc"
+}
+`,
+		ast: &DictionaryNode{
+			Pos: Pos{1, 1, 0},
+			Members: []*MemberNode{
+				{
+					Pos: Pos{1, 3, 2},
+					Key: &IdentifierNode{
+						Pos:  Pos{1, 3, 2},
+						Name: "path",
+					},
+					Value: &InterpolatedStringNode{
+						Pos: Pos{1, 9, 8},
+						Components: []StringContentNode{
+							&VariableNode{
+								Pos: Pos{1, 10, 9},
+								Identifier: &IdentifierNode{
+									Pos:  Pos{1, 12, 11},
+									Name: "join",
+								},
+								IsCall: true,
+								Args: []StringContentNode{
+									&VariableNode{
+										Pos: Pos{1, 17, 16},
+										Identifier: &IdentifierNode{
+											Pos:  Pos{1, 17, 16},
+											Name: "env",
+										},
+										IsCall: true,
+										Args: []StringContentNode{
+											&StringNode{
+												Pos:   Pos{1, 21, 20},
+												Value: "HOME",
+											},
+										},
+										Rparen: Pos{1, 27, 26},
+									},
+									&StringNode{
+										Pos:   Pos{1, 30, 29},
+										Value: "/.config",
+									},
+								},
+								Rparen: Pos{1, 40, 39},
+							},
+							&StringNode{
+								Pos:   Pos{1, 42, 41},
+								Value: "/sc",
+							},
+						},
+						End: Pos{1, 45, 44},
+					},
+				},
+			},
+			End: Pos{1, 47, 46},
+		},
+	},
+	{
+		name:  "expressions",
+		input: `{ db: "${env == "prod" ? "db" : (host ?? "localhost")}:5432" }`,
+		output: `{
+  db: "${env == "prod" ? "db" : host ?? "localhost"}:5432"
+}
+`,
+		ast: &DictionaryNode{
+			Pos: Pos{1, 1, 0},
+			Members: []*MemberNode{
+				{
+					Pos: Pos{1, 3, 2},
+					Key: &IdentifierNode{
+						Pos:  Pos{1, 3, 2},
+						Name: "db",
+					},
+					Value: &InterpolatedStringNode{
+						Pos: Pos{1, 7, 6},
+						Components: []StringContentNode{
+							&ExpressionNode{
+								Pos: Pos{1, 8, 7},
+								Op:  OpConditional,
+								Operands: []StringContentNode{
+									&ExpressionNode{
+										Pos: Pos{1, 10, 9},
+										Op:  OpEqual,
+										Operands: []StringContentNode{
+											&VariableNode{
+												Pos: Pos{1, 10, 9},
+												Identifier: &IdentifierNode{
+													Pos:  Pos{1, 10, 9},
+													Name: "env",
+												},
+											},
+											&StringNode{
+												Pos:   Pos{1, 17, 16},
+												Value: "prod",
+											},
+										},
+									},
+									&StringNode{
+										Pos:   Pos{1, 26, 25},
+										Value: "db",
+									},
+									&ExpressionNode{
+										Pos: Pos{1, 34, 33},
+										Op:  OpCoalesce,
+										Operands: []StringContentNode{
+											&VariableNode{
+												Pos: Pos{1, 34, 33},
+												Identifier: &IdentifierNode{
+													Pos:  Pos{1, 34, 33},
+													Name: "host",
+												},
+											},
+											&StringNode{
+												Pos:   Pos{1, 42, 41},
+												Value: "localhost",
+											},
+										},
+									},
+								},
+								End: Pos{1, 54, 53},
+							},
+							&StringNode{
+								Pos:   Pos{1, 55, 54},
+								Value: ":5432",
+							},
+						},
+						End: Pos{1, 60, 59},
+					},
+				},
+			},
+			End: Pos{1, 62, 61},
 		},
 	},
 }
@@ -512,13 +888,167 @@ func TestPrint(t *testing.T) {
 			if err != nil {
 				t.Fatalf("unexpected error %s", err)
 			}
-			if ok, diff := deepEqual(n, tt.ast, "Pos"); !ok {
+			if ok, diff := deepEqual(n, tt.ast, "Pos", "End", "Rparen"); !ok {
 				t.Errorf("ASTs not equal:\n%s", diff)
 			}
 		})
 	}
 }
 
+func TestFormatWithOptions(t *testing.T) {
+	input := `{
+  // Comment
+  name: "foo"
+  list: [1, { a: true }] // trailing
+}
+`
+	n, err := Parse([]byte(input))
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	got := FormatWithOptions(n, FormatOptions{Indent: "\t", Newline: "\r\n"})
+	want := "{\r\n\t// Comment\r\n\tname: \"foo\"\r\n\tlist: [\r\n\t\t1\r\n\t\t{\r\n\t\t\ta: true\r\n\t\t}\r\n\t] // trailing\r\n}\r\n"
+	if string(got) != want {
+		t.Errorf("got formatted SC\n%q\nwant\n%q", got, want)
+	}
+	// The zero value should match Format
+	if got, want := FormatWithOptions(n, FormatOptions{}), Format(n); string(got) != string(want) {
+		t.Errorf("got formatted SC\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestFormatLineWidth(t *testing.T) {
+	input := `{
+  ports: [80, 443]
+  empty: []
+  server: { host: "localhost", port: 8080, tags: ["a", "b"] }
+  long: ["aaaaaaaaaa", "bbbbbbbbbb", "cccccccccc"]
+  commented: [
+    1 // one
+  ]
+}
+`
+	n, err := Parse([]byte(input))
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	got := FormatWithOptions(n, FormatOptions{LineWidth: 40})
+	want := `{
+  ports: [80, 443]
+  empty: []
+  server: {
+    host: "localhost"
+    port: 8080
+    tags: ["a", "b"]
+  }
+  long: [
+    "aaaaaaaaaa"
+    "bbbbbbbbbb"
+    "cccccccccc"
+  ]
+  commented: [
+    1 // one
+  ]
+}
+`
+	if string(got) != want {
+		t.Errorf("got formatted SC\n%s\nwant\n%s", got, want)
+	}
+	// Make sure the output can be parsed
+	if _, err := Parse(got); err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+}
+
+func TestFormatBlankLines(t *testing.T) {
+	input := `{
+
+  name: "foo",
+
+
+  // Server config
+  host: "localhost"
+  port: 8080
+
+  list: [
+    1
+    2
+
+    3
+  ]
+}
+`
+	n, err := Parse([]byte(input))
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	blanks := []int{0, 2, 0, 1}
+	for i, m := range n.Members {
+		if got := m.Comments().BlankLinesBefore; got != blanks[i] {
+			t.Errorf("got %d blank lines before member %d, want %d", got, i, blanks[i])
+		}
+	}
+	got := Format(n)
+	want := `{
+  name: "foo"
+
+  // Server config
+  host: "localhost"
+  port: 8080
+
+  list: [
+    1
+    2
+
+    3
+  ]
+}
+`
+	if string(got) != want {
+		t.Errorf("got formatted SC\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestFormatSortKeys(t *testing.T) {
+	input := `{
+  name: "foo"
+  // Nested
+  config: {
+    z: 1
+    "a": 2
+  }
+  list: [{ b: true, a: false }]
+}
+`
+	n, err := Parse([]byte(input))
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	got := FormatWithOptions(n, FormatOptions{SortKeys: true})
+	want := `{
+  // Nested
+  config: {
+    "a": 2
+    z: 1
+  }
+  list: [
+    {
+      a: false
+      b: true
+    }
+  ]
+  name: "foo"
+}
+`
+	if string(got) != want {
+		t.Errorf("got formatted SC\n%s\nwant\n%s", got, want)
+	}
+	// The AST should not be modified
+	if k := n.Members[0].Key.KeyString(); k != "name" {
+		t.Errorf("got first key %q, want name", k)
+	}
+}
+
 func TestParseError(t *testing.T) {
 	tests := []struct {
 		name  string
@@ -559,6 +1089,110 @@ func TestParseError(t *testing.T) {
 				Context: "unexpected <${> in string key, dictionary keys cannot contain variables",
 			},
 		},
+		{
+			name:  "text after opening quotes of multi-line string",
+			input: "{ s: \"\"\" text\n  \"\"\"\n}",
+			err: &Error{
+				Pos:     Pos{1, 6, 5},
+				Context: "multi-line string must start on the line after the opening quotes",
+			},
+		},
+		{
+			name:  "text before closing quotes of multi-line string",
+			input: "{ s: \"\"\"\n  text\"\"\"\n}",
+			err: &Error{
+				Pos:     Pos{1, 6, 5},
+				Context: "closing quotes of multi-line string must be on their own line",
+			},
+		},
+		{
+			name:  "unterminated multi-line string",
+			input: "{ s: \"\"\"\n  text\n}",
+			err: &Error{
+				Pos:     Pos{1, 6, 5},
+				Context: "unterminated multi-line string",
+			},
+		},
+		{
+			name:  "insufficient indentation in multi-line string",
+			input: "{\n  s: \"\"\"\n    a\n\n  b\n    \"\"\"\n}",
+			err: &Error{
+				Pos:     Pos{5, 1, 18},
+				Context: "insufficient indentation in multi-line string, lines must be indented at least as much as the closing quotes",
+			},
+		},
+		{
+			name:  "anchor without name",
+			input: `{ a: & 1 }`,
+			err: &Error{
+				Pos:     Pos{1, 6, 5},
+				Context: "expected name after '&'",
+			},
+		},
+		{
+			name:  "anchor without value",
+			input: `{ a: [&b] }`,
+			err: &Error{
+				Pos:     Pos{1, 9, 8},
+				Context: "unexpected <]> in anchor, expected value",
+			},
+		},
+		{
+			name:  "end of list as member value",
+			input: `{a: ]}`,
+			err: &Error{
+				Pos:     Pos{1, 5, 4},
+				Context: "unexpected <]> in dictionary element, expected value",
+			},
+		},
+		{
+			name:  "concatenation of non-string",
+			input: `{ a: 1 + "b" }`,
+			err: &Error{
+				Pos:     Pos{1, 8, 7},
+				Context: "unexpected <+> in value, only strings can be concatenated",
+			},
+		},
+		{
+			name:  "concatenation without string",
+			input: `{ a: "b" + 1 }`,
+			err: &Error{
+				Pos:     Pos{1, 12, 11},
+				Context: `unexpected <Number: "1"> in string concatenation, expected string`,
+			},
+		},
+		{
+			name:  "function call missing comma",
+			input: `{ a: ${f(a b)} }`,
+			err: &Error{
+				Pos:     Pos{1, 12, 11},
+				Context: `unexpected <Identifier: "b"> in function call, expected ',' or ')'`,
+			},
+		},
+		{
+			name:  "unterminated function call",
+			input: `{ a: ${f("x)} }`,
+			err: &Error{
+				Pos:     Pos{1, 10, 9},
+				Context: "unterminated string in function arguments",
+			},
+		},
+		{
+			name:  "conditional expression missing colon",
+			input: `{ a: ${b ? "c"} }`,
+			err: &Error{
+				Pos:     Pos{1, 15, 14},
+				Context: "unexpected <}> in conditional expression, expected ':'",
+			},
+		},
+		{
+			name:  "expression missing operand",
+			input: `{ a: ${b ?? } }`,
+			err: &Error{
+				Pos:     Pos{1, 13, 12},
+				Context: "unexpected <}> in expression, expected operand",
+			},
+		},
 		{
 			name:  "invalid key",
 			input: `{ 42: null }`,
@@ -615,6 +1249,307 @@ func TestParseError(t *testing.T) {
 	}
 }
 
+func TestParseDuplicateKeys(t *testing.T) {
+	input := []byte(`{
+  foo: 1
+  bar: { a: true, "a": false }
+}`)
+	// Allowed by default
+	if _, err := Parse(input); err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+
+	_, err := Parse(input, WithDisallowDuplicateKeys(true))
+	if err == nil {
+		t.Fatalf("want error")
+	}
+	var dupErr *DuplicateKeyError
+	if !errors.As(err, &dupErr) {
+		t.Fatalf("got err %#v, want *DuplicateKeyError", err)
+	}
+	want := DuplicateKeyError{Key: "a", Pos: Pos{3, 19, 29}, PrevPos: Pos{3, 10, 20}}
+	if *dupErr != want {
+		t.Errorf("got err\n\t%+v\nwant\n\t%+v", *dupErr, want)
+	}
+	wantText := `sc: 3:19: duplicate key "a", previously defined at 3:10`
+	if err.Error() != wantText {
+		t.Errorf("got error string\n\t%s\nwant\n\t%s", err, wantText)
+	}
+}
+
+func TestParseDottedKeys(t *testing.T) {
+	input := []byte(`{
+  server.http.port: 8080
+  server.host: "localhost"
+  "db.name": "app"
+  log: { level: "info" }
+  log.format: "json"
+}`)
+	// Not allowed by default
+	if _, err := Parse(input); err == nil {
+		t.Fatalf("want error")
+	}
+
+	n, err := Parse(input, WithDottedKeys(true), WithDuplicateKeyPolicy(DuplicateKeysError))
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	want := `{
+  server: {
+    http: {
+      port: 8080
+    }
+    host: "localhost"
+  }
+  "db.name": "app"
+  log: {
+    level: "info"
+    format: "json"
+  }
+}
+`
+	if got := string(Format(n)); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+	http := n.Members[0].Value.(*DictionaryNode).Members[0]
+	if http.Key.Position() != (Pos{2, 10, 11}) {
+		t.Errorf("got key pos %v, want %v", http.Key.Position(), Pos{2, 10, 11})
+	}
+	if end := http.Value.(*DictionaryNode).End; end != (Pos{2, 24, 25}) {
+		t.Errorf("got end pos %v, want %v", end, Pos{2, 24, 25})
+	}
+
+	_, err = Parse([]byte(`{ a..b: 1 }`), WithDottedKeys(true))
+	wantErr := `sc: Parse Error: 1:3: invalid dotted key "a..b", empty key`
+	if err == nil || err.Error() != wantErr {
+		t.Errorf("got error %v, want %s", err, wantErr)
+	}
+
+	// The duplicate key policy and limits apply to the nested dictionaries
+	input = []byte("{\n  server.port: 1\n  server.port: 2\n}")
+	_, err = Parse(input, WithDottedKeys(true), WithDisallowDuplicateKeys(true))
+	wantErr = `sc: 3:10: duplicate key "port", previously defined at 2:10`
+	if err == nil || err.Error() != wantErr {
+		t.Errorf("got error %v, want %s", err, wantErr)
+	}
+	n, err = Parse(input, WithDottedKeys(true), WithDuplicateKeyPolicy(DuplicateKeysFirstWins))
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	if got, want := string(Format(n)), "{\n  server: {\n    port: 1\n  }\n}\n"; got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+	_, err = Parse([]byte(`{ a.b: 1, a.c: 2, a.d: 3, a.e: 4 }`), WithDottedKeys(true), WithMaxMembers(2))
+	wantErr = "sc: 1:21: members limit of 2 exceeded"
+	if err == nil || err.Error() != wantErr {
+		t.Errorf("got error %v, want %s", err, wantErr)
+	}
+}
+
+func TestParseFile(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		opts  []ParseOption
+		want  string
+	}{
+		{"syntax error", "{\n  a: 1\n  b 2\n}", nil, "sc: Parse Error: prod.sc:3:5: unexpected <Number: \"2\"> in dictionary element, expected ':'"},
+		{"duplicate key", "{ a: 1, a: 2 }", []ParseOption{WithDisallowDuplicateKeys(true)}, `sc: prod.sc:1:9: duplicate key "a", previously defined at 1:3`},
+		{"limit", "{ a: [1] }", []ParseOption{WithMaxDepth(1)}, "sc: prod.sc:1:6: depth limit of 1 exceeded"},
+		{"input size limit", "{ a: 1 }", []ParseOption{WithMaxInputSize(4)}, "sc: prod.sc: input size limit of 4 exceeded"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseFile("prod.sc", []byte(tt.input), tt.opts...)
+			if err == nil {
+				t.Fatalf("want error")
+			}
+			if err.Error() != tt.want {
+				t.Errorf("got error string\n\t%s\nwant\n\t%s", err, tt.want)
+			}
+		})
+	}
+
+	var perr *Error
+	_, err := Parse([]byte("{ a }"), WithFilename("dev.sc"))
+	if !errors.As(err, &perr) {
+		t.Fatalf("got err %#v, want *Error", err)
+	}
+	if perr.Filename != "dev.sc" {
+		t.Errorf("got filename %q, want %q", perr.Filename, "dev.sc")
+	}
+}
+
+func TestParseTolerant(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		output string // the AST formatted on a single line
+		errs   []string
+	}{
+		{
+			name:   "multiple errors",
+			input:  "{\n  a: 1\n  b 2\n  c: [1, 2 3, 4]\n  d: \"ok\"\n  e: \n}",
+			output: `{a: 1, c: [1, 2, 4], d: "ok"}`,
+			errs: []string{
+				`sc: Parse Error: 3:5: unexpected <Number: "2"> in dictionary element, expected ':'`,
+				`sc: Parse Error: 4:12: unexpected <Number: "3"> in list, expected ','`,
+				`sc: Parse Error: 7:1: unexpected <}> in value`,
+			},
+		},
+		{
+			name:   "unclosed list",
+			input:  "{ a: [1, 2 }",
+			output: `{a: [1, 2]}`,
+			errs:   []string{`sc: Parse Error: 1:12: unexpected <}> in list, expected ','`},
+		},
+		{
+			name:   "unclosed dictionary",
+			input:  "{ a: 1",
+			output: `{a: 1}`,
+			errs:   []string{`sc: Parse Error: 1:7: unexpected EOF in dictionary, expected ','`},
+		},
+		{
+			name:   "invalid token",
+			input:  "{ a: @, b: 2\n c: 3 }",
+			output: `{c: 3}`,
+			errs:   []string{`sc: Parse Error: 1:6: unrecognized character scanned: U+0040 '@'`},
+		},
+		{
+			name:   "nested",
+			input:  "{ a: { b: } c: 1 }",
+			output: `{a: {}}`,
+			errs: []string{
+				`sc: Parse Error: 1:11: unexpected <}> in value`,
+				`sc: Parse Error: 1:13: unexpected <Identifier: "c"> in dictionary, expected ','`,
+			},
+		},
+		{
+			name:  "not a dictionary",
+			input: "[1]",
+			errs:  []string{`sc: Parse Error: 1:1: top level value in SC document must be a dictionary`},
+		},
+		{
+			name:   "no errors",
+			input:  "{ a: 1 }",
+			output: `{a: 1}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n, err := ParseTolerant([]byte(tt.input))
+			var got string
+			if n != nil {
+				got = n.String()
+			}
+			if got != tt.output {
+				t.Errorf("got AST %s, want %s", got, tt.output)
+			}
+			if tt.errs == nil {
+				if err != nil {
+					t.Fatalf("unexpected error %s", err)
+				}
+				return
+			}
+			var errs ErrorList
+			if !errors.As(err, &errs) {
+				t.Fatalf("got err %#v, want ErrorList", err)
+			}
+			var gotErrs []string
+			for _, e := range errs {
+				gotErrs = append(gotErrs, e.Error())
+			}
+			if !reflect.DeepEqual(gotErrs, tt.errs) {
+				t.Errorf("got errors\n\t%s\nwant\n\t%s", strings.Join(gotErrs, "\n\t"), strings.Join(tt.errs, "\n\t"))
+			}
+		})
+	}
+
+	// Errors other than syntax errors still stop parsing
+	_, err := ParseTolerant([]byte("{ a: 1, a: 2 }"), WithDisallowDuplicateKeys(true))
+	var dupErr *DuplicateKeyError
+	if !errors.As(err, &dupErr) {
+		t.Errorf("got err %#v, want *DuplicateKeyError", err)
+	}
+}
+
+func TestParseLimits(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		opt   ParseOption
+		err   *LimitError
+		text  string
+	}{
+		{
+			name:  "max depth",
+			input: `{ a: { b: [ { c: 1 } ] } }`,
+			opt:   WithMaxDepth(3),
+			err:   &LimitError{Limit: LimitDepth, Max: 3, Pos: Pos{1, 13, 12}},
+			text:  "sc: 1:13: depth limit of 3 exceeded",
+		},
+		{
+			name:  "max input size",
+			input: `{ a: 1 }`,
+			opt:   WithMaxInputSize(4),
+			err:   &LimitError{Limit: LimitInputSize, Max: 4},
+			text:  "sc: input size limit of 4 exceeded",
+		},
+		{
+			name:  "max dictionary members",
+			input: `{ a: 1, b: 2, c: 3 }`,
+			opt:   WithMaxMembers(2),
+			err:   &LimitError{Limit: LimitMembers, Max: 2, Pos: Pos{1, 15, 14}},
+			text:  "sc: 1:15: members limit of 2 exceeded",
+		},
+		{
+			name:  "max list elements",
+			input: `{ a: [1, 2, 3] }`,
+			opt:   WithMaxMembers(2),
+			err:   &LimitError{Limit: LimitMembers, Max: 2, Pos: Pos{1, 13, 12}},
+			text:  "sc: 1:13: members limit of 2 exceeded",
+		},
+		{
+			name:  "max alias expansion",
+			input: `{ a: &a [1, 1, 1], b: &b [*a, *a, *a], c: &c [*b, *b, *b], d: [*c, *c, *c] }`,
+			opt:   WithMaxAliasExpansion(100),
+			err:   &LimitError{Limit: LimitAliasExpansion, Max: 100},
+			text:  "sc: alias expansion limit of 100 exceeded",
+		},
+		{
+			name:  "within alias expansion limit",
+			input: `{ a: &a [1, 1], b: [*a, *a] }`,
+			opt:   WithMaxAliasExpansion(11),
+		},
+		{
+			name:  "within limits",
+			input: `{ a: [1, 2], b: { c: true } }`,
+			opt:   WithMaxDepth(2),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.input), tt.opt)
+			if tt.err == nil {
+				if err != nil {
+					t.Fatalf("unexpected error %s", err)
+				}
+				return
+			}
+			var limitErr *LimitError
+			if !errors.As(err, &limitErr) {
+				t.Fatalf("got err %#v, want *LimitError", err)
+			}
+			if *limitErr != *tt.err {
+				t.Errorf("got err\n\t%+v\nwant\n\t%+v", *limitErr, *tt.err)
+			}
+			if err.Error() != tt.text {
+				t.Errorf("got error string\n\t%s\nwant\n\t%s", err, tt.text)
+			}
+		})
+	}
+}
+
 // deepEqual is similar to reflect.DeepEqual but returns a string
 // describing the diffs if a and be are not equal.
 func deepEqual(a, b interface{}, ignoreFields ...string) (equal bool, diff string) {
@@ -831,3 +1766,58 @@ func (c *comparer) pop() {
 		c.stack = c.stack[:l-1]
 	}
 }
+
+func BenchmarkParse(b *testing.B) {
+	var inputs [][]byte
+	var size int64
+	for _, tt := range parseTests {
+		inputs = append(inputs, []byte(tt.input))
+		size += int64(len(tt.input))
+	}
+	b.SetBytes(size)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, input := range inputs {
+			if _, err := Parse(input); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+// largeInput returns a large document with many members and comments.
+func largeInput() []byte {
+	var sb strings.Builder
+	sb.WriteString("{\n")
+	for i := 0; i < 1000; i++ {
+		fmt.Fprintf(&sb, `	// Service %d
+	service_%d: {
+		name: "service-%d"
+		image: "registry.example.com/team/service:${version}"
+		"display name": "Service \\t%d"
+		command: `+"`/bin/run --port %d`"+`
+		replicas: %d
+		ratio: 0.%d
+		enabled: true
+		labels: { tier: "backend", owner: null }
+		ports: [80, 443, %d]
+	}
+
+`, i, i, i, i, 8000+i, i%10, i, 9000+i)
+	}
+	sb.WriteString("}\n")
+	return []byte(sb.String())
+}
+
+func BenchmarkParseLarge(b *testing.B) {
+	input := largeInput()
+	b.SetBytes(int64(len(input)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := Parse(input); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
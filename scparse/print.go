@@ -5,6 +5,9 @@ package scparse
 import (
 	"bytes"
 	"fmt"
+	"math"
+	"sort"
+	"strconv"
 	"strings"
 	"unicode/utf8"
 )
@@ -16,16 +19,97 @@ import (
 // and format the data nicely. However, the original textual representation of
 // the source is not guaranteed to be preserved.
 func Format(n *DictionaryNode) []byte {
-	p := &printer{}
+	return FormatWithOptions(n, FormatOptions{})
+}
+
+// FormatOptions controls the output of FormatWithOptions.
+// The zero value uses the same style as Format.
+type FormatOptions struct {
+	// Indent is the string used for each level of indentation.
+	// If empty, two spaces are used.
+	Indent string
+	// Newline is the string used to end each line, ex: "\r\n".
+	// If empty, "\n" is used.
+	Newline string
+	// LineWidth is the maximum width of a line in columns. If it is greater
+	// than zero, lists and dictionaries that fit within the line width are
+	// printed on a single line, ex: [1, 2, 3]. Lists and dictionaries that
+	// contain comments are never printed on a single line.
+	// Columns are counted in runes, each indent counts as its length in runes.
+	LineWidth int
+	// SortKeys causes the members of all dictionaries to be printed
+	// sorted by key. Comments are kept with the member they belong to.
+	SortKeys bool
+	// Canonical causes the canonical form of the document to be printed.
+	// See Canonicalize for details.
+	Canonical bool
+	// FloatFormat controls how floats are printed. It only applies to
+	// numbers without a raw value, such as those created by sc.Marshal.
+	// Numbers parsed from the input are printed as written.
+	FloatFormat FloatFormat
+}
+
+// FloatFormat is the style used to print floats.
+// All styles use the fewest digits needed to represent the value exactly.
+type FloatFormat int
+
+const (
+	// FloatAuto prints floats in decimal notation, ex: 22.22, unless the
+	// exponent is very large or small, in which case exponent notation is used.
+	FloatAuto FloatFormat = iota
+	// FloatFixed always prints floats in decimal notation, ex: 0.0000001.
+	FloatFixed
+	// FloatExponent always prints floats in exponent notation, ex: 2.222e+01.
+	FloatExponent
+)
+
+// FormatWithOptions is like Format but allows customizing the output with opts.
+func FormatWithOptions(n *DictionaryNode, opts FormatOptions) []byte {
+	if opts.Canonical {
+		n = Canonicalize(n)
+	}
+	if n.src != nil {
+		if n.src.unmodified(n) {
+			return append([]byte(nil), n.src.input...)
+		}
+		// Match the style of the source
+		if opts.Indent == "" {
+			opts.Indent = n.src.indent
+		}
+		if opts.Newline == "" {
+			opts.Newline = n.src.newline
+		}
+	}
+	p := newPrinter(opts)
+	if n.src != nil {
+		p.src = n.src
+		if p.printSource(n) {
+			return p.Bytes()
+		}
+	}
 	p.format(n)
 	return p.Bytes()
 }
 
+// newPrinter creates a printer that uses opts, filling in default values.
+func newPrinter(opts FormatOptions) *printer {
+	if opts.Indent == "" {
+		opts.Indent = "  "
+	}
+	if opts.Newline == "" {
+		opts.Newline = "\n"
+	}
+	return &printer{opts: opts}
+}
+
 // printer handles building the source string.
 type printer struct {
 	bytes.Buffer
+	opts     FormatOptions
+	src      *source   // source of unmodified nodes, if it was preserved
 	comments []Comment // pending end-of-line comments
-	margin   int       // number of indents required
+	prefix   string    // indent of the line printing started on, used when splicing the source
+	margin   int       // number of indents required after the prefix
 }
 
 // printf prints to the buffer.
@@ -35,13 +119,31 @@ func (p *printer) printf(format string, args ...interface{}) {
 
 // indent prints the necessary indent.
 func (p *printer) indent() {
+	p.WriteString(p.prefix)
 	for i := 0; i < p.margin; i++ {
-		p.WriteString("  ") // indent is 2 spaces
+		p.WriteString(p.opts.Indent)
 	}
 }
 
+// currentIndent returns the indent printed by indent.
+func (p *printer) currentIndent() string {
+	return p.prefix + strings.Repeat(p.opts.Indent, p.margin)
+}
+
 // newline ends the current line, flushing end-of-line comments.
 func (p *printer) newline() {
+	p.endLine()
+	p.indent()
+}
+
+// endLine is like newline but does not indent the next line.
+func (p *printer) endLine() {
+	p.flushComments()
+	p.WriteString(p.opts.Newline)
+}
+
+// flushComments prints the pending end-of-line comments.
+func (p *printer) flushComments() {
 	if len(p.comments) > 0 {
 		for _, c := range p.comments {
 			p.WriteByte(' ')
@@ -49,8 +151,17 @@ func (p *printer) newline() {
 		}
 		p.comments = p.comments[:0]
 	}
-	p.WriteByte('\n')
-	p.indent()
+}
+
+// separate ends the current line before the next member or element.
+// A single blank line is printed if the next node was preceded by blank lines,
+// so that logical groupings are preserved. Blank lines are not preserved
+// if keys are sorted since the groupings are no longer meaningful.
+func (p *printer) separate(next CommentGroup) {
+	if next.BlankLinesBefore > 0 && !p.opts.SortKeys {
+		p.endLine()
+	}
+	p.newline()
 }
 
 // format formats the SC document. This is the starting point for the printer.
@@ -85,16 +196,25 @@ func (p *printer) printValue(n ValueNode) {
 
 	switch n := n.(type) {
 	// Simple cases, the string representation of these nodes can be used directly
-	case *NullNode, *BoolNode, *RawStringNode, *VariableNode:
+	case *NullNode, *BoolNode, *RawStringNode, *VariableNode, *ExpressionNode, *AliasNode:
 		p.WriteString(n.String())
 	case *NumberNode:
 		p.printNumber(n)
 	case *InterpolatedStringNode:
 		p.printInterpolatedString(n)
+	case *MultilineStringNode:
+		p.printMultilineString(n)
+	case *AnchorNode:
+		p.printf("&%s ", n.Name.Name)
+		p.printValue(n.Value)
 	case *ListNode:
-		p.printList(n)
+		if !p.printSplicedValue(n) && !p.tryInline(n) {
+			p.printList(n)
+		}
 	case *DictionaryNode:
-		p.printDictionary(n)
+		if !p.printSplicedValue(n) && !p.tryInline(n) {
+			p.printDictionary(n)
+		}
 	default:
 		panic(fmt.Errorf("impossible: unexpected node type %T", n))
 	}
@@ -110,20 +230,42 @@ func (p *printer) printNumber(n *NumberNode) {
 		return
 	}
 	// Harder, stringify the necessary number value
-	if n.IsUint {
-		p.printf("%d", n.Uint64)
-		return
+	var buf [32]byte
+	b := buf[:0]
+	switch {
+	case n.IsUint:
+		b = strconv.AppendUint(b, n.Uint64, 10)
+	case n.IsInt:
+		b = strconv.AppendInt(b, n.Int64, 10)
+	case n.IsFloat:
+		b = appendFloat(b, n.Float64, p.opts.FloatFormat)
+	default:
+		// Empty number, print zero value
+		b = append(b, '0')
 	}
-	if n.IsInt {
-		p.printf("%d", n.Int64)
-		return
+	p.Write(b)
+}
+
+// appendFloat appends the shortest representation of f that round trips
+// using the given style.
+func appendFloat(b []byte, f float64, style FloatFormat) []byte {
+	format := byte('f')
+	switch style {
+	case FloatExponent:
+		format = 'e'
+	case FloatAuto:
+		// Same cutoffs as encoding/json
+		if abs := math.Abs(f); abs != 0 && (abs < 1e-6 || abs >= 1e21) {
+			format = 'e'
+		}
 	}
-	if n.IsFloat {
-		p.printf("%g", n.Float64)
-		return
+	b = strconv.AppendFloat(b, f, format, -1, 64)
+	// Whole numbers in decimal notation are parsed as ints, which fails if
+	// they overflow an int64, so make sure they are parsed as floats.
+	if format == 'f' && math.Abs(f) >= 1<<63 && !math.IsInf(f, 0) {
+		b = append(b, ".0"...)
 	}
-	// Empty number, print zero value
-	p.WriteByte('0')
+	return b
 }
 
 func (p *printer) printInterpolatedString(n *InterpolatedStringNode) {
@@ -132,7 +274,7 @@ func (p *printer) printInterpolatedString(n *InterpolatedStringNode) {
 		switch c := c.(type) {
 		case *StringNode:
 			p.escapeString(c.Value)
-		case *VariableNode:
+		case *VariableNode, *ExpressionNode:
 			p.WriteString(c.String())
 		default:
 			panic(fmt.Errorf("impossible: unexpected node type %T in InterpolatedStringNode", n))
@@ -141,6 +283,35 @@ func (p *printer) printInterpolatedString(n *InterpolatedStringNode) {
 	p.WriteByte('"')
 }
 
+// printMultilineString prints n with its content indented one level deeper than the current line.
+// If the value cannot be represented as a multi-line string, it is printed as a double quoted string.
+func (p *printer) printMultilineString(n *MultilineStringNode) {
+	if strings.Contains(n.Value, `"""`) || strings.Contains(n.Value, "\r") {
+		p.WriteByte('"')
+		p.escapeString(n.Value)
+		p.WriteByte('"')
+		return
+	}
+	p.WriteString(`"""`)
+	p.margin++
+	for i, line := range strings.Split(n.Value, "\n") {
+		if i == 0 && n.Value == "" {
+			// The closing quotes can follow the opening quotes directly
+			break
+		}
+		if line == "" {
+			// Don't leave trailing whitespace on empty lines
+			p.endLine()
+			continue
+		}
+		p.newline()
+		p.WriteString(line)
+	}
+	p.newline()
+	p.WriteString(`"""`)
+	p.margin--
+}
+
 func (p *printer) printList(n *ListNode) {
 	p.WriteByte('[')
 
@@ -164,13 +335,15 @@ func (p *printer) printList(n *ListNode) {
 	// Inner comments will get converted into comments before the first element node
 	p.printComments(n.Comments().Inner)
 	for i, e := range n.Elements {
-		p.printValue(e)
+		if !p.printOriginal(e) {
+			p.printValue(e)
+		}
 		for _, c := range e.Comments().Foot {
 			p.newline()
 			p.printComment(c)
 		}
 		if i < len(n.Elements)-1 {
-			p.newline()
+			p.separate(*n.Elements[i+1].Comments())
 		}
 	}
 	p.margin--
@@ -178,11 +351,7 @@ func (p *printer) printList(n *ListNode) {
 	p.WriteByte(']')
 }
 
-func (p *printer) printMember(n *MemberNode) {
-	p.printComments(n.Comments().Head)
-
-	k := n.Key
-	p.printComments(k.Comments().Head)
+func (p *printer) printKey(k KeyNode) {
 	switch k := k.(type) {
 	case *IdentifierNode, *RawStringNode:
 		p.WriteString(k.String())
@@ -191,8 +360,16 @@ func (p *printer) printMember(n *MemberNode) {
 		p.escapeString(k.Value)
 		p.WriteByte('"')
 	default:
-		panic(fmt.Errorf("impossible: unexpected node type %T in key", n))
+		panic(fmt.Errorf("impossible: unexpected node type %T in key", k))
 	}
+}
+
+func (p *printer) printMember(n *MemberNode) {
+	p.printComments(n.Comments().Head)
+
+	k := n.Key
+	p.printComments(k.Comments().Head)
+	p.printKey(k)
 
 	p.comments = append(p.comments, k.Comments().Inline...)
 	onOwnLine := false
@@ -235,14 +412,17 @@ func (p *printer) printDictionary(n *DictionaryNode) {
 	p.newline()
 	// Inner comments will get converted into comments before the first member node
 	p.printComments(n.Comments().Inner)
-	for i, m := range n.Members {
-		p.printMember(m)
+	members := p.members(n)
+	for i, m := range members {
+		if !p.printOriginal(m) {
+			p.printMember(m)
+		}
 		for _, c := range m.Comments().Foot {
 			p.newline()
 			p.printComment(c)
 		}
-		if i < len(n.Members)-1 {
-			p.newline()
+		if i < len(members)-1 {
+			p.separate(members[i+1].CommentGroup)
 		}
 	}
 	p.margin--
@@ -250,6 +430,274 @@ func (p *printer) printDictionary(n *DictionaryNode) {
 	p.WriteByte('}')
 }
 
+// printOriginal prints the source text of n if the source was preserved
+// and n has not been modified. It reports whether n was printed.
+func (p *printer) printOriginal(n Node) bool {
+	if p.src == nil {
+		return false
+	}
+	span, ok := p.src.original(n)
+	if !ok {
+		return false
+	}
+	p.paste(p.src.text(span), span.indent)
+	return true
+}
+
+// paste prints text from the source. Lines after the first that start with
+// the indent from are reindented to the current indent, so that the text
+// keeps its indentation relative to the surrounding lines.
+func (p *printer) paste(text, from string) {
+	to := p.currentIndent()
+	if from == to {
+		p.WriteString(text)
+		return
+	}
+	for i, line := range strings.SplitAfter(text, "\n") {
+		if i > 0 && strings.HasPrefix(line, from) {
+			p.WriteString(to)
+			line = line[len(from):]
+		}
+		p.WriteString(line)
+	}
+}
+
+// reindent returns the indent of a line in the output given its indent in the
+// source, where from is the source indent that corresponds to the current indent.
+func (p *printer) reindent(indent, from string) string {
+	if !strings.HasPrefix(indent, from) {
+		return indent
+	}
+	return p.currentIndent() + indent[len(from):]
+}
+
+// printSource prints the document n by copying its source text, only reprinting
+// the members and elements that have been modified. It reports whether n was
+// printed, see printSpliced for the requirements.
+func (p *printer) printSource(n *DictionaryNode) bool {
+	span, ok := p.src.containers[n]
+	if !ok {
+		return false
+	}
+	p.WriteString(p.src.input[:span.start])
+	if !p.printSpliced(n, span.indent) {
+		p.Reset()
+		return false
+	}
+	p.WriteString(p.src.input[span.end:])
+	return true
+}
+
+// printSplicedValue prints the list or dictionary n using printSpliced
+// if the source was preserved. It reports whether n was printed.
+func (p *printer) printSplicedValue(n ValueNode) bool {
+	return p.src != nil && p.printSpliced(n, p.currentIndent())
+}
+
+// printSpliced prints the list or dictionary n by copying its source text, only
+// reprinting the members or elements that have been modified. indent is the indent
+// of the line n starts on. It reports whether n was printed, which requires that its
+// children have not been added, removed, or reordered, and that its comments and the
+// comments and blank lines between its children have not been modified. A child may
+// be replaced by a new node, which is treated as a modification. Keys must not be sorted.
+//
+// Modified children are printed with the indentation of the source text they replace
+// and keep the whitespace and comma around them.
+func (p *printer) printSpliced(n ValueNode, indent string) bool {
+	span, ok := p.src.containers[n]
+	if !ok || p.opts.SortKeys || shell(n) != span.shell {
+		return false
+	}
+	nodes := children(n)
+	for i, c := range nodes {
+		if _, ok := p.src.spans[c]; ok && c != span.children[i] {
+			// Moved from elsewhere in the document
+			return false
+		}
+	}
+	prefix, margin, comments := p.prefix, p.margin, len(p.comments)
+	p.prefix, p.margin = indent, 0
+	defer func() { p.prefix, p.margin = prefix, margin }()
+	mark := p.Len()
+	pos := span.start
+	for i, c := range nodes {
+		cs := p.src.spans[span.children[i]]
+		if !p.printGap(p.src.input[pos:cs.start], span.indent) {
+			p.Truncate(mark)
+			p.comments = p.comments[:comments]
+			return false
+		}
+		p.printChild(c, cs, span.indent)
+		pos = cs.end
+	}
+	if !p.printGap(p.src.input[pos:span.end], span.indent) {
+		p.Truncate(mark)
+		p.comments = p.comments[:comments]
+		return false
+	}
+	return true
+}
+
+// printGap prints gap, the source text between the children of a spliced list or
+// dictionary, which is indented by from. If the previous child was reprinted and has
+// end-of-line comments, they are printed after the comma following it. This requires
+// that the rest of the line is empty, otherwise printGap reports false.
+func (p *printer) printGap(gap, from string) bool {
+	if len(p.comments) > 0 {
+		rest := strings.TrimLeft(gap, " \t")
+		if strings.HasPrefix(rest, ",") {
+			p.WriteByte(',')
+			rest = rest[1:]
+		}
+		i := strings.IndexByte(rest, '\n')
+		if i < 0 || strings.TrimSpace(rest[:i]) != "" {
+			return false
+		}
+		p.flushComments()
+		gap = strings.TrimLeft(rest, " \t")
+	}
+	p.paste(gap, from)
+	return true
+}
+
+// printChild prints the member or element n of a spliced list or dictionary in place
+// of the source text span. from is the source indent of the line the parent starts on.
+func (p *printer) printChild(n Node, span *sourceSpan, from string) {
+	prefix, margin := p.prefix, p.margin
+	p.prefix, p.margin = p.reindent(span.indent, from), 0
+	defer func() { p.prefix, p.margin = prefix, margin }()
+	if p.printOriginal(n) || p.printChildSpliced(n, span) {
+		return
+	}
+	switch n := n.(type) {
+	case *MemberNode:
+		p.printMember(n)
+	case ValueNode:
+		p.printValue(n)
+	}
+	if span.comma {
+		// The source text of the next child starts after the comma
+		p.WriteByte(',')
+	}
+}
+
+// printChildSpliced prints the modified member or element n whose value is a list or
+// dictionary that can be spliced, keeping the source text around the value.
+// It reports whether n was printed.
+func (p *printer) printChildSpliced(n Node, span *sourceSpan) bool {
+	var v Node = n
+	if m, ok := n.(*MemberNode); ok {
+		if shell(m) != span.shell {
+			return false
+		}
+		v = m.Value
+	}
+	vs, ok := p.src.containers[v]
+	if !ok || vs.start < span.start || vs.end > span.end {
+		// The value must be the one that was parsed as part of n
+		return false
+	}
+	mark := p.Len()
+	p.paste(p.src.input[span.start:vs.start], span.indent)
+	if !p.printSpliced(v.(ValueNode), p.reindent(vs.indent, span.indent)) {
+		p.Truncate(mark)
+		return false
+	}
+	p.paste(p.src.input[vs.end:span.end], span.indent)
+	return true
+}
+
+// members returns the members of n in the order they should be printed.
+func (p *printer) members(n *DictionaryNode) []*MemberNode {
+	if !p.opts.SortKeys {
+		return n.Members
+	}
+	members := make([]*MemberNode, len(n.Members))
+	copy(members, n.Members)
+	sort.SliceStable(members, func(i, j int) bool {
+		return members[i].Key.KeyString() < members[j].Key.KeyString()
+	})
+	return members
+}
+
+// tryInline prints the list or dictionary n on a single line if inlining is enabled
+// and n fits within the line width. It reports whether n was printed.
+// The top level dictionary is never inlined.
+func (p *printer) tryInline(n ValueNode) bool {
+	if p.opts.LineWidth <= 0 || (p.margin == 0 && p.prefix == "") {
+		return false
+	}
+	q := newPrinter(p.opts)
+	if !q.printInline(n) {
+		return false
+	}
+	if p.column()+utf8.RuneCount(q.Bytes()) > p.opts.LineWidth {
+		return false
+	}
+	p.Write(q.Bytes())
+	return true
+}
+
+// printInline prints n on a single line. It returns false if n contains
+// comments, in which case it cannot be printed on a single line.
+func (p *printer) printInline(n ValueNode) bool {
+	if hasComments(*n.Comments()) {
+		return false
+	}
+	switch n := n.(type) {
+	case *ListNode:
+		p.WriteByte('[')
+		for i, e := range n.Elements {
+			if i > 0 {
+				p.WriteString(", ")
+			}
+			if !p.printInline(e) {
+				return false
+			}
+		}
+		p.WriteByte(']')
+	case *DictionaryNode:
+		if len(n.Members) == 0 {
+			p.WriteString("{}")
+			return true
+		}
+		p.WriteString("{ ")
+		for i, m := range p.members(n) {
+			if hasComments(m.CommentGroup) || hasComments(*m.Key.Comments()) {
+				return false
+			}
+			if i > 0 {
+				p.WriteString(", ")
+			}
+			p.printKey(m.Key)
+			p.WriteString(": ")
+			if !p.printInline(m.Value) {
+				return false
+			}
+		}
+		p.WriteString(" }")
+	case *MultilineStringNode:
+		return false
+	case *AnchorNode:
+		p.printf("&%s ", n.Name.Name)
+		return p.printInline(n.Value)
+	default:
+		// Scalar values have no comments so they will be printed on a single line
+		p.printValue(n)
+	}
+	return true
+}
+
+// column returns the number of runes that have been printed on the current line.
+func (p *printer) column() int {
+	b := p.Bytes()
+	return utf8.RuneCount(b[bytes.LastIndexByte(b, '\n')+1:])
+}
+
+func hasComments(cg CommentGroup) bool {
+	return len(cg.Head) > 0 || len(cg.Inline) > 0 || len(cg.Foot) > 0 || len(cg.Inner) > 0
+}
+
 // escapeString converts the Go string to an SC string literal and
 // writes it to the buffer.
 func (p *printer) escapeString(s string) {
@@ -0,0 +1,270 @@
+// Copyright (c) 2021 the SC authors. All rights reserved. MIT License.
+
+package scparse
+
+import "fmt"
+
+// Edit describes a change to source text. The bytes in the range [Start, End)
+// are replaced by Text. An insertion has Start == End and a deletion has an empty Text.
+type Edit struct {
+	Start int // Byte offset of the first replaced byte.
+	End   int // Byte offset immediately after the last replaced byte.
+	Text  string
+}
+
+// Apply returns the result of applying e to src. src is not modified.
+// It panics if the range of e is not within src.
+func (e Edit) Apply(src []byte) []byte {
+	out := make([]byte, 0, len(src)-(e.End-e.Start)+len(e.Text))
+	out = append(out, src[:e.Start]...)
+	out = append(out, e.Text...)
+	return append(out, src[e.End:]...)
+}
+
+// Reparse updates the AST n, which was produced by parsing old, to reflect edit.
+// It is equivalent to calling Parse with edit.Apply(old) and the same options,
+// but it only parses the innermost list or dictionary that contains the edit, which is
+// much faster for small edits to large documents, ex: in an editor on every keystroke.
+//
+// The reparsed list or dictionary replaces the old one in n and the positions of
+// all nodes and comments after the edit are updated, so n is modified and returned.
+// n must be the result of a successful parse of old using the same options as opts.
+//
+// Reparse falls back to parsing the entire new source, returning a new AST, if the edit
+// is not inside a list or dictionary other than the top level one, if the edit changes
+// the structure of the document, ex: by removing a closing bracket, or if the new source
+// contains a syntax error. In the latter case the error is returned as is from Parse.
+// The options WithPreserveSource, WithDottedKeys, and WithDuplicateKeyPolicy with
+// DuplicateKeysDeepMerge always cause the entire source to be parsed, since they
+// create nodes that do not correspond directly to the source text.
+func Reparse(n *DictionaryNode, old []byte, edit Edit, opts ...ParseOption) (*DictionaryNode, error) {
+	if edit.Start < 0 || edit.Start > edit.End || edit.End > len(old) {
+		return nil, fmt.Errorf("sc: invalid edit range [%d, %d) for source of length %d", edit.Start, edit.End, len(old))
+	}
+	input := edit.Apply(old)
+	if root, ok := reparse(n, old, input, edit, opts); ok {
+		return root, nil
+	}
+	return Parse(input, opts...)
+}
+
+// reparse performs the incremental reparse for Reparse. It reports whether it succeeded,
+// if not n has not been modified.
+func reparse(n *DictionaryNode, old, input []byte, edit Edit, opts []ParseOption) (*DictionaryNode, bool) {
+	if n == nil {
+		return nil, false
+	}
+	p := &parser{lex: lex(input)}
+	for _, opt := range opts {
+		opt(p)
+	}
+	if p.preserveSource || p.dottedKeys || p.duplicateKeys == DuplicateKeysDeepMerge {
+		return nil, false
+	}
+	if p.maxInputSize > 0 && len(input) > p.maxInputSize {
+		return nil, false
+	}
+
+	// Find the innermost list or dictionary whose brackets enclose the edit
+	var target ValueNode
+	var replace func(ValueNode)
+	depth := 1
+	contains := func(v ValueNode) bool {
+		switch v := v.(type) {
+		case *ListNode:
+			return v.Pos.Byte < edit.Start && edit.End <= v.End.Byte
+		case *DictionaryNode:
+			return v.Pos.Byte < edit.Start && edit.End <= v.End.Byte
+		}
+		return false
+	}
+	var children []ValueNode
+	var setters []func(ValueNode)
+	parent := ValueNode(n)
+	for {
+		children, setters = children[:0], setters[:0]
+		switch v := parent.(type) {
+		case *ListNode:
+			for i := range v.Elements {
+				i := i
+				children = append(children, v.Elements[i])
+				setters = append(setters, func(nv ValueNode) { v.Elements[i] = nv })
+			}
+		case *DictionaryNode:
+			for _, m := range v.Members {
+				m := m
+				children = append(children, m.Value)
+				setters = append(setters, func(nv ValueNode) { m.Value = nv })
+			}
+		}
+		found := false
+		for i, c := range children {
+			set := setters[i]
+			for {
+				a, ok := c.(*AnchorNode)
+				if !ok {
+					break
+				}
+				c = a.Value
+				set = func(nv ValueNode) { a.Value = nv }
+			}
+			if contains(c) {
+				target, replace, found = c, set, true
+				break
+			}
+		}
+		if !found {
+			break
+		}
+		parent = target
+		depth++
+	}
+	if target == nil {
+		// Only the top level dictionary contains the edit
+		return nil, false
+	}
+
+	oldStart, oldEnd := target.Position(), containerEnd(target)
+	if oldEnd.Byte >= len(old) || !isBracket(old[oldStart.Byte]) || !isBracket(old[oldEnd.Byte]) {
+		// n does not match old
+		return nil, false
+	}
+	delta := len(edit.Text) - (edit.End - edit.Start)
+
+	// Parse the new list or dictionary starting at the same position
+	l := p.lex
+	l.pos, l.start = oldStart.Byte, oldStart.Byte
+	l.line, l.startLine = oldStart.Line, oldStart.Line
+	p.depth = depth - 1
+	var nv ValueNode
+	var err error
+	func() {
+		defer p.recover(&err)
+		if old[oldStart.Byte] == '{' {
+			nv = p.parseDictionary()
+		} else {
+			nv = p.parseList()
+		}
+	}()
+	if err != nil {
+		return nil, false
+	}
+	newEnd := containerEnd(nv)
+	if newEnd.Byte != oldEnd.Byte+delta || input[newEnd.Byte] != old[oldEnd.Byte] {
+		// The closing bracket now belongs to a different list or dictionary
+		return nil, false
+	}
+
+	// The text after the old closing bracket is unchanged, only its position has changed
+	s := shifter{after: oldEnd.Byte, line: oldEnd.Line, bytes: delta, lines: newEnd.Line - oldEnd.Line, columns: newEnd.Column - oldEnd.Column}
+	s.shiftNode(n)
+	c := target.Comments()
+	c.Inner = nv.Comments().Inner
+	*nv.Comments() = *c
+	replace(nv)
+	return n, true
+}
+
+// containerEnd returns the position of the closing bracket of a list or dictionary.
+func containerEnd(v ValueNode) Pos {
+	if l, ok := v.(*ListNode); ok {
+		return l.End
+	}
+	return v.(*DictionaryNode).End
+}
+
+func isBracket(b byte) bool {
+	return b == '[' || b == ']' || b == '{' || b == '}'
+}
+
+// shifter moves the positions after an edit to account for the size of the edit.
+type shifter struct {
+	after   int // Byte offset of the last position that is not moved.
+	line    int // The line containing after.
+	bytes   int // Number of bytes to add.
+	lines   int // Number of lines to add.
+	columns int // Number of columns to add to positions on the same line as after.
+}
+
+func (s *shifter) shift(pos *Pos) {
+	if pos.Byte <= s.after {
+		return
+	}
+	if pos.Line == s.line {
+		pos.Column += s.columns
+	}
+	pos.Byte += s.bytes
+	pos.Line += s.lines
+}
+
+func (s *shifter) shiftComments(c *CommentGroup) {
+	for _, comments := range [][]Comment{c.Head, c.Inline, c.Foot, c.Inner} {
+		for i := range comments {
+			s.shift(&comments[i].Pos)
+		}
+	}
+}
+
+// shiftNode shifts the positions of all nodes and comments in the AST rooted at n.
+func (s *shifter) shiftNode(n Node) {
+	Inspect(n, func(n Node) bool {
+		if n == nil {
+			return false
+		}
+		s.shiftComments(n.Comments())
+		switch n := n.(type) {
+		case *NullNode:
+			s.shift(&n.Pos)
+		case *BoolNode:
+			s.shift(&n.Pos)
+		case *NumberNode:
+			s.shift(&n.Pos)
+		case *StringNode:
+			s.shift(&n.Pos)
+		case *InterpolatedStringNode:
+			s.shift(&n.Pos)
+			s.shift(&n.End)
+		case *RawStringNode:
+			s.shift(&n.Pos)
+		case *MultilineStringNode:
+			s.shift(&n.Pos)
+			s.shift(&n.End)
+		case *IdentifierNode:
+			s.shift(&n.Pos)
+		case *VariableNode:
+			s.shift(&n.Pos)
+			s.shift(&n.Rparen)
+		case *ExpressionNode:
+			s.shift(&n.Pos)
+			s.shift(&n.End)
+		case *AnchorNode:
+			s.shift(&n.Pos)
+		case *AliasNode:
+			s.shift(&n.Pos)
+		case *ListNode:
+			s.shift(&n.Pos)
+			s.shift(&n.End)
+		case *MemberNode:
+			s.shift(&n.Pos)
+		case *DictionaryNode:
+			s.shift(&n.Pos)
+			s.shift(&n.End)
+		default:
+			panic(fmt.Errorf("impossible: unexpected node type %T", n))
+		}
+		// Nodes that end before the edit contain no positions that need to be moved
+		return !endsBefore(n, s.after)
+	})
+}
+
+// endsBefore reports whether the list or dictionary n ends before the byte offset off.
+// It is false for all other nodes, since their end is not known.
+func endsBefore(n Node, off int) bool {
+	switch n := n.(type) {
+	case *ListNode:
+		return n.End.Byte < off
+	case *DictionaryNode:
+		return n.End.Byte < off
+	}
+	return false
+}
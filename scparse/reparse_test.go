@@ -0,0 +1,85 @@
+// Copyright (c) 2021 the SC authors. All rights reserved. MIT License.
+
+package scparse
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const reparseInput = `{
+  // The server
+  server: {
+    host: "localhost" // inline
+    ports: [80, 443], backup: [8080] // ports
+    tls: &tls { cert: "a ${name}", key: ` + "`k`" + ` }
+  }, /* after */
+
+  list: [
+    { a: 1 }
+    [2, 3]
+  ]
+  copy: *tls
+}
+`
+
+func TestReparse(t *testing.T) {
+	tests := []struct {
+		name        string
+		old, new    string // new replaces the first occurrence of old in reparseInput
+		incremental bool
+	}{
+		{"insert member", "    ports:", "    debug: true\n    ports:", true},
+		{"change value", `"localhost"`, `"example.com"`, true},
+		{"multiline insert", "[80, 443]", "[\n      80,\n      443,\n      8080,\n    ]", true},
+		{"delete lines", "[80, 443]", "[]", true},
+		{"inside anchor", `cert: "a ${name}"`, `cert: "b"`, true},
+		{"nested list", "[2, 3]", "[2, 3, 4]", true},
+		{"same line", "80, 443", "80, 4430", true},
+		{"unicode", "host: ", "hôst: ", true},
+		{"add comment", "ports: [80, 443]", "ports: [80 /* http */, 443]", true},
+		{"top level key", "list:", "items:", false},
+		{"remove bracket", "{ a: 1 }", "{ a: 1 ", false},
+		{"extra bracket", "{ a: 1 }", "{ a: 1 } }", false},
+		{"replace container", "[2, 3]", "4", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			old := []byte(reparseInput)
+			start := strings.Index(reparseInput, tt.old)
+			edit := Edit{Start: start, End: start + len(tt.old), Text: tt.new}
+			input := edit.Apply(old)
+
+			want, wantErr := Parse(input)
+			n, err := Parse(old)
+			if err != nil {
+				t.Fatalf("failed to parse input: %v", err)
+			}
+			got, gotErr := Reparse(n, old, edit)
+			if !reflect.DeepEqual(gotErr, wantErr) {
+				t.Fatalf("got error %v, want %v", gotErr, wantErr)
+			}
+			if !reflect.DeepEqual(got, want) {
+				gotJSON, _ := ToJSONAST(got)
+				wantJSON, _ := ToJSONAST(want)
+				t.Errorf("AST does not match Parse\ngot:\n%s\nwant:\n%s", gotJSON, wantJSON)
+			}
+			if incremental := got == n; incremental != tt.incremental {
+				t.Errorf("got incremental %t, want %t", incremental, tt.incremental)
+			}
+		})
+	}
+}
+
+func TestReparseInvalidEdit(t *testing.T) {
+	old := []byte("{a: 1}")
+	n, err := Parse(old)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = Reparse(n, old, Edit{Start: 4, End: 10, Text: "2"})
+	if err == nil {
+		t.Fatal("expected error for out of range edit")
+	}
+}
@@ -0,0 +1,192 @@
+// Copyright (c) 2021 the SC authors. All rights reserved. MIT License.
+
+package scparse
+
+import "fmt"
+
+// TokenKind identifies the kind of a Token.
+type TokenKind int
+
+const (
+	TokenError           TokenKind = iota // A syntax error, Text contains the error message.
+	TokenEOF                              // The end of the input.
+	TokenNull                             // The null literal.
+	TokenBool                             // A bool literal, either true or false.
+	TokenNumber                           // A number literal.
+	TokenString                           // Text inside a double quoted string, excluding quotes and variables.
+	TokenRawString                        // A raw string, including the backquotes.
+	TokenMultilineString                  // A triple quoted multi-line string, including the quotes.
+	TokenIdentifier                       // An identifier, ex: a key or a variable or function name.
+	TokenComment                          // A comment, either // or /* style, including the delimiters.
+	TokenDefault                          // The default value of a variable, including the :-.
+	TokenAnchor                           // An anchor, including the &.
+	TokenAlias                            // An alias, including the *.
+	TokenArgString                        // A double quoted function argument or expression operand, including the quotes.
+	TokenOperator                         // An operator in an expression: ??, ==, != or ?
+	TokenLeftBracket                      // [
+	TokenRightBracket                     // ]
+	TokenLeftBrace                        // {
+	TokenRightBrace                       // }, also used to end a variable.
+	TokenVariableStart                    // ${
+	TokenQuote                            // " at the start or end of a double quoted string.
+	TokenColon                            // :
+	TokenComma                            // ,
+	TokenPlus                             // +
+	TokenLeftParen                        // (
+	TokenRightParen                       // )
+)
+
+func (k TokenKind) String() string {
+	return [...]string{
+		"Error",
+		"EOF",
+		"Null",
+		"Bool",
+		"Number",
+		"String",
+		"RawString",
+		"MultilineString",
+		"Identifier",
+		"Comment",
+		"Default",
+		"Anchor",
+		"Alias",
+		"ArgString",
+		"Operator",
+		"LeftBracket",
+		"RightBracket",
+		"LeftBrace",
+		"RightBrace",
+		"VariableStart",
+		"Quote",
+		"Colon",
+		"Comma",
+		"Plus",
+		"LeftParen",
+		"RightParen",
+	}[k]
+}
+
+// tokenKinds maps the internal token types to their TokenKind.
+var tokenKinds = [...]TokenKind{
+	tokenError:            TokenError,
+	tokenEOF:              TokenEOF,
+	tokenBool:             TokenBool,
+	tokenNumber:           TokenNumber,
+	tokenString:           TokenString,
+	tokenRawString:        TokenRawString,
+	tokenMultilineString:  TokenMultilineString,
+	tokenIdentifier:       TokenIdentifier,
+	tokenComment:          TokenComment,
+	tokenDefault:          TokenDefault,
+	tokenAnchor:           TokenAnchor,
+	tokenAlias:            TokenAlias,
+	tokenArgString:        TokenArgString,
+	tokenOperator:         TokenOperator,
+	tokenLeftSquareParen:  TokenLeftBracket,
+	tokenRightSquareParen: TokenRightBracket,
+	tokenLeftCurlyParen:   TokenLeftBrace,
+	tokenRightCurlyParen:  TokenRightBrace,
+	tokenVariableStart:    TokenVariableStart,
+	tokenQuote:            TokenQuote,
+	tokenColon:            TokenColon,
+	tokenComma:            TokenComma,
+	tokenPlus:             TokenPlus,
+	tokenLeftParen:        TokenLeftParen,
+	tokenRightParen:       TokenRightParen,
+	tokenNull:             TokenNull,
+}
+
+// Token is a lexical token of SC source text.
+type Token struct {
+	Kind TokenKind
+	Pos  Pos // Position of the first character of the token.
+	// Text is the source text of the token. For TokenError it is the error message
+	// and for TokenEOF it is empty.
+	Text string
+}
+
+func (t Token) String() string {
+	switch t.Kind {
+	case TokenEOF:
+		return "EOF"
+	case TokenError:
+		return fmt.Sprintf("%d:%d: %s", t.Pos.Line, t.Pos.Column, t.Text)
+	}
+	return fmt.Sprintf("%d:%d: %s %q", t.Pos.Line, t.Pos.Column, t.Kind, t.Text)
+}
+
+// End returns the position immediately after the token. For TokenError and
+// TokenEOF, whose text is not source text, it returns t.Pos.
+func (t Token) End() Pos {
+	if t.Kind == TokenError || t.Kind == TokenEOF {
+		return t.Pos
+	}
+	end := t.Pos
+	end.Byte += len(t.Text)
+	for _, r := range t.Text {
+		if r == '\n' {
+			end.Line++
+			end.Column = 1
+		} else {
+			end.Column++
+		}
+	}
+	return end
+}
+
+// Scanner splits SC source text into tokens. It can be used by tools that
+// need to work with the source text directly, ex: syntax highlighters.
+//
+// The tokens are the same ones used by Parse. Double quoted strings are split into
+// a TokenQuote at each end and TokenString, TokenVariableStart, and TokenRightBrace
+// tokens, along with the tokens of each variable, in between. Whitespace is skipped,
+// and the commas that are inserted automatically at the end of lines are not returned
+// since they do not correspond to any source text.
+//
+// When a syntax error is encountered, a TokenError is returned and scanning
+// continues on the next line, so that the rest of the input can still be tokenized.
+// The Scanner does not check that the tokens form a valid document, only Parse does.
+type Scanner struct {
+	lex *lexer
+}
+
+// NewScanner returns a Scanner that tokenizes input. Only the WithDottedKeys
+// option affects scanning, all other options are ignored.
+func NewScanner(input []byte, opts ...ParseOption) *Scanner {
+	var p parser
+	for _, opt := range opts {
+		opt(&p)
+	}
+	l := lex(input)
+	l.tolerant = true
+	l.dottedKeys = p.dottedKeys
+	return &Scanner{lex: l}
+}
+
+// Scan returns the next token. Once the end of the input has been reached,
+// Scan returns TokenEOF tokens.
+func (s *Scanner) Scan() Token {
+	for {
+		tok := s.lex.nextToken()
+		if tok.typ == tokenComma && len(tok.val) != 1 {
+			// Automatic comma
+			continue
+		}
+		return Token{Kind: tokenKinds[tok.typ], Pos: tok.pos, Text: string(tok.val)}
+	}
+}
+
+// Tokenize returns all tokens in input, excluding the final TokenEOF.
+// See Scanner for details.
+func Tokenize(input []byte, opts ...ParseOption) []Token {
+	s := NewScanner(input, opts...)
+	var tokens []Token
+	for {
+		tok := s.Scan()
+		if tok.Kind == TokenEOF {
+			return tokens
+		}
+		tokens = append(tokens, tok)
+	}
+}
@@ -0,0 +1,177 @@
+// Copyright (c) 2021 the SC authors. All rights reserved. MIT License.
+
+package scparse
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		opts  []ParseOption
+		want  []string
+	}{
+		{
+			"dictionary",
+			"{\n  // c\n  a: 1\n  b: [true, null]\n}",
+			nil,
+			[]string{
+				`1:1: LeftBrace "{"`,
+				`2:3: Comment "// c"`,
+				`3:3: Identifier "a"`,
+				`3:4: Colon ":"`,
+				`3:6: Number "1"`,
+				`4:3: Identifier "b"`,
+				`4:4: Colon ":"`,
+				`4:6: LeftBracket "["`,
+				`4:7: Bool "true"`,
+				`4:11: Comma ","`,
+				`4:13: Null "null"`,
+				`4:17: RightBracket "]"`,
+				`5:1: RightBrace "}"`,
+			},
+		},
+		{
+			"strings and variables",
+			"{a: \"x ${y:-z}\" + `r`, b: ${f(\"s\", v) ?? w}}",
+			nil,
+			[]string{
+				`1:1: LeftBrace "{"`,
+				`1:2: Identifier "a"`,
+				`1:3: Colon ":"`,
+				`1:5: Quote "\""`,
+				`1:6: String "x "`,
+				`1:8: VariableStart "${"`,
+				`1:10: Identifier "y"`,
+				`1:11: Default ":-z"`,
+				`1:14: RightBrace "}"`,
+				`1:15: Quote "\""`,
+				`1:17: Plus "+"`,
+				`1:19: RawString "` + "`r`" + `"`,
+				`1:22: Comma ","`,
+				`1:24: Identifier "b"`,
+				`1:25: Colon ":"`,
+				`1:27: VariableStart "${"`,
+				`1:29: Identifier "f"`,
+				`1:30: LeftParen "("`,
+				`1:31: ArgString "\"s\""`,
+				`1:34: Comma ","`,
+				`1:36: Identifier "v"`,
+				`1:37: RightParen ")"`,
+				`1:39: Operator "??"`,
+				`1:42: Identifier "w"`,
+				`1:43: RightBrace "}"`,
+				`1:44: RightBrace "}"`,
+			},
+		},
+		{
+			"anchors",
+			"{a: &x [1], b: *x}",
+			nil,
+			[]string{
+				`1:1: LeftBrace "{"`,
+				`1:2: Identifier "a"`,
+				`1:3: Colon ":"`,
+				`1:5: Anchor "&x"`,
+				`1:8: LeftBracket "["`,
+				`1:9: Number "1"`,
+				`1:10: RightBracket "]"`,
+				`1:11: Comma ","`,
+				`1:13: Identifier "b"`,
+				`1:14: Colon ":"`,
+				`1:16: Alias "*x"`,
+				`1:18: RightBrace "}"`,
+			},
+		},
+		{
+			"dotted keys",
+			"{a.b: 1}",
+			[]ParseOption{WithDottedKeys(true)},
+			[]string{
+				`1:1: LeftBrace "{"`,
+				`1:2: Identifier "a.b"`,
+				`1:5: Colon ":"`,
+				`1:7: Number "1"`,
+				`1:8: RightBrace "}"`,
+			},
+		},
+		{
+			"errors",
+			"{\n  a: #\n  b: \"x\n  c: 1\n}",
+			nil,
+			[]string{
+				`1:1: LeftBrace "{"`,
+				`2:3: Identifier "a"`,
+				`2:4: Colon ":"`,
+				`2:6: unrecognized character scanned: U+0023 '#'`,
+				`3:3: Identifier "b"`,
+				`3:4: Colon ":"`,
+				`3:6: Quote "\""`,
+				`3:7: unterminated string`,
+				`4:3: Identifier "c"`,
+				`4:4: Colon ":"`,
+				`4:6: Number "1"`,
+				`5:1: RightBrace "}"`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, tok := range Tokenize([]byte(tt.input), tt.opts...) {
+				got = append(got, tok.String())
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("got tokens\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+// TestTokenPositions checks that the text of each token matches the input between
+// its start and end positions.
+func TestTokenPositions(t *testing.T) {
+	for _, tt := range parseTests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := []byte(tt.input)
+			for _, tok := range Tokenize(input) {
+				if tok.Kind == TokenError {
+					t.Fatalf("unexpected error token %s", tok)
+				}
+				start, end := tok.Pos, tok.End()
+				if got := string(input[start.Byte:end.Byte]); got != tok.Text {
+					t.Errorf("token %s: input at its position is %q", tok, got)
+				}
+				if want := lineColumn(input, end.Byte); end.Line != want.Line || end.Column != want.Column {
+					t.Errorf("token %s: got end %d:%d, want %d:%d", tok, end.Line, end.Column, want.Line, want.Column)
+				}
+			}
+		})
+	}
+}
+
+// lineColumn returns the line and column of the byte offset off in input.
+func lineColumn(input []byte, off int) Pos {
+	pos := Pos{Line: 1, Column: 1, Byte: off}
+	for _, r := range string(input[:off]) {
+		if r == '\n' {
+			pos.Line++
+			pos.Column = 1
+		} else {
+			pos.Column++
+		}
+	}
+	return pos
+}
+
+func TestScannerEOF(t *testing.T) {
+	s := NewScanner([]byte("{}\n"))
+	for _, want := range []TokenKind{TokenLeftBrace, TokenRightBrace, TokenEOF, TokenEOF} {
+		if tok := s.Scan(); tok.Kind != want {
+			t.Fatalf("got token %s, want %s", tok, want)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+// Copyright (c) 2021 the SC authors. All rights reserved. MIT License.
+
+package scparse
+
+// SemanticKind classifies a range of source text by its meaning,
+// ex: to choose how it is highlighted by an editor.
+type SemanticKind int
+
+const (
+	SemanticKey         SemanticKind = iota // A dictionary key, quoted or unquoted.
+	SemanticString                          // A string, including the quotes, or the part of a string outside of variables.
+	SemanticNumber                          // A number.
+	SemanticKeyword                         // true, false, or null.
+	SemanticVariable                        // The name of a variable.
+	SemanticFunction                        // The name of a function called in a variable.
+	SemanticAnchor                          // An anchor or alias, including the & or *.
+	SemanticComment                         // A comment, including the delimiters.
+	SemanticOperator                        // An operator: +, ??, ==, !=, ?, or the :- before a default value.
+	SemanticPunctuation                     // Brackets, braces, parentheses, colons, commas, and the ${ and } around variables.
+)
+
+func (k SemanticKind) String() string {
+	return [...]string{
+		"key",
+		"string",
+		"number",
+		"keyword",
+		"variable",
+		"function",
+		"anchor",
+		"comment",
+		"operator",
+		"punctuation",
+	}[k]
+}
+
+// SemanticToken is a classified range of source text.
+type SemanticToken struct {
+	Kind SemanticKind
+	Pos  Pos // Position of the first character of the range.
+	End  Pos // Position immediately after the range.
+}
+
+// SemanticTokens classifies the source text of input. The returned tokens are ordered
+// by position and do not overlap. Whitespace and text that could not be tokenized
+// because of syntax errors are not covered by any token, so SemanticTokens can be used
+// on a document while it is being edited.
+//
+// Double quoted strings are split into several tokens, so that the variables they contain
+// can be classified, ex: "a ${b}" results in a SemanticString token for `"a `, SemanticPunctuation
+// tokens for ${ and }, a SemanticVariable token for b, and a SemanticString token for the final quote.
+// Tokens may span multiple lines, ex: multi-line strings and block comments.
+//
+// The options are the same as for NewScanner.
+func SemanticTokens(input []byte, opts ...ParseOption) []SemanticToken {
+	tokens := Tokenize(input, opts...)
+	var result []SemanticToken
+	add := func(kind SemanticKind, tok Token) {
+		// Adjacent tokens of the same kind are merged,
+		// ex: the quotes and text of a string
+		if n := len(result); n > 0 && result[n-1].Kind == kind && result[n-1].End == tok.Pos {
+			result[n-1].End = tok.End()
+			return
+		}
+		result = append(result, SemanticToken{Kind: kind, Pos: tok.Pos, End: tok.End()})
+	}
+	var inString, inVariable, inKey bool
+	for i, tok := range tokens {
+		switch tok.Kind {
+		case TokenError:
+			// Scanning continues on the next line, outside of any string or variable
+			inString, inVariable, inKey = false, false, false
+		case TokenIdentifier:
+			switch {
+			case !inVariable:
+				add(SemanticKey, tok)
+			case i+1 < len(tokens) && tokens[i+1].Kind == TokenLeftParen:
+				add(SemanticFunction, tok)
+			default:
+				add(SemanticVariable, tok)
+			}
+		case TokenQuote:
+			if !inString {
+				inKey = isQuotedKey(tokens, i)
+			}
+			if inKey {
+				add(SemanticKey, tok)
+			} else {
+				add(SemanticString, tok)
+			}
+			inString = !inString
+		case TokenString:
+			if inKey {
+				add(SemanticKey, tok)
+			} else {
+				add(SemanticString, tok)
+			}
+		case TokenRawString, TokenMultilineString, TokenArgString:
+			add(SemanticString, tok)
+		case TokenNumber:
+			add(SemanticNumber, tok)
+		case TokenBool, TokenNull:
+			add(SemanticKeyword, tok)
+		case TokenAnchor, TokenAlias:
+			add(SemanticAnchor, tok)
+		case TokenComment:
+			add(SemanticComment, tok)
+		case TokenOperator, TokenPlus:
+			add(SemanticOperator, tok)
+		case TokenDefault:
+			// The :- is an operator and the rest is the default value
+			op := Token{Kind: TokenDefault, Pos: tok.Pos, Text: tok.Text[:len(":-")]}
+			add(SemanticOperator, op)
+			if len(tok.Text) > len(op.Text) {
+				add(SemanticString, Token{Kind: TokenDefault, Pos: op.End(), Text: tok.Text[len(op.Text):]})
+			}
+		case TokenVariableStart:
+			inVariable = true
+			add(SemanticPunctuation, tok)
+		case TokenRightBrace:
+			// Ends the variable if there is one, otherwise ends a dictionary
+			inVariable = false
+			add(SemanticPunctuation, tok)
+		default:
+			add(SemanticPunctuation, tok)
+		}
+	}
+	return result
+}
+
+// closingQuote returns the index of the quote that closes the string opened by
+// the quote at tokens[i], or -1 if the string is not terminated.
+func closingQuote(tokens []Token, i int) int {
+	for j := i + 1; j < len(tokens); j++ {
+		switch tokens[j].Kind {
+		case TokenQuote:
+			return j
+		case TokenError:
+			return -1
+		}
+	}
+	return -1
+}
+
+// isQuotedKey reports whether the string opened by the quote at tokens[i] is a key.
+// Quoted keys cannot contain variables.
+func isQuotedKey(tokens []Token, i int) bool {
+	j := closingQuote(tokens, i)
+	if j == -1 || j+1 >= len(tokens) || tokens[j+1].Kind != TokenColon {
+		return false
+	}
+	for _, tok := range tokens[i+1 : j] {
+		if tok.Kind != TokenString {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,122 @@
+// Copyright (c) 2021 the SC authors. All rights reserved. MIT License.
+
+package scparse
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestSemanticTokens(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{
+			"values",
+			"{\n  a: 1 // c\n  b: [true, null]\n  c: `r`\n}",
+			[]string{
+				`punctuation "{"`,
+				`key "a"`,
+				`punctuation ":"`,
+				`number "1"`,
+				`comment "// c"`,
+				`key "b"`,
+				`punctuation ":"`,
+				`punctuation "["`,
+				`keyword "true"`,
+				`punctuation ","`,
+				`keyword "null"`,
+				`punctuation "]"`,
+				`key "c"`,
+				`punctuation ":"`,
+				"string \"`r`\"",
+				`punctuation "}"`,
+			},
+		},
+		{
+			"interpolated string",
+			`{"a b": "x ${y:-z} ${f("s", v) ?? w}"}`,
+			[]string{
+				`punctuation "{"`,
+				`key "\"a b\""`,
+				`punctuation ":"`,
+				`string "\"x "`,
+				`punctuation "${"`,
+				`variable "y"`,
+				`operator ":-"`,
+				`string "z"`,
+				`punctuation "}"`,
+				`string " "`,
+				`punctuation "${"`,
+				`function "f"`,
+				`punctuation "("`,
+				`string "\"s\""`,
+				`punctuation ","`,
+				`variable "v"`,
+				`punctuation ")"`,
+				`operator "??"`,
+				`variable "w"`,
+				`punctuation "}"`,
+				`string "\""`,
+				`punctuation "}"`,
+			},
+		},
+		{
+			"anchors",
+			"{a: &x \"s\" + ${v}, b: *x}",
+			[]string{
+				`punctuation "{"`,
+				`key "a"`,
+				`punctuation ":"`,
+				`anchor "&x"`,
+				`string "\"s\""`,
+				`operator "+"`,
+				`punctuation "${"`,
+				`variable "v"`,
+				`punctuation "},"`,
+				`key "b"`,
+				`punctuation ":"`,
+				`anchor "*x"`,
+				`punctuation "}"`,
+			},
+		},
+		{
+			"errors",
+			"{\n  a: \"x\n  \"b\": 1\n}",
+			[]string{
+				`punctuation "{"`,
+				`key "a"`,
+				`punctuation ":"`,
+				`string "\""`,
+				`key "\"b\""`,
+				`punctuation ":"`,
+				`number "1"`,
+				`punctuation "}"`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := []byte(tt.input)
+			var got []string
+			prev := Pos{}
+			for _, tok := range SemanticTokens(input) {
+				if tok.Pos.Byte < prev.Byte || tok.End.Byte <= tok.Pos.Byte {
+					t.Errorf("token %v is out of order or empty", tok)
+				}
+				prev = tok.End
+				got = append(got, fmt.Sprintf("%s %q", tok.Kind, input[tok.Pos.Byte:tok.End.Byte]))
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d tokens, want %d\ngot:\n%q", len(got), len(tt.want), got)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("token %d: got %s, want %s", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+// Copyright (c) 2021 the SC authors. All rights reserved. MIT License.
+
+package scparse
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// snippetContextLines is the number of lines shown before and after the
+// line containing an error by FormatError.
+const snippetContextLines = 2
+
+// Snippet renders the source surrounding pos in input as a code frame. It contains
+// the line at pos, marked with a '>', with a caret under the column of pos, as
+// well as up to contextLines lines before and after it. Each line is prefixed
+// with its line number. For example:
+//
+//	  2 |   name: "foo"
+//	> 3 |   port: "80"
+//	    |         ^
+//	  4 | }
+//
+// If pos is not within input, Snippet returns an empty string.
+func Snippet(input []byte, pos Pos, contextLines int) string {
+	lines := bytes.Split(input, []byte{'\n'})
+	if pos.Line < 1 || pos.Line > len(lines) {
+		return ""
+	}
+	first := pos.Line - contextLines
+	if first < 1 {
+		first = 1
+	}
+	last := pos.Line + contextLines
+	if last > len(lines) {
+		last = len(lines)
+	}
+	width := len(fmt.Sprint(last))
+
+	var sb strings.Builder
+	for i := first; i <= last; i++ {
+		line := bytes.TrimRight(lines[i-1], "\r")
+		marker := "  "
+		if i == pos.Line {
+			marker = "> "
+		}
+		fmt.Fprintf(&sb, "%s%*d | %s\n", marker, width, i, line)
+		if i != pos.Line {
+			continue
+		}
+		// Keep tabs so the caret lines up with the column
+		fmt.Fprintf(&sb, "  %*s | ", width, "")
+		for j, col := 0, 1; j < len(line) && col < pos.Column; col++ {
+			r, size := utf8.DecodeRune(line[j:])
+			if r == '\t' {
+				sb.WriteByte('\t')
+			} else {
+				sb.WriteByte(' ')
+			}
+			j += size
+		}
+		sb.WriteString("^\n")
+	}
+	return sb.String()
+}
+
+// FormatError formats err for display to humans. If err contains the position
+// in the input where the error occurred, the result contains the error message
+// followed by a code frame of the surrounding source as returned by Snippet.
+// Otherwise, the result is the error message. input must be the source that
+// was parsed when err occurred.
+//
+// If err is an ErrorList, each error in the list is formatted.
+func FormatError(input []byte, err error) string {
+	var pos Pos
+	switch e := err.(type) {
+	case ErrorList:
+		var parts []string
+		for _, e := range e {
+			parts = append(parts, FormatError(input, e))
+		}
+		return strings.Join(parts, "\n")
+	case *Error:
+		pos = e.Pos
+	case *DuplicateKeyError:
+		pos = e.Pos
+	case *LimitError:
+		pos = e.Pos
+	case *UnresolvedVariableError:
+		pos = e.Pos
+	case *AnchorError:
+		pos = e.Pos
+	}
+	return FormatErrorAt(input, err, pos)
+}
+
+// FormatErrorAt is like FormatError but the position of the error is provided
+// by pos. This allows errors from other packages to be formatted.
+// If pos is the zero value, the result is the error message.
+func FormatErrorAt(input []byte, err error, pos Pos) string {
+	msg := err.Error()
+	if pos == (Pos{}) {
+		return msg
+	}
+	snippet := Snippet(input, pos, snippetContextLines)
+	if snippet == "" {
+		return msg
+	}
+	return msg + "\n" + strings.TrimSuffix(snippet, "\n")
+}
@@ -0,0 +1,80 @@
+// Copyright (c) 2021 the SC authors. All rights reserved. MIT License.
+
+package scparse
+
+import (
+	"testing"
+)
+
+func TestSnippet(t *testing.T) {
+	input := []byte("{\n  name: \"foo\"\n  port: \"80\"\n\tfoo: ☃ 1\n}")
+	tests := []struct {
+		name         string
+		pos          Pos
+		contextLines int
+		want         string
+	}{
+		{
+			name:         "middle",
+			pos:          Pos{3, 9, 25},
+			contextLines: 1,
+			want: "  2 |   name: \"foo\"\n" +
+				"> 3 |   port: \"80\"\n" +
+				"    |         ^\n" +
+				"  4 | \tfoo: ☃ 1\n",
+		},
+		{
+			name:         "first line",
+			pos:          Pos{1, 1, 0},
+			contextLines: 2,
+			want: "> 1 | {\n" +
+				"    | ^\n" +
+				"  2 |   name: \"foo\"\n" +
+				"  3 |   port: \"80\"\n",
+		},
+		{
+			name:         "tabs and unicode",
+			pos:          Pos{4, 9, 41},
+			contextLines: 0,
+			want: "> 4 | \tfoo: ☃ 1\n" +
+				"    | \t       ^\n",
+		},
+		{
+			name: "out of range",
+			pos:  Pos{9, 1, 100},
+			want: "",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Snippet(input, tt.pos, tt.contextLines)
+			if got != tt.want {
+				t.Errorf("got snippet\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormatError(t *testing.T) {
+	input := []byte("{\n  a: 1\n  b 2\n}")
+	_, err := ParseFile("test.sc", input)
+	if err == nil {
+		t.Fatalf("want error")
+	}
+	got := FormatError(input, err)
+	want := `sc: Parse Error: test.sc:3:5: unexpected <Number: "2"> in dictionary element, expected ':'
+  1 | {
+  2 |   a: 1
+> 3 |   b 2
+    |     ^
+  4 | }`
+	if got != want {
+		t.Errorf("got formatted error\n%s\nwant\n%s", got, want)
+	}
+
+	// Errors without a position are left as is
+	err = &LimitError{Limit: LimitInputSize, Max: 1}
+	if got := FormatError(input, err); got != err.Error() {
+		t.Errorf("got formatted error %q, want %q", got, err.Error())
+	}
+}
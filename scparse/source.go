@@ -0,0 +1,214 @@
+// Copyright (c) 2021 the SC authors. All rights reserved. MIT License.
+
+package scparse
+
+import (
+	"fmt"
+	"strings"
+)
+
+// source retains the original input of a parsed document so that
+// unmodified parts of the AST can be printed exactly as they appeared.
+type source struct {
+	// A copy of the input, since the caller may reuse the input slice
+	// after Parse returns.
+	input string
+	// The formatted text of the document when it was parsed. Used to
+	// determine if the document has been modified.
+	formatted string
+	// The string used for each level of indentation in the input,
+	// or empty if it could not be determined.
+	indent string
+	// The string used to end lines in the input, or empty if it is "\n".
+	newline    string
+	spans      map[Node]*sourceSpan // dictionary members and list elements
+	containers map[Node]*sourceSpan // lists and dictionaries whose source text can be spliced
+}
+
+// sourceSpan is the source text of a node.
+type sourceSpan struct {
+	start, end int    // byte offsets of the text in the input
+	indent     string // leading whitespace of the line containing start
+	comma      bool   // whether the text includes the comma after the node
+	// The formatted text of the node when it was parsed. Used to
+	// determine if the node has been modified.
+	formatted string
+	// For members, lists, and dictionaries, the parts of the node that are kept
+	// from the source text when only its children are modified, see shell.
+	shell string
+	// For lists and dictionaries, the members or elements when the node was parsed.
+	children []Node
+}
+
+// finish completes the source once the document root has been parsed.
+// This must happen after parsing since nodes are modified by the parser
+// after they are created, ex: to add comments.
+func (s *source) finish(root *DictionaryNode) {
+	s.formatted = formatNode(root)
+	for n, span := range s.spans {
+		span.indent = s.lineIndent(span.start)
+		span.formatted = formatNode(n)
+		if m, ok := n.(*MemberNode); ok {
+			span.shell = shell(m)
+		}
+	}
+	for n, span := range s.containers {
+		if !s.spliceable(n.(ValueNode), span) {
+			delete(s.containers, n)
+			continue
+		}
+		span.indent = s.lineIndent(span.start)
+		span.shell = shell(n)
+		span.children = children(n.(ValueNode))
+	}
+	if strings.Contains(s.input, "\r\n") {
+		s.newline = "\r\n"
+	}
+	// Use the indentation of the first member on its own line
+	for _, m := range root.Members {
+		if span, ok := s.spans[m]; ok && span.indent != "" && s.startsLine(span.start) {
+			s.indent = span.indent
+			break
+		}
+	}
+	root.src = s
+}
+
+// spliceable reports whether the source text of the list or dictionary n can be
+// spliced: all its children must have been recorded in order, and the text
+// between them may only contain whitespace, commas, and comments. This is not the
+// case if the parser dropped or merged children, ex: because of duplicate keys.
+func (s *source) spliceable(n ValueNode, span *sourceSpan) bool {
+	pos := span.start + 1 // skip the opening bracket
+	for _, c := range children(n) {
+		cs, ok := s.spans[c]
+		if !ok || cs.start < pos || !isTrivia(s.input[pos:cs.start]) {
+			return false
+		}
+		pos = cs.end
+	}
+	return pos < span.end && isTrivia(s.input[pos:span.end-1])
+}
+
+// original returns the source text of n if n has not been modified.
+func (s *source) original(n Node) (*sourceSpan, bool) {
+	span, ok := s.spans[n]
+	if !ok || formatNode(n) != span.formatted {
+		return nil, false
+	}
+	return span, true
+}
+
+// text returns the source text of span.
+func (s *source) text(span *sourceSpan) string {
+	return s.input[span.start:span.end]
+}
+
+// unmodified reports whether the document root has not been modified.
+func (s *source) unmodified(root *DictionaryNode) bool {
+	return formatNode(root) == s.formatted
+}
+
+// lineIndent returns the leading whitespace of the line containing the byte offset off.
+func (s *source) lineIndent(off int) string {
+	start := strings.LastIndexByte(s.input[:off], '\n') + 1
+	end := start
+	for end < off && (s.input[end] == ' ' || s.input[end] == '\t') {
+		end++
+	}
+	return s.input[start:end]
+}
+
+// startsLine reports whether the byte offset off is the first non-whitespace
+// character on its line.
+func (s *source) startsLine(off int) bool {
+	return strings.LastIndexByte(s.input[:off], '\n')+1+len(s.lineIndent(off)) == off
+}
+
+// formatNode formats the document, member, or value n using the default
+// format options. It is used to detect changes to n.
+func formatNode(n Node) string {
+	p := newPrinter(FormatOptions{})
+	switch n := n.(type) {
+	case *DictionaryNode:
+		p.format(n)
+	case *MemberNode:
+		p.printMember(n)
+	case ValueNode:
+		p.printValue(n)
+	}
+	p.endLine()
+	return p.String()
+}
+
+// shell describes the parts of the member, list, or dictionary n that are kept
+// from the source text when only its children are modified. For a member this is
+// everything except the value, and for a list or dictionary it is everything except
+// its children, ie. its comments and the comments and blank lines between its
+// children. It is used to detect changes to those parts.
+func shell(n Node) string {
+	switch n := n.(type) {
+	case *MemberNode:
+		p := newPrinter(FormatOptions{})
+		p.printKey(n.Key)
+		return fmt.Sprintf("%s %v %v", p.String(), n.CommentGroup, *n.Key.Comments())
+	case *ListNode, *DictionaryNode:
+		var sb strings.Builder
+		fmt.Fprintf(&sb, "%v", *n.(ValueNode).Comments())
+		for _, c := range children(n.(ValueNode)) {
+			cg := commentGroup(c)
+			fmt.Fprintf(&sb, " %d %v", cg.BlankLinesBefore, cg.Foot)
+		}
+		return sb.String()
+	}
+	return ""
+}
+
+// children returns the members or elements of the dictionary or list n.
+func children(n ValueNode) []Node {
+	var nodes []Node
+	switch n := n.(type) {
+	case *DictionaryNode:
+		for _, m := range n.Members {
+			nodes = append(nodes, m)
+		}
+	case *ListNode:
+		for _, e := range n.Elements {
+			nodes = append(nodes, e)
+		}
+	}
+	return nodes
+}
+
+// commentGroup returns the comments of the member or value n.
+func commentGroup(n Node) *CommentGroup {
+	if m, ok := n.(*MemberNode); ok {
+		return &m.CommentGroup
+	}
+	return n.(ValueNode).Comments()
+}
+
+// isTrivia reports whether s only contains whitespace, commas, and comments.
+func isTrivia(s string) bool {
+	for s != "" {
+		switch {
+		case strings.HasPrefix(s, "//"):
+			i := strings.IndexByte(s, '\n')
+			if i < 0 {
+				return true
+			}
+			s = s[i:]
+		case strings.HasPrefix(s, "/*"):
+			i := strings.Index(s[2:], "*/")
+			if i < 0 {
+				return false
+			}
+			s = s[i+4:]
+		case strings.IndexByte(" \t\r\n,", s[0]) >= 0:
+			s = s[1:]
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,136 @@
+// Copyright (c) 2021 the SC authors. All rights reserved. MIT License.
+
+package scparse
+
+import (
+	"strings"
+	"testing"
+)
+
+const preserveInput = `// Service config
+{
+	name:   "foo", version: 1.0  // trailing
+	tags: [ "a",   ` + "`b`" + `,
+		/* block */ "c" ]
+
+	server: {
+		host: "localhost" ,
+		port:    8080
+	}
+}
+// The end
+`
+
+func TestPreserveSourceUnmodified(t *testing.T) {
+	n, err := Parse([]byte(preserveInput), WithPreserveSource(true))
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	if got := string(Format(n)); got != preserveInput {
+		t.Errorf("got formatted SC\n%s\nwant\n%s", got, preserveInput)
+	}
+}
+
+func TestPreserveSourceModified(t *testing.T) {
+	n, err := Parse([]byte(preserveInput), WithPreserveSource(true))
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	server := n.Members[3].Value.(*DictionaryNode)
+	server.Members[1].Value = &NumberNode{IsInt: true, Int64: 9090}
+	got := string(Format(n))
+	// Only the modified member is reprinted
+	want := strings.Replace(preserveInput, "port:    8080", "port: 9090", 1)
+	if got != want {
+		t.Errorf("got formatted SC\n%s\nwant\n%s", got, want)
+	}
+	// The output should be equivalent to formatting normally
+	m, err := Parse([]byte(got))
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	if ok, diff := deepEqual(Canonicalize(m), Canonicalize(n)); !ok {
+		t.Errorf("ASTs not equal:\n%s", diff)
+	}
+}
+
+var preserveIndentInputs = []struct {
+	name  string
+	input string
+}{
+	{"preserve input", preserveInput},
+	{"four spaces", "{\n    name: \"foo\",\n    server: {\n        host: \"localhost\" // local\n        ports: [\n            80,\n            443\n        ]\n    }\n\n    // Extra\n    tags: [ \"a\", \"b\" ],\n}\n"},
+	{"tabs", "{\n\tname: \"foo\"\n\tserver: {\n\t\thost: \"localhost\" // local\n\t\tports: [\n\t\t\t80\n\t\t\t443\n\t\t]\n\t}\n\n\t// Extra\n\ttags: [\"a\", \"b\"]\n}\n"},
+	{"crlf", "{\r\n  a: 1, // one\r\n  b: {\r\n    c: [] /* empty */\r\n  }\r\n}\r\n"},
+	{"multi-line string", "{\n    a: \"\"\"\n        foo\n          bar\n        \"\"\"\n    b: 1\n}"},
+}
+
+func TestPreserveSourceSplice(t *testing.T) {
+	// Splicing the source of an unmodified document must return the input,
+	// Format only does this directly as an optimization.
+	for _, tt := range preserveIndentInputs {
+		t.Run(tt.name, func(t *testing.T) {
+			n, err := Parse([]byte(tt.input), WithPreserveSource(true))
+			if err != nil {
+				t.Fatalf("unexpected error %s", err)
+			}
+			p := newPrinter(FormatOptions{})
+			p.src = n.src
+			if !p.printSource(n) {
+				t.Fatalf("want source to be spliced")
+			}
+			if got := p.String(); got != tt.input {
+				t.Errorf("got formatted SC\n%q\nwant\n%q", got, tt.input)
+			}
+		})
+	}
+}
+
+func TestPreserveSourceStable(t *testing.T) {
+	// Removing a member causes its parent to be reprinted,
+	// the members that keep their source must keep their indentation.
+	for _, tt := range preserveIndentInputs[1:3] {
+		t.Run(tt.name, func(t *testing.T) {
+			n, err := Parse([]byte(tt.input), WithPreserveSource(true))
+			if err != nil {
+				t.Fatalf("unexpected error %s", err)
+			}
+			n.Delete("name")
+			got := string(Format(n))
+			// The optional commas are lost since the document is reprinted
+			want := strings.NewReplacer("    name: \"foo\",\n", "", "\tname: \"foo\"\n", "", "],\n}", "]\n}").Replace(tt.input)
+			if got != want {
+				t.Errorf("got formatted SC\n%s\nwant\n%s", got, want)
+			}
+		})
+	}
+}
+
+func TestPreserveSourceReindent(t *testing.T) {
+	n, err := Parse([]byte(preserveIndentInputs[1].input), WithPreserveSource(true))
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	// Move a member to a different depth, its source text must be reindented
+	server := n.Members[1].Value.(*DictionaryNode)
+	n.Members = append(n.Members, server.Members[1])
+	server.Members = server.Members[:1]
+	got := string(Format(n))
+	want := `{
+    name: "foo"
+    server: {
+        host: "localhost" // local
+    }
+
+    // Extra
+    tags: [ "a", "b" ]
+    ports: [
+        80,
+        443
+    ]
+}
+`
+	if got != want {
+		t.Errorf("got formatted SC\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,36 @@
+// Copyright (c) 2021 the SC authors. All rights reserved. MIT License.
+
+package scparse
+
+// VariableRef describes a variable that is referenced in an SC document.
+type VariableRef struct {
+	Name string // The name of the variable.
+	// The positions of each reference to the variable in the order they
+	// appear in the input text.
+	Positions []Pos
+}
+
+// ListVariables returns all variables referenced by n and its children.
+// This includes variables interpolated in strings and variables used as
+// function call arguments or expression operands. Function calls themselves are not variables.
+// The variables are returned in the order they are first referenced.
+func ListVariables(n Node) []VariableRef {
+	var refs []VariableRef
+	index := make(map[string]int) // index of each variable in refs
+	Inspect(n, func(n Node) bool {
+		vn, ok := n.(*VariableNode)
+		if !ok || vn.IsCall {
+			return true
+		}
+		name := vn.Identifier.Name
+		i, ok := index[name]
+		if !ok {
+			i = len(refs)
+			index[name] = i
+			refs = append(refs, VariableRef{Name: name})
+		}
+		refs[i].Positions = append(refs[i].Positions, vn.Pos)
+		return false
+	})
+	return refs
+}
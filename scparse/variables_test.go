@@ -0,0 +1,26 @@
+// Copyright (c) 2021 the SC authors. All rights reserved. MIT License.
+
+package scparse
+
+import "testing"
+
+func TestListVariables(t *testing.T) {
+	n, err := Parse([]byte(`{
+  user: ${user}
+  path: "/home/${user}/${dir}"
+  list: [${dir}, "${port}"]
+  nested: { port: ${port} }
+}`))
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	got := ListVariables(n)
+	want := []VariableRef{
+		{Name: "user", Positions: []Pos{{2, 9, 10}, {3, 16, 33}}},
+		{Name: "dir", Positions: []Pos{{3, 24, 41}, {4, 10, 58}}},
+		{Name: "port", Positions: []Pos{{4, 19, 67}, {5, 19, 95}}},
+	}
+	if ok, diff := deepEqual(got, want); !ok {
+		t.Errorf("variables not equal:\n%s", diff)
+	}
+}
@@ -0,0 +1,85 @@
+// Copyright (c) 2021 the SC authors. All rights reserved. MIT License.
+
+package scparse
+
+import "fmt"
+
+// A Visitor's Visit method is invoked for each node encountered by Walk.
+// If the result visitor w is not nil, Walk visits each of the children
+// of node with the visitor w, followed by a call of w.Visit(nil).
+type Visitor interface {
+	Visit(n Node) (w Visitor)
+}
+
+// Walk traverses an AST in depth-first order: It starts by calling v.Visit(n);
+// n must not be nil. If the visitor w returned by v.Visit(n) is not nil,
+// Walk is invoked recursively with visitor w for each of the non-nil children
+// of n, followed by a call of w.Visit(nil).
+//
+// The children of a node are visited in the order they appear in the source.
+// The children of a MemberNode are its key and value, and the children of a
+// VariableNode are its identifier, arguments, and default value. The children of an
+// AnchorNode are its name and value, and the child of an AliasNode is its name.
+// The children of an ExpressionNode are its operands.
+func Walk(n Node, v Visitor) {
+	if v = v.Visit(n); v == nil {
+		return
+	}
+
+	switch n := n.(type) {
+	case *NullNode, *BoolNode, *NumberNode, *StringNode, *RawStringNode, *MultilineStringNode, *IdentifierNode:
+		// No children
+	case *VariableNode:
+		Walk(n.Identifier, v)
+		for _, a := range n.Args {
+			Walk(a, v)
+		}
+		if n.Default != nil {
+			Walk(n.Default, v)
+		}
+	case *AnchorNode:
+		Walk(n.Name, v)
+		Walk(n.Value, v)
+	case *AliasNode:
+		Walk(n.Name, v)
+	case *ExpressionNode:
+		for _, o := range n.Operands {
+			Walk(o, v)
+		}
+	case *InterpolatedStringNode:
+		for _, c := range n.Components {
+			Walk(c, v)
+		}
+	case *ListNode:
+		for _, e := range n.Elements {
+			Walk(e, v)
+		}
+	case *MemberNode:
+		Walk(n.Key, v)
+		Walk(n.Value, v)
+	case *DictionaryNode:
+		for _, m := range n.Members {
+			Walk(m, v)
+		}
+	default:
+		panic(fmt.Errorf("scparse.Walk: unexpected node type %T", n))
+	}
+
+	v.Visit(nil)
+}
+
+type inspector func(Node) bool
+
+func (f inspector) Visit(n Node) Visitor {
+	if f(n) {
+		return f
+	}
+	return nil
+}
+
+// Inspect traverses an AST in depth-first order: It starts by calling f(n);
+// n must not be nil. If f returns true, Inspect invokes f recursively for
+// each of the non-nil children of n, followed by a call of f(nil).
+func Inspect(n Node, f func(Node) bool) {
+	Walk(n, inspector(f))
+}
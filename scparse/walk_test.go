@@ -0,0 +1,72 @@
+// Copyright (c) 2021 the SC authors. All rights reserved. MIT License.
+
+package scparse
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestInspect(t *testing.T) {
+	n, err := Parse([]byte(`{
+  name: "foo-${env:-dev}"
+  list: [1, null]
+  skip: { nested: true }
+}`))
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	var visited []string
+	Inspect(n, func(n Node) bool {
+		if n == nil {
+			visited = append(visited, "end")
+			return false
+		}
+		visited = append(visited, n.Type().String())
+		// Don't descend into the skipped member
+		if mn, ok := n.(*MemberNode); ok && mn.Key.KeyString() == "skip" {
+			return false
+		}
+		return true
+	})
+	got := strings.Join(visited, " ")
+	want := "Dictionary " +
+		"Member Identifier end InterpolatedString String end Variable Identifier end String end end end end " +
+		"Member Identifier end List Number end Null end end end " +
+		"Member " +
+		"end"
+	if got != want {
+		t.Errorf("got visited nodes\n%s\nwant\n%s", got, want)
+	}
+}
+
+type countVisitor map[string]int
+
+func (c countVisitor) Visit(n Node) Visitor {
+	if n != nil {
+		c[fmt.Sprintf("%T", n)]++
+	}
+	return c
+}
+
+func TestWalk(t *testing.T) {
+	n, err := Parse([]byte(`{ a: [1, 2, { b: 3 }], c: "${d}" }`))
+	if err != nil {
+		t.Fatalf("unexpected error %s", err)
+	}
+	c := countVisitor{}
+	Walk(n, c)
+	want := countVisitor{
+		"*scparse.DictionaryNode":         2,
+		"*scparse.MemberNode":             3,
+		"*scparse.IdentifierNode":         4,
+		"*scparse.ListNode":               1,
+		"*scparse.NumberNode":             3,
+		"*scparse.InterpolatedStringNode": 1,
+		"*scparse.VariableNode":           1,
+	}
+	if ok, diff := deepEqual(c, want); !ok {
+		t.Errorf("counts not equal:\n%s", diff)
+	}
+}
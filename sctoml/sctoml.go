@@ -0,0 +1,551 @@
+// Copyright (c) 2021 the SC authors. All rights reserved. MIT License.
+
+// Package sctoml converts SC documents to TOML and TOML documents to SC.
+//
+// Tables, including tables defined using dotted keys, are converted to dictionaries
+// and arrays of tables are converted to lists of dictionaries. The order of keys is
+// preserved in both directions.
+package sctoml
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/sc-lang/go-sc/internal/sccomment"
+	"github.com/sc-lang/go-sc/scparse"
+)
+
+// Option is an option that customizes the conversion performed by ToTOML and FromTOML.
+type Option func(*options)
+
+type options struct {
+	sortKeys bool
+}
+
+// WithSortedKeys sets whether the keys of dictionaries and tables are sorted.
+// By default, keys are kept in the order they appear in the input.
+func WithSortedKeys(b bool) Option {
+	return func(o *options) {
+		o.sortKeys = b
+	}
+}
+
+func newOptions(opts []Option) options {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// ToTOML converts the SC document data to TOML.
+//
+// Dictionaries are written as tables and lists that only contain dictionaries are
+// written as arrays of tables. Dictionaries inside other lists are written as inline
+// tables. Since TOML requires the keys of a table to come before its sub-tables,
+// dictionary members are moved after the other members of their dictionary.
+// Comments are kept, except for comments inside inline arrays and tables.
+//
+// Variables and expressions are replaced by their default values the same way as
+// scparse.ToGo, so a variable without a default value causes an
+// *scparse.UnresolvedVariableError to be returned. Anchors and aliases are expanded.
+// TOML has no null value, so an error is returned if the document contains null.
+func ToTOML(data []byte, opts ...Option) ([]byte, error) {
+	n, err := scparse.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	return NodeToTOML(n, opts...)
+}
+
+// NodeToTOML is like ToTOML but converts the SC dictionary n.
+func NodeToTOML(n *scparse.DictionaryNode, opts ...Option) ([]byte, error) {
+	v, err := scparse.ExpandAnchors(n)
+	if err != nil {
+		return nil, err
+	}
+	n = v.(*scparse.DictionaryNode)
+	e := encoder{opts: newOptions(opts)}
+	e.writeComments(n.Comments().Head)
+	if len(n.Comments().Head) > 0 {
+		e.buf.WriteByte('\n')
+	}
+	if err := e.encodeTable(n, nil); err != nil {
+		return nil, err
+	}
+	e.writeComments(n.Comments().Inner)
+	if foot := n.Comments().Foot; len(foot) > 0 {
+		e.separate()
+		e.writeComments(foot)
+	}
+	return e.buf.Bytes(), nil
+}
+
+// encoder writes TOML for an SC AST.
+type encoder struct {
+	buf  bytes.Buffer
+	opts options
+}
+
+// member kinds, in the order they must be written in a table
+const (
+	kindValue = iota
+	kindTable
+	kindArrayOfTables
+)
+
+func memberKind(n scparse.ValueNode) int {
+	switch n := n.(type) {
+	case *scparse.DictionaryNode:
+		return kindTable
+	case *scparse.ListNode:
+		if len(n.Elements) == 0 {
+			return kindValue
+		}
+		for _, el := range n.Elements {
+			if _, ok := el.(*scparse.DictionaryNode); !ok {
+				return kindValue
+			}
+		}
+		return kindArrayOfTables
+	}
+	return kindValue
+}
+
+// encodeTable writes the members of the table at path. The table header has already been written.
+func (e *encoder) encodeTable(n *scparse.DictionaryNode, path []string) error {
+	members := n.Members
+	if e.opts.sortKeys {
+		members = append([]*scparse.MemberNode(nil), members...)
+		sort.SliceStable(members, func(i, j int) bool {
+			return members[i].Key.KeyString() < members[j].Key.KeyString()
+		})
+	}
+	// Keep the order of members of the same kind
+	sort.SliceStable(members, func(i, j int) bool {
+		return memberKind(members[i].Value) < memberKind(members[j].Value)
+	})
+	for _, m := range members {
+		key := m.Key.KeyString()
+		mpath := append(path[:len(path):len(path)], key)
+		head := sccomment.Concat(m.Comments().Head, m.Key.Comments().Head, m.Value.Comments().Head)
+		inline := sccomment.Concat(m.Comments().Inline, m.Key.Comments().Inline, m.Value.Comments().Inline)
+		switch memberKind(m.Value) {
+		case kindValue:
+			e.writeComments(head)
+			e.writeKey([]string{key})
+			e.buf.WriteString(" = ")
+			if err := e.encodeValue(m.Value, mpath); err != nil {
+				return err
+			}
+			e.writeInlineComments(inline)
+			e.buf.WriteByte('\n')
+		case kindTable:
+			d := m.Value.(*scparse.DictionaryNode)
+			// The header of a table that only contains tables can be omitted
+			if len(d.Members) == 0 || len(head) > 0 || len(inline) > 0 || len(d.Comments().Inner) > 0 || hasValues(d) {
+				e.separate()
+				e.writeComments(head)
+				e.buf.WriteByte('[')
+				e.writeKey(mpath)
+				e.buf.WriteByte(']')
+				e.writeInlineComments(inline)
+				e.buf.WriteByte('\n')
+				e.writeComments(d.Comments().Inner)
+			}
+			if err := e.encodeTable(d, mpath); err != nil {
+				return err
+			}
+		case kindArrayOfTables:
+			e.writeComments(head)
+			for _, el := range m.Value.(*scparse.ListNode).Elements {
+				d := el.(*scparse.DictionaryNode)
+				e.separate()
+				e.writeComments(d.Comments().Head)
+				e.buf.WriteString("[[")
+				e.writeKey(mpath)
+				e.buf.WriteString("]]")
+				e.writeInlineComments(d.Comments().Inline)
+				e.buf.WriteByte('\n')
+				e.writeComments(d.Comments().Inner)
+				if err := e.encodeTable(d, mpath); err != nil {
+					return err
+				}
+				e.writeComments(d.Comments().Foot)
+			}
+		}
+		e.writeComments(m.Comments().Foot)
+	}
+	return nil
+}
+
+// hasValues reports whether n has members that are written as key/value pairs.
+func hasValues(n *scparse.DictionaryNode) bool {
+	for _, m := range n.Members {
+		if memberKind(m.Value) == kindValue {
+			return true
+		}
+	}
+	return false
+}
+
+// encodeValue writes n as an inline TOML value. path is the key of n, used for errors.
+func (e *encoder) encodeValue(n scparse.ValueNode, path []string) error {
+	switch n := n.(type) {
+	case *scparse.NullNode:
+		return nullError(path)
+	case *scparse.BoolNode:
+		e.buf.WriteString(strconv.FormatBool(n.True))
+	case *scparse.NumberNode:
+		if !n.IsInt && !isFloat(n) {
+			return fmt.Errorf("sctoml: cannot convert %s: %d is out of range for a TOML integer", formatKey(path), n.Uint64)
+		}
+		e.buf.WriteString(tomlNumber(n))
+	case *scparse.RawStringNode:
+		e.writeString(n.Value)
+	case *scparse.MultilineStringNode:
+		e.writeMultilineString(n.Value)
+	case *scparse.InterpolatedStringNode, *scparse.VariableNode, *scparse.ExpressionNode:
+		v, err := scparse.ToGo(n)
+		if err != nil {
+			return err
+		}
+		switch v := v.(type) {
+		case nil:
+			return nullError(path)
+		case bool:
+			e.buf.WriteString(strconv.FormatBool(v))
+		case string:
+			e.writeString(v)
+		default:
+			panic(fmt.Errorf("impossible: unexpected value of type %T for %T", v, n))
+		}
+	case *scparse.ListNode:
+		e.buf.WriteByte('[')
+		for i, el := range n.Elements {
+			if i > 0 {
+				e.buf.WriteString(", ")
+			}
+			if err := e.encodeValue(el, path); err != nil {
+				return err
+			}
+		}
+		e.buf.WriteByte(']')
+	case *scparse.DictionaryNode:
+		if len(n.Members) == 0 {
+			e.buf.WriteString("{}")
+			break
+		}
+		e.buf.WriteString("{ ")
+		for i, m := range n.Members {
+			if i > 0 {
+				e.buf.WriteString(", ")
+			}
+			key := m.Key.KeyString()
+			e.writeKey([]string{key})
+			e.buf.WriteString(" = ")
+			if err := e.encodeValue(m.Value, append(path[:len(path):len(path)], key)); err != nil {
+				return err
+			}
+		}
+		e.buf.WriteString(" }")
+	default:
+		panic(fmt.Errorf("impossible: invalid node type used as value: %T", n))
+	}
+	return nil
+}
+
+func nullError(path []string) error {
+	return fmt.Errorf("sctoml: cannot convert %s: TOML has no null value", formatKey(path))
+}
+
+func formatKey(path []string) string {
+	var e encoder
+	e.writeKey(path)
+	return e.buf.String()
+}
+
+// separate writes a blank line before a table header, unless it is the start of the output.
+func (e *encoder) separate() {
+	if b := e.buf.Bytes(); len(b) > 0 && !bytes.HasSuffix(b, []byte("\n\n")) {
+		e.buf.WriteByte('\n')
+	}
+}
+
+// writeComments writes each comment on its own line.
+// Each line of a block comment becomes a separate line comment.
+func (e *encoder) writeComments(comments []scparse.Comment) {
+	for _, c := range comments {
+		for _, l := range commentLines(c) {
+			e.buf.WriteString(l)
+			e.buf.WriteByte('\n')
+		}
+	}
+}
+
+func (e *encoder) writeInlineComments(comments []scparse.Comment) {
+	for _, c := range comments {
+		for _, l := range commentLines(c) {
+			e.buf.WriteByte(' ')
+			e.buf.WriteString(l)
+		}
+	}
+}
+
+func commentLines(c scparse.Comment) []string {
+	if !c.IsBlock {
+		return []string{"#" + c.Text}
+	}
+	var lines []string
+	for _, l := range strings.Split(c.Text, "\n") {
+		lines = append(lines, "#"+strings.TrimRight(l, " \t\r"))
+	}
+	return lines
+}
+
+// writeKey writes the dotted key path. Keys are quoted if they are not bare keys.
+func (e *encoder) writeKey(path []string) {
+	for i, k := range path {
+		if i > 0 {
+			e.buf.WriteByte('.')
+		}
+		if isBareKey(k) {
+			e.buf.WriteString(k)
+		} else {
+			e.writeString(k)
+		}
+	}
+}
+
+func isBareKey(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if !('a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9' || r == '_' || r == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+// writeString writes s as a TOML basic string.
+func (e *encoder) writeString(s string) {
+	e.buf.WriteByte('"')
+	e.writeEscaped(s, false)
+	e.buf.WriteByte('"')
+}
+
+// writeMultilineString writes s as a TOML multi-line basic string.
+func (e *encoder) writeMultilineString(s string) {
+	// The newline after the opening quotes is trimmed by TOML
+	e.buf.WriteString("\"\"\"\n")
+	e.writeEscaped(s, true)
+	e.buf.WriteString(`"""`)
+}
+
+func (e *encoder) writeEscaped(s string, multiline bool) {
+	for _, r := range s {
+		switch {
+		case r == '"' || r == '\\':
+			e.buf.WriteByte('\\')
+			e.buf.WriteRune(r)
+		case r == '\n' && multiline:
+			e.buf.WriteByte('\n')
+		case r == '\n':
+			e.buf.WriteString(`\n`)
+		case r == '\t':
+			e.buf.WriteString(`\t`)
+		case r == '\r':
+			e.buf.WriteString(`\r`)
+		case r < ' ' || r == 0x7f:
+			fmt.Fprintf(&e.buf, `\u%04X`, r)
+		default:
+			e.buf.WriteRune(r)
+		}
+	}
+}
+
+// isFloat reports whether n should be a float in TOML.
+// A literal like 1.0 is also an integer but should stay a float.
+func isFloat(n *scparse.NumberNode) bool {
+	if n.Raw != "" {
+		return strings.ContainsAny(n.Raw, ".eE")
+	}
+	return !n.IsInt && !n.IsUint
+}
+
+// tomlNumber returns the TOML representation of n. The literal is used if TOML
+// reads it as the same number, otherwise it is formatted from the value of n.
+// n must not be an integer that is out of range for int64.
+func tomlNumber(n *scparse.NumberNode) string {
+	isFloat := isFloat(n)
+	if n.Raw != "" {
+		var probe map[string]interface{}
+		if _, err := toml.Decode("n = "+n.Raw, &probe); err == nil {
+			switch v := probe["n"].(type) {
+			case int64:
+				if !isFloat && n.IsInt && v == n.Int64 {
+					return n.Raw
+				}
+			case float64:
+				if isFloat && v == n.Float64 {
+					return n.Raw
+				}
+			}
+		}
+	}
+	if !isFloat {
+		return strconv.FormatInt(n.Int64, 10)
+	}
+	s := strconv.FormatFloat(n.Float64, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".e") {
+		// Keep it a float
+		s += ".0"
+	}
+	return s
+}
+
+// FromTOML converts the TOML document data to SC. The result is formatted using scparse.Format.
+//
+// Strings are converted literally, ex: "${HOME}" is not converted to a variable.
+// Dates and times are converted to strings in RFC 3339 format. Comments are not kept
+// and numbers are formatted from their values, ex: 0x1F is converted to 31.
+func FromTOML(data []byte, opts ...Option) ([]byte, error) {
+	n, err := TOMLToNode(data, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return scparse.Format(n), nil
+}
+
+// TOMLToNode is like FromTOML but returns the SC AST instead of formatting it.
+func TOMLToNode(data []byte, opts ...Option) (*scparse.DictionaryNode, error) {
+	var m map[string]interface{}
+	md, err := toml.Decode(string(data), &m)
+	if err != nil {
+		return nil, fmt.Errorf("sctoml: invalid TOML: %w", err)
+	}
+	d := decoder{opts: newOptions(opts), order: make(map[string]int)}
+	for i, k := range md.Keys() {
+		// Tables defined by dotted keys, ex: a.b = 1, are not in the keys
+		for j := 1; j <= len(k); j++ {
+			if _, ok := d.order[k[:j].String()]; !ok {
+				d.order[k[:j].String()] = i
+			}
+		}
+	}
+	v, err := d.decode(m, nil)
+	if err != nil {
+		return nil, err
+	}
+	return v.(*scparse.DictionaryNode), nil
+}
+
+// decoder converts decoded TOML values to an SC AST.
+type decoder struct {
+	opts options
+	// order is the index of the first occurrence of each key in the document.
+	order map[string]int
+}
+
+func (d *decoder) decode(v interface{}, path toml.Key) (scparse.ValueNode, error) {
+	switch v := v.(type) {
+	case string:
+		return scparse.NewString(v), nil
+	case bool:
+		return scparse.NewBool(v), nil
+	case int64:
+		return scparse.NewInt(v), nil
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return nil, fmt.Errorf("sctoml: cannot convert %s: %v cannot be represented in SC", path, v)
+		}
+		s := strconv.FormatFloat(v, 'g', -1, 64)
+		if !strings.ContainsAny(s, ".e") {
+			// Keep it a float
+			s += ".0"
+		}
+		return scparse.NewNumber(s)
+	case time.Time:
+		return scparse.NewString(formatTime(v)), nil
+	case map[string]interface{}:
+		return d.decodeTable(v, path)
+	case []map[string]interface{}:
+		l := scparse.NewList()
+		for _, t := range v {
+			el, err := d.decodeTable(t, path)
+			if err != nil {
+				return nil, err
+			}
+			l.Elements = append(l.Elements, el)
+		}
+		return l, nil
+	case []interface{}:
+		l := scparse.NewList()
+		for _, x := range v {
+			el, err := d.decode(x, path)
+			if err != nil {
+				return nil, err
+			}
+			l.Elements = append(l.Elements, el)
+		}
+		return l, nil
+	}
+	panic(fmt.Errorf("impossible: unexpected TOML value of type %s", reflect.TypeOf(v)))
+}
+
+func (d *decoder) decodeTable(t map[string]interface{}, path toml.Key) (*scparse.DictionaryNode, error) {
+	keys := make([]string, 0, len(t))
+	for k := range t {
+		keys = append(keys, k)
+	}
+	if d.opts.sortKeys {
+		sort.Strings(keys)
+	} else {
+		// Keys missing from the metadata, like keys of inline tables in arrays, are sorted last
+		index := func(k string) int {
+			if i, ok := d.order[append(path[:len(path):len(path)], k).String()]; ok {
+				return i
+			}
+			return math.MaxInt32
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			ii, ij := index(keys[i]), index(keys[j])
+			if ii != ij {
+				return ii < ij
+			}
+			return keys[i] < keys[j]
+		})
+	}
+	n := scparse.NewDict()
+	for _, k := range keys {
+		v, err := d.decode(t[k], append(path[:len(path):len(path)], k))
+		if err != nil {
+			return nil, err
+		}
+		n.Members = append(n.Members, scparse.NewMember(k, v))
+	}
+	return n, nil
+}
+
+// formatTime formats t using the RFC 3339 format that matches the TOML type it was decoded from.
+func formatTime(t time.Time) string {
+	// The TOML decoder uses these time zones for local dates and times
+	switch t.Location().String() {
+	case "datetime-local":
+		return t.Format("2006-01-02T15:04:05.999999999")
+	case "date-local":
+		return t.Format("2006-01-02")
+	case "time-local":
+		return t.Format("15:04:05.999999999")
+	}
+	return t.Format(time.RFC3339Nano)
+}
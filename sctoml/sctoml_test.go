@@ -0,0 +1,231 @@
+// Copyright (c) 2021 the SC authors. All rights reserved. MIT License.
+
+package sctoml_test
+
+import (
+	"testing"
+
+	"github.com/sc-lang/go-sc/scparse"
+	"github.com/sc-lang/go-sc/sctoml"
+)
+
+func TestFromTOML(t *testing.T) {
+	input := `title = "Example"
+owner.name = "Tom"
+owner.dob = 1979-05-27T07:32:00-08:00
+release = 1979-05-27
+
+[database]
+ports = [8000, 8001]
+ratio = 1.0
+mask = 0x1F
+limits = { memory = 512, cpu = 2 }
+
+[[fruits]]
+name = "apple"
+[fruits.physical]
+color = "red"
+
+[[fruits]]
+name = "banana"
+`
+	tests := []struct {
+		name string
+		opts []sctoml.Option
+		want string
+	}{
+		{
+			name: "document order",
+			want: `{
+  title: "Example"
+  owner: { name: "Tom", dob: "1979-05-27T07:32:00-08:00" }
+  release: "1979-05-27"
+  database: {
+    ports: [8000, 8001]
+    ratio: 1.0
+    mask: 31
+    limits: { memory: 512, cpu: 2 }
+  }
+  fruits: [{ name: "apple", physical: { color: "red" } }, { name: "banana" }]
+}
+`,
+		},
+		{
+			name: "sorted keys",
+			opts: []sctoml.Option{sctoml.WithSortedKeys(true)},
+			want: `{
+  database: {
+    limits: { cpu: 2, memory: 512 }
+    mask: 31
+    ports: [8000, 8001]
+    ratio: 1.0
+  }
+  fruits: [{ name: "apple", physical: { color: "red" } }, { name: "banana" }]
+  owner: { dob: "1979-05-27T07:32:00-08:00", name: "Tom" }
+  release: "1979-05-27"
+  title: "Example"
+}
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n, err := sctoml.TOMLToNode([]byte(input), tt.opts...)
+			if err != nil {
+				t.Fatalf("unexpected error %s", err)
+			}
+			got := scparse.FormatWithOptions(n, scparse.FormatOptions{LineWidth: 80})
+			if string(got) != tt.want {
+				t.Errorf("got SC\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFromTOMLError(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"syntax error", "a = \n", "sctoml: invalid TOML: toml: line 2 (last key \"a\"): expected value but found '\\n' instead"},
+		{"infinity", "a.b = inf\n", "sctoml: cannot convert a.b: +Inf cannot be represented in SC"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := sctoml.FromTOML([]byte(tt.input))
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("got error %v, want %s", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestToTOML(t *testing.T) {
+	input := `// Service config
+{
+  // The name
+  name: "api" // inline
+  server: {
+    http: { port: ${port:-8080} }
+  }
+  ratio: 1.0
+  padded: 007
+  points: [{ x: 1 }, 2]
+  workers: [
+    // first
+    { name: "a", limits: { cpu: 2 } }
+    { name: "b" }
+  ]
+  text: """
+    line "1"
+    line 2
+    """
+  "key with space": true
+  // foot
+}`
+	tests := []struct {
+		name string
+		opts []sctoml.Option
+		want string
+	}{
+		{
+			name: "document order",
+			want: `# Service config
+
+# The name
+name = "api" # inline
+ratio = 1.0
+padded = 7
+points = [{ x = 1 }, 2]
+text = """
+line \"1\"
+line 2"""
+"key with space" = true
+# foot
+
+[server.http]
+port = "8080"
+
+# first
+[[workers]]
+name = "a"
+
+[workers.limits]
+cpu = 2
+
+[[workers]]
+name = "b"
+`,
+		},
+		{
+			name: "sorted keys",
+			opts: []sctoml.Option{sctoml.WithSortedKeys(true)},
+			want: `# Service config
+
+"key with space" = true
+# foot
+# The name
+name = "api" # inline
+padded = 7
+points = [{ x = 1 }, 2]
+ratio = 1.0
+text = """
+line \"1\"
+line 2"""
+
+[server.http]
+port = "8080"
+
+# first
+[[workers]]
+name = "a"
+
+[workers.limits]
+cpu = 2
+
+[[workers]]
+name = "b"
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := sctoml.ToTOML([]byte(input), tt.opts...)
+			if err != nil {
+				t.Fatalf("unexpected error %s", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("got TOML\n%s\nwant\n%s", got, tt.want)
+			}
+			// The output must be valid TOML with the same values
+			n, err := sctoml.TOMLToNode(got)
+			if err != nil {
+				t.Fatalf("unexpected error %s", err)
+			}
+			if len(n.Members) != 8 {
+				t.Errorf("got %d members, want 8", len(n.Members))
+			}
+		})
+	}
+}
+
+func TestToTOMLError(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"null", "{ a: { b: [null] } }", "sctoml: cannot convert a.b: TOML has no null value"},
+		{"null key", `{ "a b": null }`, `sctoml: cannot convert "a b": TOML has no null value`},
+		{"uint", "{ a: 18446744073709551615 }", "sctoml: cannot convert a: 18446744073709551615 is out of range for a TOML integer"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := sctoml.ToTOML([]byte(tt.input))
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("got error %v, want %s", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,375 @@
+// Copyright (c) 2021 the SC authors. All rights reserved. MIT License.
+
+// Package scvet defines an Analyzer that checks Go code that uses the sc package
+// for mistakes that would otherwise only be found at run time.
+//
+// The analyzer reports:
+//
+//   - sc struct tags that are malformed or contain unknown options
+//   - tag options that conflict or have no effect, ex: a name on a remain field,
+//     or the string option on a field that is not a bool or number
+//   - fields of the same struct that use the same key, which causes both fields to be ignored
+//   - UnmarshalSC, MarshalSC, ValidateSC, and MarshalSCComments methods whose signatures
+//     do not match the sc.Unmarshaler, sc.Marshaler, sc.Validator, and sc.CommentMarshaler
+//     interfaces, which causes them to be silently ignored
+//
+// The analyzer can be run with the scvet command (github.com/sc-lang/go-sc/cmd/scvet)
+// or added to any driver built with golang.org/x/tools/go/analysis.
+package scvet
+
+import (
+	"fmt"
+	"go/ast"
+	"go/token"
+	"go/types"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"golang.org/x/tools/go/analysis"
+)
+
+const (
+	scPath      = "github.com/sc-lang/go-sc"
+	scparsePath = scPath + "/scparse"
+)
+
+// Analyzer reports mistakes in sc struct tags and methods.
+var Analyzer = &analysis.Analyzer{
+	Name: "scvet",
+	Doc:  "check sc struct tags and the signatures of sc methods",
+	Run:  run,
+}
+
+// tagName is the name of the struct tag that is checked.
+var tagName string
+
+func init() {
+	Analyzer.Flags.StringVar(&tagName, "tag", "sc", "name of the struct tag to check, see sc.WithTagName")
+}
+
+func run(pass *analysis.Pass) (interface{}, error) {
+	for _, f := range pass.Files {
+		ast.Inspect(f, func(n ast.Node) bool {
+			switch n := n.(type) {
+			case *ast.StructType:
+				checkStruct(pass, n)
+			case *ast.FuncDecl:
+				checkMethod(pass, n)
+			}
+			return true
+		})
+	}
+	return nil, nil
+}
+
+// checkStruct checks the tags of the fields of the struct type st.
+func checkStruct(pass *analysis.Pass, st *ast.StructType) {
+	s, ok := pass.TypesInfo.TypeOf(st).(*types.Struct)
+	if !ok {
+		return
+	}
+	keys := make(map[string]*types.Var)
+	var remain *types.Var
+	i := 0
+	for _, af := range st.Fields.List {
+		n := len(af.Names)
+		if n == 0 {
+			// Embedded field
+			n = 1
+		}
+		for ; n > 0; n-- {
+			v := s.Field(i)
+			raw := s.Tag(i)
+			i++
+			if !v.Exported() && (!v.Embedded() || !isStruct(deref(v.Type()))) {
+				// Unexported fields are ignored, except for embedded structs
+				// since they may have exported fields
+				continue
+			}
+			tag, ok := reflect.StructTag(raw).Lookup(tagName)
+			if !ok {
+				if strings.Contains(raw, tagName+":") {
+					pass.Reportf(af.Tag.Pos(), "malformed struct tag for field %s: the %s tag must have the form %s:\"value\"", v.Name(), tagName, tagName)
+				}
+			}
+			if tag == "-" {
+				continue
+			}
+			pos := v.Pos()
+			if af.Tag != nil {
+				pos = af.Tag.Pos()
+			}
+			name, opts := checkTag(pass, pos, v, tag)
+			switch {
+			case opts["remain"]:
+				if remain != nil {
+					pass.Reportf(pos, "field %s has the remain option but only the first remain field %s is used", v.Name(), remain.Name())
+				} else {
+					remain = v
+				}
+				continue
+			case opts["inline"]:
+				continue
+			case v.Embedded() && name == "" && isStruct(deref(v.Type())):
+				// The fields of embedded structs are promoted
+				continue
+			}
+			if name == "" {
+				name = v.Name()
+			}
+			if prev, ok := keys[name]; ok {
+				pass.Reportf(pos, "field %s uses the key %q which is already used by field %s, both fields will be ignored", v.Name(), name, prev.Name())
+				continue
+			}
+			keys[name] = v
+		}
+	}
+}
+
+// knownOptions contains the tag options understood by the sc package.
+// The format option is followed by =layout, the order option by =n
+// and the asvar option by =name.
+var knownOptions = map[string]bool{
+	"omitempty": true,
+	"omitnil":   true,
+	"omitzero":  true,
+	"string":    true,
+	"required":  true,
+	"remain":    true,
+	"inline":    true,
+	"format":    true,
+	"hex":       true,
+	"base64url": true,
+	"bytelist":  true,
+	"order":     true,
+	"secret":    true,
+	"asvar":     true,
+}
+
+// checkTag checks the sc tag of the field v and returns the name and the options
+// that take effect. Problems are reported at pos.
+func checkTag(pass *analysis.Pass, pos token.Pos, v *types.Var, tag string) (string, map[string]bool) {
+	report := func(format string, args ...interface{}) {
+		pass.Reportf(pos, "field %s: %s", v.Name(), fmt.Sprintf(format, args...))
+	}
+	name, rest := tag, ""
+	if i := strings.Index(tag, ","); i != -1 {
+		name, rest = tag[:i], tag[i+1:]
+	}
+	if name == "-" && rest != "" {
+		// "-," is the way to use "-" as the key, anything else is likely a mistake
+		report("%s tag %s uses the key \"-\", use %s:\"-\" to ignore the field or %s:\"-,\" to use the key", tagName, strconv.Quote(tag), tagName, tagName)
+	}
+	if strings.TrimSpace(name) != name {
+		report("key %q has leading or trailing spaces", name)
+	}
+
+	opts := make(map[string]bool)
+	if rest != "" {
+		for _, o := range strings.Split(rest, ",") {
+			key := o
+			switch {
+			case strings.HasPrefix(o, "format="):
+				key = "format"
+			case strings.HasPrefix(o, "order="):
+				key = "order"
+			case strings.HasPrefix(o, "asvar="):
+				key = "asvar"
+			}
+			switch {
+			case o == "":
+				report("empty option in %s tag %s", tagName, strconv.Quote(tag))
+			case o == "format":
+				report("format option requires a layout, ex: format=2006-01-02")
+			case key == "order" && !isInteger(o[len("order="):]), o == "order":
+				report("order option requires an integer, ex: order=1")
+			case key == "asvar" && !isVariableName(o[len("asvar="):]), o == "asvar":
+				report("asvar option requires a variable name, ex: asvar=port")
+			case o == "-":
+				report("option \"-\" has no effect, use %s:\"-\" to ignore the field", tagName)
+			case !knownOptions[key]:
+				report("unknown option %q", o)
+			case opts[key]:
+				report("duplicate option %q", key)
+			default:
+				opts[key] = true
+			}
+		}
+	}
+
+	t := deref(v.Type())
+	if opts["remain"] {
+		switch {
+		case !isRemainType(v.Type()):
+			report("remain option requires a map with string keys, not %s", typeString(pass, v.Type()))
+			delete(opts, "remain")
+		case name != "":
+			report("key %q is ignored since the field has the remain option", name)
+		}
+	}
+	if opts["inline"] {
+		switch {
+		case !isStruct(t):
+			report("inline option requires a struct, not %s", typeString(pass, v.Type()))
+			delete(opts, "inline")
+		case opts["remain"]:
+			report("remain and inline options conflict")
+		case name != "":
+			report("key %q is ignored since the field has the inline option", name)
+		}
+	}
+	if opts["omitnil"] && !isNilable(v.Type()) {
+		report("omitnil option only applies to pointers, interfaces, maps and slices, not %s", typeString(pass, v.Type()))
+	}
+	if opts["string"] && !isQuotable(t) {
+		report("string option only applies to bools and numbers, not %s", typeString(pass, v.Type()))
+	}
+	if opts["format"] && !isTime(t) {
+		report("format option only applies to time.Time, not %s", typeString(pass, v.Type()))
+	}
+	var encodings []string
+	for _, o := range []string{"hex", "base64url", "bytelist"} {
+		if opts[o] {
+			encodings = append(encodings, o)
+		}
+	}
+	switch {
+	case len(encodings) > 1:
+		report("options %s conflict", strings.Join(encodings, " and "))
+	case len(encodings) == 1 && !isByteSlice(t):
+		report("%s option only applies to []byte, not %s", encodings[0], typeString(pass, v.Type()))
+	}
+	return name, opts
+}
+
+// signatures contains the expected signatures of the methods of the sc interfaces.
+// Types are qualified by their package path.
+var signatures = map[string]string{
+	"UnmarshalSC":       "func(" + scparsePath + ".ValueNode, " + scPath + ".Variables) error",
+	"MarshalSC":         "func() (" + scparsePath + ".ValueNode, error)",
+	"ValidateSC":        "func() error",
+	"MarshalSCComments": "func() " + scparsePath + ".CommentGroup",
+}
+
+// checkMethod checks that fn has the right signature if it is a method
+// of one of the sc interfaces.
+func checkMethod(pass *analysis.Pass, fn *ast.FuncDecl) {
+	if fn.Recv == nil {
+		return
+	}
+	want, ok := signatures[fn.Name.Name]
+	if !ok {
+		return
+	}
+	obj, ok := pass.TypesInfo.Defs[fn.Name].(*types.Func)
+	if !ok {
+		return
+	}
+	sig := obj.Type().(*types.Signature)
+	if got := signatureString(sig, nil); got != want {
+		pass.Reportf(fn.Name.Pos(), "method %s%s should have signature %s%s",
+			fn.Name.Name, strings.TrimPrefix(signatureString(sig, types.RelativeTo(pass.Pkg)), "func"),
+			fn.Name.Name, strings.TrimPrefix(shortSignature(want), "func"))
+	}
+}
+
+// signatureString returns sig without parameter names, using qf to qualify types.
+func signatureString(sig *types.Signature, qf types.Qualifier) string {
+	// Removing the receiver and parameter names gives a canonical representation
+	params := make([]*types.Var, sig.Params().Len())
+	for i := range params {
+		params[i] = types.NewParam(token.NoPos, nil, "", sig.Params().At(i).Type())
+	}
+	results := make([]*types.Var, sig.Results().Len())
+	for i := range results {
+		results[i] = types.NewParam(token.NoPos, nil, "", sig.Results().At(i).Type())
+	}
+	s := types.NewSignature(nil, types.NewTuple(params...), types.NewTuple(results...), sig.Variadic())
+	return types.TypeString(s, qf)
+}
+
+// shortSignature replaces the package paths in a signature from signatures with package names.
+func shortSignature(sig string) string {
+	sig = strings.ReplaceAll(sig, scparsePath+".", "scparse.")
+	return strings.ReplaceAll(sig, scPath+".", "sc.")
+}
+
+func typeString(pass *analysis.Pass, t types.Type) string {
+	return types.TypeString(t, types.RelativeTo(pass.Pkg))
+}
+
+// deref returns the element type of t if it is a pointer.
+func deref(t types.Type) types.Type {
+	if p, ok := t.(*types.Pointer); ok {
+		return p.Elem()
+	}
+	return t
+}
+
+func isStruct(t types.Type) bool {
+	_, ok := t.Underlying().(*types.Struct)
+	return ok
+}
+
+// isRemainType reports whether t can be used for a field with the remain option.
+func isRemainType(t types.Type) bool {
+	m, ok := t.Underlying().(*types.Map)
+	if !ok {
+		return false
+	}
+	b, ok := m.Key().Underlying().(*types.Basic)
+	return ok && b.Kind() == types.String
+}
+
+// isInteger reports whether s is a valid value for the order option.
+func isInteger(s string) bool {
+	_, err := strconv.Atoi(s)
+	return err == nil
+}
+
+// isVariableName reports whether s is a valid value for the asvar option.
+// Like variables in SC, it may be a path like server.port.
+func isVariableName(s string) bool {
+	for _, part := range strings.Split(s, ".") {
+		if part == "" {
+			return false
+		}
+		for i, r := range part {
+			if r != '_' && !unicode.IsLetter(r) && (i == 0 || !unicode.IsDigit(r)) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// isNilable reports whether values of type t can be nil for the omitnil option.
+func isNilable(t types.Type) bool {
+	switch t.Underlying().(type) {
+	case *types.Pointer, *types.Interface, *types.Map, *types.Slice:
+		return true
+	}
+	return false
+}
+
+// isQuotable reports whether t can be used with the string option.
+func isQuotable(t types.Type) bool {
+	b, ok := t.Underlying().(*types.Basic)
+	return ok && b.Info()&(types.IsBoolean|types.IsInteger|types.IsFloat) != 0
+}
+
+func isTime(t types.Type) bool {
+	n, ok := t.(*types.Named)
+	return ok && n.Obj().Pkg() != nil && n.Obj().Pkg().Path() == "time" && n.Obj().Name() == "Time"
+}
+
+func isByteSlice(t types.Type) bool {
+	s, ok := t.Underlying().(*types.Slice)
+	if !ok {
+		return false
+	}
+	b, ok := s.Elem().Underlying().(*types.Basic)
+	return ok && b.Kind() == types.Uint8
+}
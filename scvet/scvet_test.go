@@ -0,0 +1,125 @@
+// Copyright (c) 2021 the SC authors. All rights reserved. MIT License.
+
+package scvet_test
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"testing"
+
+	"github.com/sc-lang/go-sc/scvet"
+	"golang.org/x/tools/go/analysis"
+)
+
+// stubImporter imports packages from testdata/src if they exist there
+// and falls back to the default importer for everything else.
+type stubImporter struct {
+	fset *token.FileSet
+	pkgs map[string]*types.Package
+}
+
+func (imp *stubImporter) Import(path string) (*types.Package, error) {
+	if pkg, ok := imp.pkgs[path]; ok {
+		return pkg, nil
+	}
+	dir := filepath.Join("testdata", "src", filepath.FromSlash(path))
+	if _, err := os.Stat(dir); err != nil {
+		return importer.Default().Import(path)
+	}
+	pkg, _, _, err := imp.check(path, dir)
+	if err != nil {
+		return nil, err
+	}
+	imp.pkgs[path] = pkg
+	return pkg, nil
+}
+
+// check parses and type checks the package in dir.
+func (imp *stubImporter) check(path, dir string) (*types.Package, []*ast.File, *types.Info, error) {
+	matches, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	var files []*ast.File
+	for _, m := range matches {
+		f, err := parser.ParseFile(imp.fset, m, nil, parser.ParseComments)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		files = append(files, f)
+	}
+	info := &types.Info{
+		Types: make(map[ast.Expr]types.TypeAndValue),
+		Defs:  make(map[*ast.Ident]types.Object),
+		Uses:  make(map[*ast.Ident]types.Object),
+	}
+	conf := types.Config{Importer: imp}
+	pkg, err := conf.Check(path, imp.fset, files, info)
+	return pkg, files, info, err
+}
+
+var wantRE = regexp.MustCompile("// want `([^`]*)`")
+
+// TestAnalyzer runs the analyzer on testdata/src/a and checks that the diagnostics
+// match the // want comments in the source, like analysistest.
+func TestAnalyzer(t *testing.T) {
+	fset := token.NewFileSet()
+	imp := &stubImporter{fset: fset, pkgs: make(map[string]*types.Package)}
+	pkg, files, info, err := imp.check("a", filepath.Join("testdata", "src", "a"))
+	if err != nil {
+		t.Fatalf("failed to type check testdata: %s", err)
+	}
+
+	type key struct {
+		file string
+		line int
+	}
+	want := make(map[key]*regexp.Regexp)
+	for _, f := range files {
+		for _, cg := range f.Comments {
+			for _, c := range cg.List {
+				if m := wantRE.FindStringSubmatch(c.Text); m != nil {
+					p := fset.Position(c.Pos())
+					want[key{p.Filename, p.Line}] = regexp.MustCompile(m[1])
+				}
+			}
+		}
+	}
+
+	var diagnostics []analysis.Diagnostic
+	pass := &analysis.Pass{
+		Analyzer:   scvet.Analyzer,
+		Fset:       fset,
+		Files:      files,
+		Pkg:        pkg,
+		TypesInfo:  info,
+		TypesSizes: types.SizesFor("gc", "amd64"),
+		Report:     func(d analysis.Diagnostic) { diagnostics = append(diagnostics, d) },
+	}
+	if _, err := scvet.Analyzer.Run(pass); err != nil {
+		t.Fatalf("analyzer failed: %s", err)
+	}
+	sort.Slice(diagnostics, func(i, j int) bool { return diagnostics[i].Pos < diagnostics[j].Pos })
+	for _, d := range diagnostics {
+		p := fset.Position(d.Pos)
+		k := key{p.Filename, p.Line}
+		re, ok := want[k]
+		switch {
+		case !ok:
+			t.Errorf("%s: unexpected diagnostic: %s", p, d.Message)
+		case !re.MatchString(d.Message):
+			t.Errorf("%s: diagnostic %q does not match %q", p, d.Message, re)
+		}
+		delete(want, k)
+	}
+	for k, re := range want {
+		t.Errorf("%s:%d: no diagnostic was reported matching %q", k.file, k.line, re)
+	}
+}
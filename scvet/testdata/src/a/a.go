@@ -0,0 +1,81 @@
+package a
+
+import (
+	"time"
+
+	"github.com/sc-lang/go-sc"
+	"github.com/sc-lang/go-sc/scparse"
+)
+
+type Valid struct {
+	Name     string            `sc:"name,required"`
+	Port     int               `sc:"port,string,omitempty"`
+	Created  time.Time         `sc:"created,format=2006-01-02"`
+	Updated  *time.Time        `sc:"updated,format=15:04"`
+	Key      []byte            `sc:"key,hex"`
+	Retry    *int              `sc:"retry,omitnil"`
+	Expires  time.Time         `sc:"expires,omitzero"`
+	Priority int               `sc:"priority,order=-1"`
+	Password string            `sc:"password,secret"`
+	Host     string            `sc:"host,asvar=server.host"`
+	Embedded                   // promoted fields
+	Inline   Embedded          `sc:",inline"`
+	Extra    map[string]string `sc:",remain"`
+	Skipped  string            `sc:"-"`
+	Dash     string            `sc:"-,"`
+	Trailing string            `sc:"trailing,"`
+	private  string
+}
+
+type Embedded struct {
+	A string `sc:"a"`
+}
+
+type BadTags struct {
+	Dash string    `sc:"-,omitempty"`         // want `field Dash: sc tag "-,omitempty" uses the key "-", use sc:"-" to ignore the field or sc:"-," to use the key`
+	A    string    `sc:a`                     // want `malformed struct tag for field A: the sc tag must have the form sc:"value"`
+	B    string    `sc:"b,omitmepty"`         // want `field B: unknown option "omitmepty"`
+	C    string    `sc:"c,,required"`         // want `field C: empty option in sc tag "c,,required"`
+	D    string    `sc:"d,required,required"` // want `field D: duplicate option "required"`
+	E    string    `sc:" e"`                  // want `field E: key " e" has leading or trailing spaces`
+	F    string    `sc:"f,-"`                 // want `field F: option "-" has no effect, use sc:"-" to ignore the field`
+	G    time.Time `sc:"g,format"`            // want `field G: format option requires a layout, ex: format=2006-01-02`
+	H    string    `sc:"h,order=first"`       // want `field H: order option requires an integer, ex: order=1`
+	I    string    `sc:"i,asvar=1st"`         // want `field I: asvar option requires a variable name, ex: asvar=port`
+}
+
+type Conflicts struct {
+	A string            `sc:"a,string"`       // want `field A: string option only applies to bools and numbers, not string`
+	B int               `sc:"b,format=15:04"` // want `field B: format option only applies to time.Time, not int`
+	C []byte            `sc:"c,hex,bytelist"` // want `field C: options hex and bytelist conflict`
+	D string            `sc:"d,base64url"`    // want `field D: base64url option only applies to \[\]byte, not string`
+	E map[int]string    `sc:"e,remain"`       // want `field E: remain option requires a map with string keys, not map\[int\]string`
+	F map[string]string `sc:"f,remain"`       // want `field F: key "f" is ignored since the field has the remain option`
+	G map[string]string `sc:",remain"`        // want `field G has the remain option but only the first remain field F is used`
+	H string            `sc:",inline"`        // want `field H: inline option requires a struct, not string`
+	I Embedded          `sc:"i,inline"`       // want `field I: key "i" is ignored since the field has the inline option`
+	J int               `sc:"j,omitnil"`      // want `field J: omitnil option only applies to pointers, interfaces, maps and slices, not int`
+}
+
+type Duplicates struct {
+	Name  string `sc:"name"`
+	Name2 string `sc:"name"` // want `field Name2 uses the key "name" which is already used by field Name, both fields will be ignored`
+	Other string
+	X     string `sc:"Other"` // want `field X uses the key "Other" which is already used by field Other, both fields will be ignored`
+}
+
+type T struct{}
+
+func (t *T) UnmarshalSC(n scparse.ValueNode, vars sc.Variables) error { return nil }
+func (t T) MarshalSC() (scparse.ValueNode, error)                     { return nil, nil }
+func (t T) ValidateSC() error                                         { return nil }
+func (t T) MarshalSCComments() scparse.CommentGroup                   { return scparse.CommentGroup{} }
+
+type U struct{}
+
+func (u *U) UnmarshalSC(n scparse.ValueNode) error   { return nil }  // want `method UnmarshalSC\(github.com/sc-lang/go-sc/scparse.ValueNode\) error should have signature UnmarshalSC\(scparse.ValueNode, sc.Variables\) error`
+func (u U) MarshalSC() scparse.ValueNode             { return nil }  // want `method MarshalSC\(\) github.com/sc-lang/go-sc/scparse.ValueNode should have signature MarshalSC\(\) \(scparse.ValueNode, error\)`
+func (u U) ValidateSC() bool                         { return true } // want `method ValidateSC\(\) bool should have signature ValidateSC\(\) error`
+func (u U) MarshalSCComments() *scparse.CommentGroup { return nil }  // want `method MarshalSCComments\(\) \*github.com/sc-lang/go-sc/scparse.CommentGroup should have signature MarshalSCComments\(\) scparse.CommentGroup`
+
+func UnmarshalSC() {} // Not a method
@@ -0,0 +1,4 @@
+// Package sc is a stub of the real package for testing.
+package sc
+
+type Variables map[string]interface{}
@@ -0,0 +1,6 @@
+// Package scparse is a stub of the real package for testing.
+package scparse
+
+type ValueNode interface{}
+
+type CommentGroup struct{}
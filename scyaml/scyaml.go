@@ -0,0 +1,620 @@
+// Copyright (c) 2021 the SC authors. All rights reserved. MIT License.
+
+// Package scyaml converts SC documents to YAML and YAML documents to SC.
+//
+// The order of keys is preserved and comments are converted in both directions.
+// YAML anchors and aliases are converted to SC anchors and aliases where possible.
+package scyaml
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/sc-lang/go-sc/internal/sccomment"
+	"github.com/sc-lang/go-sc/scparse"
+	"gopkg.in/yaml.v3"
+)
+
+// Option is an option that customizes the conversion performed by ToYAML and FromYAML.
+type Option func(*options)
+
+type options struct {
+	sortKeys bool
+	indent   int
+}
+
+// WithSortedKeys sets whether the keys of dictionaries and mappings are sorted.
+// By default, keys are kept in the order they appear in the input.
+func WithSortedKeys(b bool) Option {
+	return func(o *options) {
+		o.sortKeys = b
+	}
+}
+
+// WithIndent sets the number of spaces used for each level of indentation
+// in the output of ToYAML. The default is 2.
+func WithIndent(spaces int) Option {
+	return func(o *options) {
+		o.indent = spaces
+	}
+}
+
+func newOptions(opts []Option) options {
+	o := options{indent: 2}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// ToYAML converts the SC document data to YAML.
+//
+// Variables and expressions are replaced by their default values the same way as
+// scparse.ToGo, so a variable without a default value causes an
+// *scparse.UnresolvedVariableError to be returned. Anchors and aliases are expanded
+// since SC allows an alias to come before its anchor, which YAML does not.
+func ToYAML(data []byte, opts ...Option) ([]byte, error) {
+	n, err := scparse.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	return NodeToYAML(n, opts...)
+}
+
+// NodeToYAML is like ToYAML but converts the SC value n.
+func NodeToYAML(n scparse.ValueNode, opts ...Option) ([]byte, error) {
+	n, err := scparse.ExpandAnchors(n)
+	if err != nil {
+		return nil, err
+	}
+	o := newOptions(opts)
+	yn, err := toYAMLNode(n, o)
+	if err != nil {
+		return nil, err
+	}
+	doc := &yaml.Node{
+		Kind:        yaml.DocumentNode,
+		Content:     []*yaml.Node{yn},
+		HeadComment: yamlComment(n.Comments().Head),
+		LineComment: yamlComment(n.Comments().Inline),
+		FootComment: yamlComment(n.Comments().Foot),
+	}
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(o.indent)
+	if err := enc.Encode(doc); err != nil {
+		return nil, fmt.Errorf("scyaml: %w", err)
+	}
+	if err := enc.Close(); err != nil {
+		return nil, fmt.Errorf("scyaml: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// toYAMLNode converts n to a YAML node. The head and inline comments of n
+// are not set since where they belong depends on the parent.
+func toYAMLNode(n scparse.ValueNode, o options) (*yaml.Node, error) {
+	switch n := n.(type) {
+	case *scparse.NullNode:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null", Value: "null"}, nil
+	case *scparse.BoolNode:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: strconv.FormatBool(n.True)}, nil
+	case *scparse.NumberNode:
+		return yamlNumber(n), nil
+	case *scparse.RawStringNode:
+		return yamlString(n.Value), nil
+	case *scparse.MultilineStringNode:
+		yn := yamlString(n.Value)
+		yn.Style = yaml.LiteralStyle
+		return yn, nil
+	case *scparse.InterpolatedStringNode, *scparse.VariableNode, *scparse.ExpressionNode:
+		v, err := scparse.ToGo(n)
+		if err != nil {
+			return nil, err
+		}
+		switch v := v.(type) {
+		case nil:
+			return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null", Value: "null"}, nil
+		case bool:
+			return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: strconv.FormatBool(v)}, nil
+		case string:
+			return yamlString(v), nil
+		default:
+			panic(fmt.Errorf("impossible: unexpected value of type %T for %T", v, n))
+		}
+	case *scparse.ListNode:
+		yn := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		for _, el := range n.Elements {
+			yel, err := toYAMLNode(el, o)
+			if err != nil {
+				return nil, err
+			}
+			yel.HeadComment = yamlComment(el.Comments().Head)
+			yel.LineComment = joinComments(yel.LineComment, yamlComment(el.Comments().Inline))
+			yel.FootComment = yamlComment(el.Comments().Foot)
+			yn.Content = append(yn.Content, yel)
+		}
+		setInnerComment(yn, n.CommentGroup)
+		return yn, nil
+	case *scparse.DictionaryNode:
+		members := n.Members
+		if o.sortKeys {
+			members = append([]*scparse.MemberNode(nil), members...)
+			sort.SliceStable(members, func(i, j int) bool {
+				return members[i].Key.KeyString() < members[j].Key.KeyString()
+			})
+		}
+		yn := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		for _, m := range members {
+			key := yamlString(m.Key.KeyString())
+			key.HeadComment = yamlComment(sccomment.Concat(m.Comments().Head, m.Key.Comments().Head))
+			val, err := toYAMLNode(m.Value, o)
+			if err != nil {
+				return nil, err
+			}
+			inline := yamlComment(sccomment.Concat(m.Comments().Inline, m.Key.Comments().Inline, m.Value.Comments().Inline))
+			if len(val.Content) > 0 {
+				// The value starts on the next line, so comments on the line of the key
+				// and comments between the key and the value go on the key.
+				key.LineComment = joinComments(inline, yamlComment(m.Value.Comments().Head))
+			} else {
+				key.HeadComment = joinComments(key.HeadComment, yamlComment(m.Value.Comments().Head))
+				val.LineComment = joinComments(val.LineComment, inline)
+			}
+			// Foot comments of a mapping entry are attached to the key
+			key.FootComment = yamlComment(m.Comments().Foot)
+			yn.Content = append(yn.Content, key, val)
+		}
+		setInnerComment(yn, n.CommentGroup)
+		return yn, nil
+	}
+	panic(fmt.Errorf("impossible: invalid node type used as value: %T", n))
+}
+
+// setInnerComment sets the comments inside the empty sequence or mapping yn from cg.
+// YAML has no comments inside empty collections, so they are kept on the same line.
+func setInnerComment(yn *yaml.Node, cg scparse.CommentGroup) {
+	if len(yn.Content) == 0 {
+		yn.LineComment = yamlComment(cg.Inner)
+	}
+}
+
+// yamlComment converts SC comments to the text of a YAML comment.
+// Each line of a block comment becomes a separate line comment.
+func yamlComment(comments []scparse.Comment) string {
+	var lines []string
+	for _, c := range comments {
+		if !c.IsBlock {
+			lines = append(lines, "#"+c.Text)
+			continue
+		}
+		for _, l := range strings.Split(c.Text, "\n") {
+			lines = append(lines, "#"+strings.TrimRight(l, " \t\r"))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func joinComments(a, b string) string {
+	if a == "" || b == "" {
+		return a + b
+	}
+	return a + "\n" + b
+}
+
+func yamlString(s string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: s}
+}
+
+// yamlNumber returns the YAML representation of n. The literal is used if YAML
+// reads it as the same kind of number, otherwise it is formatted from the value of n.
+func yamlNumber(n *scparse.NumberNode) *yaml.Node {
+	// A literal like 1.0 is also an integer but should stay a float
+	isFloat := !n.IsInt && !n.IsUint
+	if n.Raw != "" {
+		isFloat = strings.ContainsAny(n.Raw, ".eE")
+	}
+	tag := "!!int"
+	if isFloat {
+		tag = "!!float"
+	}
+	if n.Raw != "" {
+		var probe yaml.Node
+		if err := yaml.Unmarshal([]byte(n.Raw), &probe); err == nil && len(probe.Content) == 1 {
+			if v := probe.Content[0]; v.Tag == tag && v.Value == n.Raw && sameNumber(v, n, isFloat) {
+				return &yaml.Node{Kind: yaml.ScalarNode, Tag: tag, Value: n.Raw}
+			}
+		}
+	}
+	var s string
+	switch {
+	case !isFloat && n.IsInt:
+		s = strconv.FormatInt(n.Int64, 10)
+	case !isFloat && n.IsUint:
+		s = strconv.FormatUint(n.Uint64, 10)
+	default:
+		s = strconv.FormatFloat(n.Float64, 'g', -1, 64)
+		if !strings.ContainsAny(s, ".e") {
+			// Keep it a float
+			s += ".0"
+		}
+	}
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: tag, Value: s}
+}
+
+// sameNumber reports whether the YAML scalar v has the same value as n.
+func sameNumber(v *yaml.Node, n *scparse.NumberNode, isFloat bool) bool {
+	switch {
+	case isFloat:
+		var f float64
+		return v.Decode(&f) == nil && f == n.Float64
+	case n.IsInt:
+		var i int64
+		return v.Decode(&i) == nil && i == n.Int64
+	case n.IsUint:
+		var u uint64
+		return v.Decode(&u) == nil && u == n.Uint64
+	}
+	return false
+}
+
+// FromYAML converts the YAML document data to SC. The document must contain a single
+// mapping or be empty. The result is formatted using scparse.Format.
+//
+// Strings are converted literally, ex: "${HOME}" is not converted to a variable.
+// Merge keys (<<) are expanded. YAML allows an anchor to be redefined but SC does not,
+// so aliases of a redefined anchor, or of an anchor whose name is not a valid SC anchor
+// name, are replaced by a copy of the anchored value. An error is returned if an alias
+// is used inside the value of its own anchor, ex: a: &x [1, *x]. WithIndent has no effect.
+func FromYAML(data []byte, opts ...Option) ([]byte, error) {
+	n, err := YAMLToNode(data, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return scparse.Format(n), nil
+}
+
+// YAMLToNode is like FromYAML but returns the SC AST instead of formatting it.
+func YAMLToNode(data []byte, opts ...Option) (*scparse.DictionaryNode, error) {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	var doc yaml.Node
+	if err := dec.Decode(&doc); err != nil {
+		if err == io.EOF {
+			return scparse.NewDict(), nil
+		}
+		return nil, fmt.Errorf("scyaml: invalid YAML: %w", err)
+	}
+	var extra yaml.Node
+	if err := dec.Decode(&extra); err != io.EOF {
+		if err == nil {
+			err = errors.New("multiple documents are not supported")
+		}
+		return nil, fmt.Errorf("scyaml: invalid YAML: %w", err)
+	}
+	if len(doc.Content) == 0 {
+		return scparse.NewDict(), nil
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, errors.New("scyaml: top level YAML value must be a mapping")
+	}
+	d := decoder{
+		opts:    newOptions(opts),
+		anchors: make(map[string]int),
+		emitted: make(map[*yaml.Node]bool),
+		active:  make(map[*yaml.Node]bool),
+	}
+	d.countAnchors(root)
+	n, err := d.decodeMapping(root)
+	if err != nil {
+		return nil, err
+	}
+	n.CommentGroup.Head = scComments(doc.HeadComment, root.HeadComment)
+	n.CommentGroup.Inline = scComments(doc.LineComment, root.LineComment)
+	n.CommentGroup.Foot = scComments(doc.FootComment)
+	return n, nil
+}
+
+// decoder converts YAML nodes to an SC AST.
+type decoder struct {
+	opts options
+	// anchors is the number of times each anchor name is defined.
+	anchors map[string]int
+	// emitted contains the anchored nodes that have been converted to an AnchorNode.
+	emitted map[*yaml.Node]bool
+	// active contains the anchored nodes that are being converted, used to detect
+	// aliases that reference their own anchor.
+	active map[*yaml.Node]bool
+}
+
+func (d *decoder) countAnchors(n *yaml.Node) {
+	if n.Anchor != "" {
+		d.anchors[n.Anchor]++
+	}
+	for _, c := range n.Content {
+		d.countAnchors(c)
+	}
+}
+
+// keepAnchor reports whether the anchor of n can be kept as an SC anchor.
+func (d *decoder) keepAnchor(n *yaml.Node) bool {
+	if n.Anchor == "" || d.anchors[n.Anchor] != 1 {
+		return false
+	}
+	for _, r := range n.Anchor {
+		if r != '_' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// checkAlias returns an error if the alias n references an anchor whose value is
+// being converted, ex: a: &x [1, *x]. SC does not allow anchors to reference themselves.
+func (d *decoder) checkAlias(n *yaml.Node) error {
+	if d.active[n.Alias] {
+		return d.errorf(n, "alias %q references its own anchor", n.Value)
+	}
+	return nil
+}
+
+func (d *decoder) decode(n *yaml.Node) (scparse.ValueNode, error) {
+	if n.Kind == yaml.AliasNode {
+		if err := d.checkAlias(n); err != nil {
+			return nil, err
+		}
+		return d.decode(n.Alias)
+	}
+	if n.Anchor != "" {
+		d.active[n] = true
+		defer delete(d.active, n)
+	}
+	if d.keepAnchor(n) {
+		name := &scparse.IdentifierNode{Name: n.Anchor}
+		if d.emitted[n] {
+			return &scparse.AliasNode{Name: name}, nil
+		}
+		d.emitted[n] = true
+		v, err := d.decodeValue(n)
+		if err != nil {
+			return nil, err
+		}
+		return &scparse.AnchorNode{Name: name, Value: v}, nil
+	}
+	return d.decodeValue(n)
+}
+
+func (d *decoder) decodeValue(n *yaml.Node) (scparse.ValueNode, error) {
+	switch n.Kind {
+	case yaml.MappingNode:
+		return d.decodeMapping(n)
+	case yaml.SequenceNode:
+		return d.decodeSequence(n)
+	case yaml.ScalarNode:
+		return d.decodeScalar(n)
+	}
+	return nil, d.errorf(n, "unsupported YAML node")
+}
+
+func (d *decoder) decodeScalar(n *yaml.Node) (scparse.ValueNode, error) {
+	switch n.ShortTag() {
+	case "!!null":
+		return scparse.NewNull(), nil
+	case "!!bool":
+		var b bool
+		if err := n.Decode(&b); err != nil {
+			return nil, d.errorf(n, "%v", err)
+		}
+		return scparse.NewBool(b), nil
+	case "!!int":
+		// Keep the literal if SC accepts it so it is printed the same way
+		if num, err := scparse.NewNumber(n.Value); err == nil {
+			return num, nil
+		}
+		var i int64
+		if err := n.Decode(&i); err == nil {
+			return scparse.NewInt(i), nil
+		}
+		var u uint64
+		if err := n.Decode(&u); err != nil {
+			return nil, d.errorf(n, "cannot convert number %s: %v", n.Value, err)
+		}
+		return scparse.NewUint(u), nil
+	case "!!float":
+		if num, err := scparse.NewNumber(n.Value); err == nil {
+			return num, nil
+		}
+		var f float64
+		if err := n.Decode(&f); err != nil {
+			return nil, d.errorf(n, "cannot convert number %s: %v", n.Value, err)
+		}
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return nil, d.errorf(n, "cannot convert number %s: %v cannot be represented in SC", n.Value, f)
+		}
+		return scparse.NewFloat(f), nil
+	}
+	// Strings and other types that have no SC equivalent, like timestamps, are kept as strings
+	if n.Style&(yaml.LiteralStyle|yaml.FoldedStyle) != 0 && strings.Contains(n.Value, "\n") &&
+		!strings.Contains(n.Value, `"""`) && !strings.Contains(n.Value, "\r") {
+		return &scparse.MultilineStringNode{Value: n.Value}, nil
+	}
+	return scparse.NewString(n.Value), nil
+}
+
+func (d *decoder) decodeSequence(n *yaml.Node) (*scparse.ListNode, error) {
+	l := scparse.NewList()
+	for _, yel := range n.Content {
+		el, err := d.decode(yel)
+		if err != nil {
+			return nil, err
+		}
+		cg := el.Comments()
+		cg.Head = scComments(yel.HeadComment)
+		cg.Inline = scComments(yel.LineComment)
+		cg.Foot = scComments(yel.FootComment)
+		l.Elements = append(l.Elements, el)
+	}
+	if len(l.Elements) == 0 {
+		l.CommentGroup.Inner = scComments(n.HeadComment, n.LineComment, n.FootComment)
+	} else {
+		last := l.Elements[len(l.Elements)-1].Comments()
+		last.Foot = append(last.Foot, scComments(n.FootComment)...)
+	}
+	return l, nil
+}
+
+func (d *decoder) decodeMapping(n *yaml.Node) (*scparse.DictionaryNode, error) {
+	dict := scparse.NewDict()
+	// Keys defined explicitly take precedence over merged keys
+	explicit := make(map[string]bool)
+	for i := 0; i < len(n.Content); i += 2 {
+		if !isMergeKey(n.Content[i]) {
+			key, err := d.key(n.Content[i])
+			if err != nil {
+				return nil, err
+			}
+			explicit[key] = true
+		}
+	}
+	seen := make(map[string]bool)
+	for i := 0; i < len(n.Content); i += 2 {
+		ykey, yval := n.Content[i], n.Content[i+1]
+		if isMergeKey(ykey) {
+			merged, err := d.merge(yval, explicit, seen)
+			if err != nil {
+				return nil, err
+			}
+			if len(merged) > 0 {
+				merged[0].CommentGroup.Head = scComments(ykey.HeadComment)
+				merged[len(merged)-1].CommentGroup.Foot = scComments(ykey.FootComment, yval.FootComment)
+			}
+			dict.Members = append(dict.Members, merged...)
+			continue
+		}
+		key, err := d.key(ykey)
+		if err != nil {
+			return nil, err
+		}
+		if seen[key] {
+			return nil, d.errorf(ykey, "duplicate key %q", key)
+		}
+		seen[key] = true
+		val, err := d.decode(yval)
+		if err != nil {
+			return nil, err
+		}
+		m := scparse.NewMember(key, val)
+		m.CommentGroup.Head = scComments(ykey.HeadComment)
+		m.CommentGroup.Foot = scComments(ykey.FootComment, yval.FootComment)
+		// A comment is only on the line of the key if the value is on the next line, ex: a mapping.
+		// SC keeps comments between a key and its value before the value.
+		val.Comments().Head = scComments(ykey.LineComment, yval.HeadComment)
+		val.Comments().Inline = scComments(yval.LineComment)
+		dict.Members = append(dict.Members, m)
+	}
+	if len(dict.Members) == 0 {
+		dict.CommentGroup.Inner = scComments(n.HeadComment, n.LineComment, n.FootComment)
+	} else {
+		last := &dict.Members[len(dict.Members)-1].CommentGroup
+		last.Foot = append(last.Foot, scComments(n.FootComment)...)
+	}
+	if d.opts.sortKeys {
+		sort.SliceStable(dict.Members, func(i, j int) bool {
+			return dict.Members[i].Key.KeyString() < dict.Members[j].Key.KeyString()
+		})
+	}
+	return dict, nil
+}
+
+// merge returns the members of the mappings referenced by the merge key value v
+// that are not in explicit or seen. Mappings earlier in a sequence take precedence.
+func (d *decoder) merge(v *yaml.Node, explicit, seen map[string]bool) ([]*scparse.MemberNode, error) {
+	if v.Kind == yaml.AliasNode {
+		if err := d.checkAlias(v); err != nil {
+			return nil, err
+		}
+		v = v.Alias
+	}
+	var sources []*yaml.Node
+	switch v.Kind {
+	case yaml.MappingNode:
+		sources = []*yaml.Node{v}
+	case yaml.SequenceNode:
+		for _, s := range v.Content {
+			if s.Kind == yaml.AliasNode {
+				if err := d.checkAlias(s); err != nil {
+					return nil, err
+				}
+				s = s.Alias
+			}
+			if s.Kind != yaml.MappingNode {
+				return nil, d.errorf(s, "merge key value must be a mapping or a sequence of mappings")
+			}
+			sources = append(sources, s)
+		}
+	default:
+		return nil, d.errorf(v, "merge key value must be a mapping or a sequence of mappings")
+	}
+	var members []*scparse.MemberNode
+	for _, s := range sources {
+		// Convert the whole mapping so that nested merge keys are expanded
+		if s.Anchor != "" {
+			d.active[s] = true
+		}
+		dict, err := d.decodeMapping(s)
+		delete(d.active, s)
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range dict.Members {
+			key := m.Key.KeyString()
+			if explicit[key] || seen[key] {
+				continue
+			}
+			seen[key] = true
+			members = append(members, scparse.NewMember(key, m.Value))
+		}
+	}
+	return members, nil
+}
+
+func isMergeKey(n *yaml.Node) bool {
+	return n.Kind == yaml.ScalarNode && n.ShortTag() == "!!merge"
+}
+
+// key returns the SC key for the YAML mapping key n, which must be a scalar.
+func (d *decoder) key(n *yaml.Node) (string, error) {
+	if n.Kind == yaml.AliasNode {
+		n = n.Alias
+	}
+	if n.Kind != yaml.ScalarNode {
+		return "", d.errorf(n, "mapping key must be a scalar")
+	}
+	return n.Value, nil
+}
+
+// scComments converts the text of YAML comments to SC line comments.
+func scComments(texts ...string) []scparse.Comment {
+	var comments []scparse.Comment
+	for _, t := range texts {
+		for _, l := range strings.Split(t, "\n") {
+			l = strings.TrimSpace(l)
+			if l == "" {
+				continue
+			}
+			comments = append(comments, scparse.Comment{Text: strings.TrimPrefix(l, "#")})
+		}
+	}
+	return comments
+}
+
+func (d *decoder) errorf(n *yaml.Node, format string, args ...interface{}) error {
+	return fmt.Errorf("scyaml: %d:%d: %s", n.Line, n.Column, fmt.Sprintf(format, args...))
+}
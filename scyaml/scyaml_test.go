@@ -0,0 +1,234 @@
+// Copyright (c) 2021 the SC authors. All rights reserved. MIT License.
+
+package scyaml_test
+
+import (
+	"testing"
+
+	"github.com/sc-lang/go-sc/scparse"
+	"github.com/sc-lang/go-sc/scyaml"
+)
+
+func TestFromYAML(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		opts  []scyaml.Option
+		want  string
+	}{
+		{
+			name: "comments",
+			input: `# Service config
+
+# The name
+name: api # inline
+limits: # on key
+  # before cpu
+  cpu: 2
+  # after cpu
+tags:
+  - a # first
+  - b
+  # after b
+`,
+			want: `// Service config
+{
+  // The name
+  name: "api" // inline
+  limits:
+    // on key
+    {
+      // before cpu
+      cpu: 2
+      // after cpu
+    }
+  tags: [
+    "a" // first
+    "b"
+    // after b
+  ]
+}
+`,
+		},
+		{
+			name: "values",
+			input: `numbers: [1.0, 007, 0x1F, 1_000, .5, 2e3, 18446744073709551615]
+strings: ["${x}", 2001-12-14, 'it''s']
+text: |
+  line 1
+  line 2
+other: [~, true, off]
+`,
+			want: `{
+  numbers: [1.0, 007, 31, 1000, 0.5, 2e3, 18446744073709551615]
+  strings: ["\${x}", "2001-12-14", "it's"]
+  text: """
+    line 1
+    line 2
+
+    """
+  other: [null, true, "off"]
+}
+`,
+		},
+		{
+			name: "anchors",
+			input: `base: &base
+  cpu: 2
+  mem: 512
+worker:
+  <<: *base
+  mem: 1024
+copy: *base
+dashed: &a-b [1]
+again: *a-b
+`,
+			want: `{
+  base: &base { cpu: 2, mem: 512 }
+  worker: { cpu: 2, mem: 1024 }
+  copy: *base
+  dashed: [1]
+  again: [1]
+}
+`,
+		},
+		{
+			name:  "sorted keys",
+			input: "b: 1\na: {d: 1, c: 2}\n",
+			opts:  []scyaml.Option{scyaml.WithSortedKeys(true)},
+			want:  "{\n  a: { c: 2, d: 1 }\n  b: 1\n}\n",
+		},
+		{
+			name:  "empty document",
+			input: "# nothing\n",
+			want:  "{}\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n, err := scyaml.YAMLToNode([]byte(tt.input), tt.opts...)
+			if err != nil {
+				t.Fatalf("unexpected error %s", err)
+			}
+			got := scparse.FormatWithOptions(n, scparse.FormatOptions{LineWidth: 80})
+			if string(got) != tt.want {
+				t.Errorf("got SC\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFromYAMLError(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"not a mapping", "[1, 2]", "scyaml: top level YAML value must be a mapping"},
+		{"multiple documents", "a: 1\n---\nb: 2\n", "scyaml: invalid YAML: multiple documents are not supported"},
+		{"syntax error", "a: [", "scyaml: invalid YAML: yaml: line 1: did not find expected node content"},
+		{"infinity", "a: .inf", "scyaml: 1:4: cannot convert number .inf: +Inf cannot be represented in SC"},
+		{"complex key", "? [a]\n: 1\n", "scyaml: 1:3: mapping key must be a scalar"},
+		{"duplicate key", "a: 1\na: 2\n", `scyaml: 2:1: duplicate key "a"`},
+		{"bad merge", "a:\n  <<: 1\n", "scyaml: 2:7: merge key value must be a mapping or a sequence of mappings"},
+		{"self alias", "a: &x [1, *x]\n", `scyaml: 1:11: alias "x" references its own anchor`},
+		{"self merge", "a: &x\n  b: 1\n  <<: *x\n", `scyaml: 3:7: alias "x" references its own anchor`},
+		{"alias cycle", "a: &x\n  b: &y\n    c: *x\n", `scyaml: 3:8: alias "x" references its own anchor`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := scyaml.FromYAML([]byte(tt.input))
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("got error %v, want %s", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestToYAML(t *testing.T) {
+	input := `// Service config
+{
+  // The name
+  name: "api" // inline
+  port: ${port:-8080}
+  ratio: 1.0
+  big: 1e3
+  limits: &limits {
+    cpu: 2
+    // after cpu
+  }
+  worker: *limits
+  tags: [` + "`a`" + `, "b"]
+  empty: {}
+  text: """
+    line 1
+    line 2
+    """
+}`
+	tests := []struct {
+		name string
+		opts []scyaml.Option
+		want string
+	}{
+		{
+			name: "default",
+			want: `# Service config
+
+# The name
+name: api # inline
+port: "8080"
+ratio: 1.0
+big: 1e3
+limits:
+  cpu: 2
+  # after cpu
+worker:
+  cpu: 2
+  # after cpu
+tags:
+  - a
+  - b
+empty: {}
+text: |-
+  line 1
+  line 2
+`,
+		},
+		{
+			name: "sorted keys and indent",
+			opts: []scyaml.Option{scyaml.WithSortedKeys(true), scyaml.WithIndent(4)},
+			want: `# Service config
+
+big: 1e3
+empty: {}
+limits:
+    cpu: 2
+    # after cpu
+# The name
+name: api # inline
+port: "8080"
+ratio: 1.0
+tags:
+    - a
+    - b
+text: |-
+    line 1
+    line 2
+worker:
+    cpu: 2
+    # after cpu
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := scyaml.ToYAML([]byte(input), tt.opts...)
+			if err != nil {
+				t.Fatalf("unexpected error %s", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("got YAML\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+// Copyright (c) 2021 the SC authors. All rights reserved. MIT License.
+
+package sc
+
+import (
+	"reflect"
+
+	"github.com/sc-lang/go-sc/scparse"
+)
+
+var sourceInfoType = reflect.TypeOf(SourceInfo{})
+
+// SourceInfo holds the positions in the SC input of the dictionary a struct was
+// unmarshaled from. This allows errors found after unmarshaling, ex: by validation
+// logic in an application, to refer to the SC source.
+//
+// A struct field of type SourceInfo is not matched to a dictionary member. Instead,
+// Unmarshal sets it to the positions of the dictionary the struct is unmarshaled from.
+// The field is left unchanged if the struct is not unmarshaled from a dictionary,
+// ex: from a variable. Only the first SourceInfo field of a struct is used,
+// including the fields of embedded structs. SourceInfo fields are ignored by Marshal.
+type SourceInfo struct {
+	Pos  scparse.Pos            // Position of the dictionary.
+	Keys map[string]scparse.Pos // Position of the key of each member, by key.
+}
+
+// KeyPos returns the position of the key of the member with the given key.
+// If the dictionary has no such member, the position of the dictionary is returned.
+func (s SourceInfo) KeyPos(key string) scparse.Pos {
+	if pos, ok := s.Keys[key]; ok {
+		return pos
+	}
+	return s.Pos
+}
+
+// setSourceInfo sets the field f of the struct v to the positions of n.
+func (d *decoder) setSourceInfo(n *scparse.DictionaryNode, v reflect.Value, f *field) {
+	sv := d.fieldValue(v, f.index)
+	if !sv.IsValid() {
+		return
+	}
+	info := SourceInfo{Pos: n.Pos, Keys: make(map[string]scparse.Pos, len(n.Members))}
+	for _, mn := range n.Members {
+		key := mn.Key.KeyString()
+		if _, ok := info.Keys[key]; !ok {
+			info.Keys[key] = mn.Key.Position()
+		}
+	}
+	sv.Set(reflect.ValueOf(info))
+}
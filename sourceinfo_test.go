@@ -0,0 +1,110 @@
+// Copyright (c) 2021 the SC authors. All rights reserved. MIT License.
+
+package sc_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/sc-lang/go-sc"
+	"github.com/sc-lang/go-sc/scparse"
+)
+
+func TestSourceInfo(t *testing.T) {
+	type Meta struct {
+		sc.SourceInfo
+		Owner string `sc:"owner"`
+	}
+	type Service struct {
+		Source sc.SourceInfo
+		Name   string `sc:"name"`
+		Port   int    `sc:"port"`
+		Meta   `sc:",inline"`
+	}
+	type Config struct {
+		Services []Service `sc:"services"`
+		Meta     *Meta     `sc:"meta"`
+	}
+	input := []byte(`{
+  services: [
+    {
+      name: "api"
+      port: 80
+    }
+    { name: "web", owner: "ops" }
+  ]
+  meta: {
+    owner: "dev"
+  }
+}`)
+	want := Config{
+		Services: []Service{
+			{
+				Source: sc.SourceInfo{
+					Pos: scparse.Pos{Line: 3, Column: 5, Byte: 20},
+					Keys: map[string]scparse.Pos{
+						"name": {Line: 4, Column: 7, Byte: 28},
+						"port": {Line: 5, Column: 7, Byte: 46},
+					},
+				},
+				Name: "api",
+				Port: 80,
+			},
+			{
+				Source: sc.SourceInfo{
+					Pos: scparse.Pos{Line: 7, Column: 5, Byte: 65},
+					Keys: map[string]scparse.Pos{
+						"name":  {Line: 7, Column: 7, Byte: 67},
+						"owner": {Line: 7, Column: 20, Byte: 80},
+					},
+				},
+				Name: "web",
+				Meta: Meta{Owner: "ops"},
+			},
+		},
+		Meta: &Meta{
+			SourceInfo: sc.SourceInfo{
+				Pos:  scparse.Pos{Line: 9, Column: 9, Byte: 107},
+				Keys: map[string]scparse.Pos{"owner": {Line: 10, Column: 5, Byte: 113}},
+			},
+			Owner: "dev",
+		},
+	}
+
+	var got Config
+	if err := sc.Unmarshal(input, &got); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got\n\t%+v\nwant\n\t%+v", got, want)
+	}
+
+	td, err := sc.CompileType(reflect.TypeOf(Config{}))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	got = Config{}
+	if err := td.Unmarshal(input, &got); err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got from TypeDecoder\n\t%+v\nwant\n\t%+v", got, want)
+	}
+
+	s := got.Services[0].Source
+	if pos := s.KeyPos("port"); pos != (scparse.Pos{Line: 5, Column: 7, Byte: 46}) {
+		t.Errorf("got position of port %v", pos)
+	}
+	if pos := s.KeyPos("missing"); pos != s.Pos {
+		t.Errorf("got position of missing key %v, want %v", pos, s.Pos)
+	}
+
+	// SourceInfo fields are not marshaled
+	b, err := sc.Marshal(got.Services[0])
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if want := "{\n  name: \"api\"\n  port: 80\n  owner: \"\"\n}\n"; string(b) != want {
+		t.Errorf("got marshaled value\n\t%#v\nwant\n\t%#v", string(b), want)
+	}
+}